@@ -26,6 +26,23 @@ func (j BirthDate) MarshalJSON() ([]byte, error) {
 	return json.Marshal(formattedDate)
 }
 
+// Check if the date has not been given, like for the users which are not
+// signed up through the api
+func (j BirthDate) IsZero() bool {
+	return time.Time(j).IsZero()
+}
+
+// Returns the age, in full years, at the given time of someone born on
+// the date
+func (j BirthDate) Age(at time.Time) int {
+	born := time.Time(j)
+	age := at.Year() - born.Year()
+	if at.Month() < born.Month() || (at.Month() == born.Month() && at.Day() < born.Day()) {
+		age--
+	}
+	return age
+}
+
 // Format function for printing your date
 func (j BirthDate) Format(s string) string {
 	t := time.Time(j)
@@ -2,6 +2,7 @@ package bindings
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -29,3 +30,19 @@ func TestBirthdate(t *testing.T) {
 		assert.Error(err)
 	})
 }
+
+func TestBirthdateAge(t *testing.T) {
+	assert := require.New(t)
+	birthdate := BirthDate(time.Date(2008, time.March, 15, 0, 0, 0, 0, time.UTC))
+
+	t.Run("Test age is given in full years", func(t *testing.T) {
+		assert.Equal(17, birthdate.Age(time.Date(2026, time.March, 14, 0, 0, 0, 0, time.UTC)))
+		assert.Equal(18, birthdate.Age(time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)))
+		assert.Equal(18, birthdate.Age(time.Date(2026, time.December, 1, 0, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("Test missing birthdate", func(t *testing.T) {
+		assert.True(BirthDate{}.IsZero())
+		assert.False(birthdate.IsZero())
+	})
+}
@@ -17,14 +17,12 @@ func (e ScopeNotFoundError) Error() string {
 }
 
 var validScopes = map[string]bool{
-	security.ScopeUserAuthorizeApp:   true,
-	security.ScopeUserVerify:         true,
-	security.ScopeUserChangePassword: true,
-	security.ScopeUserRead:           true,
-	security.ScopeUserWrite:          true,
-	security.ScopeUserDelete:         true,
-	security.ScopeAppRead:            true,
-	security.ScopeAppWrite:           true,
+	security.ScopeUserAuthorizeApp: true,
+	security.ScopeUserRead:         true,
+	security.ScopeUserWrite:        true,
+	security.ScopeUserDelete:       true,
+	security.ScopeAppRead:          true,
+	security.ScopeAppWrite:         true,
 }
 
 // Scope binding
@@ -35,6 +35,13 @@ func TestScope(t *testing.T) {
 		assert.Equal(expectedError.Error(), expectedMsg)
 	})
 
+	t.Run("Test export scope cannot be requested by apps", func(t *testing.T) {
+		var scope Scope
+		err := scope.UnmarshalJSON([]byte(security.ScopeUserExport))
+
+		assert.IsType(ScopeNotFoundError{}, err)
+	})
+
 	t.Run("Test ScopeArrayToStringArray", func(t *testing.T) {
 		scopes := []Scope{security.ScopeAppRead, security.ScopeUserAuthorizationCode}
 		stringScopes := ScopeArrayToStringArray(scopes)
@@ -8,6 +8,7 @@ import (
 	"gandalf/services"
 	"gandalf/validators"
 	"os"
+	"strings"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/thatisuday/commando"
@@ -67,7 +68,10 @@ func main() {
 				os.Exit(1)
 			}
 
-			userService.Verificate(user)
+			if err := userService.Verificate(user, nil); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
 			fmt.Printf("User %s created successfully\n", user.Name)
 		})
 
@@ -103,7 +107,7 @@ func main() {
 				os.Exit(1)
 			}
 
-			app, err := appService.Create(input, *user)
+			app, err := appService.Create(input, *user, nil, services.AuditContext{})
 			if err != nil {
 				fmt.Print(err)
 				os.Exit(1)
@@ -112,7 +116,61 @@ func main() {
 			fmt.Printf("Client ID: %s\nClient secret: %s\nRedirect Url: %s", app.ClientID, app.ClientSecret, app.RedirectUrls)
 		})
 
+	// configure role commands
+	commando.
+		Register("list-roles").
+		SetShortDescription("Lists the roles of the gandalf database").
+		SetDescription("Prints every role with the scopes it grants").
+		SetAction(func(args map[string]commando.ArgValue, flags map[string]commando.FlagValue) {
+			db := connections.NewGormPostgresConnection().Connect()
+			roleService := services.NewRoleService(db)
+			for _, role := range roleService.List() {
+				fmt.Printf("%s: %s\n", role.Name, strings.Join(role.Scopes(), " "))
+			}
+		})
+
+	commando.
+		Register("assign-role").
+		SetShortDescription("Assigns a role to an user").
+		SetDescription("Assigns the given role to the user with the given email").
+		AddFlag("email,e", "user email", commando.String, nil). // required
+		AddFlag("role,r", "role name", commando.String, nil).   // required
+		SetAction(func(args map[string]commando.ArgValue, flags map[string]commando.FlagValue) {
+			changeUserRole(flags, services.IRoleService.Assign)
+			fmt.Printf("Role %s assigned successfully\n", flags["role"].Value)
+		})
+
+	commando.
+		Register("revoke-role").
+		SetShortDescription("Revokes a role from an user").
+		SetDescription("Revokes the given role from the user with the given email").
+		AddFlag("email,e", "user email", commando.String, nil). // required
+		AddFlag("role,r", "role name", commando.String, nil).   // required
+		SetAction(func(args map[string]commando.ArgValue, flags map[string]commando.FlagValue) {
+			changeUserRole(flags, services.IRoleService.Revoke)
+			fmt.Printf("Role %s revoked successfully\n", flags["role"].Value)
+		})
+
 	// parse command-line arguments
 	commando.Parse(nil)
 
 }
+
+// Assigns or revokes the role given in the flags to the user given in the flags
+func changeUserRole(flags map[string]commando.FlagValue, change func(services.IRoleService, models.User, string) error) {
+	email, _ := flags["email"].GetString()
+	role, _ := flags["role"].GetString()
+
+	db := connections.NewGormPostgresConnection().Connect()
+	userService := services.NewUserService(db)
+	user, err := userService.ReadByEmail(email)
+	if err != nil {
+		fmt.Printf("User %s does not exist\n", email)
+		os.Exit(1)
+	}
+
+	if err := change(services.NewRoleService(db), *user, role); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,1529 @@
+package controllers
+
+import (
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// Register admin endpoints to the given router
+func RegisterAdminRoutes(
+	router *gin.Engine,
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	authService services.IAuthService,
+	userService services.IUserService,
+	outboxService services.IOutboxService,
+	adminActionService services.IAdminActionService,
+	roleService services.IRoleService,
+	sessionService services.ISessionService,
+	auditService services.IAuditService,
+	templateService services.INotificationTemplateService,
+	identityProviderService services.IIdentityProviderService,
+	scimService services.ISCIMService,
+	attributeService services.IAttributeService,
+	legalService services.ILegalService,
+	phoneService services.IPhoneService,
+	phoneThrottler security.IThrottler,
+) {
+	controller := AdminController{
+		phones: PhoneController{
+			phoneService: phoneService,
+			throttler:    phoneThrottler,
+		},
+		templateService:         templateService,
+		identityProviderService: identityProviderService,
+		scimService:             scimService,
+		attributeService:        attributeService,
+		legalService:            legalService,
+		sessionService:          sessionService,
+		auditService:            auditService,
+		authService:             authService,
+		userService:             userService,
+		outboxService:           outboxService,
+		adminActionService:      adminActionService,
+		roleService:             roleService,
+		authMiddleware:          authBearerMiddleware,
+	}
+
+	publicRoutes := router.Group("/admin")
+	{
+		publicRoutes.POST("/login", controller.Login)
+	}
+
+	readRoutes := router.Group("/admin/users")
+	{
+		scopes := []string{security.ScopeUserReadAll}
+		readRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		readRoutes.GET("", controller.ListUsers)
+		readRoutes.GET("/:uuid", controller.ReadUser)
+		readRoutes.GET("/:uuid/sessions", controller.ListUserSessions)
+	}
+
+	writeRoutes := router.Group("/admin/users")
+	{
+		scopes := []string{security.ScopeUserWriteAll}
+		writeRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		writeRoutes.POST("/:uuid/disable", controller.DisableUser)
+		writeRoutes.POST("/:uuid/enable", controller.EnableUser)
+		writeRoutes.POST("/:uuid/verify", controller.VerifyUser)
+		writeRoutes.POST("/:uuid/reset-password", controller.ResetUserPassword)
+		writeRoutes.DELETE("/:uuid/sessions", controller.RevokeUserSessions)
+		writeRoutes.DELETE("/:uuid/sessions/:session", controller.RevokeUserSession)
+		writeRoutes.PATCH("/:uuid/attributes", controller.SetUserAttributes)
+	}
+
+	deleteRoutes := router.Group("/admin/users")
+	{
+		scopes := []string{security.ScopeUserDeleteAll}
+		deleteRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		deleteRoutes.DELETE("/:uuid", controller.DeleteUser)
+	}
+
+	impersonateRoutes := router.Group("/admin/users")
+	{
+		scopes := []string{security.ScopeUserImpersonateAll}
+		impersonateRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		impersonateRoutes.POST("/:uuid/impersonate", controller.ImpersonateUser)
+	}
+
+	readRoleRoutes := router.Group("/admin")
+	{
+		scopes := []string{security.ScopeRoleReadAll}
+		readRoleRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		readRoleRoutes.GET("/roles", controller.ListRoles)
+		readRoleRoutes.GET("/users/:uuid/roles", controller.ReadUserRoles)
+	}
+
+	writeRoleRoutes := router.Group("/admin")
+	{
+		scopes := []string{security.ScopeRoleWriteAll}
+		writeRoleRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		writeRoleRoutes.PUT("/users/:uuid/roles/:role", controller.AssignRole)
+		writeRoleRoutes.DELETE("/users/:uuid/roles/:role", controller.RevokeRole)
+	}
+
+	readAuditRoutes := router.Group("/admin")
+	{
+		scopes := []string{security.ScopeAuditReadAll}
+		readAuditRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		readAuditRoutes.GET("/audit-events", controller.ListAuditEvents)
+	}
+
+	readOutboxRoutes := router.Group("/admin")
+	{
+		scopes := []string{security.ScopeOutboxReadAll}
+		readOutboxRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		readOutboxRoutes.GET("/outbox", controller.ListOutboxMessages)
+	}
+
+	readTemplateRoutes := router.Group("/admin/notification-templates")
+	{
+		scopes := []string{security.ScopeTemplateReadAll}
+		readTemplateRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		readTemplateRoutes.GET("", controller.ListNotificationTemplates)
+		readTemplateRoutes.POST("/preview", controller.PreviewNotification)
+	}
+
+	writeTemplateRoutes := router.Group("/admin/notification-templates")
+	{
+		scopes := []string{security.ScopeTemplateWriteAll}
+		writeTemplateRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		writeTemplateRoutes.PUT("/:kind/:locale", controller.SaveNotificationTemplate)
+		writeTemplateRoutes.DELETE("/:kind/:locale", controller.DeleteNotificationTemplate)
+	}
+
+	readProviderRoutes := router.Group("/admin/identity-providers")
+	{
+		scopes := []string{security.ScopeProviderReadAll}
+		readProviderRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		readProviderRoutes.GET("", controller.ListIdentityProviders)
+		readProviderRoutes.GET("/:name", controller.ReadIdentityProvider)
+	}
+
+	writeProviderRoutes := router.Group("/admin/identity-providers")
+	{
+		scopes := []string{security.ScopeProviderWriteAll}
+		writeProviderRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		writeProviderRoutes.POST("", controller.CreateIdentityProvider)
+		writeProviderRoutes.PATCH("/:name", controller.UpdateIdentityProvider)
+		writeProviderRoutes.DELETE("/:name", controller.DeleteIdentityProvider)
+	}
+
+	readSCIMRoutes := router.Group("/admin/scim-tokens")
+	{
+		scopes := []string{security.ScopeSCIMReadAll}
+		readSCIMRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		readSCIMRoutes.GET("", controller.ListSCIMTokens)
+	}
+
+	writeSCIMRoutes := router.Group("/admin/scim-tokens")
+	{
+		scopes := []string{security.ScopeSCIMWriteAll}
+		writeSCIMRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		writeSCIMRoutes.POST("", controller.CreateSCIMToken)
+		writeSCIMRoutes.DELETE("/:uuid", controller.DeleteSCIMToken)
+	}
+
+	readAttributeRoutes := router.Group("/admin/attributes")
+	{
+		scopes := []string{security.ScopeAttributeReadAll}
+		readAttributeRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		readAttributeRoutes.GET("", controller.ListAttributeDefinitions)
+	}
+
+	writeAttributeRoutes := router.Group("/admin/attributes")
+	{
+		scopes := []string{security.ScopeAttributeWriteAll}
+		writeAttributeRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		writeAttributeRoutes.POST("", controller.CreateAttributeDefinition)
+		writeAttributeRoutes.PATCH("/:name", controller.UpdateAttributeDefinition)
+		writeAttributeRoutes.DELETE("/:name", controller.DeleteAttributeDefinition)
+	}
+
+	readLegalRoutes := router.Group("/admin")
+	{
+		scopes := []string{security.ScopeLegalReadAll}
+		readLegalRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		readLegalRoutes.GET("/legal-documents", controller.ListLegalDocuments)
+		readLegalRoutes.GET("/users/:uuid/legal-acceptances", controller.ListUserLegalAcceptances)
+	}
+
+	writeLegalRoutes := router.Group("/admin/legal-documents")
+	{
+		scopes := []string{security.ScopeLegalWriteAll}
+		writeLegalRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		writeLegalRoutes.POST("", controller.CreateLegalDocument)
+		writeLegalRoutes.POST("/:uuid/publish", controller.PublishLegalDocument)
+		writeLegalRoutes.DELETE("/:uuid", controller.DeleteLegalDocument)
+	}
+}
+
+// Controller for /admin endpoints
+type AdminController struct {
+	phones                  PhoneController
+	authService             services.IAuthService
+	userService             services.IUserService
+	outboxService           services.IOutboxService
+	adminActionService      services.IAdminActionService
+	roleService             services.IRoleService
+	sessionService          services.ISessionService
+	auditService            services.IAuditService
+	templateService         services.INotificationTemplateService
+	identityProviderService services.IIdentityProviderService
+	scimService             services.ISCIMService
+	attributeService        services.IAttributeService
+	legalService            services.ILegalService
+	authMiddleware          middlewares.IAuthBearerMiddleware
+}
+
+// Records the given action performed by the staff user who performs the
+// request. Changes are only recorded once they succeed, so rejected ones
+// are not logged. Returns false and aborts the request if it cannot be
+// recorded.
+func (controller AdminController) record(c *gin.Context, action string, target *models.User, detail string) bool {
+	staff := controller.authMiddleware.GetAuthorizedUser(c)
+	if err := controller.adminActionService.Record(action, *staff, target, detail, c.ClientIP()); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return false
+	}
+	return true
+}
+
+// Returns the given action performed by the staff user who performs the
+// request, for the services which record it along with the change
+func (controller AdminController) adminAction(c *gin.Context, action string) *services.AdminActionContext {
+	staff := controller.authMiddleware.GetAuthorizedUser(c)
+	return &services.AdminActionContext{Action: action, Staff: *staff, IP: c.ClientIP()}
+}
+
+// Reads the user given in the uri. Returns nil and aborts the request if
+// it does not exist.
+func (controller AdminController) readTarget(c *gin.Context) *models.User {
+	var input validators.UserReadData
+	if err := c.ShouldBindUri(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return nil
+	}
+
+	user, err := controller.userService.Read(uuid.FromStringOrNil(input.UUID))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+	return user
+}
+
+// @Summary Login admin
+// @Description Logs a staff user into the admin api. The issued scopes are
+// @Description the staff scopes granted by the user roles. The current
+// @Description mandatory legal documents must be accepted as in any login.
+// @ID admin-login
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param user body validators.Credentials true "Logs into the admin api with the given credentials"
+// @Success 200 {object} serializers.TokensSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 401 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 429 {object} helpers.HTTPError
+// @Router /admin/login [post]
+func (controller AdminController) Login(c *gin.Context) {
+	var input validators.Credentials
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	user, err := controller.authService.Authenticate(input, true, helpers.NewClientInfo(c))
+	if err != nil {
+		controller.phones.abortLogin(c, user, err)
+		return
+	}
+
+	_, scopes := security.SplitStaffScopes(controller.roleService.UserScopes(*user))
+	if len(scopes) == 0 {
+		helpers.AbortWithStatus(c, http.StatusForbidden, StaffScopesNotGrantedError{})
+		return
+	}
+
+	if err := controller.adminActionService.Record(models.AdminActionLogin, *user, nil, "", c.ClientIP()); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	tokens, err := controller.authService.StartSession(*user, helpers.NewClientInfo(c), scopes)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewTokensSerializer(*tokens))
+}
+
+// @Summary List users
+// @Description List and search users by email, name or surname
+// @ID admin-users-list
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param q query string false "search term"
+// @Param page query int false "cursor's page"
+// @Param limit query int false "cursor's limit"
+// @Success 200 {object} serializers.PaginatedAdminUsersSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:all:read]
+// @Router /admin/users [get]
+func (controller AdminController) ListUsers(c *gin.Context) {
+	var input validators.AdminUserListQuery
+	if err := c.ShouldBindQuery(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !controller.record(c, models.AdminActionListUsers, nil, input.Search) {
+		return
+	}
+
+	cursor := helpers.NewCursor(input.Page, input.PageSize)
+	users := controller.userService.List(input.Search, &cursor)
+	c.JSON(http.StatusOK, serializers.NewPaginatedAdminUsersSerializer(users, cursor))
+}
+
+// @Summary Get an user
+// @Description get any user by his uuid
+// @ID admin-users-read
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Success 200 {object} serializers.AdminUserSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:all:read]
+// @Router /admin/users/{uuid} [get]
+func (controller AdminController) ReadUser(c *gin.Context) {
+	user := controller.readTarget(c)
+	if user == nil || !controller.record(c, models.AdminActionReadUser, user, "") {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewAdminUserSerializer(*user))
+}
+
+// @Summary Disable an user
+// @Description disables an user, so he cannot log in nor use his tokens
+// @ID admin-users-disable
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Success 200 {object} serializers.AdminUserSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:all:write]
+// @Router /admin/users/{uuid}/disable [post]
+func (controller AdminController) DisableUser(c *gin.Context) {
+	user := controller.readTarget(c)
+	if user == nil {
+		return
+	}
+	if err := controller.userService.SetDisabled(user, true, controller.adminAction(c, models.AdminActionDisableUser)); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewAdminUserSerializer(*user))
+}
+
+// @Summary Enable an user
+// @Description re-enables a disabled user
+// @ID admin-users-enable
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Success 200 {object} serializers.AdminUserSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:all:write]
+// @Router /admin/users/{uuid}/enable [post]
+func (controller AdminController) EnableUser(c *gin.Context) {
+	user := controller.readTarget(c)
+	if user == nil {
+		return
+	}
+	if err := controller.userService.SetDisabled(user, false, controller.adminAction(c, models.AdminActionEnableUser)); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewAdminUserSerializer(*user))
+}
+
+// @Summary Verify an user
+// @Description verifies an user without the verification email
+// @ID admin-users-verify
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Success 200 {object} serializers.AdminUserSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:all:write]
+// @Router /admin/users/{uuid}/verify [post]
+func (controller AdminController) VerifyUser(c *gin.Context) {
+	user := controller.readTarget(c)
+	if user == nil {
+		return
+	}
+	if err := controller.userService.Verificate(user, controller.adminAction(c, models.AdminActionVerifyUser)); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewAdminUserSerializer(*user))
+}
+
+// @Summary Force an user password reset
+// @Description sends the reset password email to the user
+// @ID admin-users-reset-password
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:all:write]
+// @Router /admin/users/{uuid}/reset-password [post]
+func (controller AdminController) ResetUserPassword(c *gin.Context) {
+	user := controller.readTarget(c)
+	if user == nil {
+		return
+	}
+	admin := controller.adminAction(c, models.AdminActionResetPassword)
+	if err := controller.outboxService.SendResetPasswordEmail(*user, "", admin); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Delete an user
+// @Description deletes any user
+// @ID admin-users-delete
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:all:delete]
+// @Router /admin/users/{uuid} [delete]
+func (controller AdminController) DeleteUser(c *gin.Context) {
+	user := controller.readTarget(c)
+	if user == nil {
+		return
+	}
+	audit := services.AuditContext{
+		Actor:  controller.authMiddleware.GetAuthorizedUser(c),
+		Client: helpers.NewClientInfo(c),
+	}
+	admin := controller.adminAction(c, models.AdminActionDeleteUser)
+	if err := controller.userService.Delete(user.UUID, audit, admin); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Impersonate an user
+// @Description Issues a short lived access token to act as the user, so
+// @Description support can reproduce his issues without asking for his
+// @Description password. The token carries an act claim with the staff user,
+// @Description it cannot be refreshed and it is not granted to delete the
+// @Description account nor to change its password. The user is alerted, and
+// @Description every request made with the token is audited.
+// @ID admin-users-impersonate
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Param data body validators.AdminImpersonationData true "Reason to act as the user"
+// @Success 200 {object} serializers.TokensSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:all:impersonate]
+// @Router /admin/users/{uuid}/impersonate [post]
+func (controller AdminController) ImpersonateUser(c *gin.Context) {
+	var input validators.AdminImpersonationData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.readTarget(c)
+	if user == nil {
+		return
+	}
+
+	staff := controller.authMiddleware.GetAuthorizedUser(c)
+	tokens, err := controller.authService.Impersonate(*staff, *user, input.Reason, helpers.NewClientInfo(c))
+	if err != nil {
+		if _, ok := err.(services.ImpersonationError); ok {
+			helpers.AbortWithStatus(c, http.StatusForbidden, err)
+			return
+		}
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionImpersonate, user, input.Reason) {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewTokensSerializer(*tokens))
+}
+
+// @Summary List roles
+// @Description List the roles and the scopes they grant
+// @ID admin-roles-list
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} serializers.RolesSerializer
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[role:all:read]
+// @Router /admin/roles [get]
+func (controller AdminController) ListRoles(c *gin.Context) {
+	if !controller.record(c, models.AdminActionListRoles, nil, "") {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewRolesSerializer(controller.roleService.List()))
+}
+
+// @Summary List user roles
+// @Description List the roles assigned to an user
+// @ID admin-users-roles-list
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Success 200 {object} serializers.RolesSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[role:all:read]
+// @Router /admin/users/{uuid}/roles [get]
+func (controller AdminController) ReadUserRoles(c *gin.Context) {
+	user := controller.readTarget(c)
+	if user == nil || !controller.record(c, models.AdminActionReadUserRoles, user, "") {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewRolesSerializer(controller.roleService.UserRoles(*user)))
+}
+
+// @Summary Assign a role
+// @Description assigns a role to an user
+// @ID admin-users-roles-assign
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Param role path string true "Role name"
+// @Success 200 {object} serializers.RolesSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[role:all:write]
+// @Router /admin/users/{uuid}/roles/{role} [put]
+func (controller AdminController) AssignRole(c *gin.Context) {
+	controller.changeRole(c, models.AdminActionAssignRole, controller.roleService.Assign)
+}
+
+// @Summary Revoke a role
+// @Description revokes a role from an user
+// @ID admin-users-roles-revoke
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Param role path string true "Role name"
+// @Success 200 {object} serializers.RolesSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[role:all:write]
+// @Router /admin/users/{uuid}/roles/{role} [delete]
+func (controller AdminController) RevokeRole(c *gin.Context) {
+	controller.changeRole(c, models.AdminActionRevokeRole, controller.roleService.Revoke)
+}
+
+// Assigns or revokes the role given in the uri by using the given change
+// and responds with the user roles
+func (controller AdminController) changeRole(c *gin.Context, action string, change func(models.User, string) error) {
+	var input validators.AdminUserRoleData
+	if err := c.ShouldBindUri(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.readTarget(c)
+	if user == nil {
+		return
+	}
+
+	if err := change(*user, input.Role); err != nil {
+		status := http.StatusBadRequest
+		if _, notFound := err.(services.RoleNotFoundError); notFound {
+			status = http.StatusNotFound
+		}
+		helpers.AbortWithStatus(c, status, err)
+		return
+	}
+	if !controller.record(c, action, user, input.Role) {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewRolesSerializer(controller.roleService.UserRoles(*user)))
+}
+
+// @Summary List user sessions
+// @Description List the active sessions of an user
+// @ID admin-users-sessions-list
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Param page query int false "cursor's page"
+// @Param limit query int false "cursor's limit"
+// @Success 200 {object} serializers.PaginatedSessionsSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:all:read]
+// @Router /admin/users/{uuid}/sessions [get]
+func (controller AdminController) ListUserSessions(c *gin.Context) {
+	var input validators.PaginationQuery
+	if err := c.ShouldBindQuery(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.readTarget(c)
+	if user == nil || !controller.record(c, models.AdminActionListSessions, user, "") {
+		return
+	}
+
+	cursor := helpers.NewCursor(input.Page, input.PageSize)
+	sessions := controller.sessionService.List(*user, &cursor)
+	c.JSON(http.StatusOK, serializers.NewPaginatedSessionsSerializer(sessions, uuid.Nil, cursor))
+}
+
+// @Summary Revoke an user session
+// @Description Signs an user out of the given session
+// @ID admin-users-sessions-revoke
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Param session path string true "Session uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:all:write]
+// @Router /admin/users/{uuid}/sessions/{session} [delete]
+func (controller AdminController) RevokeUserSession(c *gin.Context) {
+	var input validators.AdminUserSessionData
+	if err := c.ShouldBindUri(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.readTarget(c)
+	if user == nil {
+		return
+	}
+
+	session, err := controller.sessionService.Read(*user, uuid.FromStringOrNil(input.Session))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return
+	}
+
+	if err := controller.sessionService.Revoke(*session); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionRevokeSession, user, input.Session) {
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Revoke all user sessions
+// @Description Signs an user out everywhere
+// @ID admin-users-sessions-revoke-all
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:all:write]
+// @Router /admin/users/{uuid}/sessions [delete]
+func (controller AdminController) RevokeUserSessions(c *gin.Context) {
+	user := controller.readTarget(c)
+	if user == nil {
+		return
+	}
+
+	if err := controller.sessionService.RevokeAll(*user, uuid.Nil); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionRevokeAll, user, "") {
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary List audit events
+// @Description List the security audit log, the most recent events first,
+// @Description filtered by actor, subject, action, outcome and time range
+// @ID admin-audit-events-list
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param actor query string false "uuid of the user who performed the action"
+// @Param subject query string false "uuid of the user affected by the action"
+// @Param action query string false "audited action"
+// @Param outcome query string false "success or failure"
+// @Param since query string false "RFC3339 lower bound, inclusive"
+// @Param until query string false "RFC3339 upper bound, exclusive"
+// @Param page query int false "cursor's page"
+// @Param limit query int false "cursor's limit"
+// @Success 200 {object} serializers.PaginatedAuditEventsSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[audit:all:read]
+// @Router /admin/audit-events [get]
+func (controller AdminController) ListAuditEvents(c *gin.Context) {
+	var input validators.AuditEventListQuery
+	if err := c.ShouldBindQuery(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !controller.record(c, models.AdminActionListAudit, nil, c.Request.URL.RawQuery) {
+		return
+	}
+
+	cursor := helpers.NewCursor(input.Page, input.PageSize)
+	events := controller.auditService.List(input, &cursor)
+	c.JSON(http.StatusOK, serializers.NewPaginatedAuditEventsSerializer(events, cursor))
+}
+
+// @Summary List outbox messages
+// @Description List the notifications waiting to be delivered, delivered or
+// @Description dead-lettered, the most recent first. Payloads are not shown.
+// @ID admin-outbox-list
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param status query string false "Message status" Enums(pending, delivered, dead)
+// @Param page query int false "cursor's page"
+// @Param limit query int false "cursor's limit"
+// @Success 200 {object} serializers.PaginatedOutboxMessagesSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[outbox:all:read]
+// @Router /admin/outbox [get]
+func (controller AdminController) ListOutboxMessages(c *gin.Context) {
+	var input validators.OutboxMessageListQuery
+	if err := c.ShouldBindQuery(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !controller.record(c, models.AdminActionListOutbox, nil, c.Request.URL.RawQuery) {
+		return
+	}
+
+	cursor := helpers.NewCursor(input.Page, input.PageSize)
+	messages := controller.outboxService.List(input.Status, &cursor)
+	c.JSON(http.StatusOK, serializers.NewPaginatedOutboxMessagesSerializer(messages, cursor))
+}
+
+// @Summary List notification templates
+// @Description List the notification templates overridden by the
+// @Description deployment. Kinds and locales without override are sent
+// @Description with the built-in templates.
+// @ID admin-notification-templates-list
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} serializers.NotificationTemplatesSerializer
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[template:all:read]
+// @Router /admin/notification-templates [get]
+func (controller AdminController) ListNotificationTemplates(c *gin.Context) {
+	if !controller.record(c, models.AdminActionListTemplates, nil, "") {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewNotificationTemplatesSerializer(controller.templateService.List(nil)))
+}
+
+// @Summary Override a notification template
+// @Description Overrides the subject, text and html of a notification kind
+// @Description for a locale on the whole deployment. Templates use the go
+// @Description template syntax and are validated before being saved.
+// @ID admin-notification-templates-save
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param kind path string true "Notification kind" Enums(user-verify-email, user-change-password, user-signup-attempt, organization-invitation, user-magic-link, user-data-export, guardian-consent, alert-password-changed, alert-app-authorized, alert-new-device, alert-impersonated, alert-phone-changed)
+// @Param locale path string true "Locale"
+// @Param data body validators.NotificationTemplateData true "Template data"
+// @Success 200 {object} serializers.NotificationTemplateSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[template:all:write]
+// @Router /admin/notification-templates/{kind}/{locale} [put]
+func (controller AdminController) SaveNotificationTemplate(c *gin.Context) {
+	var uri validators.NotificationTemplateReadData
+	if err := c.ShouldBindUri(&uri); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var input validators.NotificationTemplateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	template, err := controller.templateService.Save(uri.Kind, uri.Locale, input, nil)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionSaveTemplate, nil, uri.Kind+"/"+uri.Locale) {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewNotificationTemplateSerializer(*template))
+}
+
+// @Summary Delete a notification template
+// @Description Deletes the deployment override of a notification kind for
+// @Description a locale, so the built-in template is used again
+// @ID admin-notification-templates-delete
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param kind path string true "Notification kind" Enums(user-verify-email, user-change-password, user-signup-attempt, organization-invitation, user-magic-link, user-data-export, guardian-consent, alert-password-changed, alert-app-authorized, alert-new-device, alert-impersonated, alert-phone-changed)
+// @Param locale path string true "Locale"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[template:all:write]
+// @Router /admin/notification-templates/{kind}/{locale} [delete]
+func (controller AdminController) DeleteNotificationTemplate(c *gin.Context) {
+	var uri validators.NotificationTemplateReadData
+	if err := c.ShouldBindUri(&uri); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := controller.templateService.Delete(uri.Kind, uri.Locale, nil); err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionDropTemplate, nil, uri.Kind+"/"+uri.Locale) {
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Preview a notification
+// @Description Renders a notification with sample data, as it would be sent
+// @Description in the given locale and on behalf of the given app. When a
+// @Description subject and a text are given, they are previewed instead of
+// @Description the stored templates.
+// @ID admin-notification-templates-preview
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param data body validators.NotificationTemplatePreviewData true "Preview data"
+// @Success 200 {object} serializers.NotificationPreviewSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[template:all:read]
+// @Router /admin/notification-templates/preview [post]
+func (controller AdminController) PreviewNotification(c *gin.Context) {
+	var input validators.NotificationTemplatePreviewData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !controller.record(c, models.AdminActionPreview, nil, input.Kind+"/"+input.Locale) {
+		return
+	}
+
+	rendered, err := controller.templateService.Preview(input)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewNotificationPreviewSerializer(*rendered))
+}
+
+// Reads the identity provider given in the uri. Returns nil and aborts the
+// request if it does not exist.
+func (controller AdminController) readProvider(c *gin.Context) *models.IdentityProvider {
+	var uri validators.IdentityProviderReadData
+	if err := c.ShouldBindUri(&uri); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return nil
+	}
+
+	provider, err := controller.identityProviderService.Read(uri.Name)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+	return provider
+}
+
+// @Summary List identity providers
+// @Description List the upstream OIDC providers the users can sign in
+// @Description with, including the disabled ones
+// @ID admin-identity-providers-list
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} serializers.IdentityProvidersSerializer
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[provider:all:read]
+// @Router /admin/identity-providers [get]
+func (controller AdminController) ListIdentityProviders(c *gin.Context) {
+	if !controller.record(c, models.AdminActionListProviders, nil, "") {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewIdentityProvidersSerializer(controller.identityProviderService.List(true)))
+}
+
+// @Summary Read an identity provider
+// @Description Read the configuration of an upstream OIDC provider. The
+// @Description client secret is never returned.
+// @ID admin-identity-providers-read
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Identity provider name"
+// @Success 200 {object} serializers.IdentityProviderSerializer
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[provider:all:read]
+// @Router /admin/identity-providers/{name} [get]
+func (controller AdminController) ReadIdentityProvider(c *gin.Context) {
+	provider := controller.readProvider(c)
+	if provider == nil {
+		return
+	}
+
+	if !controller.record(c, models.AdminActionReadProvider, nil, provider.Name) {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewIdentityProviderSerializer(*provider))
+}
+
+// @Summary Create an identity provider
+// @Description Registers an upstream OIDC provider. The endpoints which are
+// @Description not given are discovered from the issuer configuration.
+// @ID admin-identity-providers-create
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param data body validators.IdentityProviderCreateData true "Provider data"
+// @Success 201 {object} serializers.IdentityProviderSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 502 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[provider:all:write]
+// @Router /admin/identity-providers [post]
+func (controller AdminController) CreateIdentityProvider(c *gin.Context) {
+	var input validators.IdentityProviderCreateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	provider, err := controller.identityProviderService.Create(input)
+	if err != nil {
+		status := http.StatusBadRequest
+		if _, ok := err.(services.IdentityProviderDiscoveryError); ok {
+			status = http.StatusBadGateway
+		}
+		helpers.AbortWithStatus(c, status, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionAddProvider, nil, input.Name) {
+		return
+	}
+	c.JSON(http.StatusCreated, serializers.NewIdentityProviderSerializer(*provider))
+}
+
+// @Summary Update an identity provider
+// @Description Updates the given fields of an upstream OIDC provider.
+// @Description Disabled providers are hidden from the users and cannot be
+// @Description signed in with, but their linked identities are kept.
+// @ID admin-identity-providers-update
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Identity provider name"
+// @Param data body validators.IdentityProviderUpdateData true "Provider data"
+// @Success 200 {object} serializers.IdentityProviderSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[provider:all:write]
+// @Router /admin/identity-providers/{name} [patch]
+func (controller AdminController) UpdateIdentityProvider(c *gin.Context) {
+	provider := controller.readProvider(c)
+	if provider == nil {
+		return
+	}
+
+	var input validators.IdentityProviderUpdateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := controller.identityProviderService.Update(provider, input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionEditProvider, nil, provider.Name) {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewIdentityProviderSerializer(*provider))
+}
+
+// @Summary Delete an identity provider
+// @Description Deletes an upstream OIDC provider along with the identities
+// @Description linked through it. The users keep their accounts.
+// @ID admin-identity-providers-delete
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Identity provider name"
+// @Success 204
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[provider:all:write]
+// @Router /admin/identity-providers/{name} [delete]
+func (controller AdminController) DeleteIdentityProvider(c *gin.Context) {
+	provider := controller.readProvider(c)
+	if provider == nil {
+		return
+	}
+
+	if err := controller.identityProviderService.Delete(*provider); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionDropProvider, nil, provider.Name) {
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary List SCIM tokens
+// @Description List the tokens the directories of the customers provision
+// @Description users with. Their secrets are never returned.
+// @ID admin-scim-tokens-list
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} serializers.SCIMTokensSerializer
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[scim:all:read]
+// @Router /admin/scim-tokens [get]
+func (controller AdminController) ListSCIMTokens(c *gin.Context) {
+	if !controller.record(c, models.AdminActionListSCIM, nil, "") {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewSCIMTokensSerializer(controller.scimService.ListTokens()))
+}
+
+// @Summary Create a SCIM token
+// @Description Issues a token for the SCIM API. Its secret is only
+// @Description returned by this request.
+// @ID admin-scim-tokens-create
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param data body validators.SCIMTokenCreateData true "Token data"
+// @Success 201 {object} serializers.SCIMTokenSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[scim:all:write]
+// @Router /admin/scim-tokens [post]
+func (controller AdminController) CreateSCIMToken(c *gin.Context) {
+	var input validators.SCIMTokenCreateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	token, err := controller.scimService.CreateToken(input)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionAddSCIM, nil, input.Name) {
+		return
+	}
+	c.JSON(http.StatusCreated, serializers.NewSCIMTokenSerializer(*token))
+}
+
+// @Summary Delete a SCIM token
+// @Description Revokes a token of the SCIM API. The users it provisioned
+// @Description are kept.
+// @ID admin-scim-tokens-delete
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Token uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[scim:all:write]
+// @Router /admin/scim-tokens/{uuid} [delete]
+func (controller AdminController) DeleteSCIMToken(c *gin.Context) {
+	var uri validators.SCIMTokenReadData
+	if err := c.ShouldBindUri(&uri); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	token, err := controller.scimService.ReadToken(uuid.FromStringOrNil(uri.UUID))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return
+	}
+
+	if err := controller.scimService.DeleteToken(*token); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionDropSCIM, nil, token.Name) {
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Set the custom attributes of an user
+// @Description Sets the given custom attributes of an user, whether they
+// @Description are user editable or not. Attributes set to null are
+// @Description removed.
+// @ID admin-users-attributes
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Param data body validators.UserAttributesData true "Attributes data"
+// @Success 200 {object} serializers.AdminUserSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:all:write]
+// @Router /admin/users/{uuid}/attributes [patch]
+func (controller AdminController) SetUserAttributes(c *gin.Context) {
+	user := controller.readTarget(c)
+	if user == nil {
+		return
+	}
+
+	var input validators.UserAttributesData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	audit := services.AuditContext{Actor: controller.authMiddleware.GetAuthorizedUser(c), Client: helpers.NewClientInfo(c)}
+	if err := controller.attributeService.SetUserAttributes(user, input.Attributes, true, audit); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionSetAttributes, user, "") {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewAdminUserSerializer(*user))
+}
+
+// Reads the attribute definition given in the uri. Returns nil and aborts
+// the request if it does not exist.
+func (controller AdminController) readAttribute(c *gin.Context) *models.AttributeDefinition {
+	var uri validators.AttributeDefinitionReadData
+	if err := c.ShouldBindUri(&uri); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return nil
+	}
+
+	definition, err := controller.attributeService.Read(uri.Name)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+	return definition
+}
+
+// @Summary List attribute definitions
+// @Description List the custom attributes the users can have
+// @ID admin-attributes-list
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} serializers.AttributeDefinitionsSerializer
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[attribute:all:read]
+// @Router /admin/attributes [get]
+func (controller AdminController) ListAttributeDefinitions(c *gin.Context) {
+	if !controller.record(c, models.AdminActionListAttributes, nil, "") {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewAttributeDefinitionsSerializer(controller.attributeService.List()))
+}
+
+// @Summary Create an attribute definition
+// @Description Defines a custom attribute the users can have. Its name is
+// @Description written in snake case, and string attributes can be
+// @Description restricted to a pattern or a set of options. Only the
+// @Description releasable attributes can be released to the apps.
+// @ID admin-attributes-create
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param data body validators.AttributeDefinitionCreateData true "Attribute data"
+// @Success 201 {object} serializers.AttributeDefinitionSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[attribute:all:write]
+// @Router /admin/attributes [post]
+func (controller AdminController) CreateAttributeDefinition(c *gin.Context) {
+	var input validators.AttributeDefinitionCreateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	definition, err := controller.attributeService.Create(input)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionAddAttribute, nil, input.Name) {
+		return
+	}
+	c.JSON(http.StatusCreated, serializers.NewAttributeDefinitionSerializer(*definition))
+}
+
+// @Summary Update an attribute definition
+// @Description Updates the given fields of a custom attribute. Its name and
+// @Description type cannot be changed. Attributes which are no longer
+// @Description releasable are removed from the claim mapping of the apps.
+// @ID admin-attributes-update
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Attribute name"
+// @Param data body validators.AttributeDefinitionUpdateData true "Attribute data"
+// @Success 200 {object} serializers.AttributeDefinitionSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[attribute:all:write]
+// @Router /admin/attributes/{name} [patch]
+func (controller AdminController) UpdateAttributeDefinition(c *gin.Context) {
+	definition := controller.readAttribute(c)
+	if definition == nil {
+		return
+	}
+
+	var input validators.AttributeDefinitionUpdateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := controller.attributeService.Update(definition, input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionEditAttribute, nil, definition.Name) {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewAttributeDefinitionSerializer(*definition))
+}
+
+// @Summary Delete an attribute definition
+// @Description Deletes a custom attribute along with the values the users
+// @Description have for it and the claim mapping rules which release it
+// @ID admin-attributes-delete
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Attribute name"
+// @Success 204
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[attribute:all:write]
+// @Router /admin/attributes/{name} [delete]
+func (controller AdminController) DeleteAttributeDefinition(c *gin.Context) {
+	definition := controller.readAttribute(c)
+	if definition == nil {
+		return
+	}
+
+	if err := controller.attributeService.Delete(*definition); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionDropAttribute, nil, definition.Name) {
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// Reads the deployment wide legal document given in the uri. Returns nil
+// and aborts the request if it does not exist.
+func (controller AdminController) readLegalDocument(c *gin.Context) *models.LegalDocument {
+	var uri validators.LegalDocumentReadData
+	if err := c.ShouldBindUri(&uri); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return nil
+	}
+
+	document, err := controller.legalService.Read(uuid.FromStringOrNil(uri.UUID), nil)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+	return document
+}
+
+// @Summary List legal documents
+// @Description List every version of the deployment wide terms of service
+// @Description and privacy policy, including the unpublished ones
+// @ID admin-legal-documents-list
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} serializers.LegalDocumentsSerializer
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[legal:all:read]
+// @Router /admin/legal-documents [get]
+func (controller AdminController) ListLegalDocuments(c *gin.Context) {
+	if !controller.record(c, models.AdminActionListLegal, nil, "") {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewLegalDocumentsSerializer(controller.legalService.List(nil)))
+}
+
+// @Summary Create a legal document
+// @Description Creates an unpublished version of the deployment wide terms
+// @Description of service or privacy policy, so it can be reviewed before
+// @Description it is published
+// @ID admin-legal-documents-create
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param data body validators.LegalDocumentCreateData true "Document data"
+// @Success 201 {object} serializers.LegalDocumentSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[legal:all:write]
+// @Router /admin/legal-documents [post]
+func (controller AdminController) CreateLegalDocument(c *gin.Context) {
+	var input validators.LegalDocumentCreateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	document, err := controller.legalService.Create(input, nil)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionAddLegal, nil, input.Kind+" "+input.Version) {
+		return
+	}
+	c.JSON(http.StatusCreated, serializers.NewLegalDocumentSerializer(*document))
+}
+
+// @Summary Publish a legal document
+// @Description Publishes a version of a deployment wide legal document.
+// @Description When it is mandatory, the users must accept it on their next
+// @Description login or authorization.
+// @ID admin-legal-documents-publish
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Document uuid"
+// @Success 200 {object} serializers.LegalDocumentSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[legal:all:write]
+// @Router /admin/legal-documents/{uuid}/publish [post]
+func (controller AdminController) PublishLegalDocument(c *gin.Context) {
+	document := controller.readLegalDocument(c)
+	if document == nil {
+		return
+	}
+
+	if err := controller.legalService.Publish(document); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionPublishLegal, nil, document.Kind+" "+document.Version) {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewLegalDocumentSerializer(*document))
+}
+
+// @Summary Delete a legal document
+// @Description Deletes an unpublished version of a deployment wide legal
+// @Description document. Published versions are kept as the proof of what
+// @Description the users accepted.
+// @ID admin-legal-documents-delete
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Document uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[legal:all:write]
+// @Router /admin/legal-documents/{uuid} [delete]
+func (controller AdminController) DeleteLegalDocument(c *gin.Context) {
+	document := controller.readLegalDocument(c)
+	if document == nil {
+		return
+	}
+
+	if err := controller.legalService.Delete(*document); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	if !controller.record(c, models.AdminActionDropLegal, nil, document.Kind+" "+document.Version) {
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary List the legal acceptances of an user
+// @Description List the versions of the legal documents an user accepted,
+// @Description along with when and from where he did it
+// @ID admin-users-legal-acceptances
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param uuid path string true "User uuid"
+// @Success 200 {object} serializers.LegalAcceptancesSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[legal:all:read]
+// @Router /admin/users/{uuid}/legal-acceptances [get]
+func (controller AdminController) ListUserLegalAcceptances(c *gin.Context) {
+	user := controller.readTarget(c)
+	if user == nil {
+		return
+	}
+
+	if !controller.record(c, models.AdminActionReadAcceptance, user, "") {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewLegalAcceptancesSerializer(controller.legalService.Acceptances(*user)))
+}
@@ -0,0 +1,747 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/services"
+	"gandalf/tests"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type recordAdminActionRecorder struct {
+	action string
+	staff  models.User
+	target *models.User
+	detail string
+}
+
+type mockAdminActionService struct {
+	recordRecorder *recordAdminActionRecorder
+	recordError    error
+}
+
+func newMockedAdminActionService(recordError error) *mockAdminActionService {
+	return &mockAdminActionService{
+		recordRecorder: new(recordAdminActionRecorder),
+		recordError:    recordError,
+	}
+}
+
+func (service *mockAdminActionService) Record(action string, staff models.User, target *models.User, detail string, ip string) error {
+	*service.recordRecorder = recordAdminActionRecorder{action, staff, target, detail}
+	return service.recordError
+}
+
+func setupAdminRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	authService services.IAuthService,
+	userService services.IUserService,
+	outboxService services.IOutboxService,
+	adminActionService services.IAdminActionService,
+	roleService services.IRoleService,
+	sessionService services.ISessionService,
+	auditService services.IAuditService,
+) *gin.Engine {
+	router := gin.Default()
+	RegisterAdminRoutes(
+		router, authBearerMiddleware,
+		authService, userService,
+		outboxService,
+		adminActionService, roleService,
+		sessionService, auditService,
+		newMockedNotificationTemplateService(nil),
+		newMockedIdentityProviderService(nil), newMockedSCIMService(nil),
+		newMockedAttributeService(nil), newMockedLegalService(nil),
+		newMockedPhoneService(nil), newMockedThrottler(true),
+	)
+	return router
+}
+
+func TestAdminLogin(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test admin login successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		authService := newMockedAuthService(&user, nil, nil, nil, nil, nil)
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(nil), authService,
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		payload, _ := json.Marshal(map[string]string{
+			"email":    user.Email,
+			"password": "password1234",
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/admin/login", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal(security.GroupStaff, authService.startSessionRecorder.scopes)
+		assert.Equal(models.AdminActionLogin, adminActionService.recordRecorder.action)
+		assert.Equal(user.Email, adminActionService.recordRecorder.staff.Email)
+	})
+
+	t.Run("Test admin login without staff scopes", func(t *testing.T) {
+		user := tests.UserFactory()
+		authService := newMockedAuthService(&user, nil, nil, nil, nil, nil)
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(nil), authService,
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService([]string{security.ScopeUserRead}, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		payload, _ := json.Marshal(map[string]string{
+			"email":    user.Email,
+			"password": "password1234",
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/admin/login", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+		assert.Equal("", adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test admin login forbidden", func(t *testing.T) {
+		authService := newMockedAuthService(nil, errors.New("Whoops!"), nil, nil, nil, nil)
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(nil), authService,
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		payload, _ := json.Marshal(map[string]string{
+			"email":    "test@test.com",
+			"password": "password1234",
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/admin/login", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+		assert.Equal("", adminActionService.recordRecorder.action)
+	})
+}
+
+func TestAdminListUsers(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list users successfully", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			authMiddleware, newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/users?q=gandalf", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeUserReadAll}, *authMiddleware.requestedScopes)
+		assert.Equal("gandalf", userService.listRecorder.search)
+		assert.Equal(models.AdminActionListUsers, adminActionService.recordRecorder.action)
+		assert.Equal("gandalf", adminActionService.recordRecorder.detail)
+	})
+
+	t.Run("Test list users record error", func(t *testing.T) {
+		staff := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil),
+			newMockedAdminActionService(errors.New("Whoops!")),
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/users", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusInternalServerError, recorder.Result().StatusCode)
+		assert.Equal("", userService.listRecorder.search)
+	})
+}
+
+func TestAdminUserActions(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test read user successfully", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("GET", fmt.Sprintf("/admin/users/%s", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal(uuid, userService.readRecorder.uuid)
+		assert.Equal(models.AdminActionReadUser, adminActionService.recordRecorder.action)
+		assert.NotNil(adminActionService.recordRecorder.target)
+	})
+
+	t.Run("Test read user not found", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, errors.New("Whoops!"), nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("GET", fmt.Sprintf("/admin/users/%s", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+		assert.Equal("", adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test disable and enable user", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			authMiddleware, newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+		uuid, _ := uuid.NewV4()
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", fmt.Sprintf("/admin/users/%s/disable", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeUserWriteAll}, *authMiddleware.requestedScopes)
+		assert.True(userService.setDisabledRecorder.disabled)
+		assert.Equal(models.AdminActionDisableUser, userService.adminActionRecorder.Action)
+		assert.Equal(staff.Email, userService.adminActionRecorder.Staff.Email)
+
+		recorder = httptest.NewRecorder()
+		request, _ = http.NewRequest("POST", fmt.Sprintf("/admin/users/%s/enable", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.False(userService.setDisabledRecorder.disabled)
+		assert.Equal(models.AdminActionEnableUser, userService.adminActionRecorder.Action)
+	})
+
+	t.Run("Test verify user", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("POST", fmt.Sprintf("/admin/users/%s/verify", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.True(userService.verificateRecorder.called)
+		assert.Equal(models.AdminActionVerifyUser, userService.adminActionRecorder.Action)
+	})
+
+	t.Run("Test reset user password", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		outboxService := newMockedOutboxService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, outboxService, adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("POST", fmt.Sprintf("/admin/users/%s/reset-password", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal(models.OutboxKindUserChangePassword, outboxService.sendRecorder.kind)
+		assert.Equal(models.AdminActionResetPassword, outboxService.adminActionRecorder.Action)
+	})
+
+	t.Run("Test reset user password enqueue error", func(t *testing.T) {
+		staff := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(errors.New("enqueue error")), newMockedAdminActionService(nil),
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("POST", fmt.Sprintf("/admin/users/%s/reset-password", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusInternalServerError, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test delete user", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			authMiddleware, newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("DELETE", fmt.Sprintf("/admin/users/%s", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeUserDeleteAll}, *authMiddleware.requestedScopes)
+		assert.Equal(models.AdminActionDeleteUser, userService.adminActionRecorder.Action)
+	})
+
+	t.Run("Test failed delete user is not recorded", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, errors.New("delete error"), nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("DELETE", fmt.Sprintf("/admin/users/%s", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+		assert.Equal("", adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test impersonate user", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			authMiddleware, authService,
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+		var response gin.H
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		payload, _ := json.Marshal(map[string]string{"reason": "Reproduce a login issue"})
+		request, _ := http.NewRequest("POST", fmt.Sprintf("/admin/users/%s/impersonate", uuid), bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal("impersonation", response["access_token"])
+		assert.Equal([]string{security.ScopeUserImpersonateAll}, *authMiddleware.requestedScopes)
+		assert.Equal(models.AdminActionImpersonate, adminActionService.recordRecorder.action)
+		assert.Equal("Reproduce a login issue", adminActionService.recordRecorder.detail)
+		assert.Equal(staff.Email, authService.impersonateRecorder.staff.Email)
+		assert.Equal("Reproduce a login issue", authService.impersonateRecorder.reason)
+	})
+
+	t.Run("Test impersonate user without reason", func(t *testing.T) {
+		staff := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), newMockedAdminActionService(nil),
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("POST", fmt.Sprintf("/admin/users/%s/impersonate", uuid), bytes.NewBufferString("{}"))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test impersonate staff user", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		authService.impersonateError = services.ImpersonationError{}
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), authService,
+			&userService, newMockedOutboxService(nil), newMockedAdminActionService(nil),
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		payload, _ := json.Marshal(map[string]string{"reason": "Reproduce a login issue"})
+		request, _ := http.NewRequest("POST", fmt.Sprintf("/admin/users/%s/impersonate", uuid), bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+}
+
+func TestAdminRoles(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list roles", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			authMiddleware, newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+		var response gin.H
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/roles", nil)
+		router.ServeHTTP(recorder, request)
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeRoleReadAll}, *authMiddleware.requestedScopes)
+		assert.Equal(1, len(response["data"].([]interface{})))
+		assert.Equal(models.AdminActionListRoles, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test assign and revoke role", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		roleService := newMockedRoleService(security.GroupStaff, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			authMiddleware, newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			roleService,
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+		uuid, _ := uuid.NewV4()
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PUT", fmt.Sprintf("/admin/users/%s/roles/%s", uuid, models.RoleStaff), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeRoleWriteAll}, *authMiddleware.requestedScopes)
+		assert.Equal(models.RoleStaff, roleService.assignRecorder.name)
+		assert.Equal(models.AdminActionAssignRole, adminActionService.recordRecorder.action)
+		assert.Equal(models.RoleStaff, adminActionService.recordRecorder.detail)
+
+		recorder = httptest.NewRecorder()
+		request, _ = http.NewRequest("DELETE", fmt.Sprintf("/admin/users/%s/roles/%s", uuid, models.RoleStaff), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal(models.RoleStaff, roleService.revokeRecorder.name)
+		assert.Equal(models.AdminActionRevokeRole, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test assign role not found", func(t *testing.T) {
+		staff := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), newMockedAdminActionService(nil),
+			newMockedRoleService(nil, services.RoleNotFoundError{}),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+		uuid, _ := uuid.NewV4()
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PUT", fmt.Sprintf("/admin/users/%s/roles/unknown", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+	})
+}
+
+func TestAdminSessions(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list user sessions", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			authMiddleware, newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+		uuid, _ := uuid.NewV4()
+		var response gin.H
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", fmt.Sprintf("/admin/users/%s/sessions", uuid), nil)
+		router.ServeHTTP(recorder, request)
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeUserReadAll}, *authMiddleware.requestedScopes)
+		assert.Equal(1, len(response["data"].([]interface{})))
+		assert.Equal(models.AdminActionListSessions, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test revoke user session", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		sessionService := newMockedSessionService(nil)
+		router := setupAdminRouter(
+			authMiddleware, newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			sessionService,
+			newMockedAuditService(),
+		)
+		user, _ := uuid.NewV4()
+		session, _ := uuid.NewV4()
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", fmt.Sprintf("/admin/users/%s/sessions/%s", user, session), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeUserWriteAll}, *authMiddleware.requestedScopes)
+		assert.Equal(session, sessionService.revokeRecorder.UUID)
+		assert.Equal(models.AdminActionRevokeSession, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test revoke user session not found", func(t *testing.T) {
+		staff := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), newMockedAdminActionService(nil),
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(services.SessionNotFoundError{}),
+			newMockedAuditService(),
+		)
+		user, _ := uuid.NewV4()
+		session, _ := uuid.NewV4()
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", fmt.Sprintf("/admin/users/%s/sessions/%s", user, session), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test revoke all user sessions", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		sessionService := newMockedSessionService(nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			sessionService,
+			newMockedAuditService(),
+		)
+		user, _ := uuid.NewV4()
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", fmt.Sprintf("/admin/users/%s/sessions", user), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal(uuid.Nil, sessionService.revokeAllRecorder.except)
+		assert.Equal(models.AdminActionRevokeAll, adminActionService.recordRecorder.action)
+	})
+}
+
+func TestAdminListAuditEvents(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list audit events successfully", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		auditService := newMockedAuditService()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			authMiddleware, newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			auditService,
+		)
+
+		actor := uuid.Must(uuid.NewV4()).String()
+		query := fmt.Sprintf("actor=%s&outcome=failure&since=2021-10-19T08:00:00Z", actor)
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/audit-events?"+query, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeAuditReadAll}, *authMiddleware.requestedScopes)
+		assert.Equal(actor, auditService.listRecorder.filter.Actor)
+		assert.Equal(models.AuditOutcomeFailure, auditService.listRecorder.filter.Outcome)
+		assert.Equal(2021, auditService.listRecorder.filter.Since.Year())
+		assert.Equal(models.AdminActionListAudit, adminActionService.recordRecorder.action)
+		assert.Equal(query, adminActionService.recordRecorder.detail)
+	})
+
+	t.Run("Test list audit events bad request", func(t *testing.T) {
+		staff := tests.UserFactory()
+		auditService := newMockedAuditService()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil), newMockedAdminActionService(nil),
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			auditService,
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/audit-events?outcome=maybe", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+		assert.Equal("", auditService.listRecorder.filter.Outcome)
+	})
+}
+
+func TestAdminListOutboxMessages(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list outbox messages successfully", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		outboxService := newMockedOutboxService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			authMiddleware, newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, outboxService, adminActionService,
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+		var response gin.H
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/outbox?status=dead", nil)
+		router.ServeHTTP(recorder, request)
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeOutboxReadAll}, *authMiddleware.requestedScopes)
+		assert.Equal(models.OutboxMessageDead, *outboxService.listRecorder)
+		assert.Equal(models.AdminActionListOutbox, adminActionService.recordRecorder.action)
+		assert.Equal("outbox-message", response["type"])
+		assert.NotContains(recorder.Body.String(), "payload")
+	})
+
+	t.Run("Test list outbox messages bad request", func(t *testing.T) {
+		staff := tests.UserFactory()
+		outboxService := newMockedOutboxService(nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupAdminRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, outboxService, newMockedAdminActionService(nil),
+			newMockedRoleService(security.GroupStaff, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/outbox?status=lost", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+		assert.Equal("", *outboxService.listRecorder)
+	})
+}
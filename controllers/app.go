@@ -3,6 +3,7 @@ package controllers
 import (
 	"gandalf/helpers"
 	"gandalf/middlewares"
+	"gandalf/models"
 	"gandalf/security"
 	"gandalf/serializers"
 	"gandalf/services"
@@ -17,11 +18,13 @@ func RegisterAppRoutes(
 	router *gin.Engine,
 	authBearerMiddleware middlewares.IAuthBearerMiddleware,
 	appService services.IAppService,
+	organizationService services.IOrganizationService,
 ) {
 
 	controller := AppController{
-		appService:     appService,
-		authMiddleware: authBearerMiddleware,
+		appService:          appService,
+		organizationService: organizationService,
+		authMiddleware:      authBearerMiddleware,
 	}
 
 	publicRoutes := router.Group("/apps")
@@ -35,6 +38,8 @@ func RegisterAppRoutes(
 		writeRoutes.Use(authBearerMiddleware.HasScopes(scopes))
 
 		writeRoutes.POST("", controller.CreateApp)
+		writeRoutes.PATCH("/:uuid", controller.UpdateApp)
+		writeRoutes.DELETE("/:uuid", controller.DeleteApp)
 	}
 
 	readRoutes := router.Group("/apps")
@@ -48,12 +53,54 @@ func RegisterAppRoutes(
 
 // Controller for /app endpoints
 type AppController struct {
-	appService     services.IAppService
-	authMiddleware middlewares.IAuthBearerMiddleware
+	appService          services.IAppService
+	organizationService services.IOrganizationService
+	authMiddleware      middlewares.IAuthBearerMiddleware
+}
+
+// Reads the organization with the given uuid and checks the given user can
+// manage its apps. Returns nil and aborts the request otherwise.
+func (controller AppController) managedOrganization(c *gin.Context, organizationUUID string, user models.User) *models.Organization {
+	organization, err := controller.organizationService.Read(uuid.FromStringOrNil(organizationUUID))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+
+	membership, err := controller.organizationService.Membership(*organization, user)
+	if err != nil || !models.MembershipRoleAtLeast(membership.Role, models.MembershipAdmin) {
+		helpers.AbortWithStatus(c, http.StatusForbidden, AppAccessDeniedError{})
+		return nil
+	}
+	return organization
+}
+
+// Reads the app given in the uri and checks the given user can manage it.
+// Returns nil and aborts the request otherwise.
+func (controller AppController) managedApp(c *gin.Context, user models.User) *models.App {
+	var input validators.AppReadData
+	if err := c.ShouldBindUri(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return nil
+	}
+
+	app, err := controller.appService.Read(uuid.FromStringOrNil(input.UUID))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+
+	role := controller.appService.AccessRole(*app, user)
+	if !models.MembershipRoleAtLeast(role, models.MembershipAdmin) {
+		helpers.AbortWithStatus(c, http.StatusForbidden, AppAccessDeniedError{})
+		return nil
+	}
+	return app
 }
 
 // @Summary Creates a new app
-// @Description creates an app
+// @Description creates an app. Apps created for an organization require
+// @Description to be owner or admin of the organization.
 // @ID app-create
 // @Tags App
 // @Accept json
@@ -62,6 +109,7 @@ type AppController struct {
 // @Success 201 {object} serializers.AppSerializer
 // @Failure 400 {object} helpers.HTTPError
 // @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
 // @Security OAuth2AccessCode[app:me:write]
 // @Router /apps [post]
 func (controller AppController) CreateApp(c *gin.Context) {
@@ -72,7 +120,16 @@ func (controller AppController) CreateApp(c *gin.Context) {
 	}
 
 	user := controller.authMiddleware.GetAuthorizedUser(c)
-	app, err := controller.appService.Create(input, *user)
+	var organization *models.Organization
+	if input.Organization != "" {
+		if organization = controller.managedOrganization(c, input.Organization, *user); organization == nil {
+			return
+		}
+	}
+
+	app, err := controller.appService.Create(input, *user, organization, services.AuditContext{
+		Actor: user, Client: helpers.NewClientInfo(c),
+	})
 	if err != nil {
 		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
 		return
@@ -134,3 +191,84 @@ func (controller AppController) ReadByClientID(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, serializers.NewAppPublicSerializer(*app))
 }
+
+// @Summary Update an app
+// @Description updates an app. Organization apps can be updated by the
+// @Description owners and admins of the organization, and the app can be
+// @Description transferred to an organization managed by the user.
+// @ID app-update
+// @Tags App
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Param app body validators.AppUpdateData true "Updates an app"
+// @Success 200 {object} serializers.AppSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid} [patch]
+func (controller AppController) UpdateApp(c *gin.Context) {
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	app := controller.managedApp(c, *user)
+	if app == nil {
+		return
+	}
+
+	var input validators.AppUpdateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var organization *models.Organization
+	if input.Organization != "" {
+		if organization = controller.managedOrganization(c, input.Organization, *user); organization == nil {
+			return
+		}
+	}
+
+	audit := services.AuditContext{Actor: user, Client: helpers.NewClientInfo(c)}
+	app, err := controller.appService.Update(app.UUID, input, audit)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if organization != nil {
+		if err := controller.appService.Transfer(app, *organization, audit); err != nil {
+			helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, serializers.NewAppSerializer(*app))
+}
+
+// @Summary Delete an app
+// @Description deletes an app. Organization apps can be deleted by the
+// @Description owners and admins of the organization.
+// @ID app-delete
+// @Tags App
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid} [delete]
+func (controller AppController) DeleteApp(c *gin.Context) {
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	app := controller.managedApp(c, *user)
+	if app == nil {
+		return
+	}
+
+	audit := services.AuditContext{Actor: user, Client: helpers.NewClientInfo(c)}
+	if err := controller.appService.Delete(app.UUID, audit); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"gandalf/middlewares"
+	"gandalf/models"
 	"gandalf/services"
 	"gandalf/tests"
 	"net/http"
@@ -23,8 +24,9 @@ func setupAppRouter(
 	appService services.IAppService,
 ) *gin.Engine {
 	router := gin.Default()
+	organizationService := newMockedOrganizationService(models.MembershipOwner, nil, nil, nil)
 	RegisterAppRoutes(
-		router, authBearerMiddleware, appService,
+		router, authBearerMiddleware, appService, &organizationService,
 	)
 	return router
 }
@@ -197,3 +199,116 @@ func TestReadAppByClientID(t *testing.T) {
 		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
 	})
 }
+
+func TestUpdateApp(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test update app successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupAppRouter(authBearerMiddleware, &appService)
+
+		name := faker.Company().Name()
+		payload, _ := json.Marshal(map[string]interface{}{"name": name})
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		url := fmt.Sprintf("/apps/%s", uuid.String())
+		request, _ := http.NewRequest("PATCH", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal(name, appService.updateAppRecorder.appUpdateData.Name)
+		assert.Nil(appService.transferAppRecorder.organization)
+	})
+
+	t.Run("Test update app transfer to organization", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupAppRouter(authBearerMiddleware, &appService)
+
+		organization, _ := uuid.NewV4()
+		payload, _ := json.Marshal(map[string]interface{}{"organization": organization.String()})
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		url := fmt.Sprintf("/apps/%s", uuid.String())
+		request, _ := http.NewRequest("PATCH", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.NotNil(appService.transferAppRecorder.organization)
+	})
+
+	t.Run("Test update app without access", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		appService.accessRole = models.MembershipMember
+		router := setupAppRouter(authBearerMiddleware, &appService)
+
+		payload, _ := json.Marshal(map[string]interface{}{"name": "name"})
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		url := fmt.Sprintf("/apps/%s", uuid.String())
+		request, _ := http.NewRequest("PATCH", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test update app not found", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		appService := newMockedAppService(nil, errors.New("Whoops!"), nil, nil, nil)
+		router := setupAppRouter(authBearerMiddleware, &appService)
+
+		payload, _ := json.Marshal(map[string]interface{}{"name": "name"})
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		url := fmt.Sprintf("/apps/%s", uuid.String())
+		request, _ := http.NewRequest("PATCH", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+	})
+}
+
+func TestDeleteApp(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test delete app successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupAppRouter(authBearerMiddleware, &appService)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		url := fmt.Sprintf("/apps/%s", uuid.String())
+		request, _ := http.NewRequest("DELETE", url, bytes.NewBuffer([]byte{}))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test delete app without access", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		appService.accessRole = ""
+		router := setupAppRouter(authBearerMiddleware, &appService)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		url := fmt.Sprintf("/apps/%s", uuid.String())
+		request, _ := http.NewRequest("DELETE", url, bytes.NewBuffer([]byte{}))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register custom attribute endpoints to the given router
+func RegisterAttributeRoutes(
+	router *gin.Engine,
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	attributeService services.IAttributeService,
+) {
+	controller := AttributeController{
+		attributeService: attributeService,
+		authMiddleware:   authBearerMiddleware,
+	}
+
+	writeRoutes := router.Group("/me/attributes")
+	{
+		scopes := []string{security.ScopeUserWrite}
+		writeRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+		writeRoutes.PATCH("", controller.SetMyAttributes)
+	}
+}
+
+// Controller for /me/attributes endpoints
+type AttributeController struct {
+	attributeService services.IAttributeService
+	authMiddleware   middlewares.IAuthBearerMiddleware
+}
+
+// @Summary Set my custom attributes
+// @Description Sets the given custom attributes of mine. Only the user
+// @Description editable attributes can be changed, and the ones set to
+// @Description null are removed.
+// @ID me-attributes
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param data body validators.UserAttributesData true "Attributes data"
+// @Success 200 {object} serializers.UserSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:write]
+// @Router /me/attributes [patch]
+func (controller AttributeController) SetMyAttributes(c *gin.Context) {
+	var input validators.UserAttributesData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	audit := services.AuditContext{Actor: user, Client: helpers.NewClientInfo(c)}
+	if err := controller.attributeService.SetUserAttributes(user, input.Attributes, false, audit); err != nil {
+		status := http.StatusBadRequest
+		if _, ok := err.(services.AttributeNotEditableError); ok {
+			status = http.StatusForbidden
+		}
+		helpers.AbortWithStatus(c, status, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewUserSerializer(*user))
+}
@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type attributeRecorder struct {
+	create  validators.AttributeDefinitionCreateData
+	update  validators.AttributeDefinitionUpdateData
+	name    string
+	values  map[string]interface{}
+	byStaff bool
+	deleted bool
+}
+
+type mockAttributeService struct {
+	recorder *attributeRecorder
+	err      error
+}
+
+func newMockedAttributeService(err error) *mockAttributeService {
+	return &mockAttributeService{recorder: new(attributeRecorder), err: err}
+}
+
+func (service *mockAttributeService) List() []models.AttributeDefinition {
+	return []models.AttributeDefinition{models.NewAttributeDefinition("plan", models.AttributeTypeString, true)}
+}
+
+func (service *mockAttributeService) Read(name string) (*models.AttributeDefinition, error) {
+	service.recorder.name = name
+	if service.err != nil {
+		return nil, service.err
+	}
+	definition := models.NewAttributeDefinition(name, models.AttributeTypeString, false)
+	return &definition, nil
+}
+
+func (service *mockAttributeService) Create(data validators.AttributeDefinitionCreateData) (*models.AttributeDefinition, error) {
+	service.recorder.create = data
+	if service.err != nil {
+		return nil, service.err
+	}
+	definition := models.NewAttributeDefinition(data.Name, data.Type, data.UserEditable)
+	return &definition, nil
+}
+
+func (service *mockAttributeService) Update(definition *models.AttributeDefinition, data validators.AttributeDefinitionUpdateData) error {
+	service.recorder.update = data
+	if data.UserEditable != nil {
+		definition.UserEditable = *data.UserEditable
+	}
+	if data.Releasable != nil {
+		definition.Releasable = *data.Releasable
+	}
+	return nil
+}
+
+func (service *mockAttributeService) Delete(definition models.AttributeDefinition) error {
+	service.recorder.deleted = true
+	return nil
+}
+
+func (service *mockAttributeService) SetUserAttributes(user *models.User, values map[string]interface{}, byStaff bool, audit services.AuditContext) error {
+	service.recorder.values = values
+	service.recorder.byStaff = byStaff
+	if service.err != nil {
+		return service.err
+	}
+	user.Attributes = models.UserAttributes(values)
+	return nil
+}
+
+func setupAttributeRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	attributeService services.IAttributeService,
+) *gin.Engine {
+	router := gin.Default()
+	RegisterAttributeRoutes(router, authBearerMiddleware, attributeService)
+	return router
+}
+
+func setupAdminAttributeRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	adminActionService services.IAdminActionService,
+	attributeService services.IAttributeService,
+) *gin.Engine {
+	router := gin.Default()
+	userService := newMockedUserService(nil, nil, nil, nil, nil)
+	RegisterAdminRoutes(
+		router, authBearerMiddleware,
+		newMockedAuthService(nil, nil, nil, nil, nil, nil), &userService,
+		newMockedOutboxService(nil),
+		adminActionService, newMockedRoleService(nil, nil),
+		newMockedSessionService(nil), newMockedAuditService(),
+		newMockedNotificationTemplateService(nil),
+		newMockedIdentityProviderService(nil), newMockedSCIMService(nil),
+		attributeService, newMockedLegalService(nil),
+		newMockedPhoneService(nil), newMockedThrottler(true),
+	)
+	return router
+}
+
+func TestSetMyAttributes(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test set my attributes successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		attributeService := newMockedAttributeService(nil)
+		router := setupAttributeRouter(newMockAuthBearerMiddleware(&user), attributeService)
+
+		payload, _ := json.Marshal(validators.UserAttributesData{Attributes: map[string]interface{}{"plan": "pro"}})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PATCH", "/me/attributes", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.UserSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal("pro", response.Data.Attributes["plan"])
+		assert.False(attributeService.recorder.byStaff)
+	})
+
+	t.Run("Test set attribute which is not user editable", func(t *testing.T) {
+		user := tests.UserFactory()
+		router := setupAttributeRouter(newMockAuthBearerMiddleware(&user), newMockedAttributeService(services.AttributeNotEditableError{}))
+
+		payload, _ := json.Marshal(validators.UserAttributesData{Attributes: map[string]interface{}{"employee_id": "E-1"}})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PATCH", "/me/attributes", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Code)
+	})
+
+	t.Run("Test set invalid attribute", func(t *testing.T) {
+		user := tests.UserFactory()
+		router := setupAttributeRouter(newMockAuthBearerMiddleware(&user), newMockedAttributeService(services.AttributeNotValidError{}))
+
+		payload, _ := json.Marshal(validators.UserAttributesData{Attributes: map[string]interface{}{"plan": 3}})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PATCH", "/me/attributes", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("Test set attributes without payload", func(t *testing.T) {
+		user := tests.UserFactory()
+		router := setupAttributeRouter(newMockAuthBearerMiddleware(&user), newMockedAttributeService(nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PATCH", "/me/attributes", bytes.NewBufferString("{}"))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+	})
+}
+
+func TestAdminAttributes(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test create attribute definition", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		attributeService := newMockedAttributeService(nil)
+		router := setupAdminAttributeRouter(newMockAuthBearerMiddleware(&staff), adminActionService, attributeService)
+
+		payload, _ := json.Marshal(validators.AttributeDefinitionCreateData{Name: "employee_id", Type: models.AttributeTypeString})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/admin/attributes", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.AttributeDefinitionSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusCreated, recorder.Code)
+		assert.Equal("employee_id", response.Data.Name)
+		assert.Equal(models.AdminActionAddAttribute, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test create attribute definition with unknown type", func(t *testing.T) {
+		staff := tests.UserFactory()
+		router := setupAdminAttributeRouter(newMockAuthBearerMiddleware(&staff), newMockedAdminActionService(nil), newMockedAttributeService(nil))
+
+		payload, _ := json.Marshal(validators.AttributeDefinitionCreateData{Name: "employee_id", Type: "float"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/admin/attributes", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("Test list attribute definitions", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		router := setupAdminAttributeRouter(newMockAuthBearerMiddleware(&staff), adminActionService, newMockedAttributeService(nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/attributes", nil)
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.AttributeDefinitionsSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Len(response.Data, 1)
+		assert.Equal(models.AdminActionListAttributes, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test update attribute definition", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		router := setupAdminAttributeRouter(newMockAuthBearerMiddleware(&staff), adminActionService, newMockedAttributeService(nil))
+
+		editable := true
+		payload, _ := json.Marshal(validators.AttributeDefinitionUpdateData{UserEditable: &editable})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PATCH", "/admin/attributes/plan", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.AttributeDefinitionSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.True(response.Data.UserEditable)
+		assert.Equal("plan", adminActionService.recordRecorder.detail)
+	})
+
+	t.Run("Test delete attribute definition", func(t *testing.T) {
+		staff := tests.UserFactory()
+		attributeService := newMockedAttributeService(nil)
+		router := setupAdminAttributeRouter(newMockAuthBearerMiddleware(&staff), newMockedAdminActionService(nil), attributeService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", "/admin/attributes/plan", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Code)
+		assert.True(attributeService.recorder.deleted)
+	})
+
+	t.Run("Test delete unknown attribute definition", func(t *testing.T) {
+		staff := tests.UserFactory()
+		router := setupAdminAttributeRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAdminActionService(nil),
+			newMockedAttributeService(services.AttributeDefinitionNotFoundError{}),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", "/admin/attributes/plan", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("Test set attributes of an user", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		attributeService := newMockedAttributeService(nil)
+		router := setupAdminAttributeRouter(newMockAuthBearerMiddleware(&staff), adminActionService, attributeService)
+
+		payload, _ := json.Marshal(validators.UserAttributesData{Attributes: map[string]interface{}{"employee_id": "E-1"}})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PATCH", "/admin/users/4722679b-5a48-4e85-9084-605e8df610f4/attributes", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.AdminUserSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal("E-1", response.Data.Attributes["employee_id"])
+		assert.True(attributeService.recorder.byStaff)
+		assert.Equal(models.AdminActionSetAttributes, adminActionService.recordRecorder.action)
+	})
+}
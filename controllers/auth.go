@@ -12,9 +12,20 @@ import (
 )
 
 // Register auth endpoints to the given router
-func RegisterAuthRoutes(router *gin.Engine, authService services.IAuthService) {
+func RegisterAuthRoutes(
+	router *gin.Engine,
+	authService services.IAuthService,
+	roleService services.IRoleService,
+	phoneService services.IPhoneService,
+	phoneThrottler security.IThrottler,
+) {
 	controller := AuthController{
+		phones: PhoneController{
+			phoneService: phoneService,
+			throttler:    phoneThrottler,
+		},
 		authService: authService,
+		roleService: roleService,
 	}
 
 	publicRoutes := router.Group("/auth")
@@ -26,11 +37,15 @@ func RegisterAuthRoutes(router *gin.Engine, authService services.IAuthService) {
 
 // Controller fot /auth endpoints
 type AuthController struct {
+	phones      PhoneController
 	authService services.IAuthService
+	roleService services.IRoleService
 }
 
 // @Summary Login admin
-// @Description Logs an user into the system
+// @Description Logs an user into the system. The current mandatory legal
+// @Description documents the user has not accepted yet are answered with a
+// @Description 403, and they can be accepted along with the login.
 // @ID auth-login
 // @Tags Auth
 // @Accept json
@@ -38,7 +53,9 @@ type AuthController struct {
 // @Param user body validators.Credentials true "Logs into the system with the given credentials"
 // @Success 200 {object} serializers.TokensSerializer
 // @Failure 400 {object} helpers.HTTPError
+// @Failure 401 {object} helpers.HTTPError
 // @Failure 403 {object} helpers.HTTPError
+// @Failure 429 {object} helpers.HTTPError
 // @Router /auth/login [post]
 func (controller AuthController) Login(c *gin.Context) {
 	var input validators.Credentials
@@ -46,14 +63,20 @@ func (controller AuthController) Login(c *gin.Context) {
 		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
 		return
 	}
-	user, err := controller.authService.Authenticate(input, false)
+	user, err := controller.authService.Authenticate(input, false, helpers.NewClientInfo(c))
 	if err != nil {
-		helpers.AbortWithStatus(c, http.StatusForbidden, err)
+		controller.phones.abortLogin(c, user, err)
 		return
 	}
 
-	tokens := controller.authService.GenerateTokens(*user, security.GroupUserSelf)
-	c.JSON(http.StatusOK, serializers.NewTokensSerializer(tokens))
+	// Staff scopes are only issued through the admin login
+	scopes, _ := security.SplitStaffScopes(controller.roleService.UserScopes(*user))
+	tokens, err := controller.authService.StartSession(*user, helpers.NewClientInfo(c), scopes)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewTokensSerializer(*tokens))
 }
 
 // @Summary Refresh
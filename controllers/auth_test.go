@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"gandalf/helpers"
 	"gandalf/models"
 	"gandalf/security"
 	"gandalf/services"
@@ -14,6 +15,7 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/require"
 	"syreclabs.com/go/faker"
 )
@@ -22,8 +24,9 @@ type authenticateRecorder struct {
 	credentials validators.Credentials
 }
 
-type generateTokensRecorder struct {
+type startSessionRecorder struct {
 	user   models.User
+	client helpers.ClientInfo
 	scopes []string
 }
 
@@ -37,19 +40,30 @@ type refreshTokenRecorder struct {
 	refreshToken string
 }
 
+type impersonateRecorder struct {
+	staff  models.User
+	user   models.User
+	reason string
+}
+
 type mockAuthService struct {
 	authenticateRecorder      *authenticateRecorder
-	generateTokensRecorder    *generateTokensRecorder
+	startSessionRecorder      *startSessionRecorder
 	getAuthorizedUserRecorder *getAuthorizedUserRecorder
 	refreshTokenRecorder      *refreshTokenRecorder
+	impersonateRecorder       *impersonateRecorder
 
 	authenticateError       error
 	getAuthorizedUserError  error
 	refreshTokenError       error
 	authorizeAppError       error
 	exchangeOauthTokenError error
+	impersonateError        error
 
 	returnedUser *models.User
+
+	// Scopes of the given access tokens, checked when set
+	tokenScopes []string
 }
 
 func newMockedAuthService(
@@ -62,9 +76,10 @@ func newMockedAuthService(
 ) *mockAuthService {
 	return &mockAuthService{
 		authenticateRecorder:      new(authenticateRecorder),
-		generateTokensRecorder:    new(generateTokensRecorder),
+		startSessionRecorder:      new(startSessionRecorder),
 		getAuthorizedUserRecorder: new(getAuthorizedUserRecorder),
 		refreshTokenRecorder:      new(refreshTokenRecorder),
+		impersonateRecorder:       new(impersonateRecorder),
 		authenticateError:         authenticateError,
 		getAuthorizedUserError:    getAuthorizedUserError,
 		refreshTokenError:         refreshTokenError,
@@ -74,24 +89,30 @@ func newMockedAuthService(
 	}
 }
 
-func (service *mockAuthService) Authenticate(credentials validators.Credentials, isStaff bool) (*models.User, error) {
+func (service *mockAuthService) Authenticate(credentials validators.Credentials, isStaff bool, client helpers.ClientInfo) (*models.User, error) {
 	service.authenticateRecorder.credentials = credentials
 	return service.returnedUser, service.authenticateError
 }
 
-func (service *mockAuthService) Authorize(app *models.App, user *models.User, data validators.OauthAuthorizeData) (string, error) {
+func (service *mockAuthService) Authorize(app *models.App, user *models.User, data validators.OauthAuthorizeData, client helpers.ClientInfo, parent uuid.UUID) (string, error) {
 	return faker.RandomString(10), service.authorizeAppError
 }
 
-func (service *mockAuthService) GenerateTokens(user models.User, scopes []string) services.AuthTokens {
-	service.generateTokensRecorder.user = user
-	service.generateTokensRecorder.scopes = scopes
-	return services.AuthTokens{AccessToken: "", RefreshToken: ""}
+func (service *mockAuthService) StartSession(user models.User, client helpers.ClientInfo, scopes []string) (*services.AuthTokens, error) {
+	*service.startSessionRecorder = startSessionRecorder{user, client, scopes}
+	return &services.AuthTokens{AccessToken: "", RefreshToken: ""}, nil
+}
+
+func (service *mockAuthService) GetTokenSession(accessToken string) uuid.UUID {
+	return uuid.Nil
 }
 
 func (service *mockAuthService) GetAuthorizedUser(accessToken string, scopes []string) (*models.User, error) {
 	service.getAuthorizedUserRecorder.accessToken = accessToken
 	service.getAuthorizedUserRecorder.scopes = scopes
+	if service.tokenScopes != nil && len(security.IntersectScopes(scopes, service.tokenScopes)) != len(scopes) {
+		return nil, services.AuthorizationError{}
+	}
 	return service.returnedUser, service.getAuthorizedUserError
 }
 
@@ -101,13 +122,84 @@ func (service *mockAuthService) RefreshToken(accessToken string, refreshToken st
 	return &services.AuthTokens{AccessToken: "", RefreshToken: refreshToken}, service.refreshTokenError
 }
 
-func (service *mockAuthService) ExchangeOauthToken(app models.App, data validators.OauthExchangeToken) (*services.AuthTokens, error) {
-	return &services.AuthTokens{AccessToken: "", RefreshToken: ""}, service.exchangeOauthTokenError
+func (service *mockAuthService) ExchangeOauthToken(app models.App, data validators.OauthExchangeToken, client helpers.ClientInfo) (*services.AuthTokens, error) {
+	if service.exchangeOauthTokenError != nil {
+		return nil, service.exchangeOauthTokenError
+	}
+	return &services.AuthTokens{AccessToken: "", RefreshToken: ""}, nil
+}
+
+func (service *mockAuthService) Impersonate(staff models.User, user models.User, reason string, client helpers.ClientInfo) (*services.AuthTokens, error) {
+	*service.impersonateRecorder = impersonateRecorder{staff, user, reason}
+	if service.impersonateError != nil {
+		return nil, service.impersonateError
+	}
+	return &services.AuthTokens{AccessToken: "impersonation", RefreshToken: ""}, nil
+}
+
+func (service *mockAuthService) GetTokenImpersonator(accessToken string) *models.User {
+	return nil
+}
+
+func (service *mockAuthService) RecordImpersonatedRequest(staff models.User, user models.User, request string, client helpers.ClientInfo) error {
+	return nil
+}
+
+type roleAssignmentRecorder struct {
+	user models.User
+	name string
+}
+
+type mockRoleService struct {
+	assignRecorder *roleAssignmentRecorder
+	revokeRecorder *roleAssignmentRecorder
+
+	scopes          []string
+	assignmentError error
+}
+
+func newMockedRoleService(scopes []string, assignmentError error) *mockRoleService {
+	return &mockRoleService{
+		assignRecorder:  new(roleAssignmentRecorder),
+		revokeRecorder:  new(roleAssignmentRecorder),
+		scopes:          scopes,
+		assignmentError: assignmentError,
+	}
+}
+
+func (service *mockRoleService) List() []models.Role {
+	return []models.Role{tests.RoleFactory(service.scopes...)}
+}
+
+func (service *mockRoleService) Read(name string) (*models.Role, error) {
+	role := tests.RoleFactory(service.scopes...)
+	return &role, service.assignmentError
+}
+
+func (service *mockRoleService) UserRoles(user models.User) []models.Role {
+	return []models.Role{tests.RoleFactory(service.scopes...)}
+}
+
+func (service *mockRoleService) UserScopes(user models.User) []string {
+	return service.scopes
+}
+
+func (service *mockRoleService) Assign(user models.User, name string) error {
+	*service.assignRecorder = roleAssignmentRecorder{user, name}
+	return service.assignmentError
+}
+
+func (service *mockRoleService) Revoke(user models.User, name string) error {
+	*service.revokeRecorder = roleAssignmentRecorder{user, name}
+	return service.assignmentError
 }
 
 func setupAuthRouter(authService services.IAuthService) *gin.Engine {
 	router := gin.Default()
-	RegisterAuthRoutes(router, authService)
+	roleService := newMockedRoleService([]string{
+		security.ScopeUserRead, security.ScopeUserWrite, security.ScopeUserReadAll,
+	}, nil)
+	RegisterAuthRoutes(router, authService, roleService, newMockedPhoneService(nil), newMockedThrottler(true))
 	return router
 }
 
@@ -116,7 +208,7 @@ func TestLogin(t *testing.T) {
 
 	t.Run("Test login successfully", func(t *testing.T) {
 		user := tests.UserFactory()
-		expectedScopes := security.GroupUserSelf
+		expectedScopes := []string{security.ScopeUserRead, security.ScopeUserWrite}
 		authService := newMockedAuthService(&user, nil, nil, nil, nil, nil)
 		router := setupAuthRouter(authService)
 		var response gin.H
@@ -134,8 +226,8 @@ func TestLogin(t *testing.T) {
 		assert.Equal(recorder.Result().StatusCode, http.StatusOK)
 		assert.Equal(authService.authenticateRecorder.credentials.Email, user.Email)
 		assert.Equal(authService.authenticateRecorder.credentials.Password, user.Password)
-		assert.Equal(authService.generateTokensRecorder.user.Email, user.Email)
-		assert.Equal(authService.generateTokensRecorder.scopes, expectedScopes)
+		assert.Equal(authService.startSessionRecorder.user.Email, user.Email)
+		assert.Equal(authService.startSessionRecorder.scopes, expectedScopes)
 	})
 
 	t.Run("Test login wrong payload", func(t *testing.T) {
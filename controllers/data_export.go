@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"fmt"
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// Register data export endpoints to the given router
+func RegisterDataExportRoutes(
+	router *gin.Engine,
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	dataExportService services.IDataExportService,
+) {
+	controller := DataExportController{
+		dataExportService: dataExportService,
+		authMiddleware:    authBearerMiddleware,
+	}
+
+	// The archive holds all the data of the user, so it is only reachable
+	// with a scope which is never granted to the apps
+	exportRoutes := router.Group("/me/export")
+	{
+		scopes := []string{security.ScopeUserExport}
+		exportRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+		exportRoutes.POST("", controller.RequestMyDataExport)
+		exportRoutes.GET("/:uuid", controller.ReadMyDataExport)
+		exportRoutes.GET("/:uuid/download", controller.DownloadMyDataExport)
+	}
+}
+
+// Controller for /me/export endpoints
+type DataExportController struct {
+	dataExportService services.IDataExportService
+	authMiddleware    middlewares.IAuthBearerMiddleware
+}
+
+// @Summary Request my data export
+// @Description Starts building a JSON archive with all my data. I am
+// @Description notified by email once it can be downloaded.
+// @ID me-export-request
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Success 202 {object} serializers.DataExportSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:export]
+// @Router /me/export [post]
+func (controller DataExportController) RequestMyDataExport(c *gin.Context) {
+	if abortImpersonated(c, controller.authMiddleware) {
+		return
+	}
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	export, err := controller.dataExportService.Request(*user, helpers.NewClientInfo(c))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, serializers.NewDataExportSerializer(*export))
+}
+
+// @Summary Get my data export
+// @Description Get the status of one of my data exports
+// @ID me-export-read
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Data export uuid"
+// @Success 200 {object} serializers.DataExportSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:export]
+// @Router /me/export/{uuid} [get]
+func (controller DataExportController) ReadMyDataExport(c *gin.Context) {
+	var input validators.DataExportReadData
+	if err := c.ShouldBindUri(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	export, err := controller.dataExportService.Read(*user, uuid.FromStringOrNil(input.UUID))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewDataExportSerializer(*export))
+}
+
+// @Summary Download my data export
+// @Description Downloads the JSON archive of one of my data exports, until
+// @Description it expires
+// @ID me-export-download
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Data export uuid"
+// @Success 200 {object} services.DataExportArchive
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Failure 409 {object} helpers.HTTPError
+// @Failure 410 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:export]
+// @Router /me/export/{uuid}/download [get]
+func (controller DataExportController) DownloadMyDataExport(c *gin.Context) {
+	var input validators.DataExportReadData
+	if err := c.ShouldBindUri(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	export, err := controller.dataExportService.Read(*user, uuid.FromStringOrNil(input.UUID))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return
+	}
+
+	archive, err := controller.dataExportService.Archive(*export)
+	if err != nil {
+		status := http.StatusGone
+		if _, pending := err.(services.DataExportNotReadyError); pending {
+			status = http.StatusConflict
+		}
+		helpers.AbortWithStatus(c, status, err)
+		return
+	}
+
+	filename := fmt.Sprintf("gandalf-export-%s.json", export.ReadyAt.Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/json", []byte(archive))
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/tests"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type mockDataExportService struct {
+	export     *models.DataExport
+	readError  error
+	requestErr error
+	requested  *models.User
+}
+
+func newMockedDataExportService(export *models.DataExport, readError error) *mockDataExportService {
+	return &mockDataExportService{export: export, readError: readError}
+}
+
+func (service *mockDataExportService) Request(user models.User, client helpers.ClientInfo) (*models.DataExport, error) {
+	service.requested = &user
+	if service.requestErr != nil {
+		return nil, service.requestErr
+	}
+	return service.export, nil
+}
+
+func (service *mockDataExportService) Read(user models.User, uuid uuid.UUID) (*models.DataExport, error) {
+	if service.readError != nil {
+		return nil, service.readError
+	}
+	return service.export, nil
+}
+
+func (service *mockDataExportService) Archive(export models.DataExport) (string, error) {
+	return services.DataExportService{}.Archive(export)
+}
+
+func (service *mockDataExportService) BuildPending() {}
+
+func setupDataExportRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	dataExportService services.IDataExportService,
+) *gin.Engine {
+	router := gin.Default()
+	RegisterDataExportRoutes(router, authBearerMiddleware, dataExportService)
+	return router
+}
+
+func TestRequestMyDataExport(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test request export successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		export := models.NewDataExport(user)
+		export.UUID, _ = uuid.NewV4()
+		service := newMockedDataExportService(&export, nil)
+		router := setupDataExportRouter(newMockAuthBearerMiddleware(&user), service)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/export", nil)
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.DataExportSerializer
+		json.NewDecoder(recorder.Body).Decode(&response)
+		assert.Equal(http.StatusAccepted, recorder.Result().StatusCode)
+		assert.Equal(export.UUID, response.Data.UUID)
+		assert.Equal(models.DataExportPending, response.Data.Status)
+		assert.Equal(user.Email, service.requested.Email)
+	})
+
+	t.Run("Test request export fails", func(t *testing.T) {
+		user := tests.UserFactory()
+		service := newMockedDataExportService(nil, nil)
+		service.requestErr = errors.New("Whoops")
+		router := setupDataExportRouter(newMockAuthBearerMiddleware(&user), service)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/export", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+}
+
+func TestDataExportScope(t *testing.T) {
+	assert := require.New(t)
+
+	// Requests an export with a token which holds the given scopes
+	requestExport := func(scopes []string) *httptest.ResponseRecorder {
+		user := tests.UserFactory()
+		export := models.NewDataExport(user)
+		authService := newMockedAuthService(&user, nil, nil, nil, nil, nil)
+		authService.tokenScopes = scopes
+		router := setupDataExportRouter(middlewares.NewAuthBearerMiddleware(authService), newMockedDataExportService(&export, nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/export", nil)
+		request.Header.Set("Authorization", "Bearer token")
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("Test tokens issued to apps cannot export", func(t *testing.T) {
+		recorder := requestExport(security.GroupUserOauth2Request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test tokens of the user sessions can export", func(t *testing.T) {
+		recorder := requestExport([]string{security.ScopeUserRead, security.ScopeUserExport})
+
+		assert.Equal(http.StatusAccepted, recorder.Result().StatusCode)
+	})
+}
+
+func TestDownloadMyDataExport(t *testing.T) {
+	assert := require.New(t)
+	exportUUID, _ := uuid.NewV4()
+
+	t.Run("Test download export successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		export := models.NewDataExport(user)
+		export.Complete(`{"profile": {}}`, time.Hour)
+		router := setupDataExportRouter(newMockAuthBearerMiddleware(&user), newMockedDataExportService(&export, nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/me/export/"+exportUUID.String()+"/download", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Contains(recorder.Header().Get("Content-Disposition"), "attachment")
+		assert.Equal(`{"profile": {}}`, recorder.Body.String())
+	})
+
+	t.Run("Test download pending export", func(t *testing.T) {
+		user := tests.UserFactory()
+		export := models.NewDataExport(user)
+		router := setupDataExportRouter(newMockAuthBearerMiddleware(&user), newMockedDataExportService(&export, nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/me/export/"+exportUUID.String()+"/download", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusConflict, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test download expired export", func(t *testing.T) {
+		user := tests.UserFactory()
+		export := models.NewDataExport(user)
+		export.Complete(`{"profile": {}}`, -time.Minute)
+		router := setupDataExportRouter(newMockAuthBearerMiddleware(&user), newMockedDataExportService(&export, nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/me/export/"+exportUUID.String()+"/download", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusGone, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test download export of other user", func(t *testing.T) {
+		user := tests.UserFactory()
+		router := setupDataExportRouter(newMockAuthBearerMiddleware(&user), newMockedDataExportService(nil, services.DataExportNotFoundError{}))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/me/export/"+exportUUID.String()+"/download", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test download export with invalid uuid", func(t *testing.T) {
+		user := tests.UserFactory()
+		router := setupDataExportRouter(newMockAuthBearerMiddleware(&user), newMockedDataExportService(nil, nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/me/export/whoops/download", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+}
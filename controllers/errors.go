@@ -0,0 +1,41 @@
+package controllers
+
+// This error will be returned when a public endpoint receives too many
+// requests for the same email or from the same ip
+type NotificationThrottledError struct{}
+
+func (e NotificationThrottledError) Error() string {
+	return "Too many requests, please try again later"
+}
+
+// This error will be returned when a staff user logs into the admin api
+// but none of his roles grants staff scopes
+type StaffScopesNotGrantedError struct{}
+
+func (e StaffScopesNotGrantedError) Error() string {
+	return "No staff scopes are granted to the user"
+}
+
+// This error will be returned when an user tries to manage an app or an
+// organization without the required membership
+type AppAccessDeniedError struct{}
+
+func (e AppAccessDeniedError) Error() string {
+	return "You are not allowed to manage this app"
+}
+
+// This error will be returned when an user tries to perform an action on an
+// organization that requires a higher membership role
+type OrganizationAccessDeniedError struct{}
+
+func (e OrganizationAccessDeniedError) Error() string {
+	return "You are not allowed to perform this action on the organization"
+}
+
+// This error will be returned when staff acting as an user tries to perform
+// an action only the user himself can, like changing his password
+type ImpersonationForbiddenError struct{}
+
+func (e ImpersonationForbiddenError) Error() string {
+	return "This action is not allowed while impersonating the user"
+}
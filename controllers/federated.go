@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register federated login endpoints to the given router
+func RegisterFederatedRoutes(
+	router *gin.Engine,
+	authService services.IAuthService,
+	roleService services.IRoleService,
+	identityProviderService services.IIdentityProviderService,
+	federatedLoginService services.IFederatedLoginService,
+	phoneService services.IPhoneService,
+	phoneThrottler security.IThrottler,
+) {
+	controller := FederatedController{
+		phones: PhoneController{
+			phoneService: phoneService,
+			throttler:    phoneThrottler,
+		},
+		authService:             authService,
+		roleService:             roleService,
+		identityProviderService: identityProviderService,
+		federatedLoginService:   federatedLoginService,
+	}
+
+	publicRoutes := router.Group("/auth/federated")
+	{
+		publicRoutes.GET("", controller.ListFederatedProviders)
+		publicRoutes.POST("/:name/start", controller.StartFederatedLogin)
+		publicRoutes.POST("/:name/callback", controller.FinishFederatedLogin)
+	}
+}
+
+// Controller for /auth/federated endpoints
+type FederatedController struct {
+	phones                  PhoneController
+	authService             services.IAuthService
+	roleService             services.IRoleService
+	identityProviderService services.IIdentityProviderService
+	federatedLoginService   services.IFederatedLoginService
+}
+
+// Reads the enabled provider given in the uri. Returns nil and aborts the
+// request if it does not exist.
+func (controller FederatedController) readProvider(c *gin.Context) *models.IdentityProvider {
+	var uri validators.IdentityProviderReadData
+	if err := c.ShouldBindUri(&uri); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return nil
+	}
+
+	provider, err := controller.identityProviderService.Read(uri.Name)
+	if err == nil && provider.Disabled {
+		err = services.IdentityProviderNotFoundError{}
+	}
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+	return provider
+}
+
+// @Summary List federated providers
+// @Description List the identity providers the users can sign in with
+// @ID auth-federated-list
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Success 200 {object} serializers.FederatedProvidersSerializer
+// @Router /auth/federated [get]
+func (controller FederatedController) ListFederatedProviders(c *gin.Context) {
+	providers := controller.identityProviderService.List(false)
+	c.JSON(http.StatusOK, serializers.NewFederatedProvidersSerializer(providers))
+}
+
+// @Summary Start a federated login
+// @Description Starts a login with an identity provider. The browser must
+// @Description be sent to the returned authorization url and keep the
+// @Description returned state, which the provider sends back along with the
+// @Description code to the given redirect uri.
+// @ID auth-federated-start
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param name path string true "Identity provider name"
+// @Param data body validators.FederatedLoginStartData true "Where the provider sends the browser back to"
+// @Success 200 {object} serializers.FederatedAuthorizationSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Router /auth/federated/{name}/start [post]
+func (controller FederatedController) StartFederatedLogin(c *gin.Context) {
+	provider := controller.readProvider(c)
+	if provider == nil {
+		return
+	}
+
+	var input validators.FederatedLoginStartData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	authorization, err := controller.federatedLoginService.Start(*provider, input)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewFederatedAuthorizationSerializer(authorization.URL, authorization.State))
+}
+
+// @Summary Finish a federated login
+// @Description Finishes a login with the code and the state the identity
+// @Description provider has sent the browser back with. Identities which
+// @Description are not linked yet are linked by verified email to an
+// @Description existing user, or to a new one. When the login misses the
+// @Description code sent to the phone, or the acceptance of the legal
+// @Description documents, it can be finished again with the same state
+// @Description along with them.
+// @ID auth-federated-callback
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param name path string true "Identity provider name"
+// @Param data body validators.FederatedLoginCallbackData true "Answer of the identity provider"
+// @Success 200 {object} serializers.TokensSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 401 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Failure 429 {object} helpers.HTTPError
+// @Router /auth/federated/{name}/callback [post]
+func (controller FederatedController) FinishFederatedLogin(c *gin.Context) {
+	provider := controller.readProvider(c)
+	if provider == nil {
+		return
+	}
+
+	var input validators.FederatedLoginCallbackData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := controller.federatedLoginService.Finish(*provider, input, helpers.NewClientInfo(c))
+	if err != nil {
+		controller.phones.abortLogin(c, user, err)
+		return
+	}
+
+	// Staff scopes are only issued through the admin login
+	scopes, _ := security.SplitStaffScopes(controller.roleService.UserScopes(*user))
+	tokens, err := controller.authService.StartSession(*user, helpers.NewClientInfo(c), scopes)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewTokensSerializer(*tokens))
+}
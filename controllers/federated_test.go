@@ -0,0 +1,383 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/services"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type identityProviderRecorder struct {
+	name            string
+	includeDisabled bool
+	create          validators.IdentityProviderCreateData
+	update          validators.IdentityProviderUpdateData
+	deleted         *models.IdentityProvider
+}
+
+type mockIdentityProviderService struct {
+	recorder *identityProviderRecorder
+	disabled bool
+	err      error
+}
+
+func newMockedIdentityProviderService(err error) *mockIdentityProviderService {
+	return &mockIdentityProviderService{recorder: new(identityProviderRecorder), err: err}
+}
+
+func (service *mockIdentityProviderService) provider(name string) models.IdentityProvider {
+	provider := models.NewIdentityProvider(name, "Acme", "https://idp.acme.test", "gandalf", "secret")
+	provider.Disabled = service.disabled
+	return provider
+}
+
+func (service *mockIdentityProviderService) Create(data validators.IdentityProviderCreateData) (*models.IdentityProvider, error) {
+	service.recorder.create = data
+	if service.err != nil {
+		return nil, service.err
+	}
+	provider := service.provider(data.Name)
+	return &provider, nil
+}
+
+func (service *mockIdentityProviderService) Read(name string) (*models.IdentityProvider, error) {
+	service.recorder.name = name
+	if service.err != nil {
+		return nil, service.err
+	}
+	provider := service.provider(name)
+	return &provider, nil
+}
+
+func (service *mockIdentityProviderService) List(includeDisabled bool) []models.IdentityProvider {
+	service.recorder.includeDisabled = includeDisabled
+	return []models.IdentityProvider{service.provider("acme")}
+}
+
+func (service *mockIdentityProviderService) Update(provider *models.IdentityProvider, data validators.IdentityProviderUpdateData) error {
+	service.recorder.update = data
+	return nil
+}
+
+func (service *mockIdentityProviderService) Delete(provider models.IdentityProvider) error {
+	service.recorder.deleted = &provider
+	return nil
+}
+
+type federatedLoginRecorder struct {
+	provider models.IdentityProvider
+	start    validators.FederatedLoginStartData
+	callback validators.FederatedLoginCallbackData
+}
+
+type mockFederatedLoginService struct {
+	recorder *federatedLoginRecorder
+	user     *models.User
+	err      error
+}
+
+func newMockedFederatedLoginService(user *models.User, err error) *mockFederatedLoginService {
+	return &mockFederatedLoginService{recorder: new(federatedLoginRecorder), user: user, err: err}
+}
+
+func (service *mockFederatedLoginService) Start(provider models.IdentityProvider, data validators.FederatedLoginStartData) (*services.FederatedAuthorization, error) {
+	service.recorder.provider = provider
+	service.recorder.start = data
+	return &services.FederatedAuthorization{URL: "https://idp.acme.test/authorize?state=state", State: "state"}, nil
+}
+
+func (service *mockFederatedLoginService) Finish(provider models.IdentityProvider, data validators.FederatedLoginCallbackData, client helpers.ClientInfo) (*models.User, error) {
+	service.recorder.provider = provider
+	service.recorder.callback = data
+	return service.user, service.err
+}
+
+func setupFederatedRouter(
+	authService services.IAuthService,
+	roleService services.IRoleService,
+	identityProviderService services.IIdentityProviderService,
+	federatedLoginService services.IFederatedLoginService,
+) *gin.Engine {
+	router := gin.Default()
+	RegisterFederatedRoutes(
+		router, authService, roleService, identityProviderService, federatedLoginService,
+		newMockedPhoneService(nil), newMockedThrottler(true),
+	)
+	return router
+}
+
+func setupAdminProviderRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	adminActionService services.IAdminActionService,
+	identityProviderService services.IIdentityProviderService,
+) *gin.Engine {
+	router := gin.Default()
+	userService := newMockedUserService(nil, nil, nil, nil, nil)
+	RegisterAdminRoutes(
+		router, authBearerMiddleware,
+		newMockedAuthService(nil, nil, nil, nil, nil, nil), &userService,
+		newMockedOutboxService(nil),
+		adminActionService, newMockedRoleService(security.GroupStaff, nil),
+		newMockedSessionService(nil), newMockedAuditService(),
+		newMockedNotificationTemplateService(nil),
+		identityProviderService, newMockedSCIMService(nil),
+		newMockedAttributeService(nil), newMockedLegalService(nil),
+		newMockedPhoneService(nil), newMockedThrottler(true),
+	)
+	return router
+}
+
+func TestFederatedLogin(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list federated providers", func(t *testing.T) {
+		providerService := newMockedIdentityProviderService(nil)
+		router := setupFederatedRouter(
+			newMockedAuthService(nil, nil, nil, nil, nil, nil), newMockedRoleService(nil, nil),
+			providerService, newMockedFederatedLoginService(nil, nil),
+		)
+		var response gin.H
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/auth/federated", nil)
+		router.ServeHTTP(recorder, request)
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.False(providerService.recorder.includeDisabled)
+		assert.Equal("federated-provider", response["type"])
+	})
+
+	t.Run("Test start federated login", func(t *testing.T) {
+		loginService := newMockedFederatedLoginService(nil, nil)
+		router := setupFederatedRouter(
+			newMockedAuthService(nil, nil, nil, nil, nil, nil), newMockedRoleService(nil, nil),
+			newMockedIdentityProviderService(nil), loginService,
+		)
+		var response gin.H
+
+		payload, _ := json.Marshal(map[string]string{"redirect_uri": "https://app.test/callback"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/federated/acme/start", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal("acme", loginService.recorder.provider.Name)
+		assert.Equal("https://app.test/callback", loginService.recorder.start.RedirectURI)
+		assert.Equal("state", response["state"])
+	})
+
+	t.Run("Test start federated login with a disabled provider", func(t *testing.T) {
+		providerService := newMockedIdentityProviderService(nil)
+		providerService.disabled = true
+		loginService := newMockedFederatedLoginService(nil, nil)
+		router := setupFederatedRouter(
+			newMockedAuthService(nil, nil, nil, nil, nil, nil), newMockedRoleService(nil, nil),
+			providerService, loginService,
+		)
+
+		payload, _ := json.Marshal(map[string]string{"redirect_uri": "https://app.test/callback"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/federated/acme/start", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+		assert.Equal("", loginService.recorder.start.RedirectURI)
+	})
+
+	t.Run("Test finish federated login", func(t *testing.T) {
+		user := tests.UserFactory()
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		loginService := newMockedFederatedLoginService(&user, nil)
+		router := setupFederatedRouter(
+			authService, newMockedRoleService(append([]string{security.ScopeUserRead}, security.GroupStaff...), nil),
+			newMockedIdentityProviderService(nil), loginService,
+		)
+
+		payload, _ := json.Marshal(map[string]string{"code": "code", "state": "state"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/federated/acme/callback", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal("code", loginService.recorder.callback.Code)
+		assert.Equal(user.Email, authService.startSessionRecorder.user.Email)
+		assert.Equal([]string{security.ScopeUserRead}, authService.startSessionRecorder.scopes)
+	})
+
+	t.Run("Test finish rejected federated login", func(t *testing.T) {
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		router := setupFederatedRouter(
+			authService, newMockedRoleService(nil, nil),
+			newMockedIdentityProviderService(nil),
+			newMockedFederatedLoginService(nil, services.FederatedLoginNotValidError{}),
+		)
+
+		payload, _ := json.Marshal(map[string]string{"code": "code", "state": "state"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/federated/acme/callback", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+		assert.Nil(authService.startSessionRecorder.scopes)
+	})
+
+	t.Run("Test finish federated login asks for the phone code", func(t *testing.T) {
+		user := tests.UserFactory()
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := gin.Default()
+		RegisterFederatedRoutes(
+			router, authService, newMockedRoleService(nil, nil), newMockedIdentityProviderService(nil),
+			newMockedFederatedLoginService(&user, services.PhoneCodeRequiredError{}),
+			phoneService, newMockedThrottler(true),
+		)
+
+		payload, _ := json.Marshal(map[string]string{"code": "code", "state": "state"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/federated/acme/callback", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusUnauthorized, recorder.Result().StatusCode)
+		assert.Equal(models.PhoneCodePurposeMFA, phoneService.sendRecorder.purpose)
+		assert.Nil(authService.startSessionRecorder.scopes)
+	})
+
+}
+
+func TestAdminIdentityProviders(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list identity providers", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		providerService := newMockedIdentityProviderService(nil)
+		router := setupAdminProviderRouter(authMiddleware, adminActionService, providerService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/identity-providers", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeProviderReadAll}, *authMiddleware.requestedScopes)
+		assert.True(providerService.recorder.includeDisabled)
+		assert.Equal(models.AdminActionListProviders, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test create identity provider", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		providerService := newMockedIdentityProviderService(nil)
+		router := setupAdminProviderRouter(authMiddleware, adminActionService, providerService)
+		var response gin.H
+
+		payload, _ := json.Marshal(map[string]string{
+			"name":          "acme",
+			"display_name":  "Acme",
+			"issuer":        "https://idp.acme.test",
+			"client_id":     "gandalf",
+			"client_secret": "secret",
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/admin/identity-providers", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		assert.Equal(http.StatusCreated, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeProviderWriteAll}, *authMiddleware.requestedScopes)
+		assert.Equal("acme", providerService.recorder.create.Name)
+		assert.Equal(models.AdminActionAddProvider, adminActionService.recordRecorder.action)
+		assert.NotContains(recorder.Body.String(), "secret")
+	})
+
+	t.Run("Test create identity provider with an invalid name", func(t *testing.T) {
+		staff := tests.UserFactory()
+		providerService := newMockedIdentityProviderService(nil)
+		router := setupAdminProviderRouter(newMockAuthBearerMiddleware(&staff), newMockedAdminActionService(nil), providerService)
+
+		payload, _ := json.Marshal(map[string]string{
+			"name": "Acme Corp", "display_name": "Acme", "issuer": "https://idp.acme.test", "client_id": "gandalf",
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/admin/identity-providers", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+		assert.Equal("", providerService.recorder.create.Name)
+	})
+
+	t.Run("Test create identity provider with an unreachable issuer", func(t *testing.T) {
+		staff := tests.UserFactory()
+		providerService := newMockedIdentityProviderService(services.IdentityProviderDiscoveryError{})
+		router := setupAdminProviderRouter(newMockAuthBearerMiddleware(&staff), newMockedAdminActionService(nil), providerService)
+
+		payload, _ := json.Marshal(map[string]string{
+			"name": "acme", "display_name": "Acme", "issuer": "https://idp.acme.test", "client_id": "gandalf",
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/admin/identity-providers", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadGateway, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test update identity provider", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		providerService := newMockedIdentityProviderService(nil)
+		router := setupAdminProviderRouter(newMockAuthBearerMiddleware(&staff), adminActionService, providerService)
+
+		payload, _ := json.Marshal(map[string]interface{}{"disabled": true})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PATCH", "/admin/identity-providers/acme", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.True(*providerService.recorder.update.Disabled)
+		assert.Equal(models.AdminActionEditProvider, adminActionService.recordRecorder.action)
+		assert.Equal("acme", adminActionService.recordRecorder.detail)
+	})
+
+	t.Run("Test delete identity provider", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		providerService := newMockedIdentityProviderService(nil)
+		router := setupAdminProviderRouter(newMockAuthBearerMiddleware(&staff), adminActionService, providerService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", "/admin/identity-providers/acme", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal("acme", providerService.recorder.deleted.Name)
+		assert.Equal(models.AdminActionDropProvider, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test delete missing identity provider", func(t *testing.T) {
+		staff := tests.UserFactory()
+		providerService := newMockedIdentityProviderService(errors.New("not found"))
+		router := setupAdminProviderRouter(newMockAuthBearerMiddleware(&staff), newMockedAdminActionService(nil), providerService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", "/admin/identity-providers/acme", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+		assert.Nil(providerService.recorder.deleted)
+	})
+}
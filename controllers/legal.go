@@ -0,0 +1,313 @@
+package controllers
+
+import (
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// Register legal document endpoints to the given router
+func RegisterLegalRoutes(
+	router *gin.Engine,
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	appService services.IAppService,
+	legalService services.ILegalService,
+) {
+	controller := LegalController{
+		apps: AppController{
+			appService:     appService,
+			authMiddleware: authBearerMiddleware,
+		},
+		appService:     appService,
+		legalService:   legalService,
+		authMiddleware: authBearerMiddleware,
+	}
+
+	publicRoutes := router.Group("/legal-documents")
+	{
+		publicRoutes.GET("", controller.ListCurrentDocuments)
+	}
+
+	readRoutes := router.Group("/me/legal-acceptances")
+	{
+		scopes := []string{security.ScopeUserRead}
+		readRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+		readRoutes.GET("", controller.ListMyAcceptances)
+	}
+
+	updateRoutes := router.Group("/me/legal-acceptances")
+	{
+		scopes := []string{security.ScopeUserWrite}
+		updateRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+		updateRoutes.POST("", controller.AcceptDocuments)
+	}
+
+	writeRoutes := router.Group("/apps/:uuid/legal-documents")
+	{
+		scopes := []string{security.ScopeAppWrite}
+		writeRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		writeRoutes.GET("", controller.ListAppDocuments)
+		writeRoutes.POST("", controller.CreateAppDocument)
+		writeRoutes.POST("/:document/publish", controller.PublishAppDocument)
+		writeRoutes.DELETE("/:document", controller.DeleteAppDocument)
+	}
+}
+
+// Controller for /legal-documents, /me/legal-acceptances and
+// /apps/{uuid}/legal-documents endpoints
+type LegalController struct {
+	apps           AppController
+	appService     services.IAppService
+	legalService   services.ILegalService
+	authMiddleware middlewares.IAuthBearerMiddleware
+}
+
+// Aborts the request when the user must accept legal documents first,
+// answering with the documents he has to accept. Returns false, and leaves
+// the request untouched, for any other error.
+func abortLegalAcceptance(c *gin.Context, err error) bool {
+	required, ok := err.(services.LegalAcceptanceRequiredError)
+	if !ok {
+		return false
+	}
+	c.JSON(http.StatusForbidden, serializers.NewLegalAcceptanceRequiredSerializer(
+		http.StatusForbidden, required.Error(), required.Documents,
+	))
+	return true
+}
+
+// Reads the legal document of the given app given in the uri. Returns nil
+// and aborts the request if it does not exist.
+func (controller LegalController) appDocument(c *gin.Context, app *models.App) *models.LegalDocument {
+	var uri validators.AppLegalDocumentReadData
+	if err := c.ShouldBindUri(&uri); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return nil
+	}
+
+	document, err := controller.legalService.Read(uuid.FromStringOrNil(uri.Document), app)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+	return document
+}
+
+// @Summary List the current legal documents
+// @Description List the current version of the terms of service and of the
+// @Description privacy policy. The documents of the app with the given
+// @Description client id, which are shown when the users authorize it, are
+// @Description listed along with the deployment wide ones.
+// @ID legal-documents-current
+// @Tags Legal
+// @Accept json
+// @Produce json
+// @Param client_id query string false "Client id of the app"
+// @Success 200 {object} serializers.LegalDocumentsSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Router /legal-documents [get]
+func (controller LegalController) ListCurrentDocuments(c *gin.Context) {
+	var query validators.LegalDocumentQueryData
+	if err := c.ShouldBindQuery(&query); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var app *models.App
+	if query.ClientID != "" {
+		var err error
+		app, err = controller.appService.ReadByClientID(uuid.FromStringOrNil(query.ClientID))
+		if err != nil {
+			helpers.AbortWithStatus(c, http.StatusNotFound, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, serializers.NewLegalDocumentsSerializer(controller.legalService.Current(app)))
+}
+
+// @Summary List my legal acceptances
+// @Description List the versions of the legal documents I accepted, along
+// @Description with when and from where I did it
+// @ID me-legal-acceptances
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Success 200 {object} serializers.LegalAcceptancesSerializer
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:read]
+// @Router /me/legal-acceptances [get]
+func (controller LegalController) ListMyAcceptances(c *gin.Context) {
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	c.JSON(http.StatusOK, serializers.NewLegalAcceptancesSerializer(controller.legalService.Acceptances(*user)))
+}
+
+// @Summary Accept legal documents
+// @Description Accepts the given published versions of the legal
+// @Description documents. The ones I already accepted are kept as they are.
+// @ID me-legal-accept
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param data body validators.LegalAcceptanceData true "Documents to accept"
+// @Success 200 {object} serializers.LegalAcceptancesSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:write]
+// @Router /me/legal-acceptances [post]
+func (controller LegalController) AcceptDocuments(c *gin.Context) {
+	if abortImpersonated(c, controller.authMiddleware) {
+		return
+	}
+	var input validators.LegalAcceptanceData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	audit := services.AuditContext{Actor: user, Client: helpers.NewClientInfo(c)}
+	if err := controller.legalService.Accept(*user, input.Documents, audit); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewLegalAcceptancesSerializer(controller.legalService.Acceptances(*user)))
+}
+
+// @Summary List app legal documents
+// @Description List every version of the legal documents attached to an
+// @Description app, including the unpublished ones
+// @ID legal-documents-app-list
+// @Tags Legal
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Success 200 {object} serializers.LegalDocumentsSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/legal-documents [get]
+func (controller LegalController) ListAppDocuments(c *gin.Context) {
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	app := controller.apps.managedApp(c, *user)
+	if app == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, serializers.NewLegalDocumentsSerializer(controller.legalService.List(app)))
+}
+
+// @Summary Create an app legal document
+// @Description Creates an unpublished version of the terms or the privacy
+// @Description policy of an app, which the users are shown when they
+// @Description authorize it once it is published
+// @ID legal-documents-app-create
+// @Tags Legal
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Param data body validators.LegalDocumentCreateData true "Document data"
+// @Success 201 {object} serializers.LegalDocumentSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/legal-documents [post]
+func (controller LegalController) CreateAppDocument(c *gin.Context) {
+	var input validators.LegalDocumentCreateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	app := controller.apps.managedApp(c, *user)
+	if app == nil {
+		return
+	}
+
+	document, err := controller.legalService.Create(input, app)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusCreated, serializers.NewLegalDocumentSerializer(*document))
+}
+
+// @Summary Publish an app legal document
+// @Description Publishes a version of a legal document of an app. When it
+// @Description is mandatory, the users must accept it before authorizing
+// @Description the app again.
+// @ID legal-documents-app-publish
+// @Tags Legal
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Param document path string true "Document uuid"
+// @Success 200 {object} serializers.LegalDocumentSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/legal-documents/{document}/publish [post]
+func (controller LegalController) PublishAppDocument(c *gin.Context) {
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	app := controller.apps.managedApp(c, *user)
+	if app == nil {
+		return
+	}
+	document := controller.appDocument(c, app)
+	if document == nil {
+		return
+	}
+
+	if err := controller.legalService.Publish(document); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewLegalDocumentSerializer(*document))
+}
+
+// @Summary Delete an app legal document
+// @Description Deletes an unpublished version of a legal document of an
+// @Description app. Published versions are kept as the proof of what the
+// @Description users accepted.
+// @ID legal-documents-app-delete
+// @Tags Legal
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Param document path string true "Document uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/legal-documents/{document} [delete]
+func (controller LegalController) DeleteAppDocument(c *gin.Context) {
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	app := controller.apps.managedApp(c, *user)
+	if app == nil {
+		return
+	}
+	document := controller.appDocument(c, app)
+	if document == nil {
+		return
+	}
+
+	if err := controller.legalService.Delete(*document); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
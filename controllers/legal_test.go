@@ -0,0 +1,552 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type legalRecorder struct {
+	app       *models.App
+	create    validators.LegalDocumentCreateData
+	documents []string
+	audit     services.AuditContext
+	published bool
+	deleted   bool
+}
+
+type mockLegalService struct {
+	recorder *legalRecorder
+	err      error
+}
+
+func newMockedLegalService(err error) *mockLegalService {
+	return &mockLegalService{recorder: new(legalRecorder), err: err}
+}
+
+func (service *mockLegalService) Current(app *models.App) []models.LegalDocument {
+	service.recorder.app = app
+	document := models.NewLegalDocument(models.LegalDocumentTerms, "v1", "Terms", "https://example.com/terms", true, nil)
+	document.Publish(time.Now())
+	return []models.LegalDocument{document}
+}
+
+func (service *mockLegalService) List(app *models.App) []models.LegalDocument {
+	service.recorder.app = app
+	return []models.LegalDocument{
+		models.NewLegalDocument(models.LegalDocumentTerms, "v2", "Terms", "https://example.com/terms", true, app),
+		models.NewLegalDocument(models.LegalDocumentTerms, "v1", "Terms", "https://example.com/terms", true, app),
+	}
+}
+
+func (service *mockLegalService) Read(uuid uuid.UUID, app *models.App) (*models.LegalDocument, error) {
+	service.recorder.app = app
+	if service.err != nil {
+		return nil, service.err
+	}
+	document := models.NewLegalDocument(models.LegalDocumentPrivacy, "v1", "Privacy", "https://example.com/privacy", true, app)
+	document.UUID = uuid
+	return &document, nil
+}
+
+func (service *mockLegalService) Create(data validators.LegalDocumentCreateData, app *models.App) (*models.LegalDocument, error) {
+	service.recorder.create = data
+	service.recorder.app = app
+	if service.err != nil {
+		return nil, service.err
+	}
+	document := models.NewLegalDocument(data.Kind, data.Version, data.Title, data.URL, data.Mandatory, app)
+	return &document, nil
+}
+
+func (service *mockLegalService) Publish(document *models.LegalDocument) error {
+	service.recorder.published = true
+	document.Publish(time.Now())
+	return nil
+}
+
+func (service *mockLegalService) Delete(document models.LegalDocument) error {
+	service.recorder.deleted = true
+	return nil
+}
+
+func (service *mockLegalService) Accept(user models.User, documents []string, audit services.AuditContext) error {
+	service.recorder.documents = documents
+	service.recorder.audit = audit
+	return service.err
+}
+
+func (service *mockLegalService) Acceptances(user models.User) []models.LegalAcceptance {
+	document := models.NewLegalDocument(models.LegalDocumentTerms, "v1", "Terms", "https://example.com/terms", true, nil)
+	acceptance := models.NewLegalAcceptance(user, document, "203.0.113.7", "Firefox")
+	acceptance.LegalDocument = document
+	return []models.LegalAcceptance{acceptance}
+}
+
+func setupLegalRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	appService services.IAppService,
+	legalService services.ILegalService,
+) *gin.Engine {
+	router := gin.Default()
+	RegisterLegalRoutes(router, authBearerMiddleware, appService, legalService)
+	return router
+}
+
+func setupAdminLegalRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	adminActionService services.IAdminActionService,
+	legalService services.ILegalService,
+) *gin.Engine {
+	router := gin.Default()
+	userService := newMockedUserService(nil, nil, nil, nil, nil)
+	RegisterAdminRoutes(
+		router, authBearerMiddleware,
+		newMockedAuthService(nil, nil, nil, nil, nil, nil), &userService,
+		newMockedOutboxService(nil),
+		adminActionService, newMockedRoleService(nil, nil),
+		newMockedSessionService(nil), newMockedAuditService(),
+		newMockedNotificationTemplateService(nil),
+		newMockedIdentityProviderService(nil), newMockedSCIMService(nil),
+		newMockedAttributeService(nil), legalService,
+		newMockedPhoneService(nil), newMockedThrottler(true),
+	)
+	return router
+}
+
+func TestCurrentLegalDocuments(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list deployment documents", func(t *testing.T) {
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		legalService := newMockedLegalService(nil)
+		router := setupLegalRouter(newMockAuthBearerMiddleware(nil), &appService, legalService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/legal-documents", nil)
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.LegalDocumentsSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Len(response.Data, 1)
+		assert.Nil(legalService.recorder.app)
+	})
+
+	t.Run("Test list documents of an app", func(t *testing.T) {
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		legalService := newMockedLegalService(nil)
+		router := setupLegalRouter(newMockAuthBearerMiddleware(nil), &appService, legalService)
+
+		clientID := uuid.Must(uuid.NewV4())
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/legal-documents?client_id="+clientID.String(), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(clientID, appService.readByClientAppRecorder.clientId)
+		assert.NotNil(legalService.recorder.app)
+	})
+
+	t.Run("Test list documents of an unknown app", func(t *testing.T) {
+		appService := newMockedAppService(nil, nil, services.AppNotFoundError{}, nil, nil)
+		router := setupLegalRouter(newMockAuthBearerMiddleware(nil), &appService, newMockedLegalService(nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", fmt.Sprintf("/legal-documents?client_id=%s", uuid.Must(uuid.NewV4())), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Code)
+	})
+}
+
+func TestMyLegalAcceptances(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list my acceptances", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		authMiddleware := newMockAuthBearerMiddleware(&user)
+		router := setupLegalRouter(authMiddleware, &appService, newMockedLegalService(nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/me/legal-acceptances", nil)
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.LegalAcceptancesSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal([]string{security.ScopeUserRead}, *authMiddleware.requestedScopes)
+		assert.Equal("v1", response.Data[0].Document.Version)
+		assert.Equal("203.0.113.7", response.Data[0].IP)
+	})
+
+	t.Run("Test accept documents", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		legalService := newMockedLegalService(nil)
+		router := setupLegalRouter(newMockAuthBearerMiddleware(&user), &appService, legalService)
+
+		document := uuid.Must(uuid.NewV4()).String()
+		payload, _ := json.Marshal(validators.LegalAcceptanceData{Documents: []string{document}})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/legal-acceptances", bytes.NewBuffer(payload))
+		request.Header.Set("User-Agent", "Firefox")
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal([]string{document}, legalService.recorder.documents)
+		assert.Equal("Firefox", legalService.recorder.audit.Client.UserAgent)
+		assert.Equal(user.Email, legalService.recorder.audit.Actor.Email)
+	})
+
+	t.Run("Test accept unknown documents", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupLegalRouter(newMockAuthBearerMiddleware(&user), &appService, newMockedLegalService(services.LegalDocumentNotFoundError{}))
+
+		payload, _ := json.Marshal(validators.LegalAcceptanceData{Documents: []string{uuid.Must(uuid.NewV4()).String()}})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/legal-acceptances", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("Test accept without documents", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		legalService := newMockedLegalService(nil)
+		router := setupLegalRouter(newMockAuthBearerMiddleware(&user), &appService, legalService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/legal-acceptances", bytes.NewBufferString(`{"documents": []}`))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Nil(legalService.recorder.documents)
+	})
+}
+
+func TestAppLegalDocuments(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test create app document", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		legalService := newMockedLegalService(nil)
+		router := setupLegalRouter(newMockAuthBearerMiddleware(&user), &appService, legalService)
+
+		payload, _ := json.Marshal(validators.LegalDocumentCreateData{
+			Kind:      models.LegalDocumentTerms,
+			Version:   "2021-10",
+			Title:     "MyApp terms",
+			URL:       "https://example.com/terms",
+			Mandatory: true,
+		})
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/legal-documents", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.LegalDocumentSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusCreated, recorder.Code)
+		assert.Equal("2021-10", response.Data.Version)
+		assert.NotNil(legalService.recorder.app)
+	})
+
+	t.Run("Test create app document with unknown kind", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		legalService := newMockedLegalService(nil)
+		router := setupLegalRouter(newMockAuthBearerMiddleware(&user), &appService, legalService)
+
+		payload, _ := json.Marshal(map[string]string{"kind": "cookies", "version": "v1", "title": "Cookies", "url": "https://example.com"})
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/legal-documents", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Equal("", legalService.recorder.create.Kind)
+	})
+
+	t.Run("Test publish app document", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		legalService := newMockedLegalService(nil)
+		router := setupLegalRouter(newMockAuthBearerMiddleware(&user), &appService, legalService)
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/legal-documents/%s/publish", uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("POST", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.LegalDocumentSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.True(legalService.recorder.published)
+		assert.NotNil(response.Data.PublishedAt)
+	})
+
+	t.Run("Test publish app document without access", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		appService.accessRole = models.MembershipMember
+		legalService := newMockedLegalService(nil)
+		router := setupLegalRouter(newMockAuthBearerMiddleware(&user), &appService, legalService)
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/legal-documents/%s/publish", uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("POST", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Code)
+		assert.False(legalService.recorder.published)
+	})
+
+	t.Run("Test delete missing app document", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		legalService := newMockedLegalService(errors.New("not found"))
+		router := setupLegalRouter(newMockAuthBearerMiddleware(&user), &appService, legalService)
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/legal-documents/%s", uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("DELETE", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Code)
+		assert.False(legalService.recorder.deleted)
+	})
+}
+
+func TestAdminLegalDocuments(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test create deployment document", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		legalService := newMockedLegalService(nil)
+		router := setupAdminLegalRouter(authMiddleware, adminActionService, legalService)
+
+		payload, _ := json.Marshal(validators.LegalDocumentCreateData{
+			Kind:    models.LegalDocumentPrivacy,
+			Version: "v2",
+			Title:   "Privacy policy",
+			URL:     "https://example.com/privacy",
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/admin/legal-documents", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusCreated, recorder.Code)
+		assert.Equal([]string{security.ScopeLegalWriteAll}, *authMiddleware.requestedScopes)
+		assert.Nil(legalService.recorder.app)
+		assert.Equal(models.AdminActionAddLegal, adminActionService.recordRecorder.action)
+		assert.Equal("privacy v2", adminActionService.recordRecorder.detail)
+	})
+
+	t.Run("Test publish deployment document", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		legalService := newMockedLegalService(nil)
+		router := setupAdminLegalRouter(newMockAuthBearerMiddleware(&staff), adminActionService, legalService)
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/admin/legal-documents/%s/publish", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("POST", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.True(legalService.recorder.published)
+		assert.Equal(models.AdminActionPublishLegal, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test list deployment documents", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		router := setupAdminLegalRouter(authMiddleware, adminActionService, newMockedLegalService(nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/legal-documents", nil)
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.LegalDocumentsSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal([]string{security.ScopeLegalReadAll}, *authMiddleware.requestedScopes)
+		assert.Len(response.Data, 2)
+		assert.Equal(models.AdminActionListLegal, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test delete unknown deployment document", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		legalService := newMockedLegalService(services.LegalDocumentNotFoundError{})
+		router := setupAdminLegalRouter(newMockAuthBearerMiddleware(&staff), adminActionService, legalService)
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/admin/legal-documents/%s", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("DELETE", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Code)
+		assert.False(legalService.recorder.deleted)
+	})
+
+	t.Run("Test list acceptances of an user", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		router := setupAdminLegalRouter(newMockAuthBearerMiddleware(&staff), adminActionService, newMockedLegalService(nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/users/4722679b-5a48-4e85-9084-605e8df610f4/legal-acceptances", nil)
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.LegalAcceptancesSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Len(response.Data, 1)
+		assert.Equal(models.AdminActionReadAcceptance, adminActionService.recordRecorder.action)
+	})
+}
+
+func TestLegalAcceptanceRequired(t *testing.T) {
+	assert := require.New(t)
+
+	pending := services.LegalAcceptanceRequiredError{Documents: []models.LegalDocument{
+		models.NewLegalDocument(models.LegalDocumentTerms, "v2", "Terms", "https://example.com/terms", true, nil),
+	}}
+
+	t.Run("Test login with pending documents", func(t *testing.T) {
+		user := tests.UserFactory()
+		authService := newMockedAuthService(&user, pending, nil, nil, nil, nil)
+		router := setupAuthRouter(authService)
+
+		document := uuid.Must(uuid.NewV4()).String()
+		payload, _ := json.Marshal(map[string]interface{}{
+			"email":              user.Email,
+			"password":           "testtesttesttest",
+			"accepted_documents": []string{document},
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.LegalAcceptanceRequiredSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusForbidden, recorder.Code)
+		assert.Equal(pending.Error(), response.Error)
+		assert.Equal("v2", response.Documents[0].Version)
+		assert.Equal([]string{document}, authService.authenticateRecorder.credentials.AcceptedDocuments)
+	})
+
+	t.Run("Test federated login with pending documents", func(t *testing.T) {
+		user := tests.UserFactory()
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		router := setupFederatedRouter(
+			authService, newMockedRoleService(nil, nil),
+			newMockedIdentityProviderService(nil), newMockedFederatedLoginService(&user, pending),
+		)
+
+		document := uuid.Must(uuid.NewV4()).String()
+		payload, _ := json.Marshal(map[string]interface{}{
+			"code":               "code",
+			"state":              "state",
+			"accepted_documents": []string{document},
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/federated/acme/callback", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.LegalAcceptanceRequiredSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusForbidden, recorder.Code)
+		assert.Equal("v2", response.Documents[0].Version)
+		assert.Nil(authService.startSessionRecorder.scopes)
+	})
+
+	t.Run("Test magic link login with pending documents", func(t *testing.T) {
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		magicLinkService := newMockedMagicLinkService(nil, pending)
+		router := setupMagicLinkRouter(authService, &userService, &appService, magicLinkService, newMockedThrottler(true))
+
+		document := uuid.Must(uuid.NewV4()).String()
+		payload, _ := json.Marshal(map[string]interface{}{
+			"code":               "code",
+			"binding":            "binding",
+			"accepted_documents": []string{document},
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/magic-link/redeem", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.LegalAcceptanceRequiredSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusForbidden, recorder.Code)
+		assert.Equal("v2", response.Documents[0].Version)
+		assert.Equal([]string{document}, magicLinkService.redeemRecorder.AcceptedDocuments)
+		assert.Nil(authService.startSessionRecorder.scopes)
+	})
+
+	t.Run("Test authorize with pending documents", func(t *testing.T) {
+		user := tests.UserFactory()
+		authService := newMockedAuthService(&user, nil, nil, nil, pending, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupOauth2Router(newMockAuthBearerMiddleware(&user), authService, &userService, &appService)
+
+		payload, _ := json.Marshal(map[string]interface{}{
+			"client_id":    uuid.Must(uuid.NewV4()),
+			"redirect_uri": "https://example.com/callback",
+			"scopes":       security.GroupUserOauth2Request,
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/oauth/authorize", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.LegalAcceptanceRequiredSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusForbidden, recorder.Code)
+		assert.Len(response.Documents, 1)
+	})
+
+	t.Run("Test login with invalid accepted documents", func(t *testing.T) {
+		user := tests.UserFactory()
+		authService := newMockedAuthService(&user, nil, nil, nil, nil, nil)
+		router := setupAuthRouter(authService)
+
+		payload, _ := json.Marshal(map[string]interface{}{
+			"email":              user.Email,
+			"password":           "testtesttesttest",
+			"accepted_documents": []string{"terms"},
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+	})
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"fmt"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+const magicLinkBindingLength = 48
+
+// Register magic link endpoints to the given router
+func RegisterMagicLinkRoutes(
+	router *gin.Engine,
+	authService services.IAuthService,
+	userService services.IUserService,
+	appService services.IAppService,
+	roleService services.IRoleService,
+	magicLinkService services.IMagicLinkService,
+	webhookService services.IWebhookService,
+	emailThrottler security.IThrottler,
+	ipThrottler security.IThrottler,
+	phoneService services.IPhoneService,
+	phoneThrottler security.IThrottler,
+) {
+	controller := MagicLinkController{
+		notifications: NotificationController{
+			emailThrottler: emailThrottler,
+			ipThrottler:    ipThrottler,
+		},
+		phones: PhoneController{
+			phoneService: phoneService,
+			throttler:    phoneThrottler,
+		},
+		authService:      authService,
+		userService:      userService,
+		appService:       appService,
+		roleService:      roleService,
+		magicLinkService: magicLinkService,
+		webhookService:   webhookService,
+		secretGenerator:  security.NewUniformSecret(),
+	}
+
+	publicRoutes := router.Group("/auth/magic-link")
+	{
+		publicRoutes.POST("", controller.RequestMagicLink)
+		publicRoutes.POST("/redeem", controller.RedeemMagicLink)
+	}
+}
+
+// Controller for /auth/magic-link endpoints
+type MagicLinkController struct {
+	notifications    NotificationController
+	phones           PhoneController
+	authService      services.IAuthService
+	userService      services.IUserService
+	appService       services.IAppService
+	roleService      services.IRoleService
+	magicLinkService services.IMagicLinkService
+	webhookService   services.IWebhookService
+	secretGenerator  security.ISecretGenerator
+}
+
+// @Summary Ask for a magic link
+// @Description Emails a single use, short lived sign-in link. The returned
+// @Description binding must be kept by the browser and sent along with the
+// @Description link code, so the link only works on this browser. The
+// @Description response is the same whether the email is registered or not.
+// @ID auth-magic-link
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param data body validators.MagicLinkRequestData true "Email and pending authorize request"
+// @Success 200 {object} serializers.MagicLinkSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 429 {object} helpers.HTTPError
+// @Router /auth/magic-link [post]
+func (controller MagicLinkController) RequestMagicLink(c *gin.Context) {
+	var input validators.MagicLinkRequestData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !controller.notifications.allowed(c, input.Email) {
+		return
+	}
+
+	binding, err := controller.secretGenerator.GenerateSecret(magicLinkBindingLength)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	user, err := controller.userService.ReadByEmail(input.Email)
+	if err == nil && user.Verified && !user.Disabled {
+		runInBackground(func() {
+			controller.magicLinkService.Send(*user, binding, input)
+		})
+	}
+
+	c.JSON(http.StatusOK, serializers.NewMagicLinkSerializer(binding))
+}
+
+// @Summary Redeem a magic link
+// @Description Logs the user in with the code of a magic link, from the
+// @Description browser which asked for it. Returns the same tokens as the
+// @Description login, or redirects to the app when the link continues an
+// @Description authorize request. Users who use their phone as second factor
+// @Description are answered with 401 and sent the code, and users who have
+// @Description not accepted the mandatory legal documents are answered with
+// @Description 403 and the pending ones. The link can be redeemed again along
+// @Description with the missing step.
+// @ID auth-magic-link-redeem
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param data body validators.MagicLinkRedeemData true "Link code and browser binding"
+// @Success 200 {object} serializers.TokensSerializer
+// @Success 302
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 401 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 429 {object} helpers.HTTPError
+// @Router /auth/magic-link/redeem [post]
+func (controller MagicLinkController) RedeemMagicLink(c *gin.Context) {
+	var input validators.MagicLinkRedeemData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	client := helpers.NewClientInfo(c)
+	user, authorize, err := controller.magicLinkService.Redeem(input, client)
+	if err != nil {
+		controller.phones.abortLogin(c, user, err)
+		return
+	}
+
+	if authorize == nil {
+		// Staff scopes are only issued through the admin login
+		scopes, _ := security.SplitStaffScopes(controller.roleService.UserScopes(*user))
+		tokens, err := controller.authService.StartSession(*user, client, scopes)
+		if err != nil {
+			helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, serializers.NewTokensSerializer(*tokens))
+		return
+	}
+
+	app, err := controller.appService.ReadByClientID(uuid.FromStringOrNil(authorize.ClientID))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	scopes := security.IntersectScopes(
+		controller.roleService.UserScopes(*user), security.GroupUserOauth2Request,
+	)
+	tokens, err := controller.authService.StartSession(*user, client, scopes)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	code, err := controller.authService.Authorize(
+		app, user, *authorize,
+		client, controller.authService.GetTokenSession(tokens.AccessToken),
+	)
+	if err != nil {
+		if abortLegalAcceptance(c, err) {
+			return
+		}
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	runInBackground(func() {
+		controller.webhookService.Dispatch(models.WebhookEventAppConnected, *user, app)
+	})
+
+	redirectUrl := fmt.Sprintf("%s?code=%s&state=%s", authorize.RedirectURI, code, authorize.State)
+	c.Redirect(http.StatusFound, redirectUrl)
+}
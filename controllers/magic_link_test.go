@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"gandalf/bindings"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type sendMagicLinkRecorder struct {
+	user    models.User
+	binding string
+	data    validators.MagicLinkRequestData
+}
+
+type mockMagicLinkService struct {
+	sendRecorder   *sendMagicLinkRecorder
+	redeemRecorder *validators.MagicLinkRedeemData
+
+	authorize   *validators.OauthAuthorizeData
+	redeemError error
+}
+
+func newMockedMagicLinkService(authorize *validators.OauthAuthorizeData, redeemError error) *mockMagicLinkService {
+	return &mockMagicLinkService{
+		sendRecorder:   new(sendMagicLinkRecorder),
+		redeemRecorder: new(validators.MagicLinkRedeemData),
+		authorize:      authorize,
+		redeemError:    redeemError,
+	}
+}
+
+func (service *mockMagicLinkService) Send(user models.User, binding string, data validators.MagicLinkRequestData) error {
+	*service.sendRecorder = sendMagicLinkRecorder{user, binding, data}
+	return nil
+}
+
+func (service *mockMagicLinkService) Redeem(data validators.MagicLinkRedeemData, client helpers.ClientInfo) (*models.User, *validators.OauthAuthorizeData, error) {
+	*service.redeemRecorder = data
+	if service.redeemError != nil {
+		return &models.User{}, nil, service.redeemError
+	}
+	return &models.User{}, service.authorize, nil
+}
+
+func setupMagicLinkRouter(
+	authService services.IAuthService,
+	userService services.IUserService,
+	appService services.IAppService,
+	magicLinkService services.IMagicLinkService,
+	throttler security.IThrottler,
+) *gin.Engine {
+	router := gin.Default()
+	RegisterMagicLinkRoutes(
+		router, authService,
+		userService, appService,
+		newMockedRoleService([]string{security.ScopeUserRead, security.ScopeUserReadAll}, nil),
+		magicLinkService, newMockedWebhookService(nil, nil),
+		throttler, newMockedThrottler(true),
+		newMockedPhoneService(nil), newMockedThrottler(true),
+	)
+	return router
+}
+
+func TestRequestMagicLink(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test request returns the browser binding", func(t *testing.T) {
+		userService := newMockedUserService(nil, errors.New("not found"), nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		magicLinkService := newMockedMagicLinkService(nil, nil)
+		router := setupMagicLinkRouter(
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, &appService, magicLinkService, newMockedThrottler(true),
+		)
+
+		payload, _ := json.Marshal(map[string]string{"email": "johndoe@example.com"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/magic-link", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.MagicLinkSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal(magicLinkBindingLength, len(response.Binding))
+		assert.Equal("", magicLinkService.sendRecorder.binding)
+	})
+
+	t.Run("Test request is not sent to unverified users", func(t *testing.T) {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		magicLinkService := newMockedMagicLinkService(nil, nil)
+		router := setupMagicLinkRouter(
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, &appService, magicLinkService, newMockedThrottler(true),
+		)
+
+		payload, _ := json.Marshal(map[string]string{"email": "johndoe@example.com"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/magic-link", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal("johndoe@example.com", userService.readByEmailRecorder.email)
+		assert.Equal("", magicLinkService.sendRecorder.binding)
+	})
+
+	t.Run("Test request throttled", func(t *testing.T) {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupMagicLinkRouter(
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, &appService, newMockedMagicLinkService(nil, nil), newMockedThrottler(false),
+		)
+
+		payload, _ := json.Marshal(map[string]string{"email": "johndoe@example.com"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/magic-link", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusTooManyRequests, recorder.Result().StatusCode)
+		assert.Equal("", userService.readByEmailRecorder.email)
+	})
+
+	t.Run("Test request wrong payload", func(t *testing.T) {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupMagicLinkRouter(
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, &appService, newMockedMagicLinkService(nil, nil), newMockedThrottler(true),
+		)
+
+		payload, _ := json.Marshal(map[string]string{"email": "johndoe"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/magic-link", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+}
+
+func TestRedeemMagicLink(t *testing.T) {
+	assert := require.New(t)
+	payload, _ := json.Marshal(map[string]string{"code": "code", "binding": "binding"})
+
+	t.Run("Test redeem logs the user in", func(t *testing.T) {
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		magicLinkService := newMockedMagicLinkService(nil, nil)
+		router := setupMagicLinkRouter(authService, &userService, &appService, magicLinkService, newMockedThrottler(true))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/magic-link/redeem", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal("binding", magicLinkService.redeemRecorder.Binding)
+		assert.Equal([]string{security.ScopeUserRead}, authService.startSessionRecorder.scopes)
+	})
+
+	t.Run("Test redeem continues the authorize request", func(t *testing.T) {
+		authorize := &validators.OauthAuthorizeData{
+			ClientID:    "4722679b-5a48-4e85-9084-605e8df610f4",
+			RedirectURI: "https://app.test/callback",
+			Scopes:      []bindings.Scope{security.ScopeUserRead},
+			State:       "state",
+		}
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupMagicLinkRouter(
+			authService, &userService, &appService,
+			newMockedMagicLinkService(authorize, nil), newMockedThrottler(true),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/magic-link/redeem", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusFound, recorder.Result().StatusCode)
+		assert.Contains(recorder.Header().Get("Location"), "https://app.test/callback?code=")
+		assert.Contains(recorder.Header().Get("Location"), "&state=state")
+	})
+
+	t.Run("Test redeem from another browser", func(t *testing.T) {
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupMagicLinkRouter(
+			authService, &userService, &appService,
+			newMockedMagicLinkService(nil, services.MagicLinkBindingError{}), newMockedThrottler(true),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/magic-link/redeem", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+		assert.Nil(authService.startSessionRecorder.scopes)
+	})
+
+	t.Run("Test redeem asks for the phone code", func(t *testing.T) {
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := gin.Default()
+		RegisterMagicLinkRoutes(
+			router, authService,
+			&userService, &appService,
+			newMockedRoleService([]string{security.ScopeUserRead}, nil),
+			newMockedMagicLinkService(nil, services.PhoneCodeRequiredError{}), newMockedWebhookService(nil, nil),
+			newMockedThrottler(true), newMockedThrottler(true),
+			phoneService, newMockedThrottler(true),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/magic-link/redeem", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusUnauthorized, recorder.Result().StatusCode)
+		assert.Nil(authService.startSessionRecorder.scopes)
+		assert.Equal(models.PhoneCodePurposeMFA, phoneService.sendRecorder.purpose)
+	})
+}
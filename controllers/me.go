@@ -3,6 +3,7 @@ package controllers
 import (
 	"gandalf/helpers"
 	"gandalf/middlewares"
+	"gandalf/models"
 	"gandalf/security"
 	"gandalf/serializers"
 	"gandalf/services"
@@ -10,6 +11,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
 )
 
 // Register me endpoints to the given router
@@ -19,22 +21,27 @@ func RegisterMeRoutes(
 	authService services.IAuthService,
 	userService services.IUserService,
 	appService services.IAppService,
-	pelipperService services.IPelipperService,
+	tokenService services.IOneTimeTokenService,
+	sessionService services.ISessionService,
+	auditService services.IAuditService,
+	webhookService services.IWebhookService,
 ) {
 	controller := MeController{
-		authService:     authService,
-		userService:     userService,
-		pelipperService: pelipperService,
-		authMiddleware:  authBearerMiddleware,
-		appService:      appService,
+		authService:    authService,
+		sessionService: sessionService,
+		auditService:   auditService,
+		webhookService: webhookService,
+		userService:    userService,
+		tokenService:   tokenService,
+		authMiddleware: authBearerMiddleware,
+		appService:     appService,
 	}
 
-	verifyRoutes := router.Group("/me")
+	publicRoutes := router.Group("/me")
 	{
-		scopes := []string{security.ScopeUserVerify}
-		verifyRoutes.Use(authBearerMiddleware.HasScopes(scopes))
-
-		verifyRoutes.POST("/verify", controller.VerificateMe)
+		publicRoutes.POST("/verify", controller.VerificateMe)
+		publicRoutes.POST("/reset-password", controller.ResetMyPassword)
+		publicRoutes.POST("/lock", controller.LockMe)
 	}
 
 	readRoutes := router.Group("/me")
@@ -43,6 +50,8 @@ func RegisterMeRoutes(
 		readRoutes.Use(authBearerMiddleware.HasScopes(scopes))
 
 		readRoutes.GET("", controller.ReadMe)
+		readRoutes.GET("/sessions", controller.GetMySessions)
+		readRoutes.GET("/activity", controller.GetMyActivity)
 	}
 
 	updateRoutes := router.Group("/me")
@@ -50,6 +59,9 @@ func RegisterMeRoutes(
 		scopes := []string{security.ScopeUserWrite}
 		updateRoutes.Use(authBearerMiddleware.HasScopes(scopes))
 		updateRoutes.PATCH("", controller.UpdateMe)
+		updateRoutes.DELETE("/sessions", controller.RevokeMyOtherSessions)
+		updateRoutes.DELETE("/sessions/:uuid", controller.RevokeMySession)
+		updateRoutes.DELETE("/connected-apps/:uuid", controller.DisconnectMyApp)
 	}
 
 	deleteRoutes := router.Group("/me")
@@ -59,14 +71,6 @@ func RegisterMeRoutes(
 		deleteRoutes.DELETE("", controller.DeleteMe)
 	}
 
-	changePasswordRoutes := router.Group("/me")
-	{
-		scopes := []string{security.ScopeUserChangePassword}
-		changePasswordRoutes.Use(authBearerMiddleware.HasScopes(scopes))
-
-		changePasswordRoutes.POST("/reset-password", controller.ResetMyPassword)
-	}
-
 	readAppsRoutes := router.Group("/me")
 	{
 		scopes := []string{security.ScopeAppRead}
@@ -79,11 +83,24 @@ func RegisterMeRoutes(
 
 // Controller for /me endpoints
 type MeController struct {
-	authService     services.IAuthService
-	userService     services.IUserService
-	pelipperService services.IPelipperService
-	appService      services.IAppService
-	authMiddleware  middlewares.IAuthBearerMiddleware
+	authService    services.IAuthService
+	userService    services.IUserService
+	tokenService   services.IOneTimeTokenService
+	appService     services.IAppService
+	sessionService services.ISessionService
+	auditService   services.IAuditService
+	webhookService services.IWebhookService
+	authMiddleware middlewares.IAuthBearerMiddleware
+}
+
+// Aborts the request with 403 when it is performed by staff acting as the
+// user, for the actions only the user himself can take
+func abortImpersonated(c *gin.Context, authMiddleware middlewares.IAuthBearerMiddleware) bool {
+	if authMiddleware.GetImpersonator(c) == nil {
+		return false
+	}
+	helpers.AbortWithStatus(c, http.StatusForbidden, ImpersonationForbiddenError{})
+	return true
 }
 
 // @Summary Get me
@@ -103,7 +120,10 @@ func (controller MeController) ReadMe(c *gin.Context) {
 }
 
 // @Summary Update me
-// @Description update me
+// @Description update me. Neither the password nor the phone can be changed
+// @Description by staff acting as the user, and the phone can only be changed
+// @Description along with a confirmation code sent to the verified one or the
+// @Description password.
 // @ID me-update
 // @Tags Me
 // @Accept json
@@ -122,10 +142,13 @@ func (controller MeController) UpdateMe(c *gin.Context) {
 		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
 		return
 	}
+	if (input.Password != "" || input.Phone != "") && abortImpersonated(c, controller.authMiddleware) {
+		return
+	}
 
 	user, err := controller.userService.Update(user.UUID, input)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		abortPhoneChange(c, err)
 		return
 	}
 
@@ -133,7 +156,8 @@ func (controller MeController) UpdateMe(c *gin.Context) {
 }
 
 // @Summary Delete me
-// @Description deletes the user who perform the request
+// @Description deletes the user who perform the request. He can restore his
+// @Description account by logging in until the deletion grace period is over.
 // @ID me-delete
 // @Tags Me
 // @Accept json
@@ -145,7 +169,8 @@ func (controller MeController) UpdateMe(c *gin.Context) {
 // @Router /me [delete]
 func (controller MeController) DeleteMe(c *gin.Context) {
 	user := controller.authMiddleware.GetAuthorizedUser(c)
-	if err := controller.userService.Delete(user.UUID); err != nil {
+	audit := services.AuditContext{Actor: user, Client: helpers.NewClientInfo(c)}
+	if err := controller.userService.Delete(user.UUID, audit, nil); err != nil {
 		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
 		return
 	}
@@ -153,31 +178,46 @@ func (controller MeController) DeleteMe(c *gin.Context) {
 }
 
 // @Summary Verify me
-// @Description Verify me
+// @Description Verify me with the code sent by email
 // @ID me-verify
 // @Tags Me
 // @Accept json
 // @Produce json
+// @Param data body validators.UserVerifyData true "Verification code"
 // @Success 204
 // @Failure 400 {object} helpers.HTTPError
 // @Failure 403 {object} helpers.HTTPError
-// @Security OAuth2AccessCode[user:me:verify]
 // @Router /me/verify [post]
 func (controller MeController) VerificateMe(c *gin.Context) {
-	controller.userService.Verificate(controller.authMiddleware.GetAuthorizedUser(c))
+	var input validators.UserVerifyData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := controller.tokenService.Consume(input.Code, models.TokenPurposeVerifyUser)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusForbidden, err)
+		return
+	}
+
+	if err := controller.userService.Verificate(user, nil); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
 	c.JSON(http.StatusNoContent, nil)
 }
 
 // @Summary Reset my password
-// @Description Reset my password
+// @Description Reset my password with the code sent by email
 // @ID me-reset-password
 // @Tags Me
 // @Accept json
 // @Produce json
+// @Param data body validators.UserResetPasswordData true "Reset code and new password"
 // @Success 204
 // @Failure 400 {object} helpers.HTTPError
 // @Failure 403 {object} helpers.HTTPError
-// @Security OAuth2AccessCode[user:me:change-password]
 // @Router /me/reset-password [post]
 func (controller MeController) ResetMyPassword(c *gin.Context) {
 	var input validators.UserResetPasswordData
@@ -185,7 +225,50 @@ func (controller MeController) ResetMyPassword(c *gin.Context) {
 		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
 		return
 	}
-	controller.userService.ResetPassword(controller.authMiddleware.GetAuthorizedUser(c), input.Password)
+
+	user, err := controller.tokenService.Consume(input.Code, models.TokenPurposeResetPassword)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusForbidden, err)
+		return
+	}
+
+	if err := controller.userService.ResetPassword(user, input.Password, helpers.NewClientInfo(c)); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Lock me
+// @Description Lock my account with the code sent with a security alert, when
+// @Description the alerted change was not made by me. The account is disabled
+// @Description and all its sessions are revoked.
+// @ID me-lock
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param data body validators.UserLockData true "Lock code"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Router /me/lock [post]
+func (controller MeController) LockMe(c *gin.Context) {
+	var input validators.UserLockData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := controller.tokenService.Consume(input.Code, models.TokenPurposeLockAccount)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusForbidden, err)
+		return
+	}
+
+	if err := controller.userService.Lock(user, helpers.NewClientInfo(c)); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
 	c.JSON(http.StatusNoContent, nil)
 }
 
@@ -242,3 +325,158 @@ func (controller MeController) GetMyConnectedApps(c *gin.Context) {
 
 	c.JSON(http.StatusOK, serializers.NewPaginatedAppsPublicSerializer(apps, cursor))
 }
+
+// @Summary Disconnect a connected app
+// @Description Disconnects an app from the user, so it is no longer among his
+// @Description connected apps and his sessions on the app are revoked
+// @ID me-connected-apps-disconnect
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:write]
+// @Router /me/connected-apps/{uuid} [delete]
+func (controller MeController) DisconnectMyApp(c *gin.Context) {
+	var input validators.AppReadData
+	if err := c.ShouldBindUri(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	app, err := controller.appService.Read(uuid.FromStringOrNil(input.UUID))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	audit := services.AuditContext{Actor: user, Client: helpers.NewClientInfo(c)}
+	if err := controller.appService.Disconnect(*app, *user, audit); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	runInBackground(func() {
+		controller.webhookService.Dispatch(models.WebhookEventAppDisconnected, *user, app)
+	})
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Get user's sessions
+// @Description Get the active sessions of the user, the most recently seen
+// @Description first. The session of the request is marked as current.
+// @ID me-sessions
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param page query int false "cursor's page"
+// @Param limit query int false "cursor's limit"
+// @Success 200 {object} serializers.PaginatedSessionsSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:read]
+// @Router /me/sessions [get]
+func (controller MeController) GetMySessions(c *gin.Context) {
+	var input validators.PaginationQuery
+	if err := c.ShouldBindQuery(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	current := controller.authMiddleware.GetAuthorizedSession(c)
+	cursor := helpers.NewCursor(input.Page, input.PageSize)
+	sessions := controller.sessionService.List(*user, &cursor)
+
+	c.JSON(http.StatusOK, serializers.NewPaginatedSessionsSerializer(sessions, current, cursor))
+}
+
+// @Summary Get user's security activity
+// @Description Get the security events performed by or affecting the user,
+// @Description the most recent first
+// @ID me-activity
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param page query int false "cursor's page"
+// @Param limit query int false "cursor's limit"
+// @Success 200 {object} serializers.PaginatedAuditEventsSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:read]
+// @Router /me/activity [get]
+func (controller MeController) GetMyActivity(c *gin.Context) {
+	var input validators.PaginationQuery
+	if err := c.ShouldBindQuery(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	cursor := helpers.NewCursor(input.Page, input.PageSize)
+	events := controller.auditService.ListForUser(*user, &cursor)
+
+	c.JSON(http.StatusOK, serializers.NewPaginatedAuditEventsSerializer(events, cursor))
+}
+
+// @Summary Revoke a session
+// @Description Signs the user out of the given session
+// @ID me-sessions-revoke
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Session uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:write]
+// @Router /me/sessions/{uuid} [delete]
+func (controller MeController) RevokeMySession(c *gin.Context) {
+	var input validators.SessionReadData
+	if err := c.ShouldBindUri(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	session, err := controller.sessionService.Read(*user, uuid.FromStringOrNil(input.UUID))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return
+	}
+
+	if err := controller.sessionService.Revoke(*session); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Revoke other sessions
+// @Description Signs the user out everywhere but the session of the request
+// @ID me-sessions-revoke-others
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:write]
+// @Router /me/sessions [delete]
+func (controller MeController) RevokeMyOtherSessions(c *gin.Context) {
+	if abortImpersonated(c, controller.authMiddleware) {
+		return
+	}
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	current := controller.authMiddleware.GetAuthorizedSession(c)
+
+	if err := controller.sessionService.RevokeAll(*user, current); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -5,32 +5,143 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"gandalf/helpers"
 	"gandalf/middlewares"
+	"gandalf/models"
 	"gandalf/security"
 	"gandalf/serializers"
 	"gandalf/services"
 	"gandalf/tests"
+	"gandalf/validators"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/require"
 	"syreclabs.com/go/faker"
 )
 
+type issueRecorder struct {
+	user    models.User
+	purpose string
+}
+
+type consumeRecorder struct {
+	secret  string
+	purpose string
+}
+
+type mockOneTimeTokenService struct {
+	issueRecorder   *issueRecorder
+	consumeRecorder *consumeRecorder
+
+	issueError   error
+	consumeError error
+}
+
+func newMockedOneTimeTokenService(issueError error, consumeError error) *mockOneTimeTokenService {
+	return &mockOneTimeTokenService{
+		issueRecorder:   new(issueRecorder),
+		consumeRecorder: new(consumeRecorder),
+		issueError:      issueError,
+		consumeError:    consumeError,
+	}
+}
+
+func (service *mockOneTimeTokenService) Issue(user models.User, purpose string) (string, error) {
+	*service.issueRecorder = issueRecorder{user, purpose}
+	return faker.RandomString(48), service.issueError
+}
+
+func (service *mockOneTimeTokenService) Consume(secret string, purpose string) (*models.User, error) {
+	*service.consumeRecorder = consumeRecorder{secret, purpose}
+	if service.consumeError != nil {
+		return nil, service.consumeError
+	}
+	return &models.User{}, nil
+}
+
+type revokeAllRecorder struct {
+	user   models.User
+	except uuid.UUID
+}
+
+type mockSessionService struct {
+	revokeRecorder    *models.Session
+	revokeAllRecorder *revokeAllRecorder
+
+	readError error
+}
+
+func newMockedSessionService(readError error) *mockSessionService {
+	return &mockSessionService{
+		revokeRecorder:    new(models.Session),
+		revokeAllRecorder: new(revokeAllRecorder),
+		readError:         readError,
+	}
+}
+
+func (service *mockSessionService) List(user models.User, cursor *helpers.Cursor) []models.Session {
+	return []models.Session{models.NewSession(user, nil, "", "")}
+}
+
+func (service *mockSessionService) Read(user models.User, uuid uuid.UUID) (*models.Session, error) {
+	session := models.NewSession(user, nil, "", "")
+	session.UUID = uuid
+	return &session, service.readError
+}
+
+func (service *mockSessionService) Revoke(session models.Session) error {
+	*service.revokeRecorder = session
+	return nil
+}
+
+func (service *mockSessionService) RevokeAll(user models.User, except uuid.UUID) error {
+	*service.revokeAllRecorder = revokeAllRecorder{user, except}
+	return nil
+}
+
+type listAuditRecorder struct {
+	filter validators.AuditEventListQuery
+	user   models.User
+}
+
+type mockAuditService struct {
+	listRecorder *listAuditRecorder
+}
+
+func newMockedAuditService() *mockAuditService {
+	return &mockAuditService{listRecorder: new(listAuditRecorder)}
+}
+
+func (service *mockAuditService) List(filter validators.AuditEventListQuery, cursor *helpers.Cursor) []models.AuditEvent {
+	service.listRecorder.filter = filter
+	return []models.AuditEvent{models.NewAuditEvent(models.AuditActionLogin, models.AuditOutcomeSuccess, nil, nil, "", "", nil)}
+}
+
+func (service *mockAuditService) ListForUser(user models.User, cursor *helpers.Cursor) []models.AuditEvent {
+	service.listRecorder.user = user
+	return []models.AuditEvent{models.NewAuditEvent(models.AuditActionLogin, models.AuditOutcomeSuccess, &user, &user, "", "", nil)}
+}
+
 func setupMeRouter(
 	authBearerMiddleware middlewares.IAuthBearerMiddleware,
 	authService services.IAuthService,
 	userService services.IUserService,
 	appService services.IAppService,
-	pelipperService services.IPelipperService,
+	tokenService services.IOneTimeTokenService,
+	sessionService services.ISessionService,
+	auditService services.IAuditService,
+	webhookService services.IWebhookService,
 ) *gin.Engine {
 	router := gin.Default()
 	RegisterMeRoutes(
 		router, authBearerMiddleware,
 		authService, userService,
-		appService, pelipperService,
+		appService, tokenService,
+		sessionService, auditService, webhookService,
 	)
 	return router
 }
@@ -48,7 +159,10 @@ func TestReadMe(t *testing.T) {
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
 			&userService,
 			&appService,
-			newPelipperServiceMock(),
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 		var response gin.H
 
@@ -75,7 +189,10 @@ func TestUpdateMe(t *testing.T) {
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
 			&userService,
 			&appService,
-			newPelipperServiceMock(),
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 		var response gin.H
 
@@ -111,7 +228,10 @@ func TestUpdateMe(t *testing.T) {
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
 			&userService,
 			&appService,
-			newPelipperServiceMock(),
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 		var response gin.H
 
@@ -141,7 +261,10 @@ func TestUpdateMe(t *testing.T) {
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
 			&userService,
 			&appService,
-			newPelipperServiceMock(),
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 		var response gin.H
 
@@ -163,6 +286,36 @@ func TestUpdateMe(t *testing.T) {
 		json.Unmarshal(recorder.Body.Bytes(), &response)
 		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
 	})
+
+	t.Run("Test update my password while impersonated", func(t *testing.T) {
+		authorizedUser := tests.UserFactory()
+		staff := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		authMiddleware := newMockAuthBearerMiddleware(&authorizedUser)
+		authMiddleware.impersonator = &staff
+		router := setupMeRouter(
+			authMiddleware,
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService,
+			&appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
+		)
+
+		payload, _ := json.Marshal(map[string]string{
+			"password": faker.Internet().Password(10, 14),
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PATCH", "/me", bytes.NewBuffer(payload))
+
+		router.ServeHTTP(recorder, request)
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+		assert.Empty(userService.updateRecorder.userData.Password)
+	})
 }
 
 func TestDeleteMe(t *testing.T) {
@@ -176,7 +329,11 @@ func TestDeleteMe(t *testing.T) {
 		router := setupMeRouter(
 			authMiddleware,
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, &appService, newPelipperServiceMock(),
+			&userService, &appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 		var response gin.H
 
@@ -198,7 +355,11 @@ func TestDeleteMe(t *testing.T) {
 		router := setupMeRouter(
 			authMiddleware,
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, &appService, newPelipperServiceMock(),
+			&userService, &appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 		var response gin.H
 
@@ -216,26 +377,78 @@ func TestVerificateMe(t *testing.T) {
 	assert := require.New(t)
 
 	t.Run("Test verificate me successfully", func(t *testing.T) {
-		authorizedUser := tests.UserFactory()
-		expectedScopes := []string{security.ScopeUserVerify}
+		code := faker.RandomString(48)
+		payload, _ := json.Marshal(map[string]string{
+			"code": code,
+		})
 		appService := newMockedAppService(nil, nil, nil, nil, nil)
 		userService := newMockedUserService(nil, nil, nil, nil, nil)
-		authMiddleware := newMockAuthBearerMiddleware(&authorizedUser)
+		tokenService := newMockedOneTimeTokenService(nil, nil)
+		authMiddleware := newMockAuthBearerMiddleware(nil)
 		router := setupMeRouter(
 			authMiddleware,
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, &appService, newPelipperServiceMock(),
+			&userService, &appService,
+			tokenService,
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 
 		recorder := httptest.NewRecorder()
-		request, _ := http.NewRequest("POST", "/me/verify", bytes.NewBuffer([]byte{}))
+		request, _ := http.NewRequest("POST", "/me/verify", bytes.NewBuffer(payload))
 		router.ServeHTTP(recorder, request)
 
-		assert.True(authMiddleware.hasScopesCalled)
-		assert.True(authMiddleware.getAuthorizedUserCalled)
+		assert.False(authMiddleware.hasScopesCalled)
 		assert.True(userService.verificateRecorder.called)
+		assert.Equal(tokenService.consumeRecorder.secret, code)
+		assert.Equal(tokenService.consumeRecorder.purpose, models.TokenPurposeVerifyUser)
 		assert.Equal(recorder.Result().StatusCode, http.StatusNoContent)
-		assert.Equal(authMiddleware.requestedScopes, &expectedScopes)
+	})
+
+	t.Run("Test verificate me wrong payload", func(t *testing.T) {
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupMeRouter(
+			newMockAuthBearerMiddleware(nil),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, &appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/verify", bytes.NewBuffer([]byte{}))
+		router.ServeHTTP(recorder, request)
+
+		assert.False(userService.verificateRecorder.called)
+		assert.Equal(recorder.Result().StatusCode, http.StatusBadRequest)
+	})
+
+	t.Run("Test verificate me invalid code", func(t *testing.T) {
+		payload, _ := json.Marshal(map[string]string{
+			"code": faker.RandomString(48),
+		})
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupMeRouter(
+			newMockAuthBearerMiddleware(nil),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, &appService,
+			newMockedOneTimeTokenService(nil, errors.New("invalid")),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/verify", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.False(userService.verificateRecorder.called)
+		assert.Equal(recorder.Result().StatusCode, http.StatusForbidden)
 	})
 }
 
@@ -243,55 +456,162 @@ func TestResetMyPassword(t *testing.T) {
 	assert := require.New(t)
 
 	t.Run("Test reset my password successfully", func(t *testing.T) {
+		code := faker.RandomString(48)
 		password := "testestestestest"
 		appService := newMockedAppService(nil, nil, nil, nil, nil)
-		authorizedUser := tests.UserFactory()
 		payload, _ := json.Marshal(map[string]string{
+			"code":     code,
 			"password": password,
 		})
-		expectedScopes := []string{security.ScopeUserChangePassword}
 		userService := newMockedUserService(nil, nil, nil, nil, nil)
-		authMiddleware := newMockAuthBearerMiddleware(&authorizedUser)
+		tokenService := newMockedOneTimeTokenService(nil, nil)
+		authMiddleware := newMockAuthBearerMiddleware(nil)
 		router := setupMeRouter(
 			authMiddleware,
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, &appService, newPelipperServiceMock(),
+			&userService, &appService,
+			tokenService,
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 
 		recorder := httptest.NewRecorder()
 		request, _ := http.NewRequest("POST", "/me/reset-password", bytes.NewBuffer(payload))
 		router.ServeHTTP(recorder, request)
 
-		assert.True(authMiddleware.hasScopesCalled)
-		assert.True(authMiddleware.getAuthorizedUserCalled)
+		assert.False(authMiddleware.hasScopesCalled)
 		assert.Equal(userService.resetPasswordRecorder.password, password)
+		assert.Equal(tokenService.consumeRecorder.secret, code)
+		assert.Equal(tokenService.consumeRecorder.purpose, models.TokenPurposeResetPassword)
 		assert.Equal(recorder.Result().StatusCode, http.StatusNoContent)
-		assert.Equal(authMiddleware.requestedScopes, &expectedScopes)
 	})
 
 	t.Run("Test reset my password wrong payload", func(t *testing.T) {
 		password := "testestestestest"
-		authorizedUser := tests.UserFactory()
 		appService := newMockedAppService(nil, nil, nil, nil, nil)
 		payload, _ := json.Marshal(map[string]string{
 			"wrong": password,
 		})
 		userService := newMockedUserService(nil, nil, nil, nil, nil)
-		authMiddleware := newMockAuthBearerMiddleware(&authorizedUser)
 		router := setupMeRouter(
-			authMiddleware,
+			newMockAuthBearerMiddleware(nil),
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
 			&userService,
 			&appService,
-			newPelipperServiceMock(),
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/reset-password", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(recorder.Result().StatusCode, http.StatusBadRequest)
+	})
+
+	t.Run("Test reset my password invalid code", func(t *testing.T) {
+		payload, _ := json.Marshal(map[string]string{
+			"code":     faker.RandomString(48),
+			"password": "testestestestest",
+		})
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupMeRouter(
+			newMockAuthBearerMiddleware(nil),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, &appService,
+			newMockedOneTimeTokenService(nil, errors.New("invalid")),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 
 		recorder := httptest.NewRecorder()
 		request, _ := http.NewRequest("POST", "/me/reset-password", bytes.NewBuffer(payload))
 		router.ServeHTTP(recorder, request)
 
+		assert.Equal(userService.resetPasswordRecorder.password, "")
+		assert.Equal(recorder.Result().StatusCode, http.StatusForbidden)
+	})
+}
+
+func TestLockMe(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test lock me successfully", func(t *testing.T) {
+		code := faker.RandomString(48)
+		payload, _ := json.Marshal(map[string]string{"code": code})
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		tokenService := newMockedOneTimeTokenService(nil, nil)
+		authMiddleware := newMockAuthBearerMiddleware(nil)
+		router := setupMeRouter(
+			authMiddleware,
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, &appService,
+			tokenService,
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/lock", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.False(authMiddleware.hasScopesCalled)
+		assert.True(userService.lockRecorder.called)
+		assert.Equal(tokenService.consumeRecorder.secret, code)
+		assert.Equal(tokenService.consumeRecorder.purpose, models.TokenPurposeLockAccount)
+		assert.Equal(recorder.Result().StatusCode, http.StatusNoContent)
+	})
+
+	t.Run("Test lock me wrong payload", func(t *testing.T) {
+		payload, _ := json.Marshal(map[string]string{"wrong": "code"})
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupMeRouter(
+			newMockAuthBearerMiddleware(nil),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, &appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/lock", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.False(userService.lockRecorder.called)
 		assert.Equal(recorder.Result().StatusCode, http.StatusBadRequest)
 	})
+
+	t.Run("Test lock me invalid code", func(t *testing.T) {
+		payload, _ := json.Marshal(map[string]string{"code": faker.RandomString(48)})
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupMeRouter(
+			newMockAuthBearerMiddleware(nil),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, &appService,
+			newMockedOneTimeTokenService(nil, errors.New("invalid")),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/lock", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.False(userService.lockRecorder.called)
+		assert.Equal(recorder.Result().StatusCode, http.StatusForbidden)
+	})
 }
 
 func TestGetMyApps(t *testing.T) {
@@ -305,7 +625,11 @@ func TestGetMyApps(t *testing.T) {
 		router := setupMeRouter(
 			authMiddleware,
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, &appService, newPelipperServiceMock(),
+			&userService, &appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 
 		var response serializers.PaginatedAppsSerializer
@@ -329,7 +653,11 @@ func TestGetMyApps(t *testing.T) {
 		router := setupMeRouter(
 			authMiddleware,
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, &appService, newPelipperServiceMock(),
+			&userService, &appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 
 		var response serializers.PaginatedAppsSerializer
@@ -356,7 +684,11 @@ func TestGetMyConnectedApps(t *testing.T) {
 		router := setupMeRouter(
 			authMiddleware,
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, &appService, newPelipperServiceMock(),
+			&userService, &appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 
 		var response serializers.PaginatedAppsPublicSerializer
@@ -380,7 +712,11 @@ func TestGetMyConnectedApps(t *testing.T) {
 		router := setupMeRouter(
 			authMiddleware,
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, &appService, newPelipperServiceMock(),
+			&userService, &appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
 		)
 
 		var response serializers.PaginatedAppsSerializer
@@ -395,3 +731,273 @@ func TestGetMyConnectedApps(t *testing.T) {
 		assert.Equal(recorder.Result().StatusCode, http.StatusBadRequest)
 	})
 }
+
+func TestMeSessions(t *testing.T) {
+	assert := require.New(t)
+
+	setup := func(user *models.User, sessionService *mockSessionService) (*gin.Engine, *mockAuthBearerMiddleware) {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		authMiddleware := newMockAuthBearerMiddleware(user)
+		router := setupMeRouter(
+			authMiddleware,
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService,
+			&appService,
+			newMockedOneTimeTokenService(nil, nil),
+			sessionService,
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
+		)
+		return router, authMiddleware
+	}
+
+	t.Run("Test list my sessions", func(t *testing.T) {
+		user := tests.UserFactory()
+		router, authMiddleware := setup(&user, newMockedSessionService(nil))
+		var response gin.H
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/me/sessions", nil)
+		router.ServeHTTP(recorder, request)
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeUserRead}, *authMiddleware.requestedScopes)
+		assert.Equal(1, len(response["data"].([]interface{})))
+	})
+
+	t.Run("Test revoke my session", func(t *testing.T) {
+		user := tests.UserFactory()
+		sessionService := newMockedSessionService(nil)
+		router, _ := setup(&user, sessionService)
+		session, _ := uuid.NewV4()
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", fmt.Sprintf("/me/sessions/%s", session), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal(session, sessionService.revokeRecorder.UUID)
+	})
+
+	t.Run("Test revoke my session not found", func(t *testing.T) {
+		user := tests.UserFactory()
+		router, _ := setup(&user, newMockedSessionService(services.SessionNotFoundError{}))
+		session, _ := uuid.NewV4()
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", fmt.Sprintf("/me/sessions/%s", session), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test revoke my other sessions", func(t *testing.T) {
+		user := tests.UserFactory()
+		sessionService := newMockedSessionService(nil)
+		router, authMiddleware := setup(&user, sessionService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", "/me/sessions", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal(authMiddleware.authorizedSession, sessionService.revokeAllRecorder.except)
+		assert.Equal(user.Email, sessionService.revokeAllRecorder.user.Email)
+	})
+}
+
+func TestMeActivity(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list my activity", func(t *testing.T) {
+		user := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		authMiddleware := newMockAuthBearerMiddleware(&user)
+		auditService := newMockedAuditService()
+		router := setupMeRouter(
+			authMiddleware,
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService,
+			&appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			auditService,
+			newMockedWebhookService(nil, nil),
+		)
+		var response gin.H
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/me/activity", nil)
+		router.ServeHTTP(recorder, request)
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeUserRead}, *authMiddleware.requestedScopes)
+		assert.Equal(user.Email, auditService.listRecorder.user.Email)
+		assert.Equal("audit-event", response["type"])
+		assert.Equal(models.AuditActionLogin, response["data"].([]interface{})[0].(map[string]interface{})["action"])
+	})
+
+	t.Run("Test list my activity bad request", func(t *testing.T) {
+		user := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupMeRouter(
+			newMockAuthBearerMiddleware(&user),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService,
+			&appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/me/activity?limit=500", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+}
+
+func TestDisconnectMyApp(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test disconnect my app successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		authMiddleware := newMockAuthBearerMiddleware(&user)
+		webhookService := newMockedWebhookService(nil, nil)
+		router := setupMeRouter(
+			authMiddleware,
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService,
+			&appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			webhookService,
+		)
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/me/connected-apps/%s", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("DELETE", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeUserWrite}, *authMiddleware.requestedScopes)
+		assert.Equal(user.Email, appService.disconnectAppRecorder.user.Email)
+		assert.Equal(models.WebhookEventAppDisconnected, webhookService.dispatchRecorder.event)
+		assert.NotNil(webhookService.dispatchRecorder.app)
+	})
+
+	t.Run("Test disconnect my app not found", func(t *testing.T) {
+		user := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, errors.New("not found"), nil, nil, nil)
+		webhookService := newMockedWebhookService(nil, nil)
+		router := setupMeRouter(
+			newMockAuthBearerMiddleware(&user),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService,
+			&appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			webhookService,
+		)
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/me/connected-apps/%s", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("DELETE", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+		assert.Equal("", webhookService.dispatchRecorder.event)
+	})
+
+	t.Run("Test disconnect my app error", func(t *testing.T) {
+		user := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		appService.disconnectError = errors.New("error")
+		router := setupMeRouter(
+			newMockAuthBearerMiddleware(&user),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService,
+			&appService,
+			newMockedOneTimeTokenService(nil, nil),
+			newMockedSessionService(nil),
+			newMockedAuditService(),
+			newMockedWebhookService(nil, nil),
+		)
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/me/connected-apps/%s", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("DELETE", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+}
+
+func TestImpersonationForbidden(t *testing.T) {
+	assert := require.New(t)
+
+	authorizedUser := tests.UserFactory()
+	staff := tests.UserFactory()
+	authMiddleware := newMockAuthBearerMiddleware(&authorizedUser)
+	authMiddleware.impersonator = &staff
+	authService := newMockedAuthService(&authorizedUser, nil, nil, nil, nil, nil)
+	userService := newMockedUserService(nil, nil, nil, nil, nil)
+	appService := newMockedAppService(nil, nil, nil, nil, nil)
+
+	meRouter := setupMeRouter(
+		authMiddleware, authService,
+		&userService, &appService,
+		newMockedOneTimeTokenService(nil, nil),
+		newMockedSessionService(nil),
+		newMockedAuditService(),
+		newMockedWebhookService(nil, nil),
+	)
+	phoneRouter := setupPhoneRouter(authMiddleware, &userService, newMockedPhoneService(nil), newMockedThrottler(true))
+	oauthRouter := setupOauth2Router(authMiddleware, authService, &userService, &appService)
+	samlRouter := setupSAMLRouter(authMiddleware, newMockedSAMLService(nil), newMockedWebhookService(nil, nil))
+	exportRouter := setupDataExportRouter(authMiddleware, newMockedDataExportService(nil, nil))
+	legalRouter := setupLegalRouter(authMiddleware, &appService, newMockedLegalService(nil))
+
+	cases := []struct {
+		router  *gin.Engine
+		method  string
+		path    string
+		payload string
+	}{
+		{meRouter, "PATCH", "/me", `{"phone": "+34666123456"}`},
+		{meRouter, "DELETE", "/me/sessions", ""},
+		{phoneRouter, "POST", "/me/phone/verify", `{"code": "123456"}`},
+		{phoneRouter, "PUT", "/me/phone/mfa", `{"enabled": false}`},
+		{oauthRouter, "POST", "/oauth/authorize", "{}"},
+		{samlRouter, "POST", "/saml/authorize", "{}"},
+		{exportRouter, "POST", "/me/export", ""},
+		{legalRouter, "POST", "/me/legal-acceptances", "{}"},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("Test %s %s while impersonated", tc.method, tc.path), func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.payload))
+			tc.router.ServeHTTP(recorder, request)
+
+			var response helpers.HTTPError
+			json.Unmarshal(recorder.Body.Bytes(), &response)
+			assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+			assert.Equal(ImpersonationForbiddenError{}.Error(), response.Error)
+		})
+	}
+
+	assert.Empty(userService.updateRecorder.userData.Phone)
+}
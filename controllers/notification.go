@@ -1,28 +1,35 @@
 package controllers
 
 import (
-	"fmt"
 	"gandalf/helpers"
 	"gandalf/security"
 	"gandalf/services"
 	"gandalf/validators"
 	"net/http"
-	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Runs the given task without blocking the request, so the response time
+// does not depend on the work done for the requested user
+var runInBackground = func(task func()) { go task() }
+
 // Register notifications endpoints to the given router
 func RegisterNotificationRoutes(
 	router *gin.Engine,
 	authService services.IAuthService,
 	userService services.IUserService,
-	pelipperService services.IPelipperService,
+	outboxService services.IOutboxService,
+	emailThrottler security.IThrottler,
+	ipThrottler security.IThrottler,
 ) {
 	controller := NotificationController{
-		authService:     authService,
-		userService:     userService,
-		pelipperService: pelipperService,
+		authService:    authService,
+		userService:    userService,
+		outboxService:  outboxService,
+		emailThrottler: emailThrottler,
+		ipThrottler:    ipThrottler,
 	}
 
 	publicRoutes := router.Group("/notifications/emails")
@@ -35,13 +42,29 @@ func RegisterNotificationRoutes(
 
 // Controller for /notifications endpoints
 type NotificationController struct {
-	authService     services.IAuthService
-	userService     services.IUserService
-	pelipperService services.IPelipperService
+	authService    services.IAuthService
+	userService    services.IUserService
+	outboxService  services.IOutboxService
+	emailThrottler security.IThrottler
+	ipThrottler    security.IThrottler
+}
+
+// Check if the request is allowed by the email and ip throttlers. Unknown
+// emails are throttled as well, so throttling does not reveal which emails
+// are registered.
+func (controller NotificationController) allowed(c *gin.Context, email string) bool {
+	ipAllowed := controller.ipThrottler.Allow(c.ClientIP())
+	emailAllowed := controller.emailThrottler.Allow(strings.ToLower(email))
+	if !ipAllowed || !emailAllowed {
+		helpers.AbortWithStatus(c, http.StatusTooManyRequests, NotificationThrottledError{})
+		return false
+	}
+	return true
 }
 
 // @Summary Sends verification email
-// @Description Sends verification email
+// @Description Sends verification email. The response is the same whether
+// @Description the email is registered or not.
 // @ID notifications-emails-verification
 // @Tags Notification
 // @Accept json
@@ -49,7 +72,7 @@ type NotificationController struct {
 // @Param data body validators.UserResendEmail true "sends the verification email"
 // @Success 204
 // @Failure 400 {object} helpers.HTTPError
-// @Failure 403 {object} helpers.HTTPError
+// @Failure 429 {object} helpers.HTTPError
 // @Router /notifications/emails/verify-user [post]
 func (controller NotificationController) UserVerificationEmail(c *gin.Context) {
 	var input validators.UserResendEmail
@@ -58,30 +81,23 @@ func (controller NotificationController) UserVerificationEmail(c *gin.Context) {
 		return
 	}
 
-	user, err := controller.userService.ReadByEmail(input.Email)
-	if err != nil {
-		c.JSON(http.StatusCreated, nil)
+	if !controller.allowed(c, input.Email) {
 		return
 	}
 
-	verifyToken := controller.authService.GenerateTokens(
-		*user, []string{security.ScopeUserVerify},
-	).AccessToken
-	url := os.Getenv("EMAIL_VERIFICATION_URL")
-	emailData := validators.PelipperUserVerifyEmail{
-		Email:   user.Email,
-		Name:    user.Name,
-		Subject: "Welcome",
-		VerificationLink: fmt.Sprintf(
-			"%s?code=%s", url, verifyToken,
-		),
+	user, err := controller.userService.ReadByEmail(input.Email)
+	if err == nil && !user.Verified {
+		runInBackground(func() {
+			controller.outboxService.SendVerificationEmail(*user, input.ClientID)
+		})
 	}
-	go controller.pelipperService.SendUserVerifyEmail(emailData)
+
 	c.JSON(http.StatusNoContent, nil)
 }
 
 // @Summary Sends reset password email
-// @Description Sends reset password email
+// @Description Sends reset password email. The response is the same whether
+// @Description the email is registered or not.
 // @ID notifications-emails-reset-password
 // @Tags Notification
 // @Accept json
@@ -89,7 +105,7 @@ func (controller NotificationController) UserVerificationEmail(c *gin.Context) {
 // @Param data body validators.UserResendEmail true "resend the reset password email"
 // @Success 204
 // @Failure 400 {object} helpers.HTTPError
-// @Failure 403 {object} helpers.HTTPError
+// @Failure 429 {object} helpers.HTTPError
 // @Router /notifications/emails/reset-user-password [post]
 func (controller NotificationController) UserResetPasswordEmail(c *gin.Context) {
 	var input validators.UserResendEmail
@@ -98,24 +114,16 @@ func (controller NotificationController) UserResetPasswordEmail(c *gin.Context)
 		return
 	}
 
-	user, err := controller.userService.ReadByEmail(input.Email)
-	if err != nil {
-		c.JSON(http.StatusCreated, nil)
+	if !controller.allowed(c, input.Email) {
 		return
 	}
 
-	changePasswordToken := controller.authService.GenerateTokens(
-		*user, []string{security.ScopeUserChangePassword},
-	).AccessToken
-	url := os.Getenv("PASSWORD_CHANGE_URL")
-	emailData := validators.PelipperUserChangePassword{
-		Email:   user.Email,
-		Name:    user.Name,
-		Subject: "Welcome",
-		ChangePasswordLink: fmt.Sprintf(
-			"%s?code=%s", url, changePasswordToken,
-		),
+	user, err := controller.userService.ReadByEmail(input.Email)
+	if err == nil {
+		runInBackground(func() {
+			controller.outboxService.SendResetPasswordEmail(*user, input.ClientID, nil)
+		})
 	}
-	go controller.pelipperService.SendUserChangePasswordEmail(emailData)
+
 	c.JSON(http.StatusNoContent, nil)
 }
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register app notification template endpoints to the given router
+func RegisterNotificationTemplateRoutes(
+	router *gin.Engine,
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	appService services.IAppService,
+	templateService services.INotificationTemplateService,
+) {
+	controller := NotificationTemplateController{
+		apps: AppController{
+			appService:     appService,
+			authMiddleware: authBearerMiddleware,
+		},
+		templateService: templateService,
+		authMiddleware:  authBearerMiddleware,
+	}
+
+	writeRoutes := router.Group("/apps/:uuid/notification-templates")
+	{
+		scopes := []string{security.ScopeAppWrite}
+		writeRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		writeRoutes.GET("", controller.ListTemplates)
+		writeRoutes.PUT("/:kind/:locale", controller.SaveTemplate)
+		writeRoutes.DELETE("/:kind/:locale", controller.DeleteTemplate)
+	}
+}
+
+// Controller for /apps/{uuid}/notification-templates endpoints
+type NotificationTemplateController struct {
+	apps            AppController
+	templateService services.INotificationTemplateService
+	authMiddleware  middlewares.IAuthBearerMiddleware
+}
+
+// @Summary List app notification templates
+// @Description List the notification templates overridden by an app. They
+// @Description are used for the notifications sent on behalf of the app.
+// @ID notification-template-list
+// @Tags NotificationTemplate
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Success 200 {object} serializers.NotificationTemplatesSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/notification-templates [get]
+func (controller NotificationTemplateController) ListTemplates(c *gin.Context) {
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	app := controller.apps.managedApp(c, *user)
+	if app == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, serializers.NewNotificationTemplatesSerializer(controller.templateService.List(app)))
+}
+
+// @Summary Override an app notification template
+// @Description Overrides the subject, text and html of a notification kind
+// @Description for a locale, for the notifications sent on behalf of an app.
+// @Description The kinds carrying a secret link (user-verify-email,
+// @Description user-change-password, user-magic-link, guardian-consent)
+// @Description cannot be overridden
+// @ID notification-template-save
+// @Tags NotificationTemplate
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Param kind path string true "Notification kind" Enums(user-verify-email, user-change-password, user-signup-attempt, organization-invitation, user-magic-link, user-data-export, guardian-consent, alert-password-changed, alert-app-authorized, alert-new-device, alert-impersonated, alert-phone-changed)
+// @Param locale path string true "Locale"
+// @Param data body validators.NotificationTemplateData true "Template data"
+// @Success 200 {object} serializers.NotificationTemplateSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/notification-templates/{kind}/{locale} [put]
+func (controller NotificationTemplateController) SaveTemplate(c *gin.Context) {
+	var uri validators.AppNotificationTemplateReadData
+	if err := c.ShouldBindUri(&uri); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var input validators.NotificationTemplateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	app := controller.apps.managedApp(c, *user)
+	if app == nil {
+		return
+	}
+
+	template, err := controller.templateService.Save(uri.Kind, uri.Locale, input, app)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewNotificationTemplateSerializer(*template))
+}
+
+// @Summary Delete an app notification template
+// @Description Deletes the override of a notification kind for a locale, so
+// @Description the deployment template is used again for the app
+// @ID notification-template-delete
+// @Tags NotificationTemplate
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Param kind path string true "Notification kind" Enums(user-verify-email, user-change-password, user-signup-attempt, organization-invitation, user-magic-link, user-data-export, guardian-consent, alert-password-changed, alert-app-authorized, alert-new-device, alert-impersonated, alert-phone-changed)
+// @Param locale path string true "Locale"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/notification-templates/{kind}/{locale} [delete]
+func (controller NotificationTemplateController) DeleteTemplate(c *gin.Context) {
+	var uri validators.AppNotificationTemplateReadData
+	if err := c.ShouldBindUri(&uri); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	app := controller.apps.managedApp(c, *user)
+	if app == nil {
+		return
+	}
+
+	if err := controller.templateService.Delete(uri.Kind, uri.Locale, app); err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/services"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type templateRecorder struct {
+	kind    string
+	locale  string
+	app     *models.App
+	data    validators.NotificationTemplateData
+	preview validators.NotificationTemplatePreviewData
+}
+
+type mockNotificationTemplateService struct {
+	recorder *templateRecorder
+	err      error
+}
+
+func newMockedNotificationTemplateService(err error) *mockNotificationTemplateService {
+	return &mockNotificationTemplateService{recorder: new(templateRecorder), err: err}
+}
+
+func (service *mockNotificationTemplateService) List(app *models.App) []models.NotificationTemplate {
+	service.recorder.app = app
+	return []models.NotificationTemplate{
+		models.NewNotificationTemplate(models.OutboxKindUserVerifyEmail, "es", "Hola", "{{.Link}}", "", app),
+	}
+}
+
+func (service *mockNotificationTemplateService) Save(kind string, locale string, data validators.NotificationTemplateData, app *models.App) (*models.NotificationTemplate, error) {
+	service.recorder.kind = kind
+	service.recorder.locale = locale
+	service.recorder.data = data
+	service.recorder.app = app
+	if service.err != nil {
+		return nil, service.err
+	}
+	template := models.NewNotificationTemplate(kind, locale, data.Subject, data.Text, data.HTML, app)
+	return &template, nil
+}
+
+func (service *mockNotificationTemplateService) Delete(kind string, locale string, app *models.App) error {
+	service.recorder.kind = kind
+	service.recorder.locale = locale
+	service.recorder.app = app
+	return service.err
+}
+
+func (service *mockNotificationTemplateService) Preview(data validators.NotificationTemplatePreviewData) (*services.RenderedNotification, error) {
+	service.recorder.preview = data
+	if service.err != nil {
+		return nil, service.err
+	}
+	return &services.RenderedNotification{Locale: data.Locale, Subject: "Hola John", Text: "text"}, nil
+}
+
+func setupNotificationTemplateRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	appService services.IAppService,
+	templateService services.INotificationTemplateService,
+) *gin.Engine {
+	router := gin.Default()
+	RegisterNotificationTemplateRoutes(router, authBearerMiddleware, appService, templateService)
+	return router
+}
+
+func setupAdminTemplateRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	adminActionService services.IAdminActionService,
+	templateService services.INotificationTemplateService,
+) *gin.Engine {
+	router := gin.Default()
+	userService := newMockedUserService(nil, nil, nil, nil, nil)
+	RegisterAdminRoutes(
+		router, authBearerMiddleware,
+		newMockedAuthService(nil, nil, nil, nil, nil, nil), &userService,
+		newMockedOutboxService(nil),
+		adminActionService, newMockedRoleService(security.GroupStaff, nil),
+		newMockedSessionService(nil), newMockedAuditService(),
+		templateService,
+		newMockedIdentityProviderService(nil), newMockedSCIMService(nil),
+		newMockedAttributeService(nil), newMockedLegalService(nil),
+		newMockedPhoneService(nil), newMockedThrottler(true),
+	)
+	return router
+}
+
+func TestAppNotificationTemplates(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list app templates", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		templateService := newMockedNotificationTemplateService(nil)
+		router := setupNotificationTemplateRouter(newMockAuthBearerMiddleware(&user), &appService, templateService)
+		var response gin.H
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/notification-templates", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(recorder, request)
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.NotNil(templateService.recorder.app)
+		assert.Equal("notification-template", response["type"])
+	})
+
+	t.Run("Test save app template", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		templateService := newMockedNotificationTemplateService(nil)
+		router := setupNotificationTemplateRouter(newMockAuthBearerMiddleware(&user), &appService, templateService)
+
+		payload, _ := json.Marshal(map[string]string{
+			"subject": "Bienvenido {{.Name}}",
+			"text":    "Verifica tu email: {{.Link}}",
+		})
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/notification-templates/user-verify-email/es-ES", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("PUT", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal(models.OutboxKindUserVerifyEmail, templateService.recorder.kind)
+		assert.Equal("es-ES", templateService.recorder.locale)
+		assert.Equal("Bienvenido {{.Name}}", templateService.recorder.data.Subject)
+		assert.NotNil(templateService.recorder.app)
+	})
+
+	t.Run("Test save app template with unknown kind", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		templateService := newMockedNotificationTemplateService(nil)
+		router := setupNotificationTemplateRouter(newMockAuthBearerMiddleware(&user), &appService, templateService)
+
+		payload, _ := json.Marshal(map[string]string{"subject": "Hi", "text": "Hi"})
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/notification-templates/unknown/es", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("PUT", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+		assert.Equal("", templateService.recorder.kind)
+	})
+
+	t.Run("Test save app template without access", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		appService.accessRole = models.MembershipMember
+		templateService := newMockedNotificationTemplateService(nil)
+		router := setupNotificationTemplateRouter(newMockAuthBearerMiddleware(&user), &appService, templateService)
+
+		payload, _ := json.Marshal(map[string]string{"subject": "Hi", "text": "Hi"})
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/notification-templates/user-verify-email/es", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("PUT", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+		assert.Equal("", templateService.recorder.kind)
+	})
+
+	t.Run("Test delete missing app template", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		templateService := newMockedNotificationTemplateService(errors.New("not found"))
+		router := setupNotificationTemplateRouter(newMockAuthBearerMiddleware(&user), &appService, templateService)
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/notification-templates/user-verify-email/es", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("DELETE", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+	})
+}
+
+func TestAdminNotificationTemplates(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test save deployment template", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		templateService := newMockedNotificationTemplateService(nil)
+		router := setupAdminTemplateRouter(authMiddleware, adminActionService, templateService)
+
+		payload, _ := json.Marshal(map[string]string{
+			"subject": "Reset your password",
+			"text":    "{{.Link}}",
+			"html":    "<a href=\"{{.Link}}\">Reset</a>",
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PUT", "/admin/notification-templates/user-change-password/en", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeTemplateWriteAll}, *authMiddleware.requestedScopes)
+		assert.Nil(templateService.recorder.app)
+		assert.Equal(models.OutboxKindUserChangePassword, templateService.recorder.kind)
+		assert.Equal(models.AdminActionSaveTemplate, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test save invalid deployment template", func(t *testing.T) {
+		staff := tests.UserFactory()
+		templateService := newMockedNotificationTemplateService(errors.New("template: subject: unexpected EOF"))
+		router := setupAdminTemplateRouter(newMockAuthBearerMiddleware(&staff), newMockedAdminActionService(nil), templateService)
+
+		payload, _ := json.Marshal(map[string]string{"subject": "{{.Name", "text": "{{.Link}}"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PUT", "/admin/notification-templates/user-change-password/en", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test list deployment templates", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		router := setupAdminTemplateRouter(authMiddleware, adminActionService, newMockedNotificationTemplateService(nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/notification-templates", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeTemplateReadAll}, *authMiddleware.requestedScopes)
+		assert.Equal(models.AdminActionListTemplates, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test delete deployment template", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		templateService := newMockedNotificationTemplateService(nil)
+		router := setupAdminTemplateRouter(newMockAuthBearerMiddleware(&staff), adminActionService, templateService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", "/admin/notification-templates/user-signup-attempt/es", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal(models.OutboxKindUserSignupAttempt, templateService.recorder.kind)
+		assert.Equal(models.AdminActionDropTemplate, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test preview notification", func(t *testing.T) {
+		staff := tests.UserFactory()
+		authMiddleware := newMockAuthBearerMiddleware(&staff)
+		adminActionService := newMockedAdminActionService(nil)
+		templateService := newMockedNotificationTemplateService(nil)
+		router := setupAdminTemplateRouter(authMiddleware, adminActionService, templateService)
+		var response gin.H
+
+		payload, _ := json.Marshal(map[string]string{"kind": "user-verify-email", "locale": "es"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/admin/notification-templates/preview", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal([]string{security.ScopeTemplateReadAll}, *authMiddleware.requestedScopes)
+		assert.Equal("es", templateService.recorder.preview.Locale)
+		assert.Equal(models.AdminActionPreview, adminActionService.recordRecorder.action)
+		assert.Equal("notification-preview", response["type"])
+	})
+
+	t.Run("Test preview draft without text", func(t *testing.T) {
+		staff := tests.UserFactory()
+		templateService := newMockedNotificationTemplateService(nil)
+		router := setupAdminTemplateRouter(newMockAuthBearerMiddleware(&staff), newMockedAdminActionService(nil), templateService)
+
+		payload, _ := json.Marshal(map[string]string{"kind": "user-verify-email", "locale": "es", "subject": "Hola"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/admin/notification-templates/preview", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+		assert.Equal("", templateService.recorder.preview.Kind)
+	})
+}
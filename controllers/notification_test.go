@@ -4,37 +4,107 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"gandalf/helpers"
+	"gandalf/models"
 	"gandalf/services"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/require"
 )
 
 func setupNotificationRouter(
 	authService services.IAuthService,
 	userService services.IUserService,
-	pelipperService services.IPelipperService,
+	outboxService services.IOutboxService,
 ) *gin.Engine {
 	router := gin.Default()
 	RegisterNotificationRoutes(
 		router, authService,
-		userService, pelipperService,
+		userService, outboxService,
+		newMockedThrottler(true), newMockedThrottler(true),
 	)
 	return router
 }
 
+type mockedThrottler struct {
+	allow bool
+	keys  []string
+}
+
+func newMockedThrottler(allow bool) *mockedThrottler {
+	return &mockedThrottler{allow: allow}
+}
+
+func (throttler *mockedThrottler) Allow(key string) bool {
+	throttler.keys = append(throttler.keys, key)
+	return throttler.allow
+}
+
+func init() {
+	runInBackground = func(task func()) { task() }
+}
+
+type sendNotificationRecorder struct {
+	kind     string
+	user     models.User
+	clientID string
+}
+
+type mockOutboxService struct {
+	sendRecorder        *sendNotificationRecorder
+	listRecorder        *string
+	adminActionRecorder *services.AdminActionContext
+
+	sendError error
+}
+
+func newMockedOutboxService(sendError error) *mockOutboxService {
+	return &mockOutboxService{
+		sendRecorder:        new(sendNotificationRecorder),
+		listRecorder:        new(string),
+		adminActionRecorder: new(services.AdminActionContext),
+		sendError:           sendError,
+	}
+}
+
+func (service *mockOutboxService) SendVerificationEmail(user models.User, clientID string) error {
+	*service.sendRecorder = sendNotificationRecorder{models.OutboxKindUserVerifyEmail, user, clientID}
+	return service.sendError
+}
+
+func (service *mockOutboxService) SendResetPasswordEmail(user models.User, clientID string, admin *services.AdminActionContext) error {
+	*service.sendRecorder = sendNotificationRecorder{models.OutboxKindUserChangePassword, user, clientID}
+	if admin != nil {
+		*service.adminActionRecorder = *admin
+	}
+	return service.sendError
+}
+
+func (service *mockOutboxService) SendSignupAttemptEmail(user models.User, clientID string) error {
+	*service.sendRecorder = sendNotificationRecorder{models.OutboxKindUserSignupAttempt, user, clientID}
+	return service.sendError
+}
+
+func (service *mockOutboxService) List(status string, cursor *helpers.Cursor) []models.OutboxMessage {
+	*service.listRecorder = status
+	return []models.OutboxMessage{models.NewOutboxMessage(models.OutboxKindUserVerifyEmail, "test@test.com", "{}")}
+}
+
+func (service *mockOutboxService) DeliverPending() {}
+
 func TestUserResendVerificationEmail(t *testing.T) {
 	assert := require.New(t)
 
 	t.Run("Test resend verification email successfully", func(t *testing.T) {
 		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
 		userService := newMockedUserService(nil, nil, nil, nil, nil)
-		pelipperService := newPelipperServiceMock()
+		outboxService := newMockedOutboxService(nil)
 		router := setupNotificationRouter(
-			authService, &userService, pelipperService,
+			authService, &userService, outboxService,
 		)
 		var response gin.H
 		email := "test@test.com"
@@ -50,15 +120,16 @@ func TestUserResendVerificationEmail(t *testing.T) {
 
 		assert.Equal(recorder.Result().StatusCode, http.StatusNoContent)
 		assert.Equal(userService.readByEmailRecorder.email, email)
-		assert.Equal(authService.generateTokensRecorder.user.Email, email)
+		assert.Equal(outboxService.sendRecorder.user.Email, email)
+		assert.Equal(outboxService.sendRecorder.kind, models.OutboxKindUserVerifyEmail)
 	})
 
 	t.Run("Test resend verification email wrong payload", func(t *testing.T) {
 		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
 		userService := newMockedUserService(nil, nil, nil, nil, nil)
-		pelipperService := newPelipperServiceMock()
+		outboxService := newMockedOutboxService(nil)
 		router := setupNotificationRouter(
-			authService, &userService, pelipperService,
+			authService, &userService, outboxService,
 		)
 		var response gin.H
 		email := "test@test.com"
@@ -78,9 +149,9 @@ func TestUserResendVerificationEmail(t *testing.T) {
 	t.Run("Test resend verification email not registered", func(t *testing.T) {
 		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
 		userService := newMockedUserService(nil, errors.New("not found"), nil, nil, nil)
-		pelipperService := newPelipperServiceMock()
+		outboxService := newMockedOutboxService(nil)
 		router := setupNotificationRouter(
-			authService, &userService, pelipperService,
+			authService, &userService, outboxService,
 		)
 		var response gin.H
 		email := "test@test.com"
@@ -94,8 +165,9 @@ func TestUserResendVerificationEmail(t *testing.T) {
 		router.ServeHTTP(recorder, request)
 		json.Unmarshal(recorder.Body.Bytes(), &response)
 
-		assert.Equal(recorder.Result().StatusCode, http.StatusCreated)
+		assert.Equal(recorder.Result().StatusCode, http.StatusNoContent)
 		assert.Equal(userService.readByEmailRecorder.email, email)
+		assert.Equal(outboxService.sendRecorder.kind, "")
 	})
 }
 
@@ -105,9 +177,9 @@ func TestUserResendResetPasswordEmail(t *testing.T) {
 	t.Run("Test resend change password email successfully", func(t *testing.T) {
 		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
 		userService := newMockedUserService(nil, nil, nil, nil, nil)
-		pelipperService := newPelipperServiceMock()
+		outboxService := newMockedOutboxService(nil)
 		router := setupNotificationRouter(
-			authService, &userService, pelipperService,
+			authService, &userService, outboxService,
 		)
 		var response gin.H
 		email := "test@test.com"
@@ -123,15 +195,38 @@ func TestUserResendResetPasswordEmail(t *testing.T) {
 
 		assert.Equal(recorder.Result().StatusCode, http.StatusNoContent)
 		assert.Equal(userService.readByEmailRecorder.email, email)
-		assert.Equal(authService.generateTokensRecorder.user.Email, email)
+		assert.Equal(outboxService.sendRecorder.user.Email, email)
+		assert.Equal(outboxService.sendRecorder.kind, models.OutboxKindUserChangePassword)
+	})
+
+	t.Run("Test resend change password email on behalf of an app", func(t *testing.T) {
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		outboxService := newMockedOutboxService(nil)
+		router := setupNotificationRouter(
+			authService, &userService, outboxService,
+		)
+		clientID := uuid.Must(uuid.NewV4()).String()
+
+		payload, _ := json.Marshal(map[string]string{
+			"email":     "test@test.com",
+			"client_id": clientID,
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/notifications/emails/reset-user-password", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal(clientID, outboxService.sendRecorder.clientID)
 	})
 
 	t.Run("Test resend change password email wrong payload", func(t *testing.T) {
 		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
 		userService := newMockedUserService(nil, nil, nil, nil, nil)
-		pelipperService := newPelipperServiceMock()
+		outboxService := newMockedOutboxService(nil)
 		router := setupNotificationRouter(
-			authService, &userService, pelipperService,
+			authService, &userService, outboxService,
 		)
 		var response gin.H
 		email := "test@test.com"
@@ -151,9 +246,9 @@ func TestUserResendResetPasswordEmail(t *testing.T) {
 	t.Run("Test resend change password email not registered", func(t *testing.T) {
 		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
 		userService := newMockedUserService(nil, errors.New("not found"), nil, nil, nil)
-		pelipperService := newPelipperServiceMock()
+		outboxService := newMockedOutboxService(nil)
 		router := setupNotificationRouter(
-			authService, &userService, pelipperService,
+			authService, &userService, outboxService,
 		)
 		var response gin.H
 		email := "test@test.com"
@@ -167,7 +262,65 @@ func TestUserResendResetPasswordEmail(t *testing.T) {
 		router.ServeHTTP(recorder, request)
 		json.Unmarshal(recorder.Body.Bytes(), &response)
 
-		assert.Equal(recorder.Result().StatusCode, http.StatusCreated)
+		assert.Equal(recorder.Result().StatusCode, http.StatusNoContent)
 		assert.Equal(userService.readByEmailRecorder.email, email)
+		assert.Equal(outboxService.sendRecorder.kind, "")
 	})
 }
+
+func TestNotificationThrottling(t *testing.T) {
+	assert := require.New(t)
+
+	for _, url := range []string{
+		"/notifications/emails/verify-user",
+		"/notifications/emails/reset-user-password",
+	} {
+		t.Run("Test throttled by email "+url, func(t *testing.T) {
+			userService := newMockedUserService(nil, nil, nil, nil, nil)
+			outboxService := newMockedOutboxService(nil)
+			emailThrottler := newMockedThrottler(false)
+			router := gin.Default()
+			RegisterNotificationRoutes(
+				router, newMockedAuthService(nil, nil, nil, nil, nil, nil),
+				&userService, outboxService,
+				emailThrottler, newMockedThrottler(true),
+			)
+
+			payload, _ := json.Marshal(map[string]string{
+				"email": "Test@test.com",
+			})
+
+			recorder := httptest.NewRecorder()
+			request, _ := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(recorder.Result().StatusCode, http.StatusTooManyRequests)
+			assert.Equal([]string{"test@test.com"}, emailThrottler.keys)
+			assert.Equal(userService.readByEmailRecorder.email, "")
+			assert.Equal(outboxService.sendRecorder.kind, "")
+		})
+
+		t.Run("Test throttled by ip "+url, func(t *testing.T) {
+			userService := newMockedUserService(nil, nil, nil, nil, nil)
+			ipThrottler := newMockedThrottler(false)
+			router := gin.Default()
+			RegisterNotificationRoutes(
+				router, newMockedAuthService(nil, nil, nil, nil, nil, nil),
+				&userService, newMockedOutboxService(nil),
+				newMockedThrottler(true), ipThrottler,
+			)
+
+			payload, _ := json.Marshal(map[string]string{
+				"email": "test@test.com",
+			})
+
+			recorder := httptest.NewRecorder()
+			request, _ := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(recorder.Result().StatusCode, http.StatusTooManyRequests)
+			assert.Equal(1, len(ipThrottler.keys))
+			assert.Equal(userService.readByEmailRecorder.email, "")
+		})
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"gandalf/helpers"
 	"gandalf/middlewares"
+	"gandalf/models"
 	"gandalf/security"
 	"gandalf/serializers"
 	"gandalf/services"
 	"gandalf/validators"
 	"net/http"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
@@ -21,11 +23,23 @@ func RegisterOauth2Routes(
 	authService services.IAuthService,
 	userService services.IUserService,
 	appService services.IAppService,
+	roleService services.IRoleService,
+	logoutService services.ILogoutService,
+	webhookService services.IWebhookService,
+	phoneService services.IPhoneService,
+	phoneThrottler security.IThrottler,
 ) {
 	controller := Oauth2Controller{
+		phones: PhoneController{
+			phoneService: phoneService,
+			throttler:    phoneThrottler,
+		},
 		authService:    authService,
 		userService:    userService,
 		appService:     appService,
+		roleService:    roleService,
+		logoutService:  logoutService,
+		webhookService: webhookService,
 		authMiddleware: authBearerMiddleware,
 	}
 
@@ -33,6 +47,8 @@ func RegisterOauth2Routes(
 	{
 		publicRoutes.POST("/login", controller.Oauth2Login)
 		publicRoutes.POST("/token", controller.Oauth2Token)
+		publicRoutes.GET("/logout", controller.Oauth2EndSession)
+		publicRoutes.POST("/logout", controller.Oauth2EndSession)
 	}
 
 	authorizeRoutes := router.Group("/oauth")
@@ -46,14 +62,20 @@ func RegisterOauth2Routes(
 
 // Controller for /oauth2 endpoints
 type Oauth2Controller struct {
+	phones         PhoneController
 	authService    services.IAuthService
 	appService     services.IAppService
 	userService    services.IUserService
+	roleService    services.IRoleService
+	logoutService  services.ILogoutService
+	webhookService services.IWebhookService
 	authMiddleware middlewares.IAuthBearerMiddleware
 }
 
 // @Summary Login an user and retrieve auth token
-// @Description logs an user
+// @Description logs an user. The current mandatory legal documents the
+// @Description user has not accepted yet are answered with a 403, and they
+// @Description can be accepted along with the login.
 // @ID oauth-login
 // @Tags Oauth
 // @Accept json
@@ -61,7 +83,9 @@ type Oauth2Controller struct {
 // @Param user body validators.Credentials true "Logs an user"
 // @Success 201 {object} serializers.TokensSerializer
 // @Failure 400 {object} helpers.HTTPError
+// @Failure 401 {object} helpers.HTTPError
 // @Failure 403 {object} helpers.HTTPError
+// @Failure 429 {object} helpers.HTTPError
 // @Router /oauth/login [post]
 func (controller Oauth2Controller) Oauth2Login(c *gin.Context) {
 	var input validators.Credentials
@@ -69,18 +93,29 @@ func (controller Oauth2Controller) Oauth2Login(c *gin.Context) {
 		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
 		return
 	}
-	user, err := controller.authService.Authenticate(input, false)
+	user, err := controller.authService.Authenticate(input, false, helpers.NewClientInfo(c))
 	if err != nil {
-		helpers.AbortWithStatus(c, http.StatusForbidden, err)
+		controller.phones.abortLogin(c, user, err)
 		return
 	}
 
-	tokens := controller.authService.GenerateTokens(*user, security.GroupUserOauth2Request)
-	c.JSON(http.StatusOK, serializers.NewTokensSerializer(tokens))
+	scopes := security.IntersectScopes(
+		controller.roleService.UserScopes(*user), security.GroupUserOauth2Request,
+	)
+	tokens, err := controller.authService.StartSession(*user, helpers.NewClientInfo(c), scopes)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewTokensSerializer(*tokens))
 }
 
 // @Summary Authorize an app to get the user data
-// @Description authorize app
+// @Description authorize app. Users younger than the minimum age of the
+// @Description app, or whose age is unknown, are refused. The mandatory
+// @Description legal documents of the app and the deployment wide ones the
+// @Description user has not accepted yet are answered with a 403, and they
+// @Description can be accepted along with the authorization.
 // @ID oauth-authorize
 // @Tags Oauth
 // @Accept json
@@ -88,8 +123,13 @@ func (controller Oauth2Controller) Oauth2Login(c *gin.Context) {
 // @Param user body validators.OauthAuthorizeData true "Authorize app to get user's data"
 // @Security OAuth2AccessCode[user:me:authorized-app]
 // @Success 302
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
 // @Router /oauth/authorize [post]
 func (controller Oauth2Controller) Oauth2Authorize(c *gin.Context) {
+	if abortImpersonated(c, controller.authMiddleware) {
+		return
+	}
 	user := controller.authMiddleware.GetAuthorizedUser(c)
 	var input validators.OauthAuthorizeData
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -104,12 +144,26 @@ func (controller Oauth2Controller) Oauth2Authorize(c *gin.Context) {
 		return
 	}
 
-	code, err := controller.authService.Authorize(app, user, input)
+	code, err := controller.authService.Authorize(
+		app, user, input,
+		helpers.NewClientInfo(c), controller.authMiddleware.GetAuthorizedSession(c),
+	)
 	if err != nil {
-		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		if abortLegalAcceptance(c, err) {
+			return
+		}
+		status := http.StatusBadRequest
+		if _, restricted := err.(services.AppAgeRestrictedError); restricted {
+			status = http.StatusForbidden
+		}
+		helpers.AbortWithStatus(c, status, err)
 		return
 	}
 
+	runInBackground(func() {
+		controller.webhookService.Dispatch(models.WebhookEventAppConnected, *user, app)
+	})
+
 	redirectUrl := fmt.Sprintf("%s?code=%s&state=%s", input.RedirectURI, code, input.State)
 	c.Redirect(http.StatusFound, redirectUrl)
 }
@@ -136,11 +190,65 @@ func (controller Oauth2Controller) Oauth2Token(c *gin.Context) {
 	app, err := controller.appService.ReadByClientID(uuid.FromStringOrNil(input.ClientID))
 	if err != nil {
 		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
 	}
 
-	tokens, err := controller.authService.ExchangeOauthToken(*app, input)
+	tokens, err := controller.authService.ExchangeOauthToken(*app, input, helpers.NewClientInfo(c))
 	if err != nil {
 		helpers.AbortWithStatus(c, http.StatusUnauthorized, err)
+		return
 	}
 	c.JSON(http.StatusOK, serializers.NewTokensSerializer(*tokens))
 }
+
+// @Summary Ends the session of the user in an app
+// @Description Ends the session the given id token was issued for, and notifies the apps the user is connected to
+// @ID oauth-logout
+// @Tags Oauth
+// @Accept application/x-www-form-urlencoded
+// @Accept json
+// @Param data body validators.OauthEndSessionData true "End session data"
+// @Success 204
+// @Success 302
+// @Failure 400 {object} helpers.HTTPError
+// @Router /oauth/logout [post]
+func (controller Oauth2Controller) Oauth2EndSession(c *gin.Context) {
+	var input validators.OauthEndSessionData
+	if err := c.ShouldBind(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	app, session, err := controller.logoutService.ReadIDTokenHint(input.IDTokenHint)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	sessions, err := controller.logoutService.EndSession(*app, *session, input.PostLogoutRedirectURI)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := session.User
+	runInBackground(func() {
+		controller.logoutService.NotifyApps(user, sessions)
+	})
+
+	if input.PostLogoutRedirectURI == "" {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	redirectURL, err := url.Parse(input.PostLogoutRedirectURI)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	if input.State != "" {
+		query := redirectURL.Query()
+		query.Set("state", input.State)
+		redirectURL.RawQuery = query.Encode()
+	}
+	c.Redirect(http.StatusFound, redirectURL.String())
+}
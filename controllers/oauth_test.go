@@ -13,6 +13,7 @@ import (
 	"gandalf/validators"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -22,8 +23,9 @@ import (
 )
 
 type createAppRecorder struct {
-	appData validators.AppCreateData
-	user    models.User
+	appData      validators.AppCreateData
+	user         models.User
+	organization *models.Organization
 }
 
 type readAppRecorder struct {
@@ -43,22 +45,35 @@ type deleteAppRecorder struct {
 	uuid uuid.UUID
 }
 
+type transferAppRecorder struct {
+	organization *models.Organization
+}
+
+type disconnectAppRecorder struct {
+	app  models.App
+	user models.User
+}
+
 type mockAppService struct {
 	createAppRecorder       *createAppRecorder
 	readAppRecorder         *readAppRecorder
 	readByClientAppRecorder *readByClientAppRecorder
 	updateAppRecorder       *updateAppRecorder
 	deleteAppRecorder       *deleteAppRecorder
+	transferAppRecorder     *transferAppRecorder
+	disconnectAppRecorder   *disconnectAppRecorder
 
+	accessRole        string
 	createError       error
 	readError         error
 	readByClientError error
 	updateError       error
 	deleteError       error
+	disconnectError   error
 }
 
-func (service *mockAppService) Create(appData validators.AppCreateData, user models.User) (*models.App, error) {
-	*service.createAppRecorder = createAppRecorder{appData, user}
+func (service *mockAppService) Create(appData validators.AppCreateData, user models.User, organization *models.Organization, audit services.AuditContext) (*models.App, error) {
+	*service.createAppRecorder = createAppRecorder{appData, user, organization}
 	return &models.App{}, service.createError
 }
 
@@ -69,15 +84,18 @@ func (service *mockAppService) Read(uuid uuid.UUID) (*models.App, error) {
 
 func (service *mockAppService) ReadByClientID(clientID uuid.UUID) (*models.App, error) {
 	*service.readByClientAppRecorder = readByClientAppRecorder{clientID}
-	return &models.App{}, service.readByClientError
+	if service.readByClientError != nil {
+		return nil, service.readByClientError
+	}
+	return &models.App{}, nil
 }
 
-func (service *mockAppService) Update(uuid uuid.UUID, appData validators.AppUpdateData) (*models.App, error) {
+func (service *mockAppService) Update(uuid uuid.UUID, appData validators.AppUpdateData, audit services.AuditContext) (*models.App, error) {
 	*service.updateAppRecorder = updateAppRecorder{uuid, appData}
 	return &models.App{}, service.updateError
 }
 
-func (service *mockAppService) Delete(uuid uuid.UUID) error {
+func (service *mockAppService) Delete(uuid uuid.UUID, audit services.AuditContext) error {
 	*service.deleteAppRecorder = deleteAppRecorder{uuid}
 	return service.deleteError
 }
@@ -90,6 +108,20 @@ func (service *mockAppService) ListConnectedApps(user models.User, cursor *helpe
 	return []models.App{}
 }
 
+func (service *mockAppService) Transfer(app *models.App, organization models.Organization, audit services.AuditContext) error {
+	*service.transferAppRecorder = transferAppRecorder{&organization}
+	return nil
+}
+
+func (service *mockAppService) Disconnect(app models.App, user models.User, audit services.AuditContext) error {
+	*service.disconnectAppRecorder = disconnectAppRecorder{app, user}
+	return service.disconnectError
+}
+
+func (service *mockAppService) AccessRole(app models.App, user models.User) string {
+	return service.accessRole
+}
+
 func newMockedAppService(createError error, readError error, readByClientError error, updateError error, deleteError error) mockAppService {
 	return mockAppService{
 		createAppRecorder:       new(createAppRecorder),
@@ -97,6 +129,9 @@ func newMockedAppService(createError error, readError error, readByClientError e
 		readByClientAppRecorder: new(readByClientAppRecorder),
 		updateAppRecorder:       new(updateAppRecorder),
 		deleteAppRecorder:       new(deleteAppRecorder),
+		transferAppRecorder:     new(transferAppRecorder),
+		disconnectAppRecorder:   new(disconnectAppRecorder),
+		accessRole:              models.MembershipOwner,
 		createError:             createError,
 		readError:               readError,
 		readByClientError:       readByClientError,
@@ -105,16 +140,83 @@ func newMockedAppService(createError error, readError error, readByClientError e
 	}
 }
 
+type readIDTokenHintRecorder struct {
+	idToken string
+}
+
+type endSessionRecorder struct {
+	session     models.Session
+	redirectUri string
+}
+
+type notifyAppsRecorder struct {
+	user     models.User
+	sessions []models.Session
+}
+
+type mockLogoutService struct {
+	readIDTokenHintRecorder *readIDTokenHintRecorder
+	endSessionRecorder      *endSessionRecorder
+	notifyAppsRecorder      *notifyAppsRecorder
+
+	session         *models.Session
+	readHintError   error
+	endSessionError error
+}
+
+func (service *mockLogoutService) ReadIDTokenHint(idToken string) (*models.App, *models.Session, error) {
+	*service.readIDTokenHintRecorder = readIDTokenHintRecorder{idToken}
+	return &models.App{}, service.session, service.readHintError
+}
+
+func (service *mockLogoutService) EndSession(app models.App, session models.Session, redirectUri string) ([]models.Session, error) {
+	*service.endSessionRecorder = endSessionRecorder{session, redirectUri}
+	return []models.Session{session}, service.endSessionError
+}
+
+func (service *mockLogoutService) NotifyApps(user models.User, sessions []models.Session) {
+	*service.notifyAppsRecorder = notifyAppsRecorder{user, sessions}
+}
+
+func newMockedLogoutService(session *models.Session, readHintError error, endSessionError error) mockLogoutService {
+	return mockLogoutService{
+		readIDTokenHintRecorder: new(readIDTokenHintRecorder),
+		endSessionRecorder:      new(endSessionRecorder),
+		notifyAppsRecorder:      new(notifyAppsRecorder),
+		session:                 session,
+		readHintError:           readHintError,
+		endSessionError:         endSessionError,
+	}
+}
+
 func setupOauth2Router(
 	authBearerMiddleware middlewares.IAuthBearerMiddleware,
 	authService services.IAuthService,
 	userService services.IUserService,
 	appService services.IAppService,
+) *gin.Engine {
+	logoutService := newMockedLogoutService(&models.Session{}, nil, nil)
+	return setupOauth2LogoutRouter(authBearerMiddleware, authService, userService, appService, &logoutService)
+}
+
+func setupOauth2LogoutRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	authService services.IAuthService,
+	userService services.IUserService,
+	appService services.IAppService,
+	logoutService services.ILogoutService,
 ) *gin.Engine {
 	router := gin.Default()
+	roleService := newMockedRoleService([]string{
+		security.ScopeUserAuthorizeApp, security.ScopeUserRead,
+		security.ScopeUserWrite, security.ScopeAppRead,
+	}, nil)
 	RegisterOauth2Routes(
 		router, authBearerMiddleware,
 		authService, userService, appService,
+		roleService, logoutService,
+		newMockedWebhookService(nil, nil),
+		newMockedPhoneService(nil), newMockedThrottler(true),
 	)
 	return router
 }
@@ -150,8 +252,8 @@ func TestOauth2Login(t *testing.T) {
 		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
 		assert.Equal(authService.authenticateRecorder.credentials.Email, user.Email)
 		assert.Equal(authService.authenticateRecorder.credentials.Password, user.Password)
-		assert.Equal(authService.generateTokensRecorder.user.Email, user.Email)
-		assert.Equal(authService.generateTokensRecorder.scopes, expectedScopes)
+		assert.Equal(authService.startSessionRecorder.user.Email, user.Email)
+		assert.Equal(authService.startSessionRecorder.scopes, expectedScopes)
 	})
 
 	t.Run("Test oauth2 login success bad request", func(t *testing.T) {
@@ -230,7 +332,7 @@ func TestOauth2Authorize(t *testing.T) {
 		payload, _ := json.Marshal(map[string]interface{}{
 			"client_id":    uuid,
 			"redirect_uri": redirectUrl,
-			"scopes":       security.GroupAdmin,
+			"scopes":       security.GroupUserOauth2Request,
 			"state":        state,
 		})
 
@@ -262,7 +364,7 @@ func TestOauth2Authorize(t *testing.T) {
 		payload, _ := json.Marshal(map[string]interface{}{
 			"client_id":    uuid,
 			"redirect_uri": redirectUrl,
-			"scopis":       security.GroupAdmin,
+			"scopis":       security.GroupUserOauth2Request,
 			"state":        state,
 		})
 
@@ -293,7 +395,7 @@ func TestOauth2Authorize(t *testing.T) {
 		payload, _ := json.Marshal(map[string]interface{}{
 			"client_id":    uuid,
 			"redirect_uri": redirectUrl,
-			"scopes":       security.GroupAdmin,
+			"scopes":       security.GroupUserOauth2Request,
 			"state":        state,
 		})
 
@@ -324,7 +426,7 @@ func TestOauth2Authorize(t *testing.T) {
 		payload, _ := json.Marshal(map[string]interface{}{
 			"client_id":    uuid,
 			"redirect_uri": redirectUrl,
-			"scopes":       security.GroupAdmin,
+			"scopes":       security.GroupUserOauth2Request,
 			"state":        state,
 		})
 
@@ -335,6 +437,33 @@ func TestOauth2Authorize(t *testing.T) {
 		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
 	})
 
+	t.Run("Test oauth2 authorize user under the minimum age", func(t *testing.T) {
+		user := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		authService := newMockedAuthService(&user, nil, nil, nil, services.AppAgeRestrictedError{}, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupOauth2Router(
+			authBearerMiddleware,
+			authService,
+			&userService,
+			&appService,
+		)
+
+		uuid, _ := uuid.NewV4()
+		payload, _ := json.Marshal(map[string]interface{}{
+			"client_id":    uuid,
+			"redirect_uri": faker.Internet().Url(),
+			"scopes":       security.GroupUserOauth2Request,
+			"state":        faker.RandomString(10),
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/oauth/authorize", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
 }
 
 func TestOauth2Token(t *testing.T) {
@@ -463,4 +592,158 @@ func TestOauth2Token(t *testing.T) {
 
 		assert.Equal(http.StatusUnauthorized, recorder.Result().StatusCode)
 	})
+
+	t.Run("Test oauth2 token errors stop the request", func(t *testing.T) {
+		user := tests.UserFactory()
+		unknownAppService := newMockedAppService(nil, nil, errors.New("Whoops!"), nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		cases := []struct {
+			controller Oauth2Controller
+			status     int
+		}{
+			{Oauth2Controller{appService: &unknownAppService, authService: newMockedAuthService(&user, nil, nil, nil, nil, nil)}, http.StatusBadRequest},
+			{Oauth2Controller{appService: &appService, authService: newMockedAuthService(&user, nil, nil, nil, nil, errors.New("Whoops!"))}, http.StatusUnauthorized},
+		}
+
+		for _, tc := range cases {
+			payload, _ := json.Marshal(map[string]interface{}{
+				"grant_type":    "authorization_code",
+				"client_id":     uuid.Must(uuid.NewV4()),
+				"client_secret": faker.RandomString(10),
+				"code":          faker.RandomString(10),
+				"redirect_uri":  faker.Internet().Url(),
+			})
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request, _ = http.NewRequest("POST", "/oauth/token", bytes.NewBuffer(payload))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			assert.NotPanics(func() { tc.controller.Oauth2Token(c) })
+			assert.Equal(tc.status, recorder.Code)
+		}
+	})
+}
+
+func TestOauth2EndSession(t *testing.T) {
+	assert := require.New(t)
+
+	setup := func(logoutService *mockLogoutService) *gin.Engine {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		authBearerMiddleware := newMockAuthBearerMiddleware(nil)
+		authService := newMockedAuthService(nil, nil, nil, nil, nil, nil)
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		return setupOauth2LogoutRouter(
+			authBearerMiddleware,
+			authService,
+			&userService,
+			&appService,
+			logoutService,
+		)
+	}
+
+	t.Run("Test oauth2 end session with redirect", func(t *testing.T) {
+		user := tests.UserFactory()
+		session := models.Session{User: user}
+		logoutService := newMockedLogoutService(&session, nil, nil)
+		router := setup(&logoutService)
+
+		idToken := faker.RandomString(20)
+		redirectUri := faker.Internet().Url()
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(
+			"GET", "/oauth/logout?id_token_hint="+idToken+"&post_logout_redirect_uri="+redirectUri+"&state=abc", nil,
+		)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusFound, recorder.Result().StatusCode)
+		assert.Equal(redirectUri+"?state=abc", recorder.Header().Get("Location"))
+		assert.Equal(idToken, logoutService.readIDTokenHintRecorder.idToken)
+		assert.Equal(redirectUri, logoutService.endSessionRecorder.redirectUri)
+		assert.Equal(user.Email, logoutService.notifyAppsRecorder.user.Email)
+		assert.Equal(1, len(logoutService.notifyAppsRecorder.sessions))
+	})
+
+	t.Run("Test oauth2 end session keeps the redirect query", func(t *testing.T) {
+		session := models.Session{User: tests.UserFactory()}
+		logoutService := newMockedLogoutService(&session, nil, nil)
+		router := setup(&logoutService)
+
+		query := url.Values{}
+		query.Set("id_token_hint", faker.RandomString(20))
+		query.Set("post_logout_redirect_uri", "https://example.com/bye?lang=en")
+		query.Set("state", "a&b=c")
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/oauth/logout?"+query.Encode(), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusFound, recorder.Result().StatusCode)
+		assert.Equal("https://example.com/bye?lang=en&state=a%26b%3Dc", recorder.Header().Get("Location"))
+	})
+
+	t.Run("Test oauth2 end session redirect without state", func(t *testing.T) {
+		session := models.Session{User: tests.UserFactory()}
+		logoutService := newMockedLogoutService(&session, nil, nil)
+		router := setup(&logoutService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(
+			"GET", "/oauth/logout?id_token_hint=token&post_logout_redirect_uri=https://example.com/bye", nil,
+		)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusFound, recorder.Result().StatusCode)
+		assert.Equal("https://example.com/bye", recorder.Header().Get("Location"))
+	})
+
+	t.Run("Test oauth2 end session without redirect", func(t *testing.T) {
+		logoutService := newMockedLogoutService(&models.Session{}, nil, nil)
+		router := setup(&logoutService)
+
+		payload, _ := json.Marshal(map[string]interface{}{
+			"id_token_hint": faker.RandomString(20),
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/oauth/logout", bytes.NewBuffer(payload))
+		request.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test oauth2 end session bad request", func(t *testing.T) {
+		logoutService := newMockedLogoutService(&models.Session{}, nil, nil)
+		router := setup(&logoutService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/oauth/logout?post_logout_redirect_uri=notanurl", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test oauth2 end session id token hint not valid", func(t *testing.T) {
+		logoutService := newMockedLogoutService(nil, errors.New("Whoops!"), nil)
+		router := setup(&logoutService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/oauth/logout?id_token_hint=token", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test oauth2 end session redirect not registered", func(t *testing.T) {
+		logoutService := newMockedLogoutService(&models.Session{}, nil, errors.New("Whoops!"))
+		router := setup(&logoutService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(
+			"GET", "/oauth/logout?id_token_hint=token&post_logout_redirect_uri=http://evil.dev", nil,
+		)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+		assert.Equal(models.User{}, logoutService.notifyAppsRecorder.user)
+	})
 }
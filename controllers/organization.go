@@ -0,0 +1,466 @@
+package controllers
+
+import (
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// Register organization endpoints to the given router
+func RegisterOrganizationRoutes(
+	router *gin.Engine,
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	organizationService services.IOrganizationService,
+	userService services.IUserService,
+) {
+	controller := OrganizationController{
+		organizationService: organizationService,
+		userService:         userService,
+		authMiddleware:      authBearerMiddleware,
+	}
+
+	readRoutes := router.Group("/organizations")
+	{
+		scopes := []string{security.ScopeOrganizationRead}
+		readRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		readRoutes.GET("", controller.ListOrganizations)
+		readRoutes.GET("/:uuid", controller.ReadOrganization)
+		readRoutes.GET("/:uuid/members", controller.ListMembers)
+	}
+
+	writeRoutes := router.Group("/organizations")
+	{
+		scopes := []string{security.ScopeOrganizationWrite}
+		writeRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		writeRoutes.POST("", controller.CreateOrganization)
+		writeRoutes.PATCH("/:uuid", controller.UpdateOrganization)
+		writeRoutes.DELETE("/:uuid", controller.DeleteOrganization)
+		writeRoutes.PUT("/:uuid/members/:user", controller.SetMemberRole)
+		writeRoutes.DELETE("/:uuid/members/:user", controller.RemoveMember)
+		writeRoutes.POST("/:uuid/invitations", controller.Invite)
+		writeRoutes.POST("/:uuid/invitations/accept", controller.AcceptInvitation)
+	}
+}
+
+// Controller for /organizations endpoints
+type OrganizationController struct {
+	organizationService services.IOrganizationService
+	userService         services.IUserService
+	authMiddleware      middlewares.IAuthBearerMiddleware
+}
+
+// Reads the organization given in the uri without checking the membership
+// of the user. Returns nil and aborts the request if it does not exist.
+func (controller OrganizationController) readOrganization(c *gin.Context) *models.Organization {
+	var input validators.OrganizationReadData
+	if err := c.ShouldBindUri(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return nil
+	}
+
+	organization, err := controller.organizationService.Read(uuid.FromStringOrNil(input.UUID))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+	return organization
+}
+
+// Reads the organization given in the uri and the membership of the user
+// who performs the request, which must have at least the required role.
+// Returns nil and aborts the request otherwise.
+func (controller OrganizationController) readMembership(c *gin.Context, required string) *models.Membership {
+	organization := controller.readOrganization(c)
+	if organization == nil {
+		return nil
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	membership, err := controller.organizationService.Membership(*organization, *user)
+	if err != nil {
+		// Organizations are hidden to the users who are not members
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+	if !models.MembershipRoleAtLeast(membership.Role, required) {
+		helpers.AbortWithStatus(c, http.StatusForbidden, OrganizationAccessDeniedError{})
+		return nil
+	}
+	return membership
+}
+
+// Reads the member given in the uri of the given organization. Returns nil
+// and aborts the request if he is not a member.
+func (controller OrganizationController) readMember(c *gin.Context, organization models.Organization) *models.Membership {
+	var input validators.OrganizationMemberData
+	if err := c.ShouldBindUri(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return nil
+	}
+
+	user, err := controller.userService.Read(uuid.FromStringOrNil(input.UserUUID))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+
+	member, err := controller.organizationService.Membership(organization, *user)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+	return member
+}
+
+// Aborts the request with the status that matches the given membership error
+func abortMembershipError(c *gin.Context, err error) {
+	switch err.(type) {
+	case services.MembershipNotFoundError:
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+	case services.LastOwnerError:
+		helpers.AbortWithStatus(c, http.StatusConflict, err)
+	default:
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+	}
+}
+
+// @Summary Creates an organization
+// @Description creates an organization owned by the user
+// @ID organization-create
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param organization body validators.OrganizationCreateData true "Creates an organization"
+// @Success 201 {object} serializers.OrganizationSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[org:me:write]
+// @Router /organizations [post]
+func (controller OrganizationController) CreateOrganization(c *gin.Context) {
+	var input validators.OrganizationCreateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	organization, err := controller.organizationService.Create(input, *user)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusCreated, serializers.NewOrganizationSerializer(*organization))
+}
+
+// @Summary Get user's organizations
+// @Description get the organizations the user is member of
+// @ID organization-list
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param page query int false "cursor's page"
+// @Param limit query int false "cursor's limit"
+// @Success 200 {object} serializers.PaginatedOrganizationsSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[org:me:read]
+// @Router /organizations [get]
+func (controller OrganizationController) ListOrganizations(c *gin.Context) {
+	var input validators.PaginationQuery
+	if err := c.ShouldBindQuery(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	cursor := helpers.NewCursor(input.Page, input.PageSize)
+	organizations := controller.organizationService.List(*user, &cursor)
+	c.JSON(http.StatusOK, serializers.NewPaginatedOrganizationsSerializer(organizations, cursor))
+}
+
+// @Summary Get an organization
+// @Description get an organization the user is member of by his uuid
+// @ID organization-read
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Organization uuid"
+// @Success 200 {object} serializers.OrganizationSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[org:me:read]
+// @Router /organizations/{uuid} [get]
+func (controller OrganizationController) ReadOrganization(c *gin.Context) {
+	membership := controller.readMembership(c, models.MembershipMember)
+	if membership == nil {
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewOrganizationSerializer(membership.Organization))
+}
+
+// @Summary Update an organization
+// @Description updates an organization. Requires to be owner or admin of
+// @Description the organization.
+// @ID organization-update
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Organization uuid"
+// @Param organization body validators.OrganizationUpdateData true "Updates an organization"
+// @Success 200 {object} serializers.OrganizationSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[org:me:write]
+// @Router /organizations/{uuid} [patch]
+func (controller OrganizationController) UpdateOrganization(c *gin.Context) {
+	membership := controller.readMembership(c, models.MembershipAdmin)
+	if membership == nil {
+		return
+	}
+
+	var input validators.OrganizationUpdateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	organization := membership.Organization
+	controller.organizationService.Update(&organization, input)
+	c.JSON(http.StatusOK, serializers.NewOrganizationSerializer(organization))
+}
+
+// @Summary Delete an organization
+// @Description deletes an organization. Requires to be owner of the
+// @Description organization. Its apps are kept without organization.
+// @ID organization-delete
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Organization uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[org:me:write]
+// @Router /organizations/{uuid} [delete]
+func (controller OrganizationController) DeleteOrganization(c *gin.Context) {
+	membership := controller.readMembership(c, models.MembershipOwner)
+	if membership == nil {
+		return
+	}
+
+	if err := controller.organizationService.Delete(membership.Organization); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Get organization members
+// @Description get the members of an organization the user is member of
+// @ID organization-members
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Organization uuid"
+// @Param page query int false "cursor's page"
+// @Param limit query int false "cursor's limit"
+// @Success 200 {object} serializers.PaginatedMembershipsSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[org:me:read]
+// @Router /organizations/{uuid}/members [get]
+func (controller OrganizationController) ListMembers(c *gin.Context) {
+	membership := controller.readMembership(c, models.MembershipMember)
+	if membership == nil {
+		return
+	}
+
+	var input validators.PaginationQuery
+	if err := c.ShouldBindQuery(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	cursor := helpers.NewCursor(input.Page, input.PageSize)
+	memberships := controller.organizationService.ListMembers(membership.Organization, &cursor)
+	c.JSON(http.StatusOK, serializers.NewPaginatedMembershipsSerializer(memberships, cursor))
+}
+
+// @Summary Change the role of a member
+// @Description changes the role of an organization member. Requires to be
+// @Description owner of the organization, and the organization must keep
+// @Description at least one owner.
+// @ID organization-member-role
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Organization uuid"
+// @Param user path string true "Member uuid"
+// @Param role body validators.OrganizationMemberRoleData true "New role of the member"
+// @Success 200 {object} serializers.MembershipSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Failure 409 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[org:me:write]
+// @Router /organizations/{uuid}/members/{user} [put]
+func (controller OrganizationController) SetMemberRole(c *gin.Context) {
+	membership := controller.readMembership(c, models.MembershipOwner)
+	if membership == nil {
+		return
+	}
+
+	member := controller.readMember(c, membership.Organization)
+	if member == nil {
+		return
+	}
+
+	var input validators.OrganizationMemberRoleData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	member, err := controller.organizationService.SetMemberRole(membership.Organization, member.User, input.Role)
+	if err != nil {
+		abortMembershipError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewMembershipSerializer(*member))
+}
+
+// @Summary Remove a member
+// @Description removes a member from an organization. Members can leave the
+// @Description organization, and owners and admins can remove members who
+// @Description are not owners. The organization must keep at least one owner.
+// @ID organization-member-remove
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Organization uuid"
+// @Param user path string true "Member uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Failure 409 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[org:me:write]
+// @Router /organizations/{uuid}/members/{user} [delete]
+func (controller OrganizationController) RemoveMember(c *gin.Context) {
+	membership := controller.readMembership(c, models.MembershipMember)
+	if membership == nil {
+		return
+	}
+
+	member := controller.readMember(c, membership.Organization)
+	if member == nil {
+		return
+	}
+
+	isSelf := member.UserID == membership.UserID
+	canRemove := models.MembershipRoleAtLeast(membership.Role, models.MembershipAdmin) &&
+		(member.Role != models.MembershipOwner || membership.Role == models.MembershipOwner)
+	if !isSelf && !canRemove {
+		helpers.AbortWithStatus(c, http.StatusForbidden, OrganizationAccessDeniedError{})
+		return
+	}
+
+	if err := controller.organizationService.RemoveMember(membership.Organization, member.User); err != nil {
+		abortMembershipError(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Invite to an organization
+// @Description invites an email to join the organization. Requires to be
+// @Description owner or admin of the organization, and only owners can
+// @Description invite new owners.
+// @ID organization-invite
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Organization uuid"
+// @Param invitation body validators.OrganizationInviteData true "Invitation data"
+// @Success 201 {object} serializers.InvitationSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[org:me:write]
+// @Router /organizations/{uuid}/invitations [post]
+func (controller OrganizationController) Invite(c *gin.Context) {
+	membership := controller.readMembership(c, models.MembershipAdmin)
+	if membership == nil {
+		return
+	}
+
+	var input validators.OrganizationInviteData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if input.Role == models.MembershipOwner && membership.Role != models.MembershipOwner {
+		helpers.AbortWithStatus(c, http.StatusForbidden, OrganizationAccessDeniedError{})
+		return
+	}
+
+	invitation, err := controller.organizationService.Invite(membership.Organization, membership.User, input)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, serializers.NewInvitationSerializer(*invitation))
+}
+
+// @Summary Accept an invitation
+// @Description accepts an invitation to join the organization with the code
+// @Description mailed to the user. The invitation must have been sent to the
+// @Description email of the user.
+// @ID organization-accept-invitation
+// @Tags Organization
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Organization uuid"
+// @Param code body validators.OrganizationAcceptInvitationData true "Invitation code"
+// @Success 201 {object} serializers.MembershipSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[org:me:write]
+// @Router /organizations/{uuid}/invitations/accept [post]
+func (controller OrganizationController) AcceptInvitation(c *gin.Context) {
+	organization := controller.readOrganization(c)
+	if organization == nil {
+		return
+	}
+
+	var input validators.OrganizationAcceptInvitationData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	membership, err := controller.organizationService.AcceptInvitation(*organization, input.Code, *user)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusCreated, serializers.NewMembershipSerializer(*membership))
+}
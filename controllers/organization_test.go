@@ -0,0 +1,412 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/services"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+type setMemberRoleRecorder struct {
+	user models.User
+	role string
+}
+
+type removeMemberRecorder struct {
+	user models.User
+}
+
+type inviteRecorder struct {
+	data validators.OrganizationInviteData
+}
+
+type mockOrganizationService struct {
+	setMemberRoleRecorder *setMemberRoleRecorder
+	removeMemberRecorder  *removeMemberRecorder
+	inviteRecorder        *inviteRecorder
+
+	role            string
+	memberRole      string
+	readError       error
+	membershipError error
+	memberError     error
+}
+
+func (service *mockOrganizationService) Create(data validators.OrganizationCreateData, owner models.User) (*models.Organization, error) {
+	organization := models.NewOrganization(data.Name)
+	return &organization, nil
+}
+
+func (service *mockOrganizationService) Read(uuid uuid.UUID) (*models.Organization, error) {
+	return &models.Organization{UUID: uuid}, service.readError
+}
+
+func (service *mockOrganizationService) Update(organization *models.Organization, data validators.OrganizationUpdateData) {
+	organization.Name = data.Name
+}
+
+func (service *mockOrganizationService) Delete(organization models.Organization) error {
+	return nil
+}
+
+func (service *mockOrganizationService) List(user models.User, cursor *helpers.Cursor) []models.Organization {
+	return []models.Organization{}
+}
+
+// The first membership read is the one of the authorized user, and the
+// following ones are the ones of the members given in the uri
+func (service *mockOrganizationService) Membership(organization models.Organization, user models.User) (*models.Membership, error) {
+	if service.role != "" {
+		role := service.role
+		service.role = ""
+		return &models.Membership{Role: role, Organization: organization, User: user, UserID: 1}, service.membershipError
+	}
+	return &models.Membership{Role: service.memberRole, Organization: organization, User: user, UserID: 2}, service.memberError
+}
+
+func (service *mockOrganizationService) ListMembers(organization models.Organization, cursor *helpers.Cursor) []models.Membership {
+	return []models.Membership{}
+}
+
+func (service *mockOrganizationService) SetMemberRole(organization models.Organization, user models.User, role string) (*models.Membership, error) {
+	*service.setMemberRoleRecorder = setMemberRoleRecorder{user, role}
+	return &models.Membership{Role: role, User: user}, nil
+}
+
+func (service *mockOrganizationService) RemoveMember(organization models.Organization, user models.User) error {
+	*service.removeMemberRecorder = removeMemberRecorder{user}
+	return nil
+}
+
+func (service *mockOrganizationService) Invite(organization models.Organization, invitedBy models.User, data validators.OrganizationInviteData) (*models.Invitation, error) {
+	*service.inviteRecorder = inviteRecorder{data}
+	invitation := models.NewInvitation(organization, invitedBy, data.Email, data.Role, 0)
+	return &invitation, nil
+}
+
+func (service *mockOrganizationService) AcceptInvitation(organization models.Organization, secret string, user models.User) (*models.Membership, error) {
+	if secret != "valid" {
+		return nil, services.InvitationNotValidError{}
+	}
+	return &models.Membership{Role: models.MembershipMember, User: user}, nil
+}
+
+func newMockedOrganizationService(role string, readError error, membershipError error, memberError error) mockOrganizationService {
+	return mockOrganizationService{
+		setMemberRoleRecorder: new(setMemberRoleRecorder),
+		removeMemberRecorder:  new(removeMemberRecorder),
+		inviteRecorder:        new(inviteRecorder),
+		role:                  role,
+		memberRole:            models.MembershipMember,
+		readError:             readError,
+		membershipError:       membershipError,
+		memberError:           memberError,
+	}
+}
+
+func setupOrganizationRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	organizationService services.IOrganizationService,
+) *gin.Engine {
+	router := gin.Default()
+	userService := newMockedUserService(nil, nil, nil, nil, nil)
+	RegisterOrganizationRoutes(
+		router, authBearerMiddleware,
+		organizationService, &userService,
+	)
+	return router
+}
+
+func TestOrganizationRead(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test read organization successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipMember, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("GET", fmt.Sprintf("/organizations/%s", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test read organization not member", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipMember, nil, errors.New("Whoops!"), nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("GET", fmt.Sprintf("/organizations/%s", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test read organization wrong uuid", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipMember, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/organizations/invent", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+}
+
+func TestOrganizationUpdate(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test update organization as admin", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipAdmin, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		name := faker.Company().Name()
+		payload, _ := json.Marshal(map[string]interface{}{"name": name})
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("PATCH", fmt.Sprintf("/organizations/%s", uuid), bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test update organization as member", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipMember, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		payload, _ := json.Marshal(map[string]interface{}{"name": "name"})
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("PATCH", fmt.Sprintf("/organizations/%s", uuid), bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test delete organization as admin", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipAdmin, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("DELETE", fmt.Sprintf("/organizations/%s", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test delete organization as owner", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipOwner, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("DELETE", fmt.Sprintf("/organizations/%s", uuid), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+	})
+}
+
+func TestOrganizationMembers(t *testing.T) {
+	assert := require.New(t)
+
+	memberURL := func() string {
+		organization, _ := uuid.NewV4()
+		member, _ := uuid.NewV4()
+		return fmt.Sprintf("/organizations/%s/members/%s", organization, member)
+	}
+
+	t.Run("Test set member role as owner", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipOwner, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		payload, _ := json.Marshal(map[string]interface{}{"role": models.MembershipAdmin})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PUT", memberURL(), bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.Equal(models.MembershipAdmin, organizationService.setMemberRoleRecorder.role)
+	})
+
+	t.Run("Test set member role as admin", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipAdmin, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		payload, _ := json.Marshal(map[string]interface{}{"role": models.MembershipAdmin})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PUT", memberURL(), bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test set member role wrong role", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipOwner, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		payload, _ := json.Marshal(map[string]interface{}{"role": "king"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PUT", memberURL(), bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test remove member as admin", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipAdmin, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", memberURL(), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test remove owner as admin", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipAdmin, nil, nil, nil)
+		organizationService.memberRole = models.MembershipOwner
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", memberURL(), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test remove member as member", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipMember, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", memberURL(), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test remove not a member", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipOwner, nil, nil, errors.New("Whoops!"))
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", memberURL(), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+	})
+}
+
+func TestOrganizationInvitations(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test invite as admin", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipAdmin, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		email := faker.Internet().Email()
+		payload, _ := json.Marshal(map[string]interface{}{"email": email, "role": models.MembershipMember})
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("POST", fmt.Sprintf("/organizations/%s/invitations", uuid), bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusCreated, recorder.Result().StatusCode)
+		assert.Equal(email, organizationService.inviteRecorder.data.Email)
+	})
+
+	t.Run("Test invite owner as admin", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService(models.MembershipAdmin, nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		payload, _ := json.Marshal(map[string]interface{}{"email": faker.Internet().Email(), "role": models.MembershipOwner})
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("POST", fmt.Sprintf("/organizations/%s/invitations", uuid), bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test accept invitation", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService("", nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		payload, _ := json.Marshal(map[string]interface{}{"code": "valid"})
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("POST", fmt.Sprintf("/organizations/%s/invitations/accept", uuid), bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusCreated, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test accept invalid invitation", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		organizationService := newMockedOrganizationService("", nil, nil, nil)
+		router := setupOrganizationRouter(authBearerMiddleware, &organizationService)
+
+		payload, _ := json.Marshal(map[string]interface{}{"code": "invalid"})
+		recorder := httptest.NewRecorder()
+		uuid, _ := uuid.NewV4()
+		request, _ := http.NewRequest("POST", fmt.Sprintf("/organizations/%s/invitations/accept", uuid), bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+}
@@ -0,0 +1,289 @@
+package controllers
+
+import (
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register phone endpoints to the given router
+func RegisterPhoneRoutes(
+	router *gin.Engine,
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	userService services.IUserService,
+	phoneService services.IPhoneService,
+	phoneThrottler security.IThrottler,
+) {
+	controller := PhoneController{
+		userService:    userService,
+		phoneService:   phoneService,
+		throttler:      phoneThrottler,
+		authMiddleware: authBearerMiddleware,
+	}
+
+	publicRoutes := router.Group("")
+	{
+		publicRoutes.POST("/notifications/sms/reset-user-password", controller.UserResetPasswordSMS)
+		publicRoutes.POST("/me/phone/reset-password", controller.ResetMyPasswordByPhone)
+	}
+
+	updateRoutes := router.Group("/me/phone")
+	{
+		scopes := []string{security.ScopeUserWrite}
+		updateRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+		updateRoutes.POST("/code", controller.SendMyPhoneCode)
+		updateRoutes.POST("/confirmation-code", controller.SendMyPhoneConfirmationCode)
+		updateRoutes.POST("/verify", controller.VerifyMyPhone)
+		updateRoutes.PUT("/mfa", controller.SetMyPhoneMFA)
+	}
+}
+
+// Controller for /me/phone endpoints
+type PhoneController struct {
+	userService    services.IUserService
+	phoneService   services.IPhoneService
+	throttler      security.IThrottler
+	authMiddleware middlewares.IAuthBearerMiddleware
+}
+
+// Check if a new code can be sent for the given key. Every code is a paid
+// text message, so the resends are throttled.
+func (controller PhoneController) allowed(c *gin.Context, key string) bool {
+	if !controller.throttler.Allow(strings.ToLower(key)) {
+		helpers.AbortWithStatus(c, http.StatusTooManyRequests, NotificationThrottledError{})
+		return false
+	}
+	return true
+}
+
+// Aborts a failed login. When the user must give the code sent to his
+// phone, the code is sent and the login is answered with 401, so the
+// client can ask for it and retry. When he must accept legal documents,
+// they are answered along with the 403.
+func (controller PhoneController) abortLogin(c *gin.Context, user *models.User, err error) {
+	if abortLegalAcceptance(c, err) {
+		return
+	}
+	if _, required := err.(services.PhoneCodeRequiredError); !required || user == nil {
+		helpers.AbortWithStatus(c, http.StatusForbidden, err)
+		return
+	}
+
+	if controller.allowed(c, user.UUID.String()) {
+		runInBackground(func() {
+			controller.phoneService.SendCode(*user, models.PhoneCodePurposeMFA)
+		})
+		helpers.AbortWithStatus(c, http.StatusUnauthorized, err)
+	}
+}
+
+// Aborts a failed change of the phone. Changes without a valid proof are
+// answered with 403.
+func abortPhoneChange(c *gin.Context, err error) {
+	switch err.(type) {
+	case services.PhoneProofRequiredError, services.PhoneCodeNotValidError:
+		helpers.AbortWithStatus(c, http.StatusForbidden, err)
+	default:
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+	}
+}
+
+// @Summary Send my phone code
+// @Description Sends by SMS the code which verifies my phone
+// @ID me-phone-code
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 429 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:write]
+// @Router /me/phone/code [post]
+func (controller PhoneController) SendMyPhoneCode(c *gin.Context) {
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	if user.Phone == "" {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, services.PhoneNotSetError{})
+		return
+	}
+
+	if !controller.allowed(c, user.UUID.String()) {
+		return
+	}
+
+	if err := controller.phoneService.SendCode(*user, models.PhoneCodePurposeVerify); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Send my phone confirmation code
+// @Description Sends by SMS to my verified phone the code which confirms
+// @Description that I change it or turn it off as second factor
+// @ID me-phone-confirmation-code
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 429 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:write]
+// @Router /me/phone/confirmation-code [post]
+func (controller PhoneController) SendMyPhoneConfirmationCode(c *gin.Context) {
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	if !user.PhoneVerified {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, services.PhoneNotVerifiedError{})
+		return
+	}
+
+	if !controller.allowed(c, user.UUID.String()) {
+		return
+	}
+
+	if err := controller.phoneService.SendCode(*user, models.PhoneCodePurposeConfirm); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Verify my phone
+// @Description Verifies my phone with the code sent to it by SMS
+// @ID me-phone-verify
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param data body validators.PhoneCodeData true "Code sent to my phone"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:write]
+// @Router /me/phone/verify [post]
+func (controller PhoneController) VerifyMyPhone(c *gin.Context) {
+	if abortImpersonated(c, controller.authMiddleware) {
+		return
+	}
+	var input validators.PhoneCodeData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	if err := controller.phoneService.Verify(user, input.Code, helpers.NewClientInfo(c)); err != nil {
+		helpers.AbortWithStatus(c, http.StatusForbidden, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Set my phone as second factor
+// @Description Turns on or off my verified phone as second factor. When it
+// @Description is on, the logins must also give the code sent to it. Turning
+// @Description it off takes a confirmation code sent to it or my password.
+// @ID me-phone-mfa
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param data body validators.PhoneMFAData true "Whether the phone is used as second factor"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[user:me:write]
+// @Router /me/phone/mfa [put]
+func (controller PhoneController) SetMyPhoneMFA(c *gin.Context) {
+	if abortImpersonated(c, controller.authMiddleware) {
+		return
+	}
+	var input validators.PhoneMFAData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	if err := controller.phoneService.SetMFA(user, *input.Enabled, input.PhoneProofData, helpers.NewClientInfo(c)); err != nil {
+		abortPhoneChange(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Sends reset password SMS
+// @Description Sends by SMS a code to reset the password to the verified
+// @Description phone of the user. The response is the same whether the
+// @Description email is registered or not.
+// @ID notifications-sms-reset-password
+// @Tags Notification
+// @Accept json
+// @Produce json
+// @Param data body validators.PhoneRecoveryData true "sends the reset password SMS"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 429 {object} helpers.HTTPError
+// @Router /notifications/sms/reset-user-password [post]
+func (controller PhoneController) UserResetPasswordSMS(c *gin.Context) {
+	var input validators.PhoneRecoveryData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !controller.allowed(c, input.Email) {
+		return
+	}
+
+	user, err := controller.userService.ReadByEmail(input.Email)
+	if err == nil && user.PhoneVerified {
+		runInBackground(func() {
+			controller.phoneService.SendCode(*user, models.PhoneCodePurposeRecovery)
+		})
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary Reset my password by phone
+// @Description Reset my password with the code sent by SMS to my phone
+// @ID me-phone-reset-password
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param data body validators.PhoneResetPasswordData true "Email, reset code and new password"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Router /me/phone/reset-password [post]
+func (controller PhoneController) ResetMyPasswordByPhone(c *gin.Context) {
+	var input validators.PhoneResetPasswordData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := controller.userService.ReadByEmail(input.Email)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusForbidden, services.PhoneCodeNotValidError{})
+		return
+	}
+
+	if err := controller.phoneService.Consume(*user, models.PhoneCodePurposeRecovery, input.Code); err != nil {
+		helpers.AbortWithStatus(c, http.StatusForbidden, err)
+		return
+	}
+
+	if err := controller.userService.ResetPassword(user, input.Password, helpers.NewClientInfo(c)); err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
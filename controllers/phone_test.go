@@ -0,0 +1,409 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/services"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type phoneCodeRecorder struct {
+	user    models.User
+	purpose string
+	code    string
+}
+
+type mockPhoneService struct {
+	sendRecorder    *phoneCodeRecorder
+	consumeRecorder *phoneCodeRecorder
+	mfaRecorder     *bool
+
+	err error
+}
+
+func newMockedPhoneService(err error) *mockPhoneService {
+	return &mockPhoneService{
+		sendRecorder:    new(phoneCodeRecorder),
+		consumeRecorder: new(phoneCodeRecorder),
+		mfaRecorder:     new(bool),
+		err:             err,
+	}
+}
+
+func (service *mockPhoneService) SendCode(user models.User, purpose string) error {
+	*service.sendRecorder = phoneCodeRecorder{user: user, purpose: purpose}
+	return service.err
+}
+
+func (service *mockPhoneService) Consume(user models.User, purpose string, code string) error {
+	*service.consumeRecorder = phoneCodeRecorder{user, purpose, code}
+	return service.err
+}
+
+func (service *mockPhoneService) Verify(user *models.User, code string, client helpers.ClientInfo) error {
+	*service.consumeRecorder = phoneCodeRecorder{*user, models.PhoneCodePurposeVerify, code}
+	if service.err == nil {
+		user.PhoneVerified = true
+	}
+	return service.err
+}
+
+func (service *mockPhoneService) SetMFA(user *models.User, enabled bool, proof validators.PhoneProofData, client helpers.ClientInfo) error {
+	*service.mfaRecorder = enabled
+	return service.err
+}
+
+func setupPhoneRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	userService services.IUserService,
+	phoneService services.IPhoneService,
+	throttler security.IThrottler,
+) *gin.Engine {
+	router := gin.Default()
+	RegisterPhoneRoutes(router, authBearerMiddleware, userService, phoneService, throttler)
+	return router
+}
+
+func TestSendMyPhoneCode(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test send code successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		user.Phone = "+34600000000"
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(&user), &userService, phoneService, newMockedThrottler(true))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/phone/code", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal(user.Phone, phoneService.sendRecorder.user.Phone)
+		assert.Equal(models.PhoneCodePurposeVerify, phoneService.sendRecorder.purpose)
+	})
+
+	t.Run("Test send code without phone", func(t *testing.T) {
+		user := tests.UserFactory()
+		user.Phone = ""
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(&user), &userService, phoneService, newMockedThrottler(true))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/phone/code", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+		assert.Equal("", phoneService.sendRecorder.purpose)
+	})
+
+	t.Run("Test send code throttled", func(t *testing.T) {
+		user := tests.UserFactory()
+		user.Phone = "+34600000000"
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		throttler := newMockedThrottler(false)
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(&user), &userService, phoneService, throttler)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/phone/code", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusTooManyRequests, recorder.Result().StatusCode)
+		assert.Equal([]string{user.UUID.String()}, throttler.keys)
+		assert.Equal("", phoneService.sendRecorder.purpose)
+	})
+}
+
+func TestVerifyMyPhone(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test verify phone successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(&user), &userService, phoneService, newMockedThrottler(true))
+
+		payload, _ := json.Marshal(map[string]string{"code": "123456"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/phone/verify", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal("123456", phoneService.consumeRecorder.code)
+		assert.True(user.PhoneVerified)
+	})
+
+	t.Run("Test verify phone wrong code", func(t *testing.T) {
+		user := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(services.PhoneCodeNotValidError{})
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(&user), &userService, phoneService, newMockedThrottler(true))
+
+		payload, _ := json.Marshal(map[string]string{"code": "123456"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/phone/verify", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+		assert.False(user.PhoneVerified)
+	})
+
+	t.Run("Test verify phone wrong payload", func(t *testing.T) {
+		user := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(&user), &userService, phoneService, newMockedThrottler(true))
+
+		payload, _ := json.Marshal(map[string]string{"code": "12ab"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/phone/verify", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+		assert.Equal("", phoneService.consumeRecorder.code)
+	})
+}
+
+func TestSendMyPhoneConfirmationCode(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test send confirmation code successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		user.Phone = "+34600000000"
+		user.PhoneVerified = true
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(&user), &userService, phoneService, newMockedThrottler(true))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/phone/confirmation-code", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal(models.PhoneCodePurposeConfirm, phoneService.sendRecorder.purpose)
+	})
+
+	t.Run("Test send confirmation code to unverified phone", func(t *testing.T) {
+		user := tests.UserFactory()
+		user.Phone = "+34600000000"
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(&user), &userService, phoneService, newMockedThrottler(true))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/phone/confirmation-code", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+		assert.Equal("", phoneService.sendRecorder.purpose)
+	})
+}
+
+func TestSetMyPhoneMFA(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test set phone mfa successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(&user), &userService, phoneService, newMockedThrottler(true))
+
+		payload, _ := json.Marshal(map[string]bool{"enabled": true})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PUT", "/me/phone/mfa", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.True(*phoneService.mfaRecorder)
+	})
+
+	t.Run("Test set phone mfa not verified", func(t *testing.T) {
+		user := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(services.PhoneNotVerifiedError{})
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(&user), &userService, phoneService, newMockedThrottler(true))
+
+		payload, _ := json.Marshal(map[string]bool{"enabled": true})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PUT", "/me/phone/mfa", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test disable phone mfa without proof", func(t *testing.T) {
+		user := tests.UserFactory()
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(services.PhoneProofRequiredError{})
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(&user), &userService, phoneService, newMockedThrottler(true))
+
+		payload, _ := json.Marshal(map[string]bool{"enabled": false})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("PUT", "/me/phone/mfa", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+}
+
+func TestUserResetPasswordSMS(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test unverified phones are not sent any code", func(t *testing.T) {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(nil), &userService, phoneService, newMockedThrottler(true))
+
+		payload, _ := json.Marshal(map[string]string{"email": "johndoe@example.com"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/notifications/sms/reset-user-password", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal("johndoe@example.com", userService.readByEmailRecorder.email)
+		assert.Equal("", phoneService.sendRecorder.purpose)
+	})
+
+	t.Run("Test reset password sms throttled", func(t *testing.T) {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		throttler := newMockedThrottler(false)
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(nil), &userService, newMockedPhoneService(nil), throttler)
+
+		payload, _ := json.Marshal(map[string]string{"email": "JohnDoe@example.com"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/notifications/sms/reset-user-password", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusTooManyRequests, recorder.Result().StatusCode)
+		assert.Equal([]string{"johndoe@example.com"}, throttler.keys)
+		assert.Equal("", userService.readByEmailRecorder.email)
+	})
+}
+
+func TestResetMyPasswordByPhone(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test reset password by phone successfully", func(t *testing.T) {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(nil), &userService, phoneService, newMockedThrottler(true))
+
+		payload, _ := json.Marshal(map[string]string{
+			"email": "johndoe@example.com", "code": "123456", "password": "My@appPassw0rd",
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/phone/reset-password", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal(models.PhoneCodePurposeRecovery, phoneService.consumeRecorder.purpose)
+		assert.Equal("123456", phoneService.consumeRecorder.code)
+		assert.Equal("My@appPassw0rd", userService.resetPasswordRecorder.password)
+	})
+
+	t.Run("Test reset password by phone wrong code", func(t *testing.T) {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(services.PhoneCodeNotValidError{})
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(nil), &userService, phoneService, newMockedThrottler(true))
+
+		payload, _ := json.Marshal(map[string]string{
+			"email": "johndoe@example.com", "code": "123456", "password": "My@appPassw0rd",
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/phone/reset-password", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+		assert.Equal("", userService.resetPasswordRecorder.password)
+	})
+
+	t.Run("Test reset password by phone unknown email", func(t *testing.T) {
+		userService := newMockedUserService(nil, errors.New("not found"), nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupPhoneRouter(newMockAuthBearerMiddleware(nil), &userService, phoneService, newMockedThrottler(true))
+
+		payload, _ := json.Marshal(map[string]string{
+			"email": "johndoe@example.com", "code": "123456", "password": "My@appPassw0rd",
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/me/phone/reset-password", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+		assert.Equal("", phoneService.consumeRecorder.code)
+	})
+}
+
+func TestLoginPhoneMFA(t *testing.T) {
+	assert := require.New(t)
+
+	setupRouter := func(authService services.IAuthService, phoneService services.IPhoneService, throttler security.IThrottler) *gin.Engine {
+		router := gin.Default()
+		RegisterAuthRoutes(router, authService, newMockedRoleService(nil, nil), phoneService, throttler)
+		return router
+	}
+
+	t.Run("Test login asks for the phone code", func(t *testing.T) {
+		user := tests.UserFactory()
+		authService := newMockedAuthService(&user, services.PhoneCodeRequiredError{}, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupRouter(authService, phoneService, newMockedThrottler(true))
+
+		payload, _ := json.Marshal(map[string]string{"email": user.Email, "password": user.Password})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusUnauthorized, recorder.Result().StatusCode)
+		assert.Equal(user.Email, phoneService.sendRecorder.user.Email)
+		assert.Equal(models.PhoneCodePurposeMFA, phoneService.sendRecorder.purpose)
+		assert.Equal("", authService.startSessionRecorder.user.Email)
+	})
+
+	t.Run("Test login phone code resend throttled", func(t *testing.T) {
+		user := tests.UserFactory()
+		authService := newMockedAuthService(&user, services.PhoneCodeRequiredError{}, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupRouter(authService, phoneService, newMockedThrottler(false))
+
+		payload, _ := json.Marshal(map[string]string{"email": user.Email, "password": user.Password})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusTooManyRequests, recorder.Result().StatusCode)
+		assert.Equal("", phoneService.sendRecorder.purpose)
+	})
+
+	t.Run("Test login wrong phone code", func(t *testing.T) {
+		user := tests.UserFactory()
+		authService := newMockedAuthService(nil, services.PhoneCodeNotValidError{}, nil, nil, nil, nil)
+		phoneService := newMockedPhoneService(nil)
+		router := setupRouter(authService, phoneService, newMockedThrottler(true))
+
+		payload, _ := json.Marshal(map[string]string{
+			"email": user.Email, "password": user.Password, "phone_code": "123456",
+		})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+		assert.Equal("123456", authService.authenticateRecorder.credentials.PhoneCode)
+		assert.Equal("", phoneService.sendRecorder.purpose)
+	})
+}
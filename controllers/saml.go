@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Content type of the SAML metadata
+const samlMetadataContentType = "application/samlmetadata+xml"
+
+// Register SAML identity provider endpoints to the given router
+func RegisterSAMLRoutes(
+	router *gin.Engine,
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	samlService services.ISAMLService,
+	webhookService services.IWebhookService,
+) {
+	controller := SAMLController{
+		samlService:    samlService,
+		webhookService: webhookService,
+		authMiddleware: authBearerMiddleware,
+	}
+
+	publicRoutes := router.Group("/saml")
+	{
+		publicRoutes.GET("/metadata", controller.SAMLMetadata)
+		publicRoutes.GET("/sso", controller.SAMLRedirectSSO)
+		publicRoutes.POST("/sso", controller.SAMLPostSSO)
+	}
+
+	authorizeRoutes := router.Group("/saml")
+	{
+		scopes := []string{security.ScopeUserAuthorizeApp}
+		authorizeRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		authorizeRoutes.POST("/authorize", controller.SAMLAuthorize)
+	}
+}
+
+// Controller for /saml endpoints
+type SAMLController struct {
+	samlService    services.ISAMLService
+	webhookService services.IWebhookService
+	authMiddleware middlewares.IAuthBearerMiddleware
+}
+
+// @Summary SAML metadata
+// @Description Retrieves the metadata the SAML apps are configured with, which publishes the signing certificate
+// @ID saml-metadata
+// @Tags SAML
+// @Produce xml
+// @Success 200
+// @Failure 500 {object} helpers.HTTPError
+// @Router /saml/metadata [get]
+func (controller SAMLController) SAMLMetadata(c *gin.Context) {
+	metadata, err := controller.samlService.Metadata()
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Data(http.StatusOK, samlMetadataContentType, metadata)
+}
+
+// Sends the browser to the login page with the given request once it has
+// been checked
+func (controller SAMLController) startSSO(c *gin.Context, input validators.SAMLRequestData, deflated bool) {
+	request, err := controller.samlService.ReadRequest(input, deflated)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.Redirect(http.StatusFound, controller.samlService.LoginURL(*request))
+}
+
+// @Summary SAML single sign on through the redirect binding
+// @Description Checks the authentication request of a SAML app and sends the browser to the login page
+// @ID saml-sso-redirect
+// @Tags SAML
+// @Param SAMLRequest query string true "Deflated and base64 encoded authentication request"
+// @Param RelayState query string false "State the app gets back along with the response"
+// @Success 302
+// @Failure 400 {object} helpers.HTTPError
+// @Router /saml/sso [get]
+func (controller SAMLController) SAMLRedirectSSO(c *gin.Context) {
+	var input validators.SAMLRequestData
+	if err := c.ShouldBindQuery(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	controller.startSSO(c, input, true)
+}
+
+// @Summary SAML single sign on through the POST binding
+// @Description Checks the authentication request of a SAML app and sends the browser to the login page
+// @ID saml-sso-post
+// @Tags SAML
+// @Accept application/x-www-form-urlencoded
+// @Param SAMLRequest formData string true "Base64 encoded authentication request"
+// @Param RelayState formData string false "State the app gets back along with the response"
+// @Success 302
+// @Failure 400 {object} helpers.HTTPError
+// @Router /saml/sso [post]
+func (controller SAMLController) SAMLPostSSO(c *gin.Context) {
+	var input validators.SAMLRequestData
+	if err := c.ShouldBind(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	controller.startSSO(c, input, false)
+}
+
+// @Summary Answer a SAML authentication request
+// @Description Issues the signed response to the authentication request the login page got, which the browser must post to the app.
+// @Description The mandatory legal documents the user has not accepted yet are answered with a 403, as in the oauth authorization.
+// @ID saml-authorize
+// @Tags SAML
+// @Accept json
+// @Produce json
+// @Param data body validators.SAMLRequestData true "Authentication request"
+// @Security OAuth2AccessCode[user:me:authorized-app]
+// @Success 200 {object} serializers.SAMLResponseSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Router /saml/authorize [post]
+func (controller SAMLController) SAMLAuthorize(c *gin.Context) {
+	if abortImpersonated(c, controller.authMiddleware) {
+		return
+	}
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	var input validators.SAMLRequestData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	request, err := controller.samlService.ReadRequest(input, false)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	response, err := controller.samlService.Respond(
+		*request, *user,
+		helpers.NewClientInfo(c), controller.authMiddleware.GetAuthorizedSession(c),
+	)
+	if err != nil {
+		if abortLegalAcceptance(c, err) {
+			return
+		}
+		status := http.StatusBadRequest
+		if _, restricted := err.(services.AppAgeRestrictedError); restricted {
+			status = http.StatusForbidden
+		}
+		helpers.AbortWithStatus(c, status, err)
+		return
+	}
+
+	runInBackground(func() {
+		controller.webhookService.Dispatch(models.WebhookEventAppConnected, *user, &request.App)
+	})
+
+	c.JSON(http.StatusOK, serializers.NewSAMLResponseSerializer(*response))
+}
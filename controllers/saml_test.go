@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type samlRecorder struct {
+	input    validators.SAMLRequestData
+	deflated bool
+	user     models.User
+	parent   uuid.UUID
+}
+
+type mockSAMLService struct {
+	recorder *samlRecorder
+	app      models.App
+	err      error
+}
+
+func newMockedSAMLService(err error) *mockSAMLService {
+	app := tests.AppFactory()
+	app.Kind = models.AppKindSAML
+	return &mockSAMLService{recorder: new(samlRecorder), app: app, err: err}
+}
+
+func (service *mockSAMLService) Metadata() ([]byte, error) {
+	return []byte("<md:EntityDescriptor/>"), nil
+}
+
+func (service *mockSAMLService) ReadRequest(data validators.SAMLRequestData, deflated bool) (*services.SAMLRequest, error) {
+	service.recorder.input = data
+	service.recorder.deflated = deflated
+	if service.err != nil {
+		return nil, service.err
+	}
+	return &services.SAMLRequest{
+		ID:         "_request",
+		App:        service.app,
+		ACSURL:     service.app.RedirectUrls[0],
+		RelayState: data.RelayState,
+		Encoded:    "cmVxdWVzdA==",
+	}, nil
+}
+
+func (service *mockSAMLService) LoginURL(request services.SAMLRequest) string {
+	return "https://gandalf.test/login?SAMLRequest=" + url.QueryEscape(request.Encoded)
+}
+
+func (service *mockSAMLService) Respond(request services.SAMLRequest, user models.User, client helpers.ClientInfo, parent uuid.UUID) (*services.SAMLResponse, error) {
+	service.recorder.user = user
+	service.recorder.parent = parent
+	return &services.SAMLResponse{ACSURL: request.ACSURL, Response: "cmVzcG9uc2U=", RelayState: request.RelayState}, nil
+}
+
+func setupSAMLRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	samlService services.ISAMLService,
+	webhookService services.IWebhookService,
+) *gin.Engine {
+	router := gin.Default()
+	RegisterSAMLRoutes(router, authBearerMiddleware, samlService, webhookService)
+	return router
+}
+
+func TestSAMLMetadata(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test metadata is served as XML", func(t *testing.T) {
+		router := setupSAMLRouter(newMockAuthBearerMiddleware(nil), newMockedSAMLService(nil), newMockedWebhookService(nil, nil))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/saml/metadata", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(http.StatusOK, w.Code)
+		assert.Equal(samlMetadataContentType, w.Header().Get("Content-Type"))
+		assert.Equal("<md:EntityDescriptor/>", w.Body.String())
+	})
+}
+
+func TestSAMLSSO(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test redirect binding requests are sent to the login page", func(t *testing.T) {
+		samlService := newMockedSAMLService(nil)
+		router := setupSAMLRouter(newMockAuthBearerMiddleware(nil), samlService, newMockedWebhookService(nil, nil))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/saml/sso?SAMLRequest=ZGVmbGF0ZWQ%3D&RelayState=%2Fhome", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(http.StatusFound, w.Code)
+		assert.True(strings.HasPrefix(w.Header().Get("Location"), "https://gandalf.test/login?SAMLRequest="))
+		assert.True(samlService.recorder.deflated)
+		assert.Equal("ZGVmbGF0ZWQ=", samlService.recorder.input.SAMLRequest)
+		assert.Equal("/home", samlService.recorder.input.RelayState)
+	})
+
+	t.Run("Test POST binding requests are sent to the login page", func(t *testing.T) {
+		samlService := newMockedSAMLService(nil)
+		router := setupSAMLRouter(newMockAuthBearerMiddleware(nil), samlService, newMockedWebhookService(nil, nil))
+		form := url.Values{"SAMLRequest": {"cmVxdWVzdA=="}, "RelayState": {"/home"}}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/saml/sso", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(http.StatusFound, w.Code)
+		assert.False(samlService.recorder.deflated)
+		assert.Equal("cmVxdWVzdA==", samlService.recorder.input.SAMLRequest)
+	})
+
+	t.Run("Test invalid requests are rejected", func(t *testing.T) {
+		samlService := newMockedSAMLService(services.SAMLRequestError{})
+		router := setupSAMLRouter(newMockAuthBearerMiddleware(nil), samlService, newMockedWebhookService(nil, nil))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/saml/sso?SAMLRequest=Zm9yZ2Vk", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Test missing requests are rejected", func(t *testing.T) {
+		router := setupSAMLRouter(newMockAuthBearerMiddleware(nil), newMockedSAMLService(nil), newMockedWebhookService(nil, nil))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/saml/sso", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestSAMLAuthorize(t *testing.T) {
+	assert := require.New(t)
+	runInBackground = func(task func()) { task() }
+
+	t.Run("Test signed response is returned for the logged in user", func(t *testing.T) {
+		user := tests.UserFactory()
+		authBearerMiddleware := newMockAuthBearerMiddleware(&user)
+		samlService := newMockedSAMLService(nil)
+		webhookService := newMockedWebhookService(nil, nil)
+		router := setupSAMLRouter(authBearerMiddleware, samlService, webhookService)
+		body, _ := json.Marshal(validators.SAMLRequestData{SAMLRequest: "cmVxdWVzdA==", RelayState: "/home"})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/saml/authorize", bytes.NewBuffer(body))
+		router.ServeHTTP(w, req)
+
+		var response serializers.SAMLResponseSerializer
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, w.Code)
+		assert.Equal([]string{security.ScopeUserAuthorizeApp}, *authBearerMiddleware.requestedScopes)
+		assert.Equal(samlService.app.RedirectUrls[0], response.ACSURL)
+		assert.Equal("cmVzcG9uc2U=", response.SAMLResponse)
+		assert.Equal("/home", response.RelayState)
+		assert.Equal(user.Email, samlService.recorder.user.Email)
+		assert.Equal(authBearerMiddleware.authorizedSession, samlService.recorder.parent)
+		assert.Equal(models.WebhookEventAppConnected, webhookService.dispatchRecorder.event)
+	})
+
+	t.Run("Test invalid requests are not answered", func(t *testing.T) {
+		user := tests.UserFactory()
+		samlService := newMockedSAMLService(services.SAMLRequestError{})
+		router := setupSAMLRouter(newMockAuthBearerMiddleware(&user), samlService, newMockedWebhookService(nil, nil))
+		body, _ := json.Marshal(validators.SAMLRequestData{SAMLRequest: "Zm9yZ2Vk"})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/saml/authorize", bytes.NewBuffer(body))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(http.StatusBadRequest, w.Code)
+		assert.Empty(samlService.recorder.user.Email)
+	})
+
+	t.Run("Test long relay states are rejected", func(t *testing.T) {
+		user := tests.UserFactory()
+		router := setupSAMLRouter(newMockAuthBearerMiddleware(&user), newMockedSAMLService(nil), newMockedWebhookService(nil, nil))
+		body, _ := json.Marshal(validators.SAMLRequestData{SAMLRequest: "cmVxdWVzdA==", RelayState: strings.Repeat("a", 81)})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/saml/authorize", bytes.NewBuffer(body))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,505 @@
+package controllers
+
+import (
+	"gandalf/models"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// Content type of the SCIM responses
+const scimContentType = "application/scim+json"
+
+// Maximum number of resources of a SCIM list page
+const scimMaxResults = 200
+
+// Register SCIM provisioning endpoints to the given router. They are
+// authenticated with the SCIM tokens issued through the admin API instead
+// of user tokens.
+func RegisterSCIMRoutes(router *gin.Engine, scimService services.ISCIMService) {
+	controller := SCIMController{scimService: scimService}
+
+	routes := router.Group("/scim/v2")
+	{
+		routes.Use(controller.authenticate)
+
+		routes.GET("/ServiceProviderConfig", controller.SCIMServiceProviderConfig)
+
+		routes.GET("/Users", controller.ListSCIMUsers)
+		routes.POST("/Users", controller.CreateSCIMUser)
+		routes.GET("/Users/:id", controller.ReadSCIMUser)
+		routes.PUT("/Users/:id", controller.ReplaceSCIMUser)
+		routes.PATCH("/Users/:id", controller.PatchSCIMUser)
+		routes.DELETE("/Users/:id", controller.DeleteSCIMUser)
+
+		routes.GET("/Groups", controller.ListSCIMGroups)
+		routes.POST("/Groups", controller.CreateSCIMGroup)
+		routes.GET("/Groups/:id", controller.ReadSCIMGroup)
+		routes.PUT("/Groups/:id", controller.ReplaceSCIMGroup)
+		routes.PATCH("/Groups/:id", controller.PatchSCIMGroup)
+		routes.DELETE("/Groups/:id", controller.DeleteSCIMGroup)
+	}
+}
+
+// Controller for /scim/v2 endpoints
+type SCIMController struct {
+	scimService services.ISCIMService
+}
+
+// Returns the status and the SCIM error type of the given error
+func scimErrorStatus(err error) (int, string) {
+	switch err.(type) {
+	case services.SCIMFilterError:
+		return http.StatusBadRequest, "invalidFilter"
+	case services.SCIMPathError:
+		return http.StatusBadRequest, "invalidPath"
+	case services.SCIMValueError:
+		return http.StatusBadRequest, "invalidValue"
+	case services.SCIMMutabilityError:
+		return http.StatusBadRequest, "mutability"
+	case services.SCIMConflictError:
+		return http.StatusConflict, "uniqueness"
+	case services.UserNotFoundError, services.RoleNotFoundError:
+		return http.StatusNotFound, ""
+	case services.SCIMTokenNotValidError:
+		return http.StatusUnauthorized, ""
+	}
+	return http.StatusInternalServerError, ""
+}
+
+// Aborts the request with the SCIM error of the given error
+func abortWithSCIMError(c *gin.Context, err error) {
+	status, scimType := scimErrorStatus(err)
+	abortWithSCIMStatus(c, status, scimType, err)
+}
+
+// Aborts the request with a SCIM error of the given status and type
+func abortWithSCIMStatus(c *gin.Context, status int, scimType string, err error) {
+	c.Header("Content-Type", scimContentType)
+	c.AbortWithStatusJSON(status, serializers.NewSCIMErrorSerializer(status, scimType, err))
+}
+
+// Writes the given SCIM resource with the SCIM content type
+func writeSCIM(c *gin.Context, status int, resource interface{}) {
+	c.Header("Content-Type", scimContentType)
+	c.JSON(status, resource)
+}
+
+// Authenticates the request with the SCIM token of its authorization header
+func (controller SCIMController) authenticate(c *gin.Context) {
+	bearer := strings.Split(c.GetHeader("Authorization"), "Bearer ")
+	if len(bearer) < 2 || bearer[1] == "" {
+		abortWithSCIMError(c, services.SCIMTokenNotValidError{})
+		return
+	}
+	if _, err := controller.scimService.Authenticate(bearer[1]); err != nil {
+		abortWithSCIMError(c, err)
+		return
+	}
+}
+
+// Reads the user given in the uri. Returns nil and aborts the request if
+// it does not exist.
+func (controller SCIMController) readUser(c *gin.Context) *models.User {
+	var uri validators.SCIMResourceData
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithSCIMStatus(c, http.StatusNotFound, "", err)
+		return nil
+	}
+
+	user, err := controller.scimService.ReadUser(uuid.FromStringOrNil(uri.ID))
+	if err != nil {
+		abortWithSCIMError(c, err)
+		return nil
+	}
+	return user
+}
+
+// Reads the group given in the uri. Returns nil and aborts the request if
+// it does not exist.
+func (controller SCIMController) readGroup(c *gin.Context) *services.SCIMGroup {
+	var uri validators.SCIMResourceData
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithSCIMStatus(c, http.StatusNotFound, "", err)
+		return nil
+	}
+
+	group, err := controller.scimService.ReadGroup(uuid.FromStringOrNil(uri.ID))
+	if err != nil {
+		abortWithSCIMError(c, err)
+		return nil
+	}
+	return group
+}
+
+// @Summary SCIM service provider configuration
+// @Description Tells the directories the SCIM features that are supported
+// @ID scim-service-provider-config
+// @Tags SCIM
+// @Produce json
+// @Success 200 {object} serializers.SCIMServiceProviderConfigSerializer
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/ServiceProviderConfig [get]
+func (controller SCIMController) SCIMServiceProviderConfig(c *gin.Context) {
+	writeSCIM(c, http.StatusOK, serializers.NewSCIMServiceProviderConfigSerializer(scimMaxResults))
+}
+
+// @Summary List SCIM users
+// @Description List the users that match the given filter. The filters
+// @Description support the eq, ne, co, sw, ew and pr operators over the
+// @Description userName, emails, externalId, name, id and active attributes.
+// @ID scim-users-list
+// @Tags SCIM
+// @Produce json
+// @Param filter query string false "SCIM filter"
+// @Param startIndex query int false "1-based index of the first user"
+// @Param count query int false "Number of users per page"
+// @Success 200 {object} serializers.SCIMListSerializer
+// @Failure 400 {object} serializers.SCIMErrorSerializer
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/Users [get]
+func (controller SCIMController) ListSCIMUsers(c *gin.Context) {
+	var query validators.SCIMListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		abortWithSCIMStatus(c, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+
+	users, total, err := controller.scimService.ListUsers(query)
+	if err != nil {
+		abortWithSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, serializers.NewSCIMUsersSerializer(
+		users, total, query.StartIndex, controller.scimService.BaseURL(),
+	))
+}
+
+// @Summary Create a SCIM user
+// @Description Provisions a new user. Users provisioned without password
+// @Description get a random one and must reset it.
+// @ID scim-users-create
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param data body validators.SCIMUserData true "User data"
+// @Success 201 {object} serializers.SCIMUserSerializer
+// @Failure 400 {object} serializers.SCIMErrorSerializer
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Failure 409 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/Users [post]
+func (controller SCIMController) CreateSCIMUser(c *gin.Context) {
+	var input validators.SCIMUserData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		abortWithSCIMStatus(c, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+
+	user, err := controller.scimService.CreateUser(input)
+	if err != nil {
+		abortWithSCIMError(c, err)
+		return
+	}
+	serialized := serializers.NewSCIMUserSerializer(*user, controller.scimService.BaseURL())
+	c.Header("Location", serialized.Meta.Location)
+	writeSCIM(c, http.StatusCreated, serialized)
+}
+
+// @Summary Read a SCIM user
+// @Description Read a user along with the groups he belongs to
+// @ID scim-users-read
+// @Tags SCIM
+// @Produce json
+// @Param id path string true "User uuid"
+// @Success 200 {object} serializers.SCIMUserSerializer
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Failure 404 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/Users/{id} [get]
+func (controller SCIMController) ReadSCIMUser(c *gin.Context) {
+	user := controller.readUser(c)
+	if user == nil {
+		return
+	}
+	writeSCIM(c, http.StatusOK, serializers.NewSCIMUserSerializer(*user, controller.scimService.BaseURL()))
+}
+
+// @Summary Replace a SCIM user
+// @Description Replaces the attributes of a user. Setting active to false
+// @Description disables the user and revokes all his sessions.
+// @ID scim-users-replace
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param id path string true "User uuid"
+// @Param data body validators.SCIMUserData true "User data"
+// @Success 200 {object} serializers.SCIMUserSerializer
+// @Failure 400 {object} serializers.SCIMErrorSerializer
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Failure 404 {object} serializers.SCIMErrorSerializer
+// @Failure 409 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/Users/{id} [put]
+func (controller SCIMController) ReplaceSCIMUser(c *gin.Context) {
+	user := controller.readUser(c)
+	if user == nil {
+		return
+	}
+
+	var input validators.SCIMUserData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		abortWithSCIMStatus(c, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+
+	if err := controller.scimService.ReplaceUser(user, input); err != nil {
+		abortWithSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, serializers.NewSCIMUserSerializer(*user, controller.scimService.BaseURL()))
+}
+
+// @Summary Patch a SCIM user
+// @Description Applies add, replace and remove operations to a user.
+// @Description Attributes which are not supported are ignored.
+// @ID scim-users-patch
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param id path string true "User uuid"
+// @Param data body validators.SCIMPatchData true "Patch operations"
+// @Success 200 {object} serializers.SCIMUserSerializer
+// @Failure 400 {object} serializers.SCIMErrorSerializer
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Failure 404 {object} serializers.SCIMErrorSerializer
+// @Failure 409 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/Users/{id} [patch]
+func (controller SCIMController) PatchSCIMUser(c *gin.Context) {
+	user := controller.readUser(c)
+	if user == nil {
+		return
+	}
+
+	var input validators.SCIMPatchData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		abortWithSCIMStatus(c, http.StatusBadRequest, "invalidSyntax", err)
+		return
+	}
+
+	if err := controller.scimService.PatchUser(user, input); err != nil {
+		abortWithSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, serializers.NewSCIMUserSerializer(*user, controller.scimService.BaseURL()))
+}
+
+// @Summary Delete a SCIM user
+// @Description Deletes a user through the same flow as the admin API
+// @ID scim-users-delete
+// @Tags SCIM
+// @Param id path string true "User uuid"
+// @Success 204
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Failure 404 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/Users/{id} [delete]
+func (controller SCIMController) DeleteSCIMUser(c *gin.Context) {
+	user := controller.readUser(c)
+	if user == nil {
+		return
+	}
+
+	if err := controller.scimService.DeleteUser(*user); err != nil {
+		abortWithSCIMError(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary List SCIM groups
+// @Description List the groups, which are the roles of gandalf, that match
+// @Description the given filter. Members can be left out with the
+// @Description excludedAttributes parameter.
+// @ID scim-groups-list
+// @Tags SCIM
+// @Produce json
+// @Param filter query string false "SCIM filter"
+// @Param startIndex query int false "1-based index of the first group"
+// @Param count query int false "Number of groups per page"
+// @Param excludedAttributes query string false "Attributes left out of the response"
+// @Success 200 {object} serializers.SCIMListSerializer
+// @Failure 400 {object} serializers.SCIMErrorSerializer
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/Groups [get]
+func (controller SCIMController) ListSCIMGroups(c *gin.Context) {
+	var query validators.SCIMListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		abortWithSCIMStatus(c, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+
+	members := !strings.Contains(strings.ToLower(query.ExcludedAttributes), "members")
+	groups, total, err := controller.scimService.ListGroups(query, members)
+	if err != nil {
+		abortWithSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, serializers.NewSCIMGroupsSerializer(
+		groups, total, query.StartIndex, controller.scimService.BaseURL(),
+	))
+}
+
+// @Summary Create a SCIM group
+// @Description Creates a role without permissions for the group, which
+// @Description staff users can grant permissions to
+// @ID scim-groups-create
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param data body validators.SCIMGroupData true "Group data"
+// @Success 201 {object} serializers.SCIMGroupSerializer
+// @Failure 400 {object} serializers.SCIMErrorSerializer
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Failure 409 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/Groups [post]
+func (controller SCIMController) CreateSCIMGroup(c *gin.Context) {
+	var input validators.SCIMGroupData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		abortWithSCIMStatus(c, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+
+	group, err := controller.scimService.CreateGroup(input)
+	if err != nil {
+		abortWithSCIMError(c, err)
+		return
+	}
+	serialized := serializers.NewSCIMGroupSerializer(*group, controller.scimService.BaseURL())
+	c.Header("Location", serialized.Meta.Location)
+	writeSCIM(c, http.StatusCreated, serialized)
+}
+
+// @Summary Read a SCIM group
+// @Description Read a group along with its members
+// @ID scim-groups-read
+// @Tags SCIM
+// @Produce json
+// @Param id path string true "Group uuid"
+// @Success 200 {object} serializers.SCIMGroupSerializer
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Failure 404 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/Groups/{id} [get]
+func (controller SCIMController) ReadSCIMGroup(c *gin.Context) {
+	group := controller.readGroup(c)
+	if group == nil {
+		return
+	}
+	writeSCIM(c, http.StatusOK, serializers.NewSCIMGroupSerializer(*group, controller.scimService.BaseURL()))
+}
+
+// @Summary Replace a SCIM group
+// @Description Renames a group and replaces its members. The default roles
+// @Description cannot be renamed, and the members of the staff one cannot
+// @Description be changed.
+// @ID scim-groups-replace
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param id path string true "Group uuid"
+// @Param data body validators.SCIMGroupData true "Group data"
+// @Success 200 {object} serializers.SCIMGroupSerializer
+// @Failure 400 {object} serializers.SCIMErrorSerializer
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Failure 404 {object} serializers.SCIMErrorSerializer
+// @Failure 409 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/Groups/{id} [put]
+func (controller SCIMController) ReplaceSCIMGroup(c *gin.Context) {
+	group := controller.readGroup(c)
+	if group == nil {
+		return
+	}
+
+	var input validators.SCIMGroupData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		abortWithSCIMStatus(c, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+
+	if err := controller.scimService.ReplaceGroup(group, input); err != nil {
+		abortWithSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, serializers.NewSCIMGroupSerializer(*group, controller.scimService.BaseURL()))
+}
+
+// @Summary Patch a SCIM group
+// @Description Renames a group or adds, removes and replaces its members
+// @ID scim-groups-patch
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Param id path string true "Group uuid"
+// @Param data body validators.SCIMPatchData true "Patch operations"
+// @Success 200 {object} serializers.SCIMGroupSerializer
+// @Failure 400 {object} serializers.SCIMErrorSerializer
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Failure 404 {object} serializers.SCIMErrorSerializer
+// @Failure 409 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/Groups/{id} [patch]
+func (controller SCIMController) PatchSCIMGroup(c *gin.Context) {
+	group := controller.readGroup(c)
+	if group == nil {
+		return
+	}
+
+	var input validators.SCIMPatchData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		abortWithSCIMStatus(c, http.StatusBadRequest, "invalidSyntax", err)
+		return
+	}
+
+	if err := controller.scimService.PatchGroup(group, input); err != nil {
+		abortWithSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, serializers.NewSCIMGroupSerializer(*group, controller.scimService.BaseURL()))
+}
+
+// @Summary Delete a SCIM group
+// @Description Deletes the role of a group. The default roles cannot be
+// @Description deleted.
+// @ID scim-groups-delete
+// @Tags SCIM
+// @Param id path string true "Group uuid"
+// @Success 204
+// @Failure 400 {object} serializers.SCIMErrorSerializer
+// @Failure 401 {object} serializers.SCIMErrorSerializer
+// @Failure 404 {object} serializers.SCIMErrorSerializer
+// @Security SCIMToken
+// @Router /scim/v2/Groups/{id} [delete]
+func (controller SCIMController) DeleteSCIMGroup(c *gin.Context) {
+	group := controller.readGroup(c)
+	if group == nil {
+		return
+	}
+
+	if err := controller.scimService.DeleteGroup(*group); err != nil {
+		abortWithSCIMError(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,474 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const testSCIMSecret = "scim-secret"
+
+type scimRecorder struct {
+	secret  string
+	query   validators.SCIMListQuery
+	members bool
+	user    validators.SCIMUserData
+	group   validators.SCIMGroupData
+	patch   validators.SCIMPatchData
+	deleted bool
+	token   validators.SCIMTokenCreateData
+}
+
+type mockSCIMService struct {
+	recorder *scimRecorder
+	err      error
+}
+
+func newMockedSCIMService(err error) *mockSCIMService {
+	return &mockSCIMService{recorder: new(scimRecorder), err: err}
+}
+
+func (service *mockSCIMService) BaseURL() string {
+	return "https://gandalf.test/scim/v2"
+}
+
+func (service *mockSCIMService) Authenticate(secret string) (*models.SCIMToken, error) {
+	service.recorder.secret = secret
+	if secret != testSCIMSecret {
+		return nil, services.SCIMTokenNotValidError{}
+	}
+	token := models.SCIMToken{Name: "Workday"}
+	return &token, nil
+}
+
+func (service *mockSCIMService) ListTokens() []models.SCIMToken {
+	return []models.SCIMToken{{Name: "Workday"}}
+}
+
+func (service *mockSCIMService) CreateToken(data validators.SCIMTokenCreateData) (*models.SCIMToken, error) {
+	service.recorder.token = data
+	token := models.NewSCIMToken(data.Name)
+	return &token, service.err
+}
+
+func (service *mockSCIMService) ReadToken(uuid uuid.UUID) (*models.SCIMToken, error) {
+	if service.err != nil {
+		return nil, service.err
+	}
+	token := models.SCIMToken{UUID: uuid, Name: "Workday"}
+	return &token, nil
+}
+
+func (service *mockSCIMService) DeleteToken(token models.SCIMToken) error {
+	service.recorder.deleted = true
+	return nil
+}
+
+func (service *mockSCIMService) ListUsers(query validators.SCIMListQuery) ([]models.User, int64, error) {
+	service.recorder.query = query
+	if service.err != nil {
+		return nil, 0, service.err
+	}
+	return []models.User{tests.UserFactory()}, 3, nil
+}
+
+func (service *mockSCIMService) ReadUser(id uuid.UUID) (*models.User, error) {
+	if service.err != nil {
+		return nil, service.err
+	}
+	user := tests.UserFactory()
+	user.UUID = id
+	return &user, nil
+}
+
+func (service *mockSCIMService) CreateUser(data validators.SCIMUserData) (*models.User, error) {
+	service.recorder.user = data
+	if service.err != nil {
+		return nil, service.err
+	}
+	user := tests.UserFactory()
+	user.UUID = uuid.Must(uuid.NewV4())
+	user.Email = data.UserName
+	return &user, nil
+}
+
+func (service *mockSCIMService) ReplaceUser(user *models.User, data validators.SCIMUserData) error {
+	service.recorder.user = data
+	return nil
+}
+
+func (service *mockSCIMService) PatchUser(user *models.User, data validators.SCIMPatchData) error {
+	service.recorder.patch = data
+	user.Disabled = true
+	return nil
+}
+
+func (service *mockSCIMService) DeleteUser(user models.User) error {
+	service.recorder.deleted = true
+	return nil
+}
+
+func (service *mockSCIMService) ListGroups(query validators.SCIMListQuery, members bool) ([]services.SCIMGroup, int64, error) {
+	service.recorder.query = query
+	service.recorder.members = members
+	return []services.SCIMGroup{{Role: models.NewRole("engineering", "", nil)}}, 1, nil
+}
+
+func (service *mockSCIMService) ReadGroup(id uuid.UUID) (*services.SCIMGroup, error) {
+	role := models.NewRole(models.RoleDeveloper, "", nil)
+	role.UUID = id
+	return &services.SCIMGroup{Role: role}, nil
+}
+
+func (service *mockSCIMService) CreateGroup(data validators.SCIMGroupData) (*services.SCIMGroup, error) {
+	service.recorder.group = data
+	return &services.SCIMGroup{Role: models.NewRole(data.DisplayName, "", nil)}, nil
+}
+
+func (service *mockSCIMService) ReplaceGroup(group *services.SCIMGroup, data validators.SCIMGroupData) error {
+	service.recorder.group = data
+	return service.err
+}
+
+func (service *mockSCIMService) PatchGroup(group *services.SCIMGroup, data validators.SCIMPatchData) error {
+	service.recorder.patch = data
+	return service.err
+}
+
+func (service *mockSCIMService) DeleteGroup(group services.SCIMGroup) error {
+	return service.err
+}
+
+func setupSCIMRouter(scimService services.ISCIMService) *gin.Engine {
+	router := gin.Default()
+	RegisterSCIMRoutes(router, scimService)
+	return router
+}
+
+// Performs a SCIM request authenticated with the test secret
+func performSCIMRequest(router *gin.Engine, method string, path string, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest(method, path, bytes.NewBuffer(payload))
+	request.Header.Set("Authorization", "Bearer "+testSCIMSecret)
+	request.Header.Set("Content-Type", scimContentType)
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestSCIMAuthentication(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test requests without a valid token are rejected", func(t *testing.T) {
+		router := setupSCIMRouter(newMockedSCIMService(nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/scim/v2/Users", nil)
+		request.Header.Set("Authorization", "Bearer forged")
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.SCIMErrorSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusUnauthorized, recorder.Code)
+		assert.Equal(scimContentType, recorder.Header().Get("Content-Type"))
+		assert.Equal([]string{serializers.SCIMSchemaError}, response.Schemas)
+		assert.Equal("401", response.Status)
+	})
+
+	t.Run("Test service provider configuration", func(t *testing.T) {
+		router := setupSCIMRouter(newMockedSCIMService(nil))
+
+		recorder := performSCIMRequest(router, "GET", "/scim/v2/ServiceProviderConfig", nil)
+
+		var response serializers.SCIMServiceProviderConfigSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.True(response.Patch.Supported)
+		assert.False(response.Bulk.Supported)
+	})
+}
+
+func TestSCIMUsers(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list users with a filter", func(t *testing.T) {
+		scimService := newMockedSCIMService(nil)
+		router := setupSCIMRouter(scimService)
+		query := url.Values{"filter": {`userName eq "john@acme.test"`}, "startIndex": {"2"}, "count": {"1"}}
+
+		recorder := performSCIMRequest(router, "GET", "/scim/v2/Users?"+query.Encode(), nil)
+
+		var response map[string]interface{}
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(scimContentType, recorder.Header().Get("Content-Type"))
+		assert.Equal(`userName eq "john@acme.test"`, scimService.recorder.query.Filter)
+		assert.Equal(float64(3), response["totalResults"])
+		assert.Equal(float64(2), response["startIndex"])
+		assert.Equal(float64(1), response["itemsPerPage"])
+		assert.Len(response["Resources"], 1)
+	})
+
+	t.Run("Test invalid filters are rejected", func(t *testing.T) {
+		router := setupSCIMRouter(newMockedSCIMService(services.SCIMFilterError{}))
+
+		recorder := performSCIMRequest(router, "GET", "/scim/v2/Users?filter=password+eq+%22a%22", nil)
+
+		var response serializers.SCIMErrorSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Equal("invalidFilter", response.SCIMType)
+	})
+
+	t.Run("Test create user", func(t *testing.T) {
+		scimService := newMockedSCIMService(nil)
+		router := setupSCIMRouter(scimService)
+
+		recorder := performSCIMRequest(router, "POST", "/scim/v2/Users", map[string]interface{}{
+			"schemas":    []string{serializers.SCIMSchemaUser},
+			"userName":   "john@acme.test",
+			"externalId": "00u1",
+			"name":       map[string]string{"givenName": "John", "familyName": "Doe"},
+			"active":     true,
+		})
+
+		var response serializers.SCIMUserSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusCreated, recorder.Code)
+		assert.Equal("john@acme.test", response.UserName)
+		assert.Equal(response.Meta.Location, recorder.Header().Get("Location"))
+		assert.Equal("00u1", scimService.recorder.user.ExternalID)
+		assert.Equal("John", scimService.recorder.user.Name.GivenName)
+	})
+
+	t.Run("Test create existing user", func(t *testing.T) {
+		router := setupSCIMRouter(newMockedSCIMService(services.SCIMConflictError{}))
+
+		recorder := performSCIMRequest(router, "POST", "/scim/v2/Users", map[string]interface{}{"userName": "john@acme.test"})
+
+		var response serializers.SCIMErrorSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusConflict, recorder.Code)
+		assert.Equal("uniqueness", response.SCIMType)
+	})
+
+	t.Run("Test create user without an email user name", func(t *testing.T) {
+		router := setupSCIMRouter(newMockedSCIMService(nil))
+
+		recorder := performSCIMRequest(router, "POST", "/scim/v2/Users", map[string]interface{}{"userName": "john"})
+
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("Test deactivate user", func(t *testing.T) {
+		scimService := newMockedSCIMService(nil)
+		router := setupSCIMRouter(scimService)
+		id := uuid.Must(uuid.NewV4())
+
+		recorder := performSCIMRequest(router, "PATCH", "/scim/v2/Users/"+id.String(), map[string]interface{}{
+			"schemas":    []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+			"Operations": []map[string]interface{}{{"op": "Replace", "path": "active", "value": "False"}},
+		})
+
+		var response serializers.SCIMUserSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(id, response.ID)
+		assert.False(response.Active)
+		assert.Equal("Replace", scimService.recorder.patch.Operations[0].Op)
+	})
+
+	t.Run("Test patch without operations", func(t *testing.T) {
+		router := setupSCIMRouter(newMockedSCIMService(nil))
+
+		recorder := performSCIMRequest(router, "PATCH", "/scim/v2/Users/"+uuid.Must(uuid.NewV4()).String(), map[string]interface{}{})
+
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("Test read user not found", func(t *testing.T) {
+		router := setupSCIMRouter(newMockedSCIMService(services.UserNotFoundError{}))
+
+		recorder := performSCIMRequest(router, "GET", "/scim/v2/Users/"+uuid.Must(uuid.NewV4()).String(), nil)
+
+		assert.Equal(http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("Test delete user", func(t *testing.T) {
+		scimService := newMockedSCIMService(nil)
+		router := setupSCIMRouter(scimService)
+
+		recorder := performSCIMRequest(router, "DELETE", "/scim/v2/Users/"+uuid.Must(uuid.NewV4()).String(), nil)
+
+		assert.Equal(http.StatusNoContent, recorder.Code)
+		assert.True(scimService.recorder.deleted)
+	})
+}
+
+func TestSCIMGroups(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list groups without members", func(t *testing.T) {
+		scimService := newMockedSCIMService(nil)
+		router := setupSCIMRouter(scimService)
+
+		recorder := performSCIMRequest(router, "GET", "/scim/v2/Groups?excludedAttributes=members", nil)
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.False(scimService.recorder.members)
+	})
+
+	t.Run("Test create group", func(t *testing.T) {
+		scimService := newMockedSCIMService(nil)
+		router := setupSCIMRouter(scimService)
+
+		recorder := performSCIMRequest(router, "POST", "/scim/v2/Groups", map[string]interface{}{
+			"displayName": "engineering",
+			"members":     []map[string]string{{"value": uuid.Must(uuid.NewV4()).String()}},
+		})
+
+		var response serializers.SCIMGroupSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusCreated, recorder.Code)
+		assert.Equal("engineering", response.DisplayName)
+		assert.Len(scimService.recorder.group.Members, 1)
+	})
+
+	t.Run("Test patch group members", func(t *testing.T) {
+		scimService := newMockedSCIMService(nil)
+		router := setupSCIMRouter(scimService)
+
+		recorder := performSCIMRequest(router, "PATCH", "/scim/v2/Groups/"+uuid.Must(uuid.NewV4()).String(), map[string]interface{}{
+			"Operations": []map[string]interface{}{{"op": "add", "path": "members", "value": []map[string]string{{"value": "x"}}}},
+		})
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal("members", scimService.recorder.patch.Operations[0].Path)
+	})
+
+	t.Run("Test patch unsupported group path", func(t *testing.T) {
+		router := setupSCIMRouter(newMockedSCIMService(services.SCIMPathError{}))
+
+		recorder := performSCIMRequest(router, "PATCH", "/scim/v2/Groups/"+uuid.Must(uuid.NewV4()).String(), map[string]interface{}{
+			"Operations": []map[string]interface{}{{"op": "replace", "path": "owners", "value": "x"}},
+		})
+
+		var response serializers.SCIMErrorSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Equal("invalidPath", response.SCIMType)
+	})
+
+	t.Run("Test delete default group", func(t *testing.T) {
+		router := setupSCIMRouter(newMockedSCIMService(services.SCIMMutabilityError{}))
+
+		recorder := performSCIMRequest(router, "DELETE", "/scim/v2/Groups/"+uuid.Must(uuid.NewV4()).String(), nil)
+
+		var response serializers.SCIMErrorSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Equal("mutability", response.SCIMType)
+	})
+}
+
+func setupAdminSCIMRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	adminActionService services.IAdminActionService,
+	scimService services.ISCIMService,
+) *gin.Engine {
+	router := gin.Default()
+	userService := newMockedUserService(nil, nil, nil, nil, nil)
+	RegisterAdminRoutes(
+		router, authBearerMiddleware,
+		newMockedAuthService(nil, nil, nil, nil, nil, nil), &userService,
+		newMockedOutboxService(nil),
+		adminActionService, newMockedRoleService(nil, nil),
+		newMockedSessionService(nil), newMockedAuditService(),
+		newMockedNotificationTemplateService(nil),
+		newMockedIdentityProviderService(nil), scimService,
+		newMockedAttributeService(nil), newMockedLegalService(nil),
+		newMockedPhoneService(nil), newMockedThrottler(true),
+	)
+	return router
+}
+
+func TestAdminSCIMTokens(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test create SCIM token returns its secret", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		scimService := newMockedSCIMService(nil)
+		router := setupAdminSCIMRouter(newMockAuthBearerMiddleware(&staff), adminActionService, scimService)
+
+		payload, _ := json.Marshal(validators.SCIMTokenCreateData{Name: "Workday"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/admin/scim-tokens", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		var response serializers.SCIMTokenSerializer
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		assert.Equal(http.StatusCreated, recorder.Code)
+		assert.NotEmpty(response.Data.Token)
+		assert.Equal("Workday", scimService.recorder.token.Name)
+		assert.Equal(models.AdminActionAddSCIM, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test list SCIM tokens", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		router := setupAdminSCIMRouter(newMockAuthBearerMiddleware(&staff), adminActionService, newMockedSCIMService(nil))
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/admin/scim-tokens", nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(models.AdminActionListSCIM, adminActionService.recordRecorder.action)
+	})
+
+	t.Run("Test delete SCIM token", func(t *testing.T) {
+		staff := tests.UserFactory()
+		adminActionService := newMockedAdminActionService(nil)
+		scimService := newMockedSCIMService(nil)
+		router := setupAdminSCIMRouter(newMockAuthBearerMiddleware(&staff), adminActionService, scimService)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", "/admin/scim-tokens/"+uuid.Must(uuid.NewV4()).String(), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Code)
+		assert.True(scimService.recorder.deleted)
+		assert.Equal("Workday", adminActionService.recordRecorder.detail)
+	})
+
+	t.Run("Test delete unknown SCIM token", func(t *testing.T) {
+		staff := tests.UserFactory()
+		router := setupAdminSCIMRouter(
+			newMockAuthBearerMiddleware(&staff), newMockedAdminActionService(nil),
+			newMockedSCIMService(services.SCIMTokenNotFoundError{}),
+		)
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("DELETE", "/admin/scim-tokens/"+uuid.Must(uuid.NewV4()).String(), nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Code)
+	})
+}
@@ -1,7 +1,6 @@
 package controllers
 
 import (
-	"fmt"
 	"gandalf/helpers"
 	"gandalf/middlewares"
 	"gandalf/security"
@@ -9,7 +8,6 @@ import (
 	"gandalf/services"
 	"gandalf/validators"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
@@ -21,18 +19,25 @@ func RegisterUserRoutes(
 	authBearerMiddleware middlewares.IAuthBearerMiddleware,
 	authService services.IAuthService,
 	userService services.IUserService,
-	pelipperService services.IPelipperService,
+	outboxService services.IOutboxService,
+	emailThrottler security.IThrottler,
+	ipThrottler security.IThrottler,
 ) {
 	controller := UserController{
-		authService:     authService,
-		userService:     userService,
-		pelipperService: pelipperService,
-		authMiddleware:  authBearerMiddleware,
+		notifications: NotificationController{
+			emailThrottler: emailThrottler,
+			ipThrottler:    ipThrottler,
+		},
+		authService:    authService,
+		userService:    userService,
+		outboxService:  outboxService,
+		authMiddleware: authBearerMiddleware,
 	}
 
 	publicRoutes := router.Group("/users")
 	{
 		publicRoutes.POST("", controller.CreateUser)
+		publicRoutes.POST("/guardian-consent", controller.ConsentAsGuardian)
 	}
 
 	readRoutes := router.Group("/users")
@@ -46,21 +51,28 @@ func RegisterUserRoutes(
 
 // Controller for /users endpoints
 type UserController struct {
-	authService     services.IAuthService
-	userService     services.IUserService
-	pelipperService services.IPelipperService
-	authMiddleware  middlewares.IAuthBearerMiddleware
+	notifications  NotificationController
+	authService    services.IAuthService
+	userService    services.IUserService
+	outboxService  services.IOutboxService
+	authMiddleware middlewares.IAuthBearerMiddleware
 }
 
 // @Summary Create User
-// @Description Creates a new user
+// @Description Creates a new user and sends him the verification email. If
+// @Description the email is already registered, its owner is notified instead
+// @Description and the response stays the same. Minors must give the email
+// @Description of a guardian, and their verification email is only sent
+// @Description once the guardian consents. Sign-ups are throttled by email
+// @Description and ip, as any request which sends emails.
 // @ID user-create
 // @Tags User
 // @Accept json
 // @Produce json
 // @Param user body validators.UserCreateData true "Creates a new user"
-// @Success 201 {object} serializers.UserSerializer
+// @Success 202
 // @Failure 400 {object} helpers.HTTPError
+// @Failure 429 {object} helpers.HTTPError
 // @Router /users [post]
 func (controller UserController) CreateUser(c *gin.Context) {
 	var input validators.UserCreateData
@@ -69,28 +81,31 @@ func (controller UserController) CreateUser(c *gin.Context) {
 		return
 	}
 
-	user, err := controller.userService.Create(input)
-	if err != nil {
+	if !controller.notifications.allowed(c, input.Email) {
+		return
+	}
+
+	_, err := controller.userService.Create(input)
+	switch err.(type) {
+	case services.UserTooYoungError, services.GuardianConsentRequiredError:
 		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
 		return
 	}
+	if err == nil {
+		c.JSON(http.StatusAccepted, nil)
+		return
+	}
 
-	verifyToken := controller.authService.GenerateTokens(
-		*user, []string{security.ScopeUserVerify},
-	).AccessToken
-
-	url := os.Getenv("EMAIL_VERIFICATION_URL")
-	emailData := validators.PelipperUserVerifyEmail{
-		Email:   user.Email,
-		Name:    user.Name,
-		Subject: "Welcome",
-		VerificationLink: fmt.Sprintf(
-			"%s?code=%s", url, verifyToken,
-		),
+	registeredUser, readErr := controller.userService.ReadByEmail(input.Email)
+	if readErr != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
 	}
 
-	go controller.pelipperService.SendUserVerifyEmail(emailData)
-	c.JSON(http.StatusCreated, serializers.NewUserSerializer(*user))
+	runInBackground(func() {
+		controller.outboxService.SendSignupAttemptEmail(*registeredUser, input.ClientID)
+	})
+	c.JSON(http.StatusAccepted, nil)
 }
 
 // @Summary Get an user
@@ -120,3 +135,30 @@ func (controller UserController) ReadUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, serializers.NewUserSerializer(*user))
 }
+
+// @Summary Consent as guardian
+// @Description Consents to the sign-up of a minor with the code mailed to
+// @Description his guardian. The verification email is sent to the minor
+// @Description afterwards.
+// @ID user-guardian-consent
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param data body validators.GuardianConsentData true "Consent code"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Router /users/guardian-consent [post]
+func (controller UserController) ConsentAsGuardian(c *gin.Context) {
+	var input validators.GuardianConsentData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := controller.userService.ConsentAsGuardian(input.Code, helpers.NewClientInfo(c)); err != nil {
+		helpers.AbortWithStatus(c, http.StatusForbidden, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
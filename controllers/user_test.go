@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"gandalf/bindings"
+	"gandalf/helpers"
 	"gandalf/middlewares"
 	"gandalf/models"
 	"gandalf/services"
@@ -46,6 +47,18 @@ type resetPasswordRecorder struct {
 	password string
 }
 
+type listRecorder struct {
+	search string
+}
+
+type setDisabledRecorder struct {
+	disabled bool
+}
+
+type lockRecorder struct {
+	called bool
+}
+
 type mockUserService struct {
 	createRecorder        *createRecorder
 	readRecorder          *uuidRecorder
@@ -55,12 +68,17 @@ type mockUserService struct {
 	softdeleteRecorder    *uuidRecorder
 	verificateRecorder    *verificateRecorder
 	resetPasswordRecorder *resetPasswordRecorder
+	listRecorder          *listRecorder
+	setDisabledRecorder   *setDisabledRecorder
+	lockRecorder          *lockRecorder
+	adminActionRecorder   *services.AdminActionContext
 
 	createError     error
 	readError       error
 	updateError     error
 	deleteError     error
 	softdeleteError error
+	consentError    error
 }
 
 func (service *mockUserService) Create(userData validators.UserCreateData) (*models.User, error) {
@@ -83,8 +101,9 @@ func (service *mockUserService) Update(uuid uuid.UUID, userData validators.UserU
 	return &models.User{}, service.updateError
 }
 
-func (service *mockUserService) Delete(uuid uuid.UUID) error {
+func (service *mockUserService) Delete(uuid uuid.UUID, audit services.AuditContext, admin *services.AdminActionContext) error {
 	*service.deleteRecorder = uuidRecorder{uuid: uuid}
+	service.recordAdminAction(admin)
 	return service.deleteError
 }
 
@@ -93,12 +112,48 @@ func (service *mockUserService) SoftDelete(uuid uuid.UUID) error {
 	return service.softdeleteError
 }
 
-func (service *mockUserService) Verificate(*models.User) {
+func (service *mockUserService) Verificate(user *models.User, admin *services.AdminActionContext) error {
 	*service.verificateRecorder = verificateRecorder{called: true}
+	service.recordAdminAction(admin)
+	return nil
 }
 
-func (service *mockUserService) ResetPassword(user *models.User, password string) {
+func (service *mockUserService) ResetPassword(user *models.User, password string, client helpers.ClientInfo) error {
 	*service.resetPasswordRecorder = resetPasswordRecorder{password}
+	return nil
+}
+
+func (service *mockUserService) List(search string, cursor *helpers.Cursor) []models.User {
+	*service.listRecorder = listRecorder{search}
+	return []models.User{}
+}
+
+func (service *mockUserService) SetDisabled(user *models.User, disabled bool, admin *services.AdminActionContext) error {
+	*service.setDisabledRecorder = setDisabledRecorder{disabled}
+	service.recordAdminAction(admin)
+	user.Disabled = disabled
+	return nil
+}
+
+func (service *mockUserService) recordAdminAction(admin *services.AdminActionContext) {
+	if admin != nil {
+		*service.adminActionRecorder = *admin
+	}
+}
+
+func (service *mockUserService) Lock(user *models.User, client helpers.ClientInfo) error {
+	*service.lockRecorder = lockRecorder{called: true}
+	user.Disabled = true
+	return nil
+}
+
+func (service *mockUserService) PurgeDeleted() {}
+
+func (service *mockUserService) ConsentAsGuardian(code string, client helpers.ClientInfo) (*models.User, error) {
+	if service.consentError != nil {
+		return nil, service.consentError
+	}
+	return &models.User{}, nil
 }
 
 func newMockedUserService(createError error, readError error, updateError error, deleteError error, softdeleteError error) mockUserService {
@@ -111,6 +166,10 @@ func newMockedUserService(createError error, readError error, updateError error,
 		softdeleteRecorder:    new(uuidRecorder),
 		verificateRecorder:    new(verificateRecorder),
 		resetPasswordRecorder: new(resetPasswordRecorder),
+		listRecorder:          new(listRecorder),
+		setDisabledRecorder:   new(setDisabledRecorder),
+		lockRecorder:          new(lockRecorder),
+		adminActionRecorder:   new(services.AdminActionContext),
 		createError:           createError,
 		readError:             readError,
 		updateError:           updateError,
@@ -124,11 +183,13 @@ type mockAuthBearerMiddleware struct {
 	requestedScopes         *[]string
 	getAuthorizedUserCalled bool
 
-	authorizedUser *models.User
+	authorizedUser    *models.User
+	authorizedSession uuid.UUID
+	impersonator      *models.User
 }
 
 func newMockAuthBearerMiddleware(authorizedUser *models.User) *mockAuthBearerMiddleware {
-	return &mockAuthBearerMiddleware{false, new([]string), false, authorizedUser}
+	return &mockAuthBearerMiddleware{false, new([]string), false, authorizedUser, uuid.Must(uuid.NewV4()), nil}
 }
 
 func (middleware *mockAuthBearerMiddleware) HasScopes(scopes []string) gin.HandlerFunc {
@@ -143,57 +204,40 @@ func (middleware *mockAuthBearerMiddleware) GetAuthorizedUser(c *gin.Context) *m
 	return middleware.authorizedUser
 }
 
+func (middleware *mockAuthBearerMiddleware) GetAuthorizedSession(c *gin.Context) uuid.UUID {
+	return middleware.authorizedSession
+}
+
+func (middleware *mockAuthBearerMiddleware) GetImpersonator(c *gin.Context) *models.User {
+	return middleware.impersonator
+}
+
 func setupUserRouter(
 	authBearerMiddleware middlewares.IAuthBearerMiddleware,
 	authService services.IAuthService,
 	userService services.IUserService,
-	pelipperService services.IPelipperService,
+	outboxService services.IOutboxService,
 ) *gin.Engine {
 	router := gin.Default()
 	RegisterUserRoutes(
 		router, authBearerMiddleware,
-		authService, userService, pelipperService,
+		authService, userService,
+		outboxService,
+		newMockedThrottler(true), newMockedThrottler(true),
 	)
 	return router
 }
 
-type sendUserVerifyEmailRecorder struct {
-	data validators.PelipperUserVerifyEmail
-}
-
-type sendUserChangePasswordEmailRecorder struct {
-	data validators.PelipperUserChangePassword
-}
-type pelipperServiceMock struct {
-	sendUserVerifyEmailRecorder         *sendUserVerifyEmailRecorder
-	sendUserChangePasswordEmailRecorder *sendUserChangePasswordEmailRecorder
-}
-
-func newPelipperServiceMock() *pelipperServiceMock {
-	return &pelipperServiceMock{
-		sendUserVerifyEmailRecorder:         new(sendUserVerifyEmailRecorder),
-		sendUserChangePasswordEmailRecorder: new(sendUserChangePasswordEmailRecorder),
-	}
-}
-
-func (service *pelipperServiceMock) SendUserVerifyEmail(data validators.PelipperUserVerifyEmail) {
-	service.sendUserVerifyEmailRecorder.data = data
-}
-
-func (service *pelipperServiceMock) SendUserChangePasswordEmail(data validators.PelipperUserChangePassword) {
-	service.sendUserChangePasswordEmailRecorder.data = data
-}
-
 func TestCreateUser(t *testing.T) {
 	assert := require.New(t)
 
 	t.Run("Test create user successfully", func(t *testing.T) {
 		userService := newMockedUserService(nil, nil, nil, nil, nil)
-		pelipperService := newPelipperServiceMock()
+		outboxService := newMockedOutboxService(nil)
 		authBearerMiddleware := newMockAuthBearerMiddleware(nil)
 		router := setupUserRouter(
 			authBearerMiddleware, newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, pelipperService,
+			&userService, outboxService,
 		)
 		var response gin.H
 
@@ -217,22 +261,51 @@ func TestCreateUser(t *testing.T) {
 		router.ServeHTTP(recorder, request)
 		json.Unmarshal(recorder.Body.Bytes(), &response)
 
-		assert.Equal(recorder.Result().StatusCode, http.StatusCreated)
+		assert.Equal(recorder.Result().StatusCode, http.StatusAccepted)
 		assert.Equal(userService.createRecorder.userData.Email, email)
 		assert.Equal(userService.createRecorder.userData.Password, password)
 		assert.Equal(userService.createRecorder.userData.Name, name)
 		assert.Equal(userService.createRecorder.userData.Surname, surname)
 		assert.Equal(userService.createRecorder.userData.Birthday, birthday)
+		assert.Equal("", outboxService.sendRecorder.kind)
 		assert.False(authBearerMiddleware.hasScopesCalled)
 		assert.False(authBearerMiddleware.getAuthorizedUserCalled)
 	})
 
+	t.Run("Test create user throttled", func(t *testing.T) {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		outboxService := newMockedOutboxService(nil)
+		router := gin.Default()
+		RegisterUserRoutes(
+			router, newMockAuthBearerMiddleware(nil),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil), &userService,
+			outboxService,
+			newMockedThrottler(false), newMockedThrottler(true),
+		)
+
+		payload, _ := json.Marshal(map[string]string{
+			"email":    "test@test.com",
+			"password": "testtesttesttest",
+			"name":     "test",
+			"Surname":  "test",
+			"Birthday": "2000-02-15",
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/users", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusTooManyRequests, recorder.Result().StatusCode)
+		assert.Equal("", userService.createRecorder.userData.Email)
+		assert.Equal("", outboxService.sendRecorder.kind)
+	})
+
 	t.Run("Test create user binding error", func(t *testing.T) {
 		userService := newMockedUserService(nil, nil, nil, nil, nil)
 		router := setupUserRouter(
 			newMockAuthBearerMiddleware(nil),
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, newPelipperServiceMock(),
+			&userService, newMockedOutboxService(nil),
 		)
 		var response gin.H
 
@@ -248,13 +321,41 @@ func TestCreateUser(t *testing.T) {
 		assert.Equal(recorder.Result().StatusCode, http.StatusBadRequest)
 	})
 
+	t.Run("Test create user already registered", func(t *testing.T) {
+		userService := newMockedUserService(errors.New("create error"), nil, nil, nil, nil)
+		outboxService := newMockedOutboxService(nil)
+		router := setupUserRouter(
+			newMockAuthBearerMiddleware(nil),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, outboxService,
+		)
+
+		email := "test@test.com"
+		payload, _ := json.Marshal(map[string]string{
+			"email":    email,
+			"password": "testtesttesttest",
+			"name":     "test",
+			"Surname":  "test",
+			"Birthday": "2021-02-15",
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/users", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(recorder.Result().StatusCode, http.StatusAccepted)
+		assert.Equal(userService.readByEmailRecorder.email, email)
+		assert.Equal(outboxService.sendRecorder.user.Email, email)
+		assert.Equal(outboxService.sendRecorder.kind, models.OutboxKindUserSignupAttempt)
+	})
+
 	t.Run("Test create user service error", func(t *testing.T) {
 		expectedError := errors.New("create error")
-		userService := newMockedUserService(expectedError, nil, nil, nil, nil)
+		userService := newMockedUserService(expectedError, errors.New("not found"), nil, nil, nil)
 		router := setupUserRouter(
 			newMockAuthBearerMiddleware(nil),
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, newPelipperServiceMock(),
+			&userService, newMockedOutboxService(nil),
 		)
 		var response gin.H
 
@@ -281,6 +382,98 @@ func TestCreateUser(t *testing.T) {
 	})
 }
 
+func TestCreateMinorUser(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test create user without guardian email", func(t *testing.T) {
+		userService := newMockedUserService(services.GuardianConsentRequiredError{}, nil, nil, nil, nil)
+		outboxService := newMockedOutboxService(nil)
+		router := setupUserRouter(
+			newMockAuthBearerMiddleware(nil),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, outboxService,
+		)
+
+		payload, _ := json.Marshal(map[string]string{
+			"email":    "test@test.com",
+			"password": "testtesttesttest",
+			"name":     "test",
+			"Surname":  "test",
+			"Birthday": "2012-02-15",
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/users", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+		assert.Empty(userService.readByEmailRecorder.email)
+		assert.Equal("", outboxService.sendRecorder.kind)
+	})
+
+	t.Run("Test create user with guardian email", func(t *testing.T) {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupUserRouter(
+			newMockAuthBearerMiddleware(nil),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil),
+		)
+
+		payload, _ := json.Marshal(map[string]string{
+			"email":          "test@test.com",
+			"password":       "testtesttesttest",
+			"name":           "test",
+			"Surname":        "test",
+			"Birthday":       "2012-02-15",
+			"guardian_email": "guardian@test.com",
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/users", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusAccepted, recorder.Result().StatusCode)
+		assert.Equal("guardian@test.com", userService.createRecorder.userData.GuardianEmail)
+	})
+}
+
+func TestConsentAsGuardian(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test consent successfully", func(t *testing.T) {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		router := setupUserRouter(
+			newMockAuthBearerMiddleware(nil),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil),
+		)
+
+		payload, _ := json.Marshal(validators.GuardianConsentData{Code: "hG3k0-aPq9Lm2xZ7"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/users/guardian-consent", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test consent with invalid code", func(t *testing.T) {
+		userService := newMockedUserService(nil, nil, nil, nil, nil)
+		userService.consentError = services.OneTimeTokenNotValidError{}
+		router := setupUserRouter(
+			newMockAuthBearerMiddleware(nil),
+			newMockedAuthService(nil, nil, nil, nil, nil, nil),
+			&userService, newMockedOutboxService(nil),
+		)
+
+		payload, _ := json.Marshal(validators.GuardianConsentData{Code: "whoops"})
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("POST", "/users/guardian-consent", bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+}
+
 func TestReadUser(t *testing.T) {
 	assert := require.New(t)
 
@@ -291,7 +484,7 @@ func TestReadUser(t *testing.T) {
 		router := setupUserRouter(
 			authMiddleware,
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, newPelipperServiceMock(),
+			&userService, newMockedOutboxService(nil),
 		)
 		var response gin.H
 
@@ -311,7 +504,7 @@ func TestReadUser(t *testing.T) {
 		router := setupUserRouter(
 			authMiddleware,
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, newPelipperServiceMock(),
+			&userService, newMockedOutboxService(nil),
 		)
 		var response gin.H
 
@@ -332,7 +525,7 @@ func TestReadUser(t *testing.T) {
 		router := setupUserRouter(
 			authMiddleware,
 			newMockedAuthService(nil, nil, nil, nil, nil, nil),
-			&userService, newPelipperServiceMock(),
+			&userService, newMockedOutboxService(nil),
 		)
 		var response gin.H
 
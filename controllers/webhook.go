@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/serializers"
+	"gandalf/services"
+	"gandalf/validators"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// Register webhook endpoints to the given router
+func RegisterWebhookRoutes(
+	router *gin.Engine,
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	appService services.IAppService,
+	webhookService services.IWebhookService,
+) {
+	controller := WebhookController{
+		apps: AppController{
+			appService:     appService,
+			authMiddleware: authBearerMiddleware,
+		},
+		webhookService: webhookService,
+		authMiddleware: authBearerMiddleware,
+	}
+
+	writeRoutes := router.Group("/apps/:uuid/webhooks")
+	{
+		scopes := []string{security.ScopeAppWrite}
+		writeRoutes.Use(authBearerMiddleware.HasScopes(scopes))
+
+		writeRoutes.GET("", controller.ListWebhooks)
+		writeRoutes.POST("", controller.CreateWebhook)
+		writeRoutes.PATCH("/:webhook", controller.UpdateWebhook)
+		writeRoutes.DELETE("/:webhook", controller.DeleteWebhook)
+		writeRoutes.GET("/:webhook/deliveries", controller.ListDeliveries)
+		writeRoutes.POST("/:webhook/deliveries/:delivery/replay", controller.ReplayDelivery)
+	}
+}
+
+// Controller for /apps/{uuid}/webhooks endpoints
+type WebhookController struct {
+	apps           AppController
+	webhookService services.IWebhookService
+	authMiddleware middlewares.IAuthBearerMiddleware
+}
+
+// Reads the webhook given in the uri and checks the user who performs the
+// request can manage its app. Returns nil and aborts the request otherwise.
+func (controller WebhookController) managedWebhook(c *gin.Context) *models.Webhook {
+	var input validators.WebhookReadData
+	if err := c.ShouldBindUri(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return nil
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	app := controller.apps.managedApp(c, *user)
+	if app == nil {
+		return nil
+	}
+
+	webhook, err := controller.webhookService.Read(*app, uuid.FromStringOrNil(input.Webhook))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return nil
+	}
+	return webhook
+}
+
+// @Summary List app webhooks
+// @Description List the webhooks registered by an app
+// @ID webhook-list
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Param page query int false "cursor's page"
+// @Param limit query int false "cursor's limit"
+// @Success 200 {object} serializers.PaginatedWebhooksSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/webhooks [get]
+func (controller WebhookController) ListWebhooks(c *gin.Context) {
+	var input validators.PaginationQuery
+	if err := c.ShouldBindQuery(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	app := controller.apps.managedApp(c, *user)
+	if app == nil {
+		return
+	}
+
+	cursor := helpers.NewCursor(input.Page, input.PageSize)
+	webhooks := controller.webhookService.List(*app, &cursor)
+	c.JSON(http.StatusOK, serializers.NewPaginatedWebhooksSerializer(webhooks, cursor))
+}
+
+// @Summary Register a webhook
+// @Description Registers a webhook for an app, subscribed to the given
+// @Description event types. Payloads are signed with the webhook secret.
+// @ID webhook-create
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Param data body validators.WebhookCreateData true "Webhook data"
+// @Success 201 {object} serializers.WebhookSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/webhooks [post]
+func (controller WebhookController) CreateWebhook(c *gin.Context) {
+	var input validators.WebhookCreateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	user := controller.authMiddleware.GetAuthorizedUser(c)
+	app := controller.apps.managedApp(c, *user)
+	if app == nil {
+		return
+	}
+
+	webhook, err := controller.webhookService.Create(*app, input)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusCreated, serializers.NewWebhookSerializer(*webhook))
+}
+
+// @Summary Update a webhook
+// @Description Updates the url, the subscribed events or the status of a webhook
+// @ID webhook-update
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Param webhook path string true "Webhook uuid"
+// @Param data body validators.WebhookUpdateData true "Webhook data"
+// @Success 200 {object} serializers.WebhookSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/webhooks/{webhook} [patch]
+func (controller WebhookController) UpdateWebhook(c *gin.Context) {
+	var input validators.WebhookUpdateData
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	webhook := controller.managedWebhook(c)
+	if webhook == nil {
+		return
+	}
+
+	if err := controller.webhookService.Update(webhook, input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, serializers.NewWebhookSerializer(*webhook))
+}
+
+// @Summary Delete a webhook
+// @Description Deletes a webhook, its pending deliveries will not be attempted
+// @ID webhook-delete
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Param webhook path string true "Webhook uuid"
+// @Success 204
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/webhooks/{webhook} [delete]
+func (controller WebhookController) DeleteWebhook(c *gin.Context) {
+	webhook := controller.managedWebhook(c)
+	if webhook == nil {
+		return
+	}
+
+	if err := controller.webhookService.Delete(*webhook); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// @Summary List webhook deliveries
+// @Description List the deliveries of a webhook, the most recent first
+// @ID webhook-deliveries-list
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Param webhook path string true "Webhook uuid"
+// @Param page query int false "cursor's page"
+// @Param limit query int false "cursor's limit"
+// @Success 200 {object} serializers.PaginatedWebhookDeliveriesSerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/webhooks/{webhook}/deliveries [get]
+func (controller WebhookController) ListDeliveries(c *gin.Context) {
+	var input validators.PaginationQuery
+	if err := c.ShouldBindQuery(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	webhook := controller.managedWebhook(c)
+	if webhook == nil {
+		return
+	}
+
+	cursor := helpers.NewCursor(input.Page, input.PageSize)
+	deliveries := controller.webhookService.ListDeliveries(*webhook, &cursor)
+	c.JSON(http.StatusOK, serializers.NewPaginatedWebhookDeliveriesSerializer(deliveries, cursor))
+}
+
+// @Summary Replay a webhook delivery
+// @Description Sends again the payload of a delivery as a new delivery
+// @ID webhook-deliveries-replay
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param uuid path string true "App uuid"
+// @Param webhook path string true "Webhook uuid"
+// @Param delivery path string true "Delivery uuid"
+// @Success 201 {object} serializers.WebhookDeliverySerializer
+// @Failure 400 {object} helpers.HTTPError
+// @Failure 403 {object} helpers.HTTPError
+// @Failure 404 {object} helpers.HTTPError
+// @Security OAuth2AccessCode[app:me:write]
+// @Router /apps/{uuid}/webhooks/{webhook}/deliveries/{delivery}/replay [post]
+func (controller WebhookController) ReplayDelivery(c *gin.Context) {
+	var input validators.WebhookDeliveryReadData
+	if err := c.ShouldBindUri(&input); err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+
+	webhook := controller.managedWebhook(c)
+	if webhook == nil {
+		return
+	}
+
+	delivery, err := controller.webhookService.ReadDelivery(*webhook, uuid.FromStringOrNil(input.Delivery))
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusNotFound, err)
+		return
+	}
+
+	replay, err := controller.webhookService.Replay(*delivery)
+	if err != nil {
+		helpers.AbortWithStatus(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusCreated, serializers.NewWebhookDeliverySerializer(*replay))
+}
@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gandalf/helpers"
+	"gandalf/middlewares"
+	"gandalf/models"
+	"gandalf/services"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+type dispatchWebhookRecorder struct {
+	event string
+	user  models.User
+	app   *models.App
+}
+
+type mockWebhookService struct {
+	createRecorder   *validators.WebhookCreateData
+	updateRecorder   *validators.WebhookUpdateData
+	deleteRecorder   *models.Webhook
+	replayRecorder   *models.WebhookDelivery
+	dispatchRecorder *dispatchWebhookRecorder
+
+	readError         error
+	readDeliveryError error
+}
+
+func newMockedWebhookService(readError error, readDeliveryError error) *mockWebhookService {
+	return &mockWebhookService{
+		createRecorder:    new(validators.WebhookCreateData),
+		updateRecorder:    new(validators.WebhookUpdateData),
+		deleteRecorder:    new(models.Webhook),
+		replayRecorder:    new(models.WebhookDelivery),
+		dispatchRecorder:  new(dispatchWebhookRecorder),
+		readError:         readError,
+		readDeliveryError: readDeliveryError,
+	}
+}
+
+func (service *mockWebhookService) Create(app models.App, data validators.WebhookCreateData) (*models.Webhook, error) {
+	*service.createRecorder = data
+	webhook := models.NewWebhook(app, data.Url, data.Events)
+	return &webhook, nil
+}
+
+func (service *mockWebhookService) Read(app models.App, uuid uuid.UUID) (*models.Webhook, error) {
+	if service.readError != nil {
+		return nil, service.readError
+	}
+	webhook := models.NewWebhook(app, faker.Internet().Url(), models.WebhookEvents)
+	webhook.UUID = uuid
+	return &webhook, nil
+}
+
+func (service *mockWebhookService) List(app models.App, cursor *helpers.Cursor) []models.Webhook {
+	return []models.Webhook{models.NewWebhook(app, faker.Internet().Url(), models.WebhookEvents)}
+}
+
+func (service *mockWebhookService) Update(webhook *models.Webhook, data validators.WebhookUpdateData) error {
+	*service.updateRecorder = data
+	return nil
+}
+
+func (service *mockWebhookService) Delete(webhook models.Webhook) error {
+	*service.deleteRecorder = webhook
+	return nil
+}
+
+func (service *mockWebhookService) ListDeliveries(webhook models.Webhook, cursor *helpers.Cursor) []models.WebhookDelivery {
+	return []models.WebhookDelivery{models.NewWebhookDelivery(webhook, models.WebhookEventUserDeleted, "{}")}
+}
+
+func (service *mockWebhookService) ReadDelivery(webhook models.Webhook, uuid uuid.UUID) (*models.WebhookDelivery, error) {
+	if service.readDeliveryError != nil {
+		return nil, service.readDeliveryError
+	}
+	delivery := models.NewWebhookDelivery(webhook, models.WebhookEventUserDeleted, "{}")
+	delivery.UUID = uuid
+	return &delivery, nil
+}
+
+func (service *mockWebhookService) Replay(delivery models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	*service.replayRecorder = delivery
+	replay := models.NewWebhookDelivery(delivery.Webhook, delivery.Event, delivery.Payload)
+	return &replay, nil
+}
+
+func (service *mockWebhookService) Dispatch(event string, user models.User, app *models.App) {
+	*service.dispatchRecorder = dispatchWebhookRecorder{event, user, app}
+}
+
+func (service *mockWebhookService) DeliverPending() {}
+
+func setupWebhookRouter(
+	authBearerMiddleware middlewares.IAuthBearerMiddleware,
+	appService services.IAppService,
+	webhookService services.IWebhookService,
+) *gin.Engine {
+	router := gin.Default()
+	RegisterWebhookRoutes(router, authBearerMiddleware, appService, webhookService)
+	return router
+}
+
+func TestListWebhooks(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list webhooks successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupWebhookRouter(newMockAuthBearerMiddleware(&user), &appService, newMockedWebhookService(nil, nil))
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/webhooks", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+	})
+
+	t.Run("Test list webhooks without access", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		appService.accessRole = models.MembershipMember
+		router := setupWebhookRouter(newMockAuthBearerMiddleware(&user), &appService, newMockedWebhookService(nil, nil))
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/webhooks", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusForbidden, recorder.Result().StatusCode)
+	})
+}
+
+func TestCreateWebhook(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test create webhook successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		webhookService := newMockedWebhookService(nil, nil)
+		router := setupWebhookRouter(newMockAuthBearerMiddleware(&user), &appService, webhookService)
+
+		webhookUrl := faker.Internet().Url()
+		events := []string{models.WebhookEventUserDeleted}
+		payload, _ := json.Marshal(map[string]interface{}{"url": webhookUrl, "events": events})
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/webhooks", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusCreated, recorder.Result().StatusCode)
+		assert.Equal(webhookUrl, webhookService.createRecorder.Url)
+		assert.Equal(events, webhookService.createRecorder.Events)
+	})
+
+	t.Run("Test create webhook with unknown event", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupWebhookRouter(newMockAuthBearerMiddleware(&user), &appService, newMockedWebhookService(nil, nil))
+
+		payload, _ := json.Marshal(map[string]interface{}{
+			"url":    faker.Internet().Url(),
+			"events": []string{"user.unknown"},
+		})
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/webhooks", uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusBadRequest, recorder.Result().StatusCode)
+	})
+}
+
+func TestUpdateWebhook(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test update webhook successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		webhookService := newMockedWebhookService(nil, nil)
+		router := setupWebhookRouter(newMockAuthBearerMiddleware(&user), &appService, webhookService)
+
+		payload, _ := json.Marshal(map[string]interface{}{"active": false})
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/webhooks/%s", uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("PATCH", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+		assert.False(*webhookService.updateRecorder.Active)
+	})
+
+	t.Run("Test update webhook not found", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		webhookService := newMockedWebhookService(errors.New("not found"), nil)
+		router := setupWebhookRouter(newMockAuthBearerMiddleware(&user), &appService, webhookService)
+
+		payload, _ := json.Marshal(map[string]interface{}{"active": false})
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/webhooks/%s", uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("PATCH", url, bytes.NewBuffer(payload))
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+	})
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test delete webhook successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		webhookService := newMockedWebhookService(nil, nil)
+		router := setupWebhookRouter(newMockAuthBearerMiddleware(&user), &appService, webhookService)
+
+		webhook := uuid.Must(uuid.NewV4())
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/webhooks/%s", uuid.Must(uuid.NewV4()), webhook)
+		request, _ := http.NewRequest("DELETE", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNoContent, recorder.Result().StatusCode)
+		assert.Equal(webhook, webhookService.deleteRecorder.UUID)
+	})
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list webhook deliveries successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		router := setupWebhookRouter(newMockAuthBearerMiddleware(&user), &appService, newMockedWebhookService(nil, nil))
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/webhooks/%s/deliveries", uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Result().StatusCode)
+	})
+}
+
+func TestReplayWebhookDelivery(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test replay webhook delivery successfully", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		webhookService := newMockedWebhookService(nil, nil)
+		router := setupWebhookRouter(newMockAuthBearerMiddleware(&user), &appService, webhookService)
+
+		delivery := uuid.Must(uuid.NewV4())
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/webhooks/%s/deliveries/%s/replay", uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()), delivery)
+		request, _ := http.NewRequest("POST", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusCreated, recorder.Result().StatusCode)
+		assert.Equal(delivery, webhookService.replayRecorder.UUID)
+	})
+
+	t.Run("Test replay webhook delivery not found", func(t *testing.T) {
+		user := tests.UserFactory()
+		appService := newMockedAppService(nil, nil, nil, nil, nil)
+		webhookService := newMockedWebhookService(nil, errors.New("not found"))
+		router := setupWebhookRouter(newMockAuthBearerMiddleware(&user), &appService, webhookService)
+
+		recorder := httptest.NewRecorder()
+		url := fmt.Sprintf("/apps/%s/webhooks/%s/deliveries/%s/replay", uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()))
+		request, _ := http.NewRequest("POST", url, nil)
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusNotFound, recorder.Result().StatusCode)
+		assert.Equal(uuid.Nil, webhookService.replayRecorder.UUID)
+	})
+}
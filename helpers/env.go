@@ -0,0 +1,25 @@
+package helpers
+
+import (
+	"os"
+	"strconv"
+)
+
+// Returns the integer value of the given environment variable, or the
+// fallback one if it is not set or is not a valid integer
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Returns the value of the given environment variable, or the fallback one
+// if it is not set or is empty
+func GetEnvString(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEnvInt(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test variable is set", func(t *testing.T) {
+		os.Setenv("GANDALF_TEST_INT", "42")
+		defer os.Unsetenv("GANDALF_TEST_INT")
+
+		assert.Equal(42, GetEnvInt("GANDALF_TEST_INT", 1))
+	})
+
+	t.Run("Test variable is not set", func(t *testing.T) {
+		assert.Equal(1, GetEnvInt("GANDALF_TEST_UNSET_INT", 1))
+	})
+
+	t.Run("Test variable is not an integer", func(t *testing.T) {
+		os.Setenv("GANDALF_TEST_INT", "wrong")
+		defer os.Unsetenv("GANDALF_TEST_INT")
+
+		assert.Equal(1, GetEnvInt("GANDALF_TEST_INT", 1))
+	})
+}
+
+func TestGetEnvString(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test variable is set", func(t *testing.T) {
+		os.Setenv("GANDALF_TEST_STRING", "value")
+		defer os.Unsetenv("GANDALF_TEST_STRING")
+
+		assert.Equal("value", GetEnvString("GANDALF_TEST_STRING", "fallback"))
+	})
+
+	t.Run("Test variable is not set", func(t *testing.T) {
+		assert.Equal("fallback", GetEnvString("GANDALF_TEST_UNSET_STRING", "fallback"))
+	})
+}
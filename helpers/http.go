@@ -23,3 +23,17 @@ func NewHTTPError(status int, err error) HTTPError {
 func AbortWithStatus(c *gin.Context, status int, err error) {
 	c.JSON(status, NewHTTPError(status, err))
 }
+
+// Information about the client who performs a request
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// Reads the client information from the given gin context
+func NewClientInfo(c *gin.Context) ClientInfo {
+	return ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
@@ -24,14 +24,31 @@ import (
 // @tokenUrl http://localhost:9100/oauth/token
 // @authorizationurl http://localhost:3000/oauth
 // @oauth2RedirectUrl http://localhost:9100/swagger/oauth2-redirect.html
-// @scope.user:me:verify Grants access to verify created user
-// @scope.user:me:change-password Grants access to change self password
 // @scope.user:me:read Grants access to read self user
 // @scope.user:me:write Grants access to write self user
 // @scope.user:me:delete Grants access to delete self user
+// @scope.user:me:export Grants access to export all the data of self user
 // @scope.user:me:authorized-app Grants access an app to get information about the user
 // @scope.app:me:write Grants access to write self created apps
 // @scope.app:me:read Grants access to read self created apps
+// @scope.org:me:read Grants access to read self organizations
+// @scope.org:me:write Grants access to manage self organizations
+// @scope.user:all:read Grants staff access to read any user
+// @scope.user:all:write Grants staff access to manage any user
+// @scope.user:all:delete Grants staff access to delete any user
+// @scope.app:all:read Grants staff access to read any app
+// @scope.role:all:read Grants staff access to read roles and role assignments
+// @scope.role:all:write Grants staff access to assign and revoke roles
+// @scope.scim:all:read Grants staff access to read the SCIM tokens
+// @scope.scim:all:write Grants staff access to issue and revoke SCIM tokens
+// @scope.attribute:all:read Grants staff access to read the custom attribute definitions
+// @scope.attribute:all:write Grants staff access to manage the custom attribute definitions
+// @scope.legal:all:read Grants staff access to read the legal documents and the acceptances of the users
+// @scope.legal:all:write Grants staff access to publish the legal documents
+// @scope.user:all:impersonate Grants staff access to act as any user for support
+// @securityDefinitions.apikey SCIMToken
+// @in header
+// @name Authorization
 func main() {
 	docs.SwaggerInfo.Title = "Gandalf API"
 	router := gin.Default()
@@ -2,18 +2,22 @@ package middlewares
 
 import (
 	"errors"
+	"gandalf/helpers"
 	"gandalf/models"
 	auth "gandalf/services"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
 )
 
 // Interface for bearer authentication middleware
 type IAuthBearerMiddleware interface {
 	HasScopes(scopes []string) gin.HandlerFunc
 	GetAuthorizedUser(c *gin.Context) *models.User
+	GetAuthorizedSession(c *gin.Context) uuid.UUID
+	GetImpersonator(c *gin.Context) *models.User
 }
 
 // Auth middleware for authenticate users with Bearer tokens
@@ -41,6 +45,21 @@ func (middleware AuthBearerMiddleware) HasScopes(scopes []string) gin.HandlerFun
 		}
 
 		c.Set("authorizedUser", user)
+		c.Set("authorizedSession", middleware.authService.GetTokenSession(bearer[1]))
+
+		// Every request made by staff acting as the user is audited
+		// before it is handled
+		impersonator := middleware.authService.GetTokenImpersonator(bearer[1])
+		if impersonator == nil {
+			return
+		}
+		request := c.Request.Method + " " + c.Request.URL.Path
+		err = middleware.authService.RecordImpersonatedRequest(*impersonator, *user, request, helpers.NewClientInfo(c))
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.Set("authorizedImpersonator", impersonator)
 	}
 }
 
@@ -52,3 +71,23 @@ func (middleware AuthBearerMiddleware) GetAuthorizedUser(c *gin.Context) *models
 	}
 	return user.(*models.User)
 }
+
+// Return the uuid of the session the authorized user has performed the
+// request with from the given gin context
+func (middleware AuthBearerMiddleware) GetAuthorizedSession(c *gin.Context) uuid.UUID {
+	session, exists := c.Get("authorizedSession")
+	if !exists {
+		panic(AuthBearerMiddlewareNotCalledError{})
+	}
+	return session.(uuid.UUID)
+}
+
+// Return the staff user who acts as the authorized user from the given gin
+// context, or nil if the request is not made under impersonation
+func (middleware AuthBearerMiddleware) GetImpersonator(c *gin.Context) *models.User {
+	impersonator, exists := c.Get("authorizedImpersonator")
+	if !exists {
+		return nil
+	}
+	return impersonator.(*models.User)
+}
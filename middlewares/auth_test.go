@@ -3,6 +3,7 @@ package middlewares
 import (
 	"bytes"
 	"fmt"
+	"gandalf/helpers"
 	"gandalf/models"
 	"gandalf/services"
 	"gandalf/tests"
@@ -12,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
@@ -24,6 +26,9 @@ type authServiceMock struct {
 	recorder               *getAuthorizedUserRecorder
 	userGetAuthorizedUser  *models.User
 	errorGetAuthorizedUser error
+	session                uuid.UUID
+	impersonator           *models.User
+	impersonatedRequests   *[]string
 }
 
 func newAuthServiceMock(userGetAuthorizedUser *models.User, errorGetAuthorizedUser error) *authServiceMock {
@@ -31,19 +36,25 @@ func newAuthServiceMock(userGetAuthorizedUser *models.User, errorGetAuthorizedUs
 		recorder:               new(getAuthorizedUserRecorder),
 		userGetAuthorizedUser:  userGetAuthorizedUser,
 		errorGetAuthorizedUser: errorGetAuthorizedUser,
+		session:                uuid.Must(uuid.NewV4()),
+		impersonatedRequests:   new([]string),
 	}
 }
 
-func (service authServiceMock) Authenticate(credentials validators.Credentials, isStaff bool) (*models.User, error) {
+func (service authServiceMock) Authenticate(credentials validators.Credentials, isStaff bool, client helpers.ClientInfo) (*models.User, error) {
 	return nil, nil
 }
 
-func (service *authServiceMock) Authorize(app *models.App, user *models.User, data validators.OauthAuthorizeData) (string, error) {
+func (service *authServiceMock) Authorize(app *models.App, user *models.User, data validators.OauthAuthorizeData, client helpers.ClientInfo, parent uuid.UUID) (string, error) {
 	return "", nil
 }
 
-func (service *authServiceMock) GenerateTokens(user models.User, scopes []string) services.AuthTokens {
-	return services.AuthTokens{AccessToken: "", RefreshToken: ""}
+func (service *authServiceMock) StartSession(user models.User, client helpers.ClientInfo, scopes []string) (*services.AuthTokens, error) {
+	return &services.AuthTokens{AccessToken: "", RefreshToken: ""}, nil
+}
+
+func (service authServiceMock) GetTokenSession(accessToken string) uuid.UUID {
+	return service.session
 }
 
 func (service authServiceMock) GetAuthorizedUser(accessToken string, scopes []string) (*models.User, error) {
@@ -56,10 +67,23 @@ func (service authServiceMock) RefreshToken(accessToken string, refreshToken str
 	return nil, nil
 }
 
-func (service authServiceMock) ExchangeOauthToken(app models.App, data validators.OauthExchangeToken) (*services.AuthTokens, error) {
+func (service authServiceMock) ExchangeOauthToken(app models.App, data validators.OauthExchangeToken, client helpers.ClientInfo) (*services.AuthTokens, error) {
+	return nil, nil
+}
+
+func (service authServiceMock) Impersonate(staff models.User, user models.User, reason string, client helpers.ClientInfo) (*services.AuthTokens, error) {
 	return nil, nil
 }
 
+func (service authServiceMock) GetTokenImpersonator(accessToken string) *models.User {
+	return service.impersonator
+}
+
+func (service authServiceMock) RecordImpersonatedRequest(staff models.User, user models.User, request string, client helpers.ClientInfo) error {
+	*service.impersonatedRequests = append(*service.impersonatedRequests, request)
+	return nil
+}
+
 func TestAuthBearerMiddleware(t *testing.T) {
 	assert := require.New(t)
 
@@ -75,10 +99,42 @@ func TestAuthBearerMiddleware(t *testing.T) {
 
 		middleware.HasScopes(scopes)(mockContext)
 		settedUser := mockContext.MustGet("authorizedUser").(*models.User)
+		settedSession := mockContext.MustGet("authorizedSession").(uuid.UUID)
 
 		assert.Equal(authServiceMock.recorder.accessToken, token)
 		assert.Equal(authServiceMock.recorder.scopes, scopes)
 		assert.Equal(settedUser.Email, user.Email)
+		assert.Equal(authServiceMock.session, settedSession)
+	})
+
+	t.Run("Test HasScopes records impersonated requests", func(t *testing.T) {
+		user := tests.UserFactory()
+		staff := tests.UserFactory()
+		authServiceMock := newAuthServiceMock(&user, nil)
+		authServiceMock.impersonator = &staff
+		middleware := NewAuthBearerMiddleware(authServiceMock)
+		mockContext, _ := gin.CreateTestContext(httptest.NewRecorder())
+		mockContext.Request, _ = http.NewRequest("PATCH", "/me", new(bytes.Buffer))
+		mockContext.Request.Header.Set("Authorization", "Bearer mockedtoken")
+
+		middleware.HasScopes([]string{"read:misco"})(mockContext)
+
+		assert.Equal([]string{"PATCH /me"}, *authServiceMock.impersonatedRequests)
+		assert.Equal(staff.Email, middleware.GetImpersonator(mockContext).Email)
+	})
+
+	t.Run("Test HasScopes without impersonation", func(t *testing.T) {
+		user := tests.UserFactory()
+		authServiceMock := newAuthServiceMock(&user, nil)
+		middleware := NewAuthBearerMiddleware(authServiceMock)
+		mockContext, _ := gin.CreateTestContext(httptest.NewRecorder())
+		mockContext.Request, _ = http.NewRequest("GET", "/me", new(bytes.Buffer))
+		mockContext.Request.Header.Set("Authorization", "Bearer mockedtoken")
+
+		middleware.HasScopes([]string{"read:misco"})(mockContext)
+
+		assert.Empty(*authServiceMock.impersonatedRequests)
+		assert.Nil(middleware.GetImpersonator(mockContext))
 	})
 
 	t.Run("Test HasScopes wrong token header", func(t *testing.T) {
@@ -129,4 +185,22 @@ func TestAuthBearerMiddleware(t *testing.T) {
 
 		assert.PanicsWithError(AuthBearerMiddlewareNotCalledError{}.Error(), func() { middleware.GetAuthorizedUser(mockContext) })
 	})
+
+	t.Run("Test GetAuthorizedSession successfully", func(t *testing.T) {
+		session, _ := uuid.NewV4()
+		authServiceMock := newAuthServiceMock(nil, nil)
+		middleware := NewAuthBearerMiddleware(authServiceMock)
+		mockContext, _ := gin.CreateTestContext(httptest.NewRecorder())
+		mockContext.Set("authorizedSession", session)
+
+		assert.Equal(session, middleware.GetAuthorizedSession(mockContext))
+	})
+
+	t.Run("Test GetAuthorizedSession panics", func(t *testing.T) {
+		authServiceMock := newAuthServiceMock(nil, nil)
+		middleware := NewAuthBearerMiddleware(authServiceMock)
+		mockContext, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		assert.PanicsWithError(AuthBearerMiddlewareNotCalledError{}.Error(), func() { middleware.GetAuthorizedSession(mockContext) })
+	})
 }
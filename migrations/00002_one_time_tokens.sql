@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE one_time_tokens_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."one_time_tokens" (
+    "id" bigint DEFAULT nextval('one_time_tokens_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "purpose" text NOT NULL,
+    "digest" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "user_id" bigint,
+    CONSTRAINT "one_time_tokens_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "one_time_tokens_uuid_key" UNIQUE ("uuid"),
+    CONSTRAINT "one_time_tokens_digest_key" UNIQUE ("digest")
+) WITH (oids = false);
+
+CREATE INDEX "idx_one_time_tokens_deleted_at" ON "public"."one_time_tokens" USING btree ("deleted_at");
+CREATE INDEX "ott_uuid" ON "public"."one_time_tokens" USING btree ("uuid");
+CREATE INDEX "ott_digest" ON "public"."one_time_tokens" USING btree ("digest");
+
+ALTER TABLE ONLY "public"."one_time_tokens" ADD CONSTRAINT "fk_one_time_tokens_user" FOREIGN KEY (user_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "one_time_tokens";
+DROP SEQUENCE IF EXISTS one_time_tokens_id_seq;
+-- +goose StatementEnd
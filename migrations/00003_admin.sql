@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "public"."users" ADD COLUMN "disabled" boolean DEFAULT false;
+
+CREATE SEQUENCE admin_actions_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."admin_actions" (
+    "id" bigint DEFAULT nextval('admin_actions_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "action" text NOT NULL,
+    "detail" text,
+    "ip" text,
+    "staff_id" bigint,
+    "target_id" bigint,
+    CONSTRAINT "admin_actions_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "admin_actions_uuid_key" UNIQUE ("uuid")
+) WITH (oids = false);
+
+CREATE INDEX "idx_admin_actions_deleted_at" ON "public"."admin_actions" USING btree ("deleted_at");
+CREATE INDEX "admin_action_uuid" ON "public"."admin_actions" USING btree ("uuid");
+
+ALTER TABLE ONLY "public"."admin_actions" ADD CONSTRAINT "fk_admin_actions_staff" FOREIGN KEY (staff_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."admin_actions" ADD CONSTRAINT "fk_admin_actions_target" FOREIGN KEY (target_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE SET NULL NOT DEFERRABLE;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "admin_actions";
+DROP SEQUENCE IF EXISTS admin_actions_id_seq;
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "disabled";
+-- +goose StatementEnd
@@ -0,0 +1,103 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE permissions_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."permissions" (
+    "id" bigint DEFAULT nextval('permissions_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "scope" text NOT NULL,
+    "description" text,
+    CONSTRAINT "permissions_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "permissions_uuid_key" UNIQUE ("uuid"),
+    CONSTRAINT "permissions_scope_key" UNIQUE ("scope")
+) WITH (oids = false);
+
+CREATE INDEX "idx_permissions_deleted_at" ON "public"."permissions" USING btree ("deleted_at");
+CREATE INDEX "permission_uuid" ON "public"."permissions" USING btree ("uuid");
+CREATE INDEX "permission_scope" ON "public"."permissions" USING btree ("scope");
+
+
+CREATE SEQUENCE roles_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."roles" (
+    "id" bigint DEFAULT nextval('roles_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "name" text NOT NULL,
+    "description" text,
+    CONSTRAINT "roles_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "roles_uuid_key" UNIQUE ("uuid"),
+    CONSTRAINT "roles_name_key" UNIQUE ("name")
+) WITH (oids = false);
+
+CREATE INDEX "idx_roles_deleted_at" ON "public"."roles" USING btree ("deleted_at");
+CREATE INDEX "role_uuid" ON "public"."roles" USING btree ("uuid");
+CREATE INDEX "role_name" ON "public"."roles" USING btree ("name");
+
+
+CREATE TABLE "public"."role_has_permission" (
+    "role_id" bigint NOT NULL,
+    "permission_id" bigint NOT NULL,
+    CONSTRAINT "role_has_permission_pkey" PRIMARY KEY ("role_id", "permission_id")
+) WITH (oids = false);
+
+CREATE TABLE "public"."user_has_role" (
+    "user_id" bigint NOT NULL,
+    "role_id" bigint NOT NULL,
+    CONSTRAINT "user_has_role_pkey" PRIMARY KEY ("user_id", "role_id")
+) WITH (oids = false);
+
+ALTER TABLE ONLY "public"."role_has_permission" ADD CONSTRAINT "role_has_permission_role_id_fkey" FOREIGN KEY (role_id) REFERENCES roles(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."role_has_permission" ADD CONSTRAINT "role_has_permission_permission_id_fkey" FOREIGN KEY (permission_id) REFERENCES permissions(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."user_has_role" ADD CONSTRAINT "user_has_role_user_id_fkey" FOREIGN KEY (user_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."user_has_role" ADD CONSTRAINT "user_has_role_role_id_fkey" FOREIGN KEY (role_id) REFERENCES roles(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+
+
+-- Default permissions and roles
+INSERT INTO "public"."permissions" ("created_at", "updated_at", "scope", "description") VALUES
+    (now(), now(), 'user:me:authorized-app', 'Authorize apps to get information about the user'),
+    (now(), now(), 'user:me:read', 'Read self user'),
+    (now(), now(), 'user:me:write', 'Write self user'),
+    (now(), now(), 'user:me:delete', 'Delete self user'),
+    (now(), now(), 'app:me:read', 'Read self created and connected apps'),
+    (now(), now(), 'app:me:write', 'Write self created apps'),
+    (now(), now(), 'user:all:read', 'Read any user'),
+    (now(), now(), 'user:all:write', 'Manage any user'),
+    (now(), now(), 'user:all:delete', 'Delete any user'),
+    (now(), now(), 'app:all:read', 'Read any app'),
+    (now(), now(), 'role:all:read', 'Read roles and role assignments'),
+    (now(), now(), 'role:all:write', 'Assign and revoke roles');
+
+INSERT INTO "public"."roles" ("created_at", "updated_at", "name", "description") VALUES
+    (now(), now(), 'user', 'Default role for every registered user'),
+    (now(), now(), 'developer', 'Creates and manages apps'),
+    (now(), now(), 'staff', 'Manages users, apps and roles through the admin api');
+
+INSERT INTO "public"."role_has_permission" ("role_id", "permission_id")
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE (roles.name = 'user' AND permissions.scope IN (
+        'user:me:authorized-app', 'user:me:read', 'user:me:write', 'user:me:delete', 'app:me:read'
+    ))
+    OR (roles.name = 'developer' AND permissions.scope IN ('app:me:read', 'app:me:write'))
+    OR (roles.name = 'staff' AND permissions.scope IN (
+        'user:all:read', 'user:all:write', 'user:all:delete', 'app:all:read', 'role:all:read', 'role:all:write'
+    ));
+
+INSERT INTO "public"."user_has_role" ("user_id", "role_id")
+SELECT users.id, roles.id FROM users, roles
+WHERE roles.name = 'user' OR (roles.name = 'staff' AND users.staff);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "user_has_role";
+DROP TABLE IF EXISTS "role_has_permission";
+DROP TABLE IF EXISTS "roles";
+DROP TABLE IF EXISTS "permissions";
+DROP SEQUENCE IF EXISTS roles_id_seq;
+DROP SEQUENCE IF EXISTS permissions_id_seq;
+-- +goose StatementEnd
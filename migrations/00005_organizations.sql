@@ -0,0 +1,88 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE organizations_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."organizations" (
+    "id" bigint DEFAULT nextval('organizations_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "name" text NOT NULL,
+    CONSTRAINT "organizations_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "organizations_uuid_key" UNIQUE ("uuid")
+) WITH (oids = false);
+
+CREATE INDEX "idx_organizations_deleted_at" ON "public"."organizations" USING btree ("deleted_at");
+CREATE INDEX "org_uuid" ON "public"."organizations" USING btree ("uuid");
+
+
+CREATE SEQUENCE memberships_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."memberships" (
+    "id" bigint DEFAULT nextval('memberships_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "role" text NOT NULL,
+    "organization_id" bigint,
+    "user_id" bigint,
+    CONSTRAINT "memberships_pkey" PRIMARY KEY ("id")
+) WITH (oids = false);
+
+CREATE INDEX "idx_memberships_deleted_at" ON "public"."memberships" USING btree ("deleted_at");
+CREATE UNIQUE INDEX "membership_org_user" ON "public"."memberships" USING btree ("organization_id", "user_id");
+
+
+CREATE SEQUENCE invitations_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."invitations" (
+    "id" bigint DEFAULT nextval('invitations_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "email" text NOT NULL,
+    "role" text NOT NULL,
+    "digest" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "accepted_at" timestamptz,
+    "organization_id" bigint,
+    "invited_by_id" bigint,
+    CONSTRAINT "invitations_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "invitations_uuid_key" UNIQUE ("uuid"),
+    CONSTRAINT "invitations_digest_key" UNIQUE ("digest")
+) WITH (oids = false);
+
+CREATE INDEX "idx_invitations_deleted_at" ON "public"."invitations" USING btree ("deleted_at");
+CREATE INDEX "invitation_uuid" ON "public"."invitations" USING btree ("uuid");
+CREATE INDEX "invitation_digest" ON "public"."invitations" USING btree ("digest");
+
+ALTER TABLE "public"."apps" ADD COLUMN "organization_id" bigint;
+
+ALTER TABLE ONLY "public"."memberships" ADD CONSTRAINT "fk_memberships_organization" FOREIGN KEY (organization_id) REFERENCES organizations(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."memberships" ADD CONSTRAINT "fk_memberships_user" FOREIGN KEY (user_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."invitations" ADD CONSTRAINT "fk_invitations_organization" FOREIGN KEY (organization_id) REFERENCES organizations(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."invitations" ADD CONSTRAINT "fk_invitations_invited_by" FOREIGN KEY (invited_by_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."apps" ADD CONSTRAINT "fk_apps_organization" FOREIGN KEY (organization_id) REFERENCES organizations(id) ON UPDATE CASCADE ON DELETE SET NULL NOT DEFERRABLE;
+
+
+-- Organization permissions granted to every user
+INSERT INTO "public"."permissions" ("created_at", "updated_at", "scope", "description") VALUES
+    (now(), now(), 'org:me:read', 'Read the organizations the user is member of'),
+    (now(), now(), 'org:me:write', 'Create and manage organizations');
+
+INSERT INTO "public"."role_has_permission" ("role_id", "permission_id")
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'user' AND permissions.scope IN ('org:me:read', 'org:me:write');
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "public"."permissions" WHERE scope IN ('org:me:read', 'org:me:write');
+ALTER TABLE "public"."apps" DROP COLUMN IF EXISTS "organization_id";
+DROP TABLE IF EXISTS "invitations";
+DROP TABLE IF EXISTS "memberships";
+DROP TABLE IF EXISTS "organizations";
+DROP SEQUENCE IF EXISTS invitations_id_seq;
+DROP SEQUENCE IF EXISTS memberships_id_seq;
+DROP SEQUENCE IF EXISTS organizations_id_seq;
+-- +goose StatementEnd
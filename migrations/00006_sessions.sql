@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE sessions_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."sessions" (
+    "id" bigint DEFAULT nextval('sessions_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "last_seen_at" timestamptz NOT NULL,
+    "user_agent" text,
+    "ip" text,
+    "revoked_at" timestamptz,
+    "user_id" bigint,
+    "app_id" bigint,
+    CONSTRAINT "sessions_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "sessions_uuid_key" UNIQUE ("uuid")
+) WITH (oids = false);
+
+CREATE INDEX "idx_sessions_deleted_at" ON "public"."sessions" USING btree ("deleted_at");
+CREATE INDEX "session_uuid" ON "public"."sessions" USING btree ("uuid");
+CREATE INDEX "session_user" ON "public"."sessions" USING btree ("user_id");
+
+ALTER TABLE "public"."claims" ADD COLUMN "session_id" bigint;
+
+ALTER TABLE ONLY "public"."sessions" ADD CONSTRAINT "fk_sessions_user" FOREIGN KEY (user_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."sessions" ADD CONSTRAINT "fk_sessions_app" FOREIGN KEY (app_id) REFERENCES apps(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."claims" ADD CONSTRAINT "fk_claims_session" FOREIGN KEY (session_id) REFERENCES sessions(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "public"."claims" DROP COLUMN IF EXISTS "session_id";
+DROP TABLE IF EXISTS "sessions";
+DROP SEQUENCE IF EXISTS sessions_id_seq;
+-- +goose StatementEnd
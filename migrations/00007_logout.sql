@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "public"."apps" ADD COLUMN "post_logout_redirect_urls" text[];
+ALTER TABLE "public"."apps" ADD COLUMN "backchannel_logout_url" text;
+ALTER TABLE "public"."sessions" ADD COLUMN "parent_id" bigint;
+
+ALTER TABLE ONLY "public"."sessions" ADD CONSTRAINT "fk_sessions_parent" FOREIGN KEY (parent_id) REFERENCES sessions(id) ON UPDATE CASCADE ON DELETE SET NULL NOT DEFERRABLE;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "public"."sessions" DROP COLUMN IF EXISTS "parent_id";
+ALTER TABLE "public"."apps" DROP COLUMN IF EXISTS "backchannel_logout_url";
+ALTER TABLE "public"."apps" DROP COLUMN IF EXISTS "post_logout_redirect_urls";
+-- +goose StatementEnd
@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE audit_events_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."audit_events" (
+    "id" bigint DEFAULT nextval('audit_events_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "action" text NOT NULL,
+    "outcome" text NOT NULL,
+    "ip" text,
+    "user_agent" text,
+    "metadata" jsonb,
+    "actor_id" bigint,
+    "subject_id" bigint,
+    CONSTRAINT "audit_events_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "audit_events_uuid_key" UNIQUE ("uuid")
+) WITH (oids = false);
+
+CREATE INDEX "audit_event_uuid" ON "public"."audit_events" USING btree ("uuid");
+CREATE INDEX "audit_event_created_at" ON "public"."audit_events" USING btree ("created_at");
+CREATE INDEX "audit_event_action" ON "public"."audit_events" USING btree ("action");
+CREATE INDEX "audit_event_actor" ON "public"."audit_events" USING btree ("actor_id");
+CREATE INDEX "audit_event_subject" ON "public"."audit_events" USING btree ("subject_id");
+
+ALTER TABLE ONLY "public"."audit_events" ADD CONSTRAINT "fk_audit_events_actor" FOREIGN KEY (actor_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE SET NULL NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."audit_events" ADD CONSTRAINT "fk_audit_events_subject" FOREIGN KEY (subject_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE SET NULL NOT DEFERRABLE;
+
+-- The audit log is append-only
+CREATE RULE "audit_events_no_delete" AS ON DELETE TO "public"."audit_events" DO INSTEAD NOTHING;
+
+-- Audit permission granted to the staff
+INSERT INTO "public"."permissions" ("created_at", "updated_at", "scope", "description") VALUES
+    (now(), now(), 'audit:all:read', 'Read the security audit log');
+
+INSERT INTO "public"."role_has_permission" ("role_id", "permission_id")
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'staff' AND permissions.scope = 'audit:all:read';
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "public"."permissions" WHERE scope = 'audit:all:read';
+DROP TABLE IF EXISTS "audit_events";
+DROP SEQUENCE IF EXISTS audit_events_id_seq;
+-- +goose StatementEnd
@@ -0,0 +1,59 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE webhooks_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."webhooks" (
+    "id" bigint DEFAULT nextval('webhooks_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "url" text NOT NULL,
+    "secret" text NOT NULL,
+    "events" text[],
+    "active" boolean DEFAULT true,
+    "app_id" bigint,
+    CONSTRAINT "webhooks_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "webhooks_uuid_key" UNIQUE ("uuid")
+) WITH (oids = false);
+
+CREATE INDEX "idx_webhooks_deleted_at" ON "public"."webhooks" USING btree ("deleted_at");
+CREATE INDEX "webhook_uuid" ON "public"."webhooks" USING btree ("uuid");
+CREATE INDEX "webhook_app" ON "public"."webhooks" USING btree ("app_id");
+
+CREATE SEQUENCE webhook_deliveries_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."webhook_deliveries" (
+    "id" bigint DEFAULT nextval('webhook_deliveries_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "event" text NOT NULL,
+    "payload" jsonb NOT NULL,
+    "status" text NOT NULL,
+    "attempts" bigint,
+    "next_attempt_at" timestamptz,
+    "delivered_at" timestamptz,
+    "last_status" bigint,
+    "last_error" text,
+    "webhook_id" bigint,
+    CONSTRAINT "webhook_deliveries_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "webhook_deliveries_uuid_key" UNIQUE ("uuid")
+) WITH (oids = false);
+
+CREATE INDEX "idx_webhook_deliveries_deleted_at" ON "public"."webhook_deliveries" USING btree ("deleted_at");
+CREATE INDEX "webhook_delivery_uuid" ON "public"."webhook_deliveries" USING btree ("uuid");
+CREATE INDEX "webhook_delivery_status" ON "public"."webhook_deliveries" USING btree ("status");
+CREATE INDEX "webhook_delivery_webhook" ON "public"."webhook_deliveries" USING btree ("webhook_id");
+
+ALTER TABLE ONLY "public"."webhooks" ADD CONSTRAINT "fk_webhooks_app" FOREIGN KEY (app_id) REFERENCES apps(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."webhook_deliveries" ADD CONSTRAINT "fk_webhook_deliveries_webhook" FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "webhook_deliveries";
+DROP SEQUENCE IF EXISTS webhook_deliveries_id_seq;
+DROP TABLE IF EXISTS "webhooks";
+DROP SEQUENCE IF EXISTS webhooks_id_seq;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE outbox_messages_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."outbox_messages" (
+    "id" bigint DEFAULT nextval('outbox_messages_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "kind" text NOT NULL,
+    "recipient" text NOT NULL,
+    "payload" jsonb NOT NULL,
+    "status" text NOT NULL,
+    "attempts" bigint,
+    "next_attempt_at" timestamptz,
+    "delivered_at" timestamptz,
+    "last_error" text,
+    CONSTRAINT "outbox_messages_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "outbox_messages_uuid_key" UNIQUE ("uuid")
+) WITH (oids = false);
+
+CREATE INDEX "idx_outbox_messages_deleted_at" ON "public"."outbox_messages" USING btree ("deleted_at");
+CREATE INDEX "outbox_message_uuid" ON "public"."outbox_messages" USING btree ("uuid");
+CREATE INDEX "outbox_message_status" ON "public"."outbox_messages" USING btree ("status");
+
+-- Outbox permission granted to the staff
+INSERT INTO "public"."permissions" ("created_at", "updated_at", "scope", "description") VALUES
+    (now(), now(), 'outbox:all:read', 'Read the notifications outbox');
+
+INSERT INTO "public"."role_has_permission" ("role_id", "permission_id")
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'staff' AND permissions.scope = 'outbox:all:read';
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "public"."permissions" WHERE scope = 'outbox:all:read';
+DROP TABLE IF EXISTS "outbox_messages";
+DROP SEQUENCE IF EXISTS outbox_messages_id_seq;
+-- +goose StatementEnd
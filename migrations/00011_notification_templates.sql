@@ -0,0 +1,50 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "public"."users" ADD COLUMN "locale" text DEFAULT 'en' NOT NULL;
+
+CREATE SEQUENCE notification_templates_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."notification_templates" (
+    "id" bigint DEFAULT nextval('notification_templates_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "kind" text NOT NULL,
+    "locale" text NOT NULL,
+    "subject" text NOT NULL,
+    "text" text NOT NULL,
+    "html" text,
+    "app_id" bigint,
+    CONSTRAINT "notification_templates_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "notification_templates_uuid_key" UNIQUE ("uuid")
+) WITH (oids = false);
+
+CREATE INDEX "idx_notification_templates_deleted_at" ON "public"."notification_templates" USING btree ("deleted_at");
+CREATE INDEX "notification_template_uuid" ON "public"."notification_templates" USING btree ("uuid");
+CREATE INDEX "notification_template_app" ON "public"."notification_templates" USING btree ("app_id");
+
+-- A single live template per kind and locale, for the deployment and for
+-- every app
+CREATE UNIQUE INDEX "notification_template_lookup" ON "public"."notification_templates"
+    USING btree ("kind", "locale", COALESCE("app_id", 0)) WHERE "deleted_at" IS NULL;
+
+ALTER TABLE ONLY "public"."notification_templates" ADD CONSTRAINT "fk_notification_templates_app" FOREIGN KEY (app_id) REFERENCES apps(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+
+-- Template permissions granted to the staff
+INSERT INTO "public"."permissions" ("created_at", "updated_at", "scope", "description") VALUES
+    (now(), now(), 'template:all:read', 'Read and preview the notification templates'),
+    (now(), now(), 'template:all:write', 'Override the notification templates');
+
+INSERT INTO "public"."role_has_permission" ("role_id", "permission_id")
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'staff' AND permissions.scope IN ('template:all:read', 'template:all:write');
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "public"."permissions" WHERE scope IN ('template:all:read', 'template:all:write');
+DROP TABLE IF EXISTS "notification_templates";
+DROP SEQUENCE IF EXISTS notification_templates_id_seq;
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "locale";
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "public"."users" ADD COLUMN "app_authorized_alerts" boolean DEFAULT true NOT NULL;
+ALTER TABLE "public"."users" ADD COLUMN "new_device_alerts" boolean DEFAULT true NOT NULL;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "new_device_alerts";
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "app_authorized_alerts";
+-- +goose StatementEnd
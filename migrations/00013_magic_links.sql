@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE magic_links_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."magic_links" (
+    "id" bigint DEFAULT nextval('magic_links_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "binding_digest" text NOT NULL,
+    "client_id" uuid,
+    "redirect_uri" text,
+    "scopes" text[],
+    "state" text,
+    "token_id" bigint,
+    CONSTRAINT "magic_links_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "magic_links_uuid_key" UNIQUE ("uuid")
+) WITH (oids = false);
+
+CREATE INDEX "idx_magic_links_deleted_at" ON "public"."magic_links" USING btree ("deleted_at");
+CREATE INDEX "magic_link_uuid" ON "public"."magic_links" USING btree ("uuid");
+CREATE INDEX "magic_link_token" ON "public"."magic_links" USING btree ("token_id");
+
+ALTER TABLE ONLY "public"."magic_links" ADD CONSTRAINT "fk_magic_links_token" FOREIGN KEY (token_id) REFERENCES one_time_tokens(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "magic_links";
+DROP SEQUENCE IF EXISTS magic_links_id_seq;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "public"."users" ADD COLUMN "phone_verified" boolean DEFAULT false NOT NULL;
+ALTER TABLE "public"."users" ADD COLUMN "phone_mfa" boolean DEFAULT false NOT NULL;
+
+CREATE SEQUENCE phone_codes_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."phone_codes" (
+    "id" bigint DEFAULT nextval('phone_codes_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "purpose" text NOT NULL,
+    "phone" text NOT NULL,
+    "digest" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "attempts" bigint DEFAULT 0 NOT NULL,
+    "used_at" timestamptz,
+    "user_id" bigint,
+    CONSTRAINT "phone_codes_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "phone_codes_uuid_key" UNIQUE ("uuid")
+) WITH (oids = false);
+
+CREATE INDEX "idx_phone_codes_deleted_at" ON "public"."phone_codes" USING btree ("deleted_at");
+CREATE INDEX "phone_code_uuid" ON "public"."phone_codes" USING btree ("uuid");
+CREATE INDEX "phone_code_user_purpose" ON "public"."phone_codes" USING btree ("user_id", "purpose");
+
+ALTER TABLE ONLY "public"."phone_codes" ADD CONSTRAINT "fk_phone_codes_user" FOREIGN KEY (user_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "phone_codes";
+DROP SEQUENCE IF EXISTS phone_codes_id_seq;
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "phone_mfa";
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "phone_verified";
+-- +goose StatementEnd
@@ -0,0 +1,97 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE identity_providers_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."identity_providers" (
+    "id" bigint DEFAULT nextval('identity_providers_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "name" text NOT NULL,
+    "display_name" text NOT NULL,
+    "issuer" text NOT NULL,
+    "authorization_url" text NOT NULL,
+    "token_url" text NOT NULL,
+    "jwks_url" text NOT NULL,
+    "client_id" text NOT NULL,
+    "disabled" boolean DEFAULT false NOT NULL,
+    "client_secret" text,
+    "scopes" text[],
+    CONSTRAINT "identity_providers_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "identity_providers_uuid_key" UNIQUE ("uuid"),
+    CONSTRAINT "identity_providers_name_key" UNIQUE ("name")
+) WITH (oids = false);
+
+CREATE INDEX "idx_identity_providers_deleted_at" ON "public"."identity_providers" USING btree ("deleted_at");
+CREATE INDEX "identity_provider_uuid" ON "public"."identity_providers" USING btree ("uuid");
+CREATE INDEX "identity_provider_name" ON "public"."identity_providers" USING btree ("name");
+
+CREATE SEQUENCE federated_identities_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."federated_identities" (
+    "id" bigint DEFAULT nextval('federated_identities_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "subject" text NOT NULL,
+    "email" text,
+    "provider_id" bigint,
+    "user_id" bigint,
+    CONSTRAINT "federated_identities_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "federated_identities_uuid_key" UNIQUE ("uuid")
+) WITH (oids = false);
+
+CREATE INDEX "idx_federated_identities_deleted_at" ON "public"."federated_identities" USING btree ("deleted_at");
+CREATE INDEX "federated_identity_uuid" ON "public"."federated_identities" USING btree ("uuid");
+CREATE UNIQUE INDEX "federated_identity_subject" ON "public"."federated_identities" USING btree ("subject", "provider_id");
+CREATE INDEX "federated_identity_user" ON "public"."federated_identities" USING btree ("user_id");
+
+ALTER TABLE ONLY "public"."federated_identities" ADD CONSTRAINT "fk_federated_identities_provider" FOREIGN KEY (provider_id) REFERENCES identity_providers(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+ALTER TABLE ONLY "public"."federated_identities" ADD CONSTRAINT "fk_federated_identities_user" FOREIGN KEY (user_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+
+CREATE SEQUENCE federated_logins_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."federated_logins" (
+    "id" bigint DEFAULT nextval('federated_logins_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "state_digest" text NOT NULL,
+    "nonce" text NOT NULL,
+    "code_verifier" text NOT NULL,
+    "redirect_uri" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "provider_id" bigint,
+    CONSTRAINT "federated_logins_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "federated_logins_uuid_key" UNIQUE ("uuid"),
+    CONSTRAINT "federated_logins_state_digest_key" UNIQUE ("state_digest")
+) WITH (oids = false);
+
+CREATE INDEX "idx_federated_logins_deleted_at" ON "public"."federated_logins" USING btree ("deleted_at");
+CREATE INDEX "federated_login_uuid" ON "public"."federated_logins" USING btree ("uuid");
+CREATE INDEX "federated_login_state" ON "public"."federated_logins" USING btree ("state_digest");
+
+ALTER TABLE ONLY "public"."federated_logins" ADD CONSTRAINT "fk_federated_logins_provider" FOREIGN KEY (provider_id) REFERENCES identity_providers(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+
+-- Identity provider permissions granted to the staff
+INSERT INTO "public"."permissions" ("created_at", "updated_at", "scope", "description") VALUES
+    (now(), now(), 'provider:all:read', 'Read the identity providers'),
+    (now(), now(), 'provider:all:write', 'Manage the identity providers');
+
+INSERT INTO "public"."role_has_permission" ("role_id", "permission_id")
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'staff' AND permissions.scope IN ('provider:all:read', 'provider:all:write');
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "public"."permissions" WHERE scope IN ('provider:all:read', 'provider:all:write');
+DROP TABLE IF EXISTS "federated_logins";
+DROP SEQUENCE IF EXISTS federated_logins_id_seq;
+DROP TABLE IF EXISTS "federated_identities";
+DROP SEQUENCE IF EXISTS federated_identities_id_seq;
+DROP TABLE IF EXISTS "identity_providers";
+DROP SEQUENCE IF EXISTS identity_providers_id_seq;
+-- +goose StatementEnd
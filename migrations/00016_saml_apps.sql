@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "public"."apps" ADD COLUMN "kind" text DEFAULT 'oauth' NOT NULL;
+ALTER TABLE "public"."apps" ADD COLUMN "entity_id" text;
+ALTER TABLE "public"."apps" ADD COLUMN "name_id_format" text DEFAULT 'email' NOT NULL;
+ALTER TABLE "public"."apps" ADD COLUMN "saml_attributes" jsonb;
+
+CREATE INDEX "app_entity_id" ON "public"."apps" USING btree ("entity_id");
+CREATE UNIQUE INDEX "apps_saml_entity_id_key" ON "public"."apps" USING btree ("entity_id") WHERE kind = 'saml' AND deleted_at IS NULL;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS "apps_saml_entity_id_key";
+DROP INDEX IF EXISTS "app_entity_id";
+ALTER TABLE "public"."apps" DROP COLUMN IF EXISTS "saml_attributes";
+ALTER TABLE "public"."apps" DROP COLUMN IF EXISTS "name_id_format";
+ALTER TABLE "public"."apps" DROP COLUMN IF EXISTS "entity_id";
+ALTER TABLE "public"."apps" DROP COLUMN IF EXISTS "kind";
+-- +goose StatementEnd
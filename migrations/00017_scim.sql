@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE scim_tokens_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."scim_tokens" (
+    "id" bigint DEFAULT nextval('scim_tokens_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "name" text NOT NULL,
+    "digest" text NOT NULL,
+    "last_used_at" timestamptz,
+    CONSTRAINT "scim_tokens_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "scim_tokens_uuid_key" UNIQUE ("uuid"),
+    CONSTRAINT "scim_tokens_digest_key" UNIQUE ("digest")
+) WITH (oids = false);
+
+CREATE INDEX "idx_scim_tokens_deleted_at" ON "public"."scim_tokens" USING btree ("deleted_at");
+CREATE INDEX "scim_token_uuid" ON "public"."scim_tokens" USING btree ("uuid");
+CREATE INDEX "scim_token_digest" ON "public"."scim_tokens" USING btree ("digest");
+
+ALTER TABLE "public"."users" ADD COLUMN "external_id" text;
+CREATE INDEX "usr_external_id" ON "public"."users" USING btree ("external_id");
+
+-- SCIM token permissions granted to the staff
+INSERT INTO "public"."permissions" ("created_at", "updated_at", "scope", "description") VALUES
+    (now(), now(), 'scim:all:read', 'Read the SCIM tokens'),
+    (now(), now(), 'scim:all:write', 'Manage the SCIM tokens');
+
+INSERT INTO "public"."role_has_permission" ("role_id", "permission_id")
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'staff' AND permissions.scope IN ('scim:all:read', 'scim:all:write');
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "public"."permissions" WHERE scope IN ('scim:all:read', 'scim:all:write');
+DROP INDEX IF EXISTS "usr_external_id";
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "external_id";
+DROP TABLE IF EXISTS "scim_tokens";
+DROP SEQUENCE IF EXISTS scim_tokens_id_seq;
+-- +goose StatementEnd
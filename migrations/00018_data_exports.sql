@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE data_exports_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."data_exports" (
+    "id" bigint DEFAULT nextval('data_exports_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "status" text NOT NULL,
+    "archive" text,
+    "ready_at" timestamptz,
+    "expires_at" timestamptz,
+    "user_id" bigint,
+    CONSTRAINT "data_exports_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "data_exports_uuid_key" UNIQUE ("uuid")
+) WITH (oids = false);
+
+CREATE INDEX "idx_data_exports_deleted_at" ON "public"."data_exports" USING btree ("deleted_at");
+CREATE INDEX "data_export_uuid" ON "public"."data_exports" USING btree ("uuid");
+CREATE INDEX "data_export_status" ON "public"."data_exports" USING btree ("status");
+CREATE INDEX "data_export_user" ON "public"."data_exports" USING btree ("user_id");
+
+ALTER TABLE ONLY "public"."data_exports" ADD CONSTRAINT "fk_data_exports_user" FOREIGN KEY (user_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "data_exports";
+DROP SEQUENCE IF EXISTS data_exports_id_seq;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "public"."users" ADD COLUMN "purged_at" timestamptz;
+
+-- Purged users keep their row, so their email can be registered again
+ALTER TABLE "public"."users" DROP CONSTRAINT "users_email_key";
+CREATE UNIQUE INDEX "users_email_key" ON "public"."users" USING btree ("email") WHERE "purged_at" IS NULL;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS "users_email_key";
+ALTER TABLE "public"."users" ADD CONSTRAINT "users_email_key" UNIQUE ("email");
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "purged_at";
+-- +goose StatementEnd
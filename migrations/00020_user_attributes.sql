@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE attribute_definitions_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."attribute_definitions" (
+    "id" bigint DEFAULT nextval('attribute_definitions_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "name" text NOT NULL,
+    "type" text NOT NULL,
+    "user_editable" boolean DEFAULT false NOT NULL,
+    "description" text,
+    "pattern" text,
+    "options" text[],
+    CONSTRAINT "attribute_definitions_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "attribute_definitions_uuid_key" UNIQUE ("uuid"),
+    CONSTRAINT "attribute_definitions_name_key" UNIQUE ("name")
+) WITH (oids = false);
+
+CREATE INDEX "idx_attribute_definitions_deleted_at" ON "public"."attribute_definitions" USING btree ("deleted_at");
+CREATE INDEX "attribute_definition_uuid" ON "public"."attribute_definitions" USING btree ("uuid");
+CREATE INDEX "attribute_definition_name" ON "public"."attribute_definitions" USING btree ("name");
+
+ALTER TABLE "public"."users" ADD COLUMN "attributes" jsonb DEFAULT '{}';
+ALTER TABLE "public"."apps" ADD COLUMN "claim_mapping" jsonb DEFAULT '[]';
+
+-- Attribute definition permissions granted to the staff
+INSERT INTO "public"."permissions" ("created_at", "updated_at", "scope", "description") VALUES
+    (now(), now(), 'attribute:all:read', 'Read the custom attribute definitions'),
+    (now(), now(), 'attribute:all:write', 'Manage the custom attribute definitions');
+
+INSERT INTO "public"."role_has_permission" ("role_id", "permission_id")
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'staff' AND permissions.scope IN ('attribute:all:read', 'attribute:all:write');
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "public"."permissions" WHERE scope IN ('attribute:all:read', 'attribute:all:write');
+ALTER TABLE "public"."apps" DROP COLUMN IF EXISTS "claim_mapping";
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "attributes";
+DROP TABLE IF EXISTS "attribute_definitions";
+DROP SEQUENCE IF EXISTS attribute_definitions_id_seq;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "public"."users" ADD COLUMN "guardian_email" text;
+ALTER TABLE "public"."users" ADD COLUMN "guardian_consent_at" timestamptz;
+ALTER TABLE "public"."apps" ADD COLUMN "minimum_age" integer DEFAULT 0 NOT NULL;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "public"."apps" DROP COLUMN IF EXISTS "minimum_age";
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "guardian_consent_at";
+ALTER TABLE "public"."users" DROP COLUMN IF EXISTS "guardian_email";
+-- +goose StatementEnd
@@ -0,0 +1,71 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE legal_documents_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."legal_documents" (
+    "id" bigint DEFAULT nextval('legal_documents_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "kind" text NOT NULL,
+    "version" text NOT NULL,
+    "title" text NOT NULL,
+    "url" text NOT NULL,
+    "mandatory" boolean DEFAULT false NOT NULL,
+    "published_at" timestamptz,
+    "summary" text,
+    "app_id" bigint,
+    CONSTRAINT "legal_documents_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "legal_documents_uuid_key" UNIQUE ("uuid"),
+    CONSTRAINT "fk_legal_documents_app" FOREIGN KEY (app_id) REFERENCES apps(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE
+) WITH (oids = false);
+
+CREATE INDEX "idx_legal_documents_deleted_at" ON "public"."legal_documents" USING btree ("deleted_at");
+CREATE INDEX "legal_document_uuid" ON "public"."legal_documents" USING btree ("uuid");
+CREATE INDEX "legal_document_app" ON "public"."legal_documents" USING btree ("app_id");
+
+-- Versions are unique per kind, both deployment wide and for every app
+CREATE UNIQUE INDEX "legal_document_version" ON "public"."legal_documents" USING btree ("kind", "version", COALESCE("app_id", 0)) WHERE "deleted_at" IS NULL;
+
+CREATE SEQUENCE legal_acceptances_id_seq INCREMENT 1 MINVALUE 1 MAXVALUE 9223372036854775807 CACHE 1;
+CREATE TABLE "public"."legal_acceptances" (
+    "id" bigint DEFAULT nextval('legal_acceptances_id_seq') NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "uuid" uuid DEFAULT uuid_generate_v4(),
+    "user_id" bigint NOT NULL,
+    "legal_document_id" bigint NOT NULL,
+    "accepted_at" timestamptz NOT NULL,
+    "ip" text,
+    "user_agent" text,
+    CONSTRAINT "legal_acceptances_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "legal_acceptances_uuid_key" UNIQUE ("uuid"),
+    CONSTRAINT "legal_acceptance_document_key" UNIQUE ("user_id", "legal_document_id"),
+    CONSTRAINT "fk_legal_acceptances_user" FOREIGN KEY (user_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE,
+    CONSTRAINT "fk_legal_acceptances_legal_document" FOREIGN KEY (legal_document_id) REFERENCES legal_documents(id) ON UPDATE CASCADE ON DELETE RESTRICT NOT DEFERRABLE
+) WITH (oids = false);
+
+CREATE INDEX "idx_legal_acceptances_deleted_at" ON "public"."legal_acceptances" USING btree ("deleted_at");
+CREATE INDEX "legal_acceptance_uuid" ON "public"."legal_acceptances" USING btree ("uuid");
+CREATE INDEX "legal_acceptance_user" ON "public"."legal_acceptances" USING btree ("user_id");
+
+-- Legal document permissions granted to the staff
+INSERT INTO "public"."permissions" ("created_at", "updated_at", "scope", "description") VALUES
+    (now(), now(), 'legal:all:read', 'Read the legal documents and the acceptances of the users'),
+    (now(), now(), 'legal:all:write', 'Publish the legal documents');
+
+INSERT INTO "public"."role_has_permission" ("role_id", "permission_id")
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'staff' AND permissions.scope IN ('legal:all:read', 'legal:all:write');
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "public"."permissions" WHERE scope IN ('legal:all:read', 'legal:all:write');
+DROP TABLE IF EXISTS "legal_acceptances";
+DROP SEQUENCE IF EXISTS legal_acceptances_id_seq;
+DROP TABLE IF EXISTS "legal_documents";
+DROP SEQUENCE IF EXISTS legal_documents_id_seq;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Support staff act as the users to reproduce their issues
+INSERT INTO "public"."permissions" ("created_at", "updated_at", "scope", "description") VALUES
+    (now(), now(), 'user:all:impersonate', 'Act as any user for support');
+
+INSERT INTO "public"."roles" ("created_at", "updated_at", "name", "description") VALUES
+    (now(), now(), 'support', 'Acts as the users to reproduce their issues through the admin api');
+
+INSERT INTO "public"."role_has_permission" ("role_id", "permission_id")
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'support' AND permissions.scope IN ('user:all:read', 'user:all:impersonate');
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "public"."roles" WHERE name = 'support';
+DELETE FROM "public"."permissions" WHERE scope = 'user:all:impersonate';
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The audit log is append-only, so updates have no effect, as deletes. Only
+-- the references to the users are nulled when they are deleted.
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF (NEW.actor_id IS NULL OR NEW.actor_id IS NOT DISTINCT FROM OLD.actor_id)
+        AND (NEW.subject_id IS NULL OR NEW.subject_id IS NOT DISTINCT FROM OLD.subject_id)
+        AND (NEW.id, NEW.created_at, NEW.uuid, NEW.action, NEW.outcome, NEW.ip, NEW.user_agent, NEW.metadata::text)
+            IS NOT DISTINCT FROM (OLD.id, OLD.created_at, OLD.uuid, OLD.action, OLD.outcome, OLD.ip, OLD.user_agent, OLD.metadata::text)
+    THEN
+        RETURN NEW;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "audit_events_no_update" ON "public"."audit_events";
+CREATE TRIGGER "audit_events_no_update" BEFORE UPDATE ON "public"."audit_events"
+    FOR EACH ROW EXECUTE PROCEDURE audit_events_append_only();
+
+CREATE OR REPLACE RULE "audit_events_no_delete" AS ON DELETE TO "public"."audit_events" DO INSTEAD NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS "audit_events_no_update" ON "public"."audit_events";
+DROP FUNCTION IF EXISTS audit_events_append_only();
+-- +goose StatementEnd
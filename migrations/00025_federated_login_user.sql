@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Logins which have traded their code keep the user the provider vouched
+-- for, so they can be finished once the steps they miss are given
+ALTER TABLE "public"."federated_logins" ADD COLUMN "completed_at" timestamptz;
+ALTER TABLE "public"."federated_logins" ADD COLUMN "user_id" bigint;
+ALTER TABLE ONLY "public"."federated_logins" ADD CONSTRAINT "fk_federated_logins_user" FOREIGN KEY (user_id) REFERENCES users(id) ON UPDATE CASCADE ON DELETE CASCADE NOT DEFERRABLE;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "public"."federated_logins" DROP CONSTRAINT IF EXISTS "fk_federated_logins_user";
+ALTER TABLE "public"."federated_logins" DROP COLUMN IF EXISTS "user_id";
+ALTER TABLE "public"."federated_logins" DROP COLUMN IF EXISTS "completed_at";
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The data export holds all the data of the user, so it gets its own scope
+-- which is granted to the users but never to the apps they authorize
+INSERT INTO "public"."permissions" ("created_at", "updated_at", "scope", "description") VALUES
+    (now(), now(), 'user:me:export', 'Export all the data of self user');
+
+INSERT INTO "public"."role_has_permission" ("role_id", "permission_id")
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'user' AND permissions.scope = 'user:me:export';
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "public"."permissions" WHERE scope = 'user:me:export';
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Any app owner can write the claim mapping of their app, so only the
+-- attributes the staff mark as releasable can be released. The user
+-- editable ones hold nothing the users do not choose themselves, so they
+-- stay releasable, while the rules which release the others are removed.
+ALTER TABLE "public"."attribute_definitions" ADD COLUMN "releasable" boolean DEFAULT false NOT NULL;
+UPDATE "public"."attribute_definitions" SET "releasable" = true WHERE "user_editable";
+
+UPDATE "public"."apps" SET "claim_mapping" = (
+    SELECT COALESCE(jsonb_agg(rule), '[]') FROM jsonb_array_elements(claim_mapping) rule
+    WHERE rule->>'attribute' NOT IN (SELECT name FROM attribute_definitions WHERE NOT releasable)
+) WHERE jsonb_typeof("claim_mapping") = 'array';
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "public"."attribute_definitions" DROP COLUMN IF EXISTS "releasable";
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The links of these notifications carry a one-time code, which must never
+-- reach markup an app controls, so the apps can no longer override them.
+DELETE FROM "public"."notification_templates"
+WHERE "app_id" IS NOT NULL AND "kind" IN ('user-verify-email', 'user-change-password', 'guardian-consent');
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- The deleted overrides cannot be restored
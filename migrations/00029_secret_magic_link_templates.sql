@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The magic link logs the user in, so like the other links carrying a
+-- one-time code it must never reach markup an app controls.
+DELETE FROM "public"."notification_templates"
+WHERE "app_id" IS NOT NULL AND "kind" = 'user-magic-link';
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- The deleted overrides cannot be restored
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Admin actions
+const (
+	AdminActionLogin          = "login"
+	AdminActionListUsers      = "list-users"
+	AdminActionReadUser       = "read-user"
+	AdminActionDisableUser    = "disable-user"
+	AdminActionEnableUser     = "enable-user"
+	AdminActionVerifyUser     = "verify-user"
+	AdminActionResetPassword  = "reset-user-password"
+	AdminActionDeleteUser     = "delete-user"
+	AdminActionListRoles      = "list-roles"
+	AdminActionReadUserRoles  = "read-user-roles"
+	AdminActionAssignRole     = "assign-role"
+	AdminActionRevokeRole     = "revoke-role"
+	AdminActionListSessions   = "list-user-sessions"
+	AdminActionRevokeSession  = "revoke-user-session"
+	AdminActionRevokeAll      = "revoke-user-sessions"
+	AdminActionListAudit      = "list-audit-events"
+	AdminActionListOutbox     = "list-outbox-messages"
+	AdminActionListTemplates  = "list-notification-templates"
+	AdminActionSaveTemplate   = "save-notification-template"
+	AdminActionDropTemplate   = "delete-notification-template"
+	AdminActionPreview        = "preview-notification"
+	AdminActionListProviders  = "list-identity-providers"
+	AdminActionReadProvider   = "read-identity-provider"
+	AdminActionAddProvider    = "create-identity-provider"
+	AdminActionEditProvider   = "update-identity-provider"
+	AdminActionDropProvider   = "delete-identity-provider"
+	AdminActionListSCIM       = "list-scim-tokens"
+	AdminActionAddSCIM        = "create-scim-token"
+	AdminActionDropSCIM       = "delete-scim-token"
+	AdminActionListAttributes = "list-attribute-definitions"
+	AdminActionAddAttribute   = "create-attribute-definition"
+	AdminActionEditAttribute  = "update-attribute-definition"
+	AdminActionDropAttribute  = "delete-attribute-definition"
+	AdminActionSetAttributes  = "set-user-attributes"
+	AdminActionListLegal      = "list-legal-documents"
+	AdminActionAddLegal       = "create-legal-document"
+	AdminActionPublishLegal   = "publish-legal-document"
+	AdminActionDropLegal      = "delete-legal-document"
+	AdminActionReadAcceptance = "read-user-legal-acceptances"
+	AdminActionImpersonate    = "impersonate-user"
+)
+
+// An admin action records an operation performed by a staff user
+// through the admin API
+type AdminAction struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID   uuid.UUID `gorm:"index:admin_action_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Action string    `gorm:"not null"`
+
+	// Optional fields
+	Detail string
+	IP     string
+
+	// Staff user who performed the action
+	Staff   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	StaffID uint
+
+	// User affected by the action
+	Target   *User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	TargetID *uint
+}
+
+// Creates a new admin action
+func NewAdminAction(action string, staff User, target *User, detail string, ip string) AdminAction {
+	adminAction := AdminAction{
+		Action:  action,
+		Detail:  detail,
+		IP:      ip,
+		StaffID: staff.ID,
+	}
+	if target != nil {
+		adminAction.TargetID = &target.ID
+	}
+	return adminAction
+}
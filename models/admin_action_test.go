@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestAdminActionModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor with target", func(t *testing.T) {
+		staff := User{}
+		staff.ID = uint(faker.Number().NumberInt(3))
+		target := User{}
+		target.ID = uint(faker.Number().NumberInt(3))
+		ip := faker.Internet().IpV4Address()
+
+		action := NewAdminAction(AdminActionDisableUser, staff, &target, "", ip)
+
+		assert.Equal(AdminActionDisableUser, action.Action)
+		assert.Equal(staff.ID, action.StaffID)
+		assert.Equal(target.ID, *action.TargetID)
+		assert.Equal(ip, action.IP)
+	})
+
+	t.Run("Test constructor without target", func(t *testing.T) {
+		staff := User{}
+		staff.ID = uint(faker.Number().NumberInt(3))
+
+		action := NewAdminAction(AdminActionListUsers, staff, nil, "john", "")
+
+		assert.Equal(AdminActionListUsers, action.Action)
+		assert.Equal("john", action.Detail)
+		assert.Nil(action.TargetID)
+	})
+}
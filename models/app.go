@@ -1,7 +1,11 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"gandalf/security"
+	"strings"
 
 	"github.com/gofrs/uuid"
 	"github.com/lib/pq"
@@ -10,6 +14,117 @@ import (
 
 const clientSecretLenght = 32
 
+// Protocols the users sign in the apps with
+const (
+	AppKindOauth = "oauth"
+	AppKindSAML  = "saml"
+)
+
+// Formats of the name id which identifies the users to the SAML apps
+const (
+	SAMLNameIDEmail      = "email"
+	SAMLNameIDPersistent = "persistent"
+)
+
+// User fields which can be released to the SAML apps as attributes
+var samlUserFields = map[string]func(User) []string{
+	"uuid":      func(user User) []string { return []string{user.UUID.String()} },
+	"email":     func(user User) []string { return []string{user.Email} },
+	"name":      func(user User) []string { return []string{user.Name} },
+	"surname":   func(user User) []string { return []string{user.Surname} },
+	"full_name": func(user User) []string { return []string{strings.TrimSpace(user.Name + " " + user.Surname)} },
+	"locale":    func(user User) []string { return []string{user.Locale} },
+	"phone": func(user User) []string {
+		if !user.PhoneVerified {
+			return nil
+		}
+		return []string{user.Phone}
+	},
+	"roles": func(user User) []string {
+		roles := []string{}
+		for _, role := range user.Roles {
+			roles = append(roles, role.Name)
+		}
+		return roles
+	},
+}
+
+// Maps the names of the attributes released to a SAML app to the user
+// fields they hold, stored as a JSON object
+type SAMLAttributeMapping map[string]string
+
+// Attributes released to the SAML apps which do not define their mapping
+var DefaultSAMLAttributes = SAMLAttributeMapping{"email": "email", "firstName": "name", "lastName": "surname"}
+
+// Implement driver Valuer interface
+func (mapping SAMLAttributeMapping) Value() (driver.Value, error) {
+	if mapping == nil {
+		return "{}", nil
+	}
+	value, err := json.Marshal(mapping)
+	return string(value), err
+}
+
+// Implement sql Scanner interface
+func (mapping *SAMLAttributeMapping) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*mapping = SAMLAttributeMapping{}
+		return nil
+	default:
+		return errors.New("SAML attributes must be a JSON object")
+	}
+	return json.Unmarshal(data, mapping)
+}
+
+// Tokens of an app the custom attributes of the users can be released in
+const (
+	ClaimTokenAccess = "access"
+	ClaimTokenID     = "id"
+)
+
+// Rule which releases a custom attribute of the users to an app, as the
+// given claim of the given tokens
+type ClaimRule struct {
+	Claim     string   `json:"claim"`
+	Attribute string   `json:"attribute"`
+	Tokens    []string `json:"tokens"`
+}
+
+// Claim mapping rules of an app, stored as a JSON array
+type ClaimMapping []ClaimRule
+
+// Implement driver Valuer interface
+func (mapping ClaimMapping) Value() (driver.Value, error) {
+	if mapping == nil {
+		return "[]", nil
+	}
+	value, err := json.Marshal(mapping)
+	return string(value), err
+}
+
+// Implement sql Scanner interface
+func (mapping *ClaimMapping) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*mapping = ClaimMapping{}
+		return nil
+	default:
+		return errors.New("claim mapping must be a JSON array")
+	}
+	return json.Unmarshal(data, mapping)
+}
+
 // An app represents the application that will use Gandalf as an Oauth2
 // backend. It has a relationship with the user that manage it and those
 // ones that have signed into the app with Gandalf.
@@ -27,10 +142,34 @@ type App struct {
 	IconUrl      string
 	RedirectUrls pq.StringArray `gorm:"type:text[]"`
 
+	// Logout fields. Users can only be redirected to the registered post
+	// logout urls, and the back-channel url is notified when they log out.
+	PostLogoutRedirectUrls pq.StringArray `gorm:"type:text[]"`
+	BackchannelLogoutUrl   string
+
+	// SAML fields. SAML apps are service providers identified by their
+	// entity id, and their redirect urls are the assertion consumer
+	// service urls the signed responses are posted to.
+	Kind           string               `gorm:"not null;default:'oauth'"`
+	EntityID       string               `gorm:"index:app_entity_id"`
+	NameIDFormat   string               `gorm:"not null;default:'email'"`
+	SAMLAttributes SAMLAttributeMapping `gorm:"type:jsonb"`
+
+	// Custom attributes of the users released in the tokens of the app
+	ClaimMapping ClaimMapping `gorm:"type:jsonb"`
+
+	// Users younger than the minimum age cannot sign in the app. No age is
+	// required when it is zero.
+	MinimumAge int `gorm:"not null;default:0"`
+
 	// User
 	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	UserID uint
 
+	// Organization that owns the app, if any
+	Organization   *Organization `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	OrganizationID *uint
+
 	// Users that have been sign on the app
 	ConnectedUsers []User `gorm:"many2many:user_has_signin_on_app;constraint:OnDelete:CASCADE"`
 
@@ -47,12 +186,61 @@ func (app *App) generateClientSecret() {
 	app.ClientSecret = secret
 }
 
+// Returns whether the users sign in the app through SAML
+func (app App) IsSAML() bool {
+	return app.Kind == AppKindSAML
+}
+
+// Returns the attributes released to the app about the given user, by
+// attribute name. Empty values are left out.
+func (app App) SAMLAttributeValues(user User) map[string][]string {
+	mapping := app.SAMLAttributes
+	if len(mapping) == 0 {
+		mapping = DefaultSAMLAttributes
+	}
+
+	attributes := map[string][]string{}
+	for name, field := range mapping {
+		read, found := samlUserFields[field]
+		if !found {
+			continue
+		}
+		values := []string{}
+		for _, value := range read(user) {
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) > 0 {
+			attributes[name] = values
+		}
+	}
+	return attributes
+}
+
+// Returns the claims released to the app in the given token about the
+// given user, by claim name. Attributes the user has not are left out.
+func (app App) MappedClaims(user User, token string) map[string]interface{} {
+	claims := map[string]interface{}{}
+	for _, rule := range app.ClaimMapping {
+		if !containsString(rule.Tokens, token) {
+			continue
+		}
+		if value, found := user.Attributes[rule.Attribute]; found {
+			claims[rule.Claim] = value
+		}
+	}
+	return claims
+}
+
 // Creates a new app
 func NewApp(name string, IconUrl string, RedirectUrls []string, user User) App {
 	app := App{
 		Name:            name,
 		IconUrl:         IconUrl,
 		RedirectUrls:    RedirectUrls,
+		Kind:            AppKindOauth,
+		NameIDFormat:    SAMLNameIDEmail,
 		UserID:          user.ID,
 		secretGenerator: security.NewUniformSecret(),
 	}
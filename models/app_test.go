@@ -57,3 +57,76 @@ func TestAppModel(t *testing.T) {
 		assert.PanicsWithError(expectedError.Error(), func() { app.generateClientSecret() })
 	})
 }
+
+func TestAppSAMLAttributes(t *testing.T) {
+	assert := require.New(t)
+	user := User{Email: "johndoe@acme.com", Name: "John", Surname: "Doe", Phone: "+34600000000"}
+	user.Roles = []Role{{Name: RoleUser}, {Name: RoleStaff}}
+
+	t.Run("Test default attributes are released without mapping", func(t *testing.T) {
+		app := App{Kind: AppKindSAML}
+
+		attributes := app.SAMLAttributeValues(user)
+
+		assert.Equal(map[string][]string{
+			"email":     {"johndoe@acme.com"},
+			"firstName": {"John"},
+			"lastName":  {"Doe"},
+		}, attributes)
+	})
+
+	t.Run("Test mapped attributes are released", func(t *testing.T) {
+		app := App{Kind: AppKindSAML, SAMLAttributes: SAMLAttributeMapping{
+			"displayName": "full_name",
+			"groups":      "roles",
+			"mobile":      "phone",
+			"unknown":     "password",
+		}}
+
+		attributes := app.SAMLAttributeValues(user)
+
+		assert.Equal(map[string][]string{
+			"displayName": {"John Doe"},
+			"groups":      {RoleUser, RoleStaff},
+		}, attributes)
+	})
+
+	t.Run("Test mapping is stored as a JSON object", func(t *testing.T) {
+		mapping := SAMLAttributeMapping{"mail": "email"}
+		value, err := mapping.Value()
+		assert.NoError(err)
+
+		var scanned SAMLAttributeMapping
+		assert.NoError(scanned.Scan(value))
+		assert.Equal(mapping, scanned)
+	})
+}
+
+func TestAppClaimMapping(t *testing.T) {
+	assert := require.New(t)
+	user := User{Attributes: UserAttributes{"employee_id": "E-1234", "plan": "pro"}}
+
+	t.Run("Test mapped attributes are released in their tokens", func(t *testing.T) {
+		app := App{ClaimMapping: ClaimMapping{
+			{Claim: "employee", Attribute: "employee_id", Tokens: []string{ClaimTokenAccess, ClaimTokenID}},
+			{Claim: "plan", Attribute: "plan", Tokens: []string{ClaimTokenID}},
+			{Claim: "department", Attribute: "department", Tokens: []string{ClaimTokenAccess}},
+		}}
+
+		assert.Equal(map[string]interface{}{"employee": "E-1234"}, app.MappedClaims(user, ClaimTokenAccess))
+		assert.Equal(map[string]interface{}{"employee": "E-1234", "plan": "pro"}, app.MappedClaims(user, ClaimTokenID))
+	})
+
+	t.Run("Test mapping is stored as a JSON array", func(t *testing.T) {
+		mapping := ClaimMapping{{Claim: "plan", Attribute: "plan", Tokens: []string{ClaimTokenID}}}
+		value, err := mapping.Value()
+		assert.NoError(err)
+
+		var scanned ClaimMapping
+		assert.NoError(scanned.Scan(value))
+		assert.Equal(mapping, scanned)
+
+		value, _ = ClaimMapping(nil).Value()
+		assert.Equal("[]", value)
+	})
+}
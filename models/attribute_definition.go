@@ -0,0 +1,95 @@
+package models
+
+import (
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Types the custom attributes of the users can have. Dates are written as
+// YYYY-MM-DD strings.
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeInteger = "integer"
+	AttributeTypeBoolean = "boolean"
+	AttributeTypeDate    = "date"
+)
+
+// An attribute definition describes a custom attribute of the users, like
+// their employee id or their plan. The values of the users are checked
+// against it before they are stored, and only the user editable ones can
+// be changed by the users themselves. Only the releasable ones can be
+// released to the apps through their claim mapping.
+type AttributeDefinition struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID         uuid.UUID `gorm:"index:attribute_definition_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Name         string    `gorm:"not null;index:attribute_definition_name;unique"`
+	Type         string    `gorm:"not null"`
+	UserEditable bool      `gorm:"not null;default:false"`
+	Releasable   bool      `gorm:"not null;default:false"`
+
+	// Optional fields. String values must match the whole pattern and be
+	// one of the options when they are given.
+	Description string
+	Pattern     string
+	Options     pq.StringArray `gorm:"type:text[]"`
+}
+
+// Creates a new attribute definition
+func NewAttributeDefinition(name string, kind string, userEditable bool) AttributeDefinition {
+	return AttributeDefinition{
+		Name:         name,
+		Type:         kind,
+		UserEditable: userEditable,
+	}
+}
+
+// Check if the given value, as decoded from JSON, is valid for the
+// attribute
+func (definition AttributeDefinition) Accepts(value interface{}) bool {
+	switch definition.Type {
+	case AttributeTypeString:
+		text, ok := value.(string)
+		if !ok {
+			return false
+		}
+		if len(definition.Options) > 0 && !containsString(definition.Options, text) {
+			return false
+		}
+		if definition.Pattern != "" {
+			matched, err := regexp.MatchString("^(?:"+definition.Pattern+")$", text)
+			return err == nil && matched
+		}
+		return true
+	case AttributeTypeInteger:
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case AttributeTypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case AttributeTypeDate:
+		text, ok := value.(string)
+		if !ok {
+			return false
+		}
+		_, err := time.Parse("2006-01-02", text)
+		return err == nil
+	}
+	return false
+}
+
+// Check if the given values include the given one
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
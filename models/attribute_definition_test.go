@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeDefinitionModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		definition := NewAttributeDefinition("employee_id", AttributeTypeString, false)
+
+		assert.Equal("employee_id", definition.Name)
+		assert.Equal(AttributeTypeString, definition.Type)
+		assert.False(definition.UserEditable)
+	})
+
+	t.Run("Test values are checked against their type", func(t *testing.T) {
+		integer := NewAttributeDefinition("seats", AttributeTypeInteger, false)
+		boolean := NewAttributeDefinition("beta", AttributeTypeBoolean, true)
+		date := NewAttributeDefinition("hired_on", AttributeTypeDate, false)
+
+		assert.True(integer.Accepts(float64(12)))
+		assert.False(integer.Accepts(12.5))
+		assert.False(integer.Accepts("12"))
+		assert.True(boolean.Accepts(true))
+		assert.False(boolean.Accepts("true"))
+		assert.True(date.Accepts("2021-10-19"))
+		assert.False(date.Accepts("19/10/2021"))
+		assert.False(NewAttributeDefinition("other", "unknown", false).Accepts("value"))
+	})
+
+	t.Run("Test strings are checked against their pattern and options", func(t *testing.T) {
+		employee := NewAttributeDefinition("employee_id", AttributeTypeString, false)
+		employee.Pattern = "E-[0-9]+"
+		plan := NewAttributeDefinition("plan", AttributeTypeString, false)
+		plan.Options = []string{"free", "pro"}
+
+		assert.True(employee.Accepts("E-1234"))
+		assert.False(employee.Accepts("XE-1234"))
+		assert.False(employee.Accepts(float64(1234)))
+		assert.True(plan.Accepts("pro"))
+		assert.False(plan.Accepts("enterprise"))
+	})
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Audited actions
+const (
+	AuditActionLogin         = "login"
+	AuditActionAuthorizeApp  = "authorize-app"
+	AuditActionExchangeToken = "exchange-token"
+	AuditActionResetPassword = "reset-password"
+	AuditActionDeleteUser    = "delete-user"
+	AuditActionCreateApp     = "create-app"
+	AuditActionUpdateApp     = "update-app"
+	AuditActionDeleteApp     = "delete-app"
+	AuditActionTransferApp   = "transfer-app"
+	AuditActionDisconnectApp = "disconnect-app"
+	AuditActionLockAccount   = "lock-account"
+	AuditActionVerifyPhone   = "verify-phone"
+	AuditActionPhoneMFA      = "phone-mfa"
+	AuditActionLinkIdentity  = "link-identity"
+	AuditActionProvision     = "provision-user"
+	AuditActionDeprovision   = "deprovision-user"
+	AuditActionExportData    = "export-data"
+	AuditActionRestoreUser   = "restore-user"
+	AuditActionPurgeUser     = "purge-user"
+	AuditActionSetAttributes = "set-attributes"
+	AuditActionGuardian      = "guardian-consent"
+	AuditActionAcceptLegal   = "accept-legal-documents"
+	AuditActionImpersonate   = "impersonate-user"
+	AuditActionImpersonated  = "impersonated-request"
+)
+
+// Outcomes of an audited action
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// Free form details about an audited action, stored as a JSON object
+type AuditMetadata map[string]string
+
+// Implement driver Valuer interface
+func (metadata AuditMetadata) Value() (driver.Value, error) {
+	if metadata == nil {
+		return "{}", nil
+	}
+	value, err := json.Marshal(metadata)
+	return string(value), err
+}
+
+// Implement sql Scanner interface
+func (metadata *AuditMetadata) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*metadata = AuditMetadata{}
+		return nil
+	default:
+		return errors.New("audit metadata must be a JSON object")
+	}
+	return json.Unmarshal(data, metadata)
+}
+
+// An audit event records a security relevant action, who performed it,
+// who was affected by it and whether it succeeded. Audit events are
+// append-only, so they are never updated nor soft deleted.
+type AuditEvent struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index:audit_event_created_at"`
+
+	// Mandatory fields
+	UUID    uuid.UUID `gorm:"index:audit_event_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Action  string    `gorm:"not null;index:audit_event_action"`
+	Outcome string    `gorm:"not null"`
+
+	// Optional fields
+	IP        string
+	UserAgent string
+	Metadata  AuditMetadata `gorm:"type:jsonb"`
+
+	// User who performed the action, if known
+	Actor   *User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	ActorID *uint `gorm:"index:audit_event_actor"`
+
+	// User affected by the action, if any
+	Subject   *User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	SubjectID *uint `gorm:"index:audit_event_subject"`
+}
+
+// Creates a new audit event. Both actor and subject are optional.
+func NewAuditEvent(action string, outcome string, actor *User, subject *User, userAgent string, ip string, metadata AuditMetadata) AuditEvent {
+	event := AuditEvent{
+		Action:    action,
+		Outcome:   outcome,
+		IP:        ip,
+		UserAgent: userAgent,
+		Metadata:  metadata,
+	}
+	if actor != nil {
+		event.ActorID = &actor.ID
+	}
+	if subject != nil {
+		event.SubjectID = &subject.ID
+	}
+	return event
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestAuditEventModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test audit event constructor", func(t *testing.T) {
+		actor := User{}
+		actor.ID = uint(faker.Number().NumberInt(3))
+		userAgent := faker.Internet().UserName()
+		ip := faker.Internet().IpV4Address()
+
+		event := NewAuditEvent(AuditActionLogin, AuditOutcomeSuccess, &actor, nil, userAgent, ip, nil)
+
+		assert.Equal(AuditActionLogin, event.Action)
+		assert.Equal(AuditOutcomeSuccess, event.Outcome)
+		assert.Equal(actor.ID, *event.ActorID)
+		assert.Nil(event.SubjectID)
+		assert.Equal(userAgent, event.UserAgent)
+		assert.Equal(ip, event.IP)
+	})
+
+	t.Run("Test audit metadata value and scan", func(t *testing.T) {
+		metadata := AuditMetadata{"email": faker.Internet().Email()}
+
+		value, err := metadata.Value()
+		assert.NoError(err)
+
+		var scanned AuditMetadata
+		assert.NoError(scanned.Scan([]byte(value.(string))))
+		assert.Equal(metadata, scanned)
+	})
+
+	t.Run("Test empty audit metadata", func(t *testing.T) {
+		value, err := AuditMetadata(nil).Value()
+		assert.NoError(err)
+		assert.Equal("{}", value)
+
+		var scanned AuditMetadata
+		assert.NoError(scanned.Scan(nil))
+		assert.Equal(AuditMetadata{}, scanned)
+		assert.Error(scanned.Scan(1))
+	})
+}
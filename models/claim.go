@@ -25,6 +25,10 @@ type Claim struct {
 	// App
 	App   App `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	AppID uint
+
+	// Session started for the app when the user authorized it
+	Session   *Session `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	SessionID *uint
 }
 
 // Creates a new claim
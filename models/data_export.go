@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Data export statuses
+const (
+	DataExportPending = "pending"
+	DataExportReady   = "ready"
+	DataExportExpired = "expired"
+)
+
+// A data export is a JSON archive with everything gandalf knows about a
+// user, built in background after he asks for it. The archive can be
+// downloaded until the export expires, when it is wiped.
+type DataExport struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID   uuid.UUID `gorm:"index:data_export_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Status string    `gorm:"not null;index:data_export_status"`
+
+	// Optional fields
+	Archive   string
+	ReadyAt   *time.Time
+	ExpiresAt *time.Time
+
+	// User
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID uint `gorm:"index:data_export_user"`
+}
+
+// Creates a new pending data export for the given user
+func NewDataExport(user User) DataExport {
+	return DataExport{
+		Status: DataExportPending,
+		UserID: user.ID,
+	}
+}
+
+// Marks the export as ready with the given archive, which can be
+// downloaded for the given time
+func (export *DataExport) Complete(archive string, ttl time.Duration) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	export.Status = DataExportReady
+	export.Archive = archive
+	export.ReadyAt = &now
+	export.ExpiresAt = &expiresAt
+}
+
+// Check if the archive of the export can be downloaded
+func (export DataExport) IsAvailable() bool {
+	return export.Status == DataExportReady && export.ExpiresAt != nil && time.Now().Before(*export.ExpiresAt)
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataExportModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		user := User{}
+		user.ID = 3
+
+		export := NewDataExport(user)
+
+		assert.Equal(DataExportPending, export.Status)
+		assert.Equal(user.ID, export.UserID)
+		assert.False(export.IsAvailable())
+	})
+
+	t.Run("Test completed exports are available until they expire", func(t *testing.T) {
+		export := DataExport{Status: DataExportPending}
+
+		export.Complete(`{"profile": {}}`, time.Hour)
+
+		assert.Equal(DataExportReady, export.Status)
+		assert.Equal(`{"profile": {}}`, export.Archive)
+		assert.NotNil(export.ReadyAt)
+		assert.True(export.IsAvailable())
+
+		expiresAt := time.Now().Add(-time.Minute)
+		export.ExpiresAt = &expiresAt
+		assert.False(export.IsAvailable())
+	})
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// A federated identity links an user to the account he has on an upstream
+// identity provider, so he can sign in with it. The account is identified
+// by the subject the provider gives it, which never changes.
+type FederatedIdentity struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID    uuid.UUID `gorm:"index:federated_identity_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Subject string    `gorm:"not null;index:federated_identity_subject,unique"`
+
+	// Optional fields
+	Email string
+
+	// Upstream identity provider
+	Provider   IdentityProvider `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ProviderID uint             `gorm:"index:federated_identity_subject,unique"`
+
+	// User
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID uint
+}
+
+// Creates a new identity which links the given user to the account with the
+// given subject on the given provider
+func NewFederatedIdentity(provider IdentityProvider, user User, subject string, email string) FederatedIdentity {
+	return FederatedIdentity{
+		Subject:    subject,
+		Email:      email,
+		ProviderID: provider.ID,
+		UserID:     user.ID,
+	}
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFederatedIdentity(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		provider := IdentityProvider{}
+		provider.ID = 3
+		user := User{}
+		user.ID = 5
+
+		identity := NewFederatedIdentity(provider, user, "subject", "johndoe@example.com")
+
+		assert.Equal(uint(3), identity.ProviderID)
+		assert.Equal(uint(5), identity.UserID)
+		assert.Equal("subject", identity.Subject)
+		assert.Equal("johndoe@example.com", identity.Email)
+	})
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"gandalf/security"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	federatedStateLength    = 48
+	federatedNonceLength    = 48
+	federatedVerifierLength = 64
+)
+
+// A federated login is an authorization request sent to an upstream
+// identity provider and not answered yet. The state it is looked up by is
+// only known by the browser which started it, so only the digest is stored.
+// The nonce and the PKCE verifier bind the answer of the provider to it.
+// Once the code has been traded, the login keeps the user the provider
+// vouched for until it is completed, so the steps the user misses, like
+// the second factor, can be given without asking the provider again.
+type FederatedLogin struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID         uuid.UUID `gorm:"index:federated_login_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	StateDigest  string    `gorm:"not null;index:federated_login_state;unique"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	RedirectURI  string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+
+	// Optional fields
+	UsedAt      *time.Time
+	CompletedAt *time.Time
+
+	// Upstream identity provider
+	Provider   IdentityProvider `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ProviderID uint
+
+	// User the provider vouched for
+	User   *User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID *uint
+
+	// Untracked fields
+	state           string                    `gorm:"-"`
+	secretGenerator security.ISecretGenerator `gorm:"-"`
+}
+
+// Generates the state, the nonce and the PKCE verifier of the login
+func (login *FederatedLogin) generateSecrets() {
+	secrets := make([]string, 3)
+	for i, length := range []int{federatedStateLength, federatedNonceLength, federatedVerifierLength} {
+		secret, err := login.secretGenerator.GenerateSecret(length)
+		if err != nil {
+			panic(err)
+		}
+		secrets[i] = secret
+	}
+
+	login.state = secrets[0]
+	login.StateDigest = security.Sha256Digest(secrets[0])
+	login.Nonce = secrets[1]
+	login.CodeVerifier = secrets[2]
+}
+
+// Returns the plain state of the login. It is only available for logins
+// that have just been started.
+func (login FederatedLogin) State() string {
+	return login.state
+}
+
+// Returns the PKCE challenge sent to the provider
+func (login FederatedLogin) CodeChallenge() string {
+	return security.PKCEChallenge(login.CodeVerifier)
+}
+
+// Check if the code of the login can still be traded
+func (login FederatedLogin) IsUsable() bool {
+	return login.UsedAt == nil && time.Now().Before(login.ExpiresAt)
+}
+
+// Check if the code of the login has been traded and the login still
+// waits for the steps the user missed
+func (login FederatedLogin) IsPending() bool {
+	return login.UserID != nil && login.CompletedAt == nil && time.Now().Before(login.ExpiresAt)
+}
+
+// Creates a new login against the given provider, which will send the
+// browser back to the given redirect uri
+func NewFederatedLogin(provider IdentityProvider, redirectURI string, ttl time.Duration) FederatedLogin {
+	login := FederatedLogin{
+		RedirectURI:     redirectURI,
+		ExpiresAt:       time.Now().Add(ttl),
+		ProviderID:      provider.ID,
+		secretGenerator: security.NewUniformSecret(),
+	}
+	login.generateSecrets()
+	return login
+}
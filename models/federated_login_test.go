@@ -0,0 +1,55 @@
+package models
+
+import (
+	"gandalf/security"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFederatedLogin(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		provider := IdentityProvider{}
+		provider.ID = 3
+		login := NewFederatedLogin(provider, "https://app.test/callback", time.Minute)
+
+		assert.Equal(uint(3), login.ProviderID)
+		assert.Equal("https://app.test/callback", login.RedirectURI)
+		assert.Equal(federatedStateLength, len(login.State()))
+		assert.Equal(security.Sha256Digest(login.State()), login.StateDigest)
+		assert.Equal(federatedNonceLength, len(login.Nonce))
+		assert.Equal(federatedVerifierLength, len(login.CodeVerifier))
+		assert.Equal(security.PKCEChallenge(login.CodeVerifier), login.CodeChallenge())
+	})
+
+	t.Run("Test logins are single use and short lived", func(t *testing.T) {
+		login := NewFederatedLogin(IdentityProvider{}, "https://app.test/callback", time.Minute)
+		assert.True(login.IsUsable())
+
+		now := time.Now()
+		login.UsedAt = &now
+		assert.False(login.IsUsable())
+
+		expired := NewFederatedLogin(IdentityProvider{}, "https://app.test/callback", -time.Minute)
+		assert.False(expired.IsUsable())
+	})
+	t.Run("Test logins wait for the missing steps until completed", func(t *testing.T) {
+		login := NewFederatedLogin(IdentityProvider{}, "https://app.test/callback", time.Minute)
+		assert.False(login.IsPending())
+
+		userID := uint(7)
+		login.UserID = &userID
+		assert.True(login.IsPending())
+
+		now := time.Now()
+		login.CompletedAt = &now
+		assert.False(login.IsPending())
+
+		expired := NewFederatedLogin(IdentityProvider{}, "https://app.test/callback", -time.Minute)
+		expired.UserID = &userID
+		assert.False(expired.IsPending())
+	})
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// The scope every upstream authorization request asks for
+const openIDScope = "openid"
+
+// An identity provider is an upstream OIDC provider, like a corporate
+// directory or a social network, the users can sign in with. Gandalf acts
+// as a relying party of it, registered with the given client credentials.
+type IdentityProvider struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID             uuid.UUID `gorm:"index:identity_provider_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Name             string    `gorm:"not null;index:identity_provider_name;unique"`
+	DisplayName      string    `gorm:"not null"`
+	Issuer           string    `gorm:"not null"`
+	AuthorizationURL string    `gorm:"not null"`
+	TokenURL         string    `gorm:"not null"`
+	JWKSURL          string    `gorm:"not null"`
+	ClientID         string    `gorm:"not null"`
+	Disabled         bool      `gorm:"not null;default:false"`
+
+	// Optional fields
+	ClientSecret string
+	Scopes       pq.StringArray `gorm:"type:text[]"`
+}
+
+// Returns the scopes asked to the provider, which always include openid
+func (provider IdentityProvider) RequestedScopes() []string {
+	scopes := []string{openIDScope}
+	for _, scope := range provider.Scopes {
+		if scope != openIDScope {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// Returns the url of the provider the browser must be sent to in order to
+// start the given login
+func (provider IdentityProvider) AuthorizationRequestURL(login FederatedLogin) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.ClientID)
+	query.Set("redirect_uri", login.RedirectURI)
+	query.Set("scope", strings.Join(provider.RequestedScopes(), " "))
+	query.Set("state", login.State())
+	query.Set("nonce", login.Nonce)
+	query.Set("code_challenge", login.CodeChallenge())
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(provider.AuthorizationURL, "?") {
+		separator = "&"
+	}
+	return provider.AuthorizationURL + separator + query.Encode()
+}
+
+// Creates a new identity provider
+func NewIdentityProvider(name string, displayName string, issuer string, clientID string, clientSecret string) IdentityProvider {
+	return IdentityProvider{
+		Name:         name,
+		DisplayName:  displayName,
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityProvider(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		provider := NewIdentityProvider("acme", "Acme", "https://idp.acme.test", "client", "secret")
+
+		assert.Equal("acme", provider.Name)
+		assert.Equal("Acme", provider.DisplayName)
+		assert.Equal("https://idp.acme.test", provider.Issuer)
+		assert.Equal("client", provider.ClientID)
+		assert.Equal("secret", provider.ClientSecret)
+		assert.False(provider.Disabled)
+	})
+
+	t.Run("Test openid is always requested once", func(t *testing.T) {
+		provider := NewIdentityProvider("acme", "Acme", "https://idp.acme.test", "client", "")
+		provider.Scopes = []string{"email", "openid", "profile"}
+
+		assert.Equal([]string{"openid", "email", "profile"}, provider.RequestedScopes())
+	})
+
+	t.Run("Test authorization request url", func(t *testing.T) {
+		provider := NewIdentityProvider("acme", "Acme", "https://idp.acme.test", "client", "")
+		provider.AuthorizationURL = "https://idp.acme.test/authorize?tenant=1"
+		provider.Scopes = []string{"email"}
+		login := NewFederatedLogin(provider, "https://app.test/callback", time.Minute)
+
+		requestURL := provider.AuthorizationRequestURL(login)
+		parsed, err := url.Parse(requestURL)
+		query := parsed.Query()
+
+		assert.NoError(err)
+		assert.True(strings.HasPrefix(requestURL, "https://idp.acme.test/authorize?"))
+		assert.Equal("1", query.Get("tenant"))
+		assert.Equal("code", query.Get("response_type"))
+		assert.Equal("client", query.Get("client_id"))
+		assert.Equal("https://app.test/callback", query.Get("redirect_uri"))
+		assert.Equal("openid email", query.Get("scope"))
+		assert.Equal(login.State(), query.Get("state"))
+		assert.Equal(login.Nonce, query.Get("nonce"))
+		assert.Equal(login.CodeChallenge(), query.Get("code_challenge"))
+		assert.Equal("S256", query.Get("code_challenge_method"))
+	})
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Kinds of legal documents the users accept
+const (
+	LegalDocumentTerms   = "terms"
+	LegalDocumentPrivacy = "privacy"
+)
+
+// A legal document is a version of the terms of service or of the privacy
+// policy. Documents without app are deployment wide, and the ones of an app
+// are shown when the users authorize it. Once published they cannot be
+// changed, as the acceptances of the users refer to them.
+type LegalDocument struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID    uuid.UUID `gorm:"index:legal_document_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Kind    string    `gorm:"not null"`
+	Version string    `gorm:"not null"`
+	Title   string    `gorm:"not null"`
+	URL     string    `gorm:"not null"`
+
+	// Mandatory documents must be accepted to log in or to authorize their
+	// app once they are published
+	Mandatory   bool `gorm:"not null;default:false"`
+	PublishedAt *time.Time
+
+	// Optional fields
+	Summary string
+
+	// App which attaches the document, if any
+	App   *App  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	AppID *uint `gorm:"index:legal_document_app"`
+}
+
+// Creates a new unpublished legal document, deployment wide when no app is
+// given
+func NewLegalDocument(kind string, version string, title string, url string, mandatory bool, app *App) LegalDocument {
+	document := LegalDocument{
+		Kind:      kind,
+		Version:   version,
+		Title:     title,
+		URL:       url,
+		Mandatory: mandatory,
+	}
+	if app != nil {
+		document.AppID = &app.ID
+	}
+	return document
+}
+
+// Check if the document is published
+func (document LegalDocument) IsPublished() bool {
+	return document.PublishedAt != nil
+}
+
+// Publish the document at the given time
+func (document *LegalDocument) Publish(at time.Time) {
+	document.PublishedAt = &at
+}
+
+// A legal acceptance is the proof that an user accepted a version of a
+// legal document, and of when and from where he did it
+type LegalAcceptance struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID            uuid.UUID     `gorm:"index:legal_acceptance_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	User            User          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID          uint          `gorm:"not null;index:legal_acceptance_user"`
+	LegalDocument   LegalDocument `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+	LegalDocumentID uint          `gorm:"not null"`
+	AcceptedAt      time.Time     `gorm:"not null"`
+
+	// Client the document was accepted from
+	IP        string
+	UserAgent string
+}
+
+// Creates a new acceptance of the given document by the given user
+func NewLegalAcceptance(user User, document LegalDocument, ip string, userAgent string) LegalAcceptance {
+	return LegalAcceptance{
+		UserID:          user.ID,
+		LegalDocumentID: document.ID,
+		AcceptedAt:      time.Now(),
+		IP:              ip,
+		UserAgent:       userAgent,
+	}
+}
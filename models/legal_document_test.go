@@ -0,0 +1,56 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegalDocumentModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test deployment document constructor", func(t *testing.T) {
+		document := NewLegalDocument(LegalDocumentTerms, "2021-10", "Terms of service", "https://example.com/terms", true, nil)
+
+		assert.Equal(LegalDocumentTerms, document.Kind)
+		assert.Equal("2021-10", document.Version)
+		assert.True(document.Mandatory)
+		assert.Nil(document.AppID)
+		assert.False(document.IsPublished())
+	})
+
+	t.Run("Test app document constructor", func(t *testing.T) {
+		app := App{}
+		app.ID = 3
+
+		document := NewLegalDocument(LegalDocumentPrivacy, "v2", "Privacy policy", "https://example.com/privacy", false, &app)
+
+		assert.Equal(app.ID, *document.AppID)
+		assert.False(document.Mandatory)
+	})
+
+	t.Run("Test publish", func(t *testing.T) {
+		document := NewLegalDocument(LegalDocumentTerms, "v1", "Terms", "https://example.com/terms", true, nil)
+		now := time.Now()
+
+		document.Publish(now)
+
+		assert.True(document.IsPublished())
+		assert.Equal(now, *document.PublishedAt)
+	})
+
+	t.Run("Test acceptance constructor", func(t *testing.T) {
+		user := User{}
+		user.ID = 7
+		document := NewLegalDocument(LegalDocumentTerms, "v1", "Terms", "https://example.com/terms", true, nil)
+		document.ID = 2
+
+		acceptance := NewLegalAcceptance(user, document, "203.0.113.7", "Firefox")
+
+		assert.Equal(user.ID, acceptance.UserID)
+		assert.Equal(document.ID, acceptance.LegalDocumentID)
+		assert.Equal("203.0.113.7", acceptance.IP)
+		assert.WithinDuration(time.Now(), acceptance.AcceptedAt, time.Second)
+	})
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"crypto/subtle"
+	"gandalf/security"
+
+	"github.com/gofrs/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// A magic link lets an user log in by following a link mailed to him. It
+// is bound to the browser which asked for it through the digest of a secret
+// only that browser knows, so forwarded links cannot be used, and it may
+// carry the oauth authorize request the login continues.
+type MagicLink struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID          uuid.UUID `gorm:"index:magic_link_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	BindingDigest string    `gorm:"not null"`
+
+	// Pending authorize request, if any
+	ClientID    *uuid.UUID `gorm:"type:uuid"`
+	RedirectURI string
+	Scopes      pq.StringArray `gorm:"type:text[]"`
+	State       string
+
+	// One time token mailed to the user
+	Token   OneTimeToken `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	TokenID uint
+}
+
+// Check if the given binding secret is the one of the browser which asked
+// for the link
+func (link MagicLink) IsBoundTo(binding string) bool {
+	digest := security.Sha256Digest(binding)
+	return subtle.ConstantTimeCompare([]byte(digest), []byte(link.BindingDigest)) == 1
+}
+
+// Check if the login continues an oauth authorize request
+func (link MagicLink) HasPendingAuthorization() bool {
+	return link.ClientID != nil
+}
+
+// Creates a new magic link for the given token, bound to the browser which
+// holds the given binding secret
+func NewMagicLink(token OneTimeToken, binding string) MagicLink {
+	return MagicLink{
+		BindingDigest: security.Sha256Digest(binding),
+		TokenID:       token.ID,
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMagicLink(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		token := OneTimeToken{}
+		token.ID = 7
+		link := NewMagicLink(token, "binding")
+
+		assert.Equal(uint(7), link.TokenID)
+		assert.NotEqual("binding", link.BindingDigest)
+		assert.False(link.HasPendingAuthorization())
+	})
+
+	t.Run("Test link is bound to the browser which asked for it", func(t *testing.T) {
+		link := NewMagicLink(OneTimeToken{}, "binding")
+
+		assert.True(link.IsBoundTo("binding"))
+		assert.False(link.IsBoundTo("forwarded"))
+		assert.False(link.IsBoundTo(""))
+	})
+
+	t.Run("Test pending authorization", func(t *testing.T) {
+		link := NewMagicLink(OneTimeToken{}, "binding")
+		clientID := uuid.Must(uuid.NewV4())
+		link.ClientID = &clientID
+
+		assert.True(link.HasPendingAuthorization())
+	})
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Locale the notifications are rendered in when no template exists for the
+// locale of the user
+const DefaultLocale = "en"
+
+// Notification kinds which can be templated
+var NotificationTemplateKinds = []string{
+	OutboxKindUserVerifyEmail,
+	OutboxKindUserChangePassword,
+	OutboxKindUserSignupAttempt,
+	OutboxKindOrganizationInvitation,
+	OutboxKindUserMagicLink,
+	OutboxKindUserDataExport,
+	OutboxKindGuardianConsent,
+	OutboxKindAlertPasswordChanged,
+	OutboxKindAlertAppAuthorized,
+	OutboxKindAlertNewDevice,
+	OutboxKindAlertImpersonated,
+	OutboxKindAlertPhoneChanged,
+}
+
+// Notification kinds whose link carries a one-time code which logs in or
+// verifies the user. Apps cannot override them, so the code never reaches
+// markup an app controls.
+var SecretNotificationKinds = []string{
+	OutboxKindUserVerifyEmail,
+	OutboxKindUserChangePassword,
+	OutboxKindUserMagicLink,
+	OutboxKindGuardianConsent,
+}
+
+// Check if the apps can override the templates of the given notification
+// kind
+func IsAppTemplatable(kind string) bool {
+	for _, secret := range SecretNotificationKinds {
+		if kind == secret {
+			return false
+		}
+	}
+	return true
+}
+
+// A notification template overrides the built-in content of a notification
+// kind for a locale. Templates without app are deployment wide, and the
+// ones of an app are only used for the notifications sent on its behalf.
+type NotificationTemplate struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID    uuid.UUID `gorm:"index:notification_template_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Kind    string    `gorm:"not null"`
+	Locale  string    `gorm:"not null"`
+	Subject string    `gorm:"not null"`
+	Text    string    `gorm:"not null"`
+
+	// Optional fields
+	HTML string
+
+	// App which overrides the template, if any
+	App   *App  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	AppID *uint `gorm:"index:notification_template_app"`
+}
+
+// Creates a new notification template, deployment wide when no app is given
+func NewNotificationTemplate(kind string, locale string, subject string, text string, html string, app *App) NotificationTemplate {
+	template := NotificationTemplate{
+		Kind:    kind,
+		Locale:  locale,
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	}
+	if app != nil {
+		template.AppID = &app.ID
+	}
+	return template
+}
+
+// Returns the locales a notification is looked up in for the given one,
+// from the most to the least specific: the locale itself, its base language
+// and the default locale.
+func LocaleFallbacks(locale string) []string {
+	locales := []string{}
+	seen := map[string]bool{}
+	base := strings.SplitN(locale, "-", 2)[0]
+	for _, candidate := range []string{locale, base, DefaultLocale} {
+		if candidate != "" && !seen[candidate] {
+			seen[candidate] = true
+			locales = append(locales, candidate)
+		}
+	}
+	return locales
+}
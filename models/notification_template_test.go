@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationTemplateModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test deployment template constructor", func(t *testing.T) {
+		template := NewNotificationTemplate(OutboxKindUserVerifyEmail, "es", "Hola", "{{.Link}}", "", nil)
+
+		assert.Equal(OutboxKindUserVerifyEmail, template.Kind)
+		assert.Equal("es", template.Locale)
+		assert.Equal("Hola", template.Subject)
+		assert.Nil(template.AppID)
+	})
+
+	t.Run("Test app template constructor", func(t *testing.T) {
+		app := App{}
+		app.ID = 3
+
+		template := NewNotificationTemplate(OutboxKindUserVerifyEmail, "en", "Hi", "{{.Link}}", "<p>{{.Link}}</p>", &app)
+
+		assert.Equal(app.ID, *template.AppID)
+		assert.Equal("<p>{{.Link}}</p>", template.HTML)
+	})
+
+	t.Run("Test locale fallbacks", func(t *testing.T) {
+		assert.Equal([]string{"es-AR", "es", DefaultLocale}, LocaleFallbacks("es-AR"))
+		assert.Equal([]string{"es", DefaultLocale}, LocaleFallbacks("es"))
+		assert.Equal([]string{DefaultLocale}, LocaleFallbacks(DefaultLocale))
+		assert.Equal([]string{DefaultLocale}, LocaleFallbacks(""))
+	})
+
+	t.Run("Test kinds with secret links are not app templatable", func(t *testing.T) {
+		assert.False(IsAppTemplatable(OutboxKindUserChangePassword))
+		assert.False(IsAppTemplatable(OutboxKindUserVerifyEmail))
+		assert.False(IsAppTemplatable(OutboxKindUserMagicLink))
+		assert.True(IsAppTemplatable(OutboxKindUserSignupAttempt))
+	})
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"gandalf/security"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+const oneTimeTokenLenght = 48
+
+// One time token purposes
+const (
+	TokenPurposeVerifyUser    = "verify-user"
+	TokenPurposeResetPassword = "reset-password"
+	TokenPurposeLockAccount   = "lock-account"
+	TokenPurposeMagicLink     = "magic-link"
+	TokenPurposeGuardian      = "guardian-consent"
+)
+
+// A one time token is a short lived secret mailed to the user in order to
+// perform a single sensitive action, like verifying his email or resetting
+// his password. Only the digest of the secret is stored in the database, and
+// a token can only be used once.
+type OneTimeToken struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID      uuid.UUID `gorm:"index:ott_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Purpose   string    `gorm:"not null"`
+	Digest    string    `gorm:"not null;index:ott_digest;unique"`
+	ExpiresAt time.Time `gorm:"not null"`
+
+	// Optional fields
+	UsedAt *time.Time
+
+	// User
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID uint
+
+	// Untracked fields
+	secret          string                    `gorm:"-"`
+	secretGenerator security.ISecretGenerator `gorm:"-"`
+}
+
+// Generates the token secret and stores its digest
+func (token *OneTimeToken) generateSecret() {
+	secret, err := token.secretGenerator.GenerateSecret(oneTimeTokenLenght)
+	if err != nil {
+		panic(err)
+	}
+	token.secret = secret
+	token.Digest = security.Sha256Digest(secret)
+}
+
+// Returns the plain secret of the token. It is only available for
+// tokens that have just been created.
+func (token OneTimeToken) Secret() string {
+	return token.secret
+}
+
+// Check if the token can still be used
+func (token OneTimeToken) IsUsable() bool {
+	return token.UsedAt == nil && time.Now().Before(token.ExpiresAt)
+}
+
+// Creates a new one time token for the given user and purpose
+func NewOneTimeToken(user User, purpose string, ttl time.Duration) OneTimeToken {
+	token := OneTimeToken{
+		Purpose:         purpose,
+		ExpiresAt:       time.Now().Add(ttl),
+		UserID:          user.ID,
+		secretGenerator: security.NewUniformSecret(),
+	}
+	token.generateSecret()
+	return token
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"errors"
+	"gandalf/security"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestOneTimeTokenModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor success", func(t *testing.T) {
+		user := User{}
+		user.ID = uint(faker.Number().NumberInt(3))
+
+		token := NewOneTimeToken(user, TokenPurposeVerifyUser, time.Minute)
+
+		assert.Equal(TokenPurposeVerifyUser, token.Purpose)
+		assert.Equal(user.ID, token.UserID)
+		assert.Equal(oneTimeTokenLenght, len(token.Secret()))
+		assert.Equal(security.Sha256Digest(token.Secret()), token.Digest)
+		assert.True(token.IsUsable())
+	})
+
+	t.Run("Test constructor fail", func(t *testing.T) {
+		expectedError := errors.New("Whoops")
+		token := OneTimeToken{
+			secretGenerator: &mockedSecretGenerator{generateSecretError: expectedError},
+		}
+
+		assert.PanicsWithError(expectedError.Error(), func() { token.generateSecret() })
+	})
+
+	t.Run("Test used token is not usable", func(t *testing.T) {
+		usedAt := time.Now()
+		token := OneTimeToken{ExpiresAt: time.Now().Add(time.Minute), UsedAt: &usedAt}
+
+		assert.False(token.IsUsable())
+	})
+
+	t.Run("Test expired token is not usable", func(t *testing.T) {
+		token := OneTimeToken{ExpiresAt: time.Now().Add(-time.Minute)}
+
+		assert.False(token.IsUsable())
+	})
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"gandalf/security"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+const invitationSecretLenght = 48
+
+// Membership roles, sorted from the most to the least privileged
+const (
+	MembershipOwner  = "owner"
+	MembershipAdmin  = "admin"
+	MembershipMember = "member"
+)
+
+var membershipRoleRanks = map[string]int{
+	MembershipOwner:  3,
+	MembershipAdmin:  2,
+	MembershipMember: 1,
+}
+
+// Check if the given membership role has at least the privileges of the
+// required one
+func MembershipRoleAtLeast(role string, required string) bool {
+	return membershipRoleRanks[role] >= membershipRoleRanks[required] && membershipRoleRanks[role] > 0
+}
+
+// An organization groups users that manage apps together, so apps
+// do not depend on the account of a single user
+type Organization struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID uuid.UUID `gorm:"index:org_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Name string    `gorm:"not null"`
+
+	// Relation fields
+	Memberships []Membership `gorm:"foreignKey:OrganizationID"`
+	Apps        []App        `gorm:"foreignKey:OrganizationID"`
+}
+
+// A membership relates an user with an organization with the given role
+type Membership struct {
+	gorm.Model
+
+	// Mandatory fields
+	Role string `gorm:"not null"`
+
+	// Organization
+	Organization   Organization `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	OrganizationID uint         `gorm:"index:membership_org_user,unique"`
+
+	// User
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID uint `gorm:"index:membership_org_user,unique"`
+}
+
+// An invitation is mailed to an email address in order to join an
+// organization. Only the digest of the secret is stored in the database,
+// and an invitation can only be accepted once.
+type Invitation struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID      uuid.UUID `gorm:"index:invitation_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Email     string    `gorm:"not null"`
+	Role      string    `gorm:"not null"`
+	Digest    string    `gorm:"not null;index:invitation_digest;unique"`
+	ExpiresAt time.Time `gorm:"not null"`
+
+	// Optional fields
+	AcceptedAt *time.Time
+
+	// Organization
+	Organization   Organization `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	OrganizationID uint
+
+	// User who sent the invitation
+	InvitedBy   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	InvitedByID uint
+
+	// Untracked fields
+	secret          string                    `gorm:"-"`
+	secretGenerator security.ISecretGenerator `gorm:"-"`
+}
+
+// Generates the invitation secret and stores its digest
+func (invitation *Invitation) generateSecret() {
+	secret, err := invitation.secretGenerator.GenerateSecret(invitationSecretLenght)
+	if err != nil {
+		panic(err)
+	}
+	invitation.secret = secret
+	invitation.Digest = security.Sha256Digest(secret)
+}
+
+// Returns the plain secret of the invitation. It is only available for
+// invitations that have just been created.
+func (invitation Invitation) Secret() string {
+	return invitation.secret
+}
+
+// Check if the invitation can still be accepted by the given user
+func (invitation Invitation) IsAcceptableBy(user User) bool {
+	return invitation.AcceptedAt == nil &&
+		time.Now().Before(invitation.ExpiresAt) &&
+		strings.EqualFold(invitation.Email, user.Email)
+}
+
+// Creates a new organization
+func NewOrganization(name string) Organization {
+	return Organization{Name: name}
+}
+
+// Creates a new membership
+func NewMembership(organization Organization, user User, role string) Membership {
+	return Membership{
+		Role:           role,
+		OrganizationID: organization.ID,
+		UserID:         user.ID,
+	}
+}
+
+// Creates a new invitation to join the given organization
+func NewInvitation(organization Organization, invitedBy User, email string, role string, ttl time.Duration) Invitation {
+	invitation := Invitation{
+		Email:           email,
+		Role:            role,
+		ExpiresAt:       time.Now().Add(ttl),
+		OrganizationID:  organization.ID,
+		InvitedByID:     invitedBy.ID,
+		secretGenerator: security.NewUniformSecret(),
+	}
+	invitation.generateSecret()
+	return invitation
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"gandalf/security"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestOrganizationModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test organization constructor", func(t *testing.T) {
+		name := faker.Company().Name()
+
+		organization := NewOrganization(name)
+
+		assert.Equal(name, organization.Name)
+	})
+
+	t.Run("Test membership constructor", func(t *testing.T) {
+		organization := Organization{}
+		organization.ID = uint(faker.Number().NumberInt(3))
+		user := User{}
+		user.ID = uint(faker.Number().NumberInt(3))
+
+		membership := NewMembership(organization, user, MembershipAdmin)
+
+		assert.Equal(MembershipAdmin, membership.Role)
+		assert.Equal(organization.ID, membership.OrganizationID)
+		assert.Equal(user.ID, membership.UserID)
+	})
+
+	t.Run("Test membership role ranks", func(t *testing.T) {
+		assert.True(MembershipRoleAtLeast(MembershipOwner, MembershipAdmin))
+		assert.True(MembershipRoleAtLeast(MembershipAdmin, MembershipAdmin))
+		assert.False(MembershipRoleAtLeast(MembershipMember, MembershipAdmin))
+		assert.False(MembershipRoleAtLeast("unknown", "unknown"))
+	})
+}
+
+func TestInvitationModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor success", func(t *testing.T) {
+		organization := Organization{}
+		organization.ID = uint(faker.Number().NumberInt(3))
+		invitedBy := User{}
+		invitedBy.ID = uint(faker.Number().NumberInt(3))
+		email := faker.Internet().Email()
+
+		invitation := NewInvitation(organization, invitedBy, email, MembershipMember, time.Hour)
+
+		assert.Equal(email, invitation.Email)
+		assert.Equal(MembershipMember, invitation.Role)
+		assert.Equal(organization.ID, invitation.OrganizationID)
+		assert.Equal(invitedBy.ID, invitation.InvitedByID)
+		assert.Equal(invitationSecretLenght, len(invitation.Secret()))
+		assert.Equal(security.Sha256Digest(invitation.Secret()), invitation.Digest)
+		assert.True(invitation.IsAcceptableBy(User{Email: strings.ToUpper(email)}))
+	})
+
+	t.Run("Test constructor fail", func(t *testing.T) {
+		expectedError := errors.New("Whoops")
+		invitation := Invitation{
+			secretGenerator: &mockedSecretGenerator{generateSecretError: expectedError},
+		}
+
+		assert.PanicsWithError(expectedError.Error(), func() { invitation.generateSecret() })
+	})
+
+	t.Run("Test invitation is not acceptable by other user", func(t *testing.T) {
+		invitation := Invitation{Email: "test@test.com", ExpiresAt: time.Now().Add(time.Minute)}
+
+		assert.False(invitation.IsAcceptableBy(User{Email: "other@test.com"}))
+	})
+
+	t.Run("Test accepted invitation is not acceptable", func(t *testing.T) {
+		acceptedAt := time.Now()
+		invitation := Invitation{Email: "test@test.com", ExpiresAt: time.Now().Add(time.Minute), AcceptedAt: &acceptedAt}
+
+		assert.False(invitation.IsAcceptableBy(User{Email: "test@test.com"}))
+	})
+
+	t.Run("Test expired invitation is not acceptable", func(t *testing.T) {
+		invitation := Invitation{Email: "test@test.com", ExpiresAt: time.Now().Add(-time.Minute)}
+
+		assert.False(invitation.IsAcceptableBy(User{Email: "test@test.com"}))
+	})
+}
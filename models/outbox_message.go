@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Outbox message kinds, one per notification sent through the notifier
+const (
+	OutboxKindUserVerifyEmail        = "user-verify-email"
+	OutboxKindUserChangePassword     = "user-change-password"
+	OutboxKindUserSignupAttempt      = "user-signup-attempt"
+	OutboxKindOrganizationInvitation = "organization-invitation"
+	OutboxKindUserMagicLink          = "user-magic-link"
+	OutboxKindUserDataExport         = "user-data-export"
+	OutboxKindGuardianConsent        = "guardian-consent"
+
+	// Security alerts, which carry a link to lock the account
+	OutboxKindAlertPasswordChanged = "alert-password-changed"
+	OutboxKindAlertAppAuthorized   = "alert-app-authorized"
+	OutboxKindAlertNewDevice       = "alert-new-device"
+	OutboxKindAlertImpersonated    = "alert-impersonated"
+	OutboxKindAlertPhoneChanged    = "alert-phone-changed"
+)
+
+// Outbox message statuses
+const (
+	OutboxMessagePending   = "pending"
+	OutboxMessageDelivered = "delivered"
+	OutboxMessageDead      = "dead"
+)
+
+// An outbox message is a notification waiting to be delivered. Messages are
+// written in the same transaction as the change they notify about, and a
+// background dispatcher delivers them with exponential backoff until they
+// succeed or run out of attempts, when they are dead-lettered. The payload
+// may carry one time secrets, so it is wiped once the message is delivered.
+type OutboxMessage struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID      uuid.UUID `gorm:"index:outbox_message_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Kind      string    `gorm:"not null"`
+	Recipient string    `gorm:"not null"`
+	Payload   string    `gorm:"not null;type:jsonb"`
+	Status    string    `gorm:"not null;index:outbox_message_status"`
+
+	// Optional fields
+	Attempts      int
+	NextAttemptAt *time.Time
+	DeliveredAt   *time.Time
+	LastError     string
+}
+
+// Creates a new pending outbox message of the given kind
+func NewOutboxMessage(kind string, recipient string, payload string) OutboxMessage {
+	now := time.Now()
+	return OutboxMessage{
+		Kind:          kind,
+		Recipient:     recipient,
+		Payload:       payload,
+		Status:        OutboxMessagePending,
+		NextAttemptAt: &now,
+	}
+}
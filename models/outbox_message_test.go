@@ -0,0 +1,25 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestOutboxMessageModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		email := faker.Internet().Email()
+
+		message := NewOutboxMessage(OutboxKindUserVerifyEmail, email, "{}")
+
+		assert.Equal(OutboxKindUserVerifyEmail, message.Kind)
+		assert.Equal(email, message.Recipient)
+		assert.Equal("{}", message.Payload)
+		assert.Equal(OutboxMessagePending, message.Status)
+		assert.Equal(0, message.Attempts)
+		assert.NotNil(message.NextAttemptAt)
+	})
+}
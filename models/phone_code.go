@@ -0,0 +1,88 @@
+package models
+
+import (
+	"crypto/subtle"
+	"gandalf/security"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+const phoneCodeLength = 6
+
+// Phone code purposes
+const (
+	PhoneCodePurposeVerify   = "verify-phone"
+	PhoneCodePurposeMFA      = "mfa"
+	PhoneCodePurposeRecovery = "recovery"
+	PhoneCodePurposeConfirm  = "confirm"
+)
+
+// A phone code is a short numeric secret sent by SMS to the phone of the
+// user. Codes are easy to guess, so each one only allows a few attempts
+// besides being short lived and single use. Only the digest of the secret
+// is stored in the database.
+type PhoneCode struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID      uuid.UUID `gorm:"index:phone_code_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Purpose   string    `gorm:"not null"`
+	Phone     string    `gorm:"not null"`
+	Digest    string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	Attempts  int       `gorm:"not null;default:0"`
+
+	// Optional fields
+	UsedAt *time.Time
+
+	// User
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID uint
+
+	// Untracked fields
+	secret          string                    `gorm:"-"`
+	secretGenerator security.ISecretGenerator `gorm:"-"`
+}
+
+// Generates the code secret and stores its digest
+func (code *PhoneCode) generateSecret() {
+	secret, err := code.secretGenerator.GenerateSecret(phoneCodeLength)
+	if err != nil {
+		panic(err)
+	}
+	code.secret = secret
+	code.Digest = security.Sha256Digest(secret)
+}
+
+// Returns the plain secret of the code. It is only available for codes
+// that have just been created.
+func (code PhoneCode) Secret() string {
+	return code.secret
+}
+
+// Check if the code can still be tried with the given attempts limit
+func (code PhoneCode) IsUsable(maxAttempts int) bool {
+	return code.UsedAt == nil && code.Attempts < maxAttempts && time.Now().Before(code.ExpiresAt)
+}
+
+// Check if the given secret is the one of the code
+func (code PhoneCode) Matches(secret string) bool {
+	digest := security.Sha256Digest(secret)
+	return subtle.ConstantTimeCompare([]byte(digest), []byte(code.Digest)) == 1
+}
+
+// Creates a new code for the given purpose, sent to the current phone of
+// the given user
+func NewPhoneCode(user User, purpose string, ttl time.Duration) PhoneCode {
+	code := PhoneCode{
+		Purpose:         purpose,
+		Phone:           user.Phone,
+		ExpiresAt:       time.Now().Add(ttl),
+		UserID:          user.ID,
+		secretGenerator: security.NewNumericSecret(),
+	}
+	code.generateSecret()
+	return code
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"gandalf/security"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhoneCodeModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor success", func(t *testing.T) {
+		user := User{Phone: "+34666123456"}
+		user.ID = 3
+
+		code := NewPhoneCode(user, PhoneCodePurposeVerify, time.Minute)
+
+		assert.Equal(PhoneCodePurposeVerify, code.Purpose)
+		assert.Equal(user.Phone, code.Phone)
+		assert.Equal(user.ID, code.UserID)
+		assert.Regexp("^[0-9]{6}$", code.Secret())
+		assert.Equal(security.Sha256Digest(code.Secret()), code.Digest)
+		assert.True(code.IsUsable(5))
+		assert.True(code.Matches(code.Secret()))
+		assert.False(code.Matches("000000a"))
+	})
+
+	t.Run("Test constructor fail", func(t *testing.T) {
+		expectedError := errors.New("Whoops")
+		code := PhoneCode{
+			secretGenerator: &mockedSecretGenerator{generateSecretError: expectedError},
+		}
+
+		assert.PanicsWithError(expectedError.Error(), func() { code.generateSecret() })
+	})
+
+	t.Run("Test code runs out of attempts", func(t *testing.T) {
+		code := PhoneCode{ExpiresAt: time.Now().Add(time.Minute), Attempts: 5}
+
+		assert.False(code.IsUsable(5))
+		assert.True(code.IsUsable(6))
+	})
+
+	t.Run("Test used and expired codes are not usable", func(t *testing.T) {
+		usedAt := time.Now()
+		used := PhoneCode{ExpiresAt: time.Now().Add(time.Minute), UsedAt: &usedAt}
+		expired := PhoneCode{ExpiresAt: time.Now().Add(-time.Minute)}
+
+		assert.False(used.IsUsable(5))
+		assert.False(expired.IsUsable(5))
+	})
+}
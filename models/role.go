@@ -0,0 +1,68 @@
+package models
+
+import (
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Default roles
+const (
+	RoleUser      = "user"
+	RoleDeveloper = "developer"
+	RoleStaff     = "staff"
+	RoleSupport   = "support"
+)
+
+// A permission grants the scope with the same name to the users
+// who have a role that contains it
+type Permission struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID  uuid.UUID `gorm:"index:permission_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Scope string    `gorm:"not null;index:permission_scope;unique"`
+
+	// Optional fields
+	Description string
+}
+
+// A role groups a set of permissions that can be assigned to users
+type Role struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID uuid.UUID `gorm:"index:role_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Name string    `gorm:"not null;index:role_name;unique"`
+
+	// Optional fields
+	Description string
+
+	// Relation fields
+	Permissions []Permission `gorm:"many2many:role_has_permission;"`
+}
+
+// Returns the scopes granted by the role
+func (r Role) Scopes() []string {
+	scopes := []string{}
+	for _, permission := range r.Permissions {
+		scopes = append(scopes, permission.Scope)
+	}
+	return scopes
+}
+
+// Creates a new permission
+func NewPermission(scope string, description string) Permission {
+	return Permission{
+		Scope:       scope,
+		Description: description,
+	}
+}
+
+// Creates a new role
+func NewRole(name string, description string, permissions []Permission) Role {
+	return Role{
+		Name:        name,
+		Description: description,
+		Permissions: permissions,
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestRoleModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test permission constructor", func(t *testing.T) {
+		scope := faker.Lorem().Word()
+		description := faker.Lorem().Sentence(3)
+
+		permission := NewPermission(scope, description)
+
+		assert.Equal(scope, permission.Scope)
+		assert.Equal(description, permission.Description)
+	})
+
+	t.Run("Test role constructor", func(t *testing.T) {
+		name := faker.Lorem().Word()
+		permissions := []Permission{NewPermission("user:me:read", "")}
+
+		role := NewRole(name, "", permissions)
+
+		assert.Equal(name, role.Name)
+		assert.Equal(permissions, role.Permissions)
+	})
+
+	t.Run("Test role scopes", func(t *testing.T) {
+		role := NewRole(RoleUser, "", []Permission{
+			NewPermission("user:me:read", ""),
+			NewPermission("user:me:write", ""),
+		})
+
+		assert.Equal([]string{"user:me:read", "user:me:write"}, role.Scopes())
+	})
+
+	t.Run("Test user scopes are merged from his roles", func(t *testing.T) {
+		user := User{Roles: []Role{
+			NewRole(RoleUser, "", []Permission{
+				NewPermission("user:me:read", ""),
+				NewPermission("app:me:read", ""),
+			}),
+			NewRole(RoleStaff, "", []Permission{
+				NewPermission("user:all:read", ""),
+				NewPermission("app:me:read", ""),
+			}),
+		}}
+
+		assert.Equal([]string{"user:me:read", "app:me:read", "user:all:read"}, user.Scopes())
+	})
+}
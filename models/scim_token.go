@@ -0,0 +1,57 @@
+package models
+
+import (
+	"gandalf/security"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+const scimTokenLength = 48
+
+// A SCIM token is the bearer credential the directory of a customer, like
+// his HR system, uses to provision users through the SCIM API. Only the
+// digest of the secret is stored in the database, so the secret is only
+// shown when the token is created.
+type SCIMToken struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID   uuid.UUID `gorm:"index:scim_token_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Name   string    `gorm:"not null"`
+	Digest string    `gorm:"not null;index:scim_token_digest;unique"`
+
+	// Optional fields
+	LastUsedAt *time.Time
+
+	// Untracked fields
+	secret          string                    `gorm:"-"`
+	secretGenerator security.ISecretGenerator `gorm:"-"`
+}
+
+// Generates the token secret and stores its digest
+func (token *SCIMToken) generateSecret() {
+	secret, err := token.secretGenerator.GenerateSecret(scimTokenLength)
+	if err != nil {
+		panic(err)
+	}
+	token.secret = secret
+	token.Digest = security.Sha256Digest(secret)
+}
+
+// Returns the plain secret of the token. It is only available for
+// tokens that have just been created.
+func (token SCIMToken) Secret() string {
+	return token.secret
+}
+
+// Creates a new SCIM token with the given name
+func NewSCIMToken(name string) SCIMToken {
+	token := SCIMToken{
+		Name:            name,
+		secretGenerator: security.NewUniformSecret(),
+	}
+	token.generateSecret()
+	return token
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"errors"
+	"gandalf/security"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSCIMTokenModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor success", func(t *testing.T) {
+		token := NewSCIMToken("Workday")
+
+		assert.Equal("Workday", token.Name)
+		assert.Equal(scimTokenLength, len(token.Secret()))
+		assert.Equal(security.Sha256Digest(token.Secret()), token.Digest)
+		assert.Nil(token.LastUsedAt)
+	})
+
+	t.Run("Test constructor fail", func(t *testing.T) {
+		expectedError := errors.New("Whoops")
+		token := SCIMToken{
+			secretGenerator: &mockedSecretGenerator{generateSecretError: expectedError},
+		}
+
+		assert.PanicsWithError(expectedError.Error(), func() { token.generateSecret() })
+	})
+
+	t.Run("Test stored tokens have no secret", func(t *testing.T) {
+		token := SCIMToken{Digest: security.Sha256Digest("secret")}
+
+		assert.Empty(token.Secret())
+	})
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// A session is created every time an user logs in, and it identifies the
+// device he has logged in with. Tokens are bound to the session they were
+// issued for, so they stop working once the session is revoked.
+type Session struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID       uuid.UUID `gorm:"index:session_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	LastSeenAt time.Time `gorm:"not null"`
+
+	// Optional fields
+	UserAgent string
+	IP        string
+	RevokedAt *time.Time
+
+	// User
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID uint `gorm:"index:session_user"`
+
+	// App the user has logged in through, if any
+	App   *App `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	AppID *uint
+
+	// Session the user has authorized the app from, if any. Ending it
+	// ends the sessions of the apps authorized from it too.
+	Parent   *Session `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	ParentID *uint
+}
+
+// Check if the session has not been revoked
+func (session Session) IsActive() bool {
+	return session.RevokedAt == nil
+}
+
+// Creates a new session for the given user. The app is optional.
+func NewSession(user User, app *App, userAgent string, ip string) Session {
+	session := Session{
+		LastSeenAt: time.Now(),
+		UserAgent:  userAgent,
+		IP:         ip,
+		UserID:     user.ID,
+	}
+	if app != nil {
+		session.AppID = &app.ID
+	}
+	return session
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor without app", func(t *testing.T) {
+		user := User{}
+		user.ID = 3
+
+		session := NewSession(user, nil, "agent", "127.0.0.1")
+		assert.Equal(user.ID, session.UserID)
+		assert.Equal("agent", session.UserAgent)
+		assert.Equal("127.0.0.1", session.IP)
+		assert.Nil(session.AppID)
+		assert.True(session.IsActive())
+	})
+
+	t.Run("Test constructor with app", func(t *testing.T) {
+		app := App{}
+		app.ID = 5
+
+		session := NewSession(User{}, &app, "", "")
+		assert.Equal(app.ID, *session.AppID)
+	})
+
+	t.Run("Test revoked session is not active", func(t *testing.T) {
+		now := time.Now()
+		session := Session{RevokedAt: &now}
+		assert.False(session.IsActive())
+	})
+}
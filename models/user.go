@@ -1,6 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"gandalf/bindings"
@@ -10,6 +13,36 @@ import (
 	"gorm.io/gorm"
 )
 
+// Custom attributes of an user by name, stored as a JSON object. Their
+// values are checked against the attribute definitions.
+type UserAttributes map[string]interface{}
+
+// Implement driver Valuer interface
+func (attributes UserAttributes) Value() (driver.Value, error) {
+	if attributes == nil {
+		return "{}", nil
+	}
+	value, err := json.Marshal(attributes)
+	return string(value), err
+}
+
+// Implement sql Scanner interface
+func (attributes *UserAttributes) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*attributes = UserAttributes{}
+		return nil
+	default:
+		return errors.New("user attributes must be a JSON object")
+	}
+	return json.Unmarshal(data, attributes)
+}
+
 // Represents the basic unit of information for the users that have signed
 // into an application by using gandalf
 type User struct {
@@ -18,23 +51,52 @@ type User struct {
 
 	// Mandatory fields
 	UUID     uuid.UUID          `gorm:"index:usr_uuid;unique;type:uuid;default:uuid_generate_v4()"`
-	Email    string             `gorm:"not null;index:usr_email;unique"`
+	Email    string             `gorm:"not null;index:usr_email;uniqueIndex:users_email_key,where:purged_at IS NULL"`
 	Password string             `gorm:"not null"`
 	Name     string             `gorm:"not null"`
 	Surname  string             `gorm:"not null"`
 	Birthday bindings.BirthDate `gorm:"not null"`
 	Verified bool               `gorm:"default:false"`
 	Staff    bool               `gorm:"default:false"`
+	Disabled bool               `gorm:"default:false"`
+	Locale   string             `gorm:"not null;default:'en'"`
 
 	// Optional fields
 	Phone string
 
+	// Custom attributes, as described by the attribute definitions
+	Attributes UserAttributes `gorm:"type:jsonb"`
+
+	// Id of the user in the directory of the customer which provisions
+	// him through SCIM
+	ExternalID string `gorm:"index:usr_external_id"`
+
+	// A verified phone can be used as second factor and to recover the
+	// account. Both flags are reset when the phone changes.
+	PhoneVerified bool `gorm:"not null;default:false"`
+	PhoneMFA      bool `gorm:"not null;default:false"`
+
+	// Security alerts the user wants to receive. Critical alerts, like the
+	// password change one, cannot be turned off.
+	AppAuthorizedAlerts bool `gorm:"not null;default:true"`
+	NewDeviceAlerts     bool `gorm:"not null;default:true"`
+
+	// Deleted users can restore their account by logging in until they
+	// are purged, when their personal data is wiped
+	PurgedAt *time.Time
+
+	// Minors sign up with the email of a guardian, and their sign-up is
+	// held until the guardian consents to it
+	GuardianEmail     string
+	GuardianConsentAt *time.Time
+
 	// Untracked fields
 	hasher security.Hasher `gorm:"-"`
 
 	// Relation fields
-	Apps          []App `gorm:"foreignKey:UserID"`
-	ConnectedApps []App `gorm:"many2many:user_has_signin_on_app;"`
+	Apps          []App  `gorm:"foreignKey:UserID"`
+	ConnectedApps []App  `gorm:"many2many:user_has_signin_on_app;"`
+	Roles         []Role `gorm:"many2many:user_has_role;"`
 }
 
 // Set user password by hashing the given one
@@ -55,6 +117,89 @@ func (u User) VerifyPassword(password string) bool {
 	return true
 }
 
+// Returns the scopes granted by the user roles
+func (u User) Scopes() []string {
+	scopes := []string{}
+	granted := map[string]bool{}
+	for _, role := range u.Roles {
+		for _, scope := range role.Scopes() {
+			if !granted[scope] {
+				granted[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// Check if the user wants to receive the security alert of the given kind
+func (u User) WantsAlert(kind string) bool {
+	switch kind {
+	case OutboxKindAlertAppAuthorized:
+		return u.AppAuthorizedAlerts
+	case OutboxKindAlertNewDevice:
+		return u.NewDeviceAlerts
+	}
+	return true
+}
+
+// Changes the phone of the user. A new phone must be verified again, so
+// it stops being used as second factor until then.
+func (u *User) SetPhone(phone string) {
+	if phone == u.Phone {
+		return
+	}
+	u.Phone = phone
+	u.PhoneVerified = false
+	u.PhoneMFA = false
+}
+
+// Check if the user is at least of the given age. The age of the users
+// without birthday, like the provisioned ones, is unknown, so they only
+// pass when no age is required.
+func (u User) IsOfAge(age int) bool {
+	if age <= 0 {
+		return true
+	}
+	if u.Birthday.IsZero() {
+		return false
+	}
+	return u.Birthday.Age(time.Now()) >= age
+}
+
+// Check if the sign-up of the user is held until his guardian consents
+// to it
+func (u User) AwaitsGuardianConsent() bool {
+	return u.GuardianEmail != "" && u.GuardianConsentAt == nil
+}
+
+// Check if the deleted user can still restore his account, that is, he
+// was deleted less than the given grace period ago and has not been purged
+func (u User) IsRestorable(grace time.Duration) bool {
+	return u.DeletedAt.Valid && u.PurgedAt == nil && time.Since(u.DeletedAt.Time) < grace
+}
+
+// Wipes the personal data of the user. The row is kept, so the audit
+// events and the apps which point to him stay consistent, but nobody can
+// log in with it and its email can be registered again.
+func (u *User) Anonymize() {
+	now := time.Now()
+	u.Email = ""
+	u.Password = ""
+	u.Name = ""
+	u.Surname = ""
+	u.Birthday = bindings.BirthDate{}
+	u.Phone = ""
+	u.ExternalID = ""
+	u.GuardianEmail = ""
+	u.Attributes = UserAttributes{}
+	u.PhoneVerified = false
+	u.PhoneMFA = false
+	u.Verified = false
+	u.Disabled = true
+	u.PurgedAt = &now
+}
+
 // Gorm hook after find it in the database
 func (u *User) AfterFind(tx *gorm.DB) (err error) {
 	u.hasher = security.NewBcryptHasher()
@@ -69,7 +214,11 @@ func NewUser(email string, password string, name string, surname string, birthda
 		Surname:  surname,
 		Birthday: birthday,
 		Phone:    phone,
+		Locale:   DefaultLocale,
 		hasher:   security.NewBcryptHasher(),
+
+		AppAuthorizedAlerts: true,
+		NewDeviceAlerts:     true,
 	}
 	user.SetPassword(password)
 	return user
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"gandalf/bindings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 	"syreclabs.com/go/faker"
 )
 
@@ -61,6 +63,17 @@ func TestUserModel(t *testing.T) {
 		assert.Equal(user.Surname, surname)
 		assert.Equal(user.Birthday, birthday)
 		assert.Equal(user.Phone, phone)
+		assert.Equal(DefaultLocale, user.Locale)
+		assert.True(user.AppAuthorizedAlerts)
+		assert.True(user.NewDeviceAlerts)
+	})
+
+	t.Run("Test wants alert", func(t *testing.T) {
+		user := User{AppAuthorizedAlerts: false, NewDeviceAlerts: true}
+
+		assert.True(user.WantsAlert(OutboxKindAlertPasswordChanged))
+		assert.True(user.WantsAlert(OutboxKindAlertNewDevice))
+		assert.False(user.WantsAlert(OutboxKindAlertAppAuthorized))
 	})
 
 	t.Run("Test AfterFind gorm hook", func(t *testing.T) {
@@ -113,3 +126,87 @@ func TestUserModel(t *testing.T) {
 		assert.False(match)
 	})
 }
+
+func TestUserSetPhone(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test a new phone must be verified again", func(t *testing.T) {
+		user := User{Phone: "+34666123456", PhoneVerified: true, PhoneMFA: true}
+
+		user.SetPhone("+34666123456")
+		assert.True(user.PhoneVerified)
+		assert.True(user.PhoneMFA)
+
+		user.SetPhone("+34666654321")
+		assert.Equal("+34666654321", user.Phone)
+		assert.False(user.PhoneVerified)
+		assert.False(user.PhoneMFA)
+	})
+}
+
+func TestUserDeletion(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test deleted users are restorable during the grace period", func(t *testing.T) {
+		user := User{}
+		assert.False(user.IsRestorable(time.Hour))
+
+		user.DeletedAt = gorm.DeletedAt{Time: time.Now().Add(-time.Minute), Valid: true}
+		assert.True(user.IsRestorable(time.Hour))
+		assert.False(user.IsRestorable(time.Second))
+	})
+
+	t.Run("Test anonymized users lose their personal data", func(t *testing.T) {
+		user := NewUser(faker.Internet().Email(), "password", "John", "Doe", bindings.BirthDate(time.Now()), "+34666123456")
+		user.ExternalID = "00u1"
+		user.Attributes = UserAttributes{"employee_id": "E-1"}
+		user.Verified = true
+		user.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+
+		user.Anonymize()
+
+		assert.Empty(user.Email)
+		assert.Empty(user.Password)
+		assert.Empty(user.Name)
+		assert.Empty(user.Surname)
+		assert.Empty(user.Phone)
+		assert.Empty(user.ExternalID)
+		assert.Empty(user.Attributes)
+		assert.True(time.Time(user.Birthday).IsZero())
+		assert.False(user.Verified)
+		assert.True(user.Disabled)
+		assert.NotNil(user.PurgedAt)
+		assert.False(user.IsRestorable(time.Hour))
+	})
+}
+
+func TestUserAge(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test users are of age from their birthday", func(t *testing.T) {
+		user := User{Birthday: bindings.BirthDate(time.Now().AddDate(-16, 0, -1))}
+
+		assert.True(user.IsOfAge(0))
+		assert.True(user.IsOfAge(16))
+		assert.False(user.IsOfAge(18))
+	})
+
+	t.Run("Test users without birthday only pass when no age is required", func(t *testing.T) {
+		user := User{}
+
+		assert.True(user.IsOfAge(0))
+		assert.False(user.IsOfAge(13))
+	})
+
+	t.Run("Test sign-up is held until the guardian consents", func(t *testing.T) {
+		user := User{}
+		assert.False(user.AwaitsGuardianConsent())
+
+		user.GuardianEmail = "guardian@example.com"
+		assert.True(user.AwaitsGuardianConsent())
+
+		now := time.Now()
+		user.GuardianConsentAt = &now
+		assert.False(user.AwaitsGuardianConsent())
+	})
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"gandalf/security"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+const webhookSecretLenght = 32
+
+// Webhook event types. User events are only sent to the apps the user is
+// connected to, and app events only to the app concerned. Deleted users can be restored until they are purged, and
+// their personal data is wiped then.
+const (
+	WebhookEventUserCreated     = "user.created"
+	WebhookEventUserVerified    = "user.verified"
+	WebhookEventUserDeleted     = "user.deleted"
+	WebhookEventUserPurged      = "user.purged"
+	WebhookEventAppConnected    = "app.connected"
+	WebhookEventAppDisconnected = "app.disconnected"
+)
+
+// All the webhook event types an app can subscribe to
+var WebhookEvents = []string{
+	WebhookEventUserCreated,
+	WebhookEventUserVerified,
+	WebhookEventUserDeleted,
+	WebhookEventUserPurged,
+	WebhookEventAppConnected,
+	WebhookEventAppDisconnected,
+}
+
+// A webhook is an endpoint registered by an app in order to be notified
+// about the events it has subscribed to. Payloads are signed with the
+// webhook secret.
+type Webhook struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID   uuid.UUID      `gorm:"index:webhook_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Url    string         `gorm:"not null"`
+	Secret string         `gorm:"not null"`
+	Events pq.StringArray `gorm:"type:text[]"`
+	Active bool           `gorm:"default:true"`
+
+	// App
+	App   App  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	AppID uint `gorm:"index:webhook_app"`
+
+	// Untracked fields
+	secretGenerator security.ISecretGenerator `gorm:"-"`
+}
+
+// Generates the secret the payloads are signed with
+func (webhook *Webhook) generateSecret() {
+	secret, err := webhook.secretGenerator.GenerateSecret(webhookSecretLenght)
+	if err != nil {
+		panic(err)
+	}
+	webhook.Secret = secret
+}
+
+// Check if the webhook is subscribed to the given event
+func (webhook Webhook) Subscribed(event string) bool {
+	for _, subscribed := range webhook.Events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Creates a new active webhook for the given app
+func NewWebhook(app App, url string, events []string) Webhook {
+	webhook := Webhook{
+		Url:             url,
+		Events:          events,
+		Active:          true,
+		AppID:           app.ID,
+		secretGenerator: security.NewUniformSecret(),
+	}
+	webhook.generateSecret()
+	return webhook
+}
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// A webhook delivery is an event sent to a webhook. Failed attempts are
+// retried with exponential backoff until the delivery succeeds or it runs
+// out of attempts.
+type WebhookDelivery struct {
+	gorm.Model
+
+	// Mandatory fields
+	UUID    uuid.UUID `gorm:"index:webhook_delivery_uuid;unique;type:uuid;default:uuid_generate_v4()"`
+	Event   string    `gorm:"not null"`
+	Payload string    `gorm:"not null;type:jsonb"`
+	Status  string    `gorm:"not null;index:webhook_delivery_status"`
+
+	// Optional fields
+	Attempts      int
+	NextAttemptAt *time.Time
+	DeliveredAt   *time.Time
+	LastStatus    int
+	LastError     string
+
+	// Webhook
+	Webhook   Webhook `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	WebhookID uint    `gorm:"index:webhook_delivery_webhook"`
+}
+
+// Creates a new pending delivery of the given event to the given webhook
+func NewWebhookDelivery(webhook Webhook, event string, payload string) WebhookDelivery {
+	now := time.Now()
+	return WebhookDelivery{
+		Event:         event,
+		Payload:       payload,
+		Status:        WebhookDeliveryPending,
+		NextAttemptAt: &now,
+		WebhookID:     webhook.ID,
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestWebhookModel(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor success", func(t *testing.T) {
+		app := App{}
+		app.ID = uint(faker.Number().NumberInt(3))
+		url := faker.Internet().Url()
+		events := []string{WebhookEventUserDeleted}
+
+		webhook := NewWebhook(app, url, events)
+
+		assert.Equal(url, webhook.Url)
+		assert.Equal(pq.StringArray(events), webhook.Events)
+		assert.Equal(app.ID, webhook.AppID)
+		assert.True(webhook.Active)
+		assert.Equal(webhookSecretLenght, len(webhook.Secret))
+	})
+
+	t.Run("Test constructor fail", func(t *testing.T) {
+		expectedError := errors.New("Whoops")
+		webhook := Webhook{secretGenerator: &mockedSecretGenerator{expectedError}}
+
+		assert.PanicsWithError(expectedError.Error(), func() { webhook.generateSecret() })
+	})
+
+	t.Run("Test subscribed", func(t *testing.T) {
+		webhook := NewWebhook(App{}, faker.Internet().Url(), []string{WebhookEventUserDeleted})
+
+		assert.True(webhook.Subscribed(WebhookEventUserDeleted))
+		assert.False(webhook.Subscribed(WebhookEventUserCreated))
+	})
+
+	t.Run("Test delivery constructor", func(t *testing.T) {
+		webhook := Webhook{}
+		webhook.ID = uint(faker.Number().NumberInt(3))
+
+		delivery := NewWebhookDelivery(webhook, WebhookEventUserDeleted, "{}")
+
+		assert.Equal(webhook.ID, delivery.WebhookID)
+		assert.Equal(WebhookEventUserDeleted, delivery.Event)
+		assert.Equal(WebhookDeliveryPending, delivery.Status)
+		assert.NotNil(delivery.NextAttemptAt)
+	})
+}
@@ -3,8 +3,11 @@ package routes
 import (
 	"gandalf/connections"
 	"gandalf/controllers"
+	"gandalf/helpers"
 	"gandalf/middlewares"
+	"gandalf/security"
 	"gandalf/services"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,34 +21,161 @@ func Routes(router *gin.Engine) {
 	authService := services.NewAuthService(db)
 	userService := services.NewUserService(db)
 	appService := services.NewAppService(db)
-	pelipperService := services.NewPelipperService()
+	tokenService := services.NewOneTimeTokenService(db)
+	notifier := services.NewNotifier()
+	adminActionService := services.NewAdminActionService(db)
+	roleService := services.NewRoleService(db)
+	organizationService := services.NewOrganizationService(db)
+	sessionService := services.NewSessionService(db)
+	logoutService := services.NewLogoutService(db)
+	auditService := services.NewAuditService(db)
+	webhookService := services.NewWebhookService(db)
+	outboxService := services.NewOutboxService(db, notifier)
+	templateService := services.NewNotificationTemplateService(db)
+	magicLinkService := services.NewMagicLinkService(db)
+	phoneService := services.NewPhoneService(db, services.NewSMSSender())
+	identityProviderService := services.NewIdentityProviderService(db)
+	federatedLoginService := services.NewFederatedLoginService(db)
+	samlService := services.NewSAMLService(db)
+	scimService := services.NewSCIMService(db, userService)
+	dataExportService := services.NewDataExportService(db)
+	attributeService := services.NewAttributeService(db)
+	legalService := services.NewLegalService(db)
+
+	// Throttlers
+	emailThrottler := security.NewMemoryThrottler(
+		helpers.GetEnvInt("NOTIFICATION_EMAIL_LIMIT", 5), time.Hour,
+		time.Duration(helpers.GetEnvInt("NOTIFICATION_EMAIL_COOLDOWN", 60))*time.Second,
+	)
+	ipThrottler := security.NewMemoryThrottler(
+		helpers.GetEnvInt("NOTIFICATION_IP_LIMIT", 20), time.Hour, 0,
+	)
+	phoneThrottler := security.NewMemoryThrottler(
+		helpers.GetEnvInt("PHONE_CODE_LIMIT", 5), time.Hour,
+		time.Duration(helpers.GetEnvInt("PHONE_CODE_COOLDOWN", 60))*time.Second,
+	)
+
+	// Background jobs
+	go func() {
+		interval := time.Duration(helpers.GetEnvInt("OUTBOX_DISPATCH_INTERVAL", 5)) * time.Second
+		for range time.Tick(interval) {
+			outboxService.DeliverPending()
+		}
+	}()
+	go func() {
+		interval := time.Duration(helpers.GetEnvInt("WEBHOOK_RETRY_INTERVAL", 15)) * time.Second
+		for range time.Tick(interval) {
+			webhookService.DeliverPending()
+		}
+	}()
+	go func() {
+		interval := time.Duration(helpers.GetEnvInt("USER_PURGE_INTERVAL", 3600)) * time.Second
+		for range time.Tick(interval) {
+			userService.PurgeDeleted()
+		}
+	}()
+	go func() {
+		interval := time.Duration(helpers.GetEnvInt("DATA_EXPORT_INTERVAL", 30)) * time.Second
+		for range time.Tick(interval) {
+			dataExportService.BuildPending()
+		}
+	}()
 
 	// Middlewares
 	authBearerMiddleware := middlewares.NewAuthBearerMiddleware(authService)
 
 	// Routes
-	controllers.RegisterAuthRoutes(router, authService)
+	controllers.RegisterAuthRoutes(
+		router, authService, roleService,
+		phoneService, phoneThrottler,
+	)
 	controllers.RegisterNotificationRoutes(
 		router, authService,
-		userService, pelipperService,
+		userService, outboxService,
+		emailThrottler, ipThrottler,
+	)
+	controllers.RegisterMagicLinkRoutes(
+		router, authService,
+		userService, appService,
+		roleService, magicLinkService,
+		webhookService,
+		emailThrottler, ipThrottler,
+		phoneService, phoneThrottler,
+	)
+	controllers.RegisterFederatedRoutes(
+		router, authService, roleService,
+		identityProviderService, federatedLoginService,
+		phoneService, phoneThrottler,
 	)
 	controllers.RegisterPingRoutes(router)
 	controllers.RegisterUserRoutes(
 		router, authBearerMiddleware,
-		authService, userService, pelipperService,
+		authService, userService,
+		outboxService,
+		emailThrottler, ipThrottler,
 	)
 	controllers.RegisterMeRoutes(
 		router, authBearerMiddleware,
 		authService, userService,
-		appService, pelipperService,
+		appService, tokenService,
+		sessionService, auditService,
+		webhookService,
+	)
+	controllers.RegisterDataExportRoutes(
+		router, authBearerMiddleware,
+		dataExportService,
+	)
+	controllers.RegisterAttributeRoutes(
+		router, authBearerMiddleware,
+		attributeService,
+	)
+	controllers.RegisterLegalRoutes(
+		router, authBearerMiddleware,
+		appService, legalService,
 	)
 	controllers.RegisterOauth2Routes(
 		router, authBearerMiddleware,
 		authService, userService, appService,
+		roleService, logoutService,
+		webhookService,
+		phoneService, phoneThrottler,
+	)
+	controllers.RegisterSAMLRoutes(
+		router, authBearerMiddleware,
+		samlService, webhookService,
 	)
+	controllers.RegisterSCIMRoutes(router, scimService)
 	controllers.RegisterAppRoutes(
 		router,
 		authBearerMiddleware,
-		appService,
+		appService, organizationService,
+	)
+	controllers.RegisterOrganizationRoutes(
+		router, authBearerMiddleware,
+		organizationService, userService,
+	)
+	controllers.RegisterAdminRoutes(
+		router, authBearerMiddleware,
+		authService, userService,
+		outboxService,
+		adminActionService, roleService,
+		sessionService, auditService,
+		templateService,
+		identityProviderService, scimService,
+		attributeService, legalService,
+		phoneService, phoneThrottler,
+	)
+	controllers.RegisterPhoneRoutes(
+		router, authBearerMiddleware,
+		userService, phoneService,
+		phoneThrottler,
+	)
+	controllers.RegisterWebhookRoutes(
+		router, authBearerMiddleware,
+		appService, webhookService,
+	)
+	controllers.RegisterNotificationTemplateRoutes(
+		router, authBearerMiddleware,
+		appService, templateService,
 	)
 }
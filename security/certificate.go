@@ -0,0 +1,55 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// Size of the keys of the self-signed certificates
+const certificateKeyBits = 2048
+
+// Loads the RSA certificate and private key from the given PEM files
+func LoadRSACertificate(certFile string, keyFile string) (tls.Certificate, error) {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return certificate, err
+	}
+	if _, ok := certificate.PrivateKey.(*rsa.PrivateKey); !ok {
+		return certificate, errors.New("private key is not an RSA key")
+	}
+	return certificate, nil
+}
+
+// Creates a self-signed RSA certificate for the given common name, which
+// is valid from now on for the given duration
+func NewSelfSignedCertificate(commonName string, ttl time.Duration) (tls.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, certificateKeyBits)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(ttl),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,50 @@
+package security
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificates(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test self-signed certificate is issued for the common name", func(t *testing.T) {
+		certificate, err := NewSelfSignedCertificate("gandalf", time.Hour)
+
+		assert.NoError(err)
+		parsed, err := x509.ParseCertificate(certificate.Certificate[0])
+		assert.NoError(err)
+		assert.Equal("gandalf", parsed.Subject.CommonName)
+		assert.True(parsed.NotAfter.After(time.Now()))
+		assert.NoError(parsed.CheckSignature(parsed.SignatureAlgorithm, parsed.RawTBSCertificate, parsed.Signature))
+	})
+
+	t.Run("Test certificate is loaded from PEM files", func(t *testing.T) {
+		certificate, _ := NewSelfSignedCertificate("gandalf", time.Hour)
+		key, _ := x509.MarshalPKCS8PrivateKey(certificate.PrivateKey)
+		dir, _ := ioutil.TempDir("", "certificate")
+		defer os.RemoveAll(dir)
+		certFile := filepath.Join(dir, "cert.pem")
+		keyFile := filepath.Join(dir, "key.pem")
+		ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]}), 0600)
+		ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600)
+
+		loaded, err := LoadRSACertificate(certFile, keyFile)
+
+		assert.NoError(err)
+		assert.Equal(certificate.Certificate[0], loaded.Certificate[0])
+	})
+
+	t.Run("Test missing files are rejected", func(t *testing.T) {
+		_, err := LoadRSACertificate("missing-cert.pem", "missing-key.pem")
+
+		assert.Error(err)
+	})
+}
@@ -0,0 +1,13 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Returns the hex encoded sha256 digest of the given value. It must be used
+// for secrets that have to be looked up but never read back
+func Sha256Digest(value string) string {
+	digest := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,20 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSha256Digest(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test digest is deterministic", func(t *testing.T) {
+		assert.Equal(Sha256Digest("test"), Sha256Digest("test"))
+		assert.Equal(64, len(Sha256Digest("test")))
+	})
+
+	t.Run("Test digest differs between values", func(t *testing.T) {
+		assert.NotEqual(Sha256Digest("test"), Sha256Digest("other"))
+	})
+}
@@ -0,0 +1,77 @@
+package security
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+)
+
+// A JSON web key, as published by the OIDC providers to verify the
+// signature of their tokens. Only RSA keys are supported.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// A JSON web key set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Returns the RSA public key with the given id. When the id is empty the
+// set must hold a single signing key.
+func (set JWKS) RSAKey(kid string) (*rsa.PublicKey, error) {
+	var found *JWK
+	for i, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		if key.Kid == kid || kid == "" {
+			if found != nil {
+				return nil, errors.New("ambiguous key")
+			}
+			found = &set.Keys[i]
+		}
+	}
+	if found == nil {
+		return nil, errors.New("key not found")
+	}
+	return found.RSAPublicKey()
+}
+
+// Returns the RSA public key the JWK holds
+func (key JWK) RSAPublicKey() (*rsa.PublicKey, error) {
+	modulus, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, err
+	}
+	exponent, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil {
+		return nil, err
+	}
+	if len(modulus) == 0 || len(exponent) == 0 || len(exponent) > 4 {
+		return nil, errors.New("malformed key")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(modulus),
+		E: int(new(big.Int).SetBytes(exponent).Int64()),
+	}, nil
+}
+
+// Creates the JWK which publishes the given RSA public key
+func NewRSAJWK(kid string, key *rsa.PublicKey) JWK {
+	return JWK{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
@@ -0,0 +1,53 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWKS(t *testing.T) {
+	assert := require.New(t)
+	first, _ := rsa.GenerateKey(rand.Reader, 2048)
+	second, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	t.Run("Test keys are found by id", func(t *testing.T) {
+		set := JWKS{Keys: []JWK{NewRSAJWK("first", &first.PublicKey), NewRSAJWK("second", &second.PublicKey)}}
+
+		key, err := set.RSAKey("second")
+
+		assert.NoError(err)
+		assert.Equal(second.PublicKey.N, key.N)
+		assert.Equal(second.PublicKey.E, key.E)
+	})
+
+	t.Run("Test unknown key ids are rejected", func(t *testing.T) {
+		set := JWKS{Keys: []JWK{NewRSAJWK("first", &first.PublicKey)}}
+
+		_, err := set.RSAKey("other")
+
+		assert.Error(err)
+	})
+
+	t.Run("Test missing key id needs a single key", func(t *testing.T) {
+		single := JWKS{Keys: []JWK{NewRSAJWK("first", &first.PublicKey)}}
+		many := JWKS{Keys: []JWK{NewRSAJWK("first", &first.PublicKey), NewRSAJWK("second", &second.PublicKey)}}
+
+		_, singleErr := single.RSAKey("")
+		_, manyErr := many.RSAKey("")
+
+		assert.NoError(singleErr)
+		assert.Error(manyErr)
+	})
+
+	t.Run("Test encryption keys are skipped", func(t *testing.T) {
+		key := NewRSAJWK("first", &first.PublicKey)
+		key.Use = "enc"
+
+		_, err := JWKS{Keys: []JWK{key}}.RSAKey("first")
+
+		assert.Error(err)
+	})
+}
@@ -0,0 +1,58 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// Networks which cannot be reached from the internet: loopback, private,
+// carrier-grade NAT, link local, multicast and reserved ranges
+var nonPublicNetworks = parseNetworks(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+	"172.16.0.0/12", "192.0.0.0/24", "192.168.0.0/16", "198.18.0.0/15",
+	"224.0.0.0/4", "240.0.0.0/4",
+	"::/128", "::1/128", "fc00::/7", "fe80::/10", "ff00::/8",
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// Check if the given IP can be reached from the internet
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip = ipv4
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Dialer control which refuses to connect to addresses that are not
+// public, so the urls given by the users cannot reach the internal
+// network. It is checked once the host has been resolved.
+func PublicAddressControl(network string, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublicIP(net.ParseIP(host)) {
+		return fmt.Errorf("address %s is not public", host)
+	}
+	return nil
+}
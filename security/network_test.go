@@ -0,0 +1,37 @@
+package security
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test public addresses", func(t *testing.T) {
+		assert.True(IsPublicIP(net.ParseIP("93.184.216.34")))
+		assert.True(IsPublicIP(net.ParseIP("2606:2800:220:1:248:1893:25c8:1946")))
+	})
+
+	t.Run("Test internal addresses", func(t *testing.T) {
+		for _, ip := range []string{
+			"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+			"100.64.0.1", "0.0.0.0", "::1", "fd00::1", "fe80::1", "::ffff:127.0.0.1",
+		} {
+			assert.False(IsPublicIP(net.ParseIP(ip)), ip)
+		}
+		assert.False(IsPublicIP(nil))
+	})
+}
+
+func TestPublicAddressControl(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test control", func(t *testing.T) {
+		assert.NoError(PublicAddressControl("tcp4", "93.184.216.34:443", nil))
+		assert.Error(PublicAddressControl("tcp4", "127.0.0.1:80", nil))
+		assert.Error(PublicAddressControl("tcp6", "[::1]:80", nil))
+	})
+}
@@ -0,0 +1,13 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Returns the S256 PKCE challenge of the given code verifier, as defined by
+// RFC 7636
+func PKCEChallenge(verifier string) string {
+	digest := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
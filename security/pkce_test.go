@@ -0,0 +1,16 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPKCEChallenge(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test challenge matches the RFC 7636 example", func(t *testing.T) {
+		verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		assert.Equal("E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", PKCEChallenge(verifier))
+	})
+}
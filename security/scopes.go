@@ -4,23 +4,89 @@ package security
 const (
 	ScopeUserAuthorizationCode = "user:me:authorization-code"
 	ScopeUserAuthorizeApp      = "user:me:authorized-app"
-	ScopeUserVerify            = "user:me:verify"
-	ScopeUserChangePassword    = "user:me:change-password"
 
 	ScopeUserRead   = "user:me:read"
 	ScopeUserWrite  = "user:me:write"
 	ScopeUserDelete = "user:me:delete"
+	ScopeUserExport = "user:me:export"
 
 	ScopeAppWrite = "app:me:write"
 	ScopeAppRead  = "app:me:read"
 
-	ScopeUserReadAll = "user:all:read"
-	ScopeAppReadAll  = "app:all:read"
+	ScopeOrganizationRead  = "org:me:read"
+	ScopeOrganizationWrite = "org:me:write"
+
+	ScopeUserReadAll   = "user:all:read"
+	ScopeUserWriteAll  = "user:all:write"
+	ScopeUserDeleteAll = "user:all:delete"
+	ScopeAppReadAll    = "app:all:read"
+	ScopeRoleReadAll   = "role:all:read"
+	ScopeRoleWriteAll  = "role:all:write"
+	ScopeAuditReadAll  = "audit:all:read"
+	ScopeOutboxReadAll = "outbox:all:read"
+
+	ScopeTemplateReadAll  = "template:all:read"
+	ScopeTemplateWriteAll = "template:all:write"
+	ScopeProviderReadAll  = "provider:all:read"
+	ScopeProviderWriteAll = "provider:all:write"
+	ScopeSCIMReadAll      = "scim:all:read"
+	ScopeSCIMWriteAll     = "scim:all:write"
+
+	ScopeAttributeReadAll  = "attribute:all:read"
+	ScopeAttributeWriteAll = "attribute:all:write"
+
+	ScopeLegalReadAll  = "legal:all:read"
+	ScopeLegalWriteAll = "legal:all:write"
+
+	ScopeUserImpersonateAll = "user:all:impersonate"
 )
 
 // Group scopes
 var (
-	GroupUserSelf          = []string{ScopeUserAuthorizeApp, ScopeUserRead, ScopeUserWrite, ScopeUserDelete}
 	GroupUserOauth2Request = []string{ScopeUserAuthorizeApp, ScopeUserRead, ScopeAppRead}
-	GroupAdmin             = []string{ScopeUserRead, ScopeUserWrite, ScopeUserDelete, ScopeAppRead}
+	GroupStaff             = []string{ScopeUserReadAll, ScopeUserWriteAll, ScopeUserDeleteAll, ScopeAppReadAll, ScopeRoleReadAll, ScopeRoleWriteAll, ScopeAuditReadAll, ScopeOutboxReadAll, ScopeTemplateReadAll, ScopeTemplateWriteAll, ScopeProviderReadAll, ScopeProviderWriteAll, ScopeSCIMReadAll, ScopeSCIMWriteAll, ScopeAttributeReadAll, ScopeAttributeWriteAll, ScopeLegalReadAll, ScopeLegalWriteAll, ScopeUserImpersonateAll}
+
+	// Scopes never issued to staff acting as another user, since they delete
+	// the user, export his data or authorize apps on his behalf
+	GroupImpersonationDenied = []string{ScopeUserDelete, ScopeUserAuthorizeApp, ScopeUserExport}
 )
+
+// Splits the given scopes into the ones that can be issued by any login and
+// the ones that can only be issued through the admin login
+func SplitStaffScopes(scopes []string) ([]string, []string) {
+	userScopes := []string{}
+	staffScopes := []string{}
+	for _, scope := range scopes {
+		if len(IntersectScopes([]string{scope}, GroupStaff)) > 0 {
+			staffScopes = append(staffScopes, scope)
+		} else {
+			userScopes = append(userScopes, scope)
+		}
+	}
+	return userScopes, staffScopes
+}
+
+// Returns the given scopes which are also present in the allowed ones
+func IntersectScopes(scopes []string, allowed []string) []string {
+	intersection := []string{}
+	for _, scope := range scopes {
+		for _, allowedScope := range allowed {
+			if scope == allowedScope {
+				intersection = append(intersection, scope)
+				break
+			}
+		}
+	}
+	return intersection
+}
+
+// Returns the given scopes which are not present in the denied ones
+func ExcludeScopes(scopes []string, denied []string) []string {
+	remaining := []string{}
+	for _, scope := range scopes {
+		if len(IntersectScopes([]string{scope}, denied)) == 0 {
+			remaining = append(remaining, scope)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,65 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStaffScopes(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test split staff scopes", func(t *testing.T) {
+		userScopes, staffScopes := SplitStaffScopes([]string{
+			ScopeUserRead, ScopeUserReadAll, ScopeAppRead, ScopeRoleWriteAll,
+		})
+
+		assert.Equal([]string{ScopeUserRead, ScopeAppRead}, userScopes)
+		assert.Equal([]string{ScopeUserReadAll, ScopeRoleWriteAll}, staffScopes)
+	})
+
+	t.Run("Test split without staff scopes", func(t *testing.T) {
+		userScopes, staffScopes := SplitStaffScopes([]string{ScopeUserRead})
+
+		assert.Equal([]string{ScopeUserRead}, userScopes)
+		assert.Equal([]string{}, staffScopes)
+	})
+}
+
+func TestIntersectScopes(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test intersect scopes", func(t *testing.T) {
+		scopes := IntersectScopes(
+			[]string{ScopeUserRead, ScopeUserWrite, ScopeAppRead},
+			GroupUserOauth2Request,
+		)
+
+		assert.Equal([]string{ScopeUserRead, ScopeAppRead}, scopes)
+	})
+
+	t.Run("Test intersect disjoint scopes", func(t *testing.T) {
+		scopes := IntersectScopes([]string{ScopeUserReadAll}, GroupUserOauth2Request)
+
+		assert.Equal([]string{}, scopes)
+	})
+}
+
+func TestExcludeScopes(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test exclude scopes", func(t *testing.T) {
+		scopes := ExcludeScopes(
+			[]string{ScopeUserRead, ScopeUserWrite, ScopeUserDelete, ScopeUserAuthorizeApp, ScopeUserExport},
+			GroupImpersonationDenied,
+		)
+
+		assert.Equal([]string{ScopeUserRead, ScopeUserWrite}, scopes)
+	})
+
+	t.Run("Test exclude every scope", func(t *testing.T) {
+		scopes := ExcludeScopes([]string{ScopeUserDelete}, GroupImpersonationDenied)
+
+		assert.Equal([]string{}, scopes)
+	})
+}
@@ -8,6 +8,8 @@ import (
 
 const characters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-"
 
+const digits = "0123456789"
+
 // Interface for secrets generator
 type ISecretGenerator interface {
 	GenerateSecret(lenght int) (string, error)
@@ -20,21 +22,43 @@ type UniformSecretGenerator struct {
 
 // Returns securely generated random string
 func (secret UniformSecretGenerator) GenerateSecret(lenght int) (string, error) {
+	return generateSecret(characters, lenght, secret.getRandomPosition)
+}
+
+// Creates a new uniform secret
+func NewUniformSecret() UniformSecretGenerator {
+	return UniformSecretGenerator{
+		getRandomPosition: rand.Int,
+	}
+}
+
+// Secret made only of digits, for the codes the users type by hand
+type NumericSecretGenerator struct {
+	getRandomPosition func(rand io.Reader, max *big.Int) (n *big.Int, err error)
+}
+
+// Returns securely generated random digits
+func (secret NumericSecretGenerator) GenerateSecret(lenght int) (string, error) {
+	return generateSecret(digits, lenght, secret.getRandomPosition)
+}
+
+// Creates a new numeric secret
+func NewNumericSecret() NumericSecretGenerator {
+	return NumericSecretGenerator{
+		getRandomPosition: rand.Int,
+	}
+}
+
+// Picks every character of the secret uniformly from the given alphabet
+func generateSecret(alphabet string, lenght int, getRandomPosition func(rand io.Reader, max *big.Int) (n *big.Int, err error)) (string, error) {
 	ret := make([]byte, lenght)
 	for i := 0; i < lenght; i++ {
-		num, err := secret.getRandomPosition(rand.Reader, big.NewInt(int64(len(characters))))
+		num, err := getRandomPosition(rand.Reader, big.NewInt(int64(len(alphabet))))
 		if err != nil {
 			return "", err
 		}
-		ret[i] = characters[num.Int64()]
+		ret[i] = alphabet[num.Int64()]
 	}
 
 	return string(ret), nil
 }
-
-// Creates a new uniform secret
-func NewUniformSecret() UniformSecretGenerator {
-	return UniformSecretGenerator{
-		getRandomPosition: rand.Int,
-	}
-}
@@ -60,4 +60,21 @@ func TestGenerateSecret(t *testing.T) {
 		assert.Equal(expectedError, err)
 	})
 
+	t.Run("Test numeric secret", func(t *testing.T) {
+		secret, err := NewNumericSecret().GenerateSecret(6)
+
+		assert.Nil(err)
+		assert.Regexp("^[0-9]{6}$", secret)
+	})
+
+	t.Run("Test numeric secret alphabet", func(t *testing.T) {
+		getRandomPosition, recorder := mockedGetRandomPosition(nil)
+		secretGenerator := NumericSecretGenerator{
+			getRandomPosition: getRandomPosition,
+		}
+		secret, _ := secretGenerator.GenerateSecret(6)
+
+		assert.Equal("000000", secret)
+		assert.Equal(recorder.max, big.NewInt(int64(len(digits))))
+	})
 }
@@ -0,0 +1,20 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHmacSha256Signature(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test signature is deterministic", func(t *testing.T) {
+		assert.Equal(HmacSha256Signature("secret", []byte("test")), HmacSha256Signature("secret", []byte("test")))
+		assert.Equal(64, len(HmacSha256Signature("secret", []byte("test"))))
+	})
+
+	t.Run("Test signature differs between secrets", func(t *testing.T) {
+		assert.NotEqual(HmacSha256Signature("secret", []byte("test")), HmacSha256Signature("other", []byte("test")))
+	})
+}
@@ -0,0 +1,81 @@
+package security
+
+import (
+	"sync"
+	"time"
+)
+
+// Interface for request throttlers
+type IThrottler interface {
+	Allow(key string) bool
+}
+
+// Throttler which keeps the hits in memory. It allows `limit` hits per
+// `window` for every key, forcing a `cooldown` between two consecutive
+// allowed hits of the same key. A zero limit or cooldown disables its check.
+type MemoryThrottler struct {
+	limit    int
+	window   time.Duration
+	cooldown time.Duration
+
+	mutex     *sync.Mutex
+	hits      map[string][]time.Time
+	lastSweep *time.Time
+	now       func() time.Time
+}
+
+// Forgets the keys without hits in the current window, so the keys, which
+// are chosen by the clients, do not pile up. It runs at most once per window.
+func (throttler MemoryThrottler) sweep(now time.Time) {
+	if now.Sub(*throttler.lastSweep) < throttler.window {
+		return
+	}
+	for key, hits := range throttler.hits {
+		if len(hits) == 0 || now.Sub(hits[len(hits)-1]) >= throttler.window {
+			delete(throttler.hits, key)
+		}
+	}
+	*throttler.lastSweep = now
+}
+
+// Check if the given key can perform a new hit, recording it if so
+func (throttler MemoryThrottler) Allow(key string) bool {
+	throttler.mutex.Lock()
+	defer throttler.mutex.Unlock()
+
+	now := throttler.now()
+	throttler.sweep(now)
+
+	var hits []time.Time
+	for _, hit := range throttler.hits[key] {
+		if now.Sub(hit) < throttler.window {
+			hits = append(hits, hit)
+		}
+	}
+
+	if len(hits) > 0 && now.Sub(hits[len(hits)-1]) < throttler.cooldown {
+		throttler.hits[key] = hits
+		return false
+	}
+
+	if throttler.limit > 0 && len(hits) >= throttler.limit {
+		throttler.hits[key] = hits
+		return false
+	}
+
+	throttler.hits[key] = append(hits, now)
+	return true
+}
+
+// Creates a new memory throttler
+func NewMemoryThrottler(limit int, window time.Duration, cooldown time.Duration) MemoryThrottler {
+	return MemoryThrottler{
+		limit:     limit,
+		window:    window,
+		cooldown:  cooldown,
+		mutex:     new(sync.Mutex),
+		hits:      make(map[string][]time.Time),
+		lastSweep: new(time.Time),
+		now:       time.Now,
+	}
+}
@@ -0,0 +1,75 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockedClock struct {
+	current time.Time
+}
+
+func (clock *mockedClock) now() time.Time {
+	return clock.current
+}
+
+func (clock *mockedClock) advance(duration time.Duration) {
+	clock.current = clock.current.Add(duration)
+}
+
+func TestMemoryThrottler(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		throttler := NewMemoryThrottler(5, time.Hour, time.Minute)
+
+		assert.Equal(5, throttler.limit)
+		assert.Equal(time.Hour, throttler.window)
+		assert.Equal(time.Minute, throttler.cooldown)
+		assert.True(throttler.Allow("key"))
+	})
+
+	t.Run("Test cooldown", func(t *testing.T) {
+		clock := &mockedClock{time.Now()}
+		throttler := NewMemoryThrottler(0, time.Hour, time.Minute)
+		throttler.now = clock.now
+
+		assert.True(throttler.Allow("key"))
+		assert.False(throttler.Allow("key"))
+		assert.True(throttler.Allow("other"))
+
+		clock.advance(time.Minute)
+		assert.True(throttler.Allow("key"))
+	})
+
+	t.Run("Test limit per window", func(t *testing.T) {
+		clock := &mockedClock{time.Now()}
+		throttler := NewMemoryThrottler(2, time.Hour, 0)
+		throttler.now = clock.now
+
+		assert.True(throttler.Allow("key"))
+		assert.True(throttler.Allow("key"))
+		assert.False(throttler.Allow("key"))
+
+		clock.advance(time.Hour)
+		assert.True(throttler.Allow("key"))
+	})
+
+	t.Run("Test expired keys are forgotten", func(t *testing.T) {
+		clock := &mockedClock{time.Now()}
+		throttler := NewMemoryThrottler(2, time.Hour, 0)
+		throttler.now = clock.now
+
+		throttler.Allow("first")
+		throttler.Allow("second")
+		clock.advance(30 * time.Minute)
+		throttler.Allow("second")
+		clock.advance(time.Hour)
+		throttler.Allow("third")
+
+		assert.Equal(1, len(throttler.hits))
+		assert.Contains(throttler.hits, "third")
+	})
+}
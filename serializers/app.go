@@ -8,12 +8,22 @@ import (
 )
 
 type appDataSerializer struct {
-	UUID         uuid.UUID `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
-	ClientID     uuid.UUID `json:"client_id" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
-	ClientSecret string    `json:"client_secret" example:"iuhgf3874tiu34gtwerbguv3iu74"`
-	Name         string    `json:"name" example:"MyApp"`
-	IconUrl      string    `json:"icon_url" example:"https://rb.gy/1akgfo"`
-	RedirectUrls []string  `json:"redirect_urls" example:"http://localhost:/callback"`
+	UUID                   uuid.UUID `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	ClientID               uuid.UUID `json:"client_id" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	ClientSecret           string    `json:"client_secret" example:"iuhgf3874tiu34gtwerbguv3iu74"`
+	Name                   string    `json:"name" example:"MyApp"`
+	IconUrl                string    `json:"icon_url" example:"https://rb.gy/1akgfo"`
+	RedirectUrls           []string  `json:"redirect_urls" example:"http://localhost:/callback"`
+	PostLogoutRedirectUrls []string  `json:"post_logout_redirect_urls" example:"http://localhost:/logout"`
+	BackchannelLogoutUrl   string    `json:"backchannel_logout_url" example:"http://localhost:/backchannel-logout"`
+
+	Kind           string            `json:"kind" example:"oauth"`
+	EntityID       string            `json:"entity_id,omitempty" example:"http://localhost/saml/metadata"`
+	NameIDFormat   string            `json:"name_id_format,omitempty" example:"email"`
+	SAMLAttributes map[string]string `json:"saml_attributes,omitempty"`
+
+	ClaimMapping []models.ClaimRule `json:"claim_mapping"`
+	MinimumAge   int                `json:"minimum_age" example:"16"`
 }
 
 type appPublicDataSerializer struct {
@@ -53,17 +63,28 @@ type PaginatedAppsPublicSerializer struct {
 // Creates a new app serializer and fills it with
 // the given user data.
 func NewAppSerializer(app models.App) AppSerializer {
-	return AppSerializer{
+	serializer := AppSerializer{
 		ObjectType: "app",
 		Data: appDataSerializer{
-			UUID:         app.UUID,
-			ClientID:     app.ClientID,
-			ClientSecret: app.ClientSecret,
-			Name:         app.Name,
-			IconUrl:      app.IconUrl,
-			RedirectUrls: app.RedirectUrls,
+			UUID:                   app.UUID,
+			ClientID:               app.ClientID,
+			ClientSecret:           app.ClientSecret,
+			Name:                   app.Name,
+			IconUrl:                app.IconUrl,
+			RedirectUrls:           app.RedirectUrls,
+			PostLogoutRedirectUrls: app.PostLogoutRedirectUrls,
+			BackchannelLogoutUrl:   app.BackchannelLogoutUrl,
+			Kind:                   app.Kind,
+			ClaimMapping:           append([]models.ClaimRule{}, app.ClaimMapping...),
+			MinimumAge:             app.MinimumAge,
 		},
 	}
+	if app.IsSAML() {
+		serializer.Data.EntityID = app.EntityID
+		serializer.Data.NameIDFormat = app.NameIDFormat
+		serializer.Data.SAMLAttributes = app.SAMLAttributes
+	}
+	return serializer
 }
 
 // Creates a new app serializer and fills it with
@@ -84,15 +105,7 @@ func NewAppPublicSerializer(app models.App) AppPublicSerializer {
 func NewPaginatedAppsSerializer(apps []models.App, cursor helpers.Cursor) PaginatedAppsSerializer {
 	var serializedApps []appDataSerializer
 	for _, app := range apps {
-		serializedApp := appDataSerializer{
-			UUID:         app.UUID,
-			ClientID:     app.ClientID,
-			ClientSecret: app.ClientSecret,
-			Name:         app.Name,
-			IconUrl:      app.IconUrl,
-			RedirectUrls: app.RedirectUrls,
-		}
-		serializedApps = append(serializedApps, serializedApp)
+		serializedApps = append(serializedApps, NewAppSerializer(app).Data)
 	}
 
 	return PaginatedAppsSerializer{
@@ -24,6 +24,22 @@ func TestAppSerializer(t *testing.T) {
 		assert.Equal([]string(app.RedirectUrls), appSerializer.Data.RedirectUrls)
 	})
 
+	t.Run("Test SAML fields are only serialized for SAML apps", func(t *testing.T) {
+		oauthApp := tests.AppFactory()
+		samlApp := tests.AppFactory()
+		samlApp.Kind = models.AppKindSAML
+		samlApp.EntityID = "https://app.acme.test/saml"
+		samlApp.SAMLAttributes = models.SAMLAttributeMapping{"mail": "email"}
+
+		oauthSerializer := NewAppSerializer(oauthApp)
+		samlSerializer := NewAppSerializer(samlApp)
+
+		assert.Empty(oauthSerializer.Data.EntityID)
+		assert.Equal(models.AppKindSAML, samlSerializer.Data.Kind)
+		assert.Equal(samlApp.EntityID, samlSerializer.Data.EntityID)
+		assert.Equal(map[string]string{"mail": "email"}, samlSerializer.Data.SAMLAttributes)
+	})
+
 	t.Run("Test serialize batch", func(t *testing.T) {
 		apps := []models.App{tests.AppFactory(), tests.AppFactory(), tests.AppFactory()}
 		cursor := helpers.NewCursor(3, 10)
@@ -0,0 +1,71 @@
+package serializers
+
+import (
+	"gandalf/models"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type attributeDefinitionDataSerializer struct {
+	UUID         uuid.UUID `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Name         string    `json:"name" example:"employee_id"`
+	Type         string    `json:"type" example:"string"`
+	UserEditable bool      `json:"user_editable" example:"false"`
+	Releasable   bool      `json:"releasable" example:"false"`
+	Description  string    `json:"description" example:"Id of the employee in the HR system"`
+	Pattern      string    `json:"pattern" example:"E-[0-9]+"`
+	Options      []string  `json:"options" example:"free,pro"`
+	CreatedAt    time.Time `json:"created_at" example:"2021-10-19T08:00:00Z"`
+}
+
+// Attribute definition serialization struct
+type AttributeDefinitionSerializer struct {
+	ObjectType string                            `json:"type" example:"attribute-definition"`
+	Data       attributeDefinitionDataSerializer `json:"data"`
+}
+
+// Attribute definitions serialization struct
+type AttributeDefinitionsSerializer struct {
+	ObjectType string                              `json:"type" example:"attribute-definition"`
+	Data       []attributeDefinitionDataSerializer `json:"data"`
+}
+
+func newAttributeDefinitionDataSerializer(definition models.AttributeDefinition) attributeDefinitionDataSerializer {
+	options := []string{}
+	options = append(options, definition.Options...)
+	return attributeDefinitionDataSerializer{
+		UUID:         definition.UUID,
+		Name:         definition.Name,
+		Type:         definition.Type,
+		UserEditable: definition.UserEditable,
+		Releasable:   definition.Releasable,
+		Description:  definition.Description,
+		Pattern:      definition.Pattern,
+		Options:      options,
+		CreatedAt:    definition.CreatedAt,
+	}
+}
+
+// Creates a new attribute definition serializer and fills it with the
+// given definition data
+func NewAttributeDefinitionSerializer(definition models.AttributeDefinition) AttributeDefinitionSerializer {
+	return AttributeDefinitionSerializer{
+		ObjectType: "attribute-definition",
+		Data:       newAttributeDefinitionDataSerializer(definition),
+	}
+}
+
+// Creates a new attribute definitions serializer and fills it with the
+// given definitions data
+func NewAttributeDefinitionsSerializer(definitions []models.AttributeDefinition) AttributeDefinitionsSerializer {
+	serializedDefinitions := []attributeDefinitionDataSerializer{}
+	for _, definition := range definitions {
+		serializedDefinitions = append(serializedDefinitions, newAttributeDefinitionDataSerializer(definition))
+	}
+
+	return AttributeDefinitionsSerializer{
+		ObjectType: "attribute-definition",
+		Data:       serializedDefinitions,
+	}
+}
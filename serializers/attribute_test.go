@@ -0,0 +1,36 @@
+package serializers
+
+import (
+	"gandalf/models"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeDefinitionSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		definition := models.NewAttributeDefinition("plan", models.AttributeTypeString, true)
+		definition.Options = []string{"free", "pro"}
+		serialized := NewAttributeDefinitionSerializer(definition)
+
+		assert.Equal("attribute-definition", serialized.ObjectType)
+		assert.Equal("plan", serialized.Data.Name)
+		assert.Equal(models.AttributeTypeString, serialized.Data.Type)
+		assert.True(serialized.Data.UserEditable)
+		assert.Equal([]string{"free", "pro"}, serialized.Data.Options)
+	})
+
+	t.Run("Test serialize empty batch", func(t *testing.T) {
+		assert.Equal([]attributeDefinitionDataSerializer{}, NewAttributeDefinitionsSerializer(nil).Data)
+		assert.Equal([]string{}, NewAttributeDefinitionSerializer(models.AttributeDefinition{}).Data.Options)
+	})
+
+	t.Run("Test users without attributes are serialized with an empty object", func(t *testing.T) {
+		assert.Equal(map[string]interface{}{}, NewUserSerializer(models.User{}).Data.Attributes)
+
+		user := models.User{Attributes: models.UserAttributes{"plan": "pro"}}
+		assert.Equal(map[string]interface{}{"plan": "pro"}, NewUserSerializer(user).Data.Attributes)
+	})
+}
@@ -0,0 +1,64 @@
+package serializers
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type auditEventDataSerializer struct {
+	UUID      uuid.UUID            `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Action    string               `json:"action" example:"login"`
+	Outcome   string               `json:"outcome" example:"success"`
+	IP        string               `json:"ip" example:"127.0.0.1"`
+	UserAgent string               `json:"user_agent" example:"Mozilla/5.0 (X11; Linux x86_64)"`
+	Metadata  models.AuditMetadata `json:"metadata"`
+	Actor     *uuid.UUID           `json:"actor" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Subject   *uuid.UUID           `json:"subject" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	CreatedAt time.Time            `json:"created_at" example:"2021-10-19T08:00:00Z"`
+}
+
+type paginatedAuditEventsSerializerMeta struct {
+	Cursor CursorSerializer `json:"cursor"`
+}
+
+// Audit events serialization struct
+type PaginatedAuditEventsSerializer struct {
+	ObjectType string                             `json:"type" example:"audit-event"`
+	Data       []auditEventDataSerializer         `json:"data"`
+	Meta       paginatedAuditEventsSerializerMeta `json:"meta"`
+}
+
+// Creates a new audit events serializer and fills it with the given
+// events data
+func NewPaginatedAuditEventsSerializer(events []models.AuditEvent, cursor helpers.Cursor) PaginatedAuditEventsSerializer {
+	var serializedEvents []auditEventDataSerializer
+	for _, event := range events {
+		serializedEvent := auditEventDataSerializer{
+			UUID:      event.UUID,
+			Action:    event.Action,
+			Outcome:   event.Outcome,
+			IP:        event.IP,
+			UserAgent: event.UserAgent,
+			Metadata:  event.Metadata,
+			CreatedAt: event.CreatedAt,
+		}
+		if event.Actor != nil {
+			serializedEvent.Actor = &event.Actor.UUID
+		}
+		if event.Subject != nil {
+			serializedEvent.Subject = &event.Subject.UUID
+		}
+		serializedEvents = append(serializedEvents, serializedEvent)
+	}
+
+	return PaginatedAuditEventsSerializer{
+		ObjectType: "audit-event",
+		Data:       serializedEvents,
+		Meta: paginatedAuditEventsSerializerMeta{
+			Cursor: NewCursorSerializer(cursor),
+		},
+	}
+}
@@ -0,0 +1,40 @@
+package serializers
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestAuditEventSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test serialize batch", func(t *testing.T) {
+		actor := models.User{Email: faker.Internet().Email()}
+		actor.UUID, _ = uuid.NewV4()
+		login := models.NewAuditEvent(
+			models.AuditActionLogin, models.AuditOutcomeSuccess, &actor, &actor,
+			"agent", "127.0.0.1", models.AuditMetadata{"email": actor.Email},
+		)
+		login.Actor = &actor
+		login.Subject = &actor
+		failure := models.NewAuditEvent(
+			models.AuditActionLogin, models.AuditOutcomeFailure, nil, nil, "other", "127.0.0.2", nil,
+		)
+
+		cursor := helpers.NewCursor(0, 10)
+		eventsSerializer := NewPaginatedAuditEventsSerializer([]models.AuditEvent{login, failure}, cursor)
+
+		assert.Equal("audit-event", eventsSerializer.ObjectType)
+		assert.Equal(2, len(eventsSerializer.Data))
+		assert.Equal(actor.UUID, *eventsSerializer.Data[0].Actor)
+		assert.Equal(actor.UUID, *eventsSerializer.Data[0].Subject)
+		assert.Equal(actor.Email, eventsSerializer.Data[0].Metadata["email"])
+		assert.Nil(eventsSerializer.Data[1].Actor)
+		assert.Equal(models.AuditOutcomeFailure, eventsSerializer.Data[1].Outcome)
+	})
+}
@@ -9,6 +9,7 @@ type TokensSerializer struct {
 	RefreshToken string `json:"refresh_token" example:"kpvaG4gRG9lIiwiaWF0IjoxNTE2MjM5MDIyf"`
 	TokenType    string `json:"token_type" example:"Bearer"`
 	ExpiresIn    int64  `json:"expires_in" example:"3600"`
+	IDToken      string `json:"id_token,omitempty" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ8"`
 }
 
 // Creates a new user serializer
@@ -18,5 +19,45 @@ func NewTokensSerializer(tokens services.AuthTokens) TokensSerializer {
 		RefreshToken: tokens.RefreshToken,
 		TokenType:    "Bearer",
 		ExpiresIn:    int64(tokens.ExpiresIn),
+		IDToken:      tokens.IDToken,
+	}
+}
+
+// Magic link serialization struct. The binding secret must be kept by the
+// browser which asked for the link, and sent along with its code.
+type MagicLinkSerializer struct {
+	Binding string `json:"binding" example:"Zp4sW9-qLx2Bn7Vt"`
+}
+
+// Creates a new magic link serializer
+func NewMagicLinkSerializer(binding string) MagicLinkSerializer {
+	return MagicLinkSerializer{Binding: binding}
+}
+
+// Federated authorization serialization struct
+type FederatedAuthorizationSerializer struct {
+	AuthorizationURL string `json:"authorization_url" example:"https://idp.acme.com/authorize?client_id=gandalf&state=hG3k0-aPq9Lm2xZ7"`
+	State            string `json:"state" example:"hG3k0-aPq9Lm2xZ7"`
+}
+
+// Creates a new federated authorization serializer
+func NewFederatedAuthorizationSerializer(url string, state string) FederatedAuthorizationSerializer {
+	return FederatedAuthorizationSerializer{AuthorizationURL: url, State: state}
+}
+
+// SAML response serialization struct. The browser must post the response
+// and the relay state to the assertion consumer service url of the app.
+type SAMLResponseSerializer struct {
+	ACSURL       string `json:"acs_url" example:"https://app.acme.com/saml/acs"`
+	SAMLResponse string `json:"saml_response" example:"PHNhbWxwOlJlc3BvbnNlIHhtbG5zOnNhbWxwPSJ1cm4="`
+	RelayState   string `json:"relay_state" example:"/dashboard"`
+}
+
+// Creates a new SAML response serializer
+func NewSAMLResponseSerializer(response services.SAMLResponse) SAMLResponseSerializer {
+	return SAMLResponseSerializer{
+		ACSURL:       response.ACSURL,
+		SAMLResponse: response.Response,
+		RelayState:   response.RelayState,
 	}
 }
@@ -24,3 +24,28 @@ func TestTokensSerializer(t *testing.T) {
 		assert.Equal(serializedTokens.RefreshToken, refreshToken)
 	})
 }
+
+func TestFederatedAuthorizationSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		serialized := NewFederatedAuthorizationSerializer("https://idp.acme.test/authorize", "state")
+
+		assert.Equal("https://idp.acme.test/authorize", serialized.AuthorizationURL)
+		assert.Equal("state", serialized.State)
+	})
+}
+
+func TestSAMLResponseSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		response := services.SAMLResponse{ACSURL: "https://app.acme.test/acs", Response: "PHJlc3BvbnNlLz4=", RelayState: "/home"}
+
+		serialized := NewSAMLResponseSerializer(response)
+
+		assert.Equal("https://app.acme.test/acs", serialized.ACSURL)
+		assert.Equal("PHJlc3BvbnNlLz4=", serialized.SAMLResponse)
+		assert.Equal("/home", serialized.RelayState)
+	})
+}
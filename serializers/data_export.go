@@ -0,0 +1,37 @@
+package serializers
+
+import (
+	"gandalf/models"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type dataExportDataSerializer struct {
+	UUID      uuid.UUID  `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Status    string     `json:"status" example:"ready"`
+	CreatedAt time.Time  `json:"created_at" example:"2021-10-19T08:00:00Z"`
+	ReadyAt   *time.Time `json:"ready_at" example:"2021-10-19T08:01:00Z"`
+	ExpiresAt *time.Time `json:"expires_at" example:"2021-10-21T08:01:00Z"`
+}
+
+// Data export serialization struct
+type DataExportSerializer struct {
+	ObjectType string                   `json:"type" example:"data-export"`
+	Data       dataExportDataSerializer `json:"data"`
+}
+
+// Creates a new data export serializer and fills it with the given export
+// data. The archive is downloaded on its own.
+func NewDataExportSerializer(export models.DataExport) DataExportSerializer {
+	return DataExportSerializer{
+		ObjectType: "data-export",
+		Data: dataExportDataSerializer{
+			UUID:      export.UUID,
+			Status:    export.Status,
+			CreatedAt: export.CreatedAt,
+			ReadyAt:   export.ReadyAt,
+			ExpiresAt: export.ExpiresAt,
+		},
+	}
+}
@@ -0,0 +1,27 @@
+package serializers
+
+import (
+	"gandalf/models"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataExportSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test serialize", func(t *testing.T) {
+		export := models.NewDataExport(models.User{})
+		export.UUID, _ = uuid.NewV4()
+		export.Complete(`{"profile": {}}`, time.Hour)
+
+		serializer := NewDataExportSerializer(export)
+
+		assert.Equal("data-export", serializer.ObjectType)
+		assert.Equal(export.UUID, serializer.Data.UUID)
+		assert.Equal(models.DataExportReady, serializer.Data.Status)
+		assert.Equal(export.ExpiresAt, serializer.Data.ExpiresAt)
+	})
+}
@@ -0,0 +1,104 @@
+package serializers
+
+import (
+	"gandalf/models"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type identityProviderDataSerializer struct {
+	UUID             uuid.UUID `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Name             string    `json:"name" example:"acme"`
+	DisplayName      string    `json:"display_name" example:"Acme Corp"`
+	Issuer           string    `json:"issuer" example:"https://idp.acme.com"`
+	AuthorizationURL string    `json:"authorization_url" example:"https://idp.acme.com/authorize"`
+	TokenURL         string    `json:"token_url" example:"https://idp.acme.com/token"`
+	JWKSURL          string    `json:"jwks_url" example:"https://idp.acme.com/jwks"`
+	ClientID         string    `json:"client_id" example:"gandalf"`
+	Scopes           []string  `json:"scopes" example:"email,profile"`
+	Disabled         bool      `json:"disabled" example:"false"`
+	CreatedAt        time.Time `json:"created_at" example:"2021-10-19T08:00:00Z"`
+}
+
+// Identity provider serialization struct. The client secret is never
+// serialized.
+type IdentityProviderSerializer struct {
+	ObjectType string                         `json:"type" example:"identity-provider"`
+	Data       identityProviderDataSerializer `json:"data"`
+}
+
+// Identity providers serialization struct
+type IdentityProvidersSerializer struct {
+	ObjectType string                           `json:"type" example:"identity-provider"`
+	Data       []identityProviderDataSerializer `json:"data"`
+}
+
+func newIdentityProviderDataSerializer(provider models.IdentityProvider) identityProviderDataSerializer {
+	scopes := []string{}
+	scopes = append(scopes, provider.Scopes...)
+	return identityProviderDataSerializer{
+		UUID:             provider.UUID,
+		Name:             provider.Name,
+		DisplayName:      provider.DisplayName,
+		Issuer:           provider.Issuer,
+		AuthorizationURL: provider.AuthorizationURL,
+		TokenURL:         provider.TokenURL,
+		JWKSURL:          provider.JWKSURL,
+		ClientID:         provider.ClientID,
+		Scopes:           scopes,
+		Disabled:         provider.Disabled,
+		CreatedAt:        provider.CreatedAt,
+	}
+}
+
+// Creates a new identity provider serializer and fills it with the given
+// provider data
+func NewIdentityProviderSerializer(provider models.IdentityProvider) IdentityProviderSerializer {
+	return IdentityProviderSerializer{
+		ObjectType: "identity-provider",
+		Data:       newIdentityProviderDataSerializer(provider),
+	}
+}
+
+// Creates a new identity providers serializer and fills it with the given
+// providers data
+func NewIdentityProvidersSerializer(providers []models.IdentityProvider) IdentityProvidersSerializer {
+	serializedProviders := []identityProviderDataSerializer{}
+	for _, provider := range providers {
+		serializedProviders = append(serializedProviders, newIdentityProviderDataSerializer(provider))
+	}
+
+	return IdentityProvidersSerializer{
+		ObjectType: "identity-provider",
+		Data:       serializedProviders,
+	}
+}
+
+type federatedProviderDataSerializer struct {
+	Name        string `json:"name" example:"acme"`
+	DisplayName string `json:"display_name" example:"Acme Corp"`
+}
+
+// Public serialization struct of the providers the users can sign in with
+type FederatedProvidersSerializer struct {
+	ObjectType string                            `json:"type" example:"federated-provider"`
+	Data       []federatedProviderDataSerializer `json:"data"`
+}
+
+// Creates a new serializer of the providers the users can sign in with,
+// which only exposes how to present them
+func NewFederatedProvidersSerializer(providers []models.IdentityProvider) FederatedProvidersSerializer {
+	serializedProviders := []federatedProviderDataSerializer{}
+	for _, provider := range providers {
+		serializedProviders = append(serializedProviders, federatedProviderDataSerializer{
+			Name:        provider.Name,
+			DisplayName: provider.DisplayName,
+		})
+	}
+
+	return FederatedProvidersSerializer{
+		ObjectType: "federated-provider",
+		Data:       serializedProviders,
+	}
+}
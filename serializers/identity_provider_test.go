@@ -0,0 +1,46 @@
+package serializers
+
+import (
+	"encoding/json"
+	"gandalf/models"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityProviderSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		provider := models.NewIdentityProvider("acme", "Acme", "https://idp.acme.test", "gandalf", "secret")
+		provider.Scopes = []string{"email"}
+		serialized := NewIdentityProviderSerializer(provider)
+
+		assert.Equal("identity-provider", serialized.ObjectType)
+		assert.Equal("acme", serialized.Data.Name)
+		assert.Equal("Acme", serialized.Data.DisplayName)
+		assert.Equal("https://idp.acme.test", serialized.Data.Issuer)
+		assert.Equal("gandalf", serialized.Data.ClientID)
+		assert.Equal([]string{"email"}, serialized.Data.Scopes)
+	})
+
+	t.Run("Test client secret is never serialized", func(t *testing.T) {
+		provider := models.NewIdentityProvider("acme", "Acme", "https://idp.acme.test", "gandalf", "topsecret")
+		body, _ := json.Marshal(NewIdentityProviderSerializer(provider))
+
+		assert.NotContains(string(body), "topsecret")
+	})
+
+	t.Run("Test serialize empty batch", func(t *testing.T) {
+		assert.Equal([]identityProviderDataSerializer{}, NewIdentityProvidersSerializer(nil).Data)
+		assert.Equal([]federatedProviderDataSerializer{}, NewFederatedProvidersSerializer(nil).Data)
+	})
+
+	t.Run("Test public serializer only exposes the presentation", func(t *testing.T) {
+		provider := models.NewIdentityProvider("acme", "Acme", "https://idp.acme.test", "gandalf", "topsecret")
+		serialized := NewFederatedProvidersSerializer([]models.IdentityProvider{provider})
+
+		assert.Equal("acme", serialized.Data[0].Name)
+		assert.Equal("Acme", serialized.Data[0].DisplayName)
+	})
+}
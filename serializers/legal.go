@@ -0,0 +1,130 @@
+package serializers
+
+import (
+	"gandalf/models"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type legalDocumentDataSerializer struct {
+	UUID        uuid.UUID                `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Kind        string                   `json:"kind" example:"terms"`
+	Version     string                   `json:"version" example:"2021-10"`
+	Title       string                   `json:"title" example:"Terms of service"`
+	URL         string                   `json:"url" example:"https://example.com/terms/2021-10"`
+	Summary     string                   `json:"summary" example:"We now keep the sessions for 90 days"`
+	Mandatory   bool                     `json:"mandatory" example:"true"`
+	PublishedAt *time.Time               `json:"published_at" example:"2021-10-19T08:00:00Z"`
+	CreatedAt   time.Time                `json:"created_at" example:"2021-10-19T08:00:00Z"`
+	App         *appPublicDataSerializer `json:"app"`
+}
+
+// Legal document serialization struct
+type LegalDocumentSerializer struct {
+	ObjectType string                      `json:"type" example:"legal-document"`
+	Data       legalDocumentDataSerializer `json:"data"`
+}
+
+// Legal documents serialization struct
+type LegalDocumentsSerializer struct {
+	ObjectType string                        `json:"type" example:"legal-document"`
+	Data       []legalDocumentDataSerializer `json:"data"`
+}
+
+type legalAcceptanceDataSerializer struct {
+	UUID       uuid.UUID                   `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Document   legalDocumentDataSerializer `json:"document"`
+	AcceptedAt time.Time                   `json:"accepted_at" example:"2021-10-19T08:00:00Z"`
+	IP         string                      `json:"ip" example:"127.0.0.1"`
+	UserAgent  string                      `json:"user_agent" example:"Mozilla/5.0 (X11; Linux x86_64)"`
+}
+
+// Legal acceptances serialization struct
+type LegalAcceptancesSerializer struct {
+	ObjectType string                          `json:"type" example:"legal-acceptance"`
+	Data       []legalAcceptanceDataSerializer `json:"data"`
+}
+
+// Serialization struct for the error answered when the user must accept
+// legal documents first. It carries the documents he has to accept.
+type LegalAcceptanceRequiredSerializer struct {
+	Code      int                           `json:"code" example:"403"`
+	Error     string                        `json:"error" example:"Legal documents must be accepted"`
+	Documents []legalDocumentDataSerializer `json:"documents"`
+}
+
+func newLegalDocumentDataSerializer(document models.LegalDocument) legalDocumentDataSerializer {
+	serializer := legalDocumentDataSerializer{
+		UUID:        document.UUID,
+		Kind:        document.Kind,
+		Version:     document.Version,
+		Title:       document.Title,
+		URL:         document.URL,
+		Summary:     document.Summary,
+		Mandatory:   document.Mandatory,
+		PublishedAt: document.PublishedAt,
+		CreatedAt:   document.CreatedAt,
+	}
+	if document.App != nil {
+		app := NewAppPublicSerializer(*document.App).Data
+		serializer.App = &app
+	}
+	return serializer
+}
+
+func newLegalDocumentsDataSerializer(documents []models.LegalDocument) []legalDocumentDataSerializer {
+	serializedDocuments := []legalDocumentDataSerializer{}
+	for _, document := range documents {
+		serializedDocuments = append(serializedDocuments, newLegalDocumentDataSerializer(document))
+	}
+	return serializedDocuments
+}
+
+// Creates a new legal document serializer and fills it with the given
+// document data
+func NewLegalDocumentSerializer(document models.LegalDocument) LegalDocumentSerializer {
+	return LegalDocumentSerializer{
+		ObjectType: "legal-document",
+		Data:       newLegalDocumentDataSerializer(document),
+	}
+}
+
+// Creates a new legal documents serializer and fills it with the given
+// documents data
+func NewLegalDocumentsSerializer(documents []models.LegalDocument) LegalDocumentsSerializer {
+	return LegalDocumentsSerializer{
+		ObjectType: "legal-document",
+		Data:       newLegalDocumentsDataSerializer(documents),
+	}
+}
+
+// Creates a new legal acceptances serializer and fills it with the given
+// acceptances data
+func NewLegalAcceptancesSerializer(acceptances []models.LegalAcceptance) LegalAcceptancesSerializer {
+	serializedAcceptances := []legalAcceptanceDataSerializer{}
+	for _, acceptance := range acceptances {
+		serializedAcceptances = append(serializedAcceptances, legalAcceptanceDataSerializer{
+			UUID:       acceptance.UUID,
+			Document:   newLegalDocumentDataSerializer(acceptance.LegalDocument),
+			AcceptedAt: acceptance.AcceptedAt,
+			IP:         acceptance.IP,
+			UserAgent:  acceptance.UserAgent,
+		})
+	}
+
+	return LegalAcceptancesSerializer{
+		ObjectType: "legal-acceptance",
+		Data:       serializedAcceptances,
+	}
+}
+
+// Creates a new legal acceptance required serializer with the given status,
+// message and pending documents
+func NewLegalAcceptanceRequiredSerializer(status int, message string, documents []models.LegalDocument) LegalAcceptanceRequiredSerializer {
+	return LegalAcceptanceRequiredSerializer{
+		Code:      status,
+		Error:     message,
+		Documents: newLegalDocumentsDataSerializer(documents),
+	}
+}
@@ -0,0 +1,65 @@
+package serializers
+
+import (
+	"gandalf/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegalDocumentSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		document := models.NewLegalDocument(models.LegalDocumentTerms, "2021-10", "Terms of service", "https://example.com/terms", true, nil)
+		serialized := NewLegalDocumentSerializer(document)
+
+		assert.Equal("legal-document", serialized.ObjectType)
+		assert.Equal(models.LegalDocumentTerms, serialized.Data.Kind)
+		assert.Equal("2021-10", serialized.Data.Version)
+		assert.True(serialized.Data.Mandatory)
+		assert.Nil(serialized.Data.PublishedAt)
+		assert.Nil(serialized.Data.App)
+	})
+
+	t.Run("Test app documents are serialized with their app", func(t *testing.T) {
+		app := models.App{Name: "MyApp"}
+		document := models.NewLegalDocument(models.LegalDocumentPrivacy, "v2", "Privacy policy", "https://example.com/privacy", false, &app)
+		document.App = &app
+
+		serialized := NewLegalDocumentSerializer(document)
+
+		assert.Equal("MyApp", serialized.Data.App.Name)
+	})
+
+	t.Run("Test serialize empty batch", func(t *testing.T) {
+		assert.Equal([]legalDocumentDataSerializer{}, NewLegalDocumentsSerializer(nil).Data)
+		assert.Equal([]legalAcceptanceDataSerializer{}, NewLegalAcceptancesSerializer(nil).Data)
+	})
+
+	t.Run("Test acceptances", func(t *testing.T) {
+		document := models.NewLegalDocument(models.LegalDocumentTerms, "v1", "Terms", "https://example.com/terms", true, nil)
+		document.Publish(time.Now())
+		acceptance := models.NewLegalAcceptance(models.User{}, document, "203.0.113.7", "Firefox")
+		acceptance.LegalDocument = document
+
+		serialized := NewLegalAcceptancesSerializer([]models.LegalAcceptance{acceptance})
+
+		assert.Equal("legal-acceptance", serialized.ObjectType)
+		assert.Equal("v1", serialized.Data[0].Document.Version)
+		assert.Equal("203.0.113.7", serialized.Data[0].IP)
+		assert.Equal(acceptance.AcceptedAt, serialized.Data[0].AcceptedAt)
+	})
+
+	t.Run("Test acceptance required", func(t *testing.T) {
+		document := models.NewLegalDocument(models.LegalDocumentTerms, "v1", "Terms", "https://example.com/terms", true, nil)
+
+		serialized := NewLegalAcceptanceRequiredSerializer(http.StatusForbidden, "Legal documents must be accepted", []models.LegalDocument{document})
+
+		assert.Equal(http.StatusForbidden, serialized.Code)
+		assert.Equal("Legal documents must be accepted", serialized.Error)
+		assert.Equal("v1", serialized.Documents[0].Version)
+	})
+}
@@ -0,0 +1,93 @@
+package serializers
+
+import (
+	"gandalf/models"
+	"gandalf/services"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type notificationTemplateDataSerializer struct {
+	UUID      uuid.UUID `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Kind      string    `json:"kind" example:"user-verify-email"`
+	Locale    string    `json:"locale" example:"es"`
+	Subject   string    `json:"subject" example:"Bienvenido {{.Name}}"`
+	Text      string    `json:"text" example:"Verifica tu email: {{.Link}}"`
+	HTML      string    `json:"html" example:"<a href=\"{{.Link}}\">Verifica tu email</a>"`
+	UpdatedAt time.Time `json:"updated_at" example:"2021-10-19T08:00:00Z"`
+}
+
+func newNotificationTemplateDataSerializer(template models.NotificationTemplate) notificationTemplateDataSerializer {
+	return notificationTemplateDataSerializer{
+		UUID:      template.UUID,
+		Kind:      template.Kind,
+		Locale:    template.Locale,
+		Subject:   template.Subject,
+		Text:      template.Text,
+		HTML:      template.HTML,
+		UpdatedAt: template.UpdatedAt,
+	}
+}
+
+// Notification template serialization struct
+type NotificationTemplateSerializer struct {
+	ObjectType string                             `json:"type" example:"notification-template"`
+	Data       notificationTemplateDataSerializer `json:"data"`
+}
+
+// Creates a new notification template serializer and fills it with the
+// given template data
+func NewNotificationTemplateSerializer(template models.NotificationTemplate) NotificationTemplateSerializer {
+	return NotificationTemplateSerializer{
+		ObjectType: "notification-template",
+		Data:       newNotificationTemplateDataSerializer(template),
+	}
+}
+
+// Notification templates serialization struct
+type NotificationTemplatesSerializer struct {
+	ObjectType string                               `json:"type" example:"notification-template"`
+	Data       []notificationTemplateDataSerializer `json:"data"`
+}
+
+// Creates a new notification templates serializer and fills it with the
+// given templates data
+func NewNotificationTemplatesSerializer(templates []models.NotificationTemplate) NotificationTemplatesSerializer {
+	serializedTemplates := []notificationTemplateDataSerializer{}
+	for _, template := range templates {
+		serializedTemplates = append(serializedTemplates, newNotificationTemplateDataSerializer(template))
+	}
+
+	return NotificationTemplatesSerializer{
+		ObjectType: "notification-template",
+		Data:       serializedTemplates,
+	}
+}
+
+type notificationPreviewDataSerializer struct {
+	Locale  string `json:"locale" example:"es"`
+	Subject string `json:"subject" example:"Bienvenido John"`
+	Text    string `json:"text" example:"Verifica tu email: https://example.com/?code=hG3k0-aPq9Lm2xZ7"`
+	HTML    string `json:"html" example:"<a href=\"https://example.com/?code=hG3k0-aPq9Lm2xZ7\">Verifica tu email</a>"`
+}
+
+// Notification preview serialization struct
+type NotificationPreviewSerializer struct {
+	ObjectType string                            `json:"type" example:"notification-preview"`
+	Data       notificationPreviewDataSerializer `json:"data"`
+}
+
+// Creates a new notification preview serializer and fills it with the given
+// rendered notification
+func NewNotificationPreviewSerializer(rendered services.RenderedNotification) NotificationPreviewSerializer {
+	return NotificationPreviewSerializer{
+		ObjectType: "notification-preview",
+		Data: notificationPreviewDataSerializer{
+			Locale:  rendered.Locale,
+			Subject: rendered.Subject,
+			Text:    rendered.Text,
+			HTML:    rendered.HTML,
+		},
+	}
+}
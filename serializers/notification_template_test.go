@@ -0,0 +1,42 @@
+package serializers
+
+import (
+	"gandalf/models"
+	"gandalf/services"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationTemplateSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test serialize template", func(t *testing.T) {
+		template := models.NewNotificationTemplate(models.OutboxKindUserVerifyEmail, "es", "Hola", "{{.Link}}", "<p>{{.Link}}</p>", nil)
+
+		serializer := NewNotificationTemplateSerializer(template)
+
+		assert.Equal("notification-template", serializer.ObjectType)
+		assert.Equal(models.OutboxKindUserVerifyEmail, serializer.Data.Kind)
+		assert.Equal("es", serializer.Data.Locale)
+		assert.Equal("<p>{{.Link}}</p>", serializer.Data.HTML)
+	})
+
+	t.Run("Test serialize empty batch", func(t *testing.T) {
+		serializer := NewNotificationTemplatesSerializer(nil)
+
+		assert.Equal("notification-template", serializer.ObjectType)
+		assert.NotNil(serializer.Data)
+		assert.Equal(0, len(serializer.Data))
+	})
+
+	t.Run("Test serialize preview", func(t *testing.T) {
+		rendered := services.RenderedNotification{Locale: "en", Subject: "Hi", Text: "text", HTML: "<p>html</p>"}
+
+		serializer := NewNotificationPreviewSerializer(rendered)
+
+		assert.Equal("notification-preview", serializer.ObjectType)
+		assert.Equal("Hi", serializer.Data.Subject)
+		assert.Equal("<p>html</p>", serializer.Data.HTML)
+	})
+}
@@ -0,0 +1,136 @@
+package serializers
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type organizationDataSerializer struct {
+	UUID uuid.UUID `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Name string    `json:"name" example:"Antartical"`
+}
+
+type membershipDataSerializer struct {
+	Role string             `json:"role" example:"admin"`
+	User userDataSerializer `json:"user"`
+}
+
+type invitationDataSerializer struct {
+	UUID      uuid.UUID `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Email     string    `json:"email" example:"johndoe@example.com"`
+	Role      string    `json:"role" example:"member"`
+	ExpiresAt time.Time `json:"expires_at" example:"2021-10-19T08:00:00Z"`
+}
+
+// Organization serialization struct
+type OrganizationSerializer struct {
+	ObjectType string                     `json:"type" example:"organization"`
+	Data       organizationDataSerializer `json:"data"`
+}
+
+// Membership serialization struct
+type MembershipSerializer struct {
+	ObjectType string                   `json:"type" example:"membership"`
+	Data       membershipDataSerializer `json:"data"`
+}
+
+// Invitation serialization struct
+type InvitationSerializer struct {
+	ObjectType string                   `json:"type" example:"invitation"`
+	Data       invitationDataSerializer `json:"data"`
+}
+
+type paginatedOrganizationsSerializerMeta struct {
+	Cursor CursorSerializer `json:"cursor"`
+}
+
+type PaginatedOrganizationsSerializer struct {
+	ObjectType string                               `json:"type" example:"organization"`
+	Data       []organizationDataSerializer         `json:"data"`
+	Meta       paginatedOrganizationsSerializerMeta `json:"meta"`
+}
+
+type PaginatedMembershipsSerializer struct {
+	ObjectType string                               `json:"type" example:"membership"`
+	Data       []membershipDataSerializer           `json:"data"`
+	Meta       paginatedOrganizationsSerializerMeta `json:"meta"`
+}
+
+func newMembershipDataSerializer(membership models.Membership) membershipDataSerializer {
+	return membershipDataSerializer{
+		Role: membership.Role,
+		User: NewUserSerializer(membership.User).Data,
+	}
+}
+
+// Creates a new organization serializer and fills it with
+// the given organization data.
+func NewOrganizationSerializer(organization models.Organization) OrganizationSerializer {
+	return OrganizationSerializer{
+		ObjectType: "organization",
+		Data: organizationDataSerializer{
+			UUID: organization.UUID,
+			Name: organization.Name,
+		},
+	}
+}
+
+// Creates a new membership serializer and fills it with
+// the given membership data.
+func NewMembershipSerializer(membership models.Membership) MembershipSerializer {
+	return MembershipSerializer{
+		ObjectType: "membership",
+		Data:       newMembershipDataSerializer(membership),
+	}
+}
+
+// Creates a new invitation serializer and fills it with
+// the given invitation data.
+func NewInvitationSerializer(invitation models.Invitation) InvitationSerializer {
+	return InvitationSerializer{
+		ObjectType: "invitation",
+		Data: invitationDataSerializer{
+			UUID:      invitation.UUID,
+			Email:     invitation.Email,
+			Role:      invitation.Role,
+			ExpiresAt: invitation.ExpiresAt,
+		},
+	}
+}
+
+// Creates a new organizations serializer and fills it with
+// the given organizations data.
+func NewPaginatedOrganizationsSerializer(organizations []models.Organization, cursor helpers.Cursor) PaginatedOrganizationsSerializer {
+	var serializedOrganizations []organizationDataSerializer
+	for _, organization := range organizations {
+		serializedOrganizations = append(serializedOrganizations, NewOrganizationSerializer(organization).Data)
+	}
+
+	return PaginatedOrganizationsSerializer{
+		ObjectType: "organization",
+		Data:       serializedOrganizations,
+		Meta: paginatedOrganizationsSerializerMeta{
+			Cursor: NewCursorSerializer(cursor),
+		},
+	}
+}
+
+// Creates a new memberships serializer and fills it with
+// the given memberships data.
+func NewPaginatedMembershipsSerializer(memberships []models.Membership, cursor helpers.Cursor) PaginatedMembershipsSerializer {
+	var serializedMemberships []membershipDataSerializer
+	for _, membership := range memberships {
+		serializedMemberships = append(serializedMemberships, newMembershipDataSerializer(membership))
+	}
+
+	return PaginatedMembershipsSerializer{
+		ObjectType: "membership",
+		Data:       serializedMemberships,
+		Meta: paginatedOrganizationsSerializerMeta{
+			Cursor: NewCursorSerializer(cursor),
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package serializers
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/tests"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestOrganizationSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		organization := models.NewOrganization(faker.Company().Name())
+		organizationSerializer := NewOrganizationSerializer(organization)
+
+		assert.Equal("organization", organizationSerializer.ObjectType)
+		assert.Equal(organization.Name, organizationSerializer.Data.Name)
+	})
+
+	t.Run("Test serialize batch", func(t *testing.T) {
+		organizations := []models.Organization{models.NewOrganization("a"), models.NewOrganization("b")}
+		cursor := helpers.NewCursor(1, 10)
+		organizationsSerializer := NewPaginatedOrganizationsSerializer(organizations, cursor)
+		assert.Equal(len(organizationsSerializer.Data), 2)
+	})
+
+	t.Run("Test serialize membership", func(t *testing.T) {
+		user := tests.UserFactory()
+		membership := models.NewMembership(models.Organization{}, user, models.MembershipAdmin)
+		membership.User = user
+		membershipSerializer := NewMembershipSerializer(membership)
+
+		assert.Equal(models.MembershipAdmin, membershipSerializer.Data.Role)
+		assert.Equal(user.Email, membershipSerializer.Data.User.Email)
+	})
+
+	t.Run("Test serialize membership batch", func(t *testing.T) {
+		memberships := []models.Membership{{Role: models.MembershipOwner}}
+		cursor := helpers.NewCursor(1, 10)
+		membershipsSerializer := NewPaginatedMembershipsSerializer(memberships, cursor)
+		assert.Equal(len(membershipsSerializer.Data), 1)
+	})
+
+	t.Run("Test serialize invitation", func(t *testing.T) {
+		email := faker.Internet().Email()
+		invitation := models.NewInvitation(models.Organization{}, models.User{}, email, models.MembershipMember, time.Hour)
+		invitationSerializer := NewInvitationSerializer(invitation)
+
+		assert.Equal(email, invitationSerializer.Data.Email)
+		assert.Equal(models.MembershipMember, invitationSerializer.Data.Role)
+		assert.Equal(invitation.ExpiresAt, invitationSerializer.Data.ExpiresAt)
+	})
+}
@@ -0,0 +1,60 @@
+package serializers
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type outboxMessageDataSerializer struct {
+	UUID          uuid.UUID  `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Kind          string     `json:"kind" example:"user-verify-email"`
+	Recipient     string     `json:"recipient" example:"frodo@bolson.sh"`
+	Status        string     `json:"status" example:"dead"`
+	Attempts      int        `json:"attempts" example:"5"`
+	NextAttemptAt *time.Time `json:"next_attempt_at" example:"2021-10-19T08:00:00Z"`
+	DeliveredAt   *time.Time `json:"delivered_at" example:"2021-10-19T08:00:00Z"`
+	LastError     string     `json:"last_error" example:"unexpected status 502"`
+	CreatedAt     time.Time  `json:"created_at" example:"2021-10-19T08:00:00Z"`
+}
+
+type paginatedOutboxMessagesSerializerMeta struct {
+	Cursor CursorSerializer `json:"cursor"`
+}
+
+// Outbox messages serialization struct. Payloads are never serialized as
+// they may carry one time secrets.
+type PaginatedOutboxMessagesSerializer struct {
+	ObjectType string                                `json:"type" example:"outbox-message"`
+	Data       []outboxMessageDataSerializer         `json:"data"`
+	Meta       paginatedOutboxMessagesSerializerMeta `json:"meta"`
+}
+
+// Creates a new outbox messages serializer and fills it with the given
+// messages data
+func NewPaginatedOutboxMessagesSerializer(messages []models.OutboxMessage, cursor helpers.Cursor) PaginatedOutboxMessagesSerializer {
+	var serializedMessages []outboxMessageDataSerializer
+	for _, message := range messages {
+		serializedMessages = append(serializedMessages, outboxMessageDataSerializer{
+			UUID:          message.UUID,
+			Kind:          message.Kind,
+			Recipient:     message.Recipient,
+			Status:        message.Status,
+			Attempts:      message.Attempts,
+			NextAttemptAt: message.NextAttemptAt,
+			DeliveredAt:   message.DeliveredAt,
+			LastError:     message.LastError,
+			CreatedAt:     message.CreatedAt,
+		})
+	}
+
+	return PaginatedOutboxMessagesSerializer{
+		ObjectType: "outbox-message",
+		Data:       serializedMessages,
+		Meta: paginatedOutboxMessagesSerializerMeta{
+			Cursor: NewCursorSerializer(cursor),
+		},
+	}
+}
@@ -0,0 +1,34 @@
+package serializers
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestOutboxMessageSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test serialize batch", func(t *testing.T) {
+		email := faker.Internet().Email()
+		pending := models.NewOutboxMessage(models.OutboxKindUserVerifyEmail, email, `{"verification_link": "secret"}`)
+		dead := models.NewOutboxMessage(models.OutboxKindUserChangePassword, email, "{}")
+		dead.Status = models.OutboxMessageDead
+		dead.Attempts = 5
+		dead.LastError = "unexpected status 502"
+
+		cursor := helpers.NewCursor(0, 10)
+		messagesSerializer := NewPaginatedOutboxMessagesSerializer([]models.OutboxMessage{pending, dead}, cursor)
+
+		assert.Equal("outbox-message", messagesSerializer.ObjectType)
+		assert.Equal(2, len(messagesSerializer.Data))
+		assert.Equal(models.OutboxKindUserVerifyEmail, messagesSerializer.Data[0].Kind)
+		assert.Equal(email, messagesSerializer.Data[0].Recipient)
+		assert.Equal(models.OutboxMessageDead, messagesSerializer.Data[1].Status)
+		assert.Equal(5, messagesSerializer.Data[1].Attempts)
+		assert.Equal("unexpected status 502", messagesSerializer.Data[1].LastError)
+	})
+}
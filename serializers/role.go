@@ -0,0 +1,58 @@
+package serializers
+
+import (
+	"gandalf/models"
+
+	"github.com/gofrs/uuid"
+)
+
+type roleDataSerializer struct {
+	UUID        uuid.UUID `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Name        string    `json:"name" example:"staff"`
+	Description string    `json:"description" example:"Manages users and apps"`
+	Scopes      []string  `json:"scopes" example:"user:all:read"`
+}
+
+// Role serialization struct
+type RoleSerializer struct {
+	ObjectType string             `json:"type" example:"role"`
+	Data       roleDataSerializer `json:"data"`
+}
+
+// Roles serialization struct
+type RolesSerializer struct {
+	ObjectType string               `json:"type" example:"role"`
+	Data       []roleDataSerializer `json:"data"`
+}
+
+func newRoleDataSerializer(role models.Role) roleDataSerializer {
+	return roleDataSerializer{
+		UUID:        role.UUID,
+		Name:        role.Name,
+		Description: role.Description,
+		Scopes:      role.Scopes(),
+	}
+}
+
+// Creates a new role serializer and fills it with
+// the given role data.
+func NewRoleSerializer(role models.Role) RoleSerializer {
+	return RoleSerializer{
+		ObjectType: "role",
+		Data:       newRoleDataSerializer(role),
+	}
+}
+
+// Creates a new roles serializer and fills it with
+// the given roles data.
+func NewRolesSerializer(roles []models.Role) RolesSerializer {
+	serializedRoles := []roleDataSerializer{}
+	for _, role := range roles {
+		serializedRoles = append(serializedRoles, newRoleDataSerializer(role))
+	}
+
+	return RolesSerializer{
+		ObjectType: "role",
+		Data:       serializedRoles,
+	}
+}
@@ -0,0 +1,35 @@
+package serializers
+
+import (
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/tests"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		role := tests.RoleFactory(security.ScopeUserRead, security.ScopeAppRead)
+		roleSerializer := NewRoleSerializer(role)
+
+		assert.Equal("role", roleSerializer.ObjectType)
+		assert.Equal(role.Name, roleSerializer.Data.Name)
+		assert.Equal(role.Description, roleSerializer.Data.Description)
+		assert.Equal([]string{security.ScopeUserRead, security.ScopeAppRead}, roleSerializer.Data.Scopes)
+	})
+
+	t.Run("Test serialize batch", func(t *testing.T) {
+		roles := []models.Role{tests.RoleFactory(), tests.RoleFactory()}
+		rolesSerializer := NewRolesSerializer(roles)
+		assert.Equal(len(rolesSerializer.Data), 2)
+	})
+
+	t.Run("Test serialize empty batch", func(t *testing.T) {
+		rolesSerializer := NewRolesSerializer(nil)
+		assert.Equal([]roleDataSerializer{}, rolesSerializer.Data)
+	})
+}
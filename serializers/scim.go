@@ -0,0 +1,283 @@
+package serializers
+
+import (
+	"gandalf/models"
+	"gandalf/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// SCIM schemas
+const (
+	SCIMSchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMSchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCIMSchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMSchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SCIMSchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+type scimMetaSerializer struct {
+	ResourceType string    `json:"resourceType" example:"User"`
+	Created      time.Time `json:"created" example:"2021-10-19T08:00:00Z"`
+	LastModified time.Time `json:"lastModified" example:"2021-10-19T08:00:00Z"`
+	Location     string    `json:"location" example:"https://gandalf.example.com/scim/v2/Users/4722679b-5a48-4e85-9084-605e8df610f4"`
+}
+
+type scimNameSerializer struct {
+	GivenName  string `json:"givenName" example:"John"`
+	FamilyName string `json:"familyName" example:"Doe"`
+	Formatted  string `json:"formatted" example:"John Doe"`
+}
+
+type scimValueSerializer struct {
+	Value   string `json:"value" example:"johndoe@example.com"`
+	Display string `json:"display,omitempty" example:"John Doe"`
+	Type    string `json:"type,omitempty" example:"work"`
+	Primary bool   `json:"primary,omitempty" example:"true"`
+	Ref     string `json:"$ref,omitempty" example:"https://gandalf.example.com/scim/v2/Groups/4722679b-5a48-4e85-9084-605e8df610f4"`
+}
+
+// SCIM user serialization struct
+type SCIMUserSerializer struct {
+	Schemas    []string              `json:"schemas" example:"urn:ietf:params:scim:schemas:core:2.0:User"`
+	ID         uuid.UUID             `json:"id" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	ExternalID string                `json:"externalId,omitempty" example:"00u1a2b3c4"`
+	UserName   string                `json:"userName" example:"johndoe@example.com"`
+	Name       scimNameSerializer    `json:"name"`
+	Emails     []scimValueSerializer `json:"emails"`
+	Locale     string                `json:"locale" example:"es-ES"`
+	Active     bool                  `json:"active" example:"true"`
+	Groups     []scimValueSerializer `json:"groups"`
+	Meta       scimMetaSerializer    `json:"meta"`
+}
+
+// SCIM group serialization struct
+type SCIMGroupSerializer struct {
+	Schemas     []string              `json:"schemas" example:"urn:ietf:params:scim:schemas:core:2.0:Group"`
+	ID          uuid.UUID             `json:"id" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	DisplayName string                `json:"displayName" example:"engineering"`
+	Members     []scimValueSerializer `json:"members,omitempty"`
+	Meta        scimMetaSerializer    `json:"meta"`
+}
+
+// SCIM list serialization struct. Its resources are users or groups.
+type SCIMListSerializer struct {
+	Schemas      []string    `json:"schemas" example:"urn:ietf:params:scim:api:messages:2.0:ListResponse"`
+	TotalResults int64       `json:"totalResults" example:"1"`
+	StartIndex   int         `json:"startIndex" example:"1"`
+	ItemsPerPage int         `json:"itemsPerPage" example:"1"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// SCIM error serialization struct. The status is a string as SCIM requires.
+type SCIMErrorSerializer struct {
+	Schemas  []string `json:"schemas" example:"urn:ietf:params:scim:api:messages:2.0:Error"`
+	Status   string   `json:"status" example:"400"`
+	SCIMType string   `json:"scimType,omitempty" example:"invalidFilter"`
+	Detail   string   `json:"detail" example:"SCIM filter is not valid"`
+}
+
+type scimSupportedSerializer struct {
+	Supported bool `json:"supported" example:"true"`
+}
+
+type scimBulkSerializer struct {
+	Supported      bool `json:"supported" example:"false"`
+	MaxOperations  int  `json:"maxOperations" example:"0"`
+	MaxPayloadSize int  `json:"maxPayloadSize" example:"0"`
+}
+
+type scimFilterSerializer struct {
+	Supported  bool `json:"supported" example:"true"`
+	MaxResults int  `json:"maxResults" example:"200"`
+}
+
+type scimAuthenticationSchemeSerializer struct {
+	Type        string `json:"type" example:"oauthbearertoken"`
+	Name        string `json:"name" example:"Bearer token"`
+	Description string `json:"description" example:"Authentication with a SCIM token"`
+}
+
+// SCIM service provider configuration serialization struct
+type SCIMServiceProviderConfigSerializer struct {
+	Schemas               []string                             `json:"schemas" example:"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"`
+	Patch                 scimSupportedSerializer              `json:"patch"`
+	Bulk                  scimBulkSerializer                   `json:"bulk"`
+	Filter                scimFilterSerializer                 `json:"filter"`
+	ChangePassword        scimSupportedSerializer              `json:"changePassword"`
+	Sort                  scimSupportedSerializer              `json:"sort"`
+	ETag                  scimSupportedSerializer              `json:"etag"`
+	AuthenticationSchemes []scimAuthenticationSchemeSerializer `json:"authenticationSchemes"`
+}
+
+// Creates a new SCIM user serializer and fills it with the given user data.
+// The given base url is the one the SCIM resources are located under.
+func NewSCIMUserSerializer(user models.User, baseURL string) SCIMUserSerializer {
+	groups := []scimValueSerializer{}
+	for _, role := range user.Roles {
+		groups = append(groups, scimValueSerializer{
+			Value:   role.UUID.String(),
+			Display: role.Name,
+			Ref:     baseURL + "/Groups/" + role.UUID.String(),
+		})
+	}
+
+	return SCIMUserSerializer{
+		Schemas:    []string{SCIMSchemaUser},
+		ID:         user.UUID,
+		ExternalID: user.ExternalID,
+		UserName:   user.Email,
+		Name: scimNameSerializer{
+			GivenName:  user.Name,
+			FamilyName: user.Surname,
+			Formatted:  strings.TrimSpace(user.Name + " " + user.Surname),
+		},
+		Emails: []scimValueSerializer{{Value: user.Email, Type: "work", Primary: true}},
+		Locale: user.Locale,
+		Active: !user.Disabled,
+		Groups: groups,
+		Meta: scimMetaSerializer{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     baseURL + "/Users/" + user.UUID.String(),
+		},
+	}
+}
+
+// Creates a new SCIM group serializer and fills it with the given group data
+func NewSCIMGroupSerializer(group services.SCIMGroup, baseURL string) SCIMGroupSerializer {
+	var members []scimValueSerializer
+	for _, user := range group.Members {
+		members = append(members, scimValueSerializer{
+			Value:   user.UUID.String(),
+			Display: user.Email,
+			Ref:     baseURL + "/Users/" + user.UUID.String(),
+		})
+	}
+
+	return SCIMGroupSerializer{
+		Schemas:     []string{SCIMSchemaGroup},
+		ID:          group.Role.UUID,
+		DisplayName: group.Role.Name,
+		Members:     members,
+		Meta: scimMetaSerializer{
+			ResourceType: "Group",
+			Created:      group.Role.CreatedAt,
+			LastModified: group.Role.UpdatedAt,
+			Location:     baseURL + "/Groups/" + group.Role.UUID.String(),
+		},
+	}
+}
+
+// Creates a new SCIM list serializer for the given page of users
+func NewSCIMUsersSerializer(users []models.User, total int64, startIndex int, baseURL string) SCIMListSerializer {
+	resources := []SCIMUserSerializer{}
+	for _, user := range users {
+		resources = append(resources, NewSCIMUserSerializer(user, baseURL))
+	}
+	return newSCIMListSerializer(resources, len(resources), total, startIndex)
+}
+
+// Creates a new SCIM list serializer for the given page of groups
+func NewSCIMGroupsSerializer(groups []services.SCIMGroup, total int64, startIndex int, baseURL string) SCIMListSerializer {
+	resources := []SCIMGroupSerializer{}
+	for _, group := range groups {
+		resources = append(resources, NewSCIMGroupSerializer(group, baseURL))
+	}
+	return newSCIMListSerializer(resources, len(resources), total, startIndex)
+}
+
+func newSCIMListSerializer(resources interface{}, count int, total int64, startIndex int) SCIMListSerializer {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	return SCIMListSerializer{
+		Schemas:      []string{SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: count,
+		Resources:    resources,
+	}
+}
+
+// Creates a new SCIM error serializer for the given status, SCIM error type
+// and error
+func NewSCIMErrorSerializer(status int, scimType string, err error) SCIMErrorSerializer {
+	return SCIMErrorSerializer{
+		Schemas:  []string{SCIMSchemaError},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   err.Error(),
+	}
+}
+
+// Creates the SCIM service provider configuration serializer, which tells
+// the directories the features gandalf supports
+func NewSCIMServiceProviderConfigSerializer(maxResults int) SCIMServiceProviderConfigSerializer {
+	return SCIMServiceProviderConfigSerializer{
+		Schemas: []string{SCIMSchemaServiceProviderConfig},
+		Patch:   scimSupportedSerializer{true},
+		Filter:  scimFilterSerializer{Supported: true, MaxResults: maxResults},
+		AuthenticationSchemes: []scimAuthenticationSchemeSerializer{{
+			Type:        "oauthbearertoken",
+			Name:        "Bearer token",
+			Description: "Authentication with a SCIM token issued through the admin API",
+		}},
+	}
+}
+
+type scimTokenDataSerializer struct {
+	UUID       uuid.UUID  `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Name       string     `json:"name" example:"Workday"`
+	Token      string     `json:"token,omitempty" example:"hG3k0-aPq9Lm2xZ7"`
+	LastUsedAt *time.Time `json:"last_used_at" example:"2021-10-19T08:00:00Z"`
+	CreatedAt  time.Time  `json:"created_at" example:"2021-10-19T08:00:00Z"`
+}
+
+// SCIM token serialization struct. The token secret is only serialized
+// when it has just been created.
+type SCIMTokenSerializer struct {
+	ObjectType string                  `json:"type" example:"scim-token"`
+	Data       scimTokenDataSerializer `json:"data"`
+}
+
+// SCIM tokens serialization struct
+type SCIMTokensSerializer struct {
+	ObjectType string                    `json:"type" example:"scim-token"`
+	Data       []scimTokenDataSerializer `json:"data"`
+}
+
+func newSCIMTokenDataSerializer(token models.SCIMToken) scimTokenDataSerializer {
+	return scimTokenDataSerializer{
+		UUID:       token.UUID,
+		Name:       token.Name,
+		Token:      token.Secret(),
+		LastUsedAt: token.LastUsedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
+
+// Creates a new SCIM token serializer and fills it with the given token data
+func NewSCIMTokenSerializer(token models.SCIMToken) SCIMTokenSerializer {
+	return SCIMTokenSerializer{
+		ObjectType: "scim-token",
+		Data:       newSCIMTokenDataSerializer(token),
+	}
+}
+
+// Creates a new SCIM tokens serializer and fills it with the given tokens data
+func NewSCIMTokensSerializer(tokens []models.SCIMToken) SCIMTokensSerializer {
+	serializedTokens := []scimTokenDataSerializer{}
+	for _, token := range tokens {
+		serializedTokens = append(serializedTokens, newSCIMTokenDataSerializer(token))
+	}
+
+	return SCIMTokensSerializer{
+		ObjectType: "scim-token",
+		Data:       serializedTokens,
+	}
+}
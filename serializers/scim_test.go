@@ -0,0 +1,76 @@
+package serializers
+
+import (
+	"encoding/json"
+	"errors"
+	"gandalf/models"
+	"gandalf/services"
+	"gandalf/tests"
+	"net/http"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSCIMSerializers(t *testing.T) {
+	assert := require.New(t)
+	baseURL := "https://gandalf.test/scim/v2"
+
+	t.Run("Test user is serialized with its groups", func(t *testing.T) {
+		user := tests.UserFactory()
+		user.UUID = uuid.Must(uuid.NewV4())
+		user.ExternalID = "00u1"
+		user.Disabled = true
+		role := models.NewRole("engineering", "", nil)
+		role.UUID = uuid.Must(uuid.NewV4())
+		user.Roles = []models.Role{role}
+
+		serialized := NewSCIMUserSerializer(user, baseURL)
+
+		assert.Equal([]string{SCIMSchemaUser}, serialized.Schemas)
+		assert.Equal(user.Email, serialized.UserName)
+		assert.Equal(user.Email, serialized.Emails[0].Value)
+		assert.Equal("00u1", serialized.ExternalID)
+		assert.False(serialized.Active)
+		assert.Equal("engineering", serialized.Groups[0].Display)
+		assert.Equal(baseURL+"/Users/"+user.UUID.String(), serialized.Meta.Location)
+	})
+
+	t.Run("Test group is serialized with its members", func(t *testing.T) {
+		user := tests.UserFactory()
+		user.UUID = uuid.Must(uuid.NewV4())
+		group := services.SCIMGroup{Role: models.NewRole("engineering", "", nil), Members: []models.User{user}}
+
+		serialized := NewSCIMGroupSerializer(group, baseURL)
+
+		assert.Equal("engineering", serialized.DisplayName)
+		assert.Equal(user.UUID.String(), serialized.Members[0].Value)
+		assert.Equal("Group", serialized.Meta.ResourceType)
+	})
+
+	t.Run("Test list response format", func(t *testing.T) {
+		body, _ := json.Marshal(NewSCIMUsersSerializer(nil, 42, 0, baseURL))
+
+		assert.JSONEq(`{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:ListResponse"],
+			"totalResults": 42, "startIndex": 1, "itemsPerPage": 0, "Resources": []
+		}`, string(body))
+	})
+
+	t.Run("Test error status is a string", func(t *testing.T) {
+		serialized := NewSCIMErrorSerializer(http.StatusBadRequest, "invalidFilter", errors.New("Whoops"))
+
+		assert.Equal("400", serialized.Status)
+		assert.Equal("invalidFilter", serialized.SCIMType)
+		assert.Equal("Whoops", serialized.Detail)
+	})
+
+	t.Run("Test token secret is only serialized when created", func(t *testing.T) {
+		token := models.NewSCIMToken("Workday")
+
+		assert.Equal(token.Secret(), NewSCIMTokenSerializer(token).Data.Token)
+		body, _ := json.Marshal(NewSCIMTokensSerializer([]models.SCIMToken{{Name: "Workday"}}))
+		assert.NotContains(string(body), `"token"`)
+	})
+}
@@ -0,0 +1,59 @@
+package serializers
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type sessionDataSerializer struct {
+	UUID       uuid.UUID                `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	UserAgent  string                   `json:"user_agent" example:"Mozilla/5.0 (X11; Linux x86_64)"`
+	IP         string                   `json:"ip" example:"127.0.0.1"`
+	CreatedAt  time.Time                `json:"created_at" example:"2021-10-19T08:00:00Z"`
+	LastSeenAt time.Time                `json:"last_seen_at" example:"2021-10-19T08:00:00Z"`
+	Current    bool                     `json:"current" example:"true"`
+	App        *appPublicDataSerializer `json:"app"`
+}
+
+type paginatedSessionsSerializerMeta struct {
+	Cursor CursorSerializer `json:"cursor"`
+}
+
+// Sessions serialization struct
+type PaginatedSessionsSerializer struct {
+	ObjectType string                          `json:"type" example:"session"`
+	Data       []sessionDataSerializer         `json:"data"`
+	Meta       paginatedSessionsSerializerMeta `json:"meta"`
+}
+
+// Creates a new sessions serializer and fills it with the given sessions
+// data. The session with the given uuid is marked as the current one.
+func NewPaginatedSessionsSerializer(sessions []models.Session, current uuid.UUID, cursor helpers.Cursor) PaginatedSessionsSerializer {
+	var serializedSessions []sessionDataSerializer
+	for _, session := range sessions {
+		serializedSession := sessionDataSerializer{
+			UUID:       session.UUID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.UUID == current,
+		}
+		if session.App != nil {
+			app := NewAppPublicSerializer(*session.App).Data
+			serializedSession.App = &app
+		}
+		serializedSessions = append(serializedSessions, serializedSession)
+	}
+
+	return PaginatedSessionsSerializer{
+		ObjectType: "session",
+		Data:       serializedSessions,
+		Meta: paginatedSessionsSerializerMeta{
+			Cursor: NewCursorSerializer(cursor),
+		},
+	}
+}
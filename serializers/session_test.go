@@ -0,0 +1,35 @@
+package serializers
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestSessionSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test serialize batch", func(t *testing.T) {
+		app := models.App{Name: faker.Company().Name()}
+		current := models.NewSession(models.User{}, nil, "agent", "127.0.0.1")
+		current.UUID, _ = uuid.NewV4()
+		other := models.NewSession(models.User{}, &app, "other", "127.0.0.2")
+		other.UUID, _ = uuid.NewV4()
+		other.App = &app
+
+		cursor := helpers.NewCursor(1, 10)
+		sessionsSerializer := NewPaginatedSessionsSerializer([]models.Session{current, other}, current.UUID, cursor)
+
+		assert.Equal("session", sessionsSerializer.ObjectType)
+		assert.Equal(2, len(sessionsSerializer.Data))
+		assert.True(sessionsSerializer.Data[0].Current)
+		assert.Nil(sessionsSerializer.Data[0].App)
+		assert.Equal("agent", sessionsSerializer.Data[0].UserAgent)
+		assert.False(sessionsSerializer.Data[1].Current)
+		assert.Equal(app.Name, sessionsSerializer.Data[1].App.Name)
+	})
+}
@@ -2,7 +2,9 @@ package serializers
 
 import (
 	"gandalf/bindings"
+	"gandalf/helpers"
 	"gandalf/models"
+	"time"
 
 	"github.com/gofrs/uuid"
 )
@@ -14,6 +16,15 @@ type userDataSerializer struct {
 	Surname  string             `json:"surname" example:"Doe"`
 	Birthday bindings.BirthDate `json:"birthday" example:"1997-12-21"`
 	Phone    string             `json:"phone" example:"+34666123456"`
+	Locale   string             `json:"locale" example:"es-ES"`
+
+	PhoneVerified bool `json:"phone_verified" example:"true"`
+	PhoneMFA      bool `json:"phone_mfa" example:"false"`
+
+	AppAuthorizedAlerts bool `json:"app_authorized_alerts" example:"true"`
+	NewDeviceAlerts     bool `json:"new_device_alerts" example:"true"`
+
+	Attributes map[string]interface{} `json:"attributes"`
 }
 
 // User serialization struct
@@ -34,6 +45,88 @@ func NewUserSerializer(user models.User) UserSerializer {
 			Surname:  user.Surname,
 			Birthday: user.Birthday,
 			Phone:    user.Phone,
+			Locale:   user.Locale,
+
+			PhoneVerified: user.PhoneVerified,
+			PhoneMFA:      user.PhoneMFA,
+
+			AppAuthorizedAlerts: user.AppAuthorizedAlerts,
+			NewDeviceAlerts:     user.NewDeviceAlerts,
+
+			Attributes: newUserAttributesSerializer(user.Attributes),
+		},
+	}
+}
+
+// Copies the custom attributes of an user, so users without any of them
+// are serialized with an empty object
+func newUserAttributesSerializer(attributes models.UserAttributes) map[string]interface{} {
+	serialized := map[string]interface{}{}
+	for name, value := range attributes {
+		serialized[name] = value
+	}
+	return serialized
+}
+
+type adminUserDataSerializer struct {
+	userDataSerializer
+	Verified  bool      `json:"verified" example:"true"`
+	Staff     bool      `json:"staff" example:"false"`
+	Disabled  bool      `json:"disabled" example:"false"`
+	LastLogin time.Time `json:"last_login" example:"2021-10-19T08:00:00Z"`
+	CreatedAt time.Time `json:"created_at" example:"2021-10-19T08:00:00Z"`
+}
+
+// User serialization struct for the admin api
+type AdminUserSerializer struct {
+	ObjectType string                  `json:"type" example:"user"`
+	Data       adminUserDataSerializer `json:"data"`
+}
+
+type paginatedUsersSerializerMeta struct {
+	Cursor CursorSerializer `json:"cursor"`
+}
+
+// Paginated users serialization struct for the admin api
+type PaginatedAdminUsersSerializer struct {
+	ObjectType string                       `json:"type" example:"user"`
+	Data       []adminUserDataSerializer    `json:"data"`
+	Meta       paginatedUsersSerializerMeta `json:"meta"`
+}
+
+func newAdminUserDataSerializer(user models.User) adminUserDataSerializer {
+	return adminUserDataSerializer{
+		userDataSerializer: NewUserSerializer(user).Data,
+		Verified:           user.Verified,
+		Staff:              user.Staff,
+		Disabled:           user.Disabled,
+		LastLogin:          user.LastLogin,
+		CreatedAt:          user.CreatedAt,
+	}
+}
+
+// Creates a new admin user serializer and fills it with
+// the given user data.
+func NewAdminUserSerializer(user models.User) AdminUserSerializer {
+	return AdminUserSerializer{
+		ObjectType: "user",
+		Data:       newAdminUserDataSerializer(user),
+	}
+}
+
+// Creates a new paginated admin users serializer and fills it with
+// the given users data.
+func NewPaginatedAdminUsersSerializer(users []models.User, cursor helpers.Cursor) PaginatedAdminUsersSerializer {
+	var serializedUsers []adminUserDataSerializer
+	for _, user := range users {
+		serializedUsers = append(serializedUsers, newAdminUserDataSerializer(user))
+	}
+
+	return PaginatedAdminUsersSerializer{
+		ObjectType: "user",
+		Data:       serializedUsers,
+		Meta: paginatedUsersSerializerMeta{
+			Cursor: NewCursorSerializer(cursor),
 		},
 	}
 }
@@ -1,6 +1,8 @@
 package serializers
 
 import (
+	"gandalf/helpers"
+	"gandalf/models"
 	"gandalf/tests"
 	"testing"
 
@@ -19,5 +21,37 @@ func TestUserSerializer(t *testing.T) {
 		assert.Equal(userSerializer.Data.Surname, user.Surname)
 		assert.Equal(userSerializer.Data.Birthday, user.Birthday)
 		assert.Equal(userSerializer.Data.Phone, user.Phone)
+		assert.Equal(userSerializer.Data.Locale, user.Locale)
+		assert.Equal(userSerializer.Data.PhoneVerified, user.PhoneVerified)
+		assert.Equal(userSerializer.Data.PhoneMFA, user.PhoneMFA)
+		assert.Equal(userSerializer.Data.AppAuthorizedAlerts, user.AppAuthorizedAlerts)
+		assert.Equal(userSerializer.Data.NewDeviceAlerts, user.NewDeviceAlerts)
+	})
+}
+
+func TestAdminUserSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		user := tests.UserFactory()
+		user.Staff = true
+		user.Disabled = true
+		userSerializer := NewAdminUserSerializer(user)
+
+		assert.Equal(userSerializer.Data.Email, user.Email)
+		assert.Equal(userSerializer.Data.Verified, user.Verified)
+		assert.True(userSerializer.Data.Staff)
+		assert.True(userSerializer.Data.Disabled)
+	})
+
+	t.Run("Test paginated constructor", func(t *testing.T) {
+		users := []models.User{tests.UserFactory(), tests.UserFactory()}
+		cursor := helpers.NewCursor(0, 5)
+		cursor.Update(len(users))
+		usersSerializer := NewPaginatedAdminUsersSerializer(users, cursor)
+
+		assert.Equal(2, len(usersSerializer.Data))
+		assert.Equal(users[1].Email, usersSerializer.Data[1].Email)
+		assert.Equal(2, usersSerializer.Meta.Cursor.Data.TotalObjects)
 	})
 }
@@ -0,0 +1,134 @@
+package serializers
+
+import (
+	"encoding/json"
+	"gandalf/helpers"
+	"gandalf/models"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type webhookDataSerializer struct {
+	UUID      uuid.UUID `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Url       string    `json:"url" example:"https://yourapp.dev/webhooks"`
+	Secret    string    `json:"secret" example:"iuhgf3874tiu34gtwerbguv3iu74"`
+	Events    []string  `json:"events" example:"user.deleted"`
+	Active    bool      `json:"active" example:"true"`
+	CreatedAt time.Time `json:"created_at" example:"2021-10-19T08:00:00Z"`
+}
+
+// Webhook serialization struct
+type WebhookSerializer struct {
+	ObjectType string                `json:"type" example:"webhook"`
+	Data       webhookDataSerializer `json:"data"`
+}
+
+// Creates a new webhook serializer and fills it with the given webhook data
+func NewWebhookSerializer(webhook models.Webhook) WebhookSerializer {
+	return WebhookSerializer{
+		ObjectType: "webhook",
+		Data: webhookDataSerializer{
+			UUID:      webhook.UUID,
+			Url:       webhook.Url,
+			Secret:    webhook.Secret,
+			Events:    webhook.Events,
+			Active:    webhook.Active,
+			CreatedAt: webhook.CreatedAt,
+		},
+	}
+}
+
+type paginatedWebhooksSerializerMeta struct {
+	Cursor CursorSerializer `json:"cursor"`
+}
+
+// Webhooks serialization struct
+type PaginatedWebhooksSerializer struct {
+	ObjectType string                          `json:"type" example:"webhook"`
+	Data       []webhookDataSerializer         `json:"data"`
+	Meta       paginatedWebhooksSerializerMeta `json:"meta"`
+}
+
+// Creates a new webhooks serializer and fills it with the given webhooks data
+func NewPaginatedWebhooksSerializer(webhooks []models.Webhook, cursor helpers.Cursor) PaginatedWebhooksSerializer {
+	var serializedWebhooks []webhookDataSerializer
+	for _, webhook := range webhooks {
+		serializedWebhooks = append(serializedWebhooks, NewWebhookSerializer(webhook).Data)
+	}
+
+	return PaginatedWebhooksSerializer{
+		ObjectType: "webhook",
+		Data:       serializedWebhooks,
+		Meta: paginatedWebhooksSerializerMeta{
+			Cursor: NewCursorSerializer(cursor),
+		},
+	}
+}
+
+type webhookDeliveryDataSerializer struct {
+	UUID          uuid.UUID       `json:"uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Event         string          `json:"event" example:"user.deleted"`
+	Status        string          `json:"status" example:"delivered"`
+	Payload       json.RawMessage `json:"payload" swaggertype:"object"`
+	Attempts      int             `json:"attempts" example:"1"`
+	LastStatus    int             `json:"last_status" example:"200"`
+	LastError     string          `json:"last_error" example:""`
+	NextAttemptAt *time.Time      `json:"next_attempt_at" example:"2021-10-19T08:00:00Z"`
+	DeliveredAt   *time.Time      `json:"delivered_at" example:"2021-10-19T08:00:00Z"`
+	CreatedAt     time.Time       `json:"created_at" example:"2021-10-19T08:00:00Z"`
+}
+
+// Webhook delivery serialization struct
+type WebhookDeliverySerializer struct {
+	ObjectType string                        `json:"type" example:"webhook-delivery"`
+	Data       webhookDeliveryDataSerializer `json:"data"`
+}
+
+// Creates a new webhook delivery serializer and fills it with the given
+// delivery data
+func NewWebhookDeliverySerializer(delivery models.WebhookDelivery) WebhookDeliverySerializer {
+	return WebhookDeliverySerializer{
+		ObjectType: "webhook-delivery",
+		Data: webhookDeliveryDataSerializer{
+			UUID:          delivery.UUID,
+			Event:         delivery.Event,
+			Status:        delivery.Status,
+			Payload:       json.RawMessage(delivery.Payload),
+			Attempts:      delivery.Attempts,
+			LastStatus:    delivery.LastStatus,
+			LastError:     delivery.LastError,
+			NextAttemptAt: delivery.NextAttemptAt,
+			DeliveredAt:   delivery.DeliveredAt,
+			CreatedAt:     delivery.CreatedAt,
+		},
+	}
+}
+
+type paginatedWebhookDeliveriesSerializerMeta struct {
+	Cursor CursorSerializer `json:"cursor"`
+}
+
+// Webhook deliveries serialization struct
+type PaginatedWebhookDeliveriesSerializer struct {
+	ObjectType string                                   `json:"type" example:"webhook-delivery"`
+	Data       []webhookDeliveryDataSerializer          `json:"data"`
+	Meta       paginatedWebhookDeliveriesSerializerMeta `json:"meta"`
+}
+
+// Creates a new webhook deliveries serializer and fills it with the given
+// deliveries data
+func NewPaginatedWebhookDeliveriesSerializer(deliveries []models.WebhookDelivery, cursor helpers.Cursor) PaginatedWebhookDeliveriesSerializer {
+	var serializedDeliveries []webhookDeliveryDataSerializer
+	for _, delivery := range deliveries {
+		serializedDeliveries = append(serializedDeliveries, NewWebhookDeliverySerializer(delivery).Data)
+	}
+
+	return PaginatedWebhookDeliveriesSerializer{
+		ObjectType: "webhook-delivery",
+		Data:       serializedDeliveries,
+		Meta: paginatedWebhookDeliveriesSerializerMeta{
+			Cursor: NewCursorSerializer(cursor),
+		},
+	}
+}
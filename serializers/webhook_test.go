@@ -0,0 +1,37 @@
+package serializers
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestWebhookSerializer(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test serialize webhooks", func(t *testing.T) {
+		webhook := models.NewWebhook(models.App{}, faker.Internet().Url(), []string{models.WebhookEventUserDeleted})
+
+		cursor := helpers.NewCursor(0, 10)
+		webhooksSerializer := NewPaginatedWebhooksSerializer([]models.Webhook{webhook}, cursor)
+
+		assert.Equal("webhook", webhooksSerializer.ObjectType)
+		assert.Equal(webhook.Url, webhooksSerializer.Data[0].Url)
+		assert.Equal(webhook.Secret, webhooksSerializer.Data[0].Secret)
+		assert.Equal([]string{models.WebhookEventUserDeleted}, webhooksSerializer.Data[0].Events)
+	})
+
+	t.Run("Test serialize deliveries", func(t *testing.T) {
+		delivery := models.NewWebhookDelivery(models.Webhook{}, models.WebhookEventUserDeleted, `{"event":"user.deleted"}`)
+
+		cursor := helpers.NewCursor(0, 10)
+		deliveriesSerializer := NewPaginatedWebhookDeliveriesSerializer([]models.WebhookDelivery{delivery}, cursor)
+
+		assert.Equal("webhook-delivery", deliveriesSerializer.ObjectType)
+		assert.Equal(models.WebhookDeliveryPending, deliveriesSerializer.Data[0].Status)
+		assert.JSONEq(`{"event":"user.deleted"}`, string(deliveriesSerializer.Data[0].Payload))
+	})
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"gandalf/models"
+
+	"gorm.io/gorm"
+)
+
+// Interface for admin action service
+type IAdminActionService interface {
+	Record(action string, staff models.User, target *models.User, detail string, ip string) error
+}
+
+// Admin action service keeps track of the operations performed by the
+// staff users
+type AdminActionService struct {
+	db *gorm.DB
+}
+
+// Creates a new admin action service
+func NewAdminActionService(db *gorm.DB) AdminActionService {
+	return AdminActionService{db}
+}
+
+// Records the given action performed by the given staff user
+func (service AdminActionService) Record(action string, staff models.User, target *models.User, detail string, ip string) error {
+	return recordAdminAction(service.db, &AdminActionContext{action, staff, detail, ip}, target)
+}
+
+// Action performed by a staff user, recorded along with the change it
+// describes so both are committed or rolled back together
+type AdminActionContext struct {
+	Action string
+	Staff  models.User
+	Detail string
+	IP     string
+}
+
+// Records the given admin action about the given target through the given
+// db, which should be the transaction that performs the change. Nothing is
+// recorded when no action is given, like when users make the change
+// themselves.
+func recordAdminAction(db *gorm.DB, admin *AdminActionContext, target *models.User) error {
+	if admin == nil {
+		return nil
+	}
+	adminAction := models.NewAdminAction(admin.Action, admin.Staff, target, admin.Detail, admin.IP)
+	if err := db.Create(&adminAction).Error; err != nil {
+		return AdminActionRecordError{err}
+	}
+	return nil
+}
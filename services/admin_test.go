@@ -0,0 +1,47 @@
+package services
+
+import (
+	"gandalf/models"
+	"gandalf/tests"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminActionServiceConstructor(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		db := tests.NewTestDatabase(true)
+		service := NewAdminActionService(db)
+
+		assert.Equal(service.db, db)
+	})
+}
+
+func TestAdminActionServiceRecord(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test record action successfully", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := AdminActionService{db}
+		staff := tests.UserFactory()
+		staff.Staff = true
+		target := tests.UserFactory()
+		db.Create(&staff)
+		db.Create(&target)
+
+		err := service.Record(models.AdminActionDisableUser, staff, &target, "", "127.0.0.1")
+
+		var action models.AdminAction
+		db.Where(&models.AdminAction{StaffID: staff.ID}).First(&action)
+
+		assert.NoError(err)
+		assert.Equal(models.AdminActionDisableUser, action.Action)
+		assert.Equal(target.ID, *action.TargetID)
+
+		db.Unscoped().Delete(&action)
+		db.Unscoped().Delete(&staff)
+		db.Unscoped().Delete(&target)
+	})
+}
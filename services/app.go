@@ -1,22 +1,27 @@
 package services
 
 import (
+	"errors"
 	"gandalf/helpers"
 	"gandalf/models"
 	"gandalf/validators"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"gorm.io/gorm"
 )
 
 type IAppService interface {
-	Create(validators.AppCreateData, models.User) (*models.App, error)
+	Create(validators.AppCreateData, models.User, *models.Organization, AuditContext) (*models.App, error)
 	Read(uuid uuid.UUID) (*models.App, error)
 	ReadByClientID(clientID uuid.UUID) (*models.App, error)
-	Update(uuid.UUID, validators.AppUpdateData) (*models.App, error)
-	Delete(uuid uuid.UUID) error
+	Update(uuid.UUID, validators.AppUpdateData, AuditContext) (*models.App, error)
+	Delete(uuid uuid.UUID, audit AuditContext) error
 	ListApps(models.User, *helpers.Cursor) []models.App
 	ListConnectedApps(models.User, *helpers.Cursor) []models.App
+	Transfer(*models.App, models.Organization, AuditContext) error
+	Disconnect(models.App, models.User, AuditContext) error
+	AccessRole(models.App, models.User) string
 }
 
 // App services helps you to manage the app model with the database
@@ -29,23 +34,85 @@ func NewAppService(db *gorm.DB) AppService {
 	return AppService{db}
 }
 
-// Creates a new app into the database
-func (service AppService) Create(appData validators.AppCreateData, user models.User) (*models.App, error) {
+// Creates a new app into the database. The app will be owned by the given
+// organization if any. The back-channel logout url cannot point to the
+// internal network.
+func (service AppService) Create(appData validators.AppCreateData, user models.User, organization *models.Organization, audit AuditContext) (*models.App, error) {
+	if appData.BackchannelLogoutUrl != "" {
+		if err := validateWebhookUrl(appData.BackchannelLogoutUrl); err != nil {
+			return nil, err
+		}
+	}
 	app := models.NewApp(
 		appData.Name,
 		appData.IconUrl,
 		appData.RedirectUrls,
 		user,
 	)
+	app.PostLogoutRedirectUrls = appData.PostLogoutRedirectUrls
+	app.BackchannelLogoutUrl = appData.BackchannelLogoutUrl
+	app.MinimumAge = appData.MinimumAge
+	if organization != nil {
+		app.OrganizationID = &organization.ID
+	}
+	if appData.Kind == models.AppKindSAML {
+		app.Kind = models.AppKindSAML
+		app.EntityID = appData.EntityID
+		app.SAMLAttributes = appData.SAMLAttributes
+		if appData.NameIDFormat != "" {
+			app.NameIDFormat = appData.NameIDFormat
+		}
+		// SAML responses can only be posted to a registered url
+		if len(app.RedirectUrls) == 0 {
+			return nil, AppCreateError{errors.New("missing assertion consumer service url")}
+		}
+	}
 
-	if err := service.db.Create(&app).Error; err != nil {
-		return nil, AppCreateError{err}
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkEntityID(tx, app); err != nil {
+			return err
+		}
+		mapping, err := buildClaimMapping(tx, appData.ClaimMapping)
+		if err != nil {
+			return err
+		}
+		app.ClaimMapping = mapping
+		if err := tx.Create(&app).Error; err != nil {
+			return AppCreateError{err}
+		}
+		return recordAuditEvent(tx, audit, models.AuditActionCreateApp, models.AuditOutcomeSuccess, nil, appAuditMetadata(app))
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	app.User = user
 	return &app, nil
 }
 
+// Checks that no other SAML app has registered the entity id of the given
+// app, since SAML requests only identify the app by it
+func checkEntityID(db *gorm.DB, app models.App) error {
+	if !app.IsSAML() {
+		return nil
+	}
+
+	var count int64
+	query := db.Model(&models.App{}).Where("kind = ? AND entity_id = ? AND id <> ?", models.AppKindSAML, app.EntityID, app.ID)
+	if err := query.Count(&count).Error; err != nil {
+		return AppCreateError{err}
+	}
+	if count > 0 {
+		return AppEntityIDTakenError{}
+	}
+	return nil
+}
+
+// Metadata which identifies the given app in the audit log
+func appAuditMetadata(app models.App) models.AuditMetadata {
+	return models.AuditMetadata{"app": app.UUID.String(), "client_id": app.ClientID.String()}
+}
+
 // Read an app from database by his Client ID
 func (service AppService) ReadByClientID(clientID uuid.UUID) (*models.App, error) {
 	var app models.App
@@ -66,7 +133,7 @@ func (service AppService) Read(uuid uuid.UUID) (*models.App, error) {
 
 // Updates the user which belongs to the given ID according to
 // the given user data
-func (service AppService) Update(uuid uuid.UUID, appData validators.AppUpdateData) (*models.App, error) {
+func (service AppService) Update(uuid uuid.UUID, appData validators.AppUpdateData, audit AuditContext) (*models.App, error) {
 	app, err := service.Read(uuid)
 	if err != nil {
 		return nil, err
@@ -84,26 +151,84 @@ func (service AppService) Update(uuid uuid.UUID, appData validators.AppUpdateDat
 		app.RedirectUrls = appData.RedirectUrls
 	}
 
-	service.db.Save(app)
+	if len(appData.PostLogoutRedirectUrls) != 0 {
+		app.PostLogoutRedirectUrls = appData.PostLogoutRedirectUrls
+	}
+
+	if appData.BackchannelLogoutUrl != "" {
+		if err := validateWebhookUrl(appData.BackchannelLogoutUrl); err != nil {
+			return nil, err
+		}
+		app.BackchannelLogoutUrl = appData.BackchannelLogoutUrl
+	}
+
+	if appData.MinimumAge != nil {
+		app.MinimumAge = *appData.MinimumAge
+	}
+
+	if app.IsSAML() {
+		if appData.EntityID != "" {
+			app.EntityID = appData.EntityID
+		}
+		if appData.NameIDFormat != "" {
+			app.NameIDFormat = appData.NameIDFormat
+		}
+		if appData.SAMLAttributes != nil {
+			app.SAMLAttributes = appData.SAMLAttributes
+		}
+	}
+
+	err = service.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkEntityID(tx, *app); err != nil {
+			return err
+		}
+		if appData.ClaimMapping != nil {
+			mapping, err := buildClaimMapping(tx, appData.ClaimMapping)
+			if err != nil {
+				return err
+			}
+			app.ClaimMapping = mapping
+		}
+		if err := tx.Save(app).Error; err != nil {
+			return AppNotFoundError{err}
+		}
+		return recordAuditEvent(tx, audit, models.AuditActionUpdateApp, models.AuditOutcomeSuccess, nil, appAuditMetadata(*app))
+	})
+	if err != nil {
+		return nil, err
+	}
 	return app, nil
 }
 
 // Set the field `deletes_at` of the app but it will still alive
 // in database. Soft deleted apps will not appear as result of any query that
 // not includes `unscoped`
-func (service AppService) Delete(uuid uuid.UUID) error {
-	if err := service.db.Where(&models.App{UUID: uuid}).Delete(&models.App{}).Error; err != nil {
-		return AppNotFoundError{err}
-	}
-	return nil
+func (service AppService) Delete(uuid uuid.UUID, audit AuditContext) error {
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		var app models.App
+		if err := tx.Where(&models.App{UUID: uuid}).First(&app).Error; err != nil {
+			return AppNotFoundError{err}
+		}
+		if err := tx.Delete(&app).Error; err != nil {
+			return AppNotFoundError{err}
+		}
+		return recordAuditEvent(tx, audit, models.AuditActionDeleteApp, models.AuditOutcomeSuccess, nil, appAuditMetadata(app))
+	})
 }
 
-// List all apps created by the given user
+// List all apps created by the given user and the ones owned by the
+// organizations he is member of
 func (service AppService) ListApps(user models.User, cursor *helpers.Cursor) []models.App {
 	var apps []models.App
 	var count int64
-	service.db.Model(&models.App{}).Where(&models.App{UserID: user.ID}).Count(&count)
-	service.db.Scopes(helpers.DBPaginate(cursor.Page, cursor.PageSize)).Where(&models.App{UserID: user.ID}).Find(&apps)
+
+	managed := func(db *gorm.DB) *gorm.DB {
+		organizations := service.db.Model(&models.Membership{}).Select("organization_id").Where("user_id = ?", user.ID)
+		return db.Where("(user_id = ? AND organization_id IS NULL) OR organization_id IN (?)", user.ID, organizations)
+	}
+
+	service.db.Model(&models.App{}).Scopes(managed).Count(&count)
+	service.db.Scopes(managed, helpers.DBPaginate(cursor.Page, cursor.PageSize)).Order("id").Find(&apps)
 	cursor.Update(int(count))
 	return apps
 }
@@ -116,3 +241,51 @@ func (service AppService) ListConnectedApps(user models.User, cursor *helpers.Cu
 	cursor.Update(int(count))
 	return apps
 }
+
+// Transfers the given app to the given organization
+func (service AppService) Transfer(app *models.App, organization models.Organization, audit AuditContext) error {
+	app.OrganizationID = &organization.ID
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(app).Error; err != nil {
+			return AppNotFoundError{err}
+		}
+		metadata := appAuditMetadata(*app)
+		metadata["organization"] = organization.UUID.String()
+		return recordAuditEvent(tx, audit, models.AuditActionTransferApp, models.AuditOutcomeSuccess, nil, metadata)
+	})
+}
+
+// Disconnects the given user from the given app, so the app is no longer
+// among his connected apps and his sessions on the app are revoked
+func (service AppService) Disconnect(app models.App, user models.User, audit AuditContext) error {
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Association("ConnectedApps").Delete(&app); err != nil {
+			return AppNotFoundError{err}
+		}
+		query := tx.Model(&models.Session{}).Where("user_id = ? AND app_id = ? AND revoked_at IS NULL", user.ID, app.ID)
+		if err := query.Update("revoked_at", time.Now()).Error; err != nil {
+			return SessionNotFoundError{err}
+		}
+		return recordAuditEvent(tx, audit, models.AuditActionDisconnectApp, models.AuditOutcomeSuccess, &user, appAuditMetadata(app))
+	})
+}
+
+// Returns the membership role the given user has over the given app. Apps
+// owned by an organization are managed according to the user membership,
+// and apps without organization are only managed by their creator. An empty
+// role is returned when the user has no access to the app.
+func (service AppService) AccessRole(app models.App, user models.User) string {
+	if app.OrganizationID == nil {
+		if app.UserID == user.ID {
+			return models.MembershipOwner
+		}
+		return ""
+	}
+
+	var membership models.Membership
+	query := &models.Membership{OrganizationID: *app.OrganizationID, UserID: user.ID}
+	if err := service.db.Where(query).First(&membership).Error; err != nil {
+		return ""
+	}
+	return membership.Role
+}
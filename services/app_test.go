@@ -2,6 +2,7 @@ package services
 
 import (
 	"gandalf/helpers"
+	"gandalf/models"
 	"gandalf/tests"
 	"gandalf/validators"
 	"testing"
@@ -41,7 +42,7 @@ func TestAppServiceCreate(t *testing.T) {
 			RedirectUrls: redirectUrls,
 		}
 
-		app, err := service.Create(appData, user)
+		app, err := service.Create(appData, user, nil, AuditContext{})
 
 		assert.NoError(err)
 		assert.Equal(name, app.Name)
@@ -67,10 +68,76 @@ func TestAppServiceCreate(t *testing.T) {
 			RedirectUrls: redirectUrls,
 		}
 
-		_, err := service.Create(appData, user)
+		_, err := service.Create(appData, user, nil, AuditContext{})
 		assert.Error(err, AppCreateError{nil}.Error())
 	})
 
+	t.Run("Test SAML app create successfully", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := AppService{db}
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		appData := validators.AppCreateData{
+			Name:           faker.Company().Name(),
+			RedirectUrls:   []string{"https://app.acme.test/saml/acs"},
+			Kind:           models.AppKindSAML,
+			EntityID:       "https://app.acme.test/saml",
+			SAMLAttributes: map[string]string{"mail": "email"},
+		}
+
+		app, err := service.Create(appData, user, nil, AuditContext{})
+
+		assert.NoError(err)
+		assert.True(app.IsSAML())
+		assert.Equal(models.SAMLNameIDEmail, app.NameIDFormat)
+		stored, _ := service.Read(app.UUID)
+		assert.Equal(models.SAMLAttributeMapping{"mail": "email"}, stored.SAMLAttributes)
+	})
+
+	t.Run("Test SAML app entity ids are unique", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := AppService{db}
+		user := tests.UserFactory()
+		db.Create(&user)
+		appData := validators.AppCreateData{
+			Name:         faker.Company().Name(),
+			RedirectUrls: []string{"https://app.acme.test/saml/acs"},
+			Kind:         models.AppKindSAML,
+			EntityID:     "https://app.acme.test/saml",
+		}
+		service.Create(appData, user, nil, AuditContext{})
+
+		_, err := service.Create(appData, user, nil, AuditContext{})
+
+		assert.IsType(AppEntityIDTakenError{}, err)
+	})
+
+	t.Run("Test SAML app without assertion consumer service url", func(t *testing.T) {
+		service := AppService{nil}
+		appData := validators.AppCreateData{
+			Name:     faker.Company().Name(),
+			Kind:     models.AppKindSAML,
+			EntityID: "https://app.acme.test/saml",
+		}
+
+		_, err := service.Create(appData, tests.UserFactory(), nil, AuditContext{})
+
+		assert.IsType(AppCreateError{}, err)
+	})
+
+	t.Run("Test back-channel logout url of the internal network", func(t *testing.T) {
+		service := AppService{nil}
+		appData := validators.AppCreateData{
+			Name:                 faker.Company().Name(),
+			RedirectUrls:         []string{faker.Internet().Url()},
+			BackchannelLogoutUrl: "http://169.254.169.254/latest/meta-data",
+		}
+
+		_, err := service.Create(appData, tests.UserFactory(), nil, AuditContext{})
+
+		assert.IsType(WebhookUrlError{}, err)
+	})
 }
 
 func TestAppServiceRead(t *testing.T) {
@@ -100,6 +167,17 @@ func TestAppServiceRead(t *testing.T) {
 		assert.Error(err, AppNotFoundError{nil}.Error())
 	})
 
+	t.Run("Test update back-channel logout url of the internal network", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := AppService{db}
+		app := tests.AppFactory()
+		db.Create(&app)
+
+		_, err := service.Update(app.UUID, validators.AppUpdateData{BackchannelLogoutUrl: "http://localhost:8080/logout"}, AuditContext{})
+
+		assert.IsType(WebhookUrlError{}, err)
+		db.Unscoped().Delete(&app.User)
+	})
 }
 
 func TestAppServiceReadByClientID(t *testing.T) {
@@ -129,6 +207,17 @@ func TestAppServiceReadByClientID(t *testing.T) {
 		assert.Error(err, AppNotFoundError{nil}.Error())
 	})
 
+	t.Run("Test update back-channel logout url of the internal network", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := AppService{db}
+		app := tests.AppFactory()
+		db.Create(&app)
+
+		_, err := service.Update(app.UUID, validators.AppUpdateData{BackchannelLogoutUrl: "http://localhost:8080/logout"}, AuditContext{})
+
+		assert.IsType(WebhookUrlError{}, err)
+		db.Unscoped().Delete(&app.User)
+	})
 }
 
 func TestAppServiceUpdate(t *testing.T) {
@@ -151,7 +240,7 @@ func TestAppServiceUpdate(t *testing.T) {
 			RedirectUrls: redirectUrls,
 		}
 
-		updatedApp, err := service.Update(app.UUID, appData)
+		updatedApp, err := service.Update(app.UUID, appData, AuditContext{})
 
 		assert.NoError(err)
 		assert.Equal(name, updatedApp.Name)
@@ -175,11 +264,22 @@ func TestAppServiceUpdate(t *testing.T) {
 			RedirectUrls: redirectUrls,
 		}
 
-		_, err := service.Update(uuid, appData)
+		_, err := service.Update(uuid, appData, AuditContext{})
 
 		assert.Error(err, AppNotFoundError{nil}.Error())
 	})
 
+	t.Run("Test update back-channel logout url of the internal network", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := AppService{db}
+		app := tests.AppFactory()
+		db.Create(&app)
+
+		_, err := service.Update(app.UUID, validators.AppUpdateData{BackchannelLogoutUrl: "http://localhost:8080/logout"}, AuditContext{})
+
+		assert.IsType(WebhookUrlError{}, err)
+		db.Unscoped().Delete(&app.User)
+	})
 }
 
 func TestAppServiceDelete(t *testing.T) {
@@ -192,7 +292,7 @@ func TestAppServiceDelete(t *testing.T) {
 		app := tests.AppFactory()
 		db.Create(&app)
 
-		err := service.Delete(app.UUID)
+		err := service.Delete(app.UUID, AuditContext{})
 		assert.NoError(err)
 	})
 
@@ -202,10 +302,21 @@ func TestAppServiceDelete(t *testing.T) {
 
 		app := tests.AppFactory()
 
-		err := service.Delete(app.UUID)
+		err := service.Delete(app.UUID, AuditContext{})
 		assert.Error(err, AppNotFoundError{nil}.Error())
 	})
 
+	t.Run("Test update back-channel logout url of the internal network", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := AppService{db}
+		app := tests.AppFactory()
+		db.Create(&app)
+
+		_, err := service.Update(app.UUID, validators.AppUpdateData{BackchannelLogoutUrl: "http://localhost:8080/logout"}, AuditContext{})
+
+		assert.IsType(WebhookUrlError{}, err)
+		db.Unscoped().Delete(&app.User)
+	})
 }
 
 func TestAppServiceListApps(t *testing.T) {
@@ -241,3 +352,46 @@ func TestAppServiceListConnectedApps(t *testing.T) {
 	})
 
 }
+
+func TestAppServiceAccessRole(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test access role of personal app", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := AppService{db}
+		app := tests.AppFactory()
+		db.Create(&app)
+		stranger := tests.UserFactory()
+		db.Create(&stranger)
+
+		assert.Equal(models.MembershipOwner, service.AccessRole(app, app.User))
+		assert.Equal("", service.AccessRole(app, stranger))
+
+		db.Unscoped().Delete(&app)
+		db.Unscoped().Delete(&app.User)
+		db.Unscoped().Delete(&stranger)
+	})
+
+	t.Run("Test access role of organization app", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := AppService{db}
+		organizationService := OrganizationService{db: db}
+		app := tests.AppFactory()
+		db.Create(&app)
+		admin := tests.UserFactory()
+		db.Create(&admin)
+
+		organization, _ := organizationService.Create(validators.OrganizationCreateData{Name: faker.Company().Name()}, app.User)
+		membership := models.NewMembership(*organization, admin, models.MembershipAdmin)
+		db.Create(&membership)
+		service.Transfer(&app, *organization, AuditContext{})
+
+		assert.Equal(models.MembershipOwner, service.AccessRole(app, app.User))
+		assert.Equal(models.MembershipAdmin, service.AccessRole(app, admin))
+
+		organizationService.Delete(*organization)
+		db.Unscoped().Delete(&app)
+		db.Unscoped().Delete(&app.User)
+		db.Unscoped().Delete(&admin)
+	})
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gandalf/models"
+	"gandalf/validators"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Names of the custom attributes and of the claims they are released as
+var attributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Claims set by gandalf itself, which the claim mapping of an app cannot
+// override
+var reservedClaims = []string{"iss", "sub", "aud", "exp", "iat", "nbf", "jti", "email", "sid", "nonce", "auth_time", "scopes", "roles", "attributes", "age_verified"}
+
+// Interface for attribute service
+type IAttributeService interface {
+	List() []models.AttributeDefinition
+	Read(name string) (*models.AttributeDefinition, error)
+	Create(data validators.AttributeDefinitionCreateData) (*models.AttributeDefinition, error)
+	Update(definition *models.AttributeDefinition, data validators.AttributeDefinitionUpdateData) error
+	Delete(definition models.AttributeDefinition) error
+	SetUserAttributes(user *models.User, values map[string]interface{}, byStaff bool, audit AuditContext) error
+}
+
+// Attribute service manages the schema of the custom attributes of the
+// users and the values they have
+type AttributeService struct {
+	db *gorm.DB
+}
+
+// Creates a new attribute service
+func NewAttributeService(db *gorm.DB) AttributeService {
+	return AttributeService{db}
+}
+
+// List the attribute definitions by name
+func (service AttributeService) List() []models.AttributeDefinition {
+	var definitions []models.AttributeDefinition
+	service.db.Order("name").Find(&definitions)
+	return definitions
+}
+
+// Read an attribute definition by its name
+func (service AttributeService) Read(name string) (*models.AttributeDefinition, error) {
+	var definition models.AttributeDefinition
+	if err := service.db.Where(&models.AttributeDefinition{Name: name}).First(&definition).Error; err != nil {
+		return nil, AttributeDefinitionNotFoundError{err}
+	}
+	return &definition, nil
+}
+
+// Creates a new attribute definition. Its name must be written in snake
+// case, so it can be released as a claim as it is.
+func (service AttributeService) Create(data validators.AttributeDefinitionCreateData) (*models.AttributeDefinition, error) {
+	if !attributeNamePattern.MatchString(data.Name) {
+		return nil, AttributeDefinitionSaveError{fmt.Errorf("invalid name %s", data.Name)}
+	}
+	definition := models.NewAttributeDefinition(data.Name, data.Type, data.UserEditable)
+	definition.Releasable = data.Releasable
+	definition.Description = data.Description
+	definition.Pattern = data.Pattern
+	definition.Options = data.Options
+	if err := checkAttributeDefinition(definition); err != nil {
+		return nil, err
+	}
+
+	if err := service.db.Create(&definition).Error; err != nil {
+		return nil, AttributeDefinitionSaveError{err}
+	}
+	return &definition, nil
+}
+
+// Updates the given attribute definition. The values already stored are
+// not checked again, so they only have to match the new definition when
+// they are changed. Attributes which are no longer releasable are removed
+// from the claim mapping of the apps.
+func (service AttributeService) Update(definition *models.AttributeDefinition, data validators.AttributeDefinitionUpdateData) error {
+	if data.UserEditable != nil {
+		definition.UserEditable = *data.UserEditable
+	}
+	withheld := false
+	if data.Releasable != nil {
+		withheld = definition.Releasable && !*data.Releasable
+		definition.Releasable = *data.Releasable
+	}
+	if data.Description != "" {
+		definition.Description = data.Description
+	}
+	if data.Pattern != nil {
+		definition.Pattern = *data.Pattern
+	}
+	if data.Options != nil {
+		definition.Options = data.Options
+	}
+	if err := checkAttributeDefinition(*definition); err != nil {
+		return err
+	}
+
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(definition).Error; err != nil {
+			return AttributeDefinitionSaveError{err}
+		}
+		if withheld {
+			return dropClaimRules(tx, definition.Name)
+		}
+		return nil
+	})
+}
+
+// Checks that the constraints of the given definition can be applied to
+// its type
+func checkAttributeDefinition(definition models.AttributeDefinition) error {
+	if definition.Type != models.AttributeTypeString && (definition.Pattern != "" || len(definition.Options) > 0) {
+		return AttributeDefinitionSaveError{errors.New("only string attributes have pattern or options")}
+	}
+	if _, err := regexp.Compile(definition.Pattern); err != nil {
+		return AttributeDefinitionSaveError{err}
+	}
+	return nil
+}
+
+// Deletes the given attribute definition, along with the values the users
+// have for it and the claim mapping rules which release it. Its name can
+// be used again.
+func (service AttributeService) Delete(definition models.AttributeDefinition) error {
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&definition).Error; err != nil {
+			return AttributeDefinitionNotFoundError{err}
+		}
+		query := tx.Unscoped().Model(&models.User{}).Where("jsonb_exists(attributes, ?)", definition.Name)
+		if err := query.Update("attributes", gorm.Expr("attributes - ?", definition.Name)).Error; err != nil {
+			return AttributeDefinitionSaveError{err}
+		}
+		return dropClaimRules(tx, definition.Name)
+	})
+}
+
+// Removes the rules which release the attribute with the given name from
+// the claim mapping of every app
+func dropClaimRules(tx *gorm.DB, name string) error {
+	rule, _ := json.Marshal([]map[string]string{{"attribute": name}})
+	rules := gorm.Expr(
+		"(SELECT COALESCE(jsonb_agg(rule), '[]') FROM jsonb_array_elements(claim_mapping) rule WHERE rule->>'attribute' <> ?)",
+		name,
+	)
+	query := tx.Unscoped().Model(&models.App{}).Where("claim_mapping @> ?", string(rule))
+	if err := query.Update("claim_mapping", rules).Error; err != nil {
+		return AttributeDefinitionSaveError{err}
+	}
+	return nil
+}
+
+// Sets the given custom attributes of the given user, checking their values
+// against the attribute definitions. The attributes set to nil are removed.
+// Users can only change the user editable attributes, while the staff can
+// change any of them.
+func (service AttributeService) SetUserAttributes(user *models.User, values map[string]interface{}, byStaff bool, audit AuditContext) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	var definitions []models.AttributeDefinition
+	service.db.Where("name IN ?", names).Find(&definitions)
+	byName := map[string]models.AttributeDefinition{}
+	for _, definition := range definitions {
+		byName[definition.Name] = definition
+	}
+
+	attributes := models.UserAttributes{}
+	for name, value := range user.Attributes {
+		attributes[name] = value
+	}
+	for name, value := range values {
+		definition, found := byName[name]
+		if !found {
+			return AttributeNotValidError{fmt.Errorf("undefined attribute %s", name)}
+		}
+		if !byStaff && !definition.UserEditable {
+			return AttributeNotEditableError{fmt.Errorf("attribute %s is not user editable", name)}
+		}
+		if value == nil {
+			delete(attributes, name)
+			continue
+		}
+		if !definition.Accepts(value) {
+			return AttributeNotValidError{fmt.Errorf("invalid value for attribute %s", name)}
+		}
+		attributes[name] = value
+	}
+
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("attributes", attributes).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		user.Attributes = attributes
+		sort.Strings(names)
+		metadata := models.AuditMetadata{"attributes": strings.Join(names, ",")}
+		return recordAuditEvent(tx, audit, models.AuditActionSetAttributes, models.AuditOutcomeSuccess, user, metadata)
+	})
+}
+
+// Checks the claim mapping rules of an app. The released attributes must
+// be defined and releasable, since any app owner can write its mapping, and
+// the claims cannot override the ones set by gandalf nor be released twice
+// in the same token.
+func buildClaimMapping(db *gorm.DB, rules []validators.ClaimRuleData) (models.ClaimMapping, error) {
+	mapping := models.ClaimMapping{}
+	released := map[string]bool{}
+	for _, rule := range rules {
+		if !attributeNamePattern.MatchString(rule.Claim) {
+			return nil, AppClaimMappingError{fmt.Errorf("invalid claim %s", rule.Claim)}
+		}
+		for _, reserved := range reservedClaims {
+			if rule.Claim == reserved || strings.HasPrefix(rule.Claim, "age_over_") {
+				return nil, AppClaimMappingError{fmt.Errorf("reserved claim %s", rule.Claim)}
+			}
+		}
+		for _, token := range rule.Tokens {
+			if released[token+":"+rule.Claim] {
+				return nil, AppClaimMappingError{fmt.Errorf("claim %s released twice", rule.Claim)}
+			}
+			released[token+":"+rule.Claim] = true
+		}
+
+		var definition models.AttributeDefinition
+		if err := db.Where(&models.AttributeDefinition{Name: rule.Attribute}).First(&definition).Error; err != nil {
+			return nil, AppClaimMappingError{fmt.Errorf("undefined attribute %s", rule.Attribute)}
+		}
+		if !definition.Releasable {
+			return nil, AppClaimMappingError{fmt.Errorf("attribute %s cannot be released", rule.Attribute)}
+		}
+		mapping = append(mapping, models.ClaimRule{Claim: rule.Claim, Attribute: rule.Attribute, Tokens: rule.Tokens})
+	}
+	return mapping, nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"gandalf/models"
+	"gandalf/tests"
+	"gandalf/validators"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeService(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test definitions are checked", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewAttributeService(db)
+
+		_, err := service.Create(validators.AttributeDefinitionCreateData{Name: "Employee-ID", Type: models.AttributeTypeString})
+		assert.IsType(AttributeDefinitionSaveError{}, err)
+		_, err = service.Create(validators.AttributeDefinitionCreateData{Name: "seats", Type: models.AttributeTypeInteger, Pattern: "[0-9]+"})
+		assert.IsType(AttributeDefinitionSaveError{}, err)
+		_, err = service.Create(validators.AttributeDefinitionCreateData{Name: "badge", Type: models.AttributeTypeString, Pattern: "("})
+		assert.IsType(AttributeDefinitionSaveError{}, err)
+	})
+
+	t.Run("Test users only set their editable attributes", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewAttributeService(db)
+		employee, _ := service.Create(validators.AttributeDefinitionCreateData{Name: "employee_id", Type: models.AttributeTypeString, Pattern: "E-[0-9]+"})
+		plan, _ := service.Create(validators.AttributeDefinitionCreateData{Name: "plan", Type: models.AttributeTypeString, UserEditable: true, Options: []string{"free", "pro"}})
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		err := service.SetUserAttributes(&user, map[string]interface{}{"employee_id": "E-1"}, false, AuditContext{})
+		assert.IsType(AttributeNotEditableError{}, err)
+		err = service.SetUserAttributes(&user, map[string]interface{}{"plan": "gold"}, false, AuditContext{})
+		assert.IsType(AttributeNotValidError{}, err)
+		err = service.SetUserAttributes(&user, map[string]interface{}{"department": "sales"}, true, AuditContext{})
+		assert.IsType(AttributeNotValidError{}, err)
+
+		assert.NoError(service.SetUserAttributes(&user, map[string]interface{}{"plan": "pro"}, false, AuditContext{}))
+		assert.NoError(service.SetUserAttributes(&user, map[string]interface{}{"employee_id": "E-1"}, true, AuditContext{}))
+
+		var stored models.User
+		db.First(&stored, user.ID)
+		assert.Equal(models.UserAttributes{"employee_id": "E-1", "plan": "pro"}, stored.Attributes)
+
+		assert.NoError(service.SetUserAttributes(&user, map[string]interface{}{"plan": nil}, false, AuditContext{}))
+		db.First(&stored, user.ID)
+		assert.Equal(models.UserAttributes{"employee_id": "E-1"}, stored.Attributes)
+
+		db.Unscoped().Delete(&user)
+		service.Delete(*employee)
+		service.Delete(*plan)
+	})
+
+	t.Run("Test deleted attributes are removed from users and apps", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewAttributeService(db)
+		definition, _ := service.Create(validators.AttributeDefinitionCreateData{Name: "department", Type: models.AttributeTypeString, Releasable: true})
+		user := tests.UserFactory()
+		user.Attributes = models.UserAttributes{"department": "sales"}
+		db.Create(&user)
+		app := tests.AppFactory()
+		mapping, err := buildClaimMapping(db, []validators.ClaimRuleData{{Claim: "dept", Attribute: "department", Tokens: []string{models.ClaimTokenID}}})
+		assert.NoError(err)
+		app.ClaimMapping = mapping
+		db.Create(&app)
+
+		assert.NoError(service.Delete(*definition))
+
+		var stored models.User
+		db.First(&stored, user.ID)
+		assert.Empty(stored.Attributes)
+		db.First(&app, app.ID)
+		assert.Empty(app.ClaimMapping)
+		_, err = service.Read("department")
+		assert.IsType(AttributeDefinitionNotFoundError{}, err)
+
+		db.Unscoped().Delete(&app)
+		db.Unscoped().Delete(&user)
+	})
+
+	t.Run("Test claim mappings cannot release reserved claims", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewAttributeService(db)
+		definition, _ := service.Create(validators.AttributeDefinitionCreateData{Name: "plan", Type: models.AttributeTypeString, Releasable: true})
+
+		_, err := buildClaimMapping(db, []validators.ClaimRuleData{{Claim: "sub", Attribute: "plan", Tokens: []string{models.ClaimTokenAccess}}})
+		assert.IsType(AppClaimMappingError{}, err)
+		_, err = buildClaimMapping(db, []validators.ClaimRuleData{{Claim: "tier", Attribute: "seats", Tokens: []string{models.ClaimTokenAccess}}})
+		assert.IsType(AppClaimMappingError{}, err)
+		_, err = buildClaimMapping(db, []validators.ClaimRuleData{
+			{Claim: "tier", Attribute: "plan", Tokens: []string{models.ClaimTokenAccess}},
+			{Claim: "tier", Attribute: "plan", Tokens: []string{models.ClaimTokenAccess}},
+		})
+		assert.IsType(AppClaimMappingError{}, err)
+
+		service.Delete(*definition)
+	})
+	t.Run("Test only releasable attributes are mapped to claims", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewAttributeService(db)
+		definition, _ := service.Create(validators.AttributeDefinitionCreateData{Name: "salary_band", Type: models.AttributeTypeString})
+		rules := []validators.ClaimRuleData{{Claim: "band", Attribute: "salary_band", Tokens: []string{models.ClaimTokenAccess}}}
+
+		_, withheldErr := buildClaimMapping(db, rules)
+		releasable := true
+		service.Update(definition, validators.AttributeDefinitionUpdateData{Releasable: &releasable})
+		mapping, err := buildClaimMapping(db, rules)
+		app := tests.AppFactory()
+		app.ClaimMapping = mapping
+		db.Create(&app)
+		releasable = false
+		service.Update(definition, validators.AttributeDefinitionUpdateData{Releasable: &releasable})
+
+		assert.IsType(AppClaimMappingError{}, withheldErr)
+		assert.NoError(err)
+		db.First(&app, app.ID)
+		assert.Empty(app.ClaimMapping)
+
+		db.Unscoped().Delete(&app)
+		service.Delete(*definition)
+	})
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/validators"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Who performs an audited operation and the client it is performed from.
+// The actor is nil when it is unknown.
+type AuditContext struct {
+	Actor  *models.User
+	Client helpers.ClientInfo
+}
+
+// Records an audit event through the given db, which should be the
+// transaction that performs the change the event describes
+func recordAuditEvent(db *gorm.DB, audit AuditContext, action string, outcome string, subject *models.User, metadata models.AuditMetadata) error {
+	event := models.NewAuditEvent(
+		action, outcome, audit.Actor, subject,
+		audit.Client.UserAgent, audit.Client.IP, metadata,
+	)
+	if err := db.Create(&event).Error; err != nil {
+		return AuditEventRecordError{err}
+	}
+	return nil
+}
+
+// Interface for audit service
+type IAuditService interface {
+	List(filter validators.AuditEventListQuery, cursor *helpers.Cursor) []models.AuditEvent
+	ListForUser(user models.User, cursor *helpers.Cursor) []models.AuditEvent
+}
+
+// Audit service reads the security audit log
+type AuditService struct {
+	db *gorm.DB
+}
+
+// Creates a new audit service
+func NewAuditService(db *gorm.DB) AuditService {
+	return AuditService{db}
+}
+
+// Lists the audit events which match the given filter, the most recent first
+func (service AuditService) List(filter validators.AuditEventListQuery, cursor *helpers.Cursor) []models.AuditEvent {
+	matching := func(db *gorm.DB) *gorm.DB {
+		if filter.Actor != "" {
+			actors := service.db.Model(&models.User{}).Select("id").Where("uuid = ?", uuid.FromStringOrNil(filter.Actor))
+			db = db.Where("actor_id IN (?)", actors)
+		}
+		if filter.Subject != "" {
+			subjects := service.db.Model(&models.User{}).Select("id").Where("uuid = ?", uuid.FromStringOrNil(filter.Subject))
+			db = db.Where("subject_id IN (?)", subjects)
+		}
+		if filter.Action != "" {
+			db = db.Where("action = ?", filter.Action)
+		}
+		if filter.Outcome != "" {
+			db = db.Where("outcome = ?", filter.Outcome)
+		}
+		if !filter.Since.IsZero() {
+			db = db.Where("created_at >= ?", filter.Since)
+		}
+		if !filter.Until.IsZero() {
+			db = db.Where("created_at < ?", filter.Until)
+		}
+		return db
+	}
+	return service.list(matching, cursor)
+}
+
+// Lists the audit events performed by or affecting the given user, the
+// most recent first
+func (service AuditService) ListForUser(user models.User, cursor *helpers.Cursor) []models.AuditEvent {
+	involved := func(db *gorm.DB) *gorm.DB {
+		return db.Where("actor_id = ? OR subject_id = ?", user.ID, user.ID)
+	}
+	return service.list(involved, cursor)
+}
+
+// Lists a page of the audit events matched by the given scope
+func (service AuditService) list(scope func(*gorm.DB) *gorm.DB, cursor *helpers.Cursor) []models.AuditEvent {
+	var events []models.AuditEvent
+	var count int64
+
+	service.db.Model(&models.AuditEvent{}).Scopes(scope).Count(&count)
+	service.db.Preload("Actor").Preload("Subject").
+		Scopes(scope, helpers.DBPaginate(cursor.Page, cursor.PageSize)).
+		Order("created_at DESC, id DESC").Find(&events)
+	cursor.Update(int(count))
+	return events
+}
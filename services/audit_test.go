@@ -0,0 +1,112 @@
+package services
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/tests"
+	"gandalf/validators"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditServiceConstructor(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		db := tests.NewTestDatabase(true)
+		service := NewAuditService(db)
+
+		assert.Equal(service.db, db)
+	})
+}
+
+func TestAuditServiceList(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list filtered audit events", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewAuditService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+		audit := AuditContext{&user, helpers.ClientInfo{UserAgent: "agent", IP: "127.0.0.1"}}
+		assert.NoError(recordAuditEvent(db, audit, models.AuditActionLogin, models.AuditOutcomeSuccess, &user, nil))
+		assert.NoError(recordAuditEvent(db, audit, models.AuditActionLogin, models.AuditOutcomeFailure, &user, nil))
+
+		cursor := helpers.NewCursor(0, 10)
+		filter := validators.AuditEventListQuery{Actor: user.UUID.String(), Outcome: models.AuditOutcomeFailure}
+		events := service.List(filter, &cursor)
+
+		assert.Equal(1, len(events))
+		assert.Equal(models.AuditOutcomeFailure, events[0].Outcome)
+		assert.Equal(user.ID, events[0].Actor.ID)
+		assert.Equal("agent", events[0].UserAgent)
+
+		db.Unscoped().Delete(&user)
+	})
+
+	t.Run("Test list the activity of an user", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewAuditService(db)
+		user := tests.UserFactory()
+		staff := tests.UserFactory()
+		db.Create(&user)
+		db.Create(&staff)
+		userService := NewUserService(db)
+		assert.NoError(userService.Delete(user.UUID, AuditContext{Actor: &staff}, nil))
+
+		cursor := helpers.NewCursor(0, 10)
+		events := service.ListForUser(user, &cursor)
+
+		assert.Equal(1, len(events))
+		assert.Equal(models.AuditActionDeleteUser, events[0].Action)
+		assert.Equal(staff.ID, *events[0].ActorID)
+
+		db.Unscoped().Delete(&user)
+		db.Unscoped().Delete(&staff)
+	})
+
+	t.Run("Test audit events cannot be updated nor deleted", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		assert.NoError(tests.ApplyMigration(db, "00024_audit_events_append_only.sql"))
+		user := tests.UserFactory()
+		db.Create(&user)
+		assert.NoError(recordAuditEvent(db, AuditContext{}, models.AuditActionLogin, models.AuditOutcomeFailure, &user, nil))
+
+		db.Model(&models.AuditEvent{}).Where("subject_id = ?", user.ID).Update("outcome", models.AuditOutcomeSuccess)
+		db.Where("subject_id = ?", user.ID).Delete(&models.AuditEvent{})
+
+		var events []models.AuditEvent
+		db.Where("subject_id = ?", user.ID).Find(&events)
+		assert.Equal(1, len(events))
+		assert.Equal(models.AuditOutcomeFailure, events[0].Outcome)
+
+		// The references to deleted users are still nulled
+		assert.NoError(db.Unscoped().Delete(&user).Error)
+	})
+
+	t.Run("Test failed logins are recorded", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewAuditService(db)
+		authService := NewAuthService(db)
+		user := tests.UserFactory()
+		user.Verified = true
+		db.Create(&user)
+
+		credentials := validators.Credentials{Email: user.Email, Password: "wrong"}
+		_, err := authService.Authenticate(credentials, false, helpers.ClientInfo{IP: "127.0.0.1"})
+		assert.Error(err)
+
+		cursor := helpers.NewCursor(0, 10)
+		events := service.ListForUser(user, &cursor)
+		assert.Equal(1, len(events))
+		assert.Equal(models.AuditOutcomeFailure, events[0].Outcome)
+		assert.Nil(events[0].ActorID)
+		assert.Empty(events[0].Metadata["email"])
+		assert.Equal(security.Sha256Digest(strings.ToLower(user.Email)), events[0].Metadata["email_digest"])
+
+		db.Unscoped().Delete(&user)
+	})
+}
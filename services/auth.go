@@ -1,7 +1,9 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"gandalf/bindings"
 	"gandalf/helpers"
 	"gandalf/models"
@@ -9,6 +11,7 @@ import (
 	"gandalf/validators"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	mapset "github.com/deckarep/golang-set"
@@ -20,17 +23,33 @@ import (
 // JWT for accessing resources
 type accessTokenClaims struct {
 	jwt.StandardClaims
-	UUID   uuid.UUID
-	Email  string
-	Scopes []string
+	UUID    uuid.UUID
+	Email   string
+	Scopes  []string
+	Session uuid.UUID
+
+	// Custom attributes of the user released to the app by its claim
+	// mapping
+	Attributes map[string]interface{} `json:",omitempty"`
+
+	// Staff user acting as the user, as defined by the token exchange
+	// spec. Only impersonation tokens carry it.
+	Act *actorClaim `json:"act,omitempty"`
+}
+
+// Identifies the staff user who acts as the subject of an access token
+type actorClaim struct {
+	Subject uuid.UUID `json:"sub"`
+	Email   string    `json:"email"`
 }
 
 // Creates claims for the access token from the given params
-func newAccessTokenClaims(user models.User, scopes []string, ttl time.Duration) accessTokenClaims {
+func newAccessTokenClaims(user models.User, session uuid.UUID, scopes []string, ttl time.Duration) accessTokenClaims {
 	return accessTokenClaims{
-		UUID:   user.UUID,
-		Email:  user.Email,
-		Scopes: scopes,
+		UUID:    user.UUID,
+		Email:   user.Email,
+		Scopes:  scopes,
+		Session: session,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(ttl * time.Minute).Unix(),
 		},
@@ -40,34 +59,113 @@ func newAccessTokenClaims(user models.User, scopes []string, ttl time.Duration)
 // JWT for refreshing access token
 type refreshTokenClaims struct {
 	jwt.StandardClaims
-	UUID uuid.UUID
+	UUID    uuid.UUID
+	Session uuid.UUID
 }
 
 // Creates claims for the refresh token from the given params
-func newRefreshTokenClaims(user models.User, ttl time.Duration) refreshTokenClaims {
+func newRefreshTokenClaims(user models.User, session uuid.UUID, ttl time.Duration) refreshTokenClaims {
 	return refreshTokenClaims{
-		UUID: user.UUID,
+		UUID:    user.UUID,
+		Session: session,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(ttl * time.Minute).Unix(),
 		},
 	}
 }
 
-// Contains user tokens for authenticate and refresh
+// JWT that identifies the user to the app he has logged in, as defined by
+// OpenID Connect. It is signed with the client secret of the app.
+type idTokenClaims struct {
+	jwt.StandardClaims
+	Email   string `json:"email"`
+	Session string `json:"sid"`
+
+	// Whether the age of the user is known. Apps with a minimum age also
+	// get an age_over_N claim, but never the birthday itself.
+	AgeVerified bool `json:"age_verified"`
+
+	// Claims released to the app besides the standard ones, like the
+	// custom attributes of its claim mapping, written as top level claims
+	Extra map[string]interface{} `json:"-"`
+}
+
+// Implement json Marshaler interface, so the extra claims are written
+// along with the standard ones without overriding them
+func (claims idTokenClaims) MarshalJSON() ([]byte, error) {
+	type plainClaims idTokenClaims
+	data, err := json.Marshal(plainClaims(claims))
+	if err != nil || len(claims.Extra) == 0 {
+		return data, err
+	}
+
+	merged := map[string]interface{}{}
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for claim, value := range claims.Extra {
+		if _, found := merged[claim]; !found {
+			merged[claim] = value
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// Creates claims for the id token from the given params
+func newIDTokenClaims(issuer string, user models.User, app models.App, session models.Session, ttl time.Duration) idTokenClaims {
+	now := time.Now()
+	extra := app.MappedClaims(user, models.ClaimTokenID)
+	if app.MinimumAge > 0 {
+		extra[fmt.Sprintf("age_over_%d", app.MinimumAge)] = user.IsOfAge(app.MinimumAge)
+	}
+	return idTokenClaims{
+		Email:       user.Email,
+		Session:     session.UUID.String(),
+		AgeVerified: !user.Birthday.IsZero(),
+		Extra:       extra,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    issuer,
+			Subject:   user.UUID.String(),
+			Audience:  app.ClientID.String(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl * time.Minute).Unix(),
+		},
+	}
+}
+
+// Contains user tokens for authenticate and refresh. The id token is only
+// issued to apps.
 type AuthTokens struct {
 	AccessToken  string
 	RefreshToken string
 	ExpiresIn    time.Duration
+	IDToken      string
 }
 
 // Interface for auth service
 type IAuthService interface {
-	Authenticate(credentials validators.Credentials, isStaff bool) (*models.User, error)
-	GenerateTokens(user models.User, scopes []string) AuthTokens
+	Authenticate(credentials validators.Credentials, isStaff bool, client helpers.ClientInfo) (*models.User, error)
+	StartSession(user models.User, client helpers.ClientInfo, scopes []string) (*AuthTokens, error)
 	GetAuthorizedUser(accessToken string, scopes []string) (*models.User, error)
+	GetTokenSession(accessToken string) uuid.UUID
 	RefreshToken(accessToken string, refreshToken string) (*AuthTokens, error)
-	Authorize(*models.App, *models.User, validators.OauthAuthorizeData) (string, error)
-	ExchangeOauthToken(models.App, validators.OauthExchangeToken) (*AuthTokens, error)
+	Authorize(*models.App, *models.User, validators.OauthAuthorizeData, helpers.ClientInfo, uuid.UUID) (string, error)
+	ExchangeOauthToken(models.App, validators.OauthExchangeToken, helpers.ClientInfo) (*AuthTokens, error)
+	Impersonate(staff models.User, user models.User, reason string, client helpers.ClientInfo) (*AuthTokens, error)
+	GetTokenImpersonator(accessToken string) *models.User
+	RecordImpersonatedRequest(staff models.User, user models.User, request string, client helpers.ClientInfo) error
+}
+
+// Default lifetime in minutes of the impersonation tokens
+const defaultImpersonationTTL = 15
+
+// Returns the set of scopes that only staff users can use
+func staffScopes() mapset.Set {
+	scopes := mapset.NewSet()
+	for _, scope := range security.GroupStaff {
+		scopes.Add(scope)
+	}
+	return scopes
 }
 
 // Auth service
@@ -76,6 +174,14 @@ type AuthService struct {
 	tokenTTL  time.Duration `env:"JWT_TOKEN_TTL"`
 	tokenRTTL time.Duration `env:"JWT_TOKEN_RTTL"`
 	tokenKey  interface{}   `env:"JWT_TOKEN_KEY"`
+	issuer    string        `env:"OIDC_ISSUER"`
+	alertTTL  time.Duration `env:"SECURITY_ALERT_TOKEN_TTL"`
+
+	impersonationTTL time.Duration `env:"IMPERSONATION_TOKEN_TTL"`
+
+	phoneCodeAttempts int `env:"PHONE_CODE_MAX_ATTEMPTS"`
+
+	backends []ICredentialBackend `env:"AUTH_BACKENDS"`
 
 	parseTokenWithClaims func(tokenString string, claims jwt.Claims, keyFunc jwt.Keyfunc) (*jwt.Token, error)
 	newTokenWithClaims   func(method jwt.SigningMethod, claims jwt.Claims) *jwt.Token
@@ -97,6 +203,11 @@ func NewAuthService(db *gorm.DB) AuthService {
 		tokenTTL:             time.Duration(tokenTTL),
 		tokenRTTL:            time.Duration(tokenRTTL),
 		tokenKey:             []byte(os.Getenv("JWT_TOKEN_KEY")),
+		issuer:               os.Getenv("OIDC_ISSUER"),
+		alertTTL:             time.Duration(helpers.GetEnvInt("SECURITY_ALERT_TOKEN_TTL", defaultSecurityAlertTTL)),
+		impersonationTTL:     time.Duration(helpers.GetEnvInt("IMPERSONATION_TOKEN_TTL", defaultImpersonationTTL)),
+		phoneCodeAttempts:    helpers.GetEnvInt("PHONE_CODE_MAX_ATTEMPTS", 5),
+		backends:             NewCredentialBackends(db),
 		parseTokenWithClaims: jwt.ParseWithClaims,
 		newTokenWithClaims:   jwt.NewWithClaims,
 		keyfunc:              keyfunc,
@@ -123,30 +234,156 @@ func (service AuthService) signToken(token *jwt.Token) string {
 	return signedToken
 }
 
-// Authenticates an user with the given credentials and returns it
-func (service AuthService) Authenticate(credentials validators.Credentials, isStaff bool) (*models.User, error) {
-	var user models.User
-	if err := service.db.Where(&models.User{Email: credentials.Email, Verified: true, Staff: isStaff}).First(&user).Error; err != nil {
-		return nil, AuthenticationError{err}
+// Verifies the given credentials against every backend in turn, and
+// returns the user of the first one which accepts them. On failure, the
+// last user known by any backend is returned along with the error.
+func (service AuthService) verifyCredentials(credentials validators.Credentials, isStaff bool) (*models.User, error) {
+	var known *models.User
+	err := error(AuthenticationError{nil})
+	for _, backend := range service.backends {
+		user, verifyErr := backend.Verify(credentials, isStaff)
+		if verifyErr == nil {
+			return user, nil
+		}
+		if user != nil {
+			known = user
+		}
+		err = verifyErr
+	}
+	return known, err
+}
+
+// Runs the steps every login goes through once the identity of the given
+// user has been verified, whatever the method he signs in with. Users who
+// use their phone as second factor must give the code sent to it, and the
+// mandatory legal documents must have been accepted.
+func requireLoginSteps(db *gorm.DB, user models.User, phoneCode string, accepted []string, maxAttempts int, audit AuditContext, metadata models.AuditMetadata) error {
+	if err := requireSecondFactor(db, user, phoneCode, maxAttempts, metadata); err != nil {
+		return err
 	}
+	audit.Actor = &user
+	return requireLegalAcceptance(db, user, accepted, nil, audit)
+}
+
+// Check if the given error only asks for a step the login misses, so the
+// login can be retried along with it
+func isMissingLoginStep(err error) bool {
+	switch err.(type) {
+	case PhoneCodeRequiredError, LegalAcceptanceRequiredError:
+		return true
+	}
+	return false
+}
+
+// Authenticates an user with the given credentials and returns it. When
+// the login misses a step, like the code sent to the phone of the users who
+// use it as second factor, the user is returned along with the error, so
+// the code can be sent to him.
+func (service AuthService) Authenticate(credentials validators.Credentials, isStaff bool, client helpers.ClientInfo) (*models.User, error) {
+	// The audit log cannot be scrubbed, so the given email is only kept as
+	// a digest which correlates the attempts without revealing it
+	audit := AuditContext{Client: client}
+	metadata := models.AuditMetadata{
+		"email_digest": security.Sha256Digest(strings.ToLower(credentials.Email)),
+		"staff":        strconv.FormatBool(isStaff),
+	}
+
+	verified, err := service.verifyCredentials(credentials, isStaff)
+	if err != nil {
+		recordAuditEvent(service.db, audit, models.AuditActionLogin, models.AuditOutcomeFailure, verified, metadata)
+		return nil, err
+	}
+	user := *verified
 
-	if !user.VerifyPassword(credentials.Password) {
-		return nil, AuthenticationError{nil}
+	err = requireLoginSteps(service.db, user, credentials.PhoneCode, credentials.AcceptedDocuments, service.phoneCodeAttempts, audit, metadata)
+	if isMissingLoginStep(err) {
+		return &user, err
+	}
+	if err != nil {
+		recordAuditEvent(service.db, audit, models.AuditActionLogin, models.AuditOutcomeFailure, &user, metadata)
+		return nil, err
 	}
 
+	audit.Actor = &user
+	if user.DeletedAt.Valid {
+		if err := restoreUser(service.db, &user, audit); err != nil {
+			return nil, err
+		}
+	}
+	if err := recordAuditEvent(service.db, audit, models.AuditActionLogin, models.AuditOutcomeSuccess, &user, metadata); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
-// Generate a pair access token for the given user with the given scopes
-func (service AuthService) GenerateTokens(user models.User, scopes []string) AuthTokens {
-	accessToken := service.signToken(service.newTokenWithClaims(
-		jwt.SigningMethodHS256, newAccessTokenClaims(user, scopes, service.tokenTTL),
-	))
+// Generate a pair access token for the given user and session with the
+// given scopes. The given custom attributes are released in the access
+// token, and they are kept when it is refreshed.
+func (service AuthService) generateTokens(user models.User, session uuid.UUID, scopes []string, attributes map[string]interface{}) AuthTokens {
+	accessClaims := newAccessTokenClaims(user, session, scopes, service.tokenTTL)
+	if len(attributes) > 0 {
+		accessClaims.Attributes = attributes
+	}
+	accessToken := service.signToken(service.newTokenWithClaims(jwt.SigningMethodHS256, accessClaims))
 	refreshToken := service.signToken(service.newTokenWithClaims(
-		jwt.SigningMethodHS256, newRefreshTokenClaims(user, service.tokenRTTL),
+		jwt.SigningMethodHS256, newRefreshTokenClaims(user, session, service.tokenRTTL),
 	))
 
-	return AuthTokens{accessToken, refreshToken, service.tokenTTL}
+	return AuthTokens{accessToken, refreshToken, service.tokenTTL, ""}
+}
+
+// Generate the id token of the given user for the given app and session
+func (service AuthService) generateIDToken(user models.User, app models.App, session models.Session) string {
+	token := service.newTokenWithClaims(
+		jwt.SigningMethodHS256, newIDTokenClaims(service.issuer, user, app, session, service.tokenTTL),
+	)
+	signedToken, err := token.SignedString([]byte(app.ClientSecret))
+	if err != nil {
+		panic(err)
+	}
+	return signedToken
+}
+
+// Generate a pair access token for the given user with the given scopes.
+// These tokens are not bound to any session, so they cannot be revoked
+// and they are only used as authorization codes.
+func (service AuthService) GenerateTokens(user models.User, scopes []string) AuthTokens {
+	return service.generateTokens(user, uuid.Nil, scopes, nil)
+}
+
+// Creates a new session for the given user from the given client and
+// generates a pair access token bound to it with the given scopes. Logins
+// from devices not seen before are alerted to the user.
+func (service AuthService) StartSession(user models.User, client helpers.ClientInfo, scopes []string) (*AuthTokens, error) {
+	var session *models.Session
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		newDevice := isNewDevice(tx, user, client)
+
+		var err error
+		if session, err = createSession(tx, user, nil, nil, client); err != nil {
+			return err
+		}
+		if !newDevice {
+			return nil
+		}
+		context := NotificationContext{Device: client.UserAgent, IP: client.IP}
+		return enqueueSecurityAlert(tx, user, models.OutboxKindAlertNewDevice, context, service.alertTTL)
+	})
+	if err != nil {
+		return nil, err
+	}
+	tokens := service.generateTokens(user, session.UUID, scopes, nil)
+	return &tokens, nil
+}
+
+// Returns the uuid of the session the given access token is bound to, or
+// uuid.Nil if the token is not valid or it is not bound to any session
+func (service AuthService) GetTokenSession(accessToken string) uuid.UUID {
+	accessClaims := &accessTokenClaims{}
+	if err := service.getClaims(accessToken, accessClaims, true); err != nil {
+		return uuid.Nil
+	}
+	return accessClaims.Session
 }
 
 // Return the user who perform the request if he has
@@ -154,7 +391,6 @@ func (service AuthService) GenerateTokens(user models.User, scopes []string) Aut
 func (service AuthService) GetAuthorizedUser(token string, scopes []string) (*models.User, error) {
 	accessClaims := &accessTokenClaims{}
 	err := service.getClaims(token, accessClaims, true)
-	verified := true
 
 	if err != nil {
 		return nil, err
@@ -169,21 +405,48 @@ func (service AuthService) GetAuthorizedUser(token string, scopes []string) (*mo
 		tokenScopes.Add(elem)
 	}
 
-	// It's mandatory to search on verified users, except on the verification
-	// endpoint
-	if mandatoryScopes.Contains(security.ScopeUserVerify) {
-		verified = false
-	}
-
 	if !mandatoryScopes.IsSubset(tokenScopes) {
 		return nil, AuthorizationError{errors.New("Unauthorized")}
 	}
 
 	var user models.User
-	if err := service.db.Where(&models.User{UUID: accessClaims.UUID, Email: accessClaims.Email, Verified: verified}).First(&user).Error; err != nil {
+	if err := service.db.Where(&models.User{UUID: accessClaims.UUID, Email: accessClaims.Email, Verified: true}).First(&user).Error; err != nil {
 		return nil, AuthorizationError{errors.New("Related user does not exist")}
 	}
 
+	if user.Disabled {
+		return nil, AuthorizationError{errors.New("Related user is disabled")}
+	}
+
+	if accessClaims.Session != uuid.Nil {
+		session, err := readActiveSession(service.db, accessClaims.Session)
+		if err != nil || session.UserID != user.ID {
+			return nil, AuthorizationError{errors.New("Session has been revoked")}
+		}
+	}
+
+	// Staff scopes can only be used while the user roles still grant them
+	requiredStaffScopes := mandatoryScopes.Intersect(staffScopes())
+	if requiredStaffScopes.Cardinality() > 0 {
+		grantedScopes := mapset.NewSet()
+		for _, scope := range readUserScopes(service.db, user) {
+			grantedScopes.Add(scope)
+		}
+		if !user.Staff || !requiredStaffScopes.IsSubset(grantedScopes) {
+			return nil, AuthorizationError{errors.New("Staff scopes are not granted to the user")}
+		}
+	}
+
+	// Impersonation tokens stop working as soon as the staff user who
+	// acts as the user loses the grant to do it, and they are not logins
+	// of the user
+	if accessClaims.Act != nil {
+		if _, err := readImpersonator(service.db, accessClaims.Act.Subject); err != nil {
+			return nil, AuthorizationError{err}
+		}
+		return &user, nil
+	}
+
 	user.LastLogin = time.Now()
 	service.db.Save(&user)
 
@@ -202,35 +465,92 @@ func (service AuthService) RefreshToken(accessToken string, refreshToken string)
 		return nil, AuthenticationError{errors.New("Unrecognized token")}
 	}
 
-	if accessClaims.UUID != refreshClaims.UUID {
+	if accessClaims.UUID != refreshClaims.UUID || accessClaims.Session != refreshClaims.Session {
 		return nil, AuthenticationError{errors.New("Unrelated access and refresh token")}
 	}
 
+	if accessClaims.Act != nil {
+		return nil, AuthenticationError{errors.New("Impersonation tokens cannot be refreshed")}
+	}
+
+	if refreshClaims.Session != uuid.Nil {
+		if _, err := readActiveSession(service.db, refreshClaims.Session); err != nil {
+			return nil, AuthenticationError{errors.New("Session has been revoked")}
+		}
+	}
+
 	accessClaims.ExpiresAt = time.Now().Add(service.tokenTTL * time.Minute).Unix()
 	newAccessToken := service.signToken(service.newTokenWithClaims(jwt.SigningMethodHS256, accessClaims))
 
-	return &AuthTokens{newAccessToken, refreshToken, service.tokenTTL}, nil
+	return &AuthTokens{newAccessToken, refreshToken, service.tokenTTL, ""}, nil
 }
 
 // Associate the given app with the given app in order to save that the user
-// has signin on the given app. Returns the authorization code and error.
-func (service AuthService) Authorize(app *models.App, user *models.User, data validators.OauthAuthorizeData) (string, error) {
+// has signin on the given app. A new session is started for the app from
+// the given client, as a child of the session with the given uuid if any.
+// Returns the authorization code and error.
+func (service AuthService) Authorize(app *models.App, user *models.User, data validators.OauthAuthorizeData, client helpers.ClientInfo, parent uuid.UUID) (string, error) {
+
+	// SAML apps only receive assertions, never authorization codes
+	if app.IsSAML() {
+		return "", AppProtocolError{}
+	}
 
 	if !helpers.PqStringArrayContains(app.RedirectUrls, data.RedirectURI) {
 		return "", RedirectUriDoesNotMatch{redirectUri: data.RedirectURI}
 	}
 
+	if !user.IsOfAge(app.MinimumAge) {
+		return "", AppAgeRestrictedError{}
+	}
+
+	audit := AuditContext{Actor: user, Client: client}
+	if err := requireLegalAcceptance(service.db, *user, data.AcceptedDocuments, app, audit); err != nil {
+		return "", err
+	}
+
+	var parentSession *models.Session
+	if parent != uuid.Nil {
+		parentSession, _ = readActiveSession(service.db, parent)
+	}
+
+	// Only the first authorization of an app is alerted to the user
+	connected := service.db.Model(user).Where("apps.id = ?", app.ID).Association("ConnectedApps").Count() > 0
+
 	authorizationCode := service.GenerateTokens(*user, []string{security.ScopeUserAuthorizationCode}).AccessToken
-	claim := models.NewClaim(
-		data.RedirectURI,
-		authorizationCode,
-		bindings.ScopeArrayToStringArray(data.Scopes),
-		*user,
-		*app,
-	)
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		session, err := createSession(tx, *user, app, parentSession, client)
+		if err != nil {
+			return err
+		}
+
+		claim := models.NewClaim(
+			data.RedirectURI,
+			authorizationCode,
+			bindings.ScopeArrayToStringArray(data.Scopes),
+			*user,
+			*app,
+		)
+		claim.SessionID = &session.ID
+
+		tx.Create(&claim)
+		tx.Model(app).Association("ConnectedUsers").Append(user)
+
+		metadata := models.AuditMetadata{"app": app.ClientID.String(), "scopes": strings.Join(claim.Scopes, " ")}
+		err = recordAuditEvent(
+			tx, audit,
+			models.AuditActionAuthorizeApp, models.AuditOutcomeSuccess, user, metadata,
+		)
+		if err != nil || connected {
+			return err
+		}
+		context := NotificationContext{AppName: app.Name, Device: client.UserAgent, IP: client.IP}
+		return enqueueSecurityAlert(tx, *user, models.OutboxKindAlertAppAuthorized, context, service.alertTTL)
+	})
+	if err != nil {
+		return "", err
+	}
 
-	service.db.Create(&claim)
-	service.db.Model(app).Association("ConnectedUsers").Append(user)
 	service.db.Model(user).Association("ConnectedApps").Find(&user.ConnectedApps)
 	service.db.Model(app).Association("ConnectedUser").Find(&app.ConnectedUsers)
 
@@ -239,13 +559,18 @@ func (service AuthService) Authorize(app *models.App, user *models.User, data va
 
 // Produces an access token with the requested scopes if the given data belongs to the
 // created claim. Otherwise an error will be returned
-func (service AuthService) ExchangeOauthToken(app models.App, data validators.OauthExchangeToken) (*AuthTokens, error) {
+func (service AuthService) ExchangeOauthToken(app models.App, data validators.OauthExchangeToken, client helpers.ClientInfo) (*AuthTokens, error) {
+	audit := AuditContext{Client: client}
+	metadata := models.AuditMetadata{"app": app.ClientID.String()}
+
 	if !helpers.PqStringArrayContains(app.RedirectUrls, data.RedirectUrl) {
+		recordAuditEvent(service.db, audit, models.AuditActionExchangeToken, models.AuditOutcomeFailure, nil, metadata)
 		return nil, RedirectUriDoesNotMatch{redirectUri: data.RedirectUrl}
 	}
 
 	user, err := service.GetAuthorizedUser(data.AuthorizationCode, []string{security.ScopeUserAuthorizationCode})
 	if err != nil {
+		recordAuditEvent(service.db, audit, models.AuditActionExchangeToken, models.AuditOutcomeFailure, nil, metadata)
 		return nil, err
 	}
 
@@ -258,9 +583,112 @@ func (service AuthService) ExchangeOauthToken(app models.App, data validators.Oa
 
 	var claim models.Claim
 	if err := service.db.Where(clause).First(&claim).Error; err != nil {
+		recordAuditEvent(service.db, audit, models.AuditActionExchangeToken, models.AuditOutcomeFailure, user, metadata)
 		return nil, ClaimDoesNotExist{err}
 	}
 
-	tokens := service.GenerateTokens(*user, claim.Scopes)
+	var session *models.Session
+	err = service.db.Transaction(func(tx *gorm.DB) error {
+		// Claims created before sessions existed start a new one for the app
+		if claim.SessionID != nil {
+			session = &models.Session{}
+			if err := tx.Where("revoked_at IS NULL").First(session, *claim.SessionID).Error; err != nil {
+				return SessionNotFoundError{err}
+			}
+		} else if session, err = createSession(tx, *user, &app, nil, helpers.ClientInfo{}); err != nil {
+			return err
+		}
+
+		audit.Actor = user
+		metadata["session"] = session.UUID.String()
+		return recordAuditEvent(tx, audit, models.AuditActionExchangeToken, models.AuditOutcomeSuccess, user, metadata)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := service.generateTokens(*user, session.UUID, claim.Scopes, app.MappedClaims(*user, models.ClaimTokenAccess))
+	tokens.IDToken = service.generateIDToken(*user, app, *session)
 	return &tokens, nil
 }
+
+// Reads the staff user with the given uuid as long as his roles still grant
+// him to act as other users
+func readImpersonator(db *gorm.DB, uuid uuid.UUID) (*models.User, error) {
+	var staff models.User
+	if err := db.Where(&models.User{UUID: uuid, Staff: true}).First(&staff).Error; err != nil {
+		return nil, errors.New("Impersonator does not exist")
+	}
+	if staff.Disabled {
+		return nil, errors.New("Impersonator is disabled")
+	}
+	granted := security.IntersectScopes([]string{security.ScopeUserImpersonateAll}, readUserScopes(db, staff))
+	if len(granted) == 0 {
+		return nil, errors.New("Impersonation is no longer granted to the staff user")
+	}
+	return &staff, nil
+}
+
+// Issues a short lived access token for the given staff user to act as the
+// given user, so support can reproduce his issues without his password. The
+// token is bound to a new session of the user, which he can revoke, and it
+// carries the staff user in its act claim. It cannot be refreshed, and
+// neither staff scopes nor the ones which delete the user, export his data
+// or authorize apps on his behalf are issued. The user is
+// alerted and the impersonation is audited with the given reason.
+func (service AuthService) Impersonate(staff models.User, user models.User, reason string, client helpers.ClientInfo) (*AuthTokens, error) {
+	if user.ID == staff.ID || user.Staff {
+		return nil, ImpersonationError{errors.New("staff users cannot be impersonated")}
+	}
+	if user.Disabled || !user.Verified {
+		return nil, ImpersonationError{errors.New("only active users can be impersonated")}
+	}
+
+	userScopes, _ := security.SplitStaffScopes(readUserScopes(service.db, user))
+	scopes := security.ExcludeScopes(userScopes, security.GroupImpersonationDenied)
+
+	var session *models.Session
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if session, err = createSession(tx, user, nil, nil, client); err != nil {
+			return err
+		}
+		audit := AuditContext{Actor: &staff, Client: client}
+		metadata := models.AuditMetadata{"session": session.UUID.String(), "reason": reason}
+		if err := recordAuditEvent(tx, audit, models.AuditActionImpersonate, models.AuditOutcomeSuccess, &user, metadata); err != nil {
+			return err
+		}
+		return enqueueSecurityAlert(tx, user, models.OutboxKindAlertImpersonated, NotificationContext{}, service.alertTTL)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accessClaims := newAccessTokenClaims(user, session.UUID, scopes, service.impersonationTTL)
+	accessClaims.Act = &actorClaim{Subject: staff.UUID, Email: staff.Email}
+	accessToken := service.signToken(service.newTokenWithClaims(jwt.SigningMethodHS256, accessClaims))
+
+	return &AuthTokens{accessToken, "", service.impersonationTTL, ""}, nil
+}
+
+// Returns the staff user who acts as the user of the given access token, or
+// nil if the token is not valid or it is not an impersonation token
+func (service AuthService) GetTokenImpersonator(accessToken string) *models.User {
+	accessClaims := &accessTokenClaims{}
+	if err := service.getClaims(accessToken, accessClaims, true); err != nil || accessClaims.Act == nil {
+		return nil
+	}
+	staff, err := readImpersonator(service.db, accessClaims.Act.Subject)
+	if err != nil {
+		return nil
+	}
+	return staff
+}
+
+// Records in the audit log the given request the given staff user made
+// while acting as the given user
+func (service AuthService) RecordImpersonatedRequest(staff models.User, user models.User, request string, client helpers.ClientInfo) error {
+	audit := AuditContext{Actor: &staff, Client: client}
+	metadata := models.AuditMetadata{"request": request}
+	return recordAuditEvent(service.db, audit, models.AuditActionImpersonated, models.AuditOutcomeSuccess, &user, metadata)
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"encoding/json"
 	"gandalf/bindings"
+	"gandalf/helpers"
 	"gandalf/models"
 	"gandalf/security"
 	"gandalf/tests"
@@ -47,7 +49,7 @@ func TestAuthService(t *testing.T) {
 		user.UUID, _ = uuid.NewV4()
 		scopes := []string{security.ScopeUserRead}
 		mockToken := authService.signToken(authService.newTokenWithClaims(
-			jwt.SigningMethodHS256, newAccessTokenClaims(user, scopes, authService.tokenTTL),
+			jwt.SigningMethodHS256, newAccessTokenClaims(user, uuid.Nil, scopes, authService.tokenTTL),
 		))
 
 		claims := &accessTokenClaims{}
@@ -73,7 +75,7 @@ func TestAuthService(t *testing.T) {
 		user.UUID, _ = uuid.NewV4()
 		scopes := []string{security.ScopeUserRead}
 		mockToken := authService.signToken(authService.newTokenWithClaims(
-			jwt.SigningMethodHS256, newAccessTokenClaims(user, scopes, authService.tokenTTL),
+			jwt.SigningMethodHS256, newAccessTokenClaims(user, uuid.Nil, scopes, authService.tokenTTL),
 		))
 
 		claims := &accessTokenClaims{}
@@ -94,7 +96,7 @@ func TestAuthService(t *testing.T) {
 
 		assert.NotPanics(func() {
 			authService.signToken(authService.newTokenWithClaims(
-				jwt.SigningMethodHS256, newAccessTokenClaims(user, scopes, authService.tokenTTL),
+				jwt.SigningMethodHS256, newAccessTokenClaims(user, uuid.Nil, scopes, authService.tokenTTL),
 			))
 		})
 	})
@@ -110,7 +112,7 @@ func TestAuthService(t *testing.T) {
 
 		assert.Panics(func() {
 			authService.signToken(authService.newTokenWithClaims(
-				jwt.SigningMethodHS256, newAccessTokenClaims(user, scopes, authService.tokenTTL),
+				jwt.SigningMethodHS256, newAccessTokenClaims(user, uuid.Nil, scopes, authService.tokenTTL),
 			))
 		})
 	})
@@ -129,7 +131,7 @@ func TestAuthService(t *testing.T) {
 			Password: plainPassword,
 		}
 
-		authenticatedUser, err := authService.Authenticate(credentials, false)
+		authenticatedUser, err := authService.Authenticate(credentials, false, helpers.ClientInfo{})
 
 		assert.NoError(err)
 		assert.Equal(authenticatedUser.UUID, user.UUID)
@@ -147,7 +149,7 @@ func TestAuthService(t *testing.T) {
 			Password: plainPassword,
 		}
 
-		_, err := authService.Authenticate(credentials, false)
+		_, err := authService.Authenticate(credentials, false, helpers.ClientInfo{})
 
 		assert.Error(err, AuthenticationError{nil}.Error())
 	})
@@ -165,7 +167,7 @@ func TestAuthService(t *testing.T) {
 			Password: plainInventedPassword,
 		}
 
-		_, err := authService.Authenticate(credentials, false)
+		_, err := authService.Authenticate(credentials, false, helpers.ClientInfo{})
 
 		assert.Error(err, AuthenticationError{nil}.Error())
 		db.Unscoped().Delete(&user)
@@ -184,7 +186,7 @@ func TestAuthService(t *testing.T) {
 	t.Run("Test GetAuthorizedUser successfully", func(t *testing.T) {
 		db := tests.NewTestDatabase(false)
 		authService := NewAuthService(db)
-		scopes := []string{security.ScopeUserRead, security.ScopeUserVerify}
+		scopes := []string{security.ScopeUserRead}
 		user := tests.UserFactory()
 		user.Verified = true
 		db.Create(&user)
@@ -239,6 +241,64 @@ func TestAuthService(t *testing.T) {
 		assert.Error(err, AuthenticationError{nil}.Error())
 	})
 
+	t.Run("Test GetAuthorizedUser staff scopes granted by roles", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		authService := NewAuthService(db)
+		roleService := RoleService{db}
+		scopes := []string{security.ScopeUserReadAll}
+		role := tests.RoleFactory(security.ScopeUserReadAll)
+		user := tests.UserFactory()
+		user.Verified = true
+		user.Staff = true
+		db.Create(&role)
+		db.Create(&user)
+		roleService.Assign(user, role.Name)
+
+		tokens := authService.GenerateTokens(user, scopes)
+		authorizedUser, err := authService.GetAuthorizedUser(tokens.AccessToken, scopes)
+
+		assert.NoError(err)
+		assert.Equal(authorizedUser.UUID, user.UUID)
+
+		roleService.Revoke(user, role.Name)
+		_, err = authService.GetAuthorizedUser(tokens.AccessToken, scopes)
+
+		assert.Error(err, AuthorizationError{nil}.Error())
+
+		db.Unscoped().Delete(&user)
+		db.Unscoped().Delete(&role)
+	})
+
+	t.Run("Test session tokens stop working once revoked", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		authService := NewAuthService(db)
+		sessionService := SessionService{db}
+		scopes := []string{security.ScopeUserRead}
+		user := tests.UserFactory()
+		user.Verified = true
+		db.Create(&user)
+
+		tokens, err := authService.StartSession(user, helpers.ClientInfo{UserAgent: "agent", IP: "127.0.0.1"}, scopes)
+		assert.NoError(err)
+
+		_, err = authService.GetAuthorizedUser(tokens.AccessToken, scopes)
+		assert.NoError(err)
+		_, err = authService.RefreshToken(tokens.AccessToken, tokens.RefreshToken)
+		assert.NoError(err)
+
+		session, err := sessionService.Read(user, authService.GetTokenSession(tokens.AccessToken))
+		assert.NoError(err)
+		assert.Equal("agent", session.UserAgent)
+		sessionService.Revoke(*session)
+
+		_, err = authService.GetAuthorizedUser(tokens.AccessToken, scopes)
+		assert.Error(err, AuthorizationError{nil}.Error())
+		_, err = authService.RefreshToken(tokens.AccessToken, tokens.RefreshToken)
+		assert.Error(err, AuthenticationError{nil}.Error())
+
+		db.Unscoped().Delete(&user)
+	})
+
 	t.Run("Test RefreshToken successfully", func(t *testing.T) {
 		db := tests.NewTestDatabase(true)
 		authService := NewAuthService(db)
@@ -297,7 +357,7 @@ func TestAppServiceAuthorize(t *testing.T) {
 			State:       "state",
 		}
 
-		service.Authorize(&app, &user, input)
+		service.Authorize(&app, &user, input, helpers.ClientInfo{}, uuid.Nil)
 		assert.Equal(1, len(user.ConnectedApps))
 		assert.Equal(1, len(app.ConnectedUsers))
 		assert.Equal(user.ID, app.ConnectedUsers[0].ID)
@@ -328,12 +388,40 @@ func TestAppServiceAuthorize(t *testing.T) {
 			State:       "state",
 		}
 
-		_, err := service.Authorize(&app, &user, input)
+		_, err := service.Authorize(&app, &user, input, helpers.ClientInfo{}, uuid.Nil)
 		assert.Error(err, expectedError, expectedError.Error())
 
 		db.Delete(&app)
 		db.Delete(&user)
 	})
+
+	t.Run("Test SAML apps are not authorized", func(t *testing.T) {
+		service := NewAuthService(nil)
+		app := tests.AppFactory()
+		app.Kind = models.AppKindSAML
+		user := tests.UserFactory()
+		input := validators.OauthAuthorizeData{ClientID: app.ClientID.String(), RedirectURI: app.RedirectUrls[0]}
+
+		_, err := service.Authorize(&app, &user, input, helpers.ClientInfo{}, uuid.Nil)
+
+		assert.IsType(AppProtocolError{}, err)
+	})
+
+	t.Run("Test apps restricted by age", func(t *testing.T) {
+		service := NewAuthService(nil)
+		app := tests.AppFactory()
+		app.MinimumAge = 18
+		user := tests.UserFactory()
+		user.Birthday = bindings.BirthDate(time.Now().AddDate(-16, 0, 0))
+		input := validators.OauthAuthorizeData{ClientID: app.ClientID.String(), RedirectURI: app.RedirectUrls[0]}
+
+		_, err := service.Authorize(&app, &user, input, helpers.ClientInfo{}, uuid.Nil)
+		assert.IsType(AppAgeRestrictedError{}, err)
+
+		user.Birthday = bindings.BirthDate{}
+		_, err = service.Authorize(&app, &user, input, helpers.ClientInfo{}, uuid.Nil)
+		assert.IsType(AppAgeRestrictedError{}, err)
+	})
 }
 
 func TestAppServiceExchangeOauthToken(t *testing.T) {
@@ -367,7 +455,7 @@ func TestAppServiceExchangeOauthToken(t *testing.T) {
 		)
 		db.Create(&claim)
 
-		resultTokens, err := service.ExchangeOauthToken(app, data)
+		resultTokens, err := service.ExchangeOauthToken(app, data, helpers.ClientInfo{})
 
 		assert.Nil(err)
 		assert.NotNil(resultTokens)
@@ -406,7 +494,7 @@ func TestAppServiceExchangeOauthToken(t *testing.T) {
 		)
 		db.Create(&claim)
 
-		_, err := service.ExchangeOauthToken(app, data)
+		_, err := service.ExchangeOauthToken(app, data, helpers.ClientInfo{})
 
 		assert.Error(expectedError, err)
 
@@ -444,7 +532,7 @@ func TestAppServiceExchangeOauthToken(t *testing.T) {
 		)
 		db.Create(&claim)
 
-		_, err := service.ExchangeOauthToken(app, data)
+		_, err := service.ExchangeOauthToken(app, data, helpers.ClientInfo{})
 
 		assert.Error(expectedError, err)
 
@@ -473,7 +561,7 @@ func TestAppServiceExchangeOauthToken(t *testing.T) {
 			RedirectUrl:       app.RedirectUrls[0],
 		}
 
-		_, err := service.ExchangeOauthToken(app, data)
+		_, err := service.ExchangeOauthToken(app, data, helpers.ClientInfo{})
 
 		assert.Error(err, expectedError.Error())
 
@@ -481,3 +569,130 @@ func TestAppServiceExchangeOauthToken(t *testing.T) {
 		db.Delete(&user)
 	})
 }
+
+func TestAuthServiceClaimMapping(t *testing.T) {
+	assert := require.New(t)
+	user := tests.UserFactory()
+	user.Attributes = models.UserAttributes{"employee_id": "E-1234", "plan": "pro"}
+	app := tests.AppFactory()
+	app.ClaimMapping = models.ClaimMapping{
+		{Claim: "employee", Attribute: "employee_id", Tokens: []string{models.ClaimTokenAccess}},
+		{Claim: "plan", Attribute: "plan", Tokens: []string{models.ClaimTokenID}},
+	}
+
+	t.Run("Test mapped attributes are kept when the access token is refreshed", func(t *testing.T) {
+		service := NewAuthService(nil)
+		service.tokenTTL = 60
+		service.tokenRTTL = 60
+		tokens := service.generateTokens(user, uuid.Nil, []string{security.ScopeUserRead}, app.MappedClaims(user, models.ClaimTokenAccess))
+
+		refreshed, err := service.RefreshToken(tokens.AccessToken, tokens.RefreshToken)
+		assert.NoError(err)
+
+		claims := &accessTokenClaims{}
+		assert.NoError(service.getClaims(refreshed.AccessToken, claims, true))
+		assert.Equal(map[string]interface{}{"employee": "E-1234"}, claims.Attributes)
+	})
+
+	t.Run("Test mapped attributes are top level claims of the id token", func(t *testing.T) {
+		claims := newIDTokenClaims("https://gandalf.test", user, app, models.Session{}, 60)
+		claims.Extra["email"] = "spoofed@example.com"
+
+		body, err := json.Marshal(claims)
+		assert.NoError(err)
+		var decoded map[string]interface{}
+		assert.NoError(json.Unmarshal(body, &decoded))
+		assert.Equal("pro", decoded["plan"])
+		assert.Equal(user.Email, decoded["email"])
+		assert.NotContains(decoded, "employee")
+	})
+}
+
+func TestAuthServiceImpersonation(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test impersonation tokens cannot be refreshed", func(t *testing.T) {
+		service := NewAuthService(nil)
+		service.tokenTTL = 60
+		service.tokenRTTL = 60
+		user := tests.UserFactory()
+		staff := tests.UserFactory()
+		tokens := service.generateTokens(user, uuid.Nil, []string{security.ScopeUserRead}, nil)
+
+		claims := &accessTokenClaims{}
+		assert.NoError(service.getClaims(tokens.AccessToken, claims, true))
+		claims.Act = &actorClaim{Subject: staff.UUID, Email: staff.Email}
+		accessToken := service.signToken(service.newTokenWithClaims(jwt.SigningMethodHS256, claims))
+
+		_, err := service.RefreshToken(accessToken, tokens.RefreshToken)
+		assert.Error(err, AuthenticationError{}.Error())
+	})
+
+	t.Run("Test impersonate an user", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		authService := NewAuthService(db)
+		roleService := RoleService{db}
+		userRole := tests.RoleFactory(security.ScopeUserRead, security.ScopeUserWrite, security.ScopeUserDelete, security.ScopeUserAuthorizeApp, security.ScopeUserExport)
+		supportRole := tests.RoleFactory(security.ScopeUserImpersonateAll)
+		user := tests.UserFactory()
+		user.Verified = true
+		staff := tests.UserFactory()
+		staff.Verified = true
+		staff.Staff = true
+		db.Create(&userRole)
+		db.Create(&supportRole)
+		db.Create(&user)
+		db.Create(&staff)
+		roleService.Assign(user, userRole.Name)
+		roleService.Assign(staff, supportRole.Name)
+
+		tokens, err := authService.Impersonate(staff, user, "Reproduce a login issue", helpers.ClientInfo{IP: "127.0.0.1"})
+		assert.NoError(err)
+		assert.Empty(tokens.RefreshToken)
+
+		claims := &accessTokenClaims{}
+		assert.NoError(authService.getClaims(tokens.AccessToken, claims, true))
+		assert.Equal(user.UUID, claims.UUID)
+		assert.Equal(staff.UUID, claims.Act.Subject)
+		assert.ElementsMatch([]string{security.ScopeUserRead, security.ScopeUserWrite}, claims.Scopes)
+		assert.Equal(staff.UUID, authService.GetTokenImpersonator(tokens.AccessToken).UUID)
+
+		alerts := readSecurityAlerts(db, user)
+		assert.Equal(1, len(alerts))
+		assert.Equal(models.OutboxKindAlertImpersonated, alerts[0].Alert)
+
+		var event models.AuditEvent
+		db.Where("action = ? AND subject_id = ?", models.AuditActionImpersonate, user.ID).First(&event)
+		assert.Equal("Reproduce a login issue", event.Metadata["reason"])
+
+		_, err = authService.GetAuthorizedUser(tokens.AccessToken, []string{security.ScopeUserDelete})
+		assert.Error(err, AuthorizationError{}.Error())
+		_, err = authService.GetAuthorizedUser(tokens.AccessToken, []string{security.ScopeUserAuthorizeApp})
+		assert.Error(err, AuthorizationError{}.Error())
+		_, err = authService.GetAuthorizedUser(tokens.AccessToken, []string{security.ScopeUserRead})
+		assert.NoError(err)
+
+		roleService.Revoke(staff, supportRole.Name)
+		_, err = authService.GetAuthorizedUser(tokens.AccessToken, []string{security.ScopeUserRead})
+		assert.Error(err, AuthorizationError{}.Error())
+
+		db.Unscoped().Delete(&user)
+		db.Unscoped().Delete(&staff)
+		db.Unscoped().Delete(&userRole)
+		db.Unscoped().Delete(&supportRole)
+	})
+
+	t.Run("Test staff users cannot be impersonated", func(t *testing.T) {
+		service := NewAuthService(nil)
+		staff := tests.UserFactory()
+		staff.ID = 1
+		other := tests.UserFactory()
+		other.ID = 2
+		other.Staff = true
+
+		_, err := service.Impersonate(staff, other, "Reproduce a login issue", helpers.ClientInfo{})
+		assert.Error(err, ImpersonationError{}.Error())
+		_, err = service.Impersonate(staff, staff, "Reproduce a login issue", helpers.ClientInfo{})
+		assert.Error(err, ImpersonationError{}.Error())
+	})
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"fmt"
+	"gandalf/models"
+	"gandalf/validators"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Credential backends which can be chained through AUTH_BACKENDS
+const (
+	CredentialBackendDatabase = "database"
+	CredentialBackendLDAP     = "ldap"
+)
+
+// Credential backend interface, implemented by every store the passwords
+// of the users can be verified against
+type ICredentialBackend interface {
+	// Returns the user the given credentials belong to. When the user is
+	// known but the credentials are wrong, he is returned along with the
+	// error, so the failure can be recorded on his behalf.
+	Verify(credentials validators.Credentials, isStaff bool) (*models.User, error)
+}
+
+// Database backend verifies the credentials against the bcrypt hash of the
+// password stored for the user
+type DatabaseCredentialBackend struct {
+	db            *gorm.DB
+	deletionGrace time.Duration `env:"ACCOUNT_DELETION_GRACE"`
+}
+
+// Creates a new database backend
+func NewDatabaseCredentialBackend(db *gorm.DB) DatabaseCredentialBackend {
+	return DatabaseCredentialBackend{db: db, deletionGrace: accountDeletionGrace()}
+}
+
+// Verifies the given credentials against the stored password of a verified
+// and enabled user. Deleted users are verified too during the grace period
+// of their deletion, so they can restore their account by logging in.
+func (backend DatabaseCredentialBackend) Verify(credentials validators.Credentials, isStaff bool) (*models.User, error) {
+	var user models.User
+	query := backend.db.Unscoped().Where(&models.User{Email: credentials.Email, Verified: true, Staff: isStaff})
+	if err := query.Where("disabled = ? AND purged_at IS NULL", false).First(&user).Error; err != nil {
+		return nil, AuthenticationError{err}
+	}
+	if user.DeletedAt.Valid && !user.IsRestorable(backend.deletionGrace) {
+		return nil, AuthenticationError{nil}
+	}
+
+	if !user.VerifyPassword(credentials.Password) {
+		return &user, AuthenticationError{nil}
+	}
+	return &user, nil
+}
+
+// Creates the chain of backends configured through AUTH_BACKENDS, a comma
+// separated list which only holds the database backend by default. Panics
+// on unknown backends, since nobody could log in.
+func NewCredentialBackends(db *gorm.DB) []ICredentialBackend {
+	names := os.Getenv("AUTH_BACKENDS")
+	if names == "" {
+		names = CredentialBackendDatabase
+	}
+
+	backends := []ICredentialBackend{}
+	for _, name := range strings.Split(names, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case CredentialBackendDatabase:
+			backends = append(backends, NewDatabaseCredentialBackend(db))
+		case CredentialBackendLDAP:
+			backends = append(backends, NewLDAPCredentialBackend(db))
+		default:
+			panic(fmt.Sprintf("unknown auth backend %s", name))
+		}
+	}
+	return backends
+}
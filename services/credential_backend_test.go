@@ -0,0 +1,39 @@
+package services
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCredentialBackends(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test database backend is used by default", func(t *testing.T) {
+		os.Unsetenv("AUTH_BACKENDS")
+
+		backends := NewCredentialBackends(nil)
+
+		assert.Equal(1, len(backends))
+		assert.IsType(DatabaseCredentialBackend{}, backends[0])
+	})
+
+	t.Run("Test backends are chained in the given order", func(t *testing.T) {
+		os.Setenv("AUTH_BACKENDS", "ldap, database")
+		defer os.Unsetenv("AUTH_BACKENDS")
+
+		backends := NewCredentialBackends(nil)
+
+		assert.Equal(2, len(backends))
+		assert.IsType(LDAPCredentialBackend{}, backends[0])
+		assert.IsType(DatabaseCredentialBackend{}, backends[1])
+	})
+
+	t.Run("Test unknown backends panic", func(t *testing.T) {
+		os.Setenv("AUTH_BACKENDS", "kerberos")
+		defer os.Unsetenv("AUTH_BACKENDS")
+
+		assert.Panics(func() { NewCredentialBackends(nil) })
+	})
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"encoding/json"
+	"gandalf/bindings"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/validators"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Number of pending data exports built on every run
+const dataExportBuildBatch = 10
+
+// Interface for data export service
+type IDataExportService interface {
+	Request(user models.User, client helpers.ClientInfo) (*models.DataExport, error)
+	Read(user models.User, uuid uuid.UUID) (*models.DataExport, error)
+	Archive(export models.DataExport) (string, error)
+	BuildPending()
+}
+
+// Data export service builds the archives with everything gandalf knows
+// about the users who ask for it. Archives are built in background, and
+// the user is notified once his archive can be downloaded.
+type DataExportService struct {
+	db  *gorm.DB
+	ttl time.Duration `env:"DATA_EXPORT_TTL"`
+}
+
+// Creates a new data export service
+func NewDataExportService(db *gorm.DB) DataExportService {
+	return DataExportService{
+		db:  db,
+		ttl: time.Duration(helpers.GetEnvInt("DATA_EXPORT_TTL", 48)) * time.Hour,
+	}
+}
+
+// Archive with the data of an user, as it is downloaded
+type DataExportArchive struct {
+	ExportedAt    time.Time                `json:"exported_at"`
+	Profile       dataExportProfile        `json:"profile"`
+	Apps          []dataExportApp          `json:"apps"`
+	ConnectedApps []dataExportConnectedApp `json:"connected_apps"`
+	Claims        []dataExportClaim        `json:"claims"`
+	Sessions      []dataExportSession      `json:"sessions"`
+	AuditEvents   []dataExportAuditEvent   `json:"audit_events"`
+}
+
+type dataExportProfile struct {
+	UUID                uuid.UUID          `json:"uuid"`
+	Email               string             `json:"email"`
+	Name                string             `json:"name"`
+	Surname             string             `json:"surname"`
+	Birthday            bindings.BirthDate `json:"birthday"`
+	Locale              string             `json:"locale"`
+	Phone               string             `json:"phone"`
+	PhoneVerified       bool               `json:"phone_verified"`
+	PhoneMFA            bool               `json:"phone_mfa"`
+	Verified            bool               `json:"verified"`
+	Staff               bool               `json:"staff"`
+	Disabled            bool               `json:"disabled"`
+	ExternalID          string             `json:"external_id"`
+	AppAuthorizedAlerts bool               `json:"app_authorized_alerts"`
+	NewDeviceAlerts     bool               `json:"new_device_alerts"`
+	Roles               []string           `json:"roles"`
+	LastLogin           time.Time          `json:"last_login"`
+	CreatedAt           time.Time          `json:"created_at"`
+	UpdatedAt           time.Time          `json:"updated_at"`
+}
+
+type dataExportApp struct {
+	UUID         uuid.UUID `json:"uuid"`
+	Name         string    `json:"name"`
+	Kind         string    `json:"kind"`
+	ClientID     uuid.UUID `json:"client_id"`
+	RedirectUrls []string  `json:"redirect_urls"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type dataExportConnectedApp struct {
+	ClientID uuid.UUID `json:"client_id"`
+	Name     string    `json:"name"`
+	Scopes   []string  `json:"scopes"`
+}
+
+type dataExportClaim struct {
+	ClientID    uuid.UUID `json:"client_id"`
+	RedirectUrl string    `json:"redirect_url"`
+	Scopes      []string  `json:"scopes"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type dataExportSession struct {
+	UUID       uuid.UUID  `json:"uuid"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	App        string     `json:"app,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type dataExportAuditEvent struct {
+	UUID      uuid.UUID            `json:"uuid"`
+	Action    string               `json:"action"`
+	Outcome   string               `json:"outcome"`
+	IP        string               `json:"ip"`
+	UserAgent string               `json:"user_agent"`
+	Metadata  models.AuditMetadata `json:"metadata"`
+	Actor     *uuid.UUID           `json:"actor"`
+	Subject   *uuid.UUID           `json:"subject"`
+	CreatedAt time.Time            `json:"created_at"`
+}
+
+// Requests an export of the data of the given user. The export pending to
+// be built is returned if there is one, so asking again does not queue
+// more work.
+func (service DataExportService) Request(user models.User, client helpers.ClientInfo) (*models.DataExport, error) {
+	var export models.DataExport
+	query := &models.DataExport{UserID: user.ID, Status: models.DataExportPending}
+	if err := service.db.Where(query).First(&export).Error; err == nil {
+		return &export, nil
+	}
+
+	export = models.NewDataExport(user)
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&export).Error; err != nil {
+			return DataExportRequestError{err}
+		}
+		audit := AuditContext{Actor: &user, Client: client}
+		metadata := models.AuditMetadata{"export": export.UUID.String()}
+		return recordAuditEvent(tx, audit, models.AuditActionExportData, models.AuditOutcomeSuccess, &user, metadata)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// Reads the export of the given user with the given uuid
+func (service DataExportService) Read(user models.User, uuid uuid.UUID) (*models.DataExport, error) {
+	var export models.DataExport
+	query := &models.DataExport{UUID: uuid, UserID: user.ID}
+	if err := service.db.Where(query).First(&export).Error; err != nil {
+		return nil, DataExportNotFoundError{err}
+	}
+	return &export, nil
+}
+
+// Returns the archive of the given export, as long as it has been built
+// and it has not expired
+func (service DataExportService) Archive(export models.DataExport) (string, error) {
+	if export.Status == models.DataExportPending {
+		return "", DataExportNotReadyError{}
+	}
+	if !export.IsAvailable() {
+		return "", DataExportExpiredError{}
+	}
+	return export.Archive, nil
+}
+
+// Builds the pending exports and wipes the archives of the expired ones
+func (service DataExportService) BuildPending() {
+	var exports []models.DataExport
+	users := service.db.Model(&models.User{}).Select("id")
+	service.db.Preload("User").Where(&models.DataExport{Status: models.DataExportPending}).
+		Where("user_id IN (?)", users).Order("id").Limit(dataExportBuildBatch).Find(&exports)
+
+	for i := range exports {
+		if err := service.build(&exports[i]); err != nil {
+			log.Println("Data export", exports[i].UUID, "cannot be built:", err)
+		}
+	}
+
+	service.db.Model(&models.DataExport{}).
+		Where("status = ? AND expires_at <= ?", models.DataExportReady, time.Now()).
+		Updates(map[string]interface{}{"status": models.DataExportExpired, "archive": ""})
+}
+
+// Builds the archive of the given export and enqueues the email which
+// tells the user where to download it from
+func (service DataExportService) build(export *models.DataExport) error {
+	archive, err := collectDataExportArchive(service.db, export.User)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(archive)
+	if err != nil {
+		return err
+	}
+
+	user := export.User
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		export.Complete(string(content), service.ttl)
+		if err := tx.Omit("User").Save(export).Error; err != nil {
+			return err
+		}
+
+		link := notificationLink(os.Getenv("DATA_EXPORT_URL"), map[string]string{"export": export.UUID.String()})
+		rendered, err := renderNotification(tx, models.OutboxKindUserDataExport, user.Locale, nil, NotificationContext{
+			Name:  user.Name,
+			Email: user.Email,
+			Link:  link,
+		})
+		if err != nil {
+			return OutboxEnqueueError{err}
+		}
+
+		return enqueueNotification(tx, models.OutboxKindUserDataExport, user.Email, validators.PelipperUserDataExport{
+			Email:      user.Email,
+			Name:       user.Name,
+			Subject:    rendered.Subject,
+			ExportLink: link,
+			Locale:     rendered.Locale,
+			Text:       rendered.Text,
+			HTML:       rendered.HTML,
+		})
+	})
+}
+
+// Collects everything stored about the given user. Secrets, like the
+// password hash or the authorization codes, are left out.
+func collectDataExportArchive(db *gorm.DB, user models.User) (*DataExportArchive, error) {
+	if err := db.Preload("Roles").Preload("Apps").Preload("ConnectedApps").First(&user, user.ID).Error; err != nil {
+		return nil, UserNotFoundError{err}
+	}
+
+	archive := DataExportArchive{
+		ExportedAt: time.Now(),
+		Profile: dataExportProfile{
+			UUID:                user.UUID,
+			Email:               user.Email,
+			Name:                user.Name,
+			Surname:             user.Surname,
+			Birthday:            user.Birthday,
+			Locale:              user.Locale,
+			Phone:               user.Phone,
+			PhoneVerified:       user.PhoneVerified,
+			PhoneMFA:            user.PhoneMFA,
+			Verified:            user.Verified,
+			Staff:               user.Staff,
+			Disabled:            user.Disabled,
+			ExternalID:          user.ExternalID,
+			AppAuthorizedAlerts: user.AppAuthorizedAlerts,
+			NewDeviceAlerts:     user.NewDeviceAlerts,
+			Roles:               []string{},
+			LastLogin:           user.LastLogin,
+			CreatedAt:           user.CreatedAt,
+			UpdatedAt:           user.UpdatedAt,
+		},
+		Apps:          []dataExportApp{},
+		ConnectedApps: []dataExportConnectedApp{},
+		Claims:        []dataExportClaim{},
+		Sessions:      []dataExportSession{},
+		AuditEvents:   []dataExportAuditEvent{},
+	}
+	for _, role := range user.Roles {
+		archive.Profile.Roles = append(archive.Profile.Roles, role.Name)
+	}
+	for _, app := range user.Apps {
+		archive.Apps = append(archive.Apps, dataExportApp{
+			UUID:         app.UUID,
+			Name:         app.Name,
+			Kind:         app.Kind,
+			ClientID:     app.ClientID,
+			RedirectUrls: app.RedirectUrls,
+			CreatedAt:    app.CreatedAt,
+		})
+	}
+
+	var claims []models.Claim
+	db.Preload("App").Where(&models.Claim{UserID: user.ID}).Order("id").Find(&claims)
+	granted := map[uint]pq.StringArray{}
+	for _, claim := range claims {
+		archive.Claims = append(archive.Claims, dataExportClaim{
+			ClientID:    claim.App.ClientID,
+			RedirectUrl: claim.RedirectUrl,
+			Scopes:      claim.Scopes,
+			CreatedAt:   claim.CreatedAt,
+		})
+		granted[claim.AppID] = mergeScopes(granted[claim.AppID], claim.Scopes)
+	}
+	for _, app := range user.ConnectedApps {
+		scopes := granted[app.ID]
+		if scopes == nil {
+			scopes = []string{}
+		}
+		archive.ConnectedApps = append(archive.ConnectedApps, dataExportConnectedApp{
+			ClientID: app.ClientID,
+			Name:     app.Name,
+			Scopes:   scopes,
+		})
+	}
+
+	var sessions []models.Session
+	db.Preload("App").Where(&models.Session{UserID: user.ID}).Order("id").Find(&sessions)
+	for _, session := range sessions {
+		exported := dataExportSession{
+			UUID:       session.UUID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			RevokedAt:  session.RevokedAt,
+		}
+		if session.App != nil {
+			exported.App = session.App.Name
+		}
+		archive.Sessions = append(archive.Sessions, exported)
+	}
+
+	var events []models.AuditEvent
+	db.Preload("Actor").Preload("Subject").
+		Where("actor_id = ? OR subject_id = ?", user.ID, user.ID).
+		Order("created_at, id").Find(&events)
+	for _, event := range events {
+		exported := dataExportAuditEvent{
+			UUID:      event.UUID,
+			Action:    event.Action,
+			Outcome:   event.Outcome,
+			IP:        event.IP,
+			UserAgent: event.UserAgent,
+			Metadata:  event.Metadata,
+			CreatedAt: event.CreatedAt,
+		}
+		if event.Actor != nil {
+			exported.Actor = &event.Actor.UUID
+		}
+		if event.Subject != nil {
+			exported.Subject = &event.Subject.UUID
+		}
+		archive.AuditEvents = append(archive.AuditEvents, exported)
+	}
+
+	return &archive, nil
+}
+
+// Adds to the given scopes the missing ones among the granted
+func mergeScopes(scopes pq.StringArray, granted pq.StringArray) pq.StringArray {
+	for _, scope := range granted {
+		if !helpers.PqStringArrayContains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
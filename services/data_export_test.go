@@ -0,0 +1,130 @@
+package services
+
+import (
+	"encoding/json"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/tests"
+	"gandalf/validators"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataExportServiceArchive(t *testing.T) {
+	assert := require.New(t)
+	service := DataExportService{ttl: time.Hour}
+
+	t.Run("Test pending exports cannot be downloaded", func(t *testing.T) {
+		_, err := service.Archive(models.DataExport{Status: models.DataExportPending})
+
+		assert.IsType(DataExportNotReadyError{}, err)
+	})
+
+	t.Run("Test expired exports cannot be downloaded", func(t *testing.T) {
+		export := models.DataExport{Status: models.DataExportPending}
+		export.Complete("{}", -time.Minute)
+
+		_, err := service.Archive(export)
+		assert.IsType(DataExportExpiredError{}, err)
+
+		_, err = service.Archive(models.DataExport{Status: models.DataExportExpired})
+		assert.IsType(DataExportExpiredError{}, err)
+	})
+
+	t.Run("Test ready exports are downloaded", func(t *testing.T) {
+		export := models.DataExport{Status: models.DataExportPending}
+		export.Complete(`{"profile": {}}`, time.Hour)
+
+		archive, err := service.Archive(export)
+		assert.NoError(err)
+		assert.Equal(`{"profile": {}}`, archive)
+	})
+
+	t.Run("Test granted scopes are merged", func(t *testing.T) {
+		scopes := mergeScopes(pq.StringArray{"user:me:read"}, pq.StringArray{"user:me:read", "user:me:write"})
+
+		assert.Equal(pq.StringArray{"user:me:read", "user:me:write"}, scopes)
+	})
+}
+
+func TestDataExportService(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test exports are built and notified", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewDataExportService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+		app := tests.AppFactory()
+		app.UserID = user.ID
+		db.Create(&app)
+		db.Model(&user).Association("ConnectedApps").Append(&app)
+		claim := models.NewClaim("https://example.com", "code", []string{"user:me:read"}, user, app)
+		db.Create(&claim)
+
+		export, err := service.Request(user, helpers.ClientInfo{})
+		assert.NoError(err)
+		again, err := service.Request(user, helpers.ClientInfo{})
+		assert.NoError(err)
+		assert.Equal(export.ID, again.ID)
+
+		service.BuildPending()
+
+		export, err = service.Read(user, export.UUID)
+		assert.NoError(err)
+		assert.Equal(models.DataExportReady, export.Status)
+		content, err := service.Archive(*export)
+		assert.NoError(err)
+		var archive DataExportArchive
+		assert.NoError(json.Unmarshal([]byte(content), &archive))
+		assert.Equal(user.Email, archive.Profile.Email)
+		assert.Len(archive.Apps, 1)
+		assert.Equal([]string{"user:me:read"}, archive.ConnectedApps[0].Scopes)
+		assert.Len(archive.Claims, 1)
+		assert.NotEmpty(archive.AuditEvents)
+
+		var message models.OutboxMessage
+		db.Where(&models.OutboxMessage{Kind: models.OutboxKindUserDataExport, Recipient: user.Email}).Last(&message)
+		var payload validators.PelipperUserDataExport
+		json.Unmarshal([]byte(message.Payload), &payload)
+		assert.Contains(payload.ExportLink, export.UUID.String())
+		db.Unscoped().Delete(&user)
+	})
+
+	t.Run("Test exports of other users are not found", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewDataExportService(db)
+		user := tests.UserFactory()
+		other := tests.UserFactory()
+		db.Create(&user)
+		db.Create(&other)
+
+		export, err := service.Request(user, helpers.ClientInfo{})
+		assert.NoError(err)
+
+		_, err = service.Read(other, export.UUID)
+		assert.IsType(DataExportNotFoundError{}, err)
+		db.Unscoped().Delete(&user)
+		db.Unscoped().Delete(&other)
+	})
+
+	t.Run("Test expired archives are wiped", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewDataExportService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+		export := models.NewDataExport(user)
+		export.Complete("{}", -time.Minute)
+		db.Create(&export)
+
+		service.BuildPending()
+
+		db.First(&export, export.ID)
+		assert.Equal(models.DataExportExpired, export.Status)
+		assert.Empty(export.Archive)
+		db.Unscoped().Delete(&user)
+	})
+}
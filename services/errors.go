@@ -1,6 +1,9 @@
 package services
 
-import "fmt"
+import (
+	"fmt"
+	"gandalf/models"
+)
 
 // This error will be returned on user authentication failure
 type AuthenticationError struct {
@@ -29,6 +32,15 @@ func (e UserCreateError) Error() string {
 	return "User email already registered"
 }
 
+// This error will be returned when a deleted user cannot be purged
+type UserPurgeError struct {
+	raisedFrom error
+}
+
+func (e UserPurgeError) Error() string {
+	return "User cannot be purged"
+}
+
 // This error will be returned on user creation failure.
 type AppCreateError struct {
 	raisedFrom error
@@ -38,6 +50,26 @@ func (e AppCreateError) Error() string {
 	return "App cannot be created"
 }
 
+// This error will be returned when another SAML app has already registered
+// the given entity id
+type AppEntityIDTakenError struct {
+	raisedFrom error
+}
+
+func (e AppEntityIDTakenError) Error() string {
+	return "Entity id is already registered by another app"
+}
+
+// This error will be returned when an app is used through a protocol it
+// has not been registered for
+type AppProtocolError struct {
+	raisedFrom error
+}
+
+func (e AppProtocolError) Error() string {
+	return "App does not support this protocol"
+}
+
 // This error will be returned on app not found exception
 type AppNotFoundError struct {
 	raisedFrom error
@@ -74,3 +106,634 @@ type ClaimDoesNotExist struct {
 func (e ClaimDoesNotExist) Error() string {
 	return "Claim does not exist"
 }
+
+// This error will be returned when a one time token cannot be issued
+type OneTimeTokenIssueError struct {
+	raisedFrom error
+}
+
+func (e OneTimeTokenIssueError) Error() string {
+	return "Token cannot be issued"
+}
+
+// This error will be returned when a one time token does not exist, has
+// expired or has already been used
+type OneTimeTokenNotValidError struct {
+	raisedFrom error
+}
+
+func (e OneTimeTokenNotValidError) Error() string {
+	return "Token is not valid"
+}
+
+// This error will be returned when an admin action cannot be recorded
+type AdminActionRecordError struct {
+	raisedFrom error
+}
+
+func (e AdminActionRecordError) Error() string {
+	return "Admin action cannot be recorded"
+}
+
+// This error will be returned when a role does not exist
+type RoleNotFoundError struct {
+	raisedFrom error
+}
+
+func (e RoleNotFoundError) Error() string {
+	return "Role not found"
+}
+
+// This error will be returned when a role cannot be assigned to or revoked
+// from an user
+type RoleAssignmentError struct {
+	raisedFrom error
+}
+
+func (e RoleAssignmentError) Error() string {
+	return "Role assignment cannot be changed"
+}
+
+// This error will be returned when an organization cannot be created
+type OrganizationCreateError struct {
+	raisedFrom error
+}
+
+func (e OrganizationCreateError) Error() string {
+	return "Organization cannot be created"
+}
+
+// This error will be returned when an organization does not exist
+type OrganizationNotFoundError struct {
+	raisedFrom error
+}
+
+func (e OrganizationNotFoundError) Error() string {
+	return "Organization not found"
+}
+
+// This error will be returned when an user is not member of an organization
+type MembershipNotFoundError struct {
+	raisedFrom error
+}
+
+func (e MembershipNotFoundError) Error() string {
+	return "User is not member of the organization"
+}
+
+// This error will be returned when a change would leave an organization
+// without owners
+type LastOwnerError struct{}
+
+func (e LastOwnerError) Error() string {
+	return "Organization must keep at least one owner"
+}
+
+// This error will be returned when an invitation cannot be created
+type InvitationCreateError struct {
+	raisedFrom error
+}
+
+func (e InvitationCreateError) Error() string {
+	return "Invitation cannot be created"
+}
+
+// This error will be returned when an invitation does not exist, has
+// expired, has already been accepted or belongs to other email
+type InvitationNotValidError struct {
+	raisedFrom error
+}
+
+func (e InvitationNotValidError) Error() string {
+	return "Invitation is not valid"
+}
+
+// This error will be returned when a session cannot be created
+type SessionCreateError struct {
+	raisedFrom error
+}
+
+func (e SessionCreateError) Error() string {
+	return "Session cannot be created"
+}
+
+// This error will be returned when a session does not exist, belongs to
+// other user or has been revoked
+type SessionNotFoundError struct {
+	raisedFrom error
+}
+
+func (e SessionNotFoundError) Error() string {
+	return "Session not found"
+}
+
+// This error will be returned when an id token hint has not been issued by
+// us, is malformed or its session does not exist
+type IDTokenHintNotValidError struct {
+	raisedFrom error
+}
+
+func (e IDTokenHintNotValidError) Error() string {
+	return "Id token hint is not valid"
+}
+
+// This error will be returned when the post logout redirect uri has not
+// been registered by the app
+type PostLogoutRedirectUriDoesNotMatch struct {
+	raisedFrom  error
+	redirectUri string
+}
+
+func (e PostLogoutRedirectUriDoesNotMatch) Error() string {
+	return fmt.Sprintf("Post logout redirect uri is not registered for the app, %s", e.redirectUri)
+}
+
+// This error will be returned when an audit event cannot be recorded
+type AuditEventRecordError struct {
+	raisedFrom error
+}
+
+func (e AuditEventRecordError) Error() string {
+	return "Audit event cannot be recorded"
+}
+
+// This error will be returned when a webhook cannot be created
+type WebhookCreateError struct {
+	raisedFrom error
+}
+
+func (e WebhookCreateError) Error() string {
+	return "Webhook cannot be created"
+}
+
+// This error will be returned when the url of a webhook, or the back-channel
+// logout url of an app, is not an http url of a public host
+type WebhookUrlError struct{}
+
+func (e WebhookUrlError) Error() string {
+	return "Url must be an http url of a public host"
+}
+
+// This error will be returned when a webhook does not exist or belongs to
+// other app
+type WebhookNotFoundError struct {
+	raisedFrom error
+}
+
+func (e WebhookNotFoundError) Error() string {
+	return "Webhook not found"
+}
+
+// This error will be returned when a webhook delivery does not exist or
+// belongs to other webhook
+type WebhookDeliveryNotFoundError struct {
+	raisedFrom error
+}
+
+func (e WebhookDeliveryNotFoundError) Error() string {
+	return "Webhook delivery not found"
+}
+
+// This error will be returned when a notification cannot be enqueued
+type OutboxEnqueueError struct {
+	raisedFrom error
+}
+
+func (e OutboxEnqueueError) Error() string {
+	return "Notification cannot be enqueued"
+}
+
+// This error will be returned when a notification template cannot be
+// parsed or rendered
+type NotificationTemplateInvalidError struct {
+	raisedFrom error
+}
+
+func (e NotificationTemplateInvalidError) Error() string {
+	return fmt.Sprintf("Notification template is not valid, %s", e.raisedFrom)
+}
+
+// This error will be returned when an app overrides a notification kind
+// which carries a secret link
+type NotificationTemplateKindError struct {
+	raisedFrom error
+}
+
+func (e NotificationTemplateKindError) Error() string {
+	return "Notification kind cannot be overridden by apps"
+}
+
+// This error will be returned when a notification template cannot be saved
+type NotificationTemplateSaveError struct {
+	raisedFrom error
+}
+
+func (e NotificationTemplateSaveError) Error() string {
+	return "Notification template cannot be saved"
+}
+
+// This error will be returned when a notification template has not been
+// overridden for the given kind and locale
+type NotificationTemplateNotFoundError struct {
+	raisedFrom error
+}
+
+func (e NotificationTemplateNotFoundError) Error() string {
+	return "Notification template not found"
+}
+
+// This error will be returned when a magic link is redeemed from a browser
+// other than the one which asked for it
+type MagicLinkBindingError struct {
+	raisedFrom error
+}
+
+func (e MagicLinkBindingError) Error() string {
+	return "Magic link was not asked for from this browser"
+}
+
+// This error will be returned when a phone code is asked for an user
+// without phone
+type PhoneNotSetError struct {
+	raisedFrom error
+}
+
+func (e PhoneNotSetError) Error() string {
+	return "User has no phone"
+}
+
+// This error will be returned when the phone is used for something which
+// requires it to be verified
+type PhoneNotVerifiedError struct {
+	raisedFrom error
+}
+
+func (e PhoneNotVerifiedError) Error() string {
+	return "Phone is not verified"
+}
+
+// This error will be returned when a phone code cannot be sent
+type PhoneCodeSendError struct {
+	raisedFrom error
+}
+
+func (e PhoneCodeSendError) Error() string {
+	return "Phone code cannot be sent"
+}
+
+// This error will be returned when a phone code is wrong, expired, already
+// used or out of attempts
+type PhoneCodeNotValidError struct {
+	raisedFrom error
+}
+
+func (e PhoneCodeNotValidError) Error() string {
+	return "Phone code is not valid"
+}
+
+// This error will be returned when the user logs in with his password but
+// he has to confirm it with a code sent to his phone
+type PhoneCodeRequiredError struct {
+	raisedFrom error
+}
+
+func (e PhoneCodeRequiredError) Error() string {
+	return "A code sent to the phone of the user is required"
+}
+
+// This error will be returned when the phone is changed or turned off as
+// second factor without a code sent to it nor the current password
+type PhoneProofRequiredError struct {
+	raisedFrom error
+}
+
+func (e PhoneProofRequiredError) Error() string {
+	return "A code sent to the current phone or the current password is required"
+}
+
+// This error will be returned when an identity provider cannot be created
+// or updated
+type IdentityProviderSaveError struct {
+	raisedFrom error
+}
+
+func (e IdentityProviderSaveError) Error() string {
+	return "Identity provider cannot be saved"
+}
+
+// This error will be returned when an identity provider does not exist or
+// it has been disabled
+type IdentityProviderNotFoundError struct {
+	raisedFrom error
+}
+
+func (e IdentityProviderNotFoundError) Error() string {
+	return "Identity provider not found"
+}
+
+// This error will be returned when the endpoints of an identity provider
+// cannot be discovered from its issuer
+type IdentityProviderDiscoveryError struct {
+	raisedFrom error
+}
+
+func (e IdentityProviderDiscoveryError) Error() string {
+	return "Identity provider configuration cannot be discovered"
+}
+
+// This error will be returned when a federated login is finished with a
+// wrong, expired or already used state
+type FederatedLoginNotValidError struct {
+	raisedFrom error
+}
+
+func (e FederatedLoginNotValidError) Error() string {
+	return "Federated login is not valid"
+}
+
+// This error will be returned when the identity provider does not answer
+// the login with a valid id token
+type FederatedIdentityError struct {
+	raisedFrom error
+}
+
+func (e FederatedIdentityError) Error() string {
+	return "Identity provider did not return a valid identity"
+}
+
+// This error will be returned when the identity provider does not vouch for
+// the email of an identity which is not linked yet
+type FederatedEmailNotVerifiedError struct {
+	raisedFrom error
+}
+
+func (e FederatedEmailNotVerifiedError) Error() string {
+	return "Identity provider did not verify the email"
+}
+
+// This error will be returned when a SAML authentication request cannot be
+// decoded or it does not come from a registered SAML app
+type SAMLRequestError struct {
+	raisedFrom error
+}
+
+func (e SAMLRequestError) Error() string {
+	return "SAML request is not valid"
+}
+
+// This error will be returned when the SAML response for an app cannot be
+// issued
+type SAMLResponseError struct {
+	raisedFrom error
+}
+
+func (e SAMLResponseError) Error() string {
+	return "SAML response cannot be issued"
+}
+
+// This error will be returned when a SCIM request is authenticated with a
+// wrong or revoked token
+type SCIMTokenNotValidError struct {
+	raisedFrom error
+}
+
+func (e SCIMTokenNotValidError) Error() string {
+	return "SCIM token is not valid"
+}
+
+// This error will be returned when a SCIM filter cannot be parsed or it
+// uses attributes or operators which are not supported
+type SCIMFilterError struct {
+	raisedFrom error
+}
+
+func (e SCIMFilterError) Error() string {
+	return "SCIM filter is not valid"
+}
+
+// This error will be returned when a SCIM operation targets a path which
+// is not supported
+type SCIMPathError struct {
+	raisedFrom error
+}
+
+func (e SCIMPathError) Error() string {
+	return "SCIM path is not supported"
+}
+
+// This error will be returned when a SCIM operation has a value which is
+// not valid for the attribute it targets
+type SCIMValueError struct {
+	raisedFrom error
+}
+
+func (e SCIMValueError) Error() string {
+	return "SCIM value is not valid"
+}
+
+// This error will be returned when a SCIM resource is created or renamed
+// with the identifier of another one
+type SCIMConflictError struct {
+	raisedFrom error
+}
+
+func (e SCIMConflictError) Error() string {
+	return "SCIM resource already exists"
+}
+
+// This error will be returned when a SCIM operation tries to rename or
+// delete a built-in group
+type SCIMMutabilityError struct {
+	raisedFrom error
+}
+
+func (e SCIMMutabilityError) Error() string {
+	return "Built-in groups cannot be changed"
+}
+
+// This error will be returned when a SCIM token cannot be found
+type SCIMTokenNotFoundError struct {
+	raisedFrom error
+}
+
+func (e SCIMTokenNotFoundError) Error() string {
+	return "SCIM token not found"
+}
+
+// This error will be returned when a data export cannot be found
+type DataExportNotFoundError struct {
+	raisedFrom error
+}
+
+func (e DataExportNotFoundError) Error() string {
+	return "Data export not found"
+}
+
+// This error will be returned when a data export cannot be requested
+type DataExportRequestError struct {
+	raisedFrom error
+}
+
+func (e DataExportRequestError) Error() string {
+	return "Data export cannot be requested"
+}
+
+// This error will be returned when the archive of a data export is
+// downloaded before it has been built
+type DataExportNotReadyError struct {
+	raisedFrom error
+}
+
+func (e DataExportNotReadyError) Error() string {
+	return "Data export is not ready yet"
+}
+
+// This error will be returned when the archive of a data export is
+// downloaded after it has expired
+type DataExportExpiredError struct {
+	raisedFrom error
+}
+
+func (e DataExportExpiredError) Error() string {
+	return "Data export has expired"
+}
+
+// This error will be returned when an attribute definition is not found
+type AttributeDefinitionNotFoundError struct {
+	raisedFrom error
+}
+
+func (e AttributeDefinitionNotFoundError) Error() string {
+	return "Attribute definition not found"
+}
+
+// This error will be returned when an attribute definition cannot be
+// stored, like when its name is taken or its pattern does not compile
+type AttributeDefinitionSaveError struct {
+	raisedFrom error
+}
+
+func (e AttributeDefinitionSaveError) Error() string {
+	return "Attribute definition cannot be saved"
+}
+
+// This error will be returned when a custom attribute of an user is not
+// defined or its value does not match the definition
+type AttributeNotValidError struct {
+	raisedFrom error
+}
+
+func (e AttributeNotValidError) Error() string {
+	return "Attribute is not defined or its value is not valid"
+}
+
+// This error will be returned when an user tries to change a custom
+// attribute which is not user editable
+type AttributeNotEditableError struct {
+	raisedFrom error
+}
+
+func (e AttributeNotEditableError) Error() string {
+	return "Attribute cannot be edited by the user"
+}
+
+// This error will be returned when the claim mapping of an app releases
+// an undefined attribute or overrides a reserved claim
+type AppClaimMappingError struct {
+	raisedFrom error
+}
+
+func (e AppClaimMappingError) Error() string {
+	return "Claim mapping is not valid"
+}
+
+// This error will be returned when an user is younger than the minimum
+// age to sign up
+type UserTooYoungError struct {
+	raisedFrom error
+}
+
+func (e UserTooYoungError) Error() string {
+	return "User is too young to sign up"
+}
+
+// This error will be returned when a minor signs up without the email of a
+// guardian who can consent to it
+type GuardianConsentRequiredError struct {
+	raisedFrom error
+}
+
+func (e GuardianConsentRequiredError) Error() string {
+	return "Guardian email is required to sign up"
+}
+
+// This error will be returned when an user whose sign-up awaits the consent
+// of his guardian tries to log in
+type GuardianConsentPendingError struct {
+	raisedFrom error
+}
+
+func (e GuardianConsentPendingError) Error() string {
+	return "User awaits the consent of his guardian"
+}
+
+// This error will be returned when an user is younger than the minimum age
+// required by an app, or his age is unknown
+type AppAgeRestrictedError struct {
+	raisedFrom error
+}
+
+func (e AppAgeRestrictedError) Error() string {
+	return "User does not meet the minimum age of the app"
+}
+
+// This error will be returned when a legal document is not found
+type LegalDocumentNotFoundError struct {
+	raisedFrom error
+}
+
+func (e LegalDocumentNotFoundError) Error() string {
+	return "Legal document not found"
+}
+
+// This error will be returned when a legal document cannot be created,
+// published or deleted
+type LegalDocumentSaveError struct {
+	raisedFrom error
+}
+
+func (e LegalDocumentSaveError) Error() string {
+	return "Legal document cannot be saved"
+}
+
+// This error will be returned when the acceptance of legal documents cannot
+// be recorded
+type LegalAcceptanceError struct {
+	raisedFrom error
+}
+
+func (e LegalAcceptanceError) Error() string {
+	return "Legal documents cannot be accepted"
+}
+
+// This error will be returned when the user has to accept the current
+// version of mandatory legal documents before logging in or authorizing an
+// app. It carries the documents which are pending.
+type LegalAcceptanceRequiredError struct {
+	raisedFrom error
+	Documents  []models.LegalDocument
+}
+
+func (e LegalAcceptanceRequiredError) Error() string {
+	return "Legal documents must be accepted"
+}
+
+// This error will be returned when a staff user cannot act as the given
+// user, like other staff users or disabled ones
+type ImpersonationError struct {
+	raisedFrom error
+}
+
+func (e ImpersonationError) Error() string {
+	return "User cannot be impersonated"
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gandalf/bindings"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/validators"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+// Length of the unusable password given to the users created through an
+// identity provider
+const federatedPasswordLength = 48
+
+// Claims of the id tokens issued by the identity providers
+type upstreamIDTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+}
+
+// Answer of the token endpoint of the identity providers
+type upstreamTokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+// Authorization request the browser must follow to sign in with an
+// identity provider, along with the state it must keep to finish it
+type FederatedAuthorization struct {
+	URL   string
+	State string
+}
+
+// Interface for federated login service
+type IFederatedLoginService interface {
+	Start(provider models.IdentityProvider, data validators.FederatedLoginStartData) (*FederatedAuthorization, error)
+	Finish(provider models.IdentityProvider, data validators.FederatedLoginCallbackData, client helpers.ClientInfo) (*models.User, error)
+}
+
+// Federated login service lets the users sign in with an upstream identity
+// provider. Gandalf acts as an OIDC relying party which uses the
+// authorization code flow with PKCE.
+type FederatedLoginService struct {
+	db       *gorm.DB
+	loginTTL time.Duration `env:"FEDERATED_LOGIN_TTL"`
+
+	phoneCodeAttempts int `env:"PHONE_CODE_MAX_ATTEMPTS"`
+
+	do func(request *http.Request) (*http.Response, error)
+}
+
+// Creates a new federated login service
+func NewFederatedLoginService(db *gorm.DB) FederatedLoginService {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return FederatedLoginService{
+		db:       db,
+		loginTTL: time.Duration(helpers.GetEnvInt("FEDERATED_LOGIN_TTL", 10)),
+		do:       client.Do,
+
+		phoneCodeAttempts: helpers.GetEnvInt("PHONE_CODE_MAX_ATTEMPTS", 5),
+	}
+}
+
+// Starts a login with the given provider and returns the authorization
+// request the browser must be sent to
+func (service FederatedLoginService) Start(provider models.IdentityProvider, data validators.FederatedLoginStartData) (*FederatedAuthorization, error) {
+	login := models.NewFederatedLogin(provider, data.RedirectURI, service.loginTTL*time.Minute)
+	if err := service.db.Create(&login).Error; err != nil {
+		return nil, FederatedLoginNotValidError{err}
+	}
+	return &FederatedAuthorization{URL: provider.AuthorizationRequestURL(login), State: login.State()}, nil
+}
+
+// Finishes the login with the given state with the code the provider has
+// answered with, and returns the user it logs in. Identities which are not
+// linked yet are linked to the user with the same email, or to a new user
+// when there is none, as long as the provider has verified the email. The
+// login goes through the same steps as any other; when it misses one, the
+// user is returned along with the error and the login can be finished again
+// with it until it expires, without trading the code again.
+func (service FederatedLoginService) Finish(provider models.IdentityProvider, data validators.FederatedLoginCallbackData, client helpers.ClientInfo) (*models.User, error) {
+	audit := AuditContext{Client: client}
+	metadata := models.AuditMetadata{"method": "federated", "provider": provider.Name}
+
+	login, user, err := service.redeem(provider, data, client)
+	if err == nil && user.Disabled {
+		err = AuthenticationError{nil}
+	}
+	if err == nil {
+		err = requireLoginSteps(service.db, *user, data.PhoneCode, data.AcceptedDocuments, service.phoneCodeAttempts, audit, metadata)
+		if isMissingLoginStep(err) {
+			return user, err
+		}
+	}
+	if err == nil {
+		audit.Actor = user
+		err = service.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(login).Where("completed_at IS NULL").Update("completed_at", time.Now())
+			if result.Error != nil || result.RowsAffected != 1 {
+				return FederatedLoginNotValidError{result.Error}
+			}
+			return recordAuditEvent(tx, audit, models.AuditActionLogin, models.AuditOutcomeSuccess, user, metadata)
+		})
+	}
+	if err != nil {
+		recordAuditEvent(service.db, audit, models.AuditActionLogin, models.AuditOutcomeFailure, user, metadata)
+		return nil, err
+	}
+	return user, nil
+}
+
+// Returns the login with the given state along with the user the provider
+// vouches for. The code is traded only once; logins which still wait for
+// the steps the user missed return the user it was traded for.
+func (service FederatedLoginService) redeem(provider models.IdentityProvider, data validators.FederatedLoginCallbackData, client helpers.ClientInfo) (*models.FederatedLogin, *models.User, error) {
+	var login models.FederatedLogin
+	clause := &models.FederatedLogin{StateDigest: security.Sha256Digest(data.State), ProviderID: provider.ID}
+	if err := service.db.Preload("User").Where(clause).First(&login).Error; err != nil {
+		return nil, nil, FederatedLoginNotValidError{err}
+	}
+	if login.IsPending() && login.User != nil {
+		return &login, login.User, nil
+	}
+	if !login.IsUsable() {
+		return nil, nil, FederatedLoginNotValidError{nil}
+	}
+
+	result := service.db.Model(&login).Where("used_at IS NULL").Update("used_at", time.Now())
+	if result.Error != nil || result.RowsAffected != 1 {
+		return nil, nil, FederatedLoginNotValidError{result.Error}
+	}
+
+	idToken, err := exchangeUpstreamCode(service.do, provider, login, data.Code)
+	if err != nil {
+		return nil, nil, err
+	}
+	claims, err := verifyUpstreamIDToken(service.do, provider, login, idToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var user *models.User
+	err = service.db.Transaction(func(tx *gorm.DB) error {
+		linked, err := resolveFederatedUser(tx, provider, *claims, client)
+		if err != nil {
+			return err
+		}
+		user = linked
+		return tx.Model(&login).Update("user_id", user.ID).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &login, user, nil
+}
+
+// Trades the given authorization code for an id token at the token endpoint
+// of the given provider
+func exchangeUpstreamCode(do func(*http.Request) (*http.Response, error), provider models.IdentityProvider, login models.FederatedLogin, code string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", login.RedirectURI)
+	form.Set("client_id", provider.ClientID)
+	form.Set("code_verifier", login.CodeVerifier)
+	if provider.ClientSecret != "" {
+		form.Set("client_secret", provider.ClientSecret)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, provider.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", FederatedIdentityError{err}
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := do(request)
+	if err != nil {
+		return "", FederatedIdentityError{err}
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", FederatedIdentityError{fmt.Errorf("unexpected status %d", response.StatusCode)}
+	}
+
+	var tokens upstreamTokenResponse
+	if err := json.NewDecoder(response.Body).Decode(&tokens); err != nil || tokens.IDToken == "" {
+		return "", FederatedIdentityError{err}
+	}
+	return tokens.IDToken, nil
+}
+
+// Fetches the keys the given provider signs its tokens with
+func fetchUpstreamKeys(do func(*http.Request) (*http.Response, error), provider models.IdentityProvider) (*security.JWKS, error) {
+	request, err := http.NewRequest(http.MethodGet, provider.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	var keys security.JWKS
+	if err := json.NewDecoder(response.Body).Decode(&keys); err != nil {
+		return nil, err
+	}
+	return &keys, nil
+}
+
+// Verifies the given id token was issued by the given provider for the
+// given login, and returns its claims
+func verifyUpstreamIDToken(do func(*http.Request) (*http.Response, error), provider models.IdentityProvider, login models.FederatedLogin, idToken string) (*upstreamIDTokenClaims, error) {
+	keys, err := fetchUpstreamKeys(do, provider)
+	if err != nil {
+		return nil, FederatedIdentityError{err}
+	}
+
+	var claims upstreamIDTokenClaims
+	_, err = jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != jwt.SigningMethodRS256.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		kid, _ := token.Header["kid"].(string)
+		return keys.RSAKey(kid)
+	})
+	if err != nil {
+		return nil, FederatedIdentityError{err}
+	}
+
+	switch {
+	case claims.Issuer != provider.Issuer:
+		err = errors.New("issuer mismatch")
+	case !claims.VerifyAudience(provider.ClientID, true):
+		err = errors.New("audience mismatch")
+	case !claims.VerifyExpiresAt(time.Now(), true):
+		err = errors.New("token without expiration")
+	case claims.Nonce != login.Nonce:
+		err = errors.New("nonce mismatch")
+	case claims.Subject == "":
+		err = errors.New("token without subject")
+	}
+	if err != nil {
+		return nil, FederatedIdentityError{err}
+	}
+	return &claims, nil
+}
+
+// Returns the user linked to the identity of the given claims, linking it
+// first when needed. Identities are linked by verified email to an existing
+// user, or to a new verified user when there is none. An existing user who
+// had not verified his email loses his password, since it may have been
+// set by someone else who registered with that email. Users whose sign-up
+// awaits the consent of their guardian cannot log in.
+func resolveFederatedUser(tx *gorm.DB, provider models.IdentityProvider, claims upstreamIDTokenClaims, client helpers.ClientInfo) (*models.User, error) {
+	var identity models.FederatedIdentity
+	clause := &models.FederatedIdentity{ProviderID: provider.ID, Subject: claims.Subject}
+	if err := tx.Preload("User").Where(clause).First(&identity).Error; err == nil {
+		if identity.User.AwaitsGuardianConsent() {
+			return nil, GuardianConsentPendingError{}
+		}
+		return &identity.User, nil
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, FederatedEmailNotVerifiedError{}
+	}
+
+	password, err := security.NewUniformSecret().GenerateSecret(federatedPasswordLength)
+	if err != nil {
+		return nil, UserCreateError{err}
+	}
+
+	var user models.User
+	if err := tx.Where(&models.User{Email: claims.Email}).First(&user).Error; err == nil {
+		if user.AwaitsGuardianConsent() {
+			return nil, GuardianConsentPendingError{}
+		}
+		if !user.Verified {
+			user.SetPassword(password)
+			user.Verified = true
+			if err := tx.Save(&user).Error; err != nil {
+				return nil, UserCreateError{err}
+			}
+			if _, err := enqueueWebhookEvent(tx, models.WebhookEventUserVerified, user, nil); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		name, surname := claims.GivenName, claims.FamilyName
+		if name == "" {
+			name = claims.Name
+		}
+		user = models.NewUser(claims.Email, password, name, surname, bindings.BirthDate{}, "")
+		user.Verified = true
+		if err := createUser(tx, &user); err != nil {
+			return nil, err
+		}
+	}
+
+	identity = models.NewFederatedIdentity(provider, user, claims.Subject, claims.Email)
+	if err := tx.Create(&identity).Error; err != nil {
+		return nil, UserCreateError{err}
+	}
+
+	metadata := models.AuditMetadata{"provider": provider.Name}
+	if err := recordAuditEvent(tx, AuditContext{&user, client}, models.AuditActionLinkIdentity, models.AuditOutcomeSuccess, &user, metadata); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
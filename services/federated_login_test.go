@@ -0,0 +1,270 @@
+package services
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates a provider registered on the given mock OIDC provider
+func newTestIdentityProvider(upstream *tests.OIDCProvider) models.IdentityProvider {
+	provider := models.NewIdentityProvider("acme", "Acme", upstream.Issuer(), "gandalf", "secret")
+	provider.AuthorizationURL = upstream.AuthorizationURL()
+	provider.TokenURL = upstream.TokenURL()
+	provider.JWKSURL = upstream.JWKSURL()
+	return provider
+}
+
+func TestUpstreamIDToken(t *testing.T) {
+	assert := require.New(t)
+	upstream := tests.NewOIDCProvider()
+	defer upstream.Close()
+	provider := newTestIdentityProvider(upstream)
+	do := http.DefaultClient.Do
+
+	t.Run("Test code is traded for a verified identity", func(t *testing.T) {
+		login := models.NewFederatedLogin(provider, "https://app.test/callback", time.Minute)
+		code, state := upstream.Authorize(provider.AuthorizationRequestURL(login), tests.OIDCIdentity{
+			Subject: "subject", Email: "johndoe@example.com", EmailVerified: true,
+		})
+
+		idToken, exchangeErr := exchangeUpstreamCode(do, provider, login, code)
+		claims, err := verifyUpstreamIDToken(do, provider, login, idToken)
+
+		assert.NoError(exchangeErr)
+		assert.NoError(err)
+		assert.Equal(login.State(), state)
+		assert.Equal("subject", claims.Subject)
+		assert.Equal("johndoe@example.com", claims.Email)
+		assert.True(claims.EmailVerified)
+	})
+
+	t.Run("Test code is not traded without the PKCE verifier", func(t *testing.T) {
+		login := models.NewFederatedLogin(provider, "https://app.test/callback", time.Minute)
+		code, _ := upstream.Authorize(provider.AuthorizationRequestURL(login), tests.OIDCIdentity{Subject: "subject"})
+		login.CodeVerifier = "forged"
+
+		_, err := exchangeUpstreamCode(do, provider, login, code)
+
+		assert.IsType(FederatedIdentityError{}, err)
+	})
+
+	t.Run("Test id token is bound to the login nonce", func(t *testing.T) {
+		login := models.NewFederatedLogin(provider, "https://app.test/callback", time.Minute)
+		other := models.NewFederatedLogin(provider, "https://app.test/callback", time.Minute)
+		code, _ := upstream.Authorize(provider.AuthorizationRequestURL(other), tests.OIDCIdentity{Subject: "subject"})
+		idToken, _ := exchangeUpstreamCode(do, provider, other, code)
+
+		_, err := verifyUpstreamIDToken(do, provider, login, idToken)
+
+		assert.IsType(FederatedIdentityError{}, err)
+	})
+
+	t.Run("Test id token claims are checked", func(t *testing.T) {
+		login := models.NewFederatedLogin(provider, "https://app.test/callback", time.Minute)
+		valid := func() jwt.MapClaims {
+			return jwt.MapClaims{
+				"iss": upstream.Issuer(), "sub": "subject", "aud": "gandalf",
+				"exp": time.Now().Add(time.Minute).Unix(), "nonce": login.Nonce,
+			}
+		}
+		_, err := verifyUpstreamIDToken(do, provider, login, upstream.SignIDToken(valid()))
+		assert.NoError(err)
+
+		for claim, value := range map[string]interface{}{
+			"iss":   "https://evil.test",
+			"aud":   "other-client",
+			"exp":   time.Now().Add(-time.Minute).Unix(),
+			"nonce": "replayed",
+			"sub":   "",
+		} {
+			claims := valid()
+			claims[claim] = value
+
+			_, err := verifyUpstreamIDToken(do, provider, login, upstream.SignIDToken(claims))
+
+			assert.IsType(FederatedIdentityError{}, err, claim)
+		}
+	})
+
+	t.Run("Test id token signed with other methods is rejected", func(t *testing.T) {
+		login := models.NewFederatedLogin(provider, "https://app.test/callback", time.Minute)
+		claims := jwt.MapClaims{
+			"iss": upstream.Issuer(), "sub": "subject", "aud": "gandalf",
+			"exp": time.Now().Add(time.Minute).Unix(), "nonce": login.Nonce,
+		}
+		idToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+
+		_, err := verifyUpstreamIDToken(do, provider, login, idToken)
+
+		assert.IsType(FederatedIdentityError{}, err)
+	})
+}
+
+func TestFederatedLoginService(t *testing.T) {
+	assert := require.New(t)
+	upstream := tests.NewOIDCProvider()
+	defer upstream.Close()
+
+	// Runs a whole login of the given identity and returns its outcome
+	login := func(service FederatedLoginService, provider models.IdentityProvider, identity tests.OIDCIdentity) (*models.User, error) {
+		authorization, err := service.Start(provider, validators.FederatedLoginStartData{RedirectURI: "https://app.test/callback"})
+		if err != nil {
+			return nil, err
+		}
+		code, state := upstream.Authorize(authorization.URL, identity)
+		return service.Finish(provider, validators.FederatedLoginCallbackData{Code: code, State: state}, helpers.ClientInfo{})
+	}
+
+	t.Run("Test new identities create a verified user", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewFederatedLoginService(db)
+		provider := newTestIdentityProvider(upstream)
+		db.Create(&provider)
+
+		user, err := login(service, provider, tests.OIDCIdentity{
+			Subject: "new", Email: "newcomer@example.com", EmailVerified: true, GivenName: "John", FamilyName: "Doe",
+		})
+
+		var identities int64
+		db.Model(&models.FederatedIdentity{}).Where("user_id = ?", user.ID).Count(&identities)
+		assert.NoError(err)
+		assert.True(user.Verified)
+		assert.Equal("John", user.Name)
+		assert.Equal(int64(1), identities)
+	})
+
+	t.Run("Test identities are linked to the user with the same email", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewFederatedLoginService(db)
+		provider := newTestIdentityProvider(upstream)
+		db.Create(&provider)
+		existing := tests.UserFactory()
+		existing.Verified = true
+		db.Create(&existing)
+
+		identity := tests.OIDCIdentity{Subject: "linked", Email: existing.Email, EmailVerified: true}
+		first, firstErr := login(service, provider, identity)
+		second, secondErr := login(service, provider, identity)
+
+		assert.NoError(firstErr)
+		assert.NoError(secondErr)
+		assert.Equal(existing.UUID, first.UUID)
+		assert.Equal(existing.UUID, second.UUID)
+	})
+
+	t.Run("Test unverified local users lose their password when linked", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewFederatedLoginService(db)
+		provider := newTestIdentityProvider(upstream)
+		db.Create(&provider)
+		existing := tests.UserFactory()
+		existing.SetPassword("squatter-password")
+		db.Create(&existing)
+
+		user, err := login(service, provider, tests.OIDCIdentity{Subject: "owner", Email: existing.Email, EmailVerified: true})
+
+		assert.NoError(err)
+		assert.True(user.Verified)
+		assert.False(user.VerifyPassword("squatter-password"))
+	})
+
+	t.Run("Test users awaiting the guardian consent are not linked", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewFederatedLoginService(db)
+		provider := newTestIdentityProvider(upstream)
+		db.Create(&provider)
+		existing := tests.UserFactory()
+		existing.GuardianEmail = "guardian@test.com"
+		db.Create(&existing)
+
+		_, err := login(service, provider, tests.OIDCIdentity{Subject: "minor", Email: existing.Email, EmailVerified: true})
+
+		assert.IsType(GuardianConsentPendingError{}, err)
+		db.First(&existing, existing.ID)
+		assert.False(existing.Verified)
+	})
+
+	t.Run("Test unverified emails are not linked", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewFederatedLoginService(db)
+		provider := newTestIdentityProvider(upstream)
+		db.Create(&provider)
+		existing := tests.UserFactory()
+		existing.Verified = true
+		db.Create(&existing)
+
+		_, err := login(service, provider, tests.OIDCIdentity{Subject: "claimer", Email: existing.Email})
+
+		assert.IsType(FederatedEmailNotVerifiedError{}, err)
+	})
+
+	t.Run("Test logins can only be finished once", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewFederatedLoginService(db)
+		provider := newTestIdentityProvider(upstream)
+		db.Create(&provider)
+
+		authorization, _ := service.Start(provider, validators.FederatedLoginStartData{RedirectURI: "https://app.test/callback"})
+		code, state := upstream.Authorize(authorization.URL, tests.OIDCIdentity{
+			Subject: "once", Email: "once@example.com", EmailVerified: true,
+		})
+		data := validators.FederatedLoginCallbackData{Code: code, State: state}
+		_, firstErr := service.Finish(provider, data, helpers.ClientInfo{})
+		_, secondErr := service.Finish(provider, data, helpers.ClientInfo{})
+
+		assert.NoError(firstErr)
+		assert.IsType(FederatedLoginNotValidError{}, secondErr)
+	})
+	t.Run("Test logins missing the phone code are finished again with it", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewFederatedLoginService(db)
+		sender := NewMemorySMSSender()
+		phoneService := NewPhoneService(db, sender)
+		provider := newTestIdentityProvider(upstream)
+		db.Create(&provider)
+		existing := tests.UserFactory()
+		existing.Verified = true
+		existing.PhoneVerified = true
+		existing.PhoneMFA = true
+		db.Create(&existing)
+
+		authorization, _ := service.Start(provider, validators.FederatedLoginStartData{RedirectURI: "https://app.test/callback"})
+		code, state := upstream.Authorize(authorization.URL, tests.OIDCIdentity{
+			Subject: "mfa", Email: existing.Email, EmailVerified: true,
+		})
+		data := validators.FederatedLoginCallbackData{Code: code, State: state}
+		pending, requiredErr := service.Finish(provider, data, helpers.ClientInfo{})
+		phoneService.SendCode(*pending, models.PhoneCodePurposeMFA)
+		data.PhoneCode = lastPhoneCode(sender)
+		user, err := service.Finish(provider, data, helpers.ClientInfo{})
+		_, replayErr := service.Finish(provider, data, helpers.ClientInfo{})
+
+		assert.IsType(PhoneCodeRequiredError{}, requiredErr)
+		assert.NoError(err)
+		assert.Equal(existing.UUID, user.UUID)
+		assert.IsType(FederatedLoginNotValidError{}, replayErr)
+	})
+
+	t.Run("Test logins with pending legal documents are refused", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewFederatedLoginService(db)
+		provider := newTestIdentityProvider(upstream)
+		db.Create(&provider)
+		terms := models.NewLegalDocument(models.LegalDocumentTerms, "v1", "Terms", "https://example.com/terms", true, nil)
+		db.Create(&terms)
+
+		_, err := login(service, provider, tests.OIDCIdentity{
+			Subject: "legal", Email: "legal@example.com", EmailVerified: true,
+		})
+
+		assert.IsType(LegalAcceptanceRequiredError{}, err)
+	})
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"gandalf/models"
+	"gandalf/validators"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Path where the OIDC providers publish their configuration
+const oidcDiscoveryPath = "/.well-known/openid-configuration"
+
+// Endpoints published by an OIDC provider which are relevant for a relying
+// party
+type oidcDiscoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Interface for identity provider service
+type IIdentityProviderService interface {
+	Create(data validators.IdentityProviderCreateData) (*models.IdentityProvider, error)
+	Read(name string) (*models.IdentityProvider, error)
+	List(includeDisabled bool) []models.IdentityProvider
+	Update(provider *models.IdentityProvider, data validators.IdentityProviderUpdateData) error
+	Delete(provider models.IdentityProvider) error
+}
+
+// Identity provider service manages the upstream OIDC providers the users
+// can sign in with
+type IdentityProviderService struct {
+	db *gorm.DB
+	do func(request *http.Request) (*http.Response, error)
+}
+
+// Creates a new identity provider service
+func NewIdentityProviderService(db *gorm.DB) IdentityProviderService {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return IdentityProviderService{db: db, do: client.Do}
+}
+
+// Fetches the configuration the provider with the given issuer publishes
+func (service IdentityProviderService) discover(issuer string) (*oidcDiscoveryDocument, error) {
+	request, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(issuer, "/")+oidcDiscoveryPath, nil)
+	if err != nil {
+		return nil, IdentityProviderDiscoveryError{err}
+	}
+
+	response, err := service.do(request)
+	if err != nil {
+		return nil, IdentityProviderDiscoveryError{err}
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, IdentityProviderDiscoveryError{fmt.Errorf("unexpected status %d", response.StatusCode)}
+	}
+
+	var document oidcDiscoveryDocument
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		return nil, IdentityProviderDiscoveryError{err}
+	}
+	if document.Issuer != issuer {
+		return nil, IdentityProviderDiscoveryError{fmt.Errorf("issuer mismatch %s", document.Issuer)}
+	}
+	return &document, nil
+}
+
+// Registers a new identity provider. The endpoints which are not given are
+// discovered from the issuer.
+func (service IdentityProviderService) Create(data validators.IdentityProviderCreateData) (*models.IdentityProvider, error) {
+	provider := models.NewIdentityProvider(data.Name, data.DisplayName, data.Issuer, data.ClientID, data.ClientSecret)
+	provider.AuthorizationURL = data.AuthorizationURL
+	provider.TokenURL = data.TokenURL
+	provider.JWKSURL = data.JWKSURL
+	provider.Scopes = data.Scopes
+	provider.Disabled = data.Disabled
+
+	if provider.AuthorizationURL == "" || provider.TokenURL == "" || provider.JWKSURL == "" {
+		document, err := service.discover(provider.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		if provider.AuthorizationURL == "" {
+			provider.AuthorizationURL = document.AuthorizationEndpoint
+		}
+		if provider.TokenURL == "" {
+			provider.TokenURL = document.TokenEndpoint
+		}
+		if provider.JWKSURL == "" {
+			provider.JWKSURL = document.JWKSURI
+		}
+	}
+
+	if err := service.db.Create(&provider).Error; err != nil {
+		return nil, IdentityProviderSaveError{err}
+	}
+	return &provider, nil
+}
+
+// Read an identity provider by his name
+func (service IdentityProviderService) Read(name string) (*models.IdentityProvider, error) {
+	var provider models.IdentityProvider
+	if err := service.db.Where(&models.IdentityProvider{Name: name}).First(&provider).Error; err != nil {
+		return nil, IdentityProviderNotFoundError{err}
+	}
+	return &provider, nil
+}
+
+// List the identity providers by name. The disabled ones are only listed
+// when asked for.
+func (service IdentityProviderService) List(includeDisabled bool) []models.IdentityProvider {
+	var providers []models.IdentityProvider
+	query := service.db.Order("name")
+	if !includeDisabled {
+		query = query.Where("disabled = ?", false)
+	}
+	query.Find(&providers)
+	return providers
+}
+
+// Updates the given identity provider according to the given data
+func (service IdentityProviderService) Update(provider *models.IdentityProvider, data validators.IdentityProviderUpdateData) error {
+	if data.DisplayName != "" {
+		provider.DisplayName = data.DisplayName
+	}
+	if data.AuthorizationURL != "" {
+		provider.AuthorizationURL = data.AuthorizationURL
+	}
+	if data.TokenURL != "" {
+		provider.TokenURL = data.TokenURL
+	}
+	if data.JWKSURL != "" {
+		provider.JWKSURL = data.JWKSURL
+	}
+	if data.ClientID != "" {
+		provider.ClientID = data.ClientID
+	}
+	if data.ClientSecret != "" {
+		provider.ClientSecret = data.ClientSecret
+	}
+	if data.Scopes != nil {
+		provider.Scopes = data.Scopes
+	}
+	if data.Disabled != nil {
+		provider.Disabled = *data.Disabled
+	}
+
+	if err := service.db.Save(provider).Error; err != nil {
+		return IdentityProviderSaveError{err}
+	}
+	return nil
+}
+
+// Deletes the given identity provider. The identities linked through it
+// and its pending logins are deleted along with it, and its name can be
+// used again.
+func (service IdentityProviderService) Delete(provider models.IdentityProvider) error {
+	if err := service.db.Unscoped().Delete(&provider).Error; err != nil {
+		return IdentityProviderNotFoundError{err}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"gandalf/models"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityProviderDiscovery(t *testing.T) {
+	assert := require.New(t)
+	upstream := tests.NewOIDCProvider()
+	defer upstream.Close()
+
+	t.Run("Test endpoints are discovered from the issuer", func(t *testing.T) {
+		service := IdentityProviderService{do: http.DefaultClient.Do}
+
+		document, err := service.discover(upstream.Issuer())
+
+		assert.NoError(err)
+		assert.Equal(upstream.AuthorizationURL(), document.AuthorizationEndpoint)
+		assert.Equal(upstream.TokenURL(), document.TokenEndpoint)
+		assert.Equal(upstream.JWKSURL(), document.JWKSURI)
+	})
+
+	t.Run("Test issuer must match the configuration", func(t *testing.T) {
+		service := IdentityProviderService{do: http.DefaultClient.Do}
+
+		_, err := service.discover(upstream.Issuer() + "/")
+
+		assert.IsType(IdentityProviderDiscoveryError{}, err)
+	})
+}
+
+func TestIdentityProviderService(t *testing.T) {
+	assert := require.New(t)
+	upstream := tests.NewOIDCProvider()
+	defer upstream.Close()
+
+	t.Run("Test create discovers the missing endpoints", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewIdentityProviderService(db)
+
+		provider, err := service.Create(validators.IdentityProviderCreateData{
+			Name: "acme", DisplayName: "Acme", Issuer: upstream.Issuer(), ClientID: "gandalf",
+		})
+
+		assert.NoError(err)
+		assert.Equal(upstream.TokenURL(), provider.TokenURL)
+		assert.Equal(upstream.JWKSURL(), provider.JWKSURL)
+	})
+
+	t.Run("Test disabled providers are only listed when asked for", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewIdentityProviderService(db)
+		enabled := newTestIdentityProvider(upstream)
+		disabled := newTestIdentityProvider(upstream)
+		disabled.Name = "disabled"
+		disabled.Disabled = true
+		db.Create(&enabled)
+		db.Create(&disabled)
+
+		assert.Equal(1, len(service.List(false)))
+		assert.Equal(2, len(service.List(true)))
+	})
+
+	t.Run("Test delete frees the name and unlinks the identities", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewIdentityProviderService(db)
+		provider := newTestIdentityProvider(upstream)
+		db.Create(&provider)
+		user := tests.UserFactory()
+		db.Create(&user)
+		identity := models.NewFederatedIdentity(provider, user, "subject", user.Email)
+		db.Create(&identity)
+
+		err := service.Delete(provider)
+		recreated := newTestIdentityProvider(upstream)
+
+		var identities int64
+		db.Model(&models.FederatedIdentity{}).Where("user_id = ?", user.ID).Count(&identities)
+		assert.NoError(err)
+		assert.Equal(int64(0), identities)
+		assert.NoError(db.Create(&recreated).Error)
+	})
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"gandalf/bindings"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/validators"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Timeout of every request sent to the directory
+const ldapTimeout = 10 * time.Second
+
+// Length of the random password the users created from the directory get.
+// They never log in with it, since the directory keeps their password.
+const directoryPasswordLength = 48
+
+// User found on the directory, along with the roles granted by his groups
+type directoryUser struct {
+	Email   string
+	Name    string
+	Surname string
+	Roles   []string
+}
+
+// LDAP backend verifies the credentials with a search then bind against an
+// LDAP or Active Directory server. Users found on the directory are created
+// on their first login, and their names, roles and staff flag are synced
+// from it on every login. The passwords are sent to the directory, so it is
+// only reached over TLS.
+type LDAPCredentialBackend struct {
+	db               *gorm.DB
+	url              string            `env:"LDAP_URL"`
+	bindDN           string            `env:"LDAP_BIND_DN"`
+	bindPassword     string            `env:"LDAP_BIND_PASSWORD"`
+	baseDN           string            `env:"LDAP_BASE_DN"`
+	userFilter       string            `env:"LDAP_USER_FILTER"`
+	nameAttribute    string            `env:"LDAP_NAME_ATTRIBUTE"`
+	surnameAttribute string            `env:"LDAP_SURNAME_ATTRIBUTE"`
+	groupAttribute   string            `env:"LDAP_GROUP_ATTRIBUTE"`
+	groupRoles       map[string]string `env:"LDAP_GROUP_ROLES"`
+	rootCAs          *x509.CertPool    `env:"LDAP_CA_FILE"`
+}
+
+// Creates a new LDAP backend from the LDAP_* settings
+func NewLDAPCredentialBackend(db *gorm.DB) LDAPCredentialBackend {
+	return LDAPCredentialBackend{
+		db:               db,
+		url:              os.Getenv("LDAP_URL"),
+		bindDN:           os.Getenv("LDAP_BIND_DN"),
+		bindPassword:     os.Getenv("LDAP_BIND_PASSWORD"),
+		baseDN:           os.Getenv("LDAP_BASE_DN"),
+		userFilter:       helpers.GetEnvString("LDAP_USER_FILTER", "(mail=%s)"),
+		nameAttribute:    helpers.GetEnvString("LDAP_NAME_ATTRIBUTE", "givenName"),
+		surnameAttribute: helpers.GetEnvString("LDAP_SURNAME_ATTRIBUTE", "sn"),
+		groupAttribute:   helpers.GetEnvString("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		groupRoles:       parseLDAPGroupRoles(os.Getenv("LDAP_GROUP_ROLES")),
+		rootCAs:          loadLDAPRootCAs(os.Getenv("LDAP_CA_FILE")),
+	}
+}
+
+// Loads the authorities the certificate of the directory is checked
+// against from the given PEM file. Without it the ones of the system are
+// used. Panics when the file cannot be loaded, since no user could log in.
+func loadLDAPRootCAs(caFile string) *x509.CertPool {
+	if caFile == "" {
+		return nil
+	}
+	content, err := os.ReadFile(caFile)
+	if err != nil {
+		panic(err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(content) {
+		panic(errors.New("no certificate found in " + caFile))
+	}
+	return rootCAs
+}
+
+// Parses a comma separated list of group:role pairs, which grant the role
+// to the members of the group with the given common name
+func parseLDAPGroupRoles(value string) map[string]string {
+	groupRoles := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			continue
+		}
+		groupRoles[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	return groupRoles
+}
+
+// Returns the roles granted by the groups with the given distinguished
+// names
+func (backend LDAPCredentialBackend) groupsRoles(groups []string) []string {
+	roles := []string{}
+	for _, group := range groups {
+		dn, err := ldap.ParseDN(group)
+		if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+			continue
+		}
+		if role, found := backend.groupRoles[strings.ToLower(dn.RDNs[0].Attributes[0].Value)]; found {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Connects to the directory over TLS. Plain ldap:// urls are upgraded with
+// StartTLS, and the connection is refused when the directory cannot.
+func (backend LDAPCredentialBackend) dial() (*ldap.Conn, error) {
+	address, err := url.Parse(backend.url)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{ServerName: address.Hostname(), RootCAs: backend.rootCAs, MinVersion: tls.VersionTLS12}
+
+	conn, err := ldap.DialURL(backend.url, ldap.DialWithTLSConfig(config))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(ldapTimeout)
+	if address.Scheme != "ldaps" {
+		if err := conn.StartTLS(config); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Looks up the entry of the given credentials on the directory and binds
+// as it with the given password
+func (backend LDAPCredentialBackend) lookup(credentials validators.Credentials) (*directoryUser, error) {
+	// An empty password would be an unauthenticated bind, which succeeds
+	if credentials.Password == "" {
+		return nil, AuthenticationError{nil}
+	}
+
+	conn, err := backend.dial()
+	if err != nil {
+		return nil, AuthenticationError{err}
+	}
+	defer conn.Close()
+
+	if backend.bindDN != "" {
+		if err := conn.Bind(backend.bindDN, backend.bindPassword); err != nil {
+			return nil, AuthenticationError{err}
+		}
+	}
+
+	request := ldap.NewSearchRequest(
+		backend.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout/time.Second), false,
+		fmt.Sprintf(backend.userFilter, ldap.EscapeFilter(credentials.Email)),
+		[]string{backend.nameAttribute, backend.surnameAttribute, backend.groupAttribute},
+		nil,
+	)
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, AuthenticationError{err}
+	}
+	if len(result.Entries) != 1 {
+		return nil, AuthenticationError{nil}
+	}
+
+	entry := result.Entries[0]
+	if err := conn.Bind(entry.DN, credentials.Password); err != nil {
+		return nil, AuthenticationError{err}
+	}
+
+	return &directoryUser{
+		Email:   credentials.Email,
+		Name:    entry.GetAttributeValue(backend.nameAttribute),
+		Surname: entry.GetAttributeValue(backend.surnameAttribute),
+		Roles:   backend.groupsRoles(entry.GetAttributeValues(backend.groupAttribute)),
+	}, nil
+}
+
+// Creates or updates the local user of the given directory user. Only the
+// roles granted by some group are synced, so the ones assigned by hand are
+// kept, and the staff flag is only synced when a group grants the staff
+// role. An existing user who had not verified his email loses his
+// password, since it may have been set by someone else who registered with
+// that email, while one whose sign-up awaits the consent of his guardian
+// is refused.
+func (backend LDAPCredentialBackend) sync(tx *gorm.DB, entry directoryUser) (*models.User, error) {
+	password, err := security.NewUniformSecret().GenerateSecret(directoryPasswordLength)
+	if err != nil {
+		return nil, UserCreateError{err}
+	}
+
+	var user models.User
+	event := ""
+	if err := tx.Where(&models.User{Email: entry.Email}).First(&user).Error; err != nil {
+		user = models.NewUser(entry.Email, password, entry.Name, entry.Surname, bindings.BirthDate{}, "")
+		user.Verified = true
+		if err := createUser(tx, &user); err != nil {
+			return nil, err
+		}
+	} else if user.AwaitsGuardianConsent() {
+		return nil, GuardianConsentPendingError{}
+	} else if !user.Verified {
+		user.SetPassword(password)
+		user.Verified = true
+		event = models.WebhookEventUserVerified
+	}
+
+	if entry.Name != "" {
+		user.Name = entry.Name
+	}
+	if entry.Surname != "" {
+		user.Surname = entry.Surname
+	}
+
+	granted := map[string]bool{}
+	for _, role := range entry.Roles {
+		granted[role] = true
+	}
+	managed := map[string]bool{}
+	for _, role := range backend.groupRoles {
+		managed[role] = true
+	}
+	if managed[models.RoleStaff] {
+		user.Staff = granted[models.RoleStaff]
+	}
+	if err := tx.Omit(clause.Associations).Save(&user).Error; err != nil {
+		return nil, UserNotFoundError{err}
+	}
+	if event != "" {
+		if _, err := enqueueWebhookEvent(tx, event, user, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	for name := range managed {
+		var role models.Role
+		if err := tx.Where(&models.Role{Name: name}).First(&role).Error; err != nil {
+			continue
+		}
+		association := tx.Model(&user).Omit("Roles.*").Association("Roles")
+		if granted[name] {
+			err = association.Append(&role)
+		} else {
+			err = association.Delete(&role)
+		}
+		if err != nil {
+			return nil, RoleAssignmentError{err}
+		}
+	}
+	return &user, nil
+}
+
+// Verifies the given credentials against the directory, and syncs the
+// local user from it
+func (backend LDAPCredentialBackend) Verify(credentials validators.Credentials, isStaff bool) (*models.User, error) {
+	entry, err := backend.lookup(credentials)
+	if err != nil {
+		var user models.User
+		if backend.db.Where(&models.User{Email: credentials.Email}).First(&user).Error == nil {
+			return &user, err
+		}
+		return nil, err
+	}
+
+	var user *models.User
+	err = backend.db.Transaction(func(tx *gorm.DB) error {
+		user, err = backend.sync(tx, *entry)
+		return err
+	})
+	if err != nil {
+		return nil, AuthenticationError{err}
+	}
+
+	if user.Disabled || (isStaff && !user.Staff) {
+		return user, AuthenticationError{nil}
+	}
+	return user, nil
+}
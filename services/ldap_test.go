@@ -0,0 +1,262 @@
+package services
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/tests"
+	"gandalf/validators"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// Starts a mock directory with a service account and the staff member
+// John Doe, whose password is "password1234"
+func newTestDirectory() *tests.LDAPServer {
+	return tests.NewLDAPServer(
+		tests.LDAPEntry{DN: "cn=gandalf,ou=services,dc=acme,dc=com", Password: "service-password"},
+		tests.LDAPEntry{
+			DN:       "uid=johndoe,ou=people,dc=acme,dc=com",
+			Password: "password1234",
+			Attributes: map[string][]string{
+				"mail":      {"johndoe@acme.com"},
+				"givenName": {"John"},
+				"sn":        {"Doe"},
+				"memberOf":  {"cn=Admins,ou=groups,dc=acme,dc=com", "cn=everyone,ou=groups,dc=acme,dc=com"},
+			},
+		},
+	)
+}
+
+// Creates a backend bound to the given mock directory as its service
+// account, which grants the staff role to the admins group
+func newTestLDAPBackend(db *gorm.DB, directory *tests.LDAPServer) LDAPCredentialBackend {
+	return LDAPCredentialBackend{
+		db:               db,
+		url:              directory.URL(),
+		bindDN:           "cn=gandalf,ou=services,dc=acme,dc=com",
+		bindPassword:     "service-password",
+		baseDN:           "ou=people,dc=acme,dc=com",
+		userFilter:       "(mail=%s)",
+		nameAttribute:    "givenName",
+		surnameAttribute: "sn",
+		groupAttribute:   "memberOf",
+		groupRoles:       parseLDAPGroupRoles("admins:staff, developers:developer"),
+		rootCAs:          directory.RootCAs(),
+	}
+}
+
+func TestLDAPGroupRoles(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test pairs are parsed by group name", func(t *testing.T) {
+		groupRoles := parseLDAPGroupRoles("Admins:staff, developers:developer,malformed,:user")
+
+		assert.Equal(map[string]string{"admins": "staff", "developers": "developer"}, groupRoles)
+	})
+
+	t.Run("Test roles are granted by the group common name", func(t *testing.T) {
+		backend := LDAPCredentialBackend{groupRoles: parseLDAPGroupRoles("admins:staff")}
+
+		roles := backend.groupsRoles([]string{"cn=Admins,ou=groups,dc=acme,dc=com", "cn=other,dc=acme,dc=com", "not a dn"})
+
+		assert.Equal([]string{models.RoleStaff}, roles)
+	})
+}
+
+func TestLDAPDirectoryLookup(t *testing.T) {
+	assert := require.New(t)
+	directory := newTestDirectory()
+	defer directory.Close()
+
+	t.Run("Test entry is found and bound", func(t *testing.T) {
+		backend := newTestLDAPBackend(nil, directory)
+
+		entry, err := backend.lookup(validators.Credentials{Email: "johndoe@acme.com", Password: "password1234"})
+
+		assert.NoError(err)
+		assert.Equal("johndoe@acme.com", entry.Email)
+		assert.Equal("John", entry.Name)
+		assert.Equal("Doe", entry.Surname)
+		assert.Equal([]string{models.RoleStaff}, entry.Roles)
+	})
+
+	t.Run("Test wrong password is rejected", func(t *testing.T) {
+		backend := newTestLDAPBackend(nil, directory)
+
+		_, err := backend.lookup(validators.Credentials{Email: "johndoe@acme.com", Password: "wrong-password"})
+
+		assert.IsType(AuthenticationError{}, err)
+	})
+
+	t.Run("Test empty password is rejected", func(t *testing.T) {
+		backend := newTestLDAPBackend(nil, directory)
+
+		_, err := backend.lookup(validators.Credentials{Email: "johndoe@acme.com"})
+
+		assert.IsType(AuthenticationError{}, err)
+	})
+
+	t.Run("Test unknown email is rejected", func(t *testing.T) {
+		backend := newTestLDAPBackend(nil, directory)
+
+		_, err := backend.lookup(validators.Credentials{Email: "nobody@acme.com", Password: "password1234"})
+
+		assert.IsType(AuthenticationError{}, err)
+	})
+
+	t.Run("Test filter injection is escaped", func(t *testing.T) {
+		backend := newTestLDAPBackend(nil, directory)
+		backend.userFilter = "(&(objectClass=*)(mail=%s))"
+
+		_, err := backend.lookup(validators.Credentials{Email: "*)(mail=*", Password: "password1234"})
+
+		assert.IsType(AuthenticationError{}, err)
+	})
+
+	t.Run("Test directories not trusted are rejected", func(t *testing.T) {
+		backend := newTestLDAPBackend(nil, directory)
+		backend.rootCAs = nil
+
+		_, err := backend.lookup(validators.Credentials{Email: "johndoe@acme.com", Password: "password1234"})
+
+		assert.IsType(AuthenticationError{}, err)
+	})
+
+	t.Run("Test directories without TLS are rejected", func(t *testing.T) {
+		plain := newTestDirectory()
+		defer plain.Close()
+		plain.RefuseStartTLS()
+		backend := newTestLDAPBackend(nil, plain)
+
+		_, err := backend.lookup(validators.Credentials{Email: "johndoe@acme.com", Password: "password1234"})
+
+		assert.IsType(AuthenticationError{}, err)
+	})
+
+	t.Run("Test wrong service account is rejected", func(t *testing.T) {
+		backend := newTestLDAPBackend(nil, directory)
+		backend.bindPassword = "wrong-password"
+
+		_, err := backend.lookup(validators.Credentials{Email: "johndoe@acme.com", Password: "password1234"})
+
+		assert.IsType(AuthenticationError{}, err)
+	})
+}
+
+func TestLDAPCredentialBackend(t *testing.T) {
+	assert := require.New(t)
+	directory := newTestDirectory()
+	defer directory.Close()
+	credentials := validators.Credentials{Email: "johndoe@acme.com", Password: "password1234"}
+
+	t.Run("Test directory users are created on their first login", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		staff := models.NewRole(models.RoleStaff, "Staff", nil)
+		db.Create(&staff)
+		backend := newTestLDAPBackend(db, directory)
+
+		user, err := backend.Verify(credentials, true)
+
+		assert.NoError(err)
+		assert.True(user.Verified)
+		assert.True(user.Staff)
+		assert.Equal("John", user.Name)
+		assert.Equal([]string{models.RoleStaff}, roleNames(readUserRoles(db, *user)))
+	})
+
+	t.Run("Test roles granted by groups the user left are revoked", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		staff := models.NewRole(models.RoleStaff, "Staff", nil)
+		developer := models.NewRole(models.RoleDeveloper, "Developer", nil)
+		db.Create(&staff)
+		db.Create(&developer)
+		existing := tests.UserFactory()
+		existing.Email = credentials.Email
+		existing.Verified = true
+		existing.Roles = []models.Role{developer}
+		db.Create(&existing)
+		backend := newTestLDAPBackend(db, directory)
+
+		user, err := backend.Verify(credentials, false)
+
+		assert.NoError(err)
+		assert.Equal(existing.UUID, user.UUID)
+		assert.Equal([]string{models.RoleStaff}, roleNames(readUserRoles(db, *user)))
+	})
+
+	t.Run("Test unverified local users lose their password", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		existing := tests.UserFactory()
+		existing.Email = credentials.Email
+		existing.SetPassword("squatter-password")
+		db.Create(&existing)
+		backend := newTestLDAPBackend(db, directory)
+
+		user, err := backend.Verify(credentials, false)
+
+		assert.NoError(err)
+		assert.True(user.Verified)
+		assert.False(user.VerifyPassword("squatter-password"))
+	})
+
+	t.Run("Test users awaiting the guardian consent are rejected", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		existing := tests.UserFactory()
+		existing.Email = credentials.Email
+		existing.GuardianEmail = "guardian@test.com"
+		db.Create(&existing)
+		backend := newTestLDAPBackend(db, directory)
+
+		_, err := backend.Verify(credentials, false)
+
+		assert.IsType(AuthenticationError{}, err)
+		db.First(&existing, existing.ID)
+		assert.False(existing.Verified)
+	})
+
+	t.Run("Test disabled users are rejected", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		existing := tests.UserFactory()
+		existing.Email = credentials.Email
+		existing.Verified = true
+		existing.Disabled = true
+		db.Create(&existing)
+		backend := newTestLDAPBackend(db, directory)
+
+		user, err := backend.Verify(credentials, false)
+
+		assert.IsType(AuthenticationError{}, err)
+		assert.Equal(existing.UUID, user.UUID)
+	})
+
+	t.Run("Test backends are tried in turn", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		local := tests.UserFactory()
+		local.Verified = true
+		local.SetPassword("local-password")
+		db.Create(&local)
+		service := NewAuthService(db)
+		service.backends = []ICredentialBackend{newTestLDAPBackend(db, directory), NewDatabaseCredentialBackend(db)}
+
+		directoryUser, directoryErr := service.Authenticate(credentials, false, helpers.ClientInfo{})
+		localUser, localErr := service.Authenticate(validators.Credentials{Email: local.Email, Password: "local-password"}, false, helpers.ClientInfo{})
+		_, wrongErr := service.Authenticate(validators.Credentials{Email: local.Email, Password: "wrong-password"}, false, helpers.ClientInfo{})
+
+		assert.NoError(directoryErr)
+		assert.NoError(localErr)
+		assert.Equal(credentials.Email, directoryUser.Email)
+		assert.Equal(local.UUID, localUser.UUID)
+		assert.IsType(AuthenticationError{}, wrongErr)
+	})
+}
+
+// Returns the names of the given roles
+func roleNames(roles []models.Role) []string {
+	names := []string{}
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"gandalf/models"
+	"gandalf/validators"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Interface for legal service
+type ILegalService interface {
+	Current(app *models.App) []models.LegalDocument
+	List(app *models.App) []models.LegalDocument
+	Read(uuid uuid.UUID, app *models.App) (*models.LegalDocument, error)
+	Create(data validators.LegalDocumentCreateData, app *models.App) (*models.LegalDocument, error)
+	Publish(document *models.LegalDocument) error
+	Delete(document models.LegalDocument) error
+	Accept(user models.User, documents []string, audit AuditContext) error
+	Acceptances(user models.User) []models.LegalAcceptance
+}
+
+// Legal service manages the versions of the terms of service and of the
+// privacy policy, and keeps the proof of which of them every user accepted
+type LegalService struct {
+	db *gorm.DB
+}
+
+// Creates a new legal service
+func NewLegalService(db *gorm.DB) LegalService {
+	return LegalService{db}
+}
+
+// Restricts the given query to the documents of the given app, or to the
+// deployment wide ones when no app is given
+func scopedLegalDocuments(db *gorm.DB, app *models.App) *gorm.DB {
+	if app != nil {
+		return db.Where("app_id = ?", app.ID)
+	}
+	return db.Where("app_id IS NULL")
+}
+
+// Reads the current version of every kind of legal document, that is the
+// last one published. The ones of the given app are read along with the
+// deployment wide ones.
+func currentLegalDocuments(db *gorm.DB, app *models.App) []models.LegalDocument {
+	query := db.Select("DISTINCT ON (kind, app_id) *").Where("published_at IS NOT NULL")
+	if app != nil {
+		query = query.Where("app_id IS NULL OR app_id = ?", app.ID)
+	} else {
+		query = query.Where("app_id IS NULL")
+	}
+
+	var documents []models.LegalDocument
+	query.Preload("App").Order("kind, app_id NULLS FIRST, published_at DESC, id DESC").Find(&documents)
+	return documents
+}
+
+// Reads the current legal documents the given user has to accept before
+// logging in, or before authorizing the given app. Only the mandatory
+// versions have to be accepted, so the minor ones do not bother the users.
+func pendingLegalDocuments(db *gorm.DB, user models.User, app *models.App) []models.LegalDocument {
+	pending := []models.LegalDocument{}
+	for _, document := range currentLegalDocuments(db, app) {
+		if !document.Mandatory {
+			continue
+		}
+		var count int64
+		db.Model(&models.LegalAcceptance{}).Where("user_id = ? AND legal_document_id = ?", user.ID, document.ID).Count(&count)
+		if count == 0 {
+			pending = append(pending, document)
+		}
+	}
+	return pending
+}
+
+// Records that the given user accepts the published legal documents with
+// the given uuids. Documents he already accepted are skipped, so the first
+// acceptance is the one kept.
+func acceptLegalDocuments(db *gorm.DB, user models.User, documents []string, audit AuditContext) error {
+	var published []models.LegalDocument
+	if err := db.Where("uuid IN ? AND published_at IS NOT NULL", documents).Find(&published).Error; err != nil {
+		return LegalAcceptanceError{err}
+	}
+	if len(published) != len(uniqueStrings(documents)) {
+		return LegalDocumentNotFoundError{errors.New("unknown or unpublished legal document")}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		accepted := []string{}
+		for _, document := range published {
+			var count int64
+			tx.Model(&models.LegalAcceptance{}).Where("user_id = ? AND legal_document_id = ?", user.ID, document.ID).Count(&count)
+			if count > 0 {
+				continue
+			}
+			acceptance := models.NewLegalAcceptance(user, document, audit.Client.IP, audit.Client.UserAgent)
+			if err := tx.Create(&acceptance).Error; err != nil {
+				return LegalAcceptanceError{err}
+			}
+			accepted = append(accepted, document.UUID.String())
+		}
+		if len(accepted) == 0 {
+			return nil
+		}
+
+		metadata := models.AuditMetadata{"documents": strings.Join(accepted, ",")}
+		return recordAuditEvent(tx, audit, models.AuditActionAcceptLegal, models.AuditOutcomeSuccess, &user, metadata)
+	})
+}
+
+// Accepts the given legal documents on behalf of the given user, and checks
+// that no mandatory document is left for him to accept before logging in or
+// authorizing the given app
+func requireLegalAcceptance(db *gorm.DB, user models.User, accepted []string, app *models.App, audit AuditContext) error {
+	if len(accepted) > 0 {
+		if err := acceptLegalDocuments(db, user, accepted, audit); err != nil {
+			return err
+		}
+	}
+	if pending := pendingLegalDocuments(db, user, app); len(pending) > 0 {
+		return LegalAcceptanceRequiredError{Documents: pending}
+	}
+	return nil
+}
+
+// Returns the given values without duplicates
+func uniqueStrings(values []string) []string {
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// List the current legal documents, deployment wide and of the given app
+// if any
+func (service LegalService) Current(app *models.App) []models.LegalDocument {
+	return currentLegalDocuments(service.db, app)
+}
+
+// List every version of the legal documents of the given app, or of the
+// deployment wide ones when no app is given, including the unpublished ones
+func (service LegalService) List(app *models.App) []models.LegalDocument {
+	var documents []models.LegalDocument
+	scopedLegalDocuments(service.db, app).Order("kind, created_at DESC").Find(&documents)
+	return documents
+}
+
+// Read a legal document of the given app, or a deployment wide one when no
+// app is given, by its uuid
+func (service LegalService) Read(uuid uuid.UUID, app *models.App) (*models.LegalDocument, error) {
+	var document models.LegalDocument
+	if err := scopedLegalDocuments(service.db, app).Where("uuid = ?", uuid).First(&document).Error; err != nil {
+		return nil, LegalDocumentNotFoundError{err}
+	}
+	return &document, nil
+}
+
+// Creates a new unpublished version of a legal document of the given app,
+// or a deployment wide one when no app is given. Versions are unique per
+// kind.
+func (service LegalService) Create(data validators.LegalDocumentCreateData, app *models.App) (*models.LegalDocument, error) {
+	var count int64
+	scopedLegalDocuments(service.db, app).Model(&models.LegalDocument{}).
+		Where("kind = ? AND version = ?", data.Kind, data.Version).Count(&count)
+	if count > 0 {
+		return nil, LegalDocumentSaveError{fmt.Errorf("version %s of %s already exists", data.Version, data.Kind)}
+	}
+
+	document := models.NewLegalDocument(data.Kind, data.Version, data.Title, data.URL, data.Mandatory, app)
+	document.Summary = data.Summary
+	if err := service.db.Create(&document).Error; err != nil {
+		return nil, LegalDocumentSaveError{err}
+	}
+	return &document, nil
+}
+
+// Publishes the given legal document, which becomes the current version of
+// its kind. Mandatory documents have to be accepted from now on.
+func (service LegalService) Publish(document *models.LegalDocument) error {
+	if document.IsPublished() {
+		return LegalDocumentSaveError{errors.New("legal document already published")}
+	}
+	document.Publish(time.Now())
+	if err := service.db.Model(document).Update("published_at", document.PublishedAt).Error; err != nil {
+		return LegalDocumentSaveError{err}
+	}
+	return nil
+}
+
+// Deletes the given legal document. Only unpublished documents can be
+// deleted, as the published ones are the proof of what the users accepted.
+func (service LegalService) Delete(document models.LegalDocument) error {
+	if document.IsPublished() {
+		return LegalDocumentSaveError{errors.New("published legal documents cannot be deleted")}
+	}
+	if err := service.db.Delete(&document).Error; err != nil {
+		return LegalDocumentSaveError{err}
+	}
+	return nil
+}
+
+// Records that the given user accepts the published legal documents with
+// the given uuids, from the client of the given audit context
+func (service LegalService) Accept(user models.User, documents []string, audit AuditContext) error {
+	return acceptLegalDocuments(service.db, user, documents, audit)
+}
+
+// List the legal documents the given user accepted, the last ones first
+func (service LegalService) Acceptances(user models.User) []models.LegalAcceptance {
+	var acceptances []models.LegalAcceptance
+	service.db.Preload("LegalDocument").Preload("LegalDocument.App").
+		Where("user_id = ?", user.ID).Order("accepted_at DESC, id DESC").Find(&acceptances)
+	return acceptances
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/tests"
+	"gandalf/validators"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegalService(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test only drafts can be deleted", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewLegalService(db)
+		data := validators.LegalDocumentCreateData{Kind: models.LegalDocumentTerms, Version: "v1", Title: "Terms", URL: "https://example.com/terms"}
+
+		document, err := service.Create(data, nil)
+		assert.NoError(err)
+		_, err = service.Create(data, nil)
+		assert.IsType(LegalDocumentSaveError{}, err)
+
+		assert.NoError(service.Publish(document))
+		assert.IsType(LegalDocumentSaveError{}, service.Publish(document))
+		assert.IsType(LegalDocumentSaveError{}, service.Delete(*document))
+
+		db.Unscoped().Delete(document)
+	})
+
+	t.Run("Test mandatory versions must be accepted", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewLegalService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		first, _ := service.Create(validators.LegalDocumentCreateData{Kind: models.LegalDocumentTerms, Version: "v1", Title: "Terms", URL: "https://example.com/terms", Mandatory: true}, nil)
+		service.Publish(first)
+		assert.Len(pendingLegalDocuments(db, user, nil), 1)
+
+		audit := AuditContext{Actor: &user, Client: helpers.ClientInfo{IP: "203.0.113.7"}}
+		assert.NoError(requireLegalAcceptance(db, user, []string{first.UUID.String()}, nil, audit))
+
+		// Minor versions are current but do not have to be accepted
+		minor, _ := service.Create(validators.LegalDocumentCreateData{Kind: models.LegalDocumentTerms, Version: "v1.1", Title: "Terms", URL: "https://example.com/terms"}, nil)
+		service.Publish(minor)
+		assert.Equal(minor.ID, service.Current(nil)[0].ID)
+		assert.Empty(pendingLegalDocuments(db, user, nil))
+
+		second, _ := service.Create(validators.LegalDocumentCreateData{Kind: models.LegalDocumentTerms, Version: "v2", Title: "Terms", URL: "https://example.com/terms", Mandatory: true}, nil)
+		service.Publish(second)
+		err := requireLegalAcceptance(db, user, nil, nil, audit)
+		assert.IsType(LegalAcceptanceRequiredError{}, err)
+		assert.Equal(second.ID, err.(LegalAcceptanceRequiredError).Documents[0].ID)
+
+		acceptances := service.Acceptances(user)
+		assert.Len(acceptances, 1)
+		assert.Equal("v1", acceptances[0].LegalDocument.Version)
+		assert.Equal("203.0.113.7", acceptances[0].IP)
+
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(&models.LegalAcceptance{})
+		db.Unscoped().Delete(&user)
+		for _, document := range []*models.LegalDocument{first, minor, second} {
+			db.Unscoped().Delete(document)
+		}
+	})
+
+	t.Run("Test app documents are only required by the app", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewLegalService(db)
+		app := tests.AppFactory()
+		user := tests.UserFactory()
+		db.Create(&app)
+		db.Create(&user)
+
+		terms, _ := service.Create(validators.LegalDocumentCreateData{Kind: models.LegalDocumentTerms, Version: "v1", Title: "MyApp terms", URL: "https://example.com/terms", Mandatory: true}, &app)
+		service.Publish(terms)
+
+		assert.Empty(pendingLegalDocuments(db, user, nil))
+		assert.Len(pendingLegalDocuments(db, user, &app), 1)
+		assert.Len(service.Current(&app), 1)
+		assert.Empty(service.Current(nil))
+
+		err := service.Accept(user, []string{terms.UUID.String(), terms.UUID.String()}, AuditContext{Actor: &user})
+		assert.NoError(err)
+		assert.Empty(pendingLegalDocuments(db, user, &app))
+
+		draft, _ := service.Create(validators.LegalDocumentCreateData{Kind: models.LegalDocumentPrivacy, Version: "v1", Title: "MyApp privacy", URL: "https://example.com/privacy"}, &app)
+		assert.IsType(LegalDocumentNotFoundError{}, service.Accept(user, []string{draft.UUID.String()}, AuditContext{Actor: &user}))
+
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(&models.LegalAcceptance{})
+		db.Unscoped().Delete(terms)
+		db.Unscoped().Delete(draft)
+		db.Unscoped().Delete(&user)
+		db.Unscoped().Delete(&app)
+	})
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"gandalf/helpers"
+	"gandalf/models"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+// Event which identifies a logout token, as defined by OpenID Connect
+// Back-Channel Logout
+const backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// JWT sent to the apps in order to notify them that a session of the user
+// has ended. It is signed with the client secret of the app.
+type logoutTokenClaims struct {
+	jwt.StandardClaims
+	Session string              `json:"sid,omitempty"`
+	Events  map[string]struct{} `json:"events"`
+}
+
+// Creates claims for the logout token from the given params. The session
+// is optional.
+func newLogoutTokenClaims(issuer string, user models.User, app models.App, session *models.Session) logoutTokenClaims {
+	claims := logoutTokenClaims{
+		Events: map[string]struct{}{backchannelLogoutEvent: {}},
+		StandardClaims: jwt.StandardClaims{
+			Id:       uuid.Must(uuid.NewV4()).String(),
+			Issuer:   issuer,
+			Subject:  user.UUID.String(),
+			Audience: app.ClientID.String(),
+			IssuedAt: time.Now().Unix(),
+		},
+	}
+	if session != nil {
+		claims.Session = session.UUID.String()
+	}
+	return claims
+}
+
+// Interface for logout service
+type ILogoutService interface {
+	ReadIDTokenHint(idToken string) (*models.App, *models.Session, error)
+	EndSession(app models.App, session models.Session, redirectUri string) ([]models.Session, error)
+	NotifyApps(user models.User, sessions []models.Session)
+}
+
+// Logout service ends the sessions started through the apps and notifies
+// the apps about it
+type LogoutService struct {
+	db     *gorm.DB
+	issuer string `env:"OIDC_ISSUER"`
+
+	postForm func(url string, data url.Values) (resp *http.Response, err error)
+}
+
+// Creates a new logout service. The logout tokens cannot be sent to the
+// hosts of the internal network.
+func NewLogoutService(db *gorm.DB) LogoutService {
+	return LogoutService{
+		db:       db,
+		issuer:   os.Getenv("OIDC_ISSUER"),
+		postForm: newPublicHTTPClient().PostForm,
+	}
+}
+
+// Reads the app and the session of the given id token. Expired id tokens
+// are accepted since they are only used as a hint.
+func (service LogoutService) ReadIDTokenHint(idToken string) (*models.App, *models.Session, error) {
+	var app models.App
+	claims := idTokenClaims{}
+
+	keyfunc := func(token *jwt.Token) (interface{}, error) {
+		clientID, err := uuid.FromString(claims.Audience)
+		if err != nil {
+			return nil, err
+		}
+		if err := service.db.Where(&models.App{ClientID: clientID}).First(&app).Error; err != nil {
+			return nil, err
+		}
+		return []byte(app.ClientSecret), nil
+	}
+
+	if _, err := jwt.ParseWithClaims(idToken, &claims, keyfunc); err != nil {
+		var validationErr *jwt.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Errors != jwt.ValidationErrorExpired {
+			return nil, nil, IDTokenHintNotValidError{err}
+		}
+	}
+
+	sessionUUID, err := uuid.FromString(claims.Session)
+	if err != nil {
+		return nil, nil, IDTokenHintNotValidError{err}
+	}
+
+	var session models.Session
+	query := service.db.Preload("User").Where(&models.Session{UUID: sessionUUID, AppID: &app.ID})
+	if err := query.First(&session).Error; err != nil {
+		return nil, nil, IDTokenHintNotValidError{err}
+	}
+	if session.User.UUID.String() != claims.Subject {
+		return nil, nil, IDTokenHintNotValidError{}
+	}
+	return &app, &session, nil
+}
+
+// Ends the given session of the given app. The session the app was
+// authorized from and the sessions of the rest of apps authorized from it
+// are ended too. The redirect uri, if any, must be registered by the app.
+// Returns the sessions that have been ended.
+func (service LogoutService) EndSession(app models.App, session models.Session, redirectUri string) ([]models.Session, error) {
+	if redirectUri != "" && !helpers.PqStringArrayContains(app.PostLogoutRedirectUrls, redirectUri) {
+		return nil, PostLogoutRedirectUriDoesNotMatch{redirectUri: redirectUri}
+	}
+
+	root := session.ID
+	if session.ParentID != nil {
+		root = *session.ParentID
+	}
+
+	var sessions []models.Session
+	query := service.db.Where("(id = ? OR parent_id = ?) AND revoked_at IS NULL", root, root)
+	if err := query.Find(&sessions).Error; err != nil {
+		return nil, SessionNotFoundError{err}
+	}
+	if len(sessions) == 0 {
+		return sessions, nil
+	}
+
+	ids := make([]uint, len(sessions))
+	for i, ended := range sessions {
+		ids[i] = ended.ID
+	}
+	if err := service.db.Model(&models.Session{}).Where("id IN ?", ids).Update("revoked_at", time.Now()).Error; err != nil {
+		return nil, SessionNotFoundError{err}
+	}
+	return sessions, nil
+}
+
+// Sends a logout token to every app the given user is connected to which
+// has registered a back-channel logout url. The token identifies the ended
+// session of the app if it is among the given ones.
+func (service LogoutService) NotifyApps(user models.User, sessions []models.Session) {
+	var apps []models.App
+	service.db.Model(&user).Association("ConnectedApps").Find(&apps)
+
+	for _, app := range apps {
+		if app.BackchannelLogoutUrl == "" {
+			continue
+		}
+
+		var session *models.Session
+		for i := range sessions {
+			if sessions[i].AppID != nil && *sessions[i].AppID == app.ID {
+				session = &sessions[i]
+				break
+			}
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, newLogoutTokenClaims(service.issuer, user, app, session))
+		signedToken, err := token.SignedString([]byte(app.ClientSecret))
+		if err != nil {
+			log.Println(fmt.Sprintf("Logout token cannot be signed for app %s: %s", app.ClientID, err))
+			continue
+		}
+
+		response, err := service.postForm(app.BackchannelLogoutUrl, url.Values{"logout_token": {signedToken}})
+		if err != nil {
+			log.Println(fmt.Sprintf("%s -> %s", err.Error(), app.BackchannelLogoutUrl))
+			continue
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			log.Println(fmt.Sprintf("Logout cannot be notified to %s", app.BackchannelLogoutUrl))
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/tests"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+type postFormRecorder struct {
+	url  string
+	data url.Values
+}
+
+type mockPostForm struct {
+	postFormRecorder *postFormRecorder
+}
+
+func (mock *mockPostForm) postForm(url string, data url.Values) (resp *http.Response, err error) {
+	*mock.postFormRecorder = postFormRecorder{url, data}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}, nil
+}
+
+func TestLogoutServiceConstructor(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		db := tests.NewTestDatabase(true)
+		service := NewLogoutService(db)
+
+		assert.Equal(service.db, db)
+	})
+}
+
+func TestLogoutServiceReadIDTokenHint(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test read id token hint", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewLogoutService(db)
+		authService := NewAuthService(db)
+		app := tests.AppFactory()
+		db.Create(&app)
+		session, _ := createSession(db, app.User, &app, nil, helpers.ClientInfo{})
+
+		idToken := authService.generateIDToken(app.User, app, *session)
+		readApp, readSession, err := service.ReadIDTokenHint(idToken)
+
+		assert.NoError(err)
+		assert.Equal(app.ID, readApp.ID)
+		assert.Equal(session.ID, readSession.ID)
+		assert.Equal(app.User.ID, readSession.User.ID)
+
+		db.Unscoped().Delete(&app.User)
+	})
+
+	t.Run("Test read id token hint signed with other key", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewLogoutService(db)
+		app := tests.AppFactory()
+		db.Create(&app)
+		session, _ := createSession(db, app.User, &app, nil, helpers.ClientInfo{})
+
+		claims := newIDTokenClaims("", app.User, app, *session, 60)
+		idToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		_, _, err := service.ReadIDTokenHint(idToken)
+
+		assert.Error(err, IDTokenHintNotValidError{}.Error())
+
+		db.Unscoped().Delete(&app.User)
+	})
+}
+
+func TestLogoutServiceEndSession(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test end session with its parent and siblings", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewLogoutService(db)
+		app := tests.AppFactory()
+		db.Create(&app)
+		parent, _ := createSession(db, app.User, nil, nil, helpers.ClientInfo{})
+		session, _ := createSession(db, app.User, &app, parent, helpers.ClientInfo{})
+		createSession(db, app.User, &app, parent, helpers.ClientInfo{})
+		other, _ := createSession(db, app.User, nil, nil, helpers.ClientInfo{})
+
+		sessions, err := service.EndSession(app, *session, "")
+
+		assert.NoError(err)
+		assert.Equal(3, len(sessions))
+		_, err = readActiveSession(db, other.UUID)
+		assert.NoError(err)
+		_, err = readActiveSession(db, parent.UUID)
+		assert.Error(err)
+
+		db.Unscoped().Delete(&app.User)
+	})
+
+	t.Run("Test end session with not registered redirect", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewLogoutService(db)
+		app := tests.AppFactory()
+		db.Create(&app)
+		session, _ := createSession(db, app.User, &app, nil, helpers.ClientInfo{})
+
+		_, err := service.EndSession(app, *session, faker.Internet().Url())
+
+		assert.Error(err, PostLogoutRedirectUriDoesNotMatch{}.Error())
+		_, err = readActiveSession(db, session.UUID)
+		assert.NoError(err)
+
+		db.Unscoped().Delete(&app.User)
+	})
+}
+
+func TestLogoutServiceNotifyApps(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test notify connected apps", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		mock := mockPostForm{new(postFormRecorder)}
+		service := NewLogoutService(db)
+		service.postForm = mock.postForm
+		app := tests.AppFactory()
+		app.BackchannelLogoutUrl = faker.Internet().Url()
+		db.Create(&app)
+		db.Model(&app.User).Association("ConnectedApps").Append(&app)
+		session, _ := createSession(db, app.User, &app, nil, helpers.ClientInfo{})
+
+		service.NotifyApps(app.User, []models.Session{*session})
+
+		assert.Equal(app.BackchannelLogoutUrl, mock.postFormRecorder.url)
+		claims := logoutTokenClaims{}
+		_, err := jwt.ParseWithClaims(
+			mock.postFormRecorder.data.Get("logout_token"), &claims,
+			func(token *jwt.Token) (interface{}, error) { return []byte(app.ClientSecret), nil },
+		)
+		assert.NoError(err)
+		assert.Equal(session.UUID.String(), claims.Session)
+		assert.Equal(app.User.UUID.String(), claims.Subject)
+		assert.Contains(claims.Events, backchannelLogoutEvent)
+
+		db.Unscoped().Delete(&app.User)
+	})
+}
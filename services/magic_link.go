@@ -0,0 +1,147 @@
+package services
+
+import (
+	"gandalf/bindings"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/validators"
+	"os"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Interface for magic link service
+type IMagicLinkService interface {
+	Send(user models.User, binding string, data validators.MagicLinkRequestData) error
+	Redeem(data validators.MagicLinkRedeemData, client helpers.ClientInfo) (*models.User, *validators.OauthAuthorizeData, error)
+}
+
+// Magic link service lets the users log in without password, by following
+// a single use link mailed to them
+type MagicLinkService struct {
+	db       *gorm.DB
+	tokenTTL time.Duration `env:"MAGIC_LINK_TTL"`
+
+	phoneCodeAttempts int `env:"PHONE_CODE_MAX_ATTEMPTS"`
+}
+
+// Creates a new magic link service
+func NewMagicLinkService(db *gorm.DB) MagicLinkService {
+	return MagicLinkService{
+		db:       db,
+		tokenTTL: time.Duration(helpers.GetEnvInt("MAGIC_LINK_TTL", 15)),
+
+		phoneCodeAttempts: helpers.GetEnvInt("PHONE_CODE_MAX_ATTEMPTS", 5),
+	}
+}
+
+// Issues a magic link for the given user, bound to the browser which holds
+// the given binding secret, and enqueues the email which carries it. The
+// email is always rendered with the deployment templates, since the link
+// logs the user in.
+func (service MagicLinkService) Send(user models.User, binding string, data validators.MagicLinkRequestData) error {
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		secret, err := issueOneTimeToken(tx, user, models.TokenPurposeMagicLink, service.tokenTTL, true)
+		if err != nil {
+			return err
+		}
+
+		var token models.OneTimeToken
+		if err := tx.Where(&models.OneTimeToken{Digest: security.Sha256Digest(secret)}).First(&token).Error; err != nil {
+			return OneTimeTokenIssueError{err}
+		}
+
+		link := models.NewMagicLink(token, binding)
+		if data.Authorize != nil {
+			appClientID := uuid.FromStringOrNil(data.Authorize.ClientID)
+			link.ClientID = &appClientID
+			link.RedirectURI = data.Authorize.RedirectURI
+			link.Scopes = bindings.ScopeArrayToStringArray(data.Authorize.Scopes)
+			link.State = data.Authorize.State
+		}
+		if err := tx.Create(&link).Error; err != nil {
+			return OneTimeTokenIssueError{err}
+		}
+
+		magicLink := notificationLink(os.Getenv("MAGIC_LINK_URL"), map[string]string{"code": secret})
+		rendered, err := renderNotification(tx, models.OutboxKindUserMagicLink, user.Locale, nil, NotificationContext{
+			Name:  user.Name,
+			Email: user.Email,
+			Link:  magicLink,
+		})
+		if err != nil {
+			return OutboxEnqueueError{err}
+		}
+
+		return enqueueNotification(tx, models.OutboxKindUserMagicLink, user.Email, validators.PelipperUserMagicLink{
+			Email:     user.Email,
+			Name:      user.Name,
+			Subject:   rendered.Subject,
+			MagicLink: magicLink,
+			Locale:    rendered.Locale,
+			Text:      rendered.Text,
+			HTML:      rendered.HTML,
+		})
+	})
+}
+
+// Redeems the magic link with the given code from the browser which holds
+// the given binding secret, and returns the user it logs in along with the
+// authorize request the login continues, if any. Links redeemed from other
+// browsers are rejected and stay usable. Users who use their phone as
+// second factor must also give the code sent to it, and the pending
+// mandatory legal documents must be accepted; when a step is missing the
+// user is returned along with the error and the link stays usable, so it
+// can be redeemed again with it.
+func (service MagicLinkService) Redeem(data validators.MagicLinkRedeemData, client helpers.ClientInfo) (*models.User, *validators.OauthAuthorizeData, error) {
+	audit := AuditContext{Client: client}
+	metadata := models.AuditMetadata{"method": "magic-link"}
+
+	var link models.MagicLink
+	var owner *models.User
+	user, err := consumeOneTimeToken(service.db, data.Code, models.TokenPurposeMagicLink, func(token models.OneTimeToken) error {
+		if err := service.db.Where(&models.MagicLink{TokenID: token.ID}).First(&link).Error; err != nil {
+			return OneTimeTokenNotValidError{err}
+		}
+		if !link.IsBoundTo(data.Binding) {
+			return MagicLinkBindingError{}
+		}
+		var found models.User
+		if err := service.db.First(&found, token.UserID).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		owner = &found
+		if owner.Disabled {
+			return AuthenticationError{nil}
+		}
+		return requireLoginSteps(service.db, *owner, data.PhoneCode, data.AcceptedDocuments, service.phoneCodeAttempts, audit, metadata)
+	})
+	if isMissingLoginStep(err) {
+		return owner, nil, err
+	}
+	if err != nil {
+		recordAuditEvent(service.db, audit, models.AuditActionLogin, models.AuditOutcomeFailure, owner, metadata)
+		return nil, nil, err
+	}
+
+	audit.Actor = user
+	if err := recordAuditEvent(service.db, audit, models.AuditActionLogin, models.AuditOutcomeSuccess, user, metadata); err != nil {
+		return nil, nil, err
+	}
+
+	if !link.HasPendingAuthorization() {
+		return user, nil, nil
+	}
+	authorize := validators.OauthAuthorizeData{
+		ClientID:    link.ClientID.String(),
+		RedirectURI: link.RedirectURI,
+		State:       link.State,
+	}
+	for _, scope := range link.Scopes {
+		authorize.Scopes = append(authorize.Scopes, bindings.Scope(scope))
+	}
+	return user, &authorize, nil
+}
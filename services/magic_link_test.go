@@ -0,0 +1,164 @@
+package services
+
+import (
+	"encoding/json"
+	"gandalf/bindings"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func sendTestMagicLink(db *gorm.DB, user models.User, data validators.MagicLinkRequestData) string {
+	service := NewMagicLinkService(db)
+	service.Send(user, "binding", data)
+
+	var message models.OutboxMessage
+	db.Where(&models.OutboxMessage{Kind: models.OutboxKindUserMagicLink, Recipient: user.Email}).Last(&message)
+	var payload validators.PelipperUserMagicLink
+	json.Unmarshal([]byte(message.Payload), &payload)
+
+	link, _ := url.Parse(payload.MagicLink)
+	return link.Query().Get("code")
+}
+
+func TestMagicLinkService(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test redeem logs the user in", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewMagicLinkService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+		code := sendTestMagicLink(db, user, validators.MagicLinkRequestData{Email: user.Email})
+
+		loggedIn, authorize, err := service.Redeem(validators.MagicLinkRedeemData{Code: code, Binding: "binding"}, helpers.ClientInfo{})
+
+		assert.NoError(err)
+		assert.Equal(user.ID, loggedIn.ID)
+		assert.Nil(authorize)
+	})
+
+	t.Run("Test email ignores the app templates", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		user := tests.UserFactory()
+		db.Create(&user)
+		app := tests.AppFactory()
+		db.Create(&app)
+		db.Model(&user).Association("ConnectedApps").Append(&app)
+		template := models.NewNotificationTemplate(models.OutboxKindUserMagicLink, user.Locale, "App", "{{.Link}}", "", &app)
+		db.Create(&template)
+
+		sendTestMagicLink(db, user, validators.MagicLinkRequestData{
+			Email: user.Email,
+			Authorize: &validators.OauthAuthorizeData{
+				ClientID:    app.ClientID.String(),
+				RedirectURI: "https://app.test/callback",
+				Scopes:      []bindings.Scope{security.ScopeUserRead},
+			},
+		})
+
+		var message models.OutboxMessage
+		db.Where(&models.OutboxMessage{Kind: models.OutboxKindUserMagicLink, Recipient: user.Email}).Last(&message)
+		var payload validators.PelipperUserMagicLink
+		json.Unmarshal([]byte(message.Payload), &payload)
+		assert.NotEqual("App", payload.Subject)
+
+		db.Unscoped().Delete(&template)
+	})
+
+	t.Run("Test link is single use", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewMagicLinkService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+		code := sendTestMagicLink(db, user, validators.MagicLinkRequestData{Email: user.Email})
+		data := validators.MagicLinkRedeemData{Code: code, Binding: "binding"}
+
+		service.Redeem(data, helpers.ClientInfo{})
+		_, _, err := service.Redeem(data, helpers.ClientInfo{})
+
+		assert.IsType(OneTimeTokenNotValidError{}, err)
+	})
+
+	t.Run("Test forwarded link is rejected and stays usable", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewMagicLinkService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+		code := sendTestMagicLink(db, user, validators.MagicLinkRequestData{Email: user.Email})
+
+		_, _, err := service.Redeem(validators.MagicLinkRedeemData{Code: code, Binding: "forwarded"}, helpers.ClientInfo{})
+		assert.IsType(MagicLinkBindingError{}, err)
+
+		_, _, err = service.Redeem(validators.MagicLinkRedeemData{Code: code, Binding: "binding"}, helpers.ClientInfo{})
+		assert.NoError(err)
+	})
+
+	t.Run("Test redeem continues the authorize request", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewMagicLinkService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+		app := tests.AppFactory()
+		db.Create(&app)
+		code := sendTestMagicLink(db, user, validators.MagicLinkRequestData{
+			Email: user.Email,
+			Authorize: &validators.OauthAuthorizeData{
+				ClientID:    app.ClientID.String(),
+				RedirectURI: "https://app.test/callback",
+				Scopes:      []bindings.Scope{security.ScopeUserRead},
+				State:       "state",
+			},
+		})
+
+		_, authorize, err := service.Redeem(validators.MagicLinkRedeemData{Code: code, Binding: "binding"}, helpers.ClientInfo{})
+
+		assert.NoError(err)
+		assert.Equal(app.ClientID.String(), authorize.ClientID)
+		assert.Equal("https://app.test/callback", authorize.RedirectURI)
+		assert.Equal([]bindings.Scope{security.ScopeUserRead}, authorize.Scopes)
+		assert.Equal("state", authorize.State)
+	})
+
+	t.Run("Test disabled users cannot redeem", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewMagicLinkService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+		code := sendTestMagicLink(db, user, validators.MagicLinkRequestData{Email: user.Email})
+		db.Model(&user).Update("disabled", true)
+
+		_, _, err := service.Redeem(validators.MagicLinkRedeemData{Code: code, Binding: "binding"}, helpers.ClientInfo{})
+
+		assert.IsType(AuthenticationError{}, err)
+	})
+	t.Run("Test links are redeemed again with the pending legal documents", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewMagicLinkService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+		terms := models.NewLegalDocument(models.LegalDocumentTerms, "v1", "Terms", "https://example.com/terms", true, nil)
+		db.Create(&terms)
+		code := sendTestMagicLink(db, user, validators.MagicLinkRequestData{Email: user.Email})
+		data := validators.MagicLinkRedeemData{Code: code, Binding: "binding"}
+
+		pending, _, err := service.Redeem(data, helpers.ClientInfo{})
+		assert.IsType(LegalAcceptanceRequiredError{}, err)
+		assert.Equal(user.ID, pending.ID)
+
+		data.AcceptedDocuments = []string{terms.UUID.String()}
+		loggedIn, _, err := service.Redeem(data, helpers.ClientInfo{})
+		assert.NoError(err)
+		assert.Equal(user.ID, loggedIn.ID)
+
+		db.Unscoped().Where("1 = 1").Delete(&models.LegalAcceptance{})
+		db.Unscoped().Delete(&terms)
+	})
+}
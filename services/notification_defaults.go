@@ -0,0 +1,201 @@
+package services
+
+import "gandalf/models"
+
+// Built-in content of every notification kind, used when neither the app
+// nor the deployment have overridden it. Every kind must be defined in the
+// default locale.
+var defaultNotificationTemplates = map[string]map[string]models.NotificationTemplate{
+	models.OutboxKindUserVerifyEmail: {
+		"en": models.NewNotificationTemplate(
+			models.OutboxKindUserVerifyEmail, "en",
+			"Verify your email",
+			"Hi {{.Name}},\n\nPlease verify your email by following this link:\n\n{{.Link}}\n",
+			`<p>Hi {{.Name}},</p><p>Please verify your email by following <a href="{{.Link}}">this link</a>.</p>`,
+			nil,
+		),
+		"es": models.NewNotificationTemplate(
+			models.OutboxKindUserVerifyEmail, "es",
+			"Verifica tu email",
+			"Hola {{.Name}},\n\nPor favor, verifica tu email siguiendo este enlace:\n\n{{.Link}}\n",
+			`<p>Hola {{.Name}},</p><p>Por favor, verifica tu email siguiendo <a href="{{.Link}}">este enlace</a>.</p>`,
+			nil,
+		),
+	},
+	models.OutboxKindUserChangePassword: {
+		"en": models.NewNotificationTemplate(
+			models.OutboxKindUserChangePassword, "en",
+			"Reset your password",
+			"Hi {{.Name}},\n\nYou can choose a new password by following this link:\n\n{{.Link}}\n\nIf you did not ask for it, you can ignore this email.\n",
+			`<p>Hi {{.Name}},</p><p>You can choose a new password by following <a href="{{.Link}}">this link</a>.</p><p>If you did not ask for it, you can ignore this email.</p>`,
+			nil,
+		),
+		"es": models.NewNotificationTemplate(
+			models.OutboxKindUserChangePassword, "es",
+			"Restablece tu contraseña",
+			"Hola {{.Name}},\n\nPuedes elegir una nueva contraseña siguiendo este enlace:\n\n{{.Link}}\n\nSi no lo has pedido, puedes ignorar este email.\n",
+			`<p>Hola {{.Name}},</p><p>Puedes elegir una nueva contraseña siguiendo <a href="{{.Link}}">este enlace</a>.</p><p>Si no lo has pedido, puedes ignorar este email.</p>`,
+			nil,
+		),
+	},
+	models.OutboxKindUserSignupAttempt: {
+		"en": models.NewNotificationTemplate(
+			models.OutboxKindUserSignupAttempt, "en",
+			"Someone tried to sign up with your email",
+			"Hi {{.Name}},\n\nSomeone tried to sign up with your email. If it was you, you already have an account and can log in or reset your password.\n",
+			`<p>Hi {{.Name}},</p><p>Someone tried to sign up with your email. If it was you, you already have an account and can log in or reset your password.</p>`,
+			nil,
+		),
+		"es": models.NewNotificationTemplate(
+			models.OutboxKindUserSignupAttempt, "es",
+			"Alguien ha intentado registrarse con tu email",
+			"Hola {{.Name}},\n\nAlguien ha intentado registrarse con tu email. Si has sido tú, ya tienes una cuenta y puedes iniciar sesión o restablecer tu contraseña.\n",
+			`<p>Hola {{.Name}},</p><p>Alguien ha intentado registrarse con tu email. Si has sido tú, ya tienes una cuenta y puedes iniciar sesión o restablecer tu contraseña.</p>`,
+			nil,
+		),
+	},
+	models.OutboxKindOrganizationInvitation: {
+		"en": models.NewNotificationTemplate(
+			models.OutboxKindOrganizationInvitation, "en",
+			"{{.InvitedBy}} invited you to join {{.OrganizationName}}",
+			"Hi,\n\n{{.InvitedBy}} invited you to join {{.OrganizationName}}. You can accept the invitation by following this link:\n\n{{.Link}}\n",
+			`<p>Hi,</p><p>{{.InvitedBy}} invited you to join {{.OrganizationName}}. You can accept the invitation by following <a href="{{.Link}}">this link</a>.</p>`,
+			nil,
+		),
+		"es": models.NewNotificationTemplate(
+			models.OutboxKindOrganizationInvitation, "es",
+			"{{.InvitedBy}} te ha invitado a unirte a {{.OrganizationName}}",
+			"Hola,\n\n{{.InvitedBy}} te ha invitado a unirte a {{.OrganizationName}}. Puedes aceptar la invitación siguiendo este enlace:\n\n{{.Link}}\n",
+			`<p>Hola,</p><p>{{.InvitedBy}} te ha invitado a unirte a {{.OrganizationName}}. Puedes aceptar la invitación siguiendo <a href="{{.Link}}">este enlace</a>.</p>`,
+			nil,
+		),
+	},
+	models.OutboxKindUserMagicLink: {
+		"en": models.NewNotificationTemplate(
+			models.OutboxKindUserMagicLink, "en",
+			"Your sign-in link",
+			"Hi {{.Name}},\n\nYou can sign in by following this link, from the same browser you asked for it:\n\n{{.Link}}\n\nThe link can only be used once. If you did not ask for it, you can ignore this email.\n",
+			`<p>Hi {{.Name}},</p><p>You can sign in by following <a href="{{.Link}}">this link</a>, from the same browser you asked for it.</p><p>The link can only be used once. If you did not ask for it, you can ignore this email.</p>`,
+			nil,
+		),
+		"es": models.NewNotificationTemplate(
+			models.OutboxKindUserMagicLink, "es",
+			"Tu enlace de acceso",
+			"Hola {{.Name}},\n\nPuedes iniciar sesión siguiendo este enlace, desde el mismo navegador en el que lo pediste:\n\n{{.Link}}\n\nEl enlace solo puede usarse una vez. Si no lo has pedido, puedes ignorar este email.\n",
+			`<p>Hola {{.Name}},</p><p>Puedes iniciar sesión siguiendo <a href="{{.Link}}">este enlace</a>, desde el mismo navegador en el que lo pediste.</p><p>El enlace solo puede usarse una vez. Si no lo has pedido, puedes ignorar este email.</p>`,
+			nil,
+		),
+	},
+	models.OutboxKindUserDataExport: {
+		"en": models.NewNotificationTemplate(
+			models.OutboxKindUserDataExport, "en",
+			"Your data export is ready",
+			"Hi {{.Name}},\n\nThe copy of your data you asked for is ready. You can download it, signed in to your account, by following this link:\n\n{{.Link}}\n\nThe link is only available for a limited time. If you did not ask for it, change your password.\n",
+			`<p>Hi {{.Name}},</p><p>The copy of your data you asked for is ready. You can download it, signed in to your account, by following <a href="{{.Link}}">this link</a>.</p><p>The link is only available for a limited time. If you did not ask for it, change your password.</p>`,
+			nil,
+		),
+		"es": models.NewNotificationTemplate(
+			models.OutboxKindUserDataExport, "es",
+			"Tu exportación de datos está lista",
+			"Hola {{.Name}},\n\nLa copia de tus datos que pediste está lista. Puedes descargarla, con tu sesión iniciada, siguiendo este enlace:\n\n{{.Link}}\n\nEl enlace solo está disponible durante un tiempo limitado. Si no la has pedido, cambia tu contraseña.\n",
+			`<p>Hola {{.Name}},</p><p>La copia de tus datos que pediste está lista. Puedes descargarla, con tu sesión iniciada, siguiendo <a href="{{.Link}}">este enlace</a>.</p><p>El enlace solo está disponible durante un tiempo limitado. Si no la has pedido, cambia tu contraseña.</p>`,
+			nil,
+		),
+	},
+	models.OutboxKindGuardianConsent: {
+		"en": models.NewNotificationTemplate(
+			models.OutboxKindGuardianConsent, "en",
+			"{{.Name}} needs your consent to sign up",
+			"Hi,\n\n{{.Name}} has signed up as {{.Email}} and gave this address as the one of a parent or guardian. The account cannot be used until you consent to it by following this link:\n\n{{.Link}}\n\nIf you do not know {{.Name}}, you can ignore this email.\n",
+			`<p>Hi,</p><p>{{.Name}} has signed up as {{.Email}} and gave this address as the one of a parent or guardian. The account cannot be used until you consent to it by following <a href="{{.Link}}">this link</a>.</p><p>If you do not know {{.Name}}, you can ignore this email.</p>`,
+			nil,
+		),
+		"es": models.NewNotificationTemplate(
+			models.OutboxKindGuardianConsent, "es",
+			"{{.Name}} necesita tu consentimiento para registrarse",
+			"Hola,\n\n{{.Name}} se ha registrado como {{.Email}} y ha indicado esta dirección como la de su madre, padre o tutor. La cuenta no podrá usarse hasta que des tu consentimiento siguiendo este enlace:\n\n{{.Link}}\n\nSi no conoces a {{.Name}}, puedes ignorar este email.\n",
+			`<p>Hola,</p><p>{{.Name}} se ha registrado como {{.Email}} y ha indicado esta dirección como la de su madre, padre o tutor. La cuenta no podrá usarse hasta que des tu consentimiento siguiendo <a href="{{.Link}}">este enlace</a>.</p><p>Si no conoces a {{.Name}}, puedes ignorar este email.</p>`,
+			nil,
+		),
+	},
+	models.OutboxKindAlertPasswordChanged: {
+		"en": models.NewNotificationTemplate(
+			models.OutboxKindAlertPasswordChanged, "en",
+			"Your password has been changed",
+			"Hi {{.Name}},\n\nThe password of your account has just been changed{{if .IP}} from {{.IP}}{{end}}.\n\nIf it was not you, lock your account right now by following this link:\n\n{{.Link}}\n",
+			`<p>Hi {{.Name}},</p><p>The password of your account has just been changed{{if .IP}} from {{.IP}}{{end}}.</p><p>If it was not you, <a href="{{.Link}}">lock your account</a> right now.</p>`,
+			nil,
+		),
+		"es": models.NewNotificationTemplate(
+			models.OutboxKindAlertPasswordChanged, "es",
+			"Tu contraseña ha sido cambiada",
+			"Hola {{.Name}},\n\nLa contraseña de tu cuenta acaba de ser cambiada{{if .IP}} desde {{.IP}}{{end}}.\n\nSi no has sido tú, bloquea tu cuenta ahora mismo siguiendo este enlace:\n\n{{.Link}}\n",
+			`<p>Hola {{.Name}},</p><p>La contraseña de tu cuenta acaba de ser cambiada{{if .IP}} desde {{.IP}}{{end}}.</p><p>Si no has sido tú, <a href="{{.Link}}">bloquea tu cuenta</a> ahora mismo.</p>`,
+			nil,
+		),
+	},
+	models.OutboxKindAlertAppAuthorized: {
+		"en": models.NewNotificationTemplate(
+			models.OutboxKindAlertAppAuthorized, "en",
+			"{{.AppName}} can now access your account",
+			"Hi {{.Name}},\n\nYou have just authorized {{.AppName}} to access your account.\n\nIf it was not you, lock your account right now by following this link:\n\n{{.Link}}\n",
+			`<p>Hi {{.Name}},</p><p>You have just authorized {{.AppName}} to access your account.</p><p>If it was not you, <a href="{{.Link}}">lock your account</a> right now.</p>`,
+			nil,
+		),
+		"es": models.NewNotificationTemplate(
+			models.OutboxKindAlertAppAuthorized, "es",
+			"{{.AppName}} ya puede acceder a tu cuenta",
+			"Hola {{.Name}},\n\nAcabas de autorizar a {{.AppName}} a acceder a tu cuenta.\n\nSi no has sido tú, bloquea tu cuenta ahora mismo siguiendo este enlace:\n\n{{.Link}}\n",
+			`<p>Hola {{.Name}},</p><p>Acabas de autorizar a {{.AppName}} a acceder a tu cuenta.</p><p>Si no has sido tú, <a href="{{.Link}}">bloquea tu cuenta</a> ahora mismo.</p>`,
+			nil,
+		),
+	},
+	models.OutboxKindAlertNewDevice: {
+		"en": models.NewNotificationTemplate(
+			models.OutboxKindAlertNewDevice, "en",
+			"New login to your account",
+			"Hi {{.Name}},\n\nYour account has just been used to log in from a new device:\n\n{{.Device}} ({{.IP}})\n\nIf it was not you, lock your account right now by following this link:\n\n{{.Link}}\n",
+			`<p>Hi {{.Name}},</p><p>Your account has just been used to log in from a new device:</p><p>{{.Device}} ({{.IP}})</p><p>If it was not you, <a href="{{.Link}}">lock your account</a> right now.</p>`,
+			nil,
+		),
+		"es": models.NewNotificationTemplate(
+			models.OutboxKindAlertNewDevice, "es",
+			"Nuevo inicio de sesión en tu cuenta",
+			"Hola {{.Name}},\n\nTu cuenta acaba de iniciar sesión desde un nuevo dispositivo:\n\n{{.Device}} ({{.IP}})\n\nSi no has sido tú, bloquea tu cuenta ahora mismo siguiendo este enlace:\n\n{{.Link}}\n",
+			`<p>Hola {{.Name}},</p><p>Tu cuenta acaba de iniciar sesión desde un nuevo dispositivo:</p><p>{{.Device}} ({{.IP}})</p><p>Si no has sido tú, <a href="{{.Link}}">bloquea tu cuenta</a> ahora mismo.</p>`,
+			nil,
+		),
+	},
+	models.OutboxKindAlertImpersonated: {
+		"en": models.NewNotificationTemplate(
+			models.OutboxKindAlertImpersonated, "en",
+			"Our support team has accessed your account",
+			"Hi {{.Name}},\n\nA member of our support team has just started acting as you to look into an issue with your account. The access expires on its own shortly, and everything done with it is recorded.\n\nIf you did not ask for support, lock your account right now by following this link:\n\n{{.Link}}\n",
+			`<p>Hi {{.Name}},</p><p>A member of our support team has just started acting as you to look into an issue with your account. The access expires on its own shortly, and everything done with it is recorded.</p><p>If you did not ask for support, <a href="{{.Link}}">lock your account</a> right now.</p>`,
+			nil,
+		),
+		"es": models.NewNotificationTemplate(
+			models.OutboxKindAlertImpersonated, "es",
+			"Nuestro equipo de soporte ha accedido a tu cuenta",
+			"Hola {{.Name}},\n\nUn miembro de nuestro equipo de soporte acaba de empezar a actuar como tú para revisar un problema con tu cuenta. El acceso caduca por sí solo en breve, y todo lo que se haga con él queda registrado.\n\nSi no has pedido soporte, bloquea tu cuenta ahora mismo siguiendo este enlace:\n\n{{.Link}}\n",
+			`<p>Hola {{.Name}},</p><p>Un miembro de nuestro equipo de soporte acaba de empezar a actuar como tú para revisar un problema con tu cuenta. El acceso caduca por sí solo en breve, y todo lo que se haga con él queda registrado.</p><p>Si no has pedido soporte, <a href="{{.Link}}">bloquea tu cuenta</a> ahora mismo.</p>`,
+			nil,
+		),
+	},
+	models.OutboxKindAlertPhoneChanged: {
+		"en": models.NewNotificationTemplate(
+			models.OutboxKindAlertPhoneChanged, "en",
+			"Your phone settings have been changed",
+			"Hi {{.Name}},\n\nThe phone of your account has just been changed or stopped being asked for when you log in{{if .IP}}, from {{.IP}}{{end}}.\n\nIf it was not you, lock your account right now by following this link:\n\n{{.Link}}\n",
+			`<p>Hi {{.Name}},</p><p>The phone of your account has just been changed or stopped being asked for when you log in{{if .IP}}, from {{.IP}}{{end}}.</p><p>If it was not you, <a href="{{.Link}}">lock your account</a> right now.</p>`,
+			nil,
+		),
+		"es": models.NewNotificationTemplate(
+			models.OutboxKindAlertPhoneChanged, "es",
+			"La configuración de tu teléfono ha cambiado",
+			"Hola {{.Name}},\n\nEl teléfono de tu cuenta acaba de ser cambiado o ha dejado de pedirse al iniciar sesión{{if .IP}}, desde {{.IP}}{{end}}.\n\nSi no has sido tú, bloquea tu cuenta ahora mismo siguiendo este enlace:\n\n{{.Link}}\n",
+			`<p>Hola {{.Name}},</p><p>El teléfono de tu cuenta acaba de ser cambiado o ha dejado de pedirse al iniciar sesión{{if .IP}}, desde {{.IP}}{{end}}.</p><p>Si no has sido tú, <a href="{{.Link}}">bloquea tu cuenta</a> ahora mismo.</p>`,
+			nil,
+		),
+	},
+}
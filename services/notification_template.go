@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"gandalf/models"
+	"gandalf/validators"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Interface for notification template service
+type INotificationTemplateService interface {
+	List(app *models.App) []models.NotificationTemplate
+	Save(kind string, locale string, data validators.NotificationTemplateData, app *models.App) (*models.NotificationTemplate, error)
+	Delete(kind string, locale string, app *models.App) error
+	Preview(data validators.NotificationTemplatePreviewData) (*RenderedNotification, error)
+}
+
+// Data the notification templates are rendered with. Fields which do not
+// apply to a notification kind are left empty.
+type NotificationContext struct {
+	Name             string
+	Email            string
+	Link             string
+	OrganizationName string
+	InvitedBy        string
+	AppName          string
+	Device           string
+	IP               string
+}
+
+// Sample data the templates are validated and previewed with
+var sampleNotificationContext = NotificationContext{
+	Name:             "John",
+	Email:            "johndoe@example.com",
+	Link:             "https://example.com/?code=hG3k0-aPq9Lm2xZ7",
+	OrganizationName: "Fellowship",
+	InvitedBy:        "Gandalf",
+	AppName:          "MySuperApp",
+	Device:           "Mozilla/5.0 (X11; Linux x86_64) Firefox/92.0",
+	IP:               "203.0.113.7",
+}
+
+// A notification rendered in the locale of its recipient
+type RenderedNotification struct {
+	Locale  string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Renders the given template with the given context. The subject is kept in
+// a single line and the html is escaped according to its context.
+func renderNotificationTemplate(template models.NotificationTemplate, context NotificationContext) (*RenderedNotification, error) {
+	rendered := RenderedNotification{Locale: template.Locale}
+
+	var subject, text, html bytes.Buffer
+	subjectTemplate, err := texttemplate.New("subject").Parse(template.Subject)
+	if err == nil {
+		err = subjectTemplate.Execute(&subject, context)
+	}
+	if err != nil {
+		return nil, NotificationTemplateInvalidError{err}
+	}
+	rendered.Subject = strings.Join(strings.Fields(subject.String()), " ")
+
+	textTemplate, err := texttemplate.New("text").Parse(template.Text)
+	if err == nil {
+		err = textTemplate.Execute(&text, context)
+	}
+	if err != nil {
+		return nil, NotificationTemplateInvalidError{err}
+	}
+	rendered.Text = text.String()
+
+	if template.HTML != "" {
+		htmlTemplate, err := htmltemplate.New("html").Parse(template.HTML)
+		if err == nil {
+			err = htmlTemplate.Execute(&html, context)
+		}
+		if err != nil {
+			return nil, NotificationTemplateInvalidError{err}
+		}
+		rendered.HTML = html.String()
+	}
+	return &rendered, nil
+}
+
+// Finds the template for the given notification kind. Locales are tried from
+// the most to the least specific, and for each of them the template of the
+// app is preferred over the deployment one, which is preferred over the
+// built-in one. The templates of the kinds which carry a secret link are
+// never taken from the app.
+func findNotificationTemplate(db *gorm.DB, kind string, locale string, app *models.App) models.NotificationTemplate {
+	locales := models.LocaleFallbacks(locale)
+
+	var overrides []models.NotificationTemplate
+	query := db.Where("kind = ? AND locale IN ?", kind, locales)
+	if app != nil && models.IsAppTemplatable(kind) {
+		query = query.Where("app_id = ? OR app_id IS NULL", app.ID)
+	} else {
+		query = query.Where("app_id IS NULL")
+	}
+	query.Find(&overrides)
+
+	for _, candidate := range locales {
+		var deployment *models.NotificationTemplate
+		for i, override := range overrides {
+			if override.Locale != candidate {
+				continue
+			}
+			if override.AppID != nil {
+				return override
+			}
+			deployment = &overrides[i]
+		}
+		if deployment != nil {
+			return *deployment
+		}
+		if template, ok := defaultNotificationTemplates[kind][candidate]; ok {
+			return template
+		}
+	}
+	return defaultNotificationTemplates[kind][models.DefaultLocale]
+}
+
+// Renders the notification of the given kind in the given locale
+func renderNotification(db *gorm.DB, kind string, locale string, app *models.App, context NotificationContext) (*RenderedNotification, error) {
+	if app != nil {
+		context.AppName = app.Name
+	}
+	return renderNotificationTemplate(findNotificationTemplate(db, kind, locale, app), context)
+}
+
+// Reads the app notifications are sent on behalf of. Unknown client ids fall
+// back to the deployment templates, as the notification must be sent anyway.
+func readNotificationApp(db *gorm.DB, clientID string) *models.App {
+	if clientID == "" {
+		return nil
+	}
+	var app models.App
+	if err := db.Where(&models.App{ClientID: uuid.FromStringOrNil(clientID)}).First(&app).Error; err != nil {
+		return nil
+	}
+	return &app
+}
+
+// Reads the app the notifications about the given user are sent on behalf
+// of when they are asked for without authentication, so anyone can give any
+// client id. Only the apps the user is connected to are trusted, and the
+// deployment templates are used otherwise.
+func readConnectedNotificationApp(db *gorm.DB, clientID string, user models.User) *models.App {
+	app := readNotificationApp(db, clientID)
+	if app == nil {
+		return nil
+	}
+	var connected int64
+	db.Table("user_has_signin_on_app").Where("user_id = ? AND app_id = ?", user.ID, app.ID).Count(&connected)
+	if connected == 0 {
+		return nil
+	}
+	return app
+}
+
+// Notification template service manages the templates overridden by the
+// deployment and by the apps
+type NotificationTemplateService struct {
+	db *gorm.DB
+}
+
+// Creates a new notification template service
+func NewNotificationTemplateService(db *gorm.DB) NotificationTemplateService {
+	return NotificationTemplateService{db}
+}
+
+// Scopes the query to the templates of the given app, or to the deployment
+// ones when no app is given
+func (service NotificationTemplateService) scoped(app *models.App) *gorm.DB {
+	if app != nil {
+		return service.db.Where("app_id = ?", app.ID)
+	}
+	return service.db.Where("app_id IS NULL")
+}
+
+// List the templates overridden by the given app, or the deployment ones
+// when no app is given
+func (service NotificationTemplateService) List(app *models.App) []models.NotificationTemplate {
+	var templates []models.NotificationTemplate
+	service.scoped(app).Order("kind, locale").Find(&templates)
+	return templates
+}
+
+// Overrides the template of the given kind and locale. Templates are
+// rendered with sample data before being saved, so broken templates never
+// reach the outbox. Apps cannot override the kinds which carry a secret
+// link.
+func (service NotificationTemplateService) Save(kind string, locale string, data validators.NotificationTemplateData, app *models.App) (*models.NotificationTemplate, error) {
+	if app != nil && !models.IsAppTemplatable(kind) {
+		return nil, NotificationTemplateKindError{}
+	}
+	template := models.NewNotificationTemplate(kind, locale, data.Subject, data.Text, data.HTML, app)
+	if _, err := renderNotificationTemplate(template, sampleNotificationContext); err != nil {
+		return nil, err
+	}
+
+	var stored models.NotificationTemplate
+	if err := service.scoped(app).Where("kind = ? AND locale = ?", kind, locale).First(&stored).Error; err == nil {
+		template.Model = stored.Model
+		template.UUID = stored.UUID
+	}
+	if err := service.db.Save(&template).Error; err != nil {
+		return nil, NotificationTemplateSaveError{err}
+	}
+	return &template, nil
+}
+
+// Deletes the override of the given kind and locale, so the next template
+// in the lookup is used again
+func (service NotificationTemplateService) Delete(kind string, locale string, app *models.App) error {
+	var template models.NotificationTemplate
+	if err := service.scoped(app).Where("kind = ? AND locale = ?", kind, locale).First(&template).Error; err != nil {
+		return NotificationTemplateNotFoundError{err}
+	}
+	return service.db.Delete(&template).Error
+}
+
+// Renders a notification with sample data, either from the given draft or
+// from the template which would be used for the given kind, locale and app
+func (service NotificationTemplateService) Preview(data validators.NotificationTemplatePreviewData) (*RenderedNotification, error) {
+	app := readNotificationApp(service.db, data.ClientID)
+	if data.Subject != "" {
+		template := models.NewNotificationTemplate(data.Kind, data.Locale, data.Subject, data.Text, data.HTML, app)
+		context := sampleNotificationContext
+		if app != nil {
+			context.AppName = app.Name
+		}
+		return renderNotificationTemplate(template, context)
+	}
+	return renderNotification(service.db, data.Kind, data.Locale, app, sampleNotificationContext)
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"encoding/json"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/tests"
+	"gandalf/validators"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderNotificationTemplate(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test render template", func(t *testing.T) {
+		template := models.NewNotificationTemplate(
+			models.OutboxKindUserVerifyEmail, "es",
+			"Hola\n{{.Name}}", "Verifica: {{.Link}}", `<a href="{{.Link}}">{{.Name}}</a>`, nil,
+		)
+
+		rendered, err := renderNotificationTemplate(template, NotificationContext{
+			Name: "<b>John</b>",
+			Link: "https://test.com/?code=secret",
+		})
+
+		assert.NoError(err)
+		assert.Equal("es", rendered.Locale)
+		assert.Equal("Hola <b>John</b>", rendered.Subject)
+		assert.Equal("Verifica: https://test.com/?code=secret", rendered.Text)
+		assert.Equal(`<a href="https://test.com/?code=secret">&lt;b&gt;John&lt;/b&gt;</a>`, rendered.HTML)
+	})
+
+	t.Run("Test render invalid template", func(t *testing.T) {
+		template := models.NewNotificationTemplate(models.OutboxKindUserVerifyEmail, "es", "{{.Name", "text", "", nil)
+
+		_, err := renderNotificationTemplate(template, sampleNotificationContext)
+
+		assert.IsType(NotificationTemplateInvalidError{}, err)
+	})
+
+	t.Run("Test render unknown field", func(t *testing.T) {
+		template := models.NewNotificationTemplate(models.OutboxKindUserVerifyEmail, "es", "Hi", "{{.Password}}", "", nil)
+
+		_, err := renderNotificationTemplate(template, sampleNotificationContext)
+
+		assert.IsType(NotificationTemplateInvalidError{}, err)
+	})
+
+	t.Run("Test built-in templates render", func(t *testing.T) {
+		for kind, locales := range defaultNotificationTemplates {
+			assert.Contains(locales, models.DefaultLocale, kind)
+			for _, template := range locales {
+				_, err := renderNotificationTemplate(template, sampleNotificationContext)
+				assert.NoError(err, kind)
+			}
+		}
+	})
+}
+
+func TestNotificationLink(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test link keeps the url query", func(t *testing.T) {
+		link := notificationLink("https://test.com/verify?lang=es", map[string]string{"code": "a+b/c"})
+
+		assert.Equal("https://test.com/verify?code=a%2Bb%2Fc&lang=es", link)
+	})
+}
+
+func TestNotificationTemplateService(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test lookup precedence", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewNotificationTemplateService(db)
+		app := tests.AppFactory()
+		db.Create(&app)
+
+		data := validators.NotificationTemplateData{Subject: "Deployment", Text: "{{.Name}}"}
+		_, err := service.Save(models.OutboxKindUserSignupAttempt, "es", data, nil)
+		assert.NoError(err)
+
+		assert.Equal("Deployment", findNotificationTemplate(db, models.OutboxKindUserSignupAttempt, "es-AR", &app).Subject)
+		assert.Equal("Someone tried to sign up with your email", findNotificationTemplate(db, models.OutboxKindUserSignupAttempt, "fr", &app).Subject)
+
+		data = validators.NotificationTemplateData{Subject: "App", Text: "{{.Name}}"}
+		_, err = service.Save(models.OutboxKindUserSignupAttempt, "es", data, &app)
+		assert.NoError(err)
+
+		assert.Equal("App", findNotificationTemplate(db, models.OutboxKindUserSignupAttempt, "es", &app).Subject)
+		assert.Equal("Deployment", findNotificationTemplate(db, models.OutboxKindUserSignupAttempt, "es", nil).Subject)
+	})
+
+	t.Run("Test apps cannot override the kinds with secret links", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewNotificationTemplateService(db)
+		app := tests.AppFactory()
+		db.Create(&app)
+		data := validators.NotificationTemplateData{Subject: "App", Text: "{{.Link}}"}
+
+		_, err := service.Save(models.OutboxKindUserChangePassword, "en", data, &app)
+		assert.IsType(NotificationTemplateKindError{}, err)
+
+		stored := models.NewNotificationTemplate(models.OutboxKindUserChangePassword, "en", "App", "{{.Link}}", "", &app)
+		db.Create(&stored)
+		assert.NotEqual("App", findNotificationTemplate(db, models.OutboxKindUserChangePassword, "en", &app).Subject)
+
+		db.Unscoped().Delete(&stored)
+	})
+
+	t.Run("Test public emails use the app only when the user is connected to it", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		templates := NewNotificationTemplateService(db)
+		service := NewOutboxService(db, &mockPelipper{})
+		user := tests.UserFactory()
+		db.Create(&user)
+		app := tests.AppFactory()
+		db.Create(&app)
+		templates.Save(models.OutboxKindUserSignupAttempt, "en", validators.NotificationTemplateData{Subject: "App", Text: "{{.Name}}"}, &app)
+
+		subject := func() string {
+			var message models.OutboxMessage
+			db.Where(&models.OutboxMessage{Kind: models.OutboxKindUserSignupAttempt, Recipient: user.Email}).Last(&message)
+			var data validators.PelipperUserSignupAttempt
+			json.Unmarshal([]byte(message.Payload), &data)
+			return data.Subject
+		}
+
+		assert.NoError(service.SendSignupAttemptEmail(user, app.ClientID.String()))
+		assert.NotEqual("App", subject())
+
+		db.Model(&user).Association("ConnectedApps").Append(&app)
+		assert.NoError(service.SendSignupAttemptEmail(user, app.ClientID.String()))
+		assert.Equal("App", subject())
+
+		db.Unscoped().Where("recipient = ?", user.Email).Delete(&models.OutboxMessage{})
+		templates.Delete(models.OutboxKindUserSignupAttempt, "en", &app)
+	})
+
+	t.Run("Test save replaces the override", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewNotificationTemplateService(db)
+
+		first, _ := service.Save(models.OutboxKindUserSignupAttempt, "es", validators.NotificationTemplateData{Subject: "Uno", Text: "uno"}, nil)
+		second, err := service.Save(models.OutboxKindUserSignupAttempt, "es", validators.NotificationTemplateData{Subject: "Dos", Text: "dos"}, nil)
+
+		assert.NoError(err)
+		assert.Equal(first.UUID, second.UUID)
+		assert.Equal(1, len(service.List(nil)))
+	})
+
+	t.Run("Test delete override", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewNotificationTemplateService(db)
+		service.Save(models.OutboxKindUserSignupAttempt, "es", validators.NotificationTemplateData{Subject: "Uno", Text: "uno"}, nil)
+
+		assert.NoError(service.Delete(models.OutboxKindUserSignupAttempt, "es", nil))
+		assert.IsType(NotificationTemplateNotFoundError{}, service.Delete(models.OutboxKindUserSignupAttempt, "es", nil))
+	})
+
+	t.Run("Test preview draft", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewNotificationTemplateService(db)
+
+		rendered, err := service.Preview(validators.NotificationTemplatePreviewData{
+			Kind:    models.OutboxKindUserVerifyEmail,
+			Locale:  "es",
+			Subject: "Hola {{.Name}}",
+			Text:    "{{.Link}}",
+		})
+
+		assert.NoError(err)
+		assert.Equal("Hola John", rendered.Subject)
+		assert.Equal(sampleNotificationContext.Link, rendered.Text)
+	})
+
+	t.Run("Test verification email is rendered in the user locale", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewOutboxService(db, &mockPelipper{})
+		user := tests.UserFactory()
+		user.Locale = "es-ES"
+		db.Create(&user)
+
+		service.SendVerificationEmail(user, "")
+		messages := service.List(models.OutboxMessagePending, &helpers.Cursor{Page: 1, PageSize: 10})
+
+		var data validators.PelipperUserVerifyEmail
+		json.Unmarshal([]byte(messages[0].Payload), &data)
+		assert.Equal("es", data.Locale)
+		assert.Equal("Verifica tu email", data.Subject)
+		assert.Contains(data.Text, data.VerificationLink)
+	})
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"fmt"
+	"gandalf/validators"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Notifier drivers which can be chosen through NOTIFIER_DRIVER
+const (
+	NotifierDriverPelipper = "pelipper"
+	NotifierDriverSMTP     = "smtp"
+	NotifierDriverFile     = "file"
+	NotifierDriverStdout   = "stdout"
+	NotifierDriverMemory   = "memory"
+)
+
+// Notifier interface, implemented by every transport through the one we can
+// send notifications to users
+type INotifier interface {
+	SendUserVerifyEmail(data validators.PelipperUserVerifyEmail) error
+	SendUserChangePasswordEmail(data validators.PelipperUserChangePassword) error
+	SendUserSignupAttemptEmail(data validators.PelipperUserSignupAttempt) error
+	SendOrganizationInvitationEmail(data validators.PelipperOrganizationInvitation) error
+	SendUserMagicLinkEmail(data validators.PelipperUserMagicLink) error
+	SendUserDataExportEmail(data validators.PelipperUserDataExport) error
+	SendGuardianConsentEmail(data validators.PelipperGuardianConsent) error
+	SendSecurityAlertEmail(data validators.PelipperSecurityAlert) error
+}
+
+// A notification as an email, used by the drivers which do not rely on an
+// external service for rendering
+type NotificationMessage struct {
+	To      string
+	Subject string
+	Body    string
+	HTML    string
+}
+
+// Hands every notification, already rendered from its template, to the
+// deliver function of the driver which embeds it
+type messageNotifier struct {
+	deliver func(message NotificationMessage) error
+}
+
+// Sends the verification email
+func (notifier messageNotifier) SendUserVerifyEmail(data validators.PelipperUserVerifyEmail) error {
+	return notifier.deliver(NotificationMessage{To: data.Email, Subject: data.Subject, Body: data.Text, HTML: data.HTML})
+}
+
+// Sends the change password email
+func (notifier messageNotifier) SendUserChangePasswordEmail(data validators.PelipperUserChangePassword) error {
+	return notifier.deliver(NotificationMessage{To: data.Email, Subject: data.Subject, Body: data.Text, HTML: data.HTML})
+}
+
+// Sends the notice about someone trying to sign up with an already
+// registered email
+func (notifier messageNotifier) SendUserSignupAttemptEmail(data validators.PelipperUserSignupAttempt) error {
+	return notifier.deliver(NotificationMessage{To: data.Email, Subject: data.Subject, Body: data.Text, HTML: data.HTML})
+}
+
+// Sends the invitation to join an organization
+func (notifier messageNotifier) SendOrganizationInvitationEmail(data validators.PelipperOrganizationInvitation) error {
+	return notifier.deliver(NotificationMessage{To: data.Email, Subject: data.Subject, Body: data.Text, HTML: data.HTML})
+}
+
+// Sends the magic link the user logs in with
+func (notifier messageNotifier) SendUserMagicLinkEmail(data validators.PelipperUserMagicLink) error {
+	return notifier.deliver(NotificationMessage{To: data.Email, Subject: data.Subject, Body: data.Text, HTML: data.HTML})
+}
+
+// Sends the link to download the data export of the user
+func (notifier messageNotifier) SendUserDataExportEmail(data validators.PelipperUserDataExport) error {
+	return notifier.deliver(NotificationMessage{To: data.Email, Subject: data.Subject, Body: data.Text, HTML: data.HTML})
+}
+
+// Sends the link to consent to the sign-up of a minor to his guardian
+func (notifier messageNotifier) SendGuardianConsentEmail(data validators.PelipperGuardianConsent) error {
+	return notifier.deliver(NotificationMessage{To: data.Email, Subject: data.Subject, Body: data.Text, HTML: data.HTML})
+}
+
+// Sends a security alert about a sensitive change on the account
+func (notifier messageNotifier) SendSecurityAlertEmail(data validators.PelipperSecurityAlert) error {
+	return notifier.deliver(NotificationMessage{To: data.Email, Subject: data.Subject, Body: data.Text, HTML: data.HTML})
+}
+
+// Writer notifier writes every notification to the given writer, so the
+// emails can be read on development environments
+type WriterNotifier struct {
+	messageNotifier
+
+	mutex  *sync.Mutex
+	writer io.Writer
+}
+
+// Creates a new notifier which writes the notifications to the given writer
+func NewWriterNotifier(writer io.Writer) WriterNotifier {
+	notifier := WriterNotifier{mutex: &sync.Mutex{}, writer: writer}
+	notifier.deliver = notifier.write
+	return notifier
+}
+
+// Writes the given message
+func (notifier WriterNotifier) write(message NotificationMessage) error {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	_, err := fmt.Fprintf(
+		notifier.writer, "To: %s\nSubject: %s\n\n%s\n%s\n",
+		message.To, message.Subject, message.Body, strings.Repeat("-", 72),
+	)
+	return err
+}
+
+// Memory notifier keeps every notification in memory, so tests can assert
+// on what has been sent
+type MemoryNotifier struct {
+	messageNotifier
+
+	mutex    *sync.Mutex
+	messages *[]NotificationMessage
+}
+
+// Creates a new in memory notifier
+func NewMemoryNotifier() MemoryNotifier {
+	notifier := MemoryNotifier{mutex: &sync.Mutex{}, messages: &[]NotificationMessage{}}
+	notifier.deliver = notifier.store
+	return notifier
+}
+
+// Stores the given message
+func (notifier MemoryNotifier) store(message NotificationMessage) error {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	*notifier.messages = append(*notifier.messages, message)
+	return nil
+}
+
+// Returns a copy of the messages sent so far
+func (notifier MemoryNotifier) Messages() []NotificationMessage {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	messages := make([]NotificationMessage, len(*notifier.messages))
+	copy(messages, *notifier.messages)
+	return messages
+}
+
+// Creates the notifier configured through NOTIFIER_DRIVER, pelipper by
+// default. Panics on unknown drivers, since no notification could be sent.
+func NewNotifier() INotifier {
+	switch driver := os.Getenv("NOTIFIER_DRIVER"); driver {
+	case "", NotifierDriverPelipper:
+		return NewPelipperService()
+	case NotifierDriverSMTP:
+		return NewSMTPNotifier()
+	case NotifierDriverFile:
+		file, err := os.OpenFile(os.Getenv("NOTIFIER_FILE"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			panic(err)
+		}
+		return NewWriterNotifier(file)
+	case NotifierDriverStdout:
+		return NewWriterNotifier(os.Stdout)
+	case NotifierDriverMemory:
+		return NewMemoryNotifier()
+	default:
+		panic(fmt.Sprintf("unknown notifier driver %s", driver))
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"gandalf/validators"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type smtpRecorder struct {
+	from string
+	to   []string
+	data string
+}
+
+// Serves a single SMTP session on a random local port, recording what the
+// client sends
+func newSMTPStandIn(t *testing.T) (string, chan smtpRecorder) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan smtpRecorder, 1)
+
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		recorder := smtpRecorder{}
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.TrimRight(line, "\r\n")
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				recorder.from = strings.Trim(strings.TrimPrefix(command, "MAIL FROM:"), "<>")
+				reply("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				recorder.to = append(recorder.to, strings.Trim(strings.TrimPrefix(command, "RCPT TO:"), "<>"))
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					dataLine, err := reader.ReadString('\n')
+					if err != nil || dataLine == ".\r\n" {
+						break
+					}
+					data.WriteString(dataLine)
+				}
+				recorder.data = data.String()
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 Bye")
+				received <- recorder
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test send through smtp server", func(t *testing.T) {
+		addr, received := newSMTPStandIn(t)
+		host, port, _ := net.SplitHostPort(addr)
+		notifier := newSMTPNotifier(host, port, "", "", "accounts@test.com")
+
+		err := notifier.SendUserVerifyEmail(validators.PelipperUserVerifyEmail{
+			Email:            "test@test.com",
+			Name:             "test",
+			Subject:          "Welcome",
+			VerificationLink: "https://test.com/verify?code=secret",
+			Text:             "Verify your email: https://test.com/verify?code=secret",
+			HTML:             `<a href="https://test.com/verify?code=secret">Verify your email</a>`,
+		})
+		assert.NoError(err)
+		recorder := <-received
+
+		assert.Equal("accounts@test.com", recorder.from)
+		assert.Equal([]string{"test@test.com"}, recorder.to)
+		assert.Contains(recorder.data, "Subject: Welcome")
+		assert.Contains(recorder.data, "multipart/alternative")
+		assert.Contains(recorder.data, "Verify your email: https://test.com/verify?code=secret")
+		assert.Contains(recorder.data, `<a href="https://test.com/verify?code=secret">`)
+	})
+
+	t.Run("Test send fails when server is unreachable", func(t *testing.T) {
+		listener, _ := net.Listen("tcp", "127.0.0.1:0")
+		host, port, _ := net.SplitHostPort(listener.Addr().String())
+		listener.Close()
+		notifier := newSMTPNotifier(host, port, "", "", "accounts@test.com")
+
+		err := notifier.SendUserSignupAttemptEmail(validators.PelipperUserSignupAttempt{
+			Email: "test@test.com", Name: "test", Subject: "test",
+		})
+
+		assert.Error(err)
+		assert.Contains(err.Error(), port)
+	})
+}
+
+func TestWriterNotifier(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test write notification", func(t *testing.T) {
+		var buffer bytes.Buffer
+		notifier := NewWriterNotifier(&buffer)
+
+		err := notifier.SendOrganizationInvitationEmail(validators.PelipperOrganizationInvitation{
+			Email:            "test@test.com",
+			Subject:          "Invitation",
+			OrganizationName: "gandalf",
+			InvitedBy:        "admin@test.com",
+			InvitationLink:   "https://test.com/invitation",
+			Text:             "Join gandalf: https://test.com/invitation",
+		})
+
+		assert.NoError(err)
+		assert.Contains(buffer.String(), "To: test@test.com")
+		assert.Contains(buffer.String(), "https://test.com/invitation")
+	})
+}
+
+func TestMemoryNotifier(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test store notifications", func(t *testing.T) {
+		notifier := NewMemoryNotifier()
+
+		notifier.SendUserChangePasswordEmail(validators.PelipperUserChangePassword{
+			Email: "test@test.com", Name: "test", Subject: "Change password", ChangePasswordLink: "https://test.com/change",
+			Text: "Change your password: https://test.com/change",
+		})
+		messages := notifier.Messages()
+
+		assert.Equal(1, len(messages))
+		assert.Equal("test@test.com", messages[0].To)
+		assert.Contains(messages[0].Body, "https://test.com/change")
+	})
+}
+
+func TestNewNotifier(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test drivers", func(t *testing.T) {
+		defer os.Unsetenv("NOTIFIER_DRIVER")
+
+		os.Unsetenv("NOTIFIER_DRIVER")
+		assert.IsType(PelipperService{}, NewNotifier())
+		os.Setenv("NOTIFIER_DRIVER", NotifierDriverSMTP)
+		assert.IsType(SMTPNotifier{}, NewNotifier())
+		os.Setenv("NOTIFIER_DRIVER", NotifierDriverStdout)
+		assert.IsType(WriterNotifier{}, NewNotifier())
+		os.Setenv("NOTIFIER_DRIVER", NotifierDriverMemory)
+		assert.IsType(MemoryNotifier{}, NewNotifier())
+	})
+
+	t.Run("Test unknown driver panics", func(t *testing.T) {
+		defer os.Unsetenv("NOTIFIER_DRIVER")
+		os.Setenv("NOTIFIER_DRIVER", "pigeon")
+
+		assert.Panics(func() { NewNotifier() })
+	})
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"gandalf/models"
+	"gandalf/security"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Interface for one time token service
+type IOneTimeTokenService interface {
+	Issue(user models.User, purpose string) (string, error)
+	Consume(secret string, purpose string) (*models.User, error)
+}
+
+// One time token service issues and consumes the single use secrets
+// mailed to the users
+type OneTimeTokenService struct {
+	db       *gorm.DB
+	tokenTTL time.Duration `env:"ONE_TIME_TOKEN_TTL"`
+}
+
+// Creates a new one time token service
+func NewOneTimeTokenService(db *gorm.DB) OneTimeTokenService {
+	tokenTTL, _ := strconv.Atoi(os.Getenv("ONE_TIME_TOKEN_TTL"))
+	return OneTimeTokenService{
+		db:       db,
+		tokenTTL: time.Duration(tokenTTL),
+	}
+}
+
+// Issues a new token for the given user and purpose and returns its secret.
+// Every previous unused token with the same purpose will be invalidated.
+func (service OneTimeTokenService) Issue(user models.User, purpose string) (string, error) {
+	return issueOneTimeToken(service.db, user, purpose, service.tokenTTL, true)
+}
+
+// Issues a new token with the given db connection, so it can take part in
+// the transaction of the caller. The ttl is given in minutes. The previous
+// unused tokens with the same purpose are invalidated when replace is set,
+// otherwise they stay usable until they expire.
+func issueOneTimeToken(db *gorm.DB, user models.User, purpose string, ttl time.Duration, replace bool) (string, error) {
+	token := models.NewOneTimeToken(user, purpose, ttl*time.Minute)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if replace {
+			if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).
+				Delete(&models.OneTimeToken{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&token).Error
+	})
+	if err != nil {
+		return "", OneTimeTokenIssueError{err}
+	}
+
+	return token.Secret(), nil
+}
+
+// Consumes the token which belongs to the given secret and purpose and
+// returns its user. A token can only be consumed once.
+func (service OneTimeTokenService) Consume(secret string, purpose string) (*models.User, error) {
+	return consumeOneTimeToken(service.db, secret, purpose, nil)
+}
+
+// Consumes a token with the given db connection. The given check, if any,
+// can still reject a usable token before it is consumed.
+func consumeOneTimeToken(db *gorm.DB, secret string, purpose string, check func(token models.OneTimeToken) error) (*models.User, error) {
+	var token models.OneTimeToken
+	clause := &models.OneTimeToken{Digest: security.Sha256Digest(secret), Purpose: purpose}
+	if err := db.Where(clause).First(&token).Error; err != nil {
+		return nil, OneTimeTokenNotValidError{err}
+	}
+
+	if !token.IsUsable() {
+		return nil, OneTimeTokenNotValidError{nil}
+	}
+
+	if check != nil {
+		if err := check(token); err != nil {
+			return nil, err
+		}
+	}
+
+	result := db.Model(&token).Where("used_at IS NULL").Update("used_at", time.Now())
+	if result.Error != nil || result.RowsAffected != 1 {
+		return nil, OneTimeTokenNotValidError{result.Error}
+	}
+
+	var user models.User
+	if err := db.First(&user, token.UserID).Error; err != nil {
+		return nil, UserNotFoundError{err}
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/tests"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOneTimeTokenServiceConstructor(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		db := tests.NewTestDatabase(true)
+		service := NewOneTimeTokenService(db)
+		expectedTTL, _ := strconv.Atoi(os.Getenv("ONE_TIME_TOKEN_TTL"))
+
+		assert.Equal(service.db, db)
+		assert.Equal(service.tokenTTL, time.Duration(expectedTTL))
+	})
+}
+
+func TestOneTimeTokenServiceIssue(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test issue token successfully", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := OneTimeTokenService{db, 10}
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		secret, err := service.Issue(user, models.TokenPurposeVerifyUser)
+
+		var token models.OneTimeToken
+		db.Where(&models.OneTimeToken{Digest: security.Sha256Digest(secret)}).First(&token)
+
+		assert.NoError(err)
+		assert.Equal(user.ID, token.UserID)
+		assert.Equal(models.TokenPurposeVerifyUser, token.Purpose)
+		assert.Nil(token.UsedAt)
+
+		db.Unscoped().Delete(&user)
+	})
+
+	t.Run("Test issue token invalidates previous ones", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := OneTimeTokenService{db, 10}
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		oldSecret, _ := service.Issue(user, models.TokenPurposeResetPassword)
+		newSecret, _ := service.Issue(user, models.TokenPurposeResetPassword)
+
+		_, oldErr := service.Consume(oldSecret, models.TokenPurposeResetPassword)
+		_, newErr := service.Consume(newSecret, models.TokenPurposeResetPassword)
+
+		assert.Error(oldErr, OneTimeTokenNotValidError{nil}.Error())
+		assert.NoError(newErr)
+
+		db.Unscoped().Delete(&user)
+	})
+}
+
+func TestOneTimeTokenServiceConsume(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test consume token successfully", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := OneTimeTokenService{db, 10}
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		secret, _ := service.Issue(user, models.TokenPurposeVerifyUser)
+		consumer, err := service.Consume(secret, models.TokenPurposeVerifyUser)
+
+		assert.NoError(err)
+		assert.Equal(user.ID, consumer.ID)
+
+		db.Unscoped().Delete(&user)
+	})
+
+	t.Run("Test consume token twice", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := OneTimeTokenService{db, 10}
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		secret, _ := service.Issue(user, models.TokenPurposeVerifyUser)
+		service.Consume(secret, models.TokenPurposeVerifyUser)
+		_, err := service.Consume(secret, models.TokenPurposeVerifyUser)
+
+		assert.Error(err, OneTimeTokenNotValidError{nil}.Error())
+
+		db.Unscoped().Delete(&user)
+	})
+
+	t.Run("Test consume token with other purpose", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := OneTimeTokenService{db, 10}
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		secret, _ := service.Issue(user, models.TokenPurposeVerifyUser)
+		_, err := service.Consume(secret, models.TokenPurposeResetPassword)
+
+		assert.Error(err, OneTimeTokenNotValidError{nil}.Error())
+
+		db.Unscoped().Delete(&user)
+	})
+
+	t.Run("Test consume expired token", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := OneTimeTokenService{db, 0}
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		secret, _ := service.Issue(user, models.TokenPurposeVerifyUser)
+		_, err := service.Consume(secret, models.TokenPurposeVerifyUser)
+
+		assert.Error(err, OneTimeTokenNotValidError{nil}.Error())
+
+		db.Unscoped().Delete(&user)
+	})
+}
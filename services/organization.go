@@ -0,0 +1,284 @@
+package services
+
+import (
+	"errors"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/validators"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Interface for organization service
+type IOrganizationService interface {
+
+	// CRUD operations
+	Create(data validators.OrganizationCreateData, owner models.User) (*models.Organization, error)
+	Read(uuid uuid.UUID) (*models.Organization, error)
+	Update(organization *models.Organization, data validators.OrganizationUpdateData)
+	Delete(organization models.Organization) error
+	List(user models.User, cursor *helpers.Cursor) []models.Organization
+
+	// Membership methods
+	Membership(organization models.Organization, user models.User) (*models.Membership, error)
+	ListMembers(organization models.Organization, cursor *helpers.Cursor) []models.Membership
+	SetMemberRole(organization models.Organization, user models.User, role string) (*models.Membership, error)
+	RemoveMember(organization models.Organization, user models.User) error
+	Invite(organization models.Organization, invitedBy models.User, data validators.OrganizationInviteData) (*models.Invitation, error)
+	AcceptInvitation(organization models.Organization, secret string, user models.User) (*models.Membership, error)
+}
+
+// Organization service manages organizations and their memberships
+type OrganizationService struct {
+	db            *gorm.DB
+	invitationTTL time.Duration `env:"ORGANIZATION_INVITATION_TTL"`
+}
+
+// Creates a new organization service
+func NewOrganizationService(db *gorm.DB) OrganizationService {
+	invitationTTL, _ := strconv.Atoi(os.Getenv("ORGANIZATION_INVITATION_TTL"))
+	return OrganizationService{
+		db:            db,
+		invitationTTL: time.Duration(invitationTTL) * time.Hour,
+	}
+}
+
+// Creates a new organization owned by the given user
+func (service OrganizationService) Create(data validators.OrganizationCreateData, owner models.User) (*models.Organization, error) {
+	organization := models.NewOrganization(data.Name)
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&organization).Error; err != nil {
+			return err
+		}
+		membership := models.NewMembership(organization, owner, models.MembershipOwner)
+		return tx.Create(&membership).Error
+	})
+	if err != nil {
+		return nil, OrganizationCreateError{err}
+	}
+	return &organization, nil
+}
+
+// Read an organization by his UUID
+func (service OrganizationService) Read(uuid uuid.UUID) (*models.Organization, error) {
+	var organization models.Organization
+	if err := service.db.Where(&models.Organization{UUID: uuid}).First(&organization).Error; err != nil {
+		return nil, OrganizationNotFoundError{err}
+	}
+	return &organization, nil
+}
+
+// Updates the given organization according to the given data
+func (service OrganizationService) Update(organization *models.Organization, data validators.OrganizationUpdateData) {
+	if data.Name != "" {
+		organization.Name = data.Name
+	}
+	service.db.Save(organization)
+}
+
+// Deletes the given organization. Its apps are kept, but they will
+// not belong to any organization anymore.
+func (service OrganizationService) Delete(organization models.Organization) error {
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.App{}).Where("organization_id = ?", organization.ID).Update("organization_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("organization_id = ?", organization.ID).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&organization).Error
+	})
+	if err != nil {
+		return OrganizationNotFoundError{err}
+	}
+	return nil
+}
+
+// List the organizations the given user is member of
+func (service OrganizationService) List(user models.User, cursor *helpers.Cursor) []models.Organization {
+	var organizations []models.Organization
+	var count int64
+
+	members := func(db *gorm.DB) *gorm.DB {
+		return db.Where(
+			"id IN (?)",
+			service.db.Model(&models.Membership{}).Select("organization_id").Where("user_id = ?", user.ID),
+		)
+	}
+
+	service.db.Model(&models.Organization{}).Scopes(members).Count(&count)
+	service.db.Scopes(members, helpers.DBPaginate(cursor.Page, cursor.PageSize)).Order("id").Find(&organizations)
+	cursor.Update(int(count))
+	return organizations
+}
+
+// Read the membership of the given user in the given organization
+func (service OrganizationService) Membership(organization models.Organization, user models.User) (*models.Membership, error) {
+	var membership models.Membership
+	query := service.db.Where(&models.Membership{OrganizationID: organization.ID, UserID: user.ID})
+	if err := query.First(&membership).Error; err != nil {
+		return nil, MembershipNotFoundError{err}
+	}
+	membership.Organization = organization
+	membership.User = user
+	return &membership, nil
+}
+
+// List the memberships of the given organization
+func (service OrganizationService) ListMembers(organization models.Organization, cursor *helpers.Cursor) []models.Membership {
+	var memberships []models.Membership
+	var count int64
+	query := &models.Membership{OrganizationID: organization.ID}
+
+	service.db.Model(&models.Membership{}).Where(query).Count(&count)
+	service.db.Preload("User").Scopes(helpers.DBPaginate(cursor.Page, cursor.PageSize)).Where(query).Order("id").Find(&memberships)
+	cursor.Update(int(count))
+	return memberships
+}
+
+// Check that the organization keeps at least one owner if the given
+// membership stops being an owner
+func (service OrganizationService) keepsOwner(tx *gorm.DB, membership models.Membership) error {
+	if membership.Role != models.MembershipOwner {
+		return nil
+	}
+	var owners int64
+	tx.Model(&models.Membership{}).Where(&models.Membership{
+		OrganizationID: membership.OrganizationID,
+		Role:           models.MembershipOwner,
+	}).Count(&owners)
+	if owners <= 1 {
+		return LastOwnerError{}
+	}
+	return nil
+}
+
+// Changes the role of the given user in the given organization
+func (service OrganizationService) SetMemberRole(organization models.Organization, user models.User, role string) (*models.Membership, error) {
+	membership, err := service.Membership(organization, user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = service.db.Transaction(func(tx *gorm.DB) error {
+		if role != models.MembershipOwner {
+			if err := service.keepsOwner(tx, *membership); err != nil {
+				return err
+			}
+		}
+		return tx.Model(membership).Update("role", role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// Removes the given user from the given organization
+func (service OrganizationService) RemoveMember(organization models.Organization, user models.User) error {
+	membership, err := service.Membership(organization, user)
+	if err != nil {
+		return err
+	}
+
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := service.keepsOwner(tx, *membership); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(membership).Error
+	})
+}
+
+// Invites the given email to join the given organization, and enqueues the
+// invitation email in the same transaction. The returned invitation is the
+// only place where its secret is available.
+func (service OrganizationService) Invite(organization models.Organization, invitedBy models.User, data validators.OrganizationInviteData) (*models.Invitation, error) {
+	invitation := models.NewInvitation(organization, invitedBy, data.Email, data.Role, service.invitationTTL)
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&invitation).Error; err != nil {
+			return InvitationCreateError{err}
+		}
+
+		link := notificationLink(os.Getenv("ORGANIZATION_INVITATION_URL"), map[string]string{
+			"organization": organization.UUID.String(),
+			"code":         invitation.Secret(),
+		})
+
+		// Invitations are rendered in the locale of the invited user when
+		// the email is already registered
+		locale := models.DefaultLocale
+		var invited models.User
+		if err := tx.Where(&models.User{Email: invitation.Email}).First(&invited).Error; err == nil {
+			locale = invited.Locale
+		}
+		rendered, err := renderNotification(tx, models.OutboxKindOrganizationInvitation, locale, nil, NotificationContext{
+			Email:            invitation.Email,
+			Link:             link,
+			OrganizationName: organization.Name,
+			InvitedBy:        invitedBy.Name,
+		})
+		if err != nil {
+			return OutboxEnqueueError{err}
+		}
+
+		return enqueueNotification(tx, models.OutboxKindOrganizationInvitation, invitation.Email, validators.PelipperOrganizationInvitation{
+			Email:            invitation.Email,
+			Subject:          rendered.Subject,
+			OrganizationName: organization.Name,
+			InvitedBy:        invitedBy.Name,
+			InvitationLink:   link,
+			Locale:           rendered.Locale,
+			Text:             rendered.Text,
+			HTML:             rendered.HTML,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	invitation.Organization = organization
+	invitation.InvitedBy = invitedBy
+	return &invitation, nil
+}
+
+// Accepts the invitation to the given organization that matches the given
+// secret on behalf of the given user, who becomes member of the organization
+func (service OrganizationService) AcceptInvitation(organization models.Organization, secret string, user models.User) (*models.Membership, error) {
+	var invitation models.Invitation
+	query := service.db.Where(&models.Invitation{
+		Digest:         security.Sha256Digest(secret),
+		OrganizationID: organization.ID,
+	})
+	if err := query.First(&invitation).Error; err != nil {
+		return nil, InvitationNotValidError{err}
+	}
+
+	if !invitation.IsAcceptableBy(user) {
+		return nil, InvitationNotValidError{nil}
+	}
+
+	membership := models.NewMembership(organization, user, invitation.Role)
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL", invitation.ID).
+			Update("accepted_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errors.New("Invitation already accepted")
+		}
+		return tx.Create(&membership).Error
+	})
+	if err != nil {
+		return nil, InvitationNotValidError{err}
+	}
+
+	membership.Organization = organization
+	membership.User = user
+	return &membership, nil
+}
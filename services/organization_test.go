@@ -0,0 +1,119 @@
+package services
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/tests"
+	"gandalf/validators"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestOrganizationServiceConstructor(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		db := tests.NewTestDatabase(true)
+		service := NewOrganizationService(db)
+
+		assert.Equal(service.db, db)
+	})
+}
+
+func TestOrganizationServiceCreate(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test create organization successfully", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := OrganizationService{db: db}
+		owner := tests.UserFactory()
+		db.Create(&owner)
+
+		name := faker.Company().Name()
+		organization, err := service.Create(validators.OrganizationCreateData{Name: name}, owner)
+		assert.NoError(err)
+		assert.Equal(name, organization.Name)
+
+		membership, err := service.Membership(*organization, owner)
+		assert.NoError(err)
+		assert.Equal(models.MembershipOwner, membership.Role)
+
+		cursor := helpers.NewCursor(0, 10)
+		organizations := service.List(owner, &cursor)
+		assert.Equal(1, len(organizations))
+
+		service.Delete(*organization)
+		db.Unscoped().Delete(&owner)
+	})
+}
+
+func TestOrganizationServiceMembers(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test organization keeps an owner", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := OrganizationService{db: db}
+		owner := tests.UserFactory()
+		db.Create(&owner)
+		member := tests.UserFactory()
+		db.Create(&member)
+		organization, _ := service.Create(validators.OrganizationCreateData{Name: faker.Company().Name()}, owner)
+		membership := models.NewMembership(*organization, member, models.MembershipMember)
+		db.Create(&membership)
+
+		_, err := service.SetMemberRole(*organization, owner, models.MembershipAdmin)
+		assert.Error(err, LastOwnerError{}.Error())
+		assert.Error(service.RemoveMember(*organization, owner), LastOwnerError{}.Error())
+
+		_, err = service.SetMemberRole(*organization, member, models.MembershipOwner)
+		assert.NoError(err)
+		assert.NoError(service.RemoveMember(*organization, owner))
+
+		cursor := helpers.NewCursor(0, 10)
+		memberships := service.ListMembers(*organization, &cursor)
+		assert.Equal(1, len(memberships))
+		assert.Equal(member.ID, memberships[0].User.ID)
+
+		service.Delete(*organization)
+		db.Unscoped().Delete(&owner)
+		db.Unscoped().Delete(&member)
+	})
+}
+
+func TestOrganizationServiceInvitations(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test accept invitation only once", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := OrganizationService{db: db, invitationTTL: time.Hour}
+		owner := tests.UserFactory()
+		db.Create(&owner)
+		invited := tests.UserFactory()
+		db.Create(&invited)
+		organization, _ := service.Create(validators.OrganizationCreateData{Name: faker.Company().Name()}, owner)
+
+		invitation, err := service.Invite(*organization, owner, validators.OrganizationInviteData{
+			Email: invited.Email,
+			Role:  models.MembershipAdmin,
+		})
+		assert.NoError(err)
+
+		_, err = service.AcceptInvitation(*organization, invitation.Secret(), owner)
+		assert.Error(err, InvitationNotValidError{nil}.Error())
+
+		membership, err := service.AcceptInvitation(*organization, invitation.Secret(), invited)
+		assert.NoError(err)
+		assert.Equal(models.MembershipAdmin, membership.Role)
+
+		_, err = service.AcceptInvitation(*organization, invitation.Secret(), invited)
+		assert.Error(err, InvitationNotValidError{nil}.Error())
+
+		service.Delete(*organization)
+		db.Unscoped().Delete(invitation)
+		db.Unscoped().Delete(&owner)
+		db.Unscoped().Delete(&invited)
+	})
+}
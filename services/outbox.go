@@ -0,0 +1,364 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/validators"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Maximum number of pending messages attempted on every dispatch round
+const outboxDispatchBatch = 100
+
+// Claimed messages are attempted again after the lease when the instance
+// which claimed them stopped before storing the result of the attempt
+const outboxClaimLease = time.Minute
+
+// Interface for outbox service
+type IOutboxService interface {
+	SendVerificationEmail(user models.User, clientID string) error
+	SendResetPasswordEmail(user models.User, clientID string, admin *AdminActionContext) error
+	SendSignupAttemptEmail(user models.User, clientID string) error
+	List(status string, cursor *helpers.Cursor) []models.OutboxMessage
+	DeliverPending()
+}
+
+// Outbox service enqueues the notifications for the users and delivers
+// them through the configured notifier
+type OutboxService struct {
+	db          *gorm.DB
+	notifier    INotifier
+	tokenTTL    time.Duration `env:"ONE_TIME_TOKEN_TTL"`
+	maxAttempts int           `env:"OUTBOX_MAX_ATTEMPTS"`
+	backoff     time.Duration `env:"OUTBOX_BACKOFF"`
+}
+
+// Creates a new outbox service
+func NewOutboxService(db *gorm.DB, notifier INotifier) OutboxService {
+	tokenTTL, _ := strconv.Atoi(os.Getenv("ONE_TIME_TOKEN_TTL"))
+	return OutboxService{
+		db:          db,
+		notifier:    notifier,
+		tokenTTL:    time.Duration(tokenTTL),
+		maxAttempts: helpers.GetEnvInt("OUTBOX_MAX_ATTEMPTS", 8),
+		backoff:     time.Duration(helpers.GetEnvInt("OUTBOX_BACKOFF", 30)) * time.Second,
+	}
+}
+
+// Enqueues a notification with the given db connection, so it is only
+// delivered if the transaction of the caller commits
+func enqueueNotification(db *gorm.DB, kind string, recipient string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return OutboxEnqueueError{err}
+	}
+	message := models.NewOutboxMessage(kind, recipient, string(payload))
+	if err := db.Create(&message).Error; err != nil {
+		return OutboxEnqueueError{err}
+	}
+	return nil
+}
+
+// Builds the link mailed to the users by adding the given parameters to the
+// query of the given url
+func notificationLink(base string, params map[string]string) string {
+	query := url.Values{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+
+	link, err := url.Parse(base)
+	if err != nil {
+		return fmt.Sprintf("%s?%s", base, query.Encode())
+	}
+	values := link.Query()
+	for key := range query {
+		values.Set(key, query.Get(key))
+	}
+	link.RawQuery = values.Encode()
+	return link.String()
+}
+
+// Issues a verification token for the given user and enqueues the email
+// which carries it, rendered with the templates of the given app if any
+func enqueueVerificationEmail(db *gorm.DB, user models.User, app *models.App, tokenTTL time.Duration) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		verifyToken, err := issueOneTimeToken(tx, user, models.TokenPurposeVerifyUser, tokenTTL, true)
+		if err != nil {
+			return err
+		}
+
+		link := notificationLink(os.Getenv("EMAIL_VERIFICATION_URL"), map[string]string{"code": verifyToken})
+		rendered, err := renderNotification(tx, models.OutboxKindUserVerifyEmail, user.Locale, app, NotificationContext{
+			Name:  user.Name,
+			Email: user.Email,
+			Link:  link,
+		})
+		if err != nil {
+			return OutboxEnqueueError{err}
+		}
+
+		return enqueueNotification(tx, models.OutboxKindUserVerifyEmail, user.Email, validators.PelipperUserVerifyEmail{
+			Email:            user.Email,
+			Name:             user.Name,
+			Subject:          rendered.Subject,
+			VerificationLink: link,
+			Locale:           rendered.Locale,
+			Text:             rendered.Text,
+			HTML:             rendered.HTML,
+		})
+	})
+}
+
+// Issues a guardian consent token for the given minor and enqueues the
+// email which carries it to his guardian, rendered with the templates of
+// the given app if any
+func enqueueGuardianConsentEmail(db *gorm.DB, user models.User, app *models.App, tokenTTL time.Duration) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		consentToken, err := issueOneTimeToken(tx, user, models.TokenPurposeGuardian, tokenTTL, true)
+		if err != nil {
+			return err
+		}
+
+		link := notificationLink(os.Getenv("GUARDIAN_CONSENT_URL"), map[string]string{"code": consentToken})
+		rendered, err := renderNotification(tx, models.OutboxKindGuardianConsent, user.Locale, app, NotificationContext{
+			Name:  user.Name,
+			Email: user.Email,
+			Link:  link,
+		})
+		if err != nil {
+			return OutboxEnqueueError{err}
+		}
+
+		return enqueueNotification(tx, models.OutboxKindGuardianConsent, user.GuardianEmail, validators.PelipperGuardianConsent{
+			Email:       user.GuardianEmail,
+			Name:        user.Name,
+			Subject:     rendered.Subject,
+			ConsentLink: link,
+			Locale:      rendered.Locale,
+			Text:        rendered.Text,
+			HTML:        rendered.HTML,
+		})
+	})
+}
+
+// Issues a reset password token for the given user and enqueues the email
+// which carries it, rendered with the templates of the given app if any
+func enqueueResetPasswordEmail(db *gorm.DB, user models.User, app *models.App, tokenTTL time.Duration) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		changePasswordToken, err := issueOneTimeToken(tx, user, models.TokenPurposeResetPassword, tokenTTL, true)
+		if err != nil {
+			return err
+		}
+
+		link := notificationLink(os.Getenv("PASSWORD_CHANGE_URL"), map[string]string{"code": changePasswordToken})
+		rendered, err := renderNotification(tx, models.OutboxKindUserChangePassword, user.Locale, app, NotificationContext{
+			Name:  user.Name,
+			Email: user.Email,
+			Link:  link,
+		})
+		if err != nil {
+			return OutboxEnqueueError{err}
+		}
+
+		return enqueueNotification(tx, models.OutboxKindUserChangePassword, user.Email, validators.PelipperUserChangePassword{
+			Email:              user.Email,
+			Name:               user.Name,
+			Subject:            rendered.Subject,
+			ChangePasswordLink: link,
+			Locale:             rendered.Locale,
+			Text:               rendered.Text,
+			HTML:               rendered.HTML,
+		})
+	})
+}
+
+// Enqueues the verification email for the given user, on behalf of the app
+// with the given client id if he is connected to it. While the sign-up of
+// the user is held, the consent email is sent to his guardian again instead.
+func (service OutboxService) SendVerificationEmail(user models.User, clientID string) error {
+	app := readConnectedNotificationApp(service.db, clientID, user)
+	if user.AwaitsGuardianConsent() {
+		return enqueueGuardianConsentEmail(service.db, user, app, service.tokenTTL)
+	}
+	return enqueueVerificationEmail(service.db, user, app, service.tokenTTL)
+}
+
+// Enqueues the reset password email for the given user, on behalf of the
+// app with the given client id if he is connected to it. The given admin
+// action, if any, is recorded along with it.
+func (service OutboxService) SendResetPasswordEmail(user models.User, clientID string, admin *AdminActionContext) error {
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := enqueueResetPasswordEmail(tx, user, readConnectedNotificationApp(tx, clientID, user), service.tokenTTL); err != nil {
+			return err
+		}
+		return recordAdminAction(tx, admin, &user)
+	})
+}
+
+// Enqueues the notice about someone trying to sign up with the email of
+// the given user, on behalf of the app with the given client id if he is
+// connected to it
+func (service OutboxService) SendSignupAttemptEmail(user models.User, clientID string) error {
+	app := readConnectedNotificationApp(service.db, clientID, user)
+	rendered, err := renderNotification(service.db, models.OutboxKindUserSignupAttempt, user.Locale, app, NotificationContext{
+		Name:  user.Name,
+		Email: user.Email,
+	})
+	if err != nil {
+		return OutboxEnqueueError{err}
+	}
+
+	return enqueueNotification(service.db, models.OutboxKindUserSignupAttempt, user.Email, validators.PelipperUserSignupAttempt{
+		Email:   user.Email,
+		Name:    user.Name,
+		Subject: rendered.Subject,
+		Locale:  rendered.Locale,
+		Text:    rendered.Text,
+		HTML:    rendered.HTML,
+	})
+}
+
+// List the outbox messages with the given status, the most recent first.
+// An empty status lists every message.
+func (service OutboxService) List(status string, cursor *helpers.Cursor) []models.OutboxMessage {
+	var messages []models.OutboxMessage
+	var count int64
+
+	query := &models.OutboxMessage{Status: status}
+	service.db.Model(&models.OutboxMessage{}).Where(query).Count(&count)
+	service.db.Scopes(helpers.DBPaginate(cursor.Page, cursor.PageSize)).Where(query).Order("id DESC").Find(&messages)
+	cursor.Update(int(count))
+	return messages
+}
+
+// Attempts the pending messages whose delivery is due
+func (service OutboxService) DeliverPending() {
+	messages := service.claim()
+	for i := range messages {
+		service.deliver(&messages[i])
+	}
+}
+
+// Claims a batch of the pending messages whose delivery is due, so no
+// other instance delivers them at the same time. The rows locked by other
+// instances are skipped, and the claimed messages are postponed by the
+// claim lease until their attempt is stored.
+func (service OutboxService) claim() []models.OutboxMessage {
+	var messages []models.OutboxMessage
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("status = ? AND next_attempt_at <= ?", models.OutboxMessagePending, time.Now()).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Order("next_attempt_at").Limit(outboxDispatchBatch).Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(messages))
+		for _, message := range messages {
+			ids = append(ids, message.ID)
+		}
+		lease := time.Now().Add(outboxClaimLease)
+		return tx.Model(&models.OutboxMessage{}).Where("id IN ?", ids).Update("next_attempt_at", lease).Error
+	})
+	if err != nil {
+		log.Println("Outbox messages cannot be claimed:", err)
+		return nil
+	}
+	return messages
+}
+
+// Sends the given message through the notifier according to its kind
+func (service OutboxService) send(message models.OutboxMessage) error {
+	payload := []byte(message.Payload)
+	switch message.Kind {
+	case models.OutboxKindUserVerifyEmail:
+		var data validators.PelipperUserVerifyEmail
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return service.notifier.SendUserVerifyEmail(data)
+	case models.OutboxKindUserChangePassword:
+		var data validators.PelipperUserChangePassword
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return service.notifier.SendUserChangePasswordEmail(data)
+	case models.OutboxKindUserSignupAttempt:
+		var data validators.PelipperUserSignupAttempt
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return service.notifier.SendUserSignupAttemptEmail(data)
+	case models.OutboxKindOrganizationInvitation:
+		var data validators.PelipperOrganizationInvitation
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return service.notifier.SendOrganizationInvitationEmail(data)
+	case models.OutboxKindUserMagicLink:
+		var data validators.PelipperUserMagicLink
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return service.notifier.SendUserMagicLinkEmail(data)
+	case models.OutboxKindUserDataExport:
+		var data validators.PelipperUserDataExport
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return service.notifier.SendUserDataExportEmail(data)
+	case models.OutboxKindGuardianConsent:
+		var data validators.PelipperGuardianConsent
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return service.notifier.SendGuardianConsentEmail(data)
+	case models.OutboxKindAlertPasswordChanged, models.OutboxKindAlertAppAuthorized,
+		models.OutboxKindAlertNewDevice, models.OutboxKindAlertImpersonated,
+		models.OutboxKindAlertPhoneChanged:
+		var data validators.PelipperSecurityAlert
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return service.notifier.SendSecurityAlertEmail(data)
+	}
+	return fmt.Errorf("unknown outbox message kind %s", message.Kind)
+}
+
+// Delivers the given message and stores the result of the attempt. Failed
+// attempts are retried with exponential backoff until the message runs out
+// of attempts, when it is dead-lettered.
+func (service OutboxService) deliver(message *models.OutboxMessage) {
+	message.Attempts++
+	if err := service.send(*message); err != nil {
+		message.LastError = err.Error()
+		if message.Attempts >= service.maxAttempts {
+			message.Status = models.OutboxMessageDead
+			message.NextAttemptAt = nil
+			log.Println(fmt.Sprintf("Outbox message %s cannot be delivered to %s: %s", message.UUID, message.Recipient, err))
+		} else {
+			next := time.Now().Add(service.backoff * time.Duration(1<<uint(message.Attempts-1)))
+			message.NextAttemptAt = &next
+		}
+		service.db.Save(message)
+		return
+	}
+
+	now := time.Now()
+	message.Status = models.OutboxMessageDelivered
+	message.DeliveredAt = &now
+	message.NextAttemptAt = nil
+	message.LastError = ""
+	message.Payload = "{}"
+	service.db.Save(message)
+}
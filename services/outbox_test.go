@@ -0,0 +1,203 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"gandalf/bindings"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/tests"
+	"gandalf/validators"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockPelipper struct {
+	sent []string
+	err  error
+}
+
+func (mock *mockPelipper) SendUserVerifyEmail(data validators.PelipperUserVerifyEmail) error {
+	mock.sent = append(mock.sent, data.VerificationLink)
+	return mock.err
+}
+
+func (mock *mockPelipper) SendUserChangePasswordEmail(data validators.PelipperUserChangePassword) error {
+	mock.sent = append(mock.sent, data.ChangePasswordLink)
+	return mock.err
+}
+
+func (mock *mockPelipper) SendUserSignupAttemptEmail(data validators.PelipperUserSignupAttempt) error {
+	mock.sent = append(mock.sent, data.Email)
+	return mock.err
+}
+
+func (mock *mockPelipper) SendOrganizationInvitationEmail(data validators.PelipperOrganizationInvitation) error {
+	mock.sent = append(mock.sent, data.InvitationLink)
+	return mock.err
+}
+
+func (mock *mockPelipper) SendUserMagicLinkEmail(data validators.PelipperUserMagicLink) error {
+	mock.sent = append(mock.sent, data.MagicLink)
+	return mock.err
+}
+
+func (mock *mockPelipper) SendUserDataExportEmail(data validators.PelipperUserDataExport) error {
+	mock.sent = append(mock.sent, data.ExportLink)
+	return mock.err
+}
+
+func (mock *mockPelipper) SendGuardianConsentEmail(data validators.PelipperGuardianConsent) error {
+	mock.sent = append(mock.sent, data.ConsentLink)
+	return mock.err
+}
+
+func (mock *mockPelipper) SendSecurityAlertEmail(data validators.PelipperSecurityAlert) error {
+	mock.sent = append(mock.sent, data.LockLink)
+	return mock.err
+}
+
+func TestOutboxServiceConstructor(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		db := tests.NewTestDatabase(true)
+		pelipper := &mockPelipper{}
+		service := NewOutboxService(db, pelipper)
+
+		assert.Equal(service.db, db)
+		assert.Equal(pelipper, service.notifier)
+		assert.Equal(8, service.maxAttempts)
+		assert.Equal(30*time.Second, service.backoff)
+	})
+}
+
+func TestOutboxServiceEnqueue(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test enqueue verification email", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewOutboxService(db, &mockPelipper{})
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		err := service.SendVerificationEmail(user, "")
+		messages := service.List(models.OutboxMessagePending, &helpers.Cursor{Page: 1, PageSize: 10})
+
+		assert.NoError(err)
+		assert.Equal(1, len(messages))
+		assert.Equal(models.OutboxKindUserVerifyEmail, messages[0].Kind)
+		assert.Equal(user.Email, messages[0].Recipient)
+
+		var data validators.PelipperUserVerifyEmail
+		json.Unmarshal([]byte(messages[0].Payload), &data)
+		assert.Contains(data.VerificationLink, "code=")
+	})
+
+	t.Run("Test user creation enqueues verification email", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		userService := NewUserService(db)
+		service := NewOutboxService(db, &mockPelipper{})
+		data := validators.UserCreateData{
+			Email:    "outbox@test.com",
+			Password: "testtesttesttest",
+			Name:     "test",
+			Birthday: bindings.BirthDate(time.Now().AddDate(-30, 0, 0)),
+		}
+
+		user, err := userService.Create(data)
+		messages := service.List("", &helpers.Cursor{Page: 1, PageSize: 10})
+
+		assert.NoError(err)
+		assert.Equal(1, len(messages))
+		assert.Equal(user.Email, messages[0].Recipient)
+	})
+}
+
+func TestOutboxServiceDeliverPending(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test deliver pending messages", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		pelipper := &mockPelipper{}
+		service := NewOutboxService(db, pelipper)
+		user := tests.UserFactory()
+		db.Create(&user)
+		service.SendResetPasswordEmail(user, "", nil)
+
+		service.DeliverPending()
+		messages := service.List(models.OutboxMessageDelivered, &helpers.Cursor{Page: 1, PageSize: 10})
+
+		assert.Equal(1, len(pelipper.sent))
+		assert.Equal(1, len(messages))
+		assert.Equal(1, messages[0].Attempts)
+		assert.NotNil(messages[0].DeliveredAt)
+		assert.Equal("{}", messages[0].Payload)
+	})
+
+	t.Run("Test failed delivery is retried with backoff", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		pelipper := &mockPelipper{err: errors.New("unexpected status 502")}
+		service := NewOutboxService(db, pelipper)
+		user := tests.UserFactory()
+		db.Create(&user)
+		service.SendSignupAttemptEmail(user, "")
+
+		service.DeliverPending()
+		service.DeliverPending()
+		messages := service.List(models.OutboxMessagePending, &helpers.Cursor{Page: 1, PageSize: 10})
+
+		assert.Equal(1, len(pelipper.sent))
+		assert.Equal(1, len(messages))
+		assert.Equal("unexpected status 502", messages[0].LastError)
+		assert.True(messages[0].NextAttemptAt.After(time.Now()))
+	})
+
+	t.Run("Test claimed messages are not claimed again", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewOutboxService(db, &mockPelipper{})
+		message := models.NewOutboxMessage(models.OutboxKindUserSignupAttempt, "test@test.com", `{"Email": "test@test.com"}`)
+		db.Create(&message)
+
+		assert.Equal(1, len(service.claim()))
+		assert.Equal(0, len(service.claim()))
+
+		var stored models.OutboxMessage
+		db.First(&stored, message.ID)
+		assert.Equal(models.OutboxMessagePending, stored.Status)
+		assert.True(stored.NextAttemptAt.After(time.Now()))
+	})
+
+	t.Run("Test message is dead-lettered after max attempts", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		pelipper := &mockPelipper{err: errors.New("connection refused")}
+		service := NewOutboxService(db, pelipper)
+		service.maxAttempts = 1
+		message := models.NewOutboxMessage(models.OutboxKindUserSignupAttempt, "test@test.com", `{"Email": "test@test.com"}`)
+		db.Create(&message)
+
+		service.DeliverPending()
+		messages := service.List(models.OutboxMessageDead, &helpers.Cursor{Page: 1, PageSize: 10})
+
+		assert.Equal(1, len(messages))
+		assert.Nil(messages[0].NextAttemptAt)
+	})
+
+	t.Run("Test unknown kind is dead-lettered", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		pelipper := &mockPelipper{}
+		service := NewOutboxService(db, pelipper)
+		service.maxAttempts = 1
+		message := models.NewOutboxMessage("unknown", "test@test.com", "{}")
+		db.Create(&message)
+
+		service.DeliverPending()
+		messages := service.List(models.OutboxMessageDead, &helpers.Cursor{Page: 1, PageSize: 10})
+
+		assert.Equal(0, len(pelipper.sent))
+		assert.Equal(1, len(messages))
+		assert.Contains(messages[0].LastError, "unknown")
+	})
+}
@@ -6,19 +6,13 @@ import (
 	"fmt"
 	"gandalf/validators"
 	"io"
-	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 )
 
-// Pelipper service interface
-type IPelipperService interface {
-	SendUserVerifyEmail(data validators.PelipperUserVerifyEmail)
-	SendUserChangePasswordEmail(data validators.PelipperUserChangePassword)
-}
-
-// Pelipper is a service through the one we can send notifications to users
+// Pelipper is the notifier driver which sends the notifications through a
+// Pelipper server, where they are rendered
 type PelipperService struct {
 	Host        string
 	SMPTAccount string
@@ -35,39 +29,143 @@ func NewPelipperService() PelipperService {
 	}
 }
 
-func (service PelipperService) manageResponse(response *http.Response, err error, email string) {
-	if err != nil || response.StatusCode != http.StatusCreated {
-		log.Println(fmt.Sprintf("%s -> %s", err.Error(), service.Host))
-		log.Println(fmt.Sprintf("Verification email cannot be sended to %s", email))
+// Returns an error when the notification has not been accepted by pelipper
+func (service PelipperService) manageResponse(response *http.Response, err error) error {
+	if err != nil {
+		return fmt.Errorf("%s -> %s", err.Error(), service.Host)
+	}
+	if response.Body != nil {
+		response.Body.Close()
 	}
+	if response.StatusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected status %d -> %s", response.StatusCode, service.Host)
+	}
+	return nil
 }
 
 // Sends the verification email
-func (service PelipperService) SendUserVerifyEmail(data validators.PelipperUserVerifyEmail) {
+func (service PelipperService) SendUserVerifyEmail(data validators.PelipperUserVerifyEmail) error {
 	payload, _ := json.Marshal(map[string]string{
 		"from":              service.SMPTAccount,
 		"to":                data.Email,
 		"name":              data.Name,
 		"subject":           data.Subject,
+		"locale":            data.Locale,
 		"verification_link": data.VerificationLink,
 	})
 	httptest.NewRecorder()
 
 	response, err := service.post(fmt.Sprintf("%s/emails/users/verify", service.Host), "application/json", bytes.NewBuffer(payload))
-	service.manageResponse(response, err, data.Email)
+	return service.manageResponse(response, err)
 }
 
 // Sends the verification email
-func (service PelipperService) SendUserChangePasswordEmail(data validators.PelipperUserChangePassword) {
+func (service PelipperService) SendUserChangePasswordEmail(data validators.PelipperUserChangePassword) error {
 	payload, _ := json.Marshal(map[string]string{
 		"from":                 service.SMPTAccount,
 		"to":                   data.Email,
 		"name":                 data.Name,
 		"subject":              data.Subject,
+		"locale":               data.Locale,
 		"change_password_link": data.ChangePasswordLink,
 	})
 	httptest.NewRecorder()
 
 	response, err := service.post(fmt.Sprintf("%s/emails/users/change_password", service.Host), "application/json", bytes.NewBuffer(payload))
-	service.manageResponse(response, err, data.Email)
+	return service.manageResponse(response, err)
+}
+
+// Sends the notice about someone trying to sign up with an already
+// registered email
+func (service PelipperService) SendUserSignupAttemptEmail(data validators.PelipperUserSignupAttempt) error {
+	payload, _ := json.Marshal(map[string]string{
+		"from":    service.SMPTAccount,
+		"to":      data.Email,
+		"name":    data.Name,
+		"subject": data.Subject,
+		"locale":  data.Locale,
+	})
+
+	response, err := service.post(fmt.Sprintf("%s/emails/users/signup_attempt", service.Host), "application/json", bytes.NewBuffer(payload))
+	return service.manageResponse(response, err)
+}
+
+// Sends the invitation to join an organization
+func (service PelipperService) SendOrganizationInvitationEmail(data validators.PelipperOrganizationInvitation) error {
+	payload, _ := json.Marshal(map[string]string{
+		"from":              service.SMPTAccount,
+		"to":                data.Email,
+		"subject":           data.Subject,
+		"locale":            data.Locale,
+		"organization_name": data.OrganizationName,
+		"invited_by":        data.InvitedBy,
+		"invitation_link":   data.InvitationLink,
+	})
+
+	response, err := service.post(fmt.Sprintf("%s/emails/organizations/invitation", service.Host), "application/json", bytes.NewBuffer(payload))
+	return service.manageResponse(response, err)
+}
+
+// Sends the magic link the user logs in with
+func (service PelipperService) SendUserMagicLinkEmail(data validators.PelipperUserMagicLink) error {
+	payload, _ := json.Marshal(map[string]string{
+		"from":       service.SMPTAccount,
+		"to":         data.Email,
+		"name":       data.Name,
+		"subject":    data.Subject,
+		"locale":     data.Locale,
+		"magic_link": data.MagicLink,
+	})
+
+	response, err := service.post(fmt.Sprintf("%s/emails/users/magic_link", service.Host), "application/json", bytes.NewBuffer(payload))
+	return service.manageResponse(response, err)
+}
+
+// Sends the link to download the data export of the user
+func (service PelipperService) SendUserDataExportEmail(data validators.PelipperUserDataExport) error {
+	payload, _ := json.Marshal(map[string]string{
+		"from":        service.SMPTAccount,
+		"to":          data.Email,
+		"name":        data.Name,
+		"subject":     data.Subject,
+		"locale":      data.Locale,
+		"export_link": data.ExportLink,
+	})
+
+	response, err := service.post(fmt.Sprintf("%s/emails/users/data_export", service.Host), "application/json", bytes.NewBuffer(payload))
+	return service.manageResponse(response, err)
+}
+
+// Sends the link to consent to the sign-up of a minor to his guardian
+func (service PelipperService) SendGuardianConsentEmail(data validators.PelipperGuardianConsent) error {
+	payload, _ := json.Marshal(map[string]string{
+		"from":         service.SMPTAccount,
+		"to":           data.Email,
+		"name":         data.Name,
+		"subject":      data.Subject,
+		"locale":       data.Locale,
+		"consent_link": data.ConsentLink,
+	})
+
+	response, err := service.post(fmt.Sprintf("%s/emails/users/guardian_consent", service.Host), "application/json", bytes.NewBuffer(payload))
+	return service.manageResponse(response, err)
+}
+
+// Sends a security alert about a sensitive change on the account
+func (service PelipperService) SendSecurityAlertEmail(data validators.PelipperSecurityAlert) error {
+	payload, _ := json.Marshal(map[string]string{
+		"from":      service.SMPTAccount,
+		"to":        data.Email,
+		"name":      data.Name,
+		"subject":   data.Subject,
+		"locale":    data.Locale,
+		"alert":     data.Alert,
+		"app_name":  data.AppName,
+		"device":    data.Device,
+		"ip":        data.IP,
+		"lock_link": data.LockLink,
+	})
+
+	response, err := service.post(fmt.Sprintf("%s/emails/users/security_alert", service.Host), "application/json", bytes.NewBuffer(payload))
+	return service.manageResponse(response, err)
 }
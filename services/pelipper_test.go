@@ -62,7 +62,6 @@ func TestPelipperService(t *testing.T) {
 
 	t.Run("Test manageResponse", func(t *testing.T) {
 		raisedError := errors.New("wrong")
-		email := "test@test.com"
 		mockPost := newMockPost(http.StatusCreated, nil)
 		pelipperService := PelipperService{
 			Host:        "",
@@ -70,7 +69,9 @@ func TestPelipperService(t *testing.T) {
 			post:        mockPost.post,
 		}
 
-		pelipperService.manageResponse(nil, raisedError, email)
+		assert.Error(pelipperService.manageResponse(nil, raisedError))
+		assert.Error(pelipperService.manageResponse(&http.Response{StatusCode: http.StatusBadGateway}, nil))
+		assert.NoError(pelipperService.manageResponse(&http.Response{StatusCode: http.StatusCreated}, nil))
 	})
 
 	t.Run("Test SendUserVerifyEmail successfully", func(t *testing.T) {
@@ -92,7 +93,8 @@ func TestPelipperService(t *testing.T) {
 			VerificationLink: "",
 		}
 
-		pelipperService.SendUserVerifyEmail(emailData)
+		err := pelipperService.SendUserVerifyEmail(emailData)
+		assert.NoError(err)
 		assert.Equal(mockPost.postRecorder.url, expectedURL)
 		assert.Equal(mockPost.postRecorder.contentType, "application/json")
 	})
@@ -116,7 +118,54 @@ func TestPelipperService(t *testing.T) {
 			ChangePasswordLink: "",
 		}
 
-		pelipperService.SendUserChangePasswordEmail(emailData)
+		err := pelipperService.SendUserChangePasswordEmail(emailData)
+		assert.NoError(err)
+		assert.Equal(mockPost.postRecorder.url, expectedURL)
+		assert.Equal(mockPost.postRecorder.contentType, "application/json")
+	})
+
+	t.Run("Test SendUserSignupAttemptEmail successfully", func(t *testing.T) {
+		host := "miscohost"
+		expectedURL := fmt.Sprintf("%s/emails/users/signup_attempt", host)
+		mockPost := newMockPost(http.StatusCreated, nil)
+		pelipperService := PelipperService{
+			Host:        host,
+			SMPTAccount: "miscoAccount",
+			post:        mockPost.post,
+		}
+
+		emailData := validators.PelipperUserSignupAttempt{
+			Email:   "test@test.com",
+			Name:    "",
+			Subject: "",
+		}
+
+		err := pelipperService.SendUserSignupAttemptEmail(emailData)
+		assert.NoError(err)
+		assert.Equal(mockPost.postRecorder.url, expectedURL)
+		assert.Equal(mockPost.postRecorder.contentType, "application/json")
+	})
+
+	t.Run("Test SendOrganizationInvitationEmail successfully", func(t *testing.T) {
+		host := "miscohost"
+		expectedURL := fmt.Sprintf("%s/emails/organizations/invitation", host)
+		mockPost := newMockPost(http.StatusCreated, nil)
+		pelipperService := PelipperService{
+			Host:        host,
+			SMPTAccount: "miscoAccount",
+			post:        mockPost.post,
+		}
+
+		emailData := validators.PelipperOrganizationInvitation{
+			Email:            "test@test.com",
+			Subject:          "",
+			OrganizationName: "",
+			InvitedBy:        "",
+			InvitationLink:   "",
+		}
+
+		err := pelipperService.SendOrganizationInvitationEmail(emailData)
+		assert.NoError(err)
 		assert.Equal(mockPost.postRecorder.url, expectedURL)
 		assert.Equal(mockPost.postRecorder.contentType, "application/json")
 	})
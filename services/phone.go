@@ -0,0 +1,189 @@
+package services
+
+import (
+	"fmt"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/validators"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Interface for phone service
+type IPhoneService interface {
+	SendCode(user models.User, purpose string) error
+	Consume(user models.User, purpose string, code string) error
+	Verify(user *models.User, code string, client helpers.ClientInfo) error
+	SetMFA(user *models.User, enabled bool, proof validators.PhoneProofData, client helpers.ClientInfo) error
+}
+
+// Phone service sends one time codes by SMS to the phones of the users, so
+// they can verify them and use them as second factor and recovery channel
+type PhoneService struct {
+	db          *gorm.DB
+	sender      ISMSSender
+	codeTTL     time.Duration `env:"PHONE_CODE_TTL"`
+	maxAttempts int           `env:"PHONE_CODE_MAX_ATTEMPTS"`
+	alertTTL    time.Duration `env:"SECURITY_ALERT_TOKEN_TTL"`
+}
+
+// Creates a new phone service which sends the codes through the given sender
+func NewPhoneService(db *gorm.DB, sender ISMSSender) PhoneService {
+	return PhoneService{
+		db:          db,
+		sender:      sender,
+		codeTTL:     time.Duration(helpers.GetEnvInt("PHONE_CODE_TTL", 10)),
+		maxAttempts: helpers.GetEnvInt("PHONE_CODE_MAX_ATTEMPTS", 5),
+		alertTTL:    time.Duration(helpers.GetEnvInt("SECURITY_ALERT_TOKEN_TTL", defaultSecurityAlertTTL)),
+	}
+}
+
+// Checks the given secret against the last code sent to the user for the
+// given purpose. Every check counts as an attempt, even the concurrent
+// ones, and a matching code can only be used once.
+func verifyPhoneCode(db *gorm.DB, user models.User, purpose string, secret string, maxAttempts int) (*models.PhoneCode, error) {
+	var code models.PhoneCode
+	query := db.Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).Order("id DESC")
+	if err := query.First(&code).Error; err != nil {
+		return nil, PhoneCodeNotValidError{err}
+	}
+
+	if !code.IsUsable(maxAttempts) {
+		return nil, PhoneCodeNotValidError{nil}
+	}
+
+	result := db.Model(&code).Where("attempts < ?", maxAttempts).Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil || result.RowsAffected != 1 {
+		return nil, PhoneCodeNotValidError{result.Error}
+	}
+
+	if !code.Matches(secret) {
+		return nil, PhoneCodeNotValidError{nil}
+	}
+
+	result = db.Model(&code).Where("used_at IS NULL").Update("used_at", time.Now())
+	if result.Error != nil || result.RowsAffected != 1 {
+		return nil, PhoneCodeNotValidError{result.Error}
+	}
+	return &code, nil
+}
+
+// Checks the second factor of the given user, whose first factor has just
+// been verified by any login method. Users who use their phone as second
+// factor must give the code sent to it; when it is missing
+// PhoneCodeRequiredError is returned, so the code can be sent to him.
+func requireSecondFactor(db *gorm.DB, user models.User, phoneCode string, maxAttempts int, metadata models.AuditMetadata) error {
+	if !user.PhoneMFA {
+		return nil
+	}
+	if phoneCode == "" {
+		return PhoneCodeRequiredError{}
+	}
+	if _, err := verifyPhoneCode(db, user, models.PhoneCodePurposeMFA, phoneCode, maxAttempts); err != nil {
+		metadata["factor"] = "phone"
+		return err
+	}
+	return nil
+}
+
+// Checks the proof the given user gives before changing his phone or
+// turning it off as second factor. Otherwise anyone holding one of his
+// tokens could take over his recovery channel or his second factor.
+func verifyPhoneProof(db *gorm.DB, user models.User, proof validators.PhoneProofData, maxAttempts int) error {
+	if proof.PhoneCode != "" && user.PhoneVerified {
+		_, err := verifyPhoneCode(db, user, models.PhoneCodePurposeConfirm, proof.PhoneCode, maxAttempts)
+		return err
+	}
+	if proof.CurrentPassword != "" && user.VerifyPassword(proof.CurrentPassword) {
+		return nil
+	}
+	return PhoneProofRequiredError{}
+}
+
+// Sends a new code for the given purpose to the phone of the given user.
+// Every previous unused code with the same purpose is invalidated. Only
+// the verification codes can be sent to phones not verified yet.
+func (service PhoneService) SendCode(user models.User, purpose string) error {
+	if user.Phone == "" {
+		return PhoneNotSetError{}
+	}
+	if purpose != models.PhoneCodePurposeVerify && !user.PhoneVerified {
+		return PhoneNotVerifiedError{}
+	}
+
+	code := models.NewPhoneCode(user, purpose, service.codeTTL*time.Minute)
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).
+			Delete(&models.PhoneCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&code).Error
+	})
+	if err != nil {
+		return PhoneCodeSendError{err}
+	}
+
+	body := fmt.Sprintf("Your verification code is %s. It expires in %d minutes.", code.Secret(), service.codeTTL)
+	if err := service.sender.SendSMS(code.Phone, body); err != nil {
+		return PhoneCodeSendError{err}
+	}
+	return nil
+}
+
+// Consumes the last code sent to the given user for the given purpose
+func (service PhoneService) Consume(user models.User, purpose string, code string) error {
+	_, err := verifyPhoneCode(service.db, user, purpose, code, service.maxAttempts)
+	return err
+}
+
+// Verifies the phone of the given user with the given code. The code must
+// have been sent to the phone the user has now.
+func (service PhoneService) Verify(user *models.User, code string, client helpers.ClientInfo) error {
+	audit := AuditContext{user, client}
+	phoneCode, err := verifyPhoneCode(service.db, *user, models.PhoneCodePurposeVerify, code, service.maxAttempts)
+	if err == nil && phoneCode.Phone != user.Phone {
+		err = PhoneCodeNotValidError{nil}
+	}
+	if err != nil {
+		recordAuditEvent(service.db, audit, models.AuditActionVerifyPhone, models.AuditOutcomeFailure, user, nil)
+		return err
+	}
+
+	user.PhoneVerified = true
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("phone_verified", true).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		return recordAuditEvent(tx, audit, models.AuditActionVerifyPhone, models.AuditOutcomeSuccess, user, nil)
+	})
+}
+
+// Turns on or off the phone of the given user as second factor. Only
+// verified phones can be turned on, and turning it off takes the given
+// proof and is alerted to the user.
+func (service PhoneService) SetMFA(user *models.User, enabled bool, proof validators.PhoneProofData, client helpers.ClientInfo) error {
+	if enabled && !user.PhoneVerified {
+		return PhoneNotVerifiedError{}
+	}
+	disabled := user.PhoneMFA && !enabled
+	if disabled {
+		if err := verifyPhoneProof(service.db, *user, proof, service.maxAttempts); err != nil {
+			return err
+		}
+	}
+
+	user.PhoneMFA = enabled
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("phone_mfa", enabled).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		metadata := models.AuditMetadata{"enabled": fmt.Sprint(enabled)}
+		err := recordAuditEvent(tx, AuditContext{user, client}, models.AuditActionPhoneMFA, models.AuditOutcomeSuccess, user, metadata)
+		if err != nil || !disabled {
+			return err
+		}
+		context := NotificationContext{Device: client.UserAgent, IP: client.IP}
+		return enqueueSecurityAlert(tx, *user, models.OutboxKindAlertPhoneChanged, context, service.alertTTL)
+	})
+}
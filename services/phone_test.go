@@ -0,0 +1,190 @@
+package services
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/tests"
+	"gandalf/validators"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var sentPhoneCode = regexp.MustCompile(`\d{6}`)
+
+// Returns the code carried by the last text message sent
+func lastPhoneCode(sender MemorySMSSender) string {
+	messages := sender.Messages()
+	if len(messages) == 0 {
+		return ""
+	}
+	return sentPhoneCode.FindString(messages[len(messages)-1].Body)
+}
+
+func TestPhoneServiceVerify(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test verify phone successfully", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		sender := NewMemorySMSSender()
+		service := NewPhoneService(db, sender)
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		sendErr := service.SendCode(user, models.PhoneCodePurposeVerify)
+		err := service.Verify(&user, lastPhoneCode(sender), helpers.ClientInfo{})
+
+		var stored models.User
+		db.First(&stored, user.ID)
+		assert.NoError(sendErr)
+		assert.NoError(err)
+		assert.Equal(user.Phone, sender.Messages()[0].To)
+		assert.True(stored.PhoneVerified)
+	})
+
+	t.Run("Test codes are single use", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		sender := NewMemorySMSSender()
+		service := NewPhoneService(db, sender)
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		service.SendCode(user, models.PhoneCodePurposeVerify)
+		code := lastPhoneCode(sender)
+		service.Verify(&user, code, helpers.ClientInfo{})
+
+		assert.Error(service.Verify(&user, code, helpers.ClientInfo{}))
+	})
+
+	t.Run("Test codes are locked after too many attempts", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		sender := NewMemorySMSSender()
+		service := NewPhoneService(db, sender)
+		service.maxAttempts = 2
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		service.SendCode(user, models.PhoneCodePurposeVerify)
+		code := lastPhoneCode(sender)
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+		service.Verify(&user, wrong, helpers.ClientInfo{})
+		service.Verify(&user, wrong, helpers.ClientInfo{})
+
+		assert.Error(service.Verify(&user, code, helpers.ClientInfo{}))
+		assert.False(user.PhoneVerified)
+	})
+
+	t.Run("Test codes sent to a previous phone are rejected", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		sender := NewMemorySMSSender()
+		service := NewPhoneService(db, sender)
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		service.SendCode(user, models.PhoneCodePurposeVerify)
+		user.SetPhone("+34600000000")
+
+		assert.Error(service.Verify(&user, lastPhoneCode(sender), helpers.ClientInfo{}))
+	})
+}
+
+func TestPhoneServiceSendCode(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test only verification codes reach unverified phones", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		sender := NewMemorySMSSender()
+		service := NewPhoneService(db, sender)
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		assert.Error(service.SendCode(user, models.PhoneCodePurposeMFA))
+		assert.Error(service.SendCode(user, models.PhoneCodePurposeRecovery))
+		assert.Equal(0, len(sender.Messages()))
+	})
+
+	t.Run("Test resend invalidates the previous code", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		sender := NewMemorySMSSender()
+		service := NewPhoneService(db, sender)
+		user := tests.UserFactory()
+		user.PhoneVerified = true
+		db.Create(&user)
+
+		service.SendCode(user, models.PhoneCodePurposeRecovery)
+		first := lastPhoneCode(sender)
+		service.SendCode(user, models.PhoneCodePurposeRecovery)
+		second := lastPhoneCode(sender)
+
+		if first != second {
+			assert.Error(service.Consume(user, models.PhoneCodePurposeRecovery, first))
+		}
+		assert.NoError(service.Consume(user, models.PhoneCodePurposeRecovery, second))
+	})
+}
+
+func TestPhoneServiceSetMFA(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test mfa requires a verified phone", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewPhoneService(db, NewMemorySMSSender())
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		err := service.SetMFA(&user, true, validators.PhoneProofData{}, helpers.ClientInfo{})
+
+		assert.Error(err, PhoneNotVerifiedError{}.Error())
+		assert.False(user.PhoneMFA)
+	})
+
+	t.Run("Test disabling mfa requires a proof", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		sender := NewMemorySMSSender()
+		service := NewPhoneService(db, sender)
+		plainPassword := "testestestestest"
+		user := tests.UserFactory()
+		user.SetPassword(plainPassword)
+		user.PhoneVerified = true
+		user.PhoneMFA = true
+		db.Create(&user)
+
+		withoutProof := service.SetMFA(&user, false, validators.PhoneProofData{}, helpers.ClientInfo{})
+		wrongPassword := service.SetMFA(&user, false, validators.PhoneProofData{CurrentPassword: "wrong"}, helpers.ClientInfo{})
+		service.SendCode(user, models.PhoneCodePurposeConfirm)
+		err := service.SetMFA(&user, false, validators.PhoneProofData{PhoneCode: lastPhoneCode(sender)}, helpers.ClientInfo{})
+
+		assert.IsType(PhoneProofRequiredError{}, withoutProof)
+		assert.IsType(PhoneProofRequiredError{}, wrongPassword)
+		assert.NoError(err)
+		assert.False(user.PhoneMFA)
+	})
+
+	t.Run("Test login requires the phone code", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		sender := NewMemorySMSSender()
+		service := NewPhoneService(db, sender)
+		authService := NewAuthService(db)
+		plainPassword := "testestestestest"
+		user := tests.UserFactory()
+		user.SetPassword(plainPassword)
+		user.Verified = true
+		user.PhoneVerified = true
+		db.Create(&user)
+		service.SetMFA(&user, true, validators.PhoneProofData{}, helpers.ClientInfo{})
+
+		credentials := validators.Credentials{Email: user.Email, Password: plainPassword}
+		pending, requiredErr := authService.Authenticate(credentials, false, helpers.ClientInfo{})
+		service.SendCode(*pending, models.PhoneCodePurposeMFA)
+		credentials.PhoneCode = lastPhoneCode(sender)
+		authenticated, err := authService.Authenticate(credentials, false, helpers.ClientInfo{})
+
+		assert.IsType(PhoneCodeRequiredError{}, requiredErr)
+		assert.NoError(err)
+		assert.Equal(user.UUID, authenticated.UUID)
+	})
+}
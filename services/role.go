@@ -0,0 +1,94 @@
+package services
+
+import (
+	"gandalf/models"
+
+	"gorm.io/gorm"
+)
+
+// Interface for role service
+type IRoleService interface {
+	List() []models.Role
+	Read(name string) (*models.Role, error)
+	UserRoles(user models.User) []models.Role
+	UserScopes(user models.User) []string
+	Assign(user models.User, name string) error
+	Revoke(user models.User, name string) error
+}
+
+// Role service manages the roles and the permissions granted to the users
+type RoleService struct {
+	db *gorm.DB
+}
+
+// Creates a new role service
+func NewRoleService(db *gorm.DB) RoleService {
+	return RoleService{db}
+}
+
+// Read the roles assigned to the given user with their permissions
+func readUserRoles(db *gorm.DB, user models.User) []models.Role {
+	var roles []models.Role
+	db.Preload("Permissions").
+		Joins("JOIN user_has_role ON user_has_role.role_id = roles.id").
+		Where("user_has_role.user_id = ?", user.ID).
+		Order("roles.id").
+		Find(&roles)
+	return roles
+}
+
+// Read the scopes granted to the given user by his roles
+func readUserScopes(db *gorm.DB, user models.User) []string {
+	user.Roles = readUserRoles(db, user)
+	return user.Scopes()
+}
+
+// List all the roles with their permissions
+func (service RoleService) List() []models.Role {
+	var roles []models.Role
+	service.db.Preload("Permissions").Order("id").Find(&roles)
+	return roles
+}
+
+// Read a role by his name
+func (service RoleService) Read(name string) (*models.Role, error) {
+	var role models.Role
+	if err := service.db.Preload("Permissions").Where(&models.Role{Name: name}).First(&role).Error; err != nil {
+		return nil, RoleNotFoundError{err}
+	}
+	return &role, nil
+}
+
+// List the roles assigned to the given user
+func (service RoleService) UserRoles(user models.User) []models.Role {
+	return readUserRoles(service.db, user)
+}
+
+// Returns the scopes granted to the given user by his roles
+func (service RoleService) UserScopes(user models.User) []string {
+	return readUserScopes(service.db, user)
+}
+
+// Assigns the role with the given name to the given user
+func (service RoleService) Assign(user models.User, name string) error {
+	role, err := service.Read(name)
+	if err != nil {
+		return err
+	}
+	if err := service.db.Model(&user).Omit("Roles.*").Association("Roles").Append(role); err != nil {
+		return RoleAssignmentError{err}
+	}
+	return nil
+}
+
+// Revokes the role with the given name from the given user
+func (service RoleService) Revoke(user models.User, name string) error {
+	role, err := service.Read(name)
+	if err != nil {
+		return err
+	}
+	if err := service.db.Model(&user).Association("Roles").Delete(role); err != nil {
+		return RoleAssignmentError{err}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"gandalf/tests"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestRoleServiceConstructor(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		db := tests.NewTestDatabase(true)
+		service := NewRoleService(db)
+
+		assert.Equal(service.db, db)
+	})
+}
+
+func TestRoleServiceRead(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test read role successfully", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := RoleService{db}
+		role := tests.RoleFactory(faker.RandomString(16))
+		db.Create(&role)
+
+		readRole, err := service.Read(role.Name)
+
+		assert.NoError(err)
+		assert.Equal(role.ID, readRole.ID)
+		assert.Equal(role.Scopes(), readRole.Scopes())
+
+		db.Unscoped().Delete(&role.Permissions)
+		db.Unscoped().Delete(&role)
+	})
+
+	t.Run("Test read role not found", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := RoleService{db}
+
+		_, err := service.Read(faker.RandomString(16))
+
+		assert.Error(err, RoleNotFoundError{nil}.Error())
+	})
+}
+
+func TestRoleServiceAssignAndRevoke(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test assign and revoke role", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := RoleService{db}
+		scope := faker.RandomString(16)
+		role := tests.RoleFactory(scope)
+		user := tests.UserFactory()
+		db.Create(&role)
+		db.Create(&user)
+
+		assert.NoError(service.Assign(user, role.Name))
+		assert.Equal(1, len(service.UserRoles(user)))
+		assert.Equal([]string{scope}, service.UserScopes(user))
+
+		assert.NoError(service.Revoke(user, role.Name))
+		assert.Equal(0, len(service.UserRoles(user)))
+		assert.Equal([]string{}, service.UserScopes(user))
+
+		db.Unscoped().Delete(&user)
+		db.Unscoped().Delete(&role.Permissions)
+		db.Unscoped().Delete(&role)
+	})
+
+	t.Run("Test assign role not found", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := RoleService{db}
+		user := tests.UserFactory()
+
+		err := service.Assign(user, faker.RandomString(16))
+
+		assert.Error(err, RoleNotFoundError{nil}.Error())
+	})
+}
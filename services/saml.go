@@ -0,0 +1,445 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/validators"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/gofrs/uuid"
+	dsig "github.com/russellhaering/goxmldsig"
+	"gorm.io/gorm"
+)
+
+// SAML namespaces and identifiers
+const (
+	samlProtocolNS      = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlAssertionNS     = "urn:oasis:names:tc:SAML:2.0:assertion"
+	samlMetadataNS      = "urn:oasis:names:tc:SAML:2.0:metadata"
+	samlStatusSuccess   = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	samlBearer          = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	samlPasswordContext = "urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport"
+	samlBasicAttribute  = "urn:oasis:names:tc:SAML:2.0:attrname-format:basic"
+)
+
+// SAML bindings the SSO endpoint is served through. Responses are always
+// sent through the POST one.
+const (
+	SAMLBindingRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	SAMLBindingPOST     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+)
+
+// Formats of the name ids, by the setting of the apps
+var samlNameIDFormats = map[string]string{
+	models.SAMLNameIDEmail:      "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress",
+	models.SAMLNameIDPersistent: "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent",
+}
+
+// Maximum size of a decoded request, which bounds the inflation of the
+// ones sent through the redirect binding
+const samlRequestMaxSize = 64 * 1024
+
+// Length of the random part of the ids of the responses and assertions
+const samlIDLength = 32
+
+// Validity of the self-signed certificate created when none is configured
+const samlCertificateTTL = 10 * 365 * 24 * time.Hour
+
+// Authentication request sent by the service providers
+type samlAuthnRequest struct {
+	XMLName                     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID                          string   `xml:"ID,attr"`
+	Version                     string   `xml:"Version,attr"`
+	AssertionConsumerServiceURL string   `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string   `xml:"ProtocolBinding,attr"`
+	Issuer                      string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+}
+
+// Authentication request of a SAML app, checked against its registration
+type SAMLRequest struct {
+	ID         string
+	App        models.App
+	ACSURL     string
+	RelayState string
+
+	// Request base64 encoded without compression, as the login page must
+	// send it back once the user has logged in
+	Encoded string
+
+	// Legal documents the user accepts along with the request
+	AcceptedDocuments []string
+}
+
+// Signed response the browser must post to the app
+type SAMLResponse struct {
+	ACSURL     string
+	Response   string
+	RelayState string
+}
+
+// Interface for SAML service
+type ISAMLService interface {
+	Metadata() ([]byte, error)
+	ReadRequest(data validators.SAMLRequestData, deflated bool) (*SAMLRequest, error)
+	LoginURL(request SAMLRequest) string
+	Respond(request SAMLRequest, user models.User, client helpers.ClientInfo, parent uuid.UUID) (*SAMLResponse, error)
+}
+
+// SAML service makes Gandalf act as a SAML 2.0 identity provider for the
+// apps registered as service providers. Assertions are signed with the
+// configured certificate, which is published on the metadata.
+type SAMLService struct {
+	db           *gorm.DB
+	entityID     string          `env:"SAML_ENTITY_ID"`
+	ssoURL       string          `env:"SAML_SSO_URL"`
+	loginURL     string          `env:"SAML_LOGIN_URL"`
+	assertionTTL time.Duration   `env:"SAML_ASSERTION_TTL"`
+	alertTTL     time.Duration   `env:"SECURITY_ALERT_TOKEN_TTL"`
+	certificate  tls.Certificate `env:"SAML_CERT_FILE"`
+
+	now func() time.Time
+}
+
+// Creates a new SAML service. Its urls default to the ones under the OIDC
+// issuer.
+func NewSAMLService(db *gorm.DB) SAMLService {
+	issuer := strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/")
+	entityID := helpers.GetEnvString("SAML_ENTITY_ID", issuer+"/saml/metadata")
+	return SAMLService{
+		db:           db,
+		entityID:     entityID,
+		ssoURL:       helpers.GetEnvString("SAML_SSO_URL", issuer+"/saml/sso"),
+		loginURL:     helpers.GetEnvString("SAML_LOGIN_URL", issuer+"/login"),
+		assertionTTL: time.Duration(helpers.GetEnvInt("SAML_ASSERTION_TTL", 5)),
+		alertTTL:     time.Duration(helpers.GetEnvInt("SECURITY_ALERT_TOKEN_TTL", defaultSecurityAlertTTL)),
+		certificate:  loadSAMLCertificate(entityID),
+		now:          time.Now,
+	}
+}
+
+// Loads the signing certificate from SAML_CERT_FILE and SAML_KEY_FILE.
+// Without them a self-signed one is created on every start, so the apps
+// must fetch the metadata again after a restart. Panics when the files
+// cannot be loaded, since no assertion could be issued.
+func loadSAMLCertificate(commonName string) tls.Certificate {
+	certFile, keyFile := os.Getenv("SAML_CERT_FILE"), os.Getenv("SAML_KEY_FILE")
+
+	var certificate tls.Certificate
+	var err error
+	if certFile == "" && keyFile == "" {
+		certificate, err = security.NewSelfSignedCertificate(commonName, samlCertificateTTL)
+	} else {
+		certificate, err = security.LoadRSACertificate(certFile, keyFile)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return certificate
+}
+
+// Returns the metadata the service providers are configured with
+func (service SAMLService) Metadata() ([]byte, error) {
+	descriptor := etree.NewElement("md:EntityDescriptor")
+	descriptor.CreateAttr("xmlns:md", samlMetadataNS)
+	descriptor.CreateAttr("xmlns:ds", dsig.Namespace)
+	descriptor.CreateAttr("entityID", service.entityID)
+
+	idp := descriptor.CreateElement("md:IDPSSODescriptor")
+	idp.CreateAttr("WantAuthnRequestsSigned", "false")
+	idp.CreateAttr("protocolSupportEnumeration", samlProtocolNS)
+
+	key := idp.CreateElement("md:KeyDescriptor")
+	key.CreateAttr("use", "signing")
+	certificate := key.CreateElement("ds:KeyInfo").CreateElement("ds:X509Data").CreateElement("ds:X509Certificate")
+	certificate.SetText(base64.StdEncoding.EncodeToString(service.certificate.Certificate[0]))
+
+	for _, format := range []string{models.SAMLNameIDEmail, models.SAMLNameIDPersistent} {
+		idp.CreateElement("md:NameIDFormat").SetText(samlNameIDFormats[format])
+	}
+	for _, binding := range []string{SAMLBindingRedirect, SAMLBindingPOST} {
+		sso := idp.CreateElement("md:SingleSignOnService")
+		sso.CreateAttr("Binding", binding)
+		sso.CreateAttr("Location", service.ssoURL)
+	}
+	return writeSAMLDocument(descriptor)
+}
+
+// Decodes a base64 encoded request, which is also deflated when it comes
+// through the redirect binding
+func decodeSAMLRequest(encoded string, deflated bool) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader = bytes.NewReader(data)
+	if deflated {
+		reader = flate.NewReader(reader)
+	}
+	raw, err := ioutil.ReadAll(io.LimitReader(reader, samlRequestMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > samlRequestMaxSize {
+		return nil, errors.New("request too large")
+	}
+	return raw, nil
+}
+
+// Reads the given authentication request and checks it comes from a SAML
+// app. Requests are not required to be signed, so the response can only
+// be sent to one of the assertion consumer service urls of the app, or to
+// the first one when the request does not name it.
+func (service SAMLService) ReadRequest(data validators.SAMLRequestData, deflated bool) (*SAMLRequest, error) {
+	raw, err := decodeSAMLRequest(data.SAMLRequest, deflated)
+	if err != nil {
+		return nil, SAMLRequestError{err}
+	}
+
+	var request samlAuthnRequest
+	if err := xml.Unmarshal(raw, &request); err != nil {
+		return nil, SAMLRequestError{err}
+	}
+	issuer := strings.TrimSpace(request.Issuer)
+	if request.ID == "" || request.Version != "2.0" || issuer == "" {
+		return nil, SAMLRequestError{errors.New("malformed request")}
+	}
+	if request.ProtocolBinding != "" && request.ProtocolBinding != SAMLBindingPOST {
+		return nil, SAMLRequestError{errors.New("unsupported response binding")}
+	}
+
+	var app models.App
+	if err := service.db.Where(&models.App{Kind: models.AppKindSAML, EntityID: issuer}).First(&app).Error; err != nil {
+		return nil, SAMLRequestError{err}
+	}
+
+	acsURL := request.AssertionConsumerServiceURL
+	if acsURL == "" && len(app.RedirectUrls) > 0 {
+		acsURL = app.RedirectUrls[0]
+	}
+	if !helpers.PqStringArrayContains(app.RedirectUrls, acsURL) {
+		return nil, SAMLRequestError{errors.New("unknown assertion consumer service url")}
+	}
+
+	return &SAMLRequest{
+		ID:         request.ID,
+		App:        app,
+		ACSURL:     acsURL,
+		RelayState: data.RelayState,
+		Encoded:    base64.StdEncoding.EncodeToString(raw),
+
+		AcceptedDocuments: data.AcceptedDocuments,
+	}, nil
+}
+
+// Returns the url of the login page the browser is sent to with the given
+// request
+func (service SAMLService) LoginURL(request SAMLRequest) string {
+	query := url.Values{"SAMLRequest": {request.Encoded}}
+	if request.RelayState != "" {
+		query.Set("RelayState", request.RelayState)
+	}
+
+	separator := "?"
+	if strings.Contains(service.loginURL, "?") {
+		separator = "&"
+	}
+	return service.loginURL + separator + query.Encode()
+}
+
+// Answers the given request on behalf of the given user. A session is
+// started for the app, which is connected to the user as the OAuth apps
+// are on their authorization.
+func (service SAMLService) Respond(request SAMLRequest, user models.User, client helpers.ClientInfo, parent uuid.UUID) (*SAMLResponse, error) {
+	app := request.App
+	if !user.IsOfAge(app.MinimumAge) {
+		return nil, AppAgeRestrictedError{}
+	}
+
+	audit := AuditContext{Actor: &user, Client: client}
+	if err := requireLegalAcceptance(service.db, user, request.AcceptedDocuments, &app, audit); err != nil {
+		return nil, err
+	}
+
+	var parentSession *models.Session
+	if parent != uuid.Nil {
+		parentSession, _ = readActiveSession(service.db, parent)
+	}
+
+	// Only the first sign in on an app is alerted to the user
+	connected := service.db.Model(&user).Where("apps.id = ?", app.ID).Association("ConnectedApps").Count() > 0
+
+	subject := user
+	subject.Roles = readUserRoles(service.db, user)
+
+	var response []byte
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		session, err := createSession(tx, user, &app, parentSession, client)
+		if err != nil {
+			return err
+		}
+		response, err = service.buildResponse(request, subject, session.UUID.String(), service.now())
+		if err != nil {
+			return SAMLResponseError{err}
+		}
+
+		tx.Model(&app).Association("ConnectedUsers").Append(&user)
+
+		metadata := models.AuditMetadata{"app": app.ClientID.String(), "protocol": models.AppKindSAML}
+		err = recordAuditEvent(
+			tx, AuditContext{&user, client},
+			models.AuditActionAuthorizeApp, models.AuditOutcomeSuccess, &user, metadata,
+		)
+		if err != nil || connected {
+			return err
+		}
+		context := NotificationContext{AppName: app.Name, Device: client.UserAgent, IP: client.IP}
+		return enqueueSecurityAlert(tx, user, models.OutboxKindAlertAppAuthorized, context, service.alertTTL)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SAMLResponse{
+		ACSURL:     request.ACSURL,
+		Response:   base64.StdEncoding.EncodeToString(response),
+		RelayState: request.RelayState,
+	}, nil
+}
+
+// Builds the response to the given request, which holds the signed
+// assertion about the given user
+func (service SAMLService) buildResponse(request SAMLRequest, user models.User, sessionIndex string, now time.Time) ([]byte, error) {
+	assertion, err := service.signedAssertion(request, user, sessionIndex, now)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newSAMLID()
+	if err != nil {
+		return nil, err
+	}
+
+	response := etree.NewElement("samlp:Response")
+	response.CreateAttr("xmlns:samlp", samlProtocolNS)
+	response.CreateAttr("xmlns:saml", samlAssertionNS)
+	response.CreateAttr("ID", id)
+	response.CreateAttr("Version", "2.0")
+	response.CreateAttr("IssueInstant", samlTime(now))
+	response.CreateAttr("Destination", request.ACSURL)
+	response.CreateAttr("InResponseTo", request.ID)
+	response.CreateElement("saml:Issuer").SetText(service.entityID)
+	response.CreateElement("samlp:Status").CreateElement("samlp:StatusCode").CreateAttr("Value", samlStatusSuccess)
+	response.AddChild(assertion)
+	return writeSAMLDocument(response)
+}
+
+// Builds the assertion about the given user for the app of the given
+// request, and signs it with an enveloped signature
+func (service SAMLService) signedAssertion(request SAMLRequest, user models.User, sessionIndex string, now time.Time) (*etree.Element, error) {
+	app := request.App
+	id, err := newSAMLID()
+	if err != nil {
+		return nil, err
+	}
+	expiration := samlTime(now.Add(service.assertionTTL * time.Minute))
+
+	assertion := etree.NewElement("saml:Assertion")
+	assertion.CreateAttr("xmlns:saml", samlAssertionNS)
+	assertion.CreateAttr("ID", id)
+	assertion.CreateAttr("Version", "2.0")
+	assertion.CreateAttr("IssueInstant", samlTime(now))
+	assertion.CreateElement("saml:Issuer").SetText(service.entityID)
+
+	subject := assertion.CreateElement("saml:Subject")
+	nameID := subject.CreateElement("saml:NameID")
+	if app.NameIDFormat == models.SAMLNameIDPersistent {
+		nameID.CreateAttr("Format", samlNameIDFormats[models.SAMLNameIDPersistent])
+		nameID.SetText(user.UUID.String())
+	} else {
+		nameID.CreateAttr("Format", samlNameIDFormats[models.SAMLNameIDEmail])
+		nameID.SetText(user.Email)
+	}
+	confirmation := subject.CreateElement("saml:SubjectConfirmation")
+	confirmation.CreateAttr("Method", samlBearer)
+	confirmationData := confirmation.CreateElement("saml:SubjectConfirmationData")
+	confirmationData.CreateAttr("InResponseTo", request.ID)
+	confirmationData.CreateAttr("NotOnOrAfter", expiration)
+	confirmationData.CreateAttr("Recipient", request.ACSURL)
+
+	conditions := assertion.CreateElement("saml:Conditions")
+	conditions.CreateAttr("NotBefore", samlTime(now.Add(-time.Minute)))
+	conditions.CreateAttr("NotOnOrAfter", expiration)
+	conditions.CreateElement("saml:AudienceRestriction").CreateElement("saml:Audience").SetText(app.EntityID)
+
+	statement := assertion.CreateElement("saml:AuthnStatement")
+	statement.CreateAttr("AuthnInstant", samlTime(now))
+	statement.CreateAttr("SessionIndex", sessionIndex)
+	statement.CreateElement("saml:AuthnContext").CreateElement("saml:AuthnContextClassRef").SetText(samlPasswordContext)
+
+	attributes := app.SAMLAttributeValues(user)
+	if len(attributes) > 0 {
+		names := []string{}
+		for name := range attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		attributeStatement := assertion.CreateElement("saml:AttributeStatement")
+		for _, name := range names {
+			attribute := attributeStatement.CreateElement("saml:Attribute")
+			attribute.CreateAttr("Name", name)
+			attribute.CreateAttr("NameFormat", samlBasicAttribute)
+			for _, value := range attributes[name] {
+				attribute.CreateElement("saml:AttributeValue").SetText(value)
+			}
+		}
+	}
+
+	signingContext := dsig.NewDefaultSigningContext(dsig.TLSCertKeyStore(service.certificate))
+	signingContext.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	signature, err := signingContext.ConstructSignature(assertion, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// The schema requires the signature right after the issuer
+	assertion.InsertChildAt(1, signature)
+	return assertion, nil
+}
+
+// Returns a new random id for a SAML message. They must not start with a
+// digit.
+func newSAMLID() (string, error) {
+	secret, err := security.NewUniformSecret().GenerateSecret(samlIDLength)
+	if err != nil {
+		return "", err
+	}
+	return "_" + secret, nil
+}
+
+// Formats the given time as SAML expects
+func samlTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// Serializes the document with the given root element
+func writeSAMLDocument(root *etree.Element) ([]byte, error) {
+	document := etree.NewDocument()
+	document.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	document.SetRoot(root)
+	return document.WriteToBytes()
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/gofrs/uuid"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// Creates a SAML service signing with a new self-signed certificate
+func newTestSAMLService(db *gorm.DB) SAMLService {
+	certificate, err := security.NewSelfSignedCertificate("gandalf", time.Hour)
+	if err != nil {
+		panic(err)
+	}
+	return SAMLService{
+		db:           db,
+		entityID:     "https://gandalf.test/saml/metadata",
+		ssoURL:       "https://gandalf.test/saml/sso",
+		loginURL:     "https://gandalf.test/login",
+		assertionTTL: 5,
+		certificate:  certificate,
+		now:          time.Now,
+	}
+}
+
+// Creates a SAML app registered with the acme entity id
+func newTestSAMLApp() models.App {
+	app := tests.AppFactory()
+	app.Kind = models.AppKindSAML
+	app.EntityID = "https://app.acme.test/saml"
+	app.RedirectUrls = []string{"https://app.acme.test/saml/acs", "https://app.acme.test/saml/acs2"}
+	return app
+}
+
+// Builds an authentication request of the given issuer, encoded for the
+// redirect binding when deflated
+func newTestAuthnRequest(issuer string, acsURL string, deflated bool) string {
+	request := fmt.Sprintf(
+		`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" `+
+			`ID="_request" Version="2.0" AssertionConsumerServiceURL="%s"><saml:Issuer>%s</saml:Issuer></samlp:AuthnRequest>`,
+		acsURL, issuer,
+	)
+	if !deflated {
+		return base64.StdEncoding.EncodeToString([]byte(request))
+	}
+
+	var buffer bytes.Buffer
+	writer, _ := flate.NewWriter(&buffer, flate.DefaultCompression)
+	writer.Write([]byte(request))
+	writer.Close()
+	return base64.StdEncoding.EncodeToString(buffer.Bytes())
+}
+
+// Parses the given response and returns its assertion once its signature
+// has been verified with the certificate of the given service
+func verifySAMLAssertion(service SAMLService, response []byte) (*etree.Element, error) {
+	document := etree.NewDocument()
+	if err := document.ReadFromBytes(response); err != nil {
+		return nil, err
+	}
+	certificate, _ := x509.ParseCertificate(service.certificate.Certificate[0])
+	validation := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+		Roots: []*x509.Certificate{certificate},
+	})
+	return validation.Validate(document.Root().FindElement("./Assertion"))
+}
+
+func TestSAMLMetadata(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test metadata publishes the endpoints and the certificate", func(t *testing.T) {
+		service := newTestSAMLService(nil)
+
+		metadata, err := service.Metadata()
+
+		assert.NoError(err)
+		document := etree.NewDocument()
+		assert.NoError(document.ReadFromBytes(metadata))
+		assert.Equal(service.entityID, document.Root().SelectAttrValue("entityID", ""))
+		certificate := document.Root().FindElement(".//X509Certificate").Text()
+		assert.Equal(base64.StdEncoding.EncodeToString(service.certificate.Certificate[0]), certificate)
+		endpoints := document.Root().FindElements(".//SingleSignOnService")
+		assert.Len(endpoints, 2)
+		assert.Equal(SAMLBindingRedirect, endpoints[0].SelectAttrValue("Binding", ""))
+		assert.Equal(SAMLBindingPOST, endpoints[1].SelectAttrValue("Binding", ""))
+		assert.Equal(service.ssoURL, endpoints[1].SelectAttrValue("Location", ""))
+	})
+}
+
+func TestSAMLRequestDecoding(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test requests of both bindings are decoded", func(t *testing.T) {
+		deflated, deflatedErr := decodeSAMLRequest(newTestAuthnRequest("issuer", "acs", true), true)
+		plain, plainErr := decodeSAMLRequest(newTestAuthnRequest("issuer", "acs", false), false)
+
+		assert.NoError(deflatedErr)
+		assert.NoError(plainErr)
+		assert.Equal(plain, deflated)
+	})
+
+	t.Run("Test oversized requests are rejected", func(t *testing.T) {
+		var buffer bytes.Buffer
+		writer, _ := flate.NewWriter(&buffer, flate.BestCompression)
+		writer.Write(bytes.Repeat([]byte("a"), samlRequestMaxSize+1))
+		writer.Close()
+
+		_, err := decodeSAMLRequest(base64.StdEncoding.EncodeToString(buffer.Bytes()), true)
+
+		assert.Error(err)
+	})
+
+	t.Run("Test malformed encodings are rejected", func(t *testing.T) {
+		_, err := decodeSAMLRequest("not base64!", false)
+
+		assert.Error(err)
+	})
+}
+
+func TestSAMLLoginURL(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test request and relay state are sent to the login page", func(t *testing.T) {
+		service := newTestSAMLService(nil)
+		service.loginURL = "https://gandalf.test/login?app=saml"
+
+		loginURL, err := url.Parse(service.LoginURL(SAMLRequest{Encoded: "cmVxdWVzdA==", RelayState: "/home"}))
+
+		assert.NoError(err)
+		assert.Equal("saml", loginURL.Query().Get("app"))
+		assert.Equal("cmVxdWVzdA==", loginURL.Query().Get("SAMLRequest"))
+		assert.Equal("/home", loginURL.Query().Get("RelayState"))
+	})
+}
+
+func TestSAMLResponse(t *testing.T) {
+	assert := require.New(t)
+	user := tests.UserFactory()
+	user.UUID = uuid.Must(uuid.NewV4())
+
+	t.Run("Test assertion is signed for the app", func(t *testing.T) {
+		service := newTestSAMLService(nil)
+		request := SAMLRequest{ID: "_request", App: newTestSAMLApp(), ACSURL: "https://app.acme.test/saml/acs"}
+
+		response, err := service.buildResponse(request, user, "session", time.Now())
+		assert.NoError(err)
+		assertion, err := verifySAMLAssertion(service, response)
+
+		assert.NoError(err)
+		assert.Equal(user.Email, assertion.FindElement("./Subject/NameID").Text())
+		assert.Equal("_request", assertion.FindElement("./Subject/SubjectConfirmation/SubjectConfirmationData").SelectAttrValue("InResponseTo", ""))
+		assert.Equal(request.App.EntityID, assertion.FindElement("./Conditions/AudienceRestriction/Audience").Text())
+		assert.Equal("session", assertion.FindElement("./AuthnStatement").SelectAttrValue("SessionIndex", ""))
+		document := etree.NewDocument()
+		document.ReadFromBytes(response)
+		assert.Equal("Signature", document.Root().FindElement("./Assertion").ChildElements()[1].Tag)
+		attribute := assertion.FindElement("./AttributeStatement/Attribute[@Name='email']/AttributeValue")
+		assert.Equal(user.Email, attribute.Text())
+	})
+
+	t.Run("Test persistent name id is the user uuid", func(t *testing.T) {
+		service := newTestSAMLService(nil)
+		app := newTestSAMLApp()
+		app.NameIDFormat = models.SAMLNameIDPersistent
+		request := SAMLRequest{ID: "_request", App: app, ACSURL: "https://app.acme.test/saml/acs"}
+
+		response, _ := service.buildResponse(request, user, "session", time.Now())
+		assertion, err := verifySAMLAssertion(service, response)
+
+		assert.NoError(err)
+		assert.Equal(user.UUID.String(), assertion.FindElement("./Subject/NameID").Text())
+	})
+
+	t.Run("Test tampered assertions are rejected", func(t *testing.T) {
+		service := newTestSAMLService(nil)
+		request := SAMLRequest{ID: "_request", App: newTestSAMLApp(), ACSURL: "https://app.acme.test/saml/acs"}
+
+		response, _ := service.buildResponse(request, user, "session", time.Now())
+		tampered := strings.Replace(string(response), user.Email, "mallory@acme.test", -1)
+		_, err := verifySAMLAssertion(service, []byte(tampered))
+
+		assert.Error(err)
+	})
+
+	t.Run("Test assertions signed by other keys are rejected", func(t *testing.T) {
+		service := newTestSAMLService(nil)
+		request := SAMLRequest{ID: "_request", App: newTestSAMLApp(), ACSURL: "https://app.acme.test/saml/acs"}
+
+		response, _ := service.buildResponse(request, user, "session", time.Now())
+		_, err := verifySAMLAssertion(newTestSAMLService(nil), response)
+
+		assert.Error(err)
+	})
+}
+
+func TestSAMLService(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test requests of registered apps are read", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		app := newTestSAMLApp()
+		db.Create(&app)
+		service := newTestSAMLService(db)
+
+		request, err := service.ReadRequest(validators.SAMLRequestData{
+			SAMLRequest: newTestAuthnRequest(app.EntityID, "https://app.acme.test/saml/acs2", true),
+			RelayState:  "/home",
+		}, true)
+
+		assert.NoError(err)
+		assert.Equal("_request", request.ID)
+		assert.Equal(app.ID, request.App.ID)
+		assert.Equal("https://app.acme.test/saml/acs2", request.ACSURL)
+		assert.Equal("/home", request.RelayState)
+	})
+
+	t.Run("Test requests without url are answered to the first one", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		app := newTestSAMLApp()
+		db.Create(&app)
+		service := newTestSAMLService(db)
+
+		request, err := service.ReadRequest(validators.SAMLRequestData{
+			SAMLRequest: newTestAuthnRequest(app.EntityID, "", false),
+		}, false)
+
+		assert.NoError(err)
+		assert.Equal(app.RedirectUrls[0], request.ACSURL)
+	})
+
+	t.Run("Test unregistered urls are rejected", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		app := newTestSAMLApp()
+		db.Create(&app)
+		service := newTestSAMLService(db)
+
+		_, err := service.ReadRequest(validators.SAMLRequestData{
+			SAMLRequest: newTestAuthnRequest(app.EntityID, "https://evil.test/acs", false),
+		}, false)
+
+		assert.IsType(SAMLRequestError{}, err)
+	})
+
+	t.Run("Test OAuth apps cannot send requests", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		app := newTestSAMLApp()
+		app.Kind = models.AppKindOauth
+		db.Create(&app)
+		service := newTestSAMLService(db)
+
+		_, err := service.ReadRequest(validators.SAMLRequestData{
+			SAMLRequest: newTestAuthnRequest(app.EntityID, "", false),
+		}, false)
+
+		assert.IsType(SAMLRequestError{}, err)
+	})
+
+	t.Run("Test response connects the user to the app", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		app := newTestSAMLApp()
+		db.Create(&app)
+		user := tests.UserFactory()
+		db.Create(&user)
+		service := newTestSAMLService(db)
+		request := SAMLRequest{ID: "_request", App: app, ACSURL: app.RedirectUrls[0], RelayState: "/home"}
+
+		response, err := service.Respond(request, user, helpers.ClientInfo{}, uuid.Nil)
+
+		assert.NoError(err)
+		assert.Equal(app.RedirectUrls[0], response.ACSURL)
+		assert.Equal("/home", response.RelayState)
+		decoded, _ := base64.StdEncoding.DecodeString(response.Response)
+		_, err = verifySAMLAssertion(service, decoded)
+		assert.NoError(err)
+		assert.Equal(int64(1), db.Model(&user).Association("ConnectedApps").Count())
+
+		var event models.AuditEvent
+		db.Where(&models.AuditEvent{Action: models.AuditActionAuthorizeApp}).First(&event)
+		assert.Equal(models.AppKindSAML, event.Metadata["protocol"])
+	})
+}
@@ -0,0 +1,779 @@
+package services
+
+import (
+	"encoding/json"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/validators"
+	"net/mail"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Page sizes of the SCIM lists
+const (
+	scimDefaultCount = 100
+	scimMaxCount     = 200
+)
+
+// Length of the random password of the users provisioned without one
+const scimPasswordLength = 48
+
+// Description of the roles created through SCIM
+const scimGroupDescription = "Provisioned through SCIM"
+
+// Path of the SCIM PATCH operations which remove a single member
+var scimMemberPath = regexp.MustCompile(`^members\[value eq "([^"]+)"\]$`)
+
+// A SCIM group is a role along with the users it has been assigned to
+type SCIMGroup struct {
+	Role    models.Role
+	Members []models.User
+}
+
+// Interface for SCIM service
+type ISCIMService interface {
+	BaseURL() string
+
+	// Tokens
+	Authenticate(secret string) (*models.SCIMToken, error)
+	ListTokens() []models.SCIMToken
+	CreateToken(data validators.SCIMTokenCreateData) (*models.SCIMToken, error)
+	ReadToken(uuid uuid.UUID) (*models.SCIMToken, error)
+	DeleteToken(token models.SCIMToken) error
+
+	// Users
+	ListUsers(query validators.SCIMListQuery) ([]models.User, int64, error)
+	ReadUser(uuid uuid.UUID) (*models.User, error)
+	CreateUser(data validators.SCIMUserData) (*models.User, error)
+	ReplaceUser(user *models.User, data validators.SCIMUserData) error
+	PatchUser(user *models.User, data validators.SCIMPatchData) error
+	DeleteUser(user models.User) error
+
+	// Groups
+	ListGroups(query validators.SCIMListQuery, members bool) ([]SCIMGroup, int64, error)
+	ReadGroup(uuid uuid.UUID) (*SCIMGroup, error)
+	CreateGroup(data validators.SCIMGroupData) (*SCIMGroup, error)
+	ReplaceGroup(group *SCIMGroup, data validators.SCIMGroupData) error
+	PatchGroup(group *SCIMGroup, data validators.SCIMPatchData) error
+	DeleteGroup(group SCIMGroup) error
+}
+
+// SCIM service provisions users and groups from the directory of a customer.
+// Users are managed through the user service, and groups are the roles the
+// users are assigned to.
+type SCIMService struct {
+	db          *gorm.DB
+	userService IUserService
+	baseURL     string `env:"SCIM_BASE_URL"`
+}
+
+// Creates a new SCIM service
+func NewSCIMService(db *gorm.DB, userService IUserService) SCIMService {
+	issuer := strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/")
+	return SCIMService{
+		db:          db,
+		userService: userService,
+		baseURL:     strings.TrimSuffix(helpers.GetEnvString("SCIM_BASE_URL", issuer+"/scim/v2"), "/"),
+	}
+}
+
+// Returns the url the SCIM resources are located under
+func (service SCIMService) BaseURL() string {
+	return service.baseURL
+}
+
+// Reads the token with the given secret and records its use
+func (service SCIMService) Authenticate(secret string) (*models.SCIMToken, error) {
+	var token models.SCIMToken
+	query := service.db.Where(&models.SCIMToken{Digest: security.Sha256Digest(secret)})
+	if err := query.First(&token).Error; err != nil {
+		return nil, SCIMTokenNotValidError{err}
+	}
+	now := time.Now()
+	token.LastUsedAt = &now
+	service.db.Model(&token).Update("last_used_at", now)
+	return &token, nil
+}
+
+// List all the SCIM tokens
+func (service SCIMService) ListTokens() []models.SCIMToken {
+	var tokens []models.SCIMToken
+	service.db.Order("id").Find(&tokens)
+	return tokens
+}
+
+// Creates a new SCIM token. Its secret is only available on the returned one.
+func (service SCIMService) CreateToken(data validators.SCIMTokenCreateData) (*models.SCIMToken, error) {
+	token := models.NewSCIMToken(data.Name)
+	if err := service.db.Create(&token).Error; err != nil {
+		return nil, SCIMTokenNotFoundError{err}
+	}
+	return &token, nil
+}
+
+// Read a SCIM token by its UUID
+func (service SCIMService) ReadToken(uuid uuid.UUID) (*models.SCIMToken, error) {
+	var token models.SCIMToken
+	if err := service.db.Where(&models.SCIMToken{UUID: uuid}).First(&token).Error; err != nil {
+		return nil, SCIMTokenNotFoundError{err}
+	}
+	return &token, nil
+}
+
+// Deletes the given SCIM token, which cannot be used anymore
+func (service SCIMService) DeleteToken(token models.SCIMToken) error {
+	if err := service.db.Unscoped().Delete(&token).Error; err != nil {
+		return SCIMTokenNotFoundError{err}
+	}
+	return nil
+}
+
+// Returns the offset and the limit of the page the given query asks for
+func scimPage(query validators.SCIMListQuery) (int, int) {
+	offset := 0
+	if query.StartIndex > 1 {
+		offset = query.StartIndex - 1
+	}
+	limit := scimDefaultCount
+	if query.Count > 0 {
+		limit = query.Count
+	}
+	if limit > scimMaxCount {
+		limit = scimMaxCount
+	}
+	return offset, limit
+}
+
+// Returns a scope which applies the given SCIM filter
+func scimFilter(filter string, attributes map[string]scimAttribute) (func(*gorm.DB) *gorm.DB, error) {
+	condition, err := parseSCIMFilter(filter, attributes)
+	if err != nil {
+		return nil, err
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		if condition.query == "" {
+			return db
+		}
+		return db.Where(condition.query, condition.args...)
+	}, nil
+}
+
+// List the users that match the given filter, along with their roles, and
+// the number of users that match it
+func (service SCIMService) ListUsers(query validators.SCIMListQuery) ([]models.User, int64, error) {
+	filter, err := scimFilter(query.Filter, scimUserAttributes)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	var count int64
+	offset, limit := scimPage(query)
+	service.db.Model(&models.User{}).Scopes(filter).Count(&count)
+	service.db.Scopes(filter).Preload("Roles").Order("id").Offset(offset).Limit(limit).Find(&users)
+	return users, count, nil
+}
+
+// Read a user by his UUID along with his roles
+func (service SCIMService) ReadUser(uuid uuid.UUID) (*models.User, error) {
+	user, err := service.userService.Read(uuid)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = readUserRoles(service.db, *user)
+	return user, nil
+}
+
+// Creates a user through the user service, which mails him the verification
+// email. Users provisioned without password get a random one, so they must
+// reset it or log in through a federated identity.
+func (service SCIMService) CreateUser(data validators.SCIMUserData) (*models.User, error) {
+	if _, err := service.userService.ReadByEmail(data.UserName); err == nil {
+		return nil, SCIMConflictError{}
+	}
+
+	password := data.Password
+	if password == "" {
+		secret, err := security.NewUniformSecret().GenerateSecret(scimPasswordLength)
+		if err != nil {
+			return nil, UserCreateError{err}
+		}
+		password = secret
+	}
+	user, err := service.userService.Create(validators.UserCreateData{
+		Email:    data.UserName,
+		Password: password,
+		Name:     data.Name.GivenName,
+		Surname:  data.Name.FamilyName,
+		Locale:   data.Locale,
+
+		Provisioned: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changes := scimUserChanges{externalID: &data.ExternalID, active: data.Active}
+	if err := service.applyUserChanges(user, changes, true); err != nil {
+		return nil, err
+	}
+	user.Roles = readUserRoles(service.db, *user)
+	return user, nil
+}
+
+// Replaces the attributes of the given user with the given ones. The
+// active flag is kept when it is not given.
+func (service SCIMService) ReplaceUser(user *models.User, data validators.SCIMUserData) error {
+	changes := scimUserChanges{
+		email:      &data.UserName,
+		name:       &data.Name.GivenName,
+		surname:    &data.Name.FamilyName,
+		externalID: &data.ExternalID,
+		active:     data.Active,
+	}
+	if data.Locale != "" {
+		changes.locale = &data.Locale
+	}
+	if data.Password != "" {
+		changes.password = &data.Password
+	}
+	return service.applyUserChanges(user, changes, false)
+}
+
+// Applies the given PATCH operations to the given user. Attributes which are
+// not supported are ignored, as the directories send plenty of them.
+func (service SCIMService) PatchUser(user *models.User, data validators.SCIMPatchData) error {
+	changes := scimUserChanges{}
+	for _, operation := range data.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			return SCIMValueError{}
+		}
+
+		if operation.Path != "" {
+			if err := changes.set(op, operation.Path, operation.Value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var values map[string]json.RawMessage
+		if op == "remove" || json.Unmarshal(operation.Value, &values) != nil {
+			return SCIMValueError{}
+		}
+		for path, value := range values {
+			if err := changes.set(op, path, value); err != nil {
+				return err
+			}
+		}
+	}
+	return service.applyUserChanges(user, changes, false)
+}
+
+// Deletes the given user through the user service
+func (service SCIMService) DeleteUser(user models.User) error {
+	return service.userService.Delete(user.UUID, AuditContext{}, nil)
+}
+
+// Changes of a SCIM request to a user. Nil fields are not changed.
+type scimUserChanges struct {
+	email      *string
+	name       *string
+	surname    *string
+	externalID *string
+	locale     *string
+	password   *string
+	active     *bool
+}
+
+// Sets the change of the attribute at the given path from the given value
+func (changes *scimUserChanges) set(op string, path string, value json.RawMessage) error {
+	path = strings.ToLower(path)
+	if strings.HasPrefix(path, "emails[") || path == "emails.value" {
+		path = "emails"
+	}
+
+	if op == "remove" {
+		empty := ""
+		switch path {
+		case "externalid":
+			changes.externalID = &empty
+		case "name.givenname":
+			changes.name = &empty
+		case "name.familyname":
+			changes.surname = &empty
+		}
+		return nil
+	}
+
+	var err error
+	switch path {
+	case "username", "emails":
+		var email string
+		if email, err = decodeSCIMString(value); err == nil {
+			if _, parseErr := mail.ParseAddress(email); parseErr != nil {
+				return SCIMValueError{parseErr}
+			}
+			changes.email = &email
+		}
+	case "name":
+		var name validators.SCIMName
+		if err = json.Unmarshal(value, &name); err == nil {
+			if name.GivenName != "" {
+				changes.name = &name.GivenName
+			}
+			if name.FamilyName != "" {
+				changes.surname = &name.FamilyName
+			}
+		}
+	case "name.givenname":
+		changes.name, err = decodeSCIMStringPointer(value)
+	case "name.familyname":
+		changes.surname, err = decodeSCIMStringPointer(value)
+	case "externalid":
+		changes.externalID, err = decodeSCIMStringPointer(value)
+	case "locale":
+		changes.locale, err = decodeSCIMStringPointer(value)
+	case "password":
+		var password string
+		if password, err = decodeSCIMString(value); err == nil {
+			if len(password) < 10 {
+				return SCIMValueError{}
+			}
+			changes.password = &password
+		}
+	case "active":
+		var active bool
+		if active, err = decodeSCIMBoolean(value); err == nil {
+			changes.active = &active
+		}
+	}
+	if err != nil {
+		return SCIMValueError{err}
+	}
+	return nil
+}
+
+// Decodes a string value. Multi-valued attributes, like the emails, are
+// decoded to their primary value, or the first one.
+func decodeSCIMString(value json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(value, &text); err == nil {
+		return text, nil
+	}
+
+	var values []validators.SCIMValue
+	if err := json.Unmarshal(value, &values); err != nil {
+		var single validators.SCIMValue
+		if err := json.Unmarshal(value, &single); err != nil {
+			return "", err
+		}
+		values = []validators.SCIMValue{single}
+	}
+	for _, item := range values {
+		if item.Primary {
+			return item.Value, nil
+		}
+	}
+	if len(values) == 0 {
+		return "", SCIMValueError{}
+	}
+	return values[0].Value, nil
+}
+
+func decodeSCIMStringPointer(value json.RawMessage) (*string, error) {
+	text, err := decodeSCIMString(value)
+	if err != nil {
+		return nil, err
+	}
+	return &text, nil
+}
+
+// Decodes a boolean value, which some directories send as a string
+func decodeSCIMBoolean(value json.RawMessage) (bool, error) {
+	var flag bool
+	if err := json.Unmarshal(value, &flag); err == nil {
+		return flag, nil
+	}
+	var text string
+	if err := json.Unmarshal(value, &text); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.ToLower(text))
+}
+
+// Applies the given changes to the given user. Password and locale are
+// updated through the user service, which alerts the user about the new
+// password. Deactivating the user disables him and revokes all his
+// sessions in the same transaction.
+func (service SCIMService) applyUserChanges(user *models.User, changes scimUserChanges, created bool) error {
+	update := validators.UserUpdateData{}
+	if changes.password != nil {
+		update.Password = *changes.password
+	}
+	if changes.locale != nil {
+		update.Locale = *changes.locale
+	}
+	if update.Password != "" || update.Locale != "" {
+		updated, err := service.userService.Update(user.UUID, update)
+		if err != nil {
+			return err
+		}
+		user.Password = updated.Password
+		user.Locale = updated.Locale
+	}
+
+	if changes.email != nil && !strings.EqualFold(*changes.email, user.Email) {
+		if _, err := service.userService.ReadByEmail(*changes.email); err == nil {
+			return SCIMConflictError{}
+		}
+		user.Email = *changes.email
+	}
+	if changes.name != nil {
+		user.Name = *changes.name
+	}
+	if changes.surname != nil {
+		user.Surname = *changes.surname
+	}
+	if changes.externalID != nil {
+		user.ExternalID = *changes.externalID
+	}
+
+	action := ""
+	if created {
+		action = models.AuditActionProvision
+	}
+	if changes.active != nil && user.Disabled == *changes.active {
+		user.Disabled = !*changes.active
+		action = models.AuditActionProvision
+		if user.Disabled {
+			action = models.AuditActionDeprovision
+		}
+	}
+
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(user).Error; err != nil {
+			return SCIMConflictError{err}
+		}
+		if user.Disabled && action == models.AuditActionDeprovision {
+			err := tx.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).
+				Update("revoked_at", time.Now()).Error
+			if err != nil {
+				return SessionNotFoundError{err}
+			}
+		}
+		if action == "" {
+			return nil
+		}
+		metadata := models.AuditMetadata{"source": "scim"}
+		return recordAuditEvent(tx, AuditContext{}, action, models.AuditOutcomeSuccess, user, metadata)
+	})
+}
+
+// Read the users the given role has been assigned to
+func readRoleMembers(db *gorm.DB, role models.Role) []models.User {
+	var users []models.User
+	db.Joins("JOIN user_has_role ON user_has_role.user_id = users.id").
+		Where("user_has_role.role_id = ?", role.ID).
+		Order("users.id").
+		Find(&users)
+	return users
+}
+
+// Check if the given role is one of the default ones, which cannot be
+// renamed nor deleted through SCIM
+func isBuiltinRole(role models.Role) bool {
+	switch role.Name {
+	case models.RoleUser, models.RoleDeveloper, models.RoleStaff, models.RoleSupport:
+		return true
+	}
+	return false
+}
+
+// List the groups that match the given filter and the number of groups that
+// match it. Members are only read when asked, since the default groups
+// may have lots of them.
+func (service SCIMService) ListGroups(query validators.SCIMListQuery, members bool) ([]SCIMGroup, int64, error) {
+	filter, err := scimFilter(query.Filter, scimGroupAttributes)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var roles []models.Role
+	var count int64
+	offset, limit := scimPage(query)
+	service.db.Model(&models.Role{}).Scopes(filter).Count(&count)
+	service.db.Scopes(filter).Order("id").Offset(offset).Limit(limit).Find(&roles)
+
+	groups := make([]SCIMGroup, len(roles))
+	for i, role := range roles {
+		groups[i] = SCIMGroup{Role: role}
+		if members {
+			groups[i].Members = readRoleMembers(service.db, role)
+		}
+	}
+	return groups, count, nil
+}
+
+// Read a group by the UUID of its role along with its members
+func (service SCIMService) ReadGroup(uuid uuid.UUID) (*SCIMGroup, error) {
+	var role models.Role
+	if err := service.db.Where(&models.Role{UUID: uuid}).First(&role).Error; err != nil {
+		return nil, RoleNotFoundError{err}
+	}
+	return &SCIMGroup{Role: role, Members: readRoleMembers(service.db, role)}, nil
+}
+
+// Creates a role without permissions for the given group, which staff
+// users can grant permissions to later on
+func (service SCIMService) CreateGroup(data validators.SCIMGroupData) (*SCIMGroup, error) {
+	if err := service.checkGroupName(data.DisplayName); err != nil {
+		return nil, err
+	}
+
+	group := SCIMGroup{Role: models.NewRole(data.DisplayName, scimGroupDescription, nil)}
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group.Role).Error; err != nil {
+			return SCIMConflictError{err}
+		}
+		members, err := readSCIMMembers(tx, data.Members)
+		if err != nil {
+			return err
+		}
+		return changeRoleMembers(tx, group.Role, members, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+	group.Members = readRoleMembers(service.db, group.Role)
+	return &group, nil
+}
+
+// Renames the given group and replaces its members with the given ones
+func (service SCIMService) ReplaceGroup(group *SCIMGroup, data validators.SCIMGroupData) error {
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		if err := service.renameGroup(tx, group, data.DisplayName); err != nil {
+			return err
+		}
+		members, err := readSCIMMembers(tx, data.Members)
+		if err != nil {
+			return err
+		}
+		return replaceRoleMembers(tx, *group, members)
+	})
+	if err != nil {
+		return err
+	}
+	group.Members = readRoleMembers(service.db, group.Role)
+	return nil
+}
+
+// Applies the given PATCH operations to the given group
+func (service SCIMService) PatchGroup(group *SCIMGroup, data validators.SCIMPatchData) error {
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		for _, operation := range data.Operations {
+			op := strings.ToLower(operation.Op)
+			if op != "add" && op != "replace" && op != "remove" {
+				return SCIMValueError{}
+			}
+
+			if operation.Path != "" {
+				if err := service.patchGroup(tx, group, op, operation.Path, operation.Value); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var values map[string]json.RawMessage
+			if op == "remove" || json.Unmarshal(operation.Value, &values) != nil {
+				return SCIMValueError{}
+			}
+			for path, value := range values {
+				if err := service.patchGroup(tx, group, op, path, value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	group.Members = readRoleMembers(service.db, group.Role)
+	return nil
+}
+
+// Applies a single PATCH operation to the attribute of the given group at
+// the given path
+func (service SCIMService) patchGroup(tx *gorm.DB, group *SCIMGroup, op string, path string, value json.RawMessage) error {
+	if match := scimMemberPath.FindStringSubmatch(path); match != nil && op == "remove" {
+		members, err := readSCIMMembers(tx, []validators.SCIMValue{{Value: match[1]}})
+		if err != nil {
+			return err
+		}
+		return changeRoleMembers(tx, group.Role, members, false)
+	}
+
+	switch strings.ToLower(path) {
+	case "displayname":
+		name, err := decodeSCIMString(value)
+		if op == "remove" || err != nil || name == "" {
+			return SCIMValueError{err}
+		}
+		return service.renameGroup(tx, group, name)
+	case "members":
+		var values []validators.SCIMValue
+		if len(value) > 0 && string(value) != "null" {
+			if err := json.Unmarshal(value, &values); err != nil {
+				return SCIMValueError{err}
+			}
+		}
+		members, err := readSCIMMembers(tx, values)
+		if err != nil {
+			return err
+		}
+		switch {
+		case op == "add":
+			return changeRoleMembers(tx, group.Role, members, true)
+		case op == "replace" || len(values) == 0:
+			return replaceRoleMembers(tx, *group, members)
+		default:
+			return changeRoleMembers(tx, group.Role, members, false)
+		}
+	}
+	return SCIMPathError{}
+}
+
+// Deletes the role of the given group. Its members lose the permissions it
+// granted them.
+func (service SCIMService) DeleteGroup(group SCIMGroup) error {
+	if isBuiltinRole(group.Role) {
+		return SCIMMutabilityError{}
+	}
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&group.Role).Association("Permissions").Clear(); err != nil {
+			return RoleAssignmentError{err}
+		}
+		if err := changeRoleMembers(tx, group.Role, readRoleMembers(tx, group.Role), false); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&group.Role).Error; err != nil {
+			return RoleNotFoundError{err}
+		}
+		return nil
+	})
+}
+
+// Check that no other role has the given name
+func (service SCIMService) checkGroupName(name string) error {
+	var count int64
+	service.db.Unscoped().Model(&models.Role{}).Where("LOWER(name) = LOWER(?)", name).Count(&count)
+	if count > 0 {
+		return SCIMConflictError{}
+	}
+	return nil
+}
+
+// Renames the role of the given group unless it is a default one
+func (service SCIMService) renameGroup(tx *gorm.DB, group *SCIMGroup, name string) error {
+	if name == group.Role.Name {
+		return nil
+	}
+	if isBuiltinRole(group.Role) {
+		return SCIMMutabilityError{}
+	}
+	if err := service.checkGroupName(name); err != nil {
+		return err
+	}
+	if err := tx.Model(&group.Role).Update("name", name).Error; err != nil {
+		return SCIMConflictError{err}
+	}
+	return nil
+}
+
+// Read the users of the given member values, which are their UUIDs
+func readSCIMMembers(db *gorm.DB, values []validators.SCIMValue) ([]models.User, error) {
+	ids := []uuid.UUID{}
+	seen := map[uuid.UUID]bool{}
+	for _, value := range values {
+		id, err := uuid.FromString(value.Value)
+		if err != nil {
+			return nil, SCIMValueError{err}
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return []models.User{}, nil
+	}
+
+	var users []models.User
+	db.Where("uuid IN ?", ids).Find(&users)
+	if len(users) != len(ids) {
+		return nil, SCIMValueError{}
+	}
+	return users, nil
+}
+
+// Assigns the given role to the given users, or revokes it from them. The
+// staff and support roles grant access to the admin API and to act as
+// other users, so their members cannot be changed through SCIM.
+func changeRoleMembers(tx *gorm.DB, role models.Role, users []models.User, assign bool) error {
+	if len(users) == 0 {
+		return nil
+	}
+	if role.Name == models.RoleStaff || role.Name == models.RoleSupport {
+		return SCIMMutabilityError{}
+	}
+	for _, user := range users {
+		association := tx.Model(&user).Omit("Roles.*").Association("Roles")
+		var err error
+		if assign {
+			err = association.Append(&role)
+		} else {
+			err = association.Delete(&role)
+		}
+		if err != nil {
+			return RoleAssignmentError{err}
+		}
+	}
+	return nil
+}
+
+// Replaces the members of the given group with the given users
+func replaceRoleMembers(tx *gorm.DB, group SCIMGroup, users []models.User) error {
+	kept := map[uint]bool{}
+	for _, user := range users {
+		kept[user.ID] = true
+	}
+	current := map[uint]bool{}
+	removed := []models.User{}
+	for _, member := range readRoleMembers(tx, group.Role) {
+		current[member.ID] = true
+		if !kept[member.ID] {
+			removed = append(removed, member)
+		}
+	}
+	added := []models.User{}
+	for _, user := range users {
+		if !current[user.ID] {
+			added = append(added, user)
+		}
+	}
+
+	if err := changeRoleMembers(tx, group.Role, removed, false); err != nil {
+		return err
+	}
+	return changeRoleMembers(tx, group.Role, added, true)
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kinds of the attributes the SCIM resources can be filtered by
+const (
+	scimKindString = iota
+	scimKindUUID
+	scimKindBoolean
+)
+
+// Column of the database a SCIM attribute is filtered by. Negated
+// booleans are stored with the opposite meaning, like the active
+// attribute, which is stored as the disabled flag.
+type scimAttribute struct {
+	column  string
+	kind    int
+	negated bool
+}
+
+// Attributes the SCIM users can be filtered by, in lower case since
+// SCIM attribute names are case insensitive
+var scimUserAttributes = map[string]scimAttribute{
+	"id":              {"uuid", scimKindUUID, false},
+	"username":        {"email", scimKindString, false},
+	"emails":          {"email", scimKindString, false},
+	"emails.value":    {"email", scimKindString, false},
+	"externalid":      {"external_id", scimKindString, false},
+	"name.givenname":  {"name", scimKindString, false},
+	"name.familyname": {"surname", scimKindString, false},
+	"active":          {"disabled", scimKindBoolean, true},
+}
+
+// Attributes the SCIM groups can be filtered by
+var scimGroupAttributes = map[string]scimAttribute{
+	"id":          {"uuid", scimKindUUID, false},
+	"displayname": {"name", scimKindString, false},
+}
+
+// Condition of a SQL query translated from a SCIM filter
+type scimCondition struct {
+	query string
+	args  []interface{}
+}
+
+// Parses SCIM filters by recursive descent. The "and" operator binds
+// tighter than the "or" one, as the SCIM grammar defines.
+type scimFilterParser struct {
+	tokens     []string
+	position   int
+	attributes map[string]scimAttribute
+}
+
+// Translates the given SCIM filter into a SQL condition over the columns of
+// the given attributes. The values are always passed as query arguments.
+// An empty filter returns an empty condition.
+func parseSCIMFilter(filter string, attributes map[string]scimAttribute) (*scimCondition, error) {
+	tokens, err := tokenizeSCIMFilter(filter)
+	if err != nil {
+		return nil, SCIMFilterError{err}
+	}
+	if len(tokens) == 0 {
+		return &scimCondition{}, nil
+	}
+
+	parser := scimFilterParser{tokens: tokens, attributes: attributes}
+	condition, err := parser.parseOr()
+	if err != nil {
+		return nil, SCIMFilterError{err}
+	}
+	if parser.position < len(tokens) {
+		return nil, SCIMFilterError{fmt.Errorf("unexpected %s", tokens[parser.position])}
+	}
+	return condition, nil
+}
+
+// Splits the given filter into parentheses, quoted strings and words
+func tokenizeSCIMFilter(filter string) ([]string, error) {
+	tokens := []string{}
+	for i := 0; i < len(filter); {
+		switch char := filter[i]; {
+		case char == ' ' || char == '\t':
+			i++
+		case char == '(' || char == ')':
+			tokens = append(tokens, string(char))
+			i++
+		case char == '"':
+			end := i + 1
+			for ; end < len(filter) && filter[end] != '"'; end++ {
+				if filter[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(filter) {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, filter[i:end+1])
+			i = end + 1
+		default:
+			end := i
+			for end < len(filter) && !strings.ContainsRune(" \t()\"", rune(filter[end])) {
+				end++
+			}
+			tokens = append(tokens, filter[i:end])
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// Returns the next token in lower case without consuming it
+func (parser *scimFilterParser) peek() string {
+	if parser.position >= len(parser.tokens) {
+		return ""
+	}
+	return strings.ToLower(parser.tokens[parser.position])
+}
+
+// Consumes the next token
+func (parser *scimFilterParser) next() (string, error) {
+	if parser.position >= len(parser.tokens) {
+		return "", errors.New("unexpected end of filter")
+	}
+	parser.position++
+	return parser.tokens[parser.position-1], nil
+}
+
+// Parses the given logical operator and the expressions it joins
+func (parser *scimFilterParser) parseLogical(operator string, operand func() (*scimCondition, error)) (*scimCondition, error) {
+	condition, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for parser.peek() == operator {
+		parser.position++
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		condition = &scimCondition{
+			query: fmt.Sprintf("(%s %s %s)", condition.query, strings.ToUpper(operator), right.query),
+			args:  append(condition.args, right.args...),
+		}
+	}
+	return condition, nil
+}
+
+func (parser *scimFilterParser) parseOr() (*scimCondition, error) {
+	return parser.parseLogical("or", parser.parseAnd)
+}
+
+func (parser *scimFilterParser) parseAnd() (*scimCondition, error) {
+	return parser.parseLogical("and", parser.parseFactor)
+}
+
+// Parses a comparison, a grouped expression or a negated one
+func (parser *scimFilterParser) parseFactor() (*scimCondition, error) {
+	negated := parser.peek() == "not"
+	if negated {
+		parser.position++
+		if parser.peek() != "(" {
+			return nil, errors.New("not must be followed by a grouped expression")
+		}
+	}
+	if parser.peek() != "(" {
+		return parser.parseComparison()
+	}
+
+	parser.position++
+	condition, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token, err := parser.next(); err != nil || token != ")" {
+		return nil, errors.New("missing closing parenthesis")
+	}
+	if negated {
+		condition.query = fmt.Sprintf("(NOT %s)", condition.query)
+	}
+	return condition, nil
+}
+
+// Parses an attribute comparison and translates it to its column
+func (parser *scimFilterParser) parseComparison() (*scimCondition, error) {
+	name, err := parser.next()
+	if err != nil {
+		return nil, err
+	}
+	attribute, ok := parser.attributes[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("attribute %s is not supported", name)
+	}
+	operator, err := parser.next()
+	if err != nil {
+		return nil, err
+	}
+	operator = strings.ToLower(operator)
+	if operator == "pr" {
+		if attribute.kind == scimKindString {
+			return &scimCondition{query: fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", attribute.column, attribute.column)}, nil
+		}
+		return &scimCondition{query: fmt.Sprintf("(%s IS NOT NULL)", attribute.column)}, nil
+	}
+
+	token, err := parser.next()
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(token), &value); err != nil {
+		return nil, fmt.Errorf("value %s is not valid", token)
+	}
+
+	switch attribute.kind {
+	case scimKindBoolean:
+		return compareSCIMBoolean(attribute, operator, value)
+	case scimKindUUID:
+		return compareSCIMUUID(attribute, operator, value)
+	default:
+		return compareSCIMString(attribute, operator, value)
+	}
+}
+
+// Escapes the wildcards of the given value for a LIKE pattern
+func escapeLikePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// Compares a string attribute, ignoring the case as SCIM does for the
+// case insensitive attributes
+func compareSCIMString(attribute scimAttribute, operator string, value interface{}) (*scimCondition, error) {
+	text, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("attribute %s must be compared with a string", attribute.column)
+	}
+	column := attribute.column
+	switch operator {
+	case "eq":
+		return &scimCondition{fmt.Sprintf("(LOWER(%s) = LOWER(?))", column), []interface{}{text}}, nil
+	case "ne":
+		return &scimCondition{fmt.Sprintf("(%s IS NULL OR LOWER(%s) <> LOWER(?))", column, column), []interface{}{text}}, nil
+	case "co":
+		return &scimCondition{fmt.Sprintf("(%s ILIKE ?)", column), []interface{}{"%" + escapeLikePattern(text) + "%"}}, nil
+	case "sw":
+		return &scimCondition{fmt.Sprintf("(%s ILIKE ?)", column), []interface{}{escapeLikePattern(text) + "%"}}, nil
+	case "ew":
+		return &scimCondition{fmt.Sprintf("(%s ILIKE ?)", column), []interface{}{"%" + escapeLikePattern(text)}}, nil
+	}
+	return nil, fmt.Errorf("operator %s is not supported", operator)
+}
+
+// Compares an uuid attribute, which only supports equality
+func compareSCIMUUID(attribute scimAttribute, operator string, value interface{}) (*scimCondition, error) {
+	text, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("attribute %s must be compared with a string", attribute.column)
+	}
+	switch operator {
+	case "eq":
+		return &scimCondition{fmt.Sprintf("(CAST(%s AS text) = LOWER(?))", attribute.column), []interface{}{text}}, nil
+	case "ne":
+		return &scimCondition{fmt.Sprintf("(CAST(%s AS text) <> LOWER(?))", attribute.column), []interface{}{text}}, nil
+	}
+	return nil, fmt.Errorf("operator %s is not supported", operator)
+}
+
+// Compares a boolean attribute, which only supports equality
+func compareSCIMBoolean(attribute scimAttribute, operator string, value interface{}) (*scimCondition, error) {
+	flag, ok := value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("attribute %s must be compared with a boolean", attribute.column)
+	}
+	flag = flag != attribute.negated
+	switch operator {
+	case "eq":
+		return &scimCondition{fmt.Sprintf("(%s = ?)", attribute.column), []interface{}{flag}}, nil
+	case "ne":
+		return &scimCondition{fmt.Sprintf("(%s <> ?)", attribute.column), []interface{}{flag}}, nil
+	}
+	return nil, fmt.Errorf("operator %s is not supported", operator)
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSCIMFilter(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test equality is case insensitive", func(t *testing.T) {
+		condition, err := parseSCIMFilter(`userName Eq "John@Example.com"`, scimUserAttributes)
+
+		assert.NoError(err)
+		assert.Equal("(LOWER(email) = LOWER(?))", condition.query)
+		assert.Equal([]interface{}{"John@Example.com"}, condition.args)
+	})
+
+	t.Run("Test and binds tighter than or", func(t *testing.T) {
+		condition, err := parseSCIMFilter(`externalId eq "1" or name.givenName sw "Jo" and name.familyName co "o"`, scimUserAttributes)
+
+		assert.NoError(err)
+		assert.Equal("((LOWER(external_id) = LOWER(?)) OR ((name ILIKE ?) AND (surname ILIKE ?)))", condition.query)
+		assert.Equal([]interface{}{"1", "Jo%", "%o%"}, condition.args)
+	})
+
+	t.Run("Test grouped and negated expressions", func(t *testing.T) {
+		condition, err := parseSCIMFilter(`not (active eq true) and (id eq "abc" or emails.value pr)`, scimUserAttributes)
+
+		assert.NoError(err)
+		assert.Equal("((NOT (disabled = ?)) AND ((CAST(uuid AS text) = LOWER(?)) OR (email IS NOT NULL AND email <> '')))", condition.query)
+		assert.Equal([]interface{}{false, "abc"}, condition.args)
+	})
+
+	t.Run("Test quoted values keep their spaces and escapes", func(t *testing.T) {
+		condition, err := parseSCIMFilter(`displayName eq "Sales \"EU\" (north)"`, scimGroupAttributes)
+
+		assert.NoError(err)
+		assert.Equal([]interface{}{`Sales "EU" (north)`}, condition.args)
+	})
+
+	t.Run("Test wildcards are escaped", func(t *testing.T) {
+		condition, err := parseSCIMFilter(`userName ew "_100%"`, scimUserAttributes)
+
+		assert.NoError(err)
+		assert.Equal([]interface{}{`%\_100\%`}, condition.args)
+	})
+
+	t.Run("Test empty filters match everything", func(t *testing.T) {
+		condition, err := parseSCIMFilter("  ", scimUserAttributes)
+
+		assert.NoError(err)
+		assert.Empty(condition.query)
+	})
+
+	t.Run("Test invalid filters are rejected", func(t *testing.T) {
+		filters := []string{
+			`password eq "secret"`,
+			`userName gt "a"`,
+			`active eq "true"`,
+			`userName eq`,
+			`userName eq "a`,
+			`(userName eq "a"`,
+			`userName eq "a" userName`,
+			`members[value eq "a"]`,
+			`userName eq unquoted`,
+		}
+		for _, filter := range filters {
+			_, err := parseSCIMFilter(filter, scimUserAttributes)
+
+			assert.IsType(SCIMFilterError{}, err, filter)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"encoding/json"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/tests"
+	"gandalf/validators"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+func TestSCIMPage(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test default page", func(t *testing.T) {
+		offset, limit := scimPage(validators.SCIMListQuery{})
+
+		assert.Equal(0, offset)
+		assert.Equal(scimDefaultCount, limit)
+	})
+
+	t.Run("Test start index is 1-based and count is capped", func(t *testing.T) {
+		offset, limit := scimPage(validators.SCIMListQuery{StartIndex: 11, Count: 1000})
+
+		assert.Equal(10, offset)
+		assert.Equal(scimMaxCount, limit)
+	})
+}
+
+func TestSCIMUserChanges(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test Azure style paths are decoded", func(t *testing.T) {
+		changes := scimUserChanges{}
+
+		assert.NoError(changes.set("replace", "active", json.RawMessage(`"False"`)))
+		assert.NoError(changes.set("replace", `emails[type eq "work"].value`, json.RawMessage(`"john@acme.test"`)))
+		assert.NoError(changes.set("replace", "name.givenName", json.RawMessage(`"John"`)))
+		assert.NoError(changes.set("add", "title", json.RawMessage(`"Engineer"`)))
+
+		assert.False(*changes.active)
+		assert.Equal("john@acme.test", *changes.email)
+		assert.Equal("John", *changes.name)
+		assert.Nil(changes.surname)
+	})
+
+	t.Run("Test Okta style values are decoded", func(t *testing.T) {
+		changes := scimUserChanges{}
+
+		assert.NoError(changes.set("replace", "name", json.RawMessage(`{"familyName": "Doe"}`)))
+		assert.NoError(changes.set("replace", "emails", json.RawMessage(`[{"value": "other@acme.test"}, {"value": "john@acme.test", "primary": true}]`)))
+		assert.NoError(changes.set("replace", "active", json.RawMessage(`true`)))
+
+		assert.Equal("Doe", *changes.surname)
+		assert.Nil(changes.name)
+		assert.Equal("john@acme.test", *changes.email)
+		assert.True(*changes.active)
+	})
+
+	t.Run("Test removed attributes are cleared", func(t *testing.T) {
+		changes := scimUserChanges{}
+
+		assert.NoError(changes.set("remove", "externalId", nil))
+
+		assert.Equal("", *changes.externalID)
+	})
+
+	t.Run("Test invalid values are rejected", func(t *testing.T) {
+		changes := scimUserChanges{}
+
+		assert.IsType(SCIMValueError{}, changes.set("replace", "active", json.RawMessage(`"maybe"`)))
+		assert.IsType(SCIMValueError{}, changes.set("replace", "userName", json.RawMessage(`"not an email"`)))
+		assert.IsType(SCIMValueError{}, changes.set("replace", "password", json.RawMessage(`"short"`)))
+		assert.IsType(SCIMValueError{}, changes.set("replace", "emails", json.RawMessage(`[]`)))
+	})
+}
+
+// Creates a SCIM service backed by a user service on the given db
+func newTestSCIMService() SCIMService {
+	db := tests.NewTestDatabase(false)
+	userService := UserService{db: db, minimumAge: defaultSignupMinimumAge, consentAge: defaultGuardianConsentAge}
+	return SCIMService{db: db, userService: userService, baseURL: "https://gandalf.test/scim/v2"}
+}
+
+func TestSCIMServiceTokens(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test tokens authenticate until they are deleted", func(t *testing.T) {
+		service := newTestSCIMService()
+
+		token, err := service.CreateToken(validators.SCIMTokenCreateData{Name: "Workday"})
+		assert.NoError(err)
+		authenticated, err := service.Authenticate(token.Secret())
+		assert.NoError(err)
+		assert.Equal(token.ID, authenticated.ID)
+		assert.NotNil(authenticated.LastUsedAt)
+
+		assert.NoError(service.DeleteToken(*token))
+		_, err = service.Authenticate(token.Secret())
+		assert.IsType(SCIMTokenNotValidError{}, err)
+	})
+}
+
+func TestSCIMServiceUsers(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test users are provisioned and filtered", func(t *testing.T) {
+		service := newTestSCIMService()
+		email := faker.Internet().Email()
+
+		user, err := service.CreateUser(validators.SCIMUserData{
+			UserName:   email,
+			ExternalID: "00u1",
+			Name:       validators.SCIMName{GivenName: "John", FamilyName: "Doe"},
+		})
+		assert.NoError(err)
+		assert.Equal("00u1", user.ExternalID)
+
+		users, total, err := service.ListUsers(validators.SCIMListQuery{Filter: `userName eq "` + email + `"`})
+		assert.NoError(err)
+		assert.Equal(int64(1), total)
+		assert.Equal(user.ID, users[0].ID)
+
+		_, err = service.CreateUser(validators.SCIMUserData{UserName: email})
+		assert.IsType(SCIMConflictError{}, err)
+
+		var event models.AuditEvent
+		service.db.Where(&models.AuditEvent{Action: models.AuditActionProvision, SubjectID: &user.ID}).First(&event)
+		assert.Equal("scim", event.Metadata["source"])
+		service.db.Unscoped().Delete(user)
+	})
+
+	t.Run("Test deactivated users lose their sessions", func(t *testing.T) {
+		service := newTestSCIMService()
+		user := tests.UserFactory()
+		service.db.Create(&user)
+		session := models.NewSession(user, nil, "", "")
+		service.db.Create(&session)
+
+		err := service.PatchUser(&user, validators.SCIMPatchData{Operations: []validators.SCIMPatchOperation{
+			{Op: "Replace", Path: "active", Value: json.RawMessage(`false`)},
+		}})
+
+		assert.NoError(err)
+		assert.True(user.Disabled)
+		service.db.First(&session, session.ID)
+		assert.NotNil(session.RevokedAt)
+		service.db.Unscoped().Delete(&user)
+	})
+
+	t.Run("Test replaced users keep their email unique", func(t *testing.T) {
+		service := newTestSCIMService()
+		user := tests.UserFactory()
+		other := tests.UserFactory()
+		service.db.Create(&user)
+		service.db.Create(&other)
+
+		err := service.ReplaceUser(&user, validators.SCIMUserData{UserName: other.Email})
+
+		assert.IsType(SCIMConflictError{}, err)
+		service.db.Unscoped().Delete(&user)
+		service.db.Unscoped().Delete(&other)
+	})
+}
+
+func TestSCIMServiceGroups(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test groups are roles whose members can be patched", func(t *testing.T) {
+		service := newTestSCIMService()
+		user := tests.UserFactory()
+		other := tests.UserFactory()
+		service.db.Create(&user)
+		service.db.Create(&other)
+
+		group, err := service.CreateGroup(validators.SCIMGroupData{
+			DisplayName: faker.RandomString(16),
+			Members:     []validators.SCIMValue{{Value: user.UUID.String()}},
+		})
+		assert.NoError(err)
+		assert.Len(group.Members, 1)
+
+		err = service.PatchGroup(group, validators.SCIMPatchData{Operations: []validators.SCIMPatchOperation{
+			{Op: "add", Path: "members", Value: json.RawMessage(`[{"value": "` + other.UUID.String() + `"}]`)},
+			{Op: "remove", Path: `members[value eq "` + user.UUID.String() + `"]`},
+		}})
+		assert.NoError(err)
+		assert.Len(group.Members, 1)
+		assert.Equal(other.ID, group.Members[0].ID)
+
+		assert.NoError(service.DeleteGroup(*group))
+		assert.Empty(readUserRoles(service.db, other))
+		service.db.Unscoped().Delete(&user)
+		service.db.Unscoped().Delete(&other)
+	})
+
+	t.Run("Test default roles cannot be renamed nor deleted", func(t *testing.T) {
+		service := newTestSCIMService()
+		group := SCIMGroup{Role: models.NewRole(models.RoleDeveloper, "", nil)}
+
+		assert.IsType(SCIMMutabilityError{}, service.DeleteGroup(group))
+		assert.IsType(SCIMMutabilityError{}, service.renameGroup(service.db, &group, "engineering"))
+	})
+
+	t.Run("Test staff members cannot be changed", func(t *testing.T) {
+		user := tests.UserFactory()
+		role := models.NewRole(models.RoleStaff, "", []models.Permission{models.NewPermission(security.ScopeUserReadAll, "")})
+
+		err := changeRoleMembers(nil, role, []models.User{user}, true)
+
+		assert.IsType(SCIMMutabilityError{}, err)
+	})
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/validators"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Lock tokens sent with the security alerts live for a week by default, so
+// the users can still lock their accounts when they read the alert late
+const defaultSecurityAlertTTL = 7 * 24 * 60
+
+// Issues a lock token for the given user and enqueues the security alert of
+// the given kind which carries it. Alerts turned off by the user are
+// skipped, but the password ones are always sent. The lock links of the
+// earlier alerts keep working, so a new alert cannot revoke them.
+func enqueueSecurityAlert(db *gorm.DB, user models.User, kind string, context NotificationContext, tokenTTL time.Duration) error {
+	if !user.WantsAlert(kind) {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		lockToken, err := issueOneTimeToken(tx, user, models.TokenPurposeLockAccount, tokenTTL, false)
+		if err != nil {
+			return err
+		}
+
+		context.Name = user.Name
+		context.Email = user.Email
+		context.Link = notificationLink(os.Getenv("ACCOUNT_LOCK_URL"), map[string]string{"code": lockToken})
+		rendered, err := renderNotificationTemplate(findNotificationTemplate(tx, kind, user.Locale, nil), context)
+		if err != nil {
+			return OutboxEnqueueError{err}
+		}
+
+		return enqueueNotification(tx, kind, user.Email, validators.PelipperSecurityAlert{
+			Email:    user.Email,
+			Name:     user.Name,
+			Subject:  rendered.Subject,
+			Alert:    kind,
+			LockLink: context.Link,
+			AppName:  context.AppName,
+			Device:   context.Device,
+			IP:       context.IP,
+			Locale:   rendered.Locale,
+			Text:     rendered.Text,
+			HTML:     rendered.HTML,
+		})
+	})
+}
+
+// Reports whether the given client is a device the user has not logged in
+// from before. Devices are told apart by their user agent, and the first
+// login of an user is never reported.
+func isNewDevice(db *gorm.DB, user models.User, client helpers.ClientInfo) bool {
+	var sessions, known int64
+	db.Model(&models.Session{}).Where("user_id = ?", user.ID).Count(&sessions)
+	db.Model(&models.Session{}).Where("user_id = ? AND user_agent = ?", user.ID, client.UserAgent).Count(&known)
+	return sessions > 0 && known == 0
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"encoding/json"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/tests"
+	"gandalf/validators"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func readSecurityAlerts(db *gorm.DB, user models.User) []validators.PelipperSecurityAlert {
+	var messages []models.OutboxMessage
+	db.Where("recipient = ? AND kind LIKE 'alert-%'", user.Email).Order("id").Find(&messages)
+
+	alerts := make([]validators.PelipperSecurityAlert, len(messages))
+	for i, message := range messages {
+		json.Unmarshal([]byte(message.Payload), &alerts[i])
+	}
+	return alerts
+}
+
+func TestEnqueueSecurityAlert(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test alert carries a lock link", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		user := tests.UserFactory()
+		user.Locale = "es"
+		db.Create(&user)
+
+		context := NotificationContext{Device: "Firefox", IP: "203.0.113.7"}
+		err := enqueueSecurityAlert(db, user, models.OutboxKindAlertNewDevice, context, 10)
+		alerts := readSecurityAlerts(db, user)
+
+		assert.NoError(err)
+		assert.Equal(1, len(alerts))
+		assert.Equal(models.OutboxKindAlertNewDevice, alerts[0].Alert)
+		assert.Equal("Nuevo inicio de sesión en tu cuenta", alerts[0].Subject)
+		assert.Contains(alerts[0].LockLink, "code=")
+		assert.Contains(alerts[0].Text, alerts[0].LockLink)
+		assert.Contains(alerts[0].Text, "Firefox (203.0.113.7)")
+	})
+
+	t.Run("Test a new alert keeps the earlier lock links working", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		enqueueSecurityAlert(db, user, models.OutboxKindAlertPasswordChanged, NotificationContext{}, 10)
+		enqueueSecurityAlert(db, user, models.OutboxKindAlertNewDevice, NotificationContext{}, 10)
+		alerts := readSecurityAlerts(db, user)
+		assert.Equal(2, len(alerts))
+
+		first, err := url.Parse(alerts[0].LockLink)
+		assert.NoError(err)
+		locked, err := consumeOneTimeToken(db, first.Query().Get("code"), models.TokenPurposeLockAccount, nil)
+
+		assert.NoError(err)
+		assert.Equal(user.ID, locked.ID)
+	})
+
+	t.Run("Test alerts turned off are skipped", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		user := tests.UserFactory()
+		user.AppAuthorizedAlerts = false
+		user.NewDeviceAlerts = false
+		db.Create(&user)
+
+		enqueueSecurityAlert(db, user, models.OutboxKindAlertAppAuthorized, NotificationContext{AppName: "App"}, 10)
+		enqueueSecurityAlert(db, user, models.OutboxKindAlertNewDevice, NotificationContext{}, 10)
+		enqueueSecurityAlert(db, user, models.OutboxKindAlertPasswordChanged, NotificationContext{}, 10)
+		alerts := readSecurityAlerts(db, user)
+
+		assert.Equal(1, len(alerts))
+		assert.Equal(models.OutboxKindAlertPasswordChanged, alerts[0].Alert)
+	})
+}
+
+func TestIsNewDevice(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test devices are told apart by user agent", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		user := tests.UserFactory()
+		db.Create(&user)
+		firefox := helpers.ClientInfo{UserAgent: "Firefox", IP: "203.0.113.7"}
+
+		assert.False(isNewDevice(db, user, firefox))
+
+		createSession(db, user, nil, nil, firefox)
+		assert.False(isNewDevice(db, user, firefox))
+		assert.True(isNewDevice(db, user, helpers.ClientInfo{UserAgent: "Chrome"}))
+	})
+}
+
+func TestUserServiceLock(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test lock disables the user and revokes his sessions", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewUserService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+		createSession(db, user, nil, nil, helpers.ClientInfo{UserAgent: "Firefox"})
+		createSession(db, user, nil, nil, helpers.ClientInfo{UserAgent: "Chrome"})
+
+		err := service.Lock(&user, helpers.ClientInfo{})
+
+		var active int64
+		db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Count(&active)
+		var stored models.User
+		db.First(&stored, user.ID)
+		assert.NoError(err)
+		assert.True(stored.Disabled)
+		assert.Equal(int64(0), active)
+	})
+
+	t.Run("Test password change is alerted", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewUserService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+
+		_, err := service.Update(user.UUID, validators.UserUpdateData{Password: "NewPassword"})
+		alerts := readSecurityAlerts(db, user)
+
+		assert.NoError(err)
+		assert.Equal(1, len(alerts))
+		assert.Equal(models.OutboxKindAlertPasswordChanged, alerts[0].Alert)
+	})
+
+	t.Run("Test alert preferences are updated", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := NewUserService(db)
+		user := tests.UserFactory()
+		db.Create(&user)
+		disabled := false
+
+		updated, err := service.Update(user.UUID, validators.UserUpdateData{NewDeviceAlerts: &disabled})
+
+		assert.NoError(err)
+		assert.False(updated.NewDeviceAlerts)
+		assert.True(updated.AppAuthorizedAlerts)
+		assert.Equal(0, len(readSecurityAlerts(db, user)))
+	})
+}
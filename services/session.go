@@ -0,0 +1,106 @@
+package services
+
+import (
+	"gandalf/helpers"
+	"gandalf/models"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// Sessions are seen again at most once per this interval in order to
+// avoid writing into the database on every request
+const sessionSeenInterval = time.Minute
+
+// Interface for session service
+type ISessionService interface {
+	List(user models.User, cursor *helpers.Cursor) []models.Session
+	Read(user models.User, uuid uuid.UUID) (*models.Session, error)
+	Revoke(session models.Session) error
+	RevokeAll(user models.User, except uuid.UUID) error
+}
+
+// Session service manages the sessions where the users are logged in
+type SessionService struct {
+	db *gorm.DB
+}
+
+// Creates a new session service
+func NewSessionService(db *gorm.DB) SessionService {
+	return SessionService{db}
+}
+
+// Creates a new session for the given user from the given client. The app
+// and the parent session are optional.
+func createSession(db *gorm.DB, user models.User, app *models.App, parent *models.Session, client helpers.ClientInfo) (*models.Session, error) {
+	session := models.NewSession(user, app, client.UserAgent, client.IP)
+	if parent != nil {
+		session.ParentID = &parent.ID
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, SessionCreateError{err}
+	}
+	return &session, nil
+}
+
+// Reads the active session with the given uuid and marks it as seen
+func readActiveSession(db *gorm.DB, uuid uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	query := db.Where(&models.Session{UUID: uuid}).Where("revoked_at IS NULL")
+	if err := query.First(&session).Error; err != nil {
+		return nil, SessionNotFoundError{err}
+	}
+
+	if time.Since(session.LastSeenAt) > sessionSeenInterval {
+		session.LastSeenAt = time.Now()
+		db.Model(&session).Update("last_seen_at", session.LastSeenAt)
+	}
+	return &session, nil
+}
+
+// List the active sessions of the given user, the most recently seen first
+func (service SessionService) List(user models.User, cursor *helpers.Cursor) []models.Session {
+	var sessions []models.Session
+	var count int64
+
+	active := func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? AND revoked_at IS NULL", user.ID)
+	}
+
+	service.db.Model(&models.Session{}).Scopes(active).Count(&count)
+	service.db.Preload("App").Scopes(active, helpers.DBPaginate(cursor.Page, cursor.PageSize)).Order("last_seen_at DESC").Find(&sessions)
+	cursor.Update(int(count))
+	return sessions
+}
+
+// Read an active session of the given user by his UUID
+func (service SessionService) Read(user models.User, uuid uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	query := service.db.Where(&models.Session{UUID: uuid, UserID: user.ID}).Where("revoked_at IS NULL")
+	if err := query.First(&session).Error; err != nil {
+		return nil, SessionNotFoundError{err}
+	}
+	return &session, nil
+}
+
+// Revokes the given session, so the tokens issued for it stop working
+func (service SessionService) Revoke(session models.Session) error {
+	if err := service.db.Model(&session).Update("revoked_at", time.Now()).Error; err != nil {
+		return SessionNotFoundError{err}
+	}
+	return nil
+}
+
+// Revokes all the active sessions of the given user but the given one.
+// Use uuid.Nil to revoke all of them.
+func (service SessionService) RevokeAll(user models.User, except uuid.UUID) error {
+	query := service.db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", user.ID)
+	if except != uuid.Nil {
+		query = query.Where("uuid <> ?", except)
+	}
+	if err := query.Update("revoked_at", time.Now()).Error; err != nil {
+		return SessionNotFoundError{err}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"gandalf/helpers"
+	"gandalf/tests"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionServiceConstructor(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		db := tests.NewTestDatabase(true)
+		service := NewSessionService(db)
+
+		assert.Equal(service.db, db)
+	})
+}
+
+func TestSessionServiceRevoke(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test revoke session", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := SessionService{db}
+		user := tests.UserFactory()
+		db.Create(&user)
+		session, err := createSession(db, user, nil, nil, helpers.ClientInfo{})
+		assert.NoError(err)
+
+		assert.NoError(service.Revoke(*session))
+		_, err = service.Read(user, session.UUID)
+		assert.Error(err, SessionNotFoundError{nil}.Error())
+
+		db.Unscoped().Delete(&user)
+	})
+
+	t.Run("Test revoke all sessions but the current one", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := SessionService{db}
+		user := tests.UserFactory()
+		db.Create(&user)
+		current, _ := createSession(db, user, nil, nil, helpers.ClientInfo{})
+		createSession(db, user, nil, nil, helpers.ClientInfo{})
+		createSession(db, user, nil, nil, helpers.ClientInfo{})
+
+		cursor := helpers.NewCursor(0, 10)
+		assert.Equal(3, len(service.List(user, &cursor)))
+
+		assert.NoError(service.RevokeAll(user, current.UUID))
+		sessions := service.List(user, &cursor)
+		assert.Equal(1, len(sessions))
+		assert.Equal(current.UUID, sessions[0].UUID)
+
+		assert.NoError(service.RevokeAll(user, uuid.Nil))
+		assert.Equal(0, len(service.List(user, &cursor)))
+
+		db.Unscoped().Delete(&user)
+	})
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMS drivers which can be chosen through SMS_DRIVER
+const (
+	SMSDriverTwilio = "twilio"
+	SMSDriverStdout = "stdout"
+	SMSDriverMemory = "memory"
+)
+
+const twilioHost = "https://api.twilio.com"
+
+// SMS sender interface, implemented by every transport through the one we
+// can send text messages to the phones of the users
+type ISMSSender interface {
+	SendSMS(to string, body string) error
+}
+
+// A text message sent to a phone
+type SMSMessage struct {
+	To   string
+	Body string
+}
+
+// Twilio sender sends the text messages through the Twilio messaging api
+type TwilioSMSSender struct {
+	host       string
+	accountSID string
+	authToken  string
+	from       string
+	client     *http.Client
+}
+
+// Creates a new Twilio sender from the TWILIO_* settings
+func NewTwilioSMSSender() TwilioSMSSender {
+	return newTwilioSMSSender(
+		twilioHost,
+		os.Getenv("TWILIO_ACCOUNT_SID"),
+		os.Getenv("TWILIO_AUTH_TOKEN"),
+		os.Getenv("TWILIO_FROM"),
+	)
+}
+
+// Creates a new Twilio sender against the given api host
+func newTwilioSMSSender(host string, accountSID string, authToken string, from string) TwilioSMSSender {
+	return TwilioSMSSender{
+		host:       host,
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Sends the given text message
+func (sender TwilioSMSSender) SendSMS(to string, body string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", sender.from)
+	form.Set("Body", body)
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", sender.host, url.PathEscape(sender.accountSID))
+	request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(sender.accountSID, sender.authToken)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := sender.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// Writer sender writes every text message to the given writer, so the codes
+// can be read on development environments
+type WriterSMSSender struct {
+	mutex  *sync.Mutex
+	writer io.Writer
+}
+
+// Creates a new sender which writes the text messages to the given writer
+func NewWriterSMSSender(writer io.Writer) WriterSMSSender {
+	return WriterSMSSender{mutex: &sync.Mutex{}, writer: writer}
+}
+
+// Writes the given text message
+func (sender WriterSMSSender) SendSMS(to string, body string) error {
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+
+	_, err := fmt.Fprintf(sender.writer, "SMS to: %s\n%s\n%s\n", to, body, strings.Repeat("-", 72))
+	return err
+}
+
+// Memory sender keeps every text message in memory, so tests can assert on
+// what has been sent
+type MemorySMSSender struct {
+	mutex    *sync.Mutex
+	messages *[]SMSMessage
+}
+
+// Creates a new in memory sender
+func NewMemorySMSSender() MemorySMSSender {
+	return MemorySMSSender{mutex: &sync.Mutex{}, messages: &[]SMSMessage{}}
+}
+
+// Stores the given text message
+func (sender MemorySMSSender) SendSMS(to string, body string) error {
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+
+	*sender.messages = append(*sender.messages, SMSMessage{To: to, Body: body})
+	return nil
+}
+
+// Returns a copy of the text messages sent so far
+func (sender MemorySMSSender) Messages() []SMSMessage {
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+
+	messages := make([]SMSMessage, len(*sender.messages))
+	copy(messages, *sender.messages)
+	return messages
+}
+
+// Creates the sender configured through SMS_DRIVER, which writes to the
+// standard output by default. Panics on unknown drivers, since no code
+// could be sent.
+func NewSMSSender() ISMSSender {
+	switch driver := os.Getenv("SMS_DRIVER"); driver {
+	case "", SMSDriverStdout:
+		return NewWriterSMSSender(os.Stdout)
+	case SMSDriverTwilio:
+		return NewTwilioSMSSender()
+	case SMSDriverMemory:
+		return NewMemorySMSSender()
+	default:
+		panic(fmt.Sprintf("unknown sms driver %s", driver))
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwilioSMSSender(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test send message", func(t *testing.T) {
+		var request *http.Request
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			request = r
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer server.Close()
+		sender := newTwilioSMSSender(server.URL, "AC123", "secret", "+15005550006")
+
+		err := sender.SendSMS("+34666123456", "Your code is 123456")
+
+		username, password, _ := request.BasicAuth()
+		assert.NoError(err)
+		assert.Equal("/2010-04-01/Accounts/AC123/Messages.json", request.URL.Path)
+		assert.Equal("AC123", username)
+		assert.Equal("secret", password)
+		assert.Equal("+34666123456", request.PostForm.Get("To"))
+		assert.Equal("+15005550006", request.PostForm.Get("From"))
+		assert.Equal("Your code is 123456", request.PostForm.Get("Body"))
+	})
+
+	t.Run("Test send message rejected", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+		sender := newTwilioSMSSender(server.URL, "AC123", "secret", "+15005550006")
+
+		assert.Error(sender.SendSMS("+34666123456", "Your code is 123456"))
+	})
+}
+
+func TestWriterSMSSender(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test write message", func(t *testing.T) {
+		var buffer bytes.Buffer
+		sender := NewWriterSMSSender(&buffer)
+
+		err := sender.SendSMS("+34666123456", "Your code is 123456")
+
+		assert.NoError(err)
+		assert.Contains(buffer.String(), "SMS to: +34666123456")
+		assert.Contains(buffer.String(), "Your code is 123456")
+	})
+}
+
+func TestMemorySMSSender(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test store messages", func(t *testing.T) {
+		sender := NewMemorySMSSender()
+
+		sender.SendSMS("+34666123456", "Your code is 123456")
+		messages := sender.Messages()
+
+		assert.Equal([]SMSMessage{{To: "+34666123456", Body: "Your code is 123456"}}, messages)
+	})
+}
+
+func TestNewSMSSender(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test drivers", func(t *testing.T) {
+		defer os.Unsetenv("SMS_DRIVER")
+
+		os.Unsetenv("SMS_DRIVER")
+		assert.IsType(WriterSMSSender{}, NewSMSSender())
+		os.Setenv("SMS_DRIVER", SMSDriverTwilio)
+		assert.IsType(TwilioSMSSender{}, NewSMSSender())
+		os.Setenv("SMS_DRIVER", SMSDriverMemory)
+		assert.IsType(MemorySMSSender{}, NewSMSSender())
+	})
+
+	t.Run("Test unknown driver panics", func(t *testing.T) {
+		defer os.Unsetenv("SMS_DRIVER")
+		os.Setenv("SMS_DRIVER", "pigeon")
+
+		assert.Panics(func() { NewSMSSender() })
+	})
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMTP notifier sends the rendered notifications straight to an SMTP
+// server, for deployments without pelipper
+type SMTPNotifier struct {
+	messageNotifier
+
+	host     string `env:"SMTP_HOST"`
+	port     string `env:"SMTP_PORT"`
+	username string `env:"SMTP_USERNAME"`
+	password string `env:"SMTP_PASSWORD"`
+	from     string `env:"SMTP_FROM"`
+}
+
+// Creates a new smtp notifier
+func NewSMTPNotifier() SMTPNotifier {
+	return newSMTPNotifier(
+		os.Getenv("SMTP_HOST"),
+		os.Getenv("SMTP_PORT"),
+		os.Getenv("SMTP_USERNAME"),
+		os.Getenv("SMTP_PASSWORD"),
+		os.Getenv("SMTP_FROM"),
+	)
+}
+
+// Creates a new smtp notifier for the given server
+func newSMTPNotifier(host string, port string, username string, password string, from string) SMTPNotifier {
+	notifier := SMTPNotifier{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+	notifier.deliver = notifier.send
+	return notifier
+}
+
+// Sends the given message. Authentication is only used when a username is
+// configured, and net/smtp refuses it over unencrypted remote connections.
+func (notifier SMTPNotifier) send(message NotificationMessage) error {
+	var auth smtp.Auth
+	if notifier.username != "" {
+		auth = smtp.PlainAuth("", notifier.username, notifier.password, notifier.host)
+	}
+
+	headers := []string{
+		fmt.Sprintf("From: %s", notifier.from),
+		fmt.Sprintf("To: %s", message.To),
+		fmt.Sprintf("Subject: %s", mime.QEncoding.Encode("utf-8", message.Subject)),
+		fmt.Sprintf("Date: %s", time.Now().Format(time.RFC1123Z)),
+		"MIME-Version: 1.0",
+	}
+	body, contentType := smtpBody(message)
+	headers = append(headers, fmt.Sprintf("Content-Type: %s", contentType))
+	msg := []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + body)
+
+	addr := net.JoinHostPort(notifier.host, notifier.port)
+	if err := smtp.SendMail(addr, auth, notifier.from, []string{message.To}, msg); err != nil {
+		return fmt.Errorf("%s -> %s", err.Error(), addr)
+	}
+	return nil
+}
+
+// Builds the body of the given message and returns it along with its content
+// type. Messages with html are sent as multipart, so clients without html
+// support can still show the text.
+func smtpBody(message NotificationMessage) (string, string) {
+	text := strings.ReplaceAll(message.Body, "\n", "\r\n")
+	if message.HTML == "" {
+		return text, "text/plain; charset=UTF-8"
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", message.HTML},
+	} {
+		partWriter, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		partWriter.Write([]byte(part.content))
+	}
+	writer.Close()
+	return body.String(), fmt.Sprintf("multipart/alternative; boundary=%s", writer.Boundary())
+}
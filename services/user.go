@@ -1,8 +1,16 @@
 package services
 
 import (
+	"fmt"
+	"gandalf/helpers"
 	"gandalf/models"
+	"gandalf/security"
 	"gandalf/validators"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"gorm.io/gorm"
@@ -16,24 +24,74 @@ type IUserService interface {
 	Read(uuid uuid.UUID) (*models.User, error)
 	ReadByEmail(email string) (*models.User, error)
 	Update(uuid uuid.UUID, userData validators.UserUpdateData) (*models.User, error)
-	Delete(uuid uuid.UUID) error
+	Delete(uuid uuid.UUID, audit AuditContext, admin *AdminActionContext) error
+	List(search string, cursor *helpers.Cursor) []models.User
 
 	// User methods
-	Verificate(*models.User)
-	ResetPassword(user *models.User, password string)
+	Verificate(user *models.User, admin *AdminActionContext) error
+	ResetPassword(user *models.User, password string, client helpers.ClientInfo) error
+	SetDisabled(user *models.User, disabled bool, admin *AdminActionContext) error
+	Lock(user *models.User, client helpers.ClientInfo) error
+	PurgeDeleted()
+	ConsentAsGuardian(code string, client helpers.ClientInfo) (*models.User, error)
 }
 
+// Deleted users can restore their account by logging in during the first
+// 30 days by default
+const defaultAccountDeletionGrace = 30
+
+// Number of deleted users purged on every run
+const userPurgeBatch = 50
+
+// Users must be 13 to sign up by default, and they need the consent of a
+// guardian until 18
+const (
+	defaultSignupMinimumAge   = 13
+	defaultGuardianConsentAge = 18
+)
+
 // User's service
 type UserService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	tokenTTL time.Duration `env:"ONE_TIME_TOKEN_TTL"`
+	alertTTL time.Duration `env:"SECURITY_ALERT_TOKEN_TTL"`
+
+	deletionGrace time.Duration `env:"ACCOUNT_DELETION_GRACE"`
+
+	minimumAge int `env:"SIGNUP_MINIMUM_AGE"`
+	consentAge int `env:"GUARDIAN_CONSENT_AGE"`
+
+	phoneCodeAttempts int `env:"PHONE_CODE_MAX_ATTEMPTS"`
 }
 
 // Creates a new user service
 func NewUserService(db *gorm.DB) UserService {
-	return UserService{db}
+	tokenTTL, _ := strconv.Atoi(os.Getenv("ONE_TIME_TOKEN_TTL"))
+	return UserService{
+		db:       db,
+		tokenTTL: time.Duration(tokenTTL),
+		alertTTL: time.Duration(helpers.GetEnvInt("SECURITY_ALERT_TOKEN_TTL", defaultSecurityAlertTTL)),
+
+		deletionGrace: accountDeletionGrace(),
+
+		minimumAge: helpers.GetEnvInt("SIGNUP_MINIMUM_AGE", defaultSignupMinimumAge),
+		consentAge: helpers.GetEnvInt("GUARDIAN_CONSENT_AGE", defaultGuardianConsentAge),
+
+		phoneCodeAttempts: helpers.GetEnvInt("PHONE_CODE_MAX_ATTEMPTS", 5),
+	}
+}
+
+// Returns the grace period configured through ACCOUNT_DELETION_GRACE, in
+// days, during which the deleted users can restore their account
+func accountDeletionGrace() time.Duration {
+	return time.Duration(helpers.GetEnvInt("ACCOUNT_DELETION_GRACE", defaultAccountDeletionGrace)) * 24 * time.Hour
 }
 
-// Creates a new user
+// Creates a new user and enqueues his verification email in the same
+// transaction. The email is rendered with the templates of the app the
+// user signs up from, if any. The sign-up of minors is held, and the
+// consent email is sent to their guardian instead. The age of provisioned
+// users is only checked when their birthday is given.
 func (service UserService) Create(userData validators.UserCreateData) (*models.User, error) {
 	user := models.NewUser(
 		userData.Email,
@@ -43,14 +101,53 @@ func (service UserService) Create(userData validators.UserCreateData) (*models.U
 		userData.Birthday,
 		userData.Phone,
 	)
+	if userData.Locale != "" {
+		user.Locale = userData.Locale
+	}
 
-	if err := service.db.Create(&user).Error; err != nil {
-		return nil, UserCreateError{err}
+	ageUnknown := userData.Provisioned && user.Birthday.IsZero()
+	if !ageUnknown && !user.IsOfAge(service.minimumAge) {
+		return nil, UserTooYoungError{}
+	}
+	if !ageUnknown && !user.IsOfAge(service.consentAge) {
+		if userData.GuardianEmail == "" || strings.EqualFold(userData.GuardianEmail, userData.Email) {
+			return nil, GuardianConsentRequiredError{}
+		}
+		user.GuardianEmail = userData.GuardianEmail
+	}
+
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		if err := createUser(tx, &user); err != nil {
+			return err
+		}
+		app := readNotificationApp(tx, userData.ClientID)
+		if user.AwaitsGuardianConsent() {
+			return enqueueGuardianConsentEmail(tx, user, app, service.tokenTTL)
+		}
+		return enqueueVerificationEmail(tx, user, app, service.tokenTTL)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &user, nil
 }
 
+// Creates the given new user within the given transaction, whatever the
+// way he signs up. New users get the default role when it has been defined,
+// and the apps are told about them.
+func createUser(tx *gorm.DB, user *models.User) error {
+	var role models.Role
+	if err := tx.Where(&models.Role{Name: models.RoleUser}).First(&role).Error; err == nil {
+		user.Roles = []models.Role{role}
+	}
+	if err := tx.Omit("Roles.*").Create(user).Error; err != nil {
+		return UserCreateError{err}
+	}
+	_, err := enqueueWebhookEvent(tx, models.WebhookEventUserCreated, *user, nil)
+	return err
+}
+
 // Read user from database by his UUID
 func (service UserService) Read(uuid uuid.UUID) (*models.User, error) {
 	var user models.User
@@ -69,43 +166,283 @@ func (service UserService) ReadByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-// Updates the user which belongs to the given ID according to the given user data
+// Updates the user which belongs to the given ID according to the given user
+// data. The phone can only be changed with the proof of the user, since it
+// can reset his password. Password and phone changes are alerted to the
+// user in the same transaction.
 func (service UserService) Update(uuid uuid.UUID, userData validators.UserUpdateData) (*models.User, error) {
 	user, err := service.Read(uuid)
 	if err != nil {
 		return nil, err
 	}
 
+	var alerts []string
+	if userData.Phone != "" && userData.Phone != user.Phone {
+		if err := verifyPhoneProof(service.db, *user, userData.PhoneProofData, service.phoneCodeAttempts); err != nil {
+			return nil, err
+		}
+		user.SetPhone(userData.Phone)
+		alerts = append(alerts, models.OutboxKindAlertPhoneChanged)
+	}
+
 	if userData.Password != "" {
 		user.SetPassword(userData.Password)
+		alerts = append(alerts, models.OutboxKindAlertPasswordChanged)
+	}
+
+	if userData.Locale != "" {
+		user.Locale = userData.Locale
+	}
+
+	if userData.AppAuthorizedAlerts != nil {
+		user.AppAuthorizedAlerts = *userData.AppAuthorizedAlerts
+	}
+
+	if userData.NewDeviceAlerts != nil {
+		user.NewDeviceAlerts = *userData.NewDeviceAlerts
 	}
 
-	if userData.Phone != "" {
-		user.Phone = userData.Phone
+	if len(alerts) == 0 {
+		service.db.Save(user)
+		return user, nil
 	}
 
-	service.db.Save(user)
+	err = service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		for _, alert := range alerts {
+			if err := enqueueSecurityAlert(tx, *user, alert, NotificationContext{}, service.alertTTL); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
-// Set the field `deletes_at` of the user but it will still alive
-// in database. Soft deleted users will not appear as result of any query that
-// not includes `unscoped`
-func (service UserService) Delete(uuid uuid.UUID) error {
-	if err := service.db.Where(&models.User{UUID: uuid}).Delete(&models.User{}).Error; err != nil {
-		return UserNotFoundError{err}
+// Deletes the user which belongs to the given ID. The user is only soft
+// deleted and his sessions are revoked, so he can restore his account by
+// logging in until the grace period is over and he is purged. The given
+// admin action, if any, is recorded along with it.
+func (service UserService) Delete(uuid uuid.UUID, audit AuditContext, admin *AdminActionContext) error {
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Where(&models.User{UUID: uuid}).First(&user).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		err := tx.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return SessionNotFoundError{err}
+		}
+		if _, err := enqueueWebhookEvent(tx, models.WebhookEventUserDeleted, user, nil); err != nil {
+			return err
+		}
+		if err := recordAdminAction(tx, admin, &user); err != nil {
+			return err
+		}
+		return recordAuditEvent(tx, audit, models.AuditActionDeleteUser, models.AuditOutcomeSuccess, &user, nil)
+	})
+}
+
+// Restores the given deleted user, who has logged in during the grace
+// period of his deletion
+func restoreUser(db *gorm.DB, user *models.User, audit AuditContext) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(user).Update("deleted_at", nil).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		user.DeletedAt = gorm.DeletedAt{}
+		return recordAuditEvent(tx, audit, models.AuditActionRestoreUser, models.AuditOutcomeSuccess, user, nil)
+	})
+}
+
+// Purges the deleted users whose grace period is over. Their personal data
+// is wiped and the records which only make sense for them, like their
+// claims and sessions, are removed.
+func (service UserService) PurgeDeleted() {
+	var users []models.User
+	service.db.Unscoped().Where("deleted_at <= ? AND purged_at IS NULL", time.Now().Add(-service.deletionGrace)).
+		Order("id").Limit(userPurgeBatch).Find(&users)
+
+	for i := range users {
+		if err := purgeUser(service.db, &users[i]); err != nil {
+			log.Println("User", users[i].UUID, "cannot be purged:", err)
+		}
+	}
+}
+
+// Wipes the personal data of the given user and removes his records. The
+// notifications and webhook deliveries which still carry his email are
+// removed whatever their status. The audit events are kept, since the log
+// is append-only, but they only point to the anonymized row and their
+// metadata never holds plain emails, only digests. The apps he was
+// connected to are told, without his personal data, before his connections
+// are removed.
+func purgeUser(db *gorm.DB, user *models.User) error {
+	owned := []interface{}{
+		&models.Claim{}, &models.Session{}, &models.OneTimeToken{}, &models.PhoneCode{},
+		&models.FederatedIdentity{}, &models.DataExport{}, &models.Membership{},
 	}
-	return nil
+	return db.Transaction(func(tx *gorm.DB) error {
+		if user.Email != "" {
+			outbox := tx.Unscoped().Where("recipient = ? OR strpos(payload::text, ?) > 0", user.Email, user.Email)
+			if err := outbox.Delete(&models.OutboxMessage{}).Error; err != nil {
+				return UserPurgeError{err}
+			}
+			deliveries := tx.Unscoped().Where("strpos(payload::text, ?) > 0", user.Email)
+			if err := deliveries.Delete(&models.WebhookDelivery{}).Error; err != nil {
+				return UserPurgeError{err}
+			}
+		}
+
+		anonymized := *user
+		anonymized.Anonymize()
+		if _, err := enqueueWebhookEvent(tx, models.WebhookEventUserPurged, anonymized, nil); err != nil {
+			return err
+		}
+
+		for _, model := range owned {
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return UserPurgeError{err}
+			}
+		}
+		for _, association := range []string{"Roles", "ConnectedApps"} {
+			if err := tx.Model(user).Association(association).Clear(); err != nil {
+				return UserPurgeError{err}
+			}
+		}
+
+		user.Anonymize()
+		if err := tx.Unscoped().Save(user).Error; err != nil {
+			return UserPurgeError{err}
+		}
+		return recordAuditEvent(tx, AuditContext{}, models.AuditActionPurgeUser, models.AuditOutcomeSuccess, user, nil)
+	})
 }
 
-// Verificates the given user
-func (service UserService) Verificate(user *models.User) {
+// List users whose email, name or surname contains the given search
+// term. An empty term lists every user.
+func (service UserService) List(search string, cursor *helpers.Cursor) []models.User {
+	var users []models.User
+	var count int64
+
+	filter := func(db *gorm.DB) *gorm.DB {
+		if search == "" {
+			return db
+		}
+		term := fmt.Sprintf("%%%s%%", search)
+		return db.Where("email ILIKE ? OR name ILIKE ? OR surname ILIKE ?", term, term, term)
+	}
+
+	service.db.Model(&models.User{}).Scopes(filter).Count(&count)
+	service.db.Scopes(filter, helpers.DBPaginate(cursor.Page, cursor.PageSize)).Order("id").Find(&users)
+	cursor.Update(int(count))
+	return users
+}
+
+// Verificates the given user. The given admin action, if any, is recorded
+// along with it.
+func (service UserService) Verificate(user *models.User, admin *AdminActionContext) error {
 	user.Verified = true
-	service.db.Save(user)
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		if _, err := enqueueWebhookEvent(tx, models.WebhookEventUserVerified, *user, nil); err != nil {
+			return err
+		}
+		return recordAdminAction(tx, admin, user)
+	})
 }
 
-// Reset the user password to the given one
-func (service UserService) ResetPassword(user *models.User, password string) {
+// Reset the user password to the given one and alert the user about it
+func (service UserService) ResetPassword(user *models.User, password string, client helpers.ClientInfo) error {
 	user.SetPassword(password)
-	service.db.Save(user)
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		err := recordAuditEvent(
+			tx, AuditContext{user, client},
+			models.AuditActionResetPassword, models.AuditOutcomeSuccess, user, nil,
+		)
+		if err != nil {
+			return err
+		}
+		context := NotificationContext{Device: client.UserAgent, IP: client.IP}
+		return enqueueSecurityAlert(tx, *user, models.OutboxKindAlertPasswordChanged, context, service.alertTTL)
+	})
+}
+
+// Disables or enables the given user. Disabled users cannot log in
+// nor use their tokens. The given admin action, if any, is recorded along
+// with it.
+func (service UserService) SetDisabled(user *models.User, disabled bool, admin *AdminActionContext) error {
+	user.Disabled = disabled
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		return recordAdminAction(tx, admin, user)
+	})
+}
+
+// Locks the given user after a security alert has been reported as not
+// made by him. The user is disabled and all his sessions are revoked.
+func (service UserService) Lock(user *models.User, client helpers.ClientInfo) error {
+	user.Disabled = true
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		err := tx.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return SessionNotFoundError{err}
+		}
+		return recordAuditEvent(
+			tx, AuditContext{user, client},
+			models.AuditActionLockAccount, models.AuditOutcomeSuccess, user, nil,
+		)
+	})
+}
+
+// Consents to the sign-up of the minor the given code was mailed to his
+// guardian for. The sign-up is no longer held, so the verification email
+// is sent to the minor.
+func (service UserService) ConsentAsGuardian(code string, client helpers.ClientInfo) (*models.User, error) {
+	var user *models.User
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		minor, err := consumeOneTimeToken(tx, code, models.TokenPurposeGuardian, nil)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(minor).Update("guardian_consent_at", now).Error; err != nil {
+			return UserNotFoundError{err}
+		}
+		minor.GuardianConsentAt = &now
+		user = minor
+
+		// The audit log outlives the purge of the users, so it never holds
+		// plain emails
+		metadata := models.AuditMetadata{"guardian_digest": security.Sha256Digest(strings.ToLower(minor.GuardianEmail))}
+		if err := recordAuditEvent(tx, AuditContext{Client: client}, models.AuditActionGuardian, models.AuditOutcomeSuccess, minor, metadata); err != nil {
+			return err
+		}
+		return enqueueVerificationEmail(tx, *minor, nil, service.tokenTTL)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
 }
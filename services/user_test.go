@@ -2,6 +2,8 @@ package services
 
 import (
 	"gandalf/bindings"
+	"gandalf/helpers"
+	"gandalf/models"
 	"gandalf/tests"
 	"gandalf/validators"
 	"testing"
@@ -27,7 +29,7 @@ func TestUserServiceCreate(t *testing.T) {
 
 	t.Run("Test user create successfully", func(t *testing.T) {
 		db := tests.NewTestDatabase(false)
-		service := UserService{db}
+		service := UserService{db: db}
 		userData := validators.UserCreateData{
 			Email:    "test@test.com",
 			Password: "testestestestest",
@@ -48,7 +50,7 @@ func TestUserServiceCreate(t *testing.T) {
 
 	t.Run("Test user create database error", func(t *testing.T) {
 		db := tests.NewTestDatabase(false)
-		service := UserService{db}
+		service := UserService{db: db}
 		userData := validators.UserCreateData{
 			Email:    "test@test.com",
 			Password: "testestestestest",
@@ -65,12 +67,114 @@ func TestUserServiceCreate(t *testing.T) {
 	})
 }
 
+func TestUserServiceCreateMinor(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test user under the minimum age is rejected", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := UserService{db: db, minimumAge: 13, consentAge: 18}
+		userData := validators.UserCreateData{
+			Email:    "test@test.com",
+			Password: "testestestestest",
+			Name:     "test",
+			Surname:  "test",
+			Birthday: bindings.BirthDate(time.Now().AddDate(-10, 0, 0)),
+		}
+
+		_, err := service.Create(userData)
+
+		assert.IsType(UserTooYoungError{}, err)
+	})
+
+	t.Run("Test provisioned user without birthday skips the age check", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := UserService{db: db, minimumAge: 13, consentAge: 18}
+		userData := validators.UserCreateData{
+			Email:    "test@test.com",
+			Password: "testestestestest",
+			Name:     "test",
+			Surname:  "test",
+		}
+
+		_, err := service.Create(userData)
+		assert.IsType(UserTooYoungError{}, err)
+
+		userData.Provisioned = true
+		user, err := service.Create(userData)
+		assert.NoError(err)
+		assert.False(user.AwaitsGuardianConsent())
+
+		userData.Email = "minor@test.com"
+		userData.Birthday = bindings.BirthDate(time.Now().AddDate(-10, 0, 0))
+		_, err = service.Create(userData)
+		assert.IsType(UserTooYoungError{}, err)
+
+		db.Unscoped().Where("1 = 1").Delete(&models.OutboxMessage{})
+		db.Unscoped().Delete(user)
+	})
+
+	t.Run("Test minor without guardian is rejected", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := UserService{db: db, minimumAge: 13, consentAge: 18}
+		userData := validators.UserCreateData{
+			Email:         "test@test.com",
+			Password:      "testestestestest",
+			Name:          "test",
+			Surname:       "test",
+			Birthday:      bindings.BirthDate(time.Now().AddDate(-15, 0, 0)),
+			GuardianEmail: "test@test.com",
+		}
+
+		_, err := service.Create(userData)
+
+		assert.IsType(GuardianConsentRequiredError{}, err)
+	})
+
+	t.Run("Test minor waits for the guardian consent", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := UserService{db: db, minimumAge: 13, consentAge: 18}
+		userData := validators.UserCreateData{
+			Email:         "test@test.com",
+			Password:      "testestestestest",
+			Name:          "test",
+			Surname:       "test",
+			Birthday:      bindings.BirthDate(time.Now().AddDate(-15, 0, 0)),
+			GuardianEmail: "guardian@test.com",
+		}
+
+		user, err := service.Create(userData)
+		assert.NoError(err)
+		assert.True(user.AwaitsGuardianConsent())
+
+		var message models.OutboxMessage
+		assert.NoError(db.Where("kind = ?", models.OutboxKindGuardianConsent).First(&message).Error)
+		assert.Equal("guardian@test.com", message.Recipient)
+
+		code, err := issueOneTimeToken(db, *user, models.TokenPurposeGuardian, 10, true)
+		assert.NoError(err)
+		consented, err := service.ConsentAsGuardian(code, helpers.ClientInfo{})
+		assert.NoError(err)
+		assert.NotNil(consented.GuardianConsentAt)
+		assert.False(consented.AwaitsGuardianConsent())
+
+		var verification models.OutboxMessage
+		assert.NoError(db.Where("kind = ?", models.OutboxKindUserVerifyEmail).First(&verification).Error)
+		assert.Equal(user.Email, verification.Recipient)
+
+		_, err = service.ConsentAsGuardian(code, helpers.ClientInfo{})
+		assert.IsType(OneTimeTokenNotValidError{}, err)
+
+		db.Unscoped().Where("1 = 1").Delete(&models.OutboxMessage{})
+		db.Unscoped().Delete(user)
+	})
+}
+
 func TestUserServiceRead(t *testing.T) {
 	assert := require.New(t)
 
 	t.Run("Test read user successfully", func(t *testing.T) {
 		db := tests.NewTestDatabase(false)
-		service := UserService{db}
+		service := UserService{db: db}
 
 		user := tests.UserFactory()
 		db.Create(&user)
@@ -85,7 +189,7 @@ func TestUserServiceRead(t *testing.T) {
 
 	t.Run("Test read user not found error", func(t *testing.T) {
 		db := tests.NewTestDatabase(false)
-		service := UserService{db}
+		service := UserService{db: db}
 		uuid, _ := uuid.NewV4()
 
 		_, err := service.Read(uuid)
@@ -99,7 +203,7 @@ func TestUserServiceReadByEmail(t *testing.T) {
 
 	t.Run("Test read user by email successfully", func(t *testing.T) {
 		db := tests.NewTestDatabase(false)
-		service := UserService{db}
+		service := UserService{db: db}
 
 		user := tests.UserFactory()
 		db.Create(&user)
@@ -114,7 +218,7 @@ func TestUserServiceReadByEmail(t *testing.T) {
 
 	t.Run("Test read user by email not found error", func(t *testing.T) {
 		db := tests.NewTestDatabase(false)
-		service := UserService{db}
+		service := UserService{db: db}
 		user := tests.UserFactory()
 		_, err := service.ReadByEmail(user.Email)
 
@@ -128,7 +232,7 @@ func TestUserServiceUpdate(t *testing.T) {
 
 	t.Run("Test update user successfully", func(t *testing.T) {
 		db := tests.NewTestDatabase(false)
-		service := UserService{db}
+		service := UserService{db: db}
 
 		user := tests.UserFactory()
 		db.Create(&user)
@@ -151,7 +255,7 @@ func TestUserServiceUpdate(t *testing.T) {
 
 	t.Run("Test update user not found error", func(t *testing.T) {
 		db := tests.NewTestDatabase(false)
-		service := UserService{db}
+		service := UserService{db: db}
 
 		uuid, _ := uuid.NewV4()
 		userData := validators.UserUpdateData{
@@ -171,25 +275,119 @@ func TestUserServiceDelete(t *testing.T) {
 
 	t.Run("Test delete user successfully", func(t *testing.T) {
 		db := tests.NewTestDatabase(false)
-		service := UserService{db}
+		service := UserService{db: db}
 
 		user := tests.UserFactory()
 		db.Create(&user)
 
-		err := service.Delete(user.UUID)
+		err := service.Delete(user.UUID, AuditContext{}, nil)
 		assert.NoError(err)
 	})
 
+	t.Run("Test delete user enqueues the webhook deliveries", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := UserService{db: db}
+		user := tests.UserFactory()
+		db.Create(&user)
+		app := tests.AppFactory()
+		db.Create(&app)
+		db.Model(&user).Association("ConnectedApps").Append(&app)
+		webhook := models.NewWebhook(app, "https://yourapp.dev/webhooks", []string{models.WebhookEventUserDeleted})
+		db.Create(&webhook)
+
+		assert.NoError(service.Delete(user.UUID, AuditContext{}, nil))
+
+		var deliveries []models.WebhookDelivery
+		db.Where(&models.WebhookDelivery{WebhookID: webhook.ID}).Find(&deliveries)
+		assert.Equal(1, len(deliveries))
+		assert.Equal(models.WebhookEventUserDeleted, deliveries[0].Event)
+		assert.Equal(models.WebhookDeliveryPending, deliveries[0].Status)
+	})
+
 	t.Run("Test delete user error not found", func(t *testing.T) {
 		db := tests.NewTestDatabase(false)
-		service := UserService{db}
+		service := UserService{db: db}
 
 		user := tests.UserFactory()
 
-		err := service.Delete(user.UUID)
+		err := service.Delete(user.UUID, AuditContext{}, nil)
 		assert.Error(err, UserNotFoundError{nil}.Error())
 	})
 
+	t.Run("Test deleted user is restored by logging in", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := UserService{db: db, deletionGrace: time.Hour}
+		authService := NewAuthService(db)
+		authService.backends = []ICredentialBackend{DatabaseCredentialBackend{db: db, deletionGrace: time.Hour}}
+		user := tests.UserFactory()
+		user.SetPassword("testestestestest")
+		user.Verified = true
+		db.Create(&user)
+		session := models.NewSession(user, nil, "", "")
+		db.Create(&session)
+
+		assert.NoError(service.Delete(user.UUID, AuditContext{}, nil))
+		_, err := service.Read(user.UUID)
+		assert.IsType(UserNotFoundError{}, err)
+		db.First(&session, session.ID)
+		assert.NotNil(session.RevokedAt)
+
+		credentials := validators.Credentials{Email: user.Email, Password: "testestestestest"}
+		_, err = authService.Authenticate(credentials, false, helpers.ClientInfo{})
+		assert.NoError(err)
+		_, err = service.Read(user.UUID)
+		assert.NoError(err)
+		db.Unscoped().Delete(&user)
+	})
+
+	t.Run("Test deleted user is purged after the grace period", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := UserService{db: db, deletionGrace: time.Hour}
+		user := tests.UserFactory()
+		user.Verified = true
+		user.Attributes = models.UserAttributes{"employee_id": "E-1"}
+		db.Create(&user)
+		app := tests.AppFactory()
+		app.UserID = user.ID
+		db.Create(&app)
+		claim := models.NewClaim("https://example.com", "code", []string{}, user, app)
+		db.Create(&claim)
+		pending := models.NewOutboxMessage(models.OutboxKindUserVerifyEmail, user.Email, `{"email":"`+user.Email+`"}`)
+		dead := models.NewOutboxMessage(models.OutboxKindGuardianConsent, "guardian@example.com", `{"text":"`+user.Email+`"}`)
+		dead.Status = models.OutboxMessageDead
+		db.Create(&pending)
+		db.Create(&dead)
+		webhook := models.NewWebhook(app, "https://example.com/hook", []string{models.WebhookEventUserDeleted})
+		db.Create(&webhook)
+		delivery := models.NewWebhookDelivery(webhook, models.WebhookEventUserDeleted, newWebhookPayload(models.WebhookEventUserDeleted, user, nil))
+		db.Create(&delivery)
+		db.Delete(&user)
+		db.Unscoped().Model(&user).Update("deleted_at", time.Now().Add(-2*time.Hour))
+
+		service.PurgeDeleted()
+
+		var purged models.User
+		db.Unscoped().First(&purged, user.ID)
+		assert.NotNil(purged.PurgedAt)
+		assert.Empty(purged.Email)
+		assert.Empty(purged.Attributes)
+		var claims int64
+		db.Model(&models.Claim{}).Where("user_id = ?", user.ID).Count(&claims)
+		assert.Zero(claims)
+		var messages, deliveries int64
+		db.Unscoped().Model(&models.OutboxMessage{}).Where("id IN ?", []uint{pending.ID, dead.ID}).Count(&messages)
+		db.Unscoped().Model(&models.WebhookDelivery{}).Where("strpos(payload::text, ?) > 0", user.Email).Count(&deliveries)
+		assert.Zero(messages)
+		assert.Zero(deliveries)
+
+		again := tests.UserFactory()
+		again.Email = user.Email
+		assert.NoError(db.Create(&again).Error)
+		db.Unscoped().Delete(&again)
+		db.Unscoped().Delete(&webhook)
+		db.Unscoped().Delete(&app)
+		db.Unscoped().Delete(&purged)
+	})
 }
 
 func TestUserServiceVerificate(t *testing.T) {
@@ -197,10 +395,10 @@ func TestUserServiceVerificate(t *testing.T) {
 
 	t.Run("Test verify user successfully", func(t *testing.T) {
 		db := tests.NewTestDatabase(true)
-		service := UserService{db}
+		service := UserService{db: db}
 		user := tests.UserFactory()
 
-		service.Verificate(&user)
+		service.Verificate(&user, nil)
 
 		assert.True(user.Verified)
 	})
@@ -213,12 +411,81 @@ func TestUserServiceResetPassword(t *testing.T) {
 	t.Run("Test verify user successfully", func(t *testing.T) {
 		db := tests.NewTestDatabase(true)
 		newPassword := "wowowowowowoow"
-		service := UserService{db}
+		service := UserService{db: db}
 		user := tests.UserFactory()
 
-		service.ResetPassword(&user, newPassword)
+		service.ResetPassword(&user, newPassword, helpers.ClientInfo{})
 
 		assert.True(user.VerifyPassword(newPassword))
 	})
 
 }
+
+func TestUserServiceList(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test list users by search term", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := UserService{db: db}
+
+		user := tests.UserFactory()
+		user.Email = "gandalf.list.test@test.com"
+		other := tests.UserFactory()
+		db.Create(&user)
+		db.Create(&other)
+
+		cursor := helpers.NewCursor(0, 10)
+		users := service.List("gandalf.list.test", &cursor)
+
+		assert.Equal(1, len(users))
+		assert.Equal(user.ID, users[0].ID)
+		assert.Equal(1, cursor.Total)
+
+		db.Unscoped().Delete(&user)
+		db.Unscoped().Delete(&other)
+	})
+}
+
+func TestUserServiceSetDisabled(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test disable and enable user", func(t *testing.T) {
+		db := tests.NewTestDatabase(true)
+		service := UserService{db: db}
+		user := tests.UserFactory()
+
+		service.SetDisabled(&user, true, nil)
+		assert.True(user.Disabled)
+
+		service.SetDisabled(&user, false, nil)
+		assert.False(user.Disabled)
+	})
+
+	t.Run("Test disable user records the admin action with the change", func(t *testing.T) {
+		db := tests.NewTestDatabase(false)
+		service := UserService{db: db}
+		staff := tests.UserFactory()
+		staff.Staff = true
+		user := tests.UserFactory()
+		db.Create(&staff)
+		db.Create(&user)
+
+		err := service.SetDisabled(&user, true, &AdminActionContext{Action: models.AdminActionDisableUser, Staff: staff, IP: "127.0.0.1"})
+		assert.NoError(err)
+		var action models.AdminAction
+		assert.NoError(db.Where(&models.AdminAction{StaffID: staff.ID}).First(&action).Error)
+		assert.Equal(models.AdminActionDisableUser, action.Action)
+		assert.Equal(user.ID, *action.TargetID)
+
+		// Text cannot hold NUL bytes, so the action is not recorded
+		err = service.SetDisabled(&user, false, &AdminActionContext{Action: models.AdminActionEnableUser, Staff: staff, IP: "\x00"})
+		assert.IsType(AdminActionRecordError{}, err)
+		var stored models.User
+		db.First(&stored, user.ID)
+		assert.True(stored.Disabled)
+
+		db.Unscoped().Where("staff_id = ?", staff.ID).Delete(&models.AdminAction{})
+		db.Unscoped().Delete(&staff)
+		db.Unscoped().Delete(&user)
+	})
+}
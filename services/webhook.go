@@ -0,0 +1,376 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/security"
+	"gandalf/validators"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Maximum number of pending deliveries attempted on every retry round
+const webhookRetryBatch = 100
+
+// Claimed deliveries are attempted again after the lease when the instance
+// which claimed them stopped before storing the result of the attempt
+const webhookClaimLease = time.Minute
+
+// Body of the requests sent to the webhooks
+type webhookPayload struct {
+	ID        uuid.UUID          `json:"id"`
+	Event     string             `json:"event"`
+	CreatedAt time.Time          `json:"created_at"`
+	Data      webhookPayloadData `json:"data"`
+}
+
+type webhookPayloadData struct {
+	User webhookPayloadUser `json:"user"`
+	App  *webhookPayloadApp `json:"app,omitempty"`
+}
+
+type webhookPayloadUser struct {
+	UUID     uuid.UUID `json:"uuid"`
+	Email    string    `json:"email"`
+	Verified bool      `json:"verified"`
+}
+
+type webhookPayloadApp struct {
+	UUID     uuid.UUID `json:"uuid"`
+	ClientID uuid.UUID `json:"client_id"`
+}
+
+// Creates the payload of the given event about the given user. The app
+// is optional.
+func newWebhookPayload(event string, user models.User, app *models.App) string {
+	payload := webhookPayload{
+		ID:        uuid.Must(uuid.NewV4()),
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data: webhookPayloadData{
+			User: webhookPayloadUser{UUID: user.UUID, Email: user.Email, Verified: user.Verified},
+		},
+	}
+	if app != nil {
+		payload.Data.App = &webhookPayloadApp{UUID: app.UUID, ClientID: app.ClientID}
+	}
+	serialized, _ := json.Marshal(payload)
+	return string(serialized)
+}
+
+// Interface for webhook service
+type IWebhookService interface {
+	Create(app models.App, data validators.WebhookCreateData) (*models.Webhook, error)
+	Read(app models.App, uuid uuid.UUID) (*models.Webhook, error)
+	List(app models.App, cursor *helpers.Cursor) []models.Webhook
+	Update(webhook *models.Webhook, data validators.WebhookUpdateData) error
+	Delete(webhook models.Webhook) error
+	ListDeliveries(webhook models.Webhook, cursor *helpers.Cursor) []models.WebhookDelivery
+	ReadDelivery(webhook models.Webhook, uuid uuid.UUID) (*models.WebhookDelivery, error)
+	Replay(delivery models.WebhookDelivery) (*models.WebhookDelivery, error)
+	Dispatch(event string, user models.User, app *models.App)
+	DeliverPending()
+}
+
+// Webhook service manages the webhooks of the apps and delivers the
+// events they are subscribed to
+type WebhookService struct {
+	db          *gorm.DB
+	maxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS"`
+	backoff     time.Duration `env:"WEBHOOK_BACKOFF"`
+
+	do func(request *http.Request) (*http.Response, error)
+}
+
+// Returns an http client for the urls given by the apps, which cannot reach
+// the hosts of the internal network
+func newPublicHTTPClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: security.PublicAddressControl}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, DialContext: dialer.DialContext},
+	}
+}
+
+// Creates a new webhook service. The webhooks cannot reach the hosts of
+// the internal network.
+func NewWebhookService(db *gorm.DB) WebhookService {
+	client := newPublicHTTPClient()
+	return WebhookService{
+		db:          db,
+		maxAttempts: helpers.GetEnvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		backoff:     time.Duration(helpers.GetEnvInt("WEBHOOK_BACKOFF", 30)) * time.Second,
+		do:          client.Do,
+	}
+}
+
+// Check if the given webhook url is an http url which does not point to
+// the internal network. Hosts are checked again when they are resolved on
+// delivery.
+func validateWebhookUrl(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return WebhookUrlError{}
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return WebhookUrlError{}
+	}
+	if ip := net.ParseIP(host); ip != nil && !security.IsPublicIP(ip) {
+		return WebhookUrlError{}
+	}
+	return nil
+}
+
+// Registers a new webhook for the given app
+func (service WebhookService) Create(app models.App, data validators.WebhookCreateData) (*models.Webhook, error) {
+	if err := validateWebhookUrl(data.Url); err != nil {
+		return nil, err
+	}
+	webhook := models.NewWebhook(app, data.Url, data.Events)
+	if err := service.db.Create(&webhook).Error; err != nil {
+		return nil, WebhookCreateError{err}
+	}
+	return &webhook, nil
+}
+
+// Read a webhook of the given app by his UUID
+func (service WebhookService) Read(app models.App, uuid uuid.UUID) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := service.db.Where(&models.Webhook{UUID: uuid, AppID: app.ID}).First(&webhook).Error; err != nil {
+		return nil, WebhookNotFoundError{err}
+	}
+	return &webhook, nil
+}
+
+// List the webhooks of the given app
+func (service WebhookService) List(app models.App, cursor *helpers.Cursor) []models.Webhook {
+	var webhooks []models.Webhook
+	var count int64
+
+	query := &models.Webhook{AppID: app.ID}
+	service.db.Model(&models.Webhook{}).Where(query).Count(&count)
+	service.db.Scopes(helpers.DBPaginate(cursor.Page, cursor.PageSize)).Where(query).Order("id").Find(&webhooks)
+	cursor.Update(int(count))
+	return webhooks
+}
+
+// Updates the given webhook according to the given data
+func (service WebhookService) Update(webhook *models.Webhook, data validators.WebhookUpdateData) error {
+	if data.Url != "" {
+		if err := validateWebhookUrl(data.Url); err != nil {
+			return err
+		}
+		webhook.Url = data.Url
+	}
+
+	if len(data.Events) != 0 {
+		webhook.Events = data.Events
+	}
+
+	if data.Active != nil {
+		webhook.Active = *data.Active
+	}
+
+	if err := service.db.Save(webhook).Error; err != nil {
+		return WebhookNotFoundError{err}
+	}
+	return nil
+}
+
+// Deletes the given webhook. Its pending deliveries will not be attempted.
+func (service WebhookService) Delete(webhook models.Webhook) error {
+	if err := service.db.Delete(&webhook).Error; err != nil {
+		return WebhookNotFoundError{err}
+	}
+	return nil
+}
+
+// List the deliveries of the given webhook, the most recent first
+func (service WebhookService) ListDeliveries(webhook models.Webhook, cursor *helpers.Cursor) []models.WebhookDelivery {
+	var deliveries []models.WebhookDelivery
+	var count int64
+
+	query := &models.WebhookDelivery{WebhookID: webhook.ID}
+	service.db.Model(&models.WebhookDelivery{}).Where(query).Count(&count)
+	service.db.Scopes(helpers.DBPaginate(cursor.Page, cursor.PageSize)).Where(query).Order("id DESC").Find(&deliveries)
+	cursor.Update(int(count))
+	return deliveries
+}
+
+// Read a delivery of the given webhook by his UUID
+func (service WebhookService) ReadDelivery(webhook models.Webhook, uuid uuid.UUID) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	query := &models.WebhookDelivery{UUID: uuid, WebhookID: webhook.ID}
+	if err := service.db.Preload("Webhook").Where(query).First(&delivery).Error; err != nil {
+		return nil, WebhookDeliveryNotFoundError{err}
+	}
+	return &delivery, nil
+}
+
+// Sends again the payload of the given delivery as a new delivery, and
+// returns it once it has been attempted
+func (service WebhookService) Replay(delivery models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	replay := models.NewWebhookDelivery(delivery.Webhook, delivery.Event, delivery.Payload)
+	if err := service.db.Create(&replay).Error; err != nil {
+		return nil, WebhookCreateError{err}
+	}
+	for _, claimed := range service.claim(replay.ID) {
+		service.deliver(&claimed, claimed.Webhook)
+		replay = claimed
+	}
+	return &replay, nil
+}
+
+// Creates in the given transaction the pending deliveries of the given
+// event about the given user to the active webhooks subscribed to it. App
+// events are only sent to the given app, and user events only to the apps
+// the user is connected to, whoever owns them.
+func enqueueWebhookEvent(tx *gorm.DB, event string, user models.User, app *models.App) ([]models.WebhookDelivery, error) {
+	query := tx.Where("active = ? AND ? = ANY(events)", true, event)
+	if app != nil {
+		query = query.Where("app_id = ?", app.ID)
+	} else {
+		connected := tx.Table("user_has_signin_on_app").Select("app_id").Where("user_id = ?", user.ID)
+		targets := tx.Table("apps").Select("id").
+			Where("deleted_at IS NULL AND id IN (?)", connected)
+		query = query.Where("app_id IN (?)", targets)
+	}
+
+	var webhooks []models.Webhook
+	if err := query.Find(&webhooks).Error; err != nil {
+		return nil, WebhookCreateError{err}
+	}
+
+	payload := newWebhookPayload(event, user, app)
+	deliveries := make([]models.WebhookDelivery, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		delivery := models.NewWebhookDelivery(webhook, event, payload)
+		if err := tx.Create(&delivery).Error; err != nil {
+			return nil, WebhookCreateError{err}
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// Sends the given event about the given user to the active webhooks
+// subscribed to it, see enqueueWebhookEvent. The events raised by the
+// services are enqueued along with their changes and sent on the next
+// retry round instead.
+func (service WebhookService) Dispatch(event string, user models.User, app *models.App) {
+	deliveries, err := enqueueWebhookEvent(service.db, event, user, app)
+	if err != nil {
+		log.Println(fmt.Sprintf("Webhook deliveries cannot be created for event %s: %s", event, err))
+		return
+	}
+
+	ids := make([]uint, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.ID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	claimed := service.claim(ids...)
+	for i := range claimed {
+		service.deliver(&claimed[i], claimed[i].Webhook)
+	}
+}
+
+// Attempts the pending deliveries whose retry is due
+func (service WebhookService) DeliverPending() {
+	deliveries := service.claim()
+	for i := range deliveries {
+		service.deliver(&deliveries[i], deliveries[i].Webhook)
+	}
+}
+
+// Claims the due pending deliveries of the active webhooks, restricted to
+// the given ones if any, so no other instance attempts them at the same
+// time. The rows locked by other instances are skipped, and the claimed
+// deliveries are postponed by the claim lease until their attempt is
+// stored.
+func (service WebhookService) claim(ids ...uint) []models.WebhookDelivery {
+	var deliveries []models.WebhookDelivery
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Joins("Webhook").
+			Where("webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ?", models.WebhookDeliveryPending, time.Now()).
+			Where(`"Webhook"."deleted_at" IS NULL AND "Webhook"."active"`)
+		if len(ids) != 0 {
+			query = query.Where("webhook_deliveries.id IN ?", ids)
+		}
+		err := query.Clauses(clause.Locking{
+			Strength: "UPDATE",
+			Table:    clause.Table{Name: "webhook_deliveries"},
+			Options:  "SKIP LOCKED",
+		}).Order("webhook_deliveries.next_attempt_at").Limit(webhookRetryBatch).Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		claimed := make([]uint, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			claimed = append(claimed, delivery.ID)
+		}
+		lease := time.Now().Add(webhookClaimLease)
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", claimed).Update("next_attempt_at", lease).Error
+	})
+	if err != nil {
+		log.Println("Webhook deliveries cannot be claimed:", err)
+		return nil
+	}
+	return deliveries
+}
+
+// Sends the given delivery to the given webhook and stores the result of
+// the attempt. Failed attempts are retried with exponential backoff until
+// the delivery runs out of attempts.
+func (service WebhookService) deliver(delivery *models.WebhookDelivery, webhook models.Webhook) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := security.HmacSha256Signature(webhook.Secret, []byte(timestamp+"."+delivery.Payload))
+
+	request, _ := http.NewRequest(http.MethodPost, webhook.Url, strings.NewReader(delivery.Payload))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Gandalf-Event", delivery.Event)
+	request.Header.Set("X-Gandalf-Delivery", delivery.UUID.String())
+	request.Header.Set("X-Gandalf-Signature", fmt.Sprintf("t=%s,v1=%s", timestamp, signature))
+
+	delivery.Attempts++
+	response, err := service.do(request)
+	if err == nil {
+		response.Body.Close()
+		delivery.LastStatus = response.StatusCode
+		if response.StatusCode >= 200 && response.StatusCode < 300 {
+			now := time.Now()
+			delivery.Status = models.WebhookDeliveryDelivered
+			delivery.DeliveredAt = &now
+			delivery.NextAttemptAt = nil
+			delivery.LastError = ""
+			service.db.Save(delivery)
+			return
+		}
+		err = fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= service.maxAttempts {
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+	} else {
+		next := time.Now().Add(service.backoff * time.Duration(1<<uint(delivery.Attempts-1)))
+		delivery.NextAttemptAt = &next
+	}
+	service.db.Save(delivery)
+}
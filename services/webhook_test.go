@@ -0,0 +1,282 @@
+package services
+
+import (
+	"errors"
+	"gandalf/helpers"
+	"gandalf/models"
+	"gandalf/tests"
+	"gandalf/validators"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"syreclabs.com/go/faker"
+)
+
+type mockWebhookClient struct {
+	requests   []*http.Request
+	statusCode int
+	err        error
+}
+
+func (mock *mockWebhookClient) do(request *http.Request) (*http.Response, error) {
+	mock.requests = append(mock.requests, request)
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	return &http.Response{
+		StatusCode: mock.statusCode,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}, nil
+}
+
+func newTestWebhookService(client *mockWebhookClient) WebhookService {
+	service := NewWebhookService(tests.NewTestDatabase(false))
+	service.do = client.do
+	return service
+}
+
+func TestWebhookServiceConstructor(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test constructor", func(t *testing.T) {
+		db := tests.NewTestDatabase(true)
+		service := NewWebhookService(db)
+
+		assert.Equal(service.db, db)
+		assert.Equal(5, service.maxAttempts)
+		assert.Equal(30*time.Second, service.backoff)
+	})
+}
+
+func TestWebhookServiceCreate(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test create webhook", func(t *testing.T) {
+		service := newTestWebhookService(&mockWebhookClient{statusCode: http.StatusOK})
+		app := tests.AppFactory()
+		service.db.Create(&app)
+
+		data := validators.WebhookCreateData{
+			Url:    faker.Internet().Url(),
+			Events: []string{models.WebhookEventUserDeleted},
+		}
+		webhook, err := service.Create(app, data)
+
+		assert.NoError(err)
+		assert.Equal(app.ID, webhook.AppID)
+		assert.NotEmpty(webhook.Secret)
+		assert.True(webhook.Active)
+	})
+}
+
+func TestValidateWebhookUrl(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test public urls", func(t *testing.T) {
+		assert.NoError(validateWebhookUrl("https://yourapp.dev/webhooks"))
+		assert.NoError(validateWebhookUrl("http://93.184.216.34/webhooks"))
+	})
+
+	t.Run("Test internal urls", func(t *testing.T) {
+		for _, url := range []string{
+			"ftp://yourapp.dev/webhooks", "https://localhost/webhooks", "http://api.localhost:8080",
+			"http://127.0.0.1:9200", "http://169.254.169.254/latest/meta-data", "http://[::1]/webhooks",
+			"http://10.0.0.7/webhooks", "https:///webhooks",
+		} {
+			assert.Equal(WebhookUrlError{}, validateWebhookUrl(url), url)
+		}
+	})
+}
+
+func TestWebhookServiceUpdate(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test update webhook", func(t *testing.T) {
+		service := newTestWebhookService(&mockWebhookClient{statusCode: http.StatusOK})
+		app := tests.AppFactory()
+		service.db.Create(&app)
+		webhook := models.NewWebhook(app, faker.Internet().Url(), models.WebhookEvents)
+		service.db.Create(&webhook)
+
+		active := false
+		url := faker.Internet().Url()
+		err := service.Update(&webhook, validators.WebhookUpdateData{Url: url, Active: &active})
+		readWebhook, _ := service.Read(app, webhook.UUID)
+
+		assert.NoError(err)
+		assert.Equal(url, readWebhook.Url)
+		assert.False(readWebhook.Active)
+		assert.Equal(len(models.WebhookEvents), len(readWebhook.Events))
+	})
+}
+
+func TestWebhookServiceDispatch(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test dispatch user event to connected apps only", func(t *testing.T) {
+		client := &mockWebhookClient{statusCode: http.StatusOK}
+		service := newTestWebhookService(client)
+		user := tests.UserFactory()
+		service.db.Create(&user)
+		connected := tests.AppFactory()
+		service.db.Create(&connected)
+		other := tests.AppFactory()
+		service.db.Create(&other)
+		service.db.Model(&user).Association("ConnectedApps").Append(&connected)
+
+		webhook := models.NewWebhook(connected, faker.Internet().Url(), []string{models.WebhookEventUserDeleted})
+		service.db.Create(&webhook)
+		otherWebhook := models.NewWebhook(other, faker.Internet().Url(), []string{models.WebhookEventUserDeleted})
+		service.db.Create(&otherWebhook)
+
+		service.Dispatch(models.WebhookEventUserDeleted, user, nil)
+
+		assert.Equal(1, len(client.requests))
+		assert.Equal(webhook.Url, client.requests[0].URL.String())
+		assert.Equal(models.WebhookEventUserDeleted, client.requests[0].Header.Get("X-Gandalf-Event"))
+		assert.Contains(client.requests[0].Header.Get("X-Gandalf-Signature"), "v1=")
+
+		deliveries := service.ListDeliveries(webhook, &helpers.Cursor{Page: 1, PageSize: 10})
+		assert.Equal(1, len(deliveries))
+		assert.Equal(models.WebhookDeliveryDelivered, deliveries[0].Status)
+	})
+
+	t.Run("Test dispatch user event skips staff apps not connected", func(t *testing.T) {
+		client := &mockWebhookClient{statusCode: http.StatusOK}
+		service := newTestWebhookService(client)
+		user := tests.UserFactory()
+		service.db.Create(&user)
+		app := tests.AppFactory()
+		app.User.Staff = true
+		service.db.Create(&app)
+		webhook := models.NewWebhook(app, faker.Internet().Url(), []string{models.WebhookEventUserCreated, models.WebhookEventUserDeleted})
+		service.db.Create(&webhook)
+
+		service.Dispatch(models.WebhookEventUserCreated, user, nil)
+		service.Dispatch(models.WebhookEventUserDeleted, user, nil)
+
+		assert.Equal(0, len(client.requests))
+		assert.Empty(service.ListDeliveries(webhook, &helpers.Cursor{Page: 1, PageSize: 10}))
+	})
+
+	t.Run("Test dispatch ignores unsubscribed webhooks", func(t *testing.T) {
+		client := &mockWebhookClient{statusCode: http.StatusOK}
+		service := newTestWebhookService(client)
+		app := tests.AppFactory()
+		service.db.Create(&app)
+		webhook := models.NewWebhook(app, faker.Internet().Url(), []string{models.WebhookEventUserDeleted})
+		service.db.Create(&webhook)
+
+		service.Dispatch(models.WebhookEventAppConnected, app.User, &app)
+
+		assert.Equal(0, len(client.requests))
+	})
+
+	t.Run("Test dispatch failure schedules a retry", func(t *testing.T) {
+		client := &mockWebhookClient{err: errors.New("connection refused")}
+		service := newTestWebhookService(client)
+		app := tests.AppFactory()
+		service.db.Create(&app)
+		webhook := models.NewWebhook(app, faker.Internet().Url(), []string{models.WebhookEventAppConnected})
+		service.db.Create(&webhook)
+
+		service.Dispatch(models.WebhookEventAppConnected, app.User, &app)
+
+		deliveries := service.ListDeliveries(webhook, &helpers.Cursor{Page: 1, PageSize: 10})
+		assert.Equal(1, len(deliveries))
+		assert.Equal(models.WebhookDeliveryPending, deliveries[0].Status)
+		assert.Equal(1, deliveries[0].Attempts)
+		assert.Equal("connection refused", deliveries[0].LastError)
+		assert.True(deliveries[0].NextAttemptAt.After(time.Now()))
+	})
+}
+
+func TestWebhookServiceDeliver(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test delivery fails after max attempts", func(t *testing.T) {
+		client := &mockWebhookClient{statusCode: http.StatusInternalServerError}
+		service := newTestWebhookService(client)
+		service.maxAttempts = 2
+		app := tests.AppFactory()
+		service.db.Create(&app)
+		webhook := models.NewWebhook(app, faker.Internet().Url(), models.WebhookEvents)
+		service.db.Create(&webhook)
+		delivery := models.NewWebhookDelivery(webhook, models.WebhookEventUserDeleted, "{}")
+		service.db.Create(&delivery)
+
+		service.deliver(&delivery, webhook)
+		assert.Equal(models.WebhookDeliveryPending, delivery.Status)
+		assert.Equal(http.StatusInternalServerError, delivery.LastStatus)
+
+		service.deliver(&delivery, webhook)
+		assert.Equal(models.WebhookDeliveryFailed, delivery.Status)
+		assert.Nil(delivery.NextAttemptAt)
+	})
+
+	t.Run("Test deliver pending skips inactive webhooks", func(t *testing.T) {
+		client := &mockWebhookClient{statusCode: http.StatusOK}
+		service := newTestWebhookService(client)
+		app := tests.AppFactory()
+		service.db.Create(&app)
+		webhook := models.NewWebhook(app, faker.Internet().Url(), models.WebhookEvents)
+		service.db.Create(&webhook)
+		service.db.Model(&webhook).Update("active", false)
+		delivery := models.NewWebhookDelivery(webhook, models.WebhookEventUserDeleted, "{}")
+		service.db.Create(&delivery)
+
+		service.DeliverPending()
+
+		assert.Equal(0, len(client.requests))
+	})
+}
+
+func TestWebhookServiceClaim(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test claimed deliveries are not claimed again", func(t *testing.T) {
+		service := newTestWebhookService(&mockWebhookClient{statusCode: http.StatusOK})
+		app := tests.AppFactory()
+		service.db.Create(&app)
+		webhook := models.NewWebhook(app, faker.Internet().Url(), models.WebhookEvents)
+		service.db.Create(&webhook)
+		delivery := models.NewWebhookDelivery(webhook, models.WebhookEventUserDeleted, "{}")
+		service.db.Create(&delivery)
+
+		claimed := service.claim(delivery.ID)
+		assert.Equal(1, len(claimed))
+		assert.Equal(0, len(service.claim(delivery.ID)))
+		assert.Equal(0, len(service.claim()))
+
+		var stored models.WebhookDelivery
+		service.db.First(&stored, delivery.ID)
+		assert.True(stored.NextAttemptAt.After(time.Now()))
+	})
+}
+
+func TestWebhookServiceReplay(t *testing.T) {
+	assert := require.New(t)
+
+	t.Run("Test replay delivery", func(t *testing.T) {
+		client := &mockWebhookClient{statusCode: http.StatusOK}
+		service := newTestWebhookService(client)
+		app := tests.AppFactory()
+		service.db.Create(&app)
+		webhook := models.NewWebhook(app, faker.Internet().Url(), models.WebhookEvents)
+		service.db.Create(&webhook)
+		delivery := models.NewWebhookDelivery(webhook, models.WebhookEventUserDeleted, `{"event": "user.deleted"}`)
+		service.db.Create(&delivery)
+
+		readDelivery, _ := service.ReadDelivery(webhook, delivery.UUID)
+		replay, err := service.Replay(*readDelivery)
+
+		assert.NoError(err)
+		assert.NotEqual(delivery.UUID, replay.UUID)
+		assert.Equal(models.WebhookDeliveryDelivered, replay.Status)
+		assert.Equal(1, len(client.requests))
+	})
+}
@@ -4,6 +4,9 @@ import (
 	"gandalf/connections"
 	"gandalf/models"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 
 	"gorm.io/gorm"
 )
@@ -22,8 +25,43 @@ func NewTestDatabase(dryRun bool) *gorm.DB {
 	db := connection.Connect()
 	db.AutoMigrate(&models.User{})
 	db.AutoMigrate(&models.App{})
+	db.AutoMigrate(&models.Session{})
 	db.AutoMigrate(&models.Claim{})
+	db.AutoMigrate(&models.OneTimeToken{})
+	db.AutoMigrate(&models.AdminAction{})
+	db.AutoMigrate(&models.AuditEvent{})
+	db.AutoMigrate(&models.Permission{})
+	db.AutoMigrate(&models.Role{})
+	db.AutoMigrate(&models.Organization{})
+	db.AutoMigrate(&models.Membership{})
+	db.AutoMigrate(&models.Invitation{})
+	db.AutoMigrate(&models.Webhook{})
+	db.AutoMigrate(&models.WebhookDelivery{})
+	db.AutoMigrate(&models.OutboxMessage{})
+	db.AutoMigrate(&models.NotificationTemplate{})
+	db.AutoMigrate(&models.MagicLink{})
+	db.AutoMigrate(&models.PhoneCode{})
+	db.AutoMigrate(&models.IdentityProvider{})
+	db.AutoMigrate(&models.FederatedIdentity{})
+	db.AutoMigrate(&models.FederatedLogin{})
+	db.AutoMigrate(&models.SCIMToken{})
+	db.AutoMigrate(&models.DataExport{})
+	db.AutoMigrate(&models.AttributeDefinition{})
+	db.AutoMigrate(&models.LegalDocument{})
+	db.AutoMigrate(&models.LegalAcceptance{})
 	db.Set("gorm:auto_preload", true)
 
 	return db.Session(&gorm.Session{DryRun: dryRun})
 }
+
+// Applies the up statements of the given migration to the given test
+// database, for the guards which cannot be expressed by the models
+func ApplyMigration(db *gorm.DB, name string) error {
+	_, file, _, _ := runtime.Caller(0)
+	content, err := os.ReadFile(filepath.Join(filepath.Dir(file), "..", "migrations", name))
+	if err != nil {
+		return err
+	}
+	up := strings.Split(string(content), "-- +goose Down")[0]
+	return db.Exec(up).Error
+}
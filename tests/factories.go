@@ -37,3 +37,17 @@ func AppFactory() models.App {
 	app.User = user
 	return app
 }
+
+// Creates a role with fake data granting the given scopes. It won't be
+// saved into the db
+func RoleFactory(scopes ...string) models.Role {
+	var permissions []models.Permission
+	for _, scope := range scopes {
+		permissions = append(permissions, models.NewPermission(scope, faker.Lorem().Sentence(3)))
+	}
+	return models.NewRole(
+		faker.RandomString(16),
+		faker.Lorem().Sentence(3),
+		permissions,
+	)
+}
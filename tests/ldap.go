@@ -0,0 +1,339 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// LDAP operations the mock directory understands
+const (
+	ldapBindRequest      ber.Tag = 0
+	ldapBindResponse     ber.Tag = 1
+	ldapUnbindRequest    ber.Tag = 2
+	ldapSearchRequest    ber.Tag = 3
+	ldapSearchEntry      ber.Tag = 4
+	ldapSearchDone       ber.Tag = 5
+	ldapExtendedRequest  ber.Tag = 23
+	ldapExtendedResponse ber.Tag = 24
+)
+
+// Name of the extended operation which upgrades the connection to TLS
+const ldapStartTLSName = "1.3.6.1.4.1.1466.20037"
+
+// LDAP search filters the mock directory understands
+const (
+	ldapFilterAnd      ber.Tag = 0
+	ldapFilterOr       ber.Tag = 1
+	ldapFilterNot      ber.Tag = 2
+	ldapFilterEquality ber.Tag = 3
+	ldapFilterPresent  ber.Tag = 7
+)
+
+// LDAP result codes
+const (
+	ldapSuccess            = 0
+	ldapProtocolError      = 2
+	ldapSizeLimitExceeded  = 4
+	ldapInvalidCredentials = 49
+	ldapInsufficientAccess = 50
+)
+
+// Entry of the mock LDAP directory. Entries with a password can bind.
+type LDAPEntry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// Local LDAP directory for the tests. It only serves StartTLS, simple binds
+// and searches with equality, presence, and, or and not filters, which
+// require a bound connection. Its certificate is signed by a throwaway
+// authority, whose certificate is given by RootCAs.
+type LDAPServer struct {
+	listener    net.Listener
+	mutex       *sync.Mutex
+	entries     []LDAPEntry
+	certificate tls.Certificate
+	rootCAs     *x509.CertPool
+	plain       bool
+}
+
+// Starts a new mock LDAP directory with the given entries. It must be
+// closed after use.
+func NewLDAPServer(entries ...LDAPEntry) *LDAPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	certificate, rootCAs := newLocalCertificate()
+	server := &LDAPServer{
+		listener: listener, mutex: &sync.Mutex{}, entries: entries,
+		certificate: certificate, rootCAs: rootCAs,
+	}
+	go server.serve()
+	return server
+}
+
+// Creates a certificate for the loopback address signed by itself, along
+// with the pool which trusts it
+func newLocalCertificate() (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, rootCAs
+}
+
+// Returns the pool which trusts the certificate of the directory
+func (server *LDAPServer) RootCAs() *x509.CertPool {
+	return server.rootCAs
+}
+
+// Makes the directory refuse to upgrade the connections to TLS, like the
+// directories which only serve plain LDAP
+func (server *LDAPServer) RefuseStartTLS() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.plain = true
+}
+
+// Returns the url the directory listens on
+func (server *LDAPServer) URL() string {
+	return "ldap://" + server.listener.Addr().String()
+}
+
+// Adds the given entry to the directory
+func (server *LDAPServer) AddEntry(entry LDAPEntry) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.entries = append(server.entries, entry)
+}
+
+// Stops the directory
+func (server *LDAPServer) Close() {
+	server.listener.Close()
+}
+
+func (server *LDAPServer) serve() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.handle(conn)
+	}
+}
+
+// Serves the requests of a client until it unbinds or disconnects
+func (server *LDAPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	bound := false
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldapBindRequest:
+			bound = server.bind(request)
+			code := ldapSuccess
+			if !bound {
+				code = ldapInvalidCredentials
+			}
+			conn.Write(ldapResponse(messageID, ldapResult(ldapBindResponse, code)).Bytes())
+		case ldapSearchRequest:
+			if !bound {
+				conn.Write(ldapResponse(messageID, ldapResult(ldapSearchDone, ldapInsufficientAccess)).Bytes())
+				continue
+			}
+			server.search(conn, messageID, request)
+		case ldapExtendedRequest:
+			upgraded := server.startTLS(conn, messageID, request)
+			if upgraded == nil {
+				continue
+			}
+			conn = upgraded
+		case ldapUnbindRequest:
+			return
+		}
+	}
+}
+
+// Answers the StartTLS request and returns the connection upgraded to TLS.
+// Returns nil when the connection is not upgraded.
+func (server *LDAPServer) startTLS(conn net.Conn, messageID interface{}, request *ber.Packet) net.Conn {
+	server.mutex.Lock()
+	plain := server.plain
+	server.mutex.Unlock()
+
+	if plain || len(request.Children) < 1 || request.Children[0].Data.String() != ldapStartTLSName {
+		conn.Write(ldapResponse(messageID, ldapResult(ldapExtendedResponse, ldapProtocolError)).Bytes())
+		return nil
+	}
+	conn.Write(ldapResponse(messageID, ldapResult(ldapExtendedResponse, ldapSuccess)).Bytes())
+
+	return tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{server.certificate}})
+}
+
+// Checks the simple bind request against the passwords of the entries
+func (server *LDAPServer) bind(request *ber.Packet) bool {
+	if len(request.Children) < 3 {
+		return false
+	}
+	dn := request.Children[1].Data.String()
+	password := request.Children[2].Data.String()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	for _, entry := range server.entries {
+		if entry.Password != "" && strings.EqualFold(entry.DN, dn) && entry.Password == password {
+			return true
+		}
+	}
+	return false
+}
+
+// Sends the entries under the base of the search request which match its
+// filter, followed by the end of the search
+func (server *LDAPServer) search(conn net.Conn, messageID interface{}, request *ber.Packet) {
+	if len(request.Children) < 8 {
+		conn.Write(ldapResponse(messageID, ldapResult(ldapSearchDone, ldapInsufficientAccess)).Bytes())
+		return
+	}
+	base := strings.ToLower(request.Children[0].Data.String())
+	sizeLimit, _ := request.Children[3].Value.(int64)
+	filter := request.Children[6]
+
+	server.mutex.Lock()
+	matches := []LDAPEntry{}
+	for _, entry := range server.entries {
+		if strings.HasSuffix(strings.ToLower(entry.DN), base) && ldapMatch(entry, filter) {
+			matches = append(matches, entry)
+		}
+	}
+	server.mutex.Unlock()
+
+	code := ldapSuccess
+	if sizeLimit > 0 && int64(len(matches)) > sizeLimit {
+		matches = matches[:sizeLimit]
+		code = ldapSizeLimitExceeded
+	}
+
+	for _, entry := range matches {
+		result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapSearchEntry, nil, "Search Result Entry")
+		result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "DN"))
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		for name, values := range entry.Attributes {
+			attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+			attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+			set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+			for _, value := range values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+			}
+			attribute.AppendChild(set)
+			attributes.AppendChild(attribute)
+		}
+		result.AppendChild(attributes)
+		conn.Write(ldapResponse(messageID, result).Bytes())
+	}
+	conn.Write(ldapResponse(messageID, ldapResult(ldapSearchDone, code)).Bytes())
+}
+
+// Returns whether the given entry matches the given search filter
+func ldapMatch(entry LDAPEntry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldapFilterAnd:
+		for _, child := range filter.Children {
+			if !ldapMatch(entry, child) {
+				return false
+			}
+		}
+		return true
+	case ldapFilterOr:
+		for _, child := range filter.Children {
+			if ldapMatch(entry, child) {
+				return true
+			}
+		}
+		return false
+	case ldapFilterNot:
+		return len(filter.Children) == 1 && !ldapMatch(entry, filter.Children[0])
+	case ldapFilterEquality:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		for _, value := range ldapAttribute(entry, filter.Children[0].Data.String()) {
+			if strings.EqualFold(value, filter.Children[1].Data.String()) {
+				return true
+			}
+		}
+		return false
+	case ldapFilterPresent:
+		return len(ldapAttribute(entry, filter.Data.String())) > 0
+	}
+	return false
+}
+
+// Returns the values of the given attribute, whose name is case insensitive
+func ldapAttribute(entry LDAPEntry, name string) []string {
+	for attribute, values := range entry.Attributes {
+		if strings.EqualFold(attribute, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// Wraps the given operation into a response to the given message
+func ldapResponse(messageID interface{}, operation *ber.Packet) *ber.Packet {
+	response := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	response.AppendChild(operation)
+	return response
+}
+
+// Builds a result operation with the given code
+func ldapResult(operation ber.Tag, code int) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, operation, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(code), "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return result
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"gandalf/security"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Identity the mock OIDC provider vouches for when an user consents
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// Authorization request the mock OIDC provider has granted
+type oidcGrant struct {
+	identity      OIDCIdentity
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+// Local OIDC provider for the tests. It publishes its configuration and
+// keys, and trades the codes it grants for RS256 id tokens, checking the
+// PKCE verifier.
+type OIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+	mutex  *sync.Mutex
+	grants map[string]oidcGrant
+}
+
+// Starts a new mock OIDC provider. It must be closed after use.
+func NewOIDCProvider() *OIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	provider := &OIDCProvider{key: key, kid: "test-key", mutex: &sync.Mutex{}, grants: map[string]oidcGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", provider.configuration)
+	mux.HandleFunc("/jwks", provider.jwks)
+	mux.HandleFunc("/token", provider.token)
+	provider.server = httptest.NewServer(mux)
+	return provider
+}
+
+// Returns the issuer of the provider
+func (provider *OIDCProvider) Issuer() string {
+	return provider.server.URL
+}
+
+// Returns the authorization endpoint of the provider
+func (provider *OIDCProvider) AuthorizationURL() string {
+	return provider.server.URL + "/authorize"
+}
+
+// Returns the token endpoint of the provider
+func (provider *OIDCProvider) TokenURL() string {
+	return provider.server.URL + "/token"
+}
+
+// Returns the endpoint where the provider publishes its keys
+func (provider *OIDCProvider) JWKSURL() string {
+	return provider.server.URL + "/jwks"
+}
+
+// Stops the provider
+func (provider *OIDCProvider) Close() {
+	provider.server.Close()
+}
+
+// Grants the given authorization request url on behalf of the given
+// identity, as the provider would do when the user consents, and returns
+// the code and the state the browser is sent back with
+func (provider *OIDCProvider) Authorize(authorizationURL string, identity OIDCIdentity) (string, string) {
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		panic(err)
+	}
+	query := parsed.Query()
+
+	code, _ := security.NewUniformSecret().GenerateSecret(32)
+	provider.mutex.Lock()
+	provider.grants[code] = oidcGrant{
+		identity:      identity,
+		clientID:      query.Get("client_id"),
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+	provider.mutex.Unlock()
+	return code, query.Get("state")
+}
+
+// Signs the given claims as the provider would sign an id token
+func (provider *OIDCProvider) SignIDToken(claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = provider.kid
+	signed, err := token.SignedString(provider.key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func (provider *OIDCProvider) configuration(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 provider.Issuer(),
+		"authorization_endpoint": provider.AuthorizationURL(),
+		"token_endpoint":         provider.TokenURL(),
+		"jwks_uri":               provider.JWKSURL(),
+	})
+}
+
+func (provider *OIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(security.JWKS{
+		Keys: []security.JWK{security.NewRSAJWK(provider.kid, &provider.key.PublicKey)},
+	})
+}
+
+func (provider *OIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+
+	provider.mutex.Lock()
+	code := r.PostForm.Get("code")
+	grant, found := provider.grants[code]
+	delete(provider.grants, code)
+	provider.mutex.Unlock()
+
+	if !found ||
+		grant.clientID != r.PostForm.Get("client_id") ||
+		grant.redirectURI != r.PostForm.Get("redirect_uri") ||
+		grant.codeChallenge != security.PKCEChallenge(r.PostForm.Get("code_verifier")) {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	idToken := provider.SignIDToken(jwt.MapClaims{
+		"iss":            provider.Issuer(),
+		"sub":            grant.identity.Subject,
+		"aud":            []string{grant.clientID},
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          grant.nonce,
+		"email":          grant.identity.Email,
+		"email_verified": grant.identity.EmailVerified,
+		"given_name":     grant.identity.GivenName,
+		"family_name":    grant.identity.FamilyName,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"access_token":"upstream","token_type":"Bearer","id_token":%q}`, idToken)
+}
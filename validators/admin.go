@@ -0,0 +1,24 @@
+package validators
+
+// Validator for list users through the admin api
+type AdminUserListQuery struct {
+	PaginationQuery
+	Search string `form:"q" binding:"omitempty,max=100" example:"john"`
+}
+
+// Validator for assign or revoke a role through the admin api
+type AdminUserRoleData struct {
+	UUID string `uri:"uuid" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Role string `uri:"role" binding:"required" example:"staff"`
+}
+
+// Validator for revoke a session of an user through the admin api
+type AdminUserSessionData struct {
+	UUID    string `uri:"uuid" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Session string `uri:"session" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
+
+// Validator for act as an user through the admin api
+type AdminImpersonationData struct {
+	Reason string `json:"reason" binding:"required,max=500" example:"Reproduce the issue reported in ticket 1234"`
+}
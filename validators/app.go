@@ -2,16 +2,43 @@ package validators
 
 // Validator struct for app creation
 type AppCreateData struct {
-	Name         string   `json:"name" binding:"required" example:"MySuperApp"`
-	IconUrl      string   `json:"icon_url" binding:"omitempty,url" example:"http://youriconurl.dev"`
-	RedirectUrls []string `json:"redirect_urls" binding:"omitempty" example:"http://yourredirecturl.dev"`
+	Name                   string   `json:"name" binding:"required" example:"MySuperApp"`
+	IconUrl                string   `json:"icon_url" binding:"omitempty,url" example:"http://youriconurl.dev"`
+	RedirectUrls           []string `json:"redirect_urls" binding:"omitempty" example:"http://yourredirecturl.dev"`
+	Organization           string   `json:"organization" binding:"omitempty,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	PostLogoutRedirectUrls []string `json:"post_logout_redirect_urls" binding:"omitempty,dive,url" example:"http://yourlogouturl.dev"`
+	BackchannelLogoutUrl   string   `json:"backchannel_logout_url" binding:"omitempty,url" example:"http://yourbackchannelurl.dev"`
+
+	// SAML apps must give their entity id, and their redirect urls are the
+	// assertion consumer service urls
+	Kind           string            `json:"kind" binding:"omitempty,oneof=oauth saml" example:"oauth"`
+	EntityID       string            `json:"entity_id" binding:"required_if=Kind saml" example:"https://yourapp.dev/saml/metadata"`
+	NameIDFormat   string            `json:"name_id_format" binding:"omitempty,oneof=email persistent" example:"email"`
+	SAMLAttributes map[string]string `json:"saml_attributes" binding:"omitempty,dive,keys,required,endkeys,oneof=uuid email name surname full_name phone locale roles"`
+
+	// Custom attributes of the users released in the tokens of the app
+	ClaimMapping []ClaimRuleData `json:"claim_mapping" binding:"omitempty,dive"`
+
+	MinimumAge int `json:"minimum_age" binding:"omitempty,min=0,max=99" example:"16"`
 }
 
 // Validator struct for app update
 type AppUpdateData struct {
-	Name         string   `json:"name" binding:"omitempty" example:"MySuperApp"`
-	IconUrl      string   `json:"icon_url" binding:"omitempty,url" example:"http://youriconurl.dev"`
-	RedirectUrls []string `json:"redirect_urls" binding:"omitempty" example:"http://yourredirecturl.dev"`
+	Name                   string   `json:"name" binding:"omitempty" example:"MySuperApp"`
+	IconUrl                string   `json:"icon_url" binding:"omitempty,url" example:"http://youriconurl.dev"`
+	RedirectUrls           []string `json:"redirect_urls" binding:"omitempty" example:"http://yourredirecturl.dev"`
+	Organization           string   `json:"organization" binding:"omitempty,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	PostLogoutRedirectUrls []string `json:"post_logout_redirect_urls" binding:"omitempty,dive,url" example:"http://yourlogouturl.dev"`
+	BackchannelLogoutUrl   string   `json:"backchannel_logout_url" binding:"omitempty,url" example:"http://yourbackchannelurl.dev"`
+	EntityID               string   `json:"entity_id" binding:"omitempty" example:"https://yourapp.dev/saml/metadata"`
+	NameIDFormat           string   `json:"name_id_format" binding:"omitempty,oneof=email persistent" example:"email"`
+
+	SAMLAttributes map[string]string `json:"saml_attributes" binding:"omitempty,dive,keys,required,endkeys,oneof=uuid email name surname full_name phone locale roles"`
+
+	// Custom attributes of the users released in the tokens of the app
+	ClaimMapping []ClaimRuleData `json:"claim_mapping" binding:"omitempty,dive"`
+
+	MinimumAge *int `json:"minimum_age" binding:"omitempty,min=0,max=99" example:"16"`
 }
 
 // Validator for retrieve app by his uuid
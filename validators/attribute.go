@@ -0,0 +1,41 @@
+package validators
+
+// Validator for retrieve an attribute definition by its name
+type AttributeDefinitionReadData struct {
+	Name string `uri:"name" binding:"required" example:"employee_id"`
+}
+
+// Validator for create an attribute definition. The pattern and the
+// options only apply to string attributes.
+type AttributeDefinitionCreateData struct {
+	Name         string   `json:"name" binding:"required,max=64" example:"employee_id"`
+	Type         string   `json:"type" binding:"required,oneof=string integer boolean date" example:"string"`
+	UserEditable bool     `json:"user_editable" example:"false"`
+	Releasable   bool     `json:"releasable" example:"false"`
+	Description  string   `json:"description" binding:"omitempty,max=256" example:"Id of the employee in the HR system"`
+	Pattern      string   `json:"pattern" binding:"omitempty,max=256" example:"E-[0-9]+"`
+	Options      []string `json:"options" binding:"omitempty,dive,required" example:"free,pro"`
+}
+
+// Validator for update an attribute definition. Its name and type cannot
+// be changed, since the stored values depend on them.
+type AttributeDefinitionUpdateData struct {
+	UserEditable *bool    `json:"user_editable" binding:"omitempty" example:"true"`
+	Releasable   *bool    `json:"releasable" binding:"omitempty" example:"true"`
+	Description  string   `json:"description" binding:"omitempty,max=256" example:"Id of the employee in the HR system"`
+	Pattern      *string  `json:"pattern" binding:"omitempty,max=256" example:"E-[0-9]+"`
+	Options      []string `json:"options" binding:"omitempty,dive,required" example:"free,pro"`
+}
+
+// Validator for set the custom attributes of an user. Attributes set to
+// null are removed.
+type UserAttributesData struct {
+	Attributes map[string]interface{} `json:"attributes" binding:"required"`
+}
+
+// Validator for a claim mapping rule of an app
+type ClaimRuleData struct {
+	Claim     string   `json:"claim" binding:"required,max=64" example:"employee"`
+	Attribute string   `json:"attribute" binding:"required" example:"employee_id"`
+	Tokens    []string `json:"tokens" binding:"required,min=1,dive,oneof=access id" example:"access,id"`
+}
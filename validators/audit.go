@@ -0,0 +1,14 @@
+package validators
+
+import "time"
+
+// Validator for filter the audit events through the admin api
+type AuditEventListQuery struct {
+	PaginationQuery
+	Actor   string    `form:"actor" binding:"omitempty,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Subject string    `form:"subject" binding:"omitempty,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Action  string    `form:"action" binding:"omitempty,max=50" example:"login"`
+	Outcome string    `form:"outcome" binding:"omitempty,oneof=success failure" example:"failure"`
+	Since   time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00" example:"2021-10-19T08:00:00Z"`
+	Until   time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00" example:"2021-10-20T08:00:00Z"`
+}
@@ -4,6 +4,12 @@ package validators
 type Credentials struct {
 	Email    string `json:"email" binding:"required,email" example:"johndoe@example.com"`
 	Password string `json:"password" binding:"required,min=10" example:"My@appPassw0rd"`
+
+	// Code sent to the phone of the users who use it as second factor
+	PhoneCode string `json:"phone_code" binding:"omitempty,numeric,len=6" example:"123456"`
+
+	// Uuids of the legal documents the user accepts along with the login
+	AcceptedDocuments []string `json:"accepted_documents" binding:"omitempty,dive,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
 }
 
 // Validator for user access tokens data
@@ -11,3 +17,22 @@ type AuthTokens struct {
 	AcessToken   string `json:"access_token" binding:"required" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9"`
 	RefreshToken string `json:"refresh_token" binding:"required" example:"kpvaG4gRG9lIiwiaWF0IjoxNTE2MjM5MDIyf"`
 }
+
+// Validator for ask for a magic link. The link may continue an oauth
+// authorize request.
+type MagicLinkRequestData struct {
+	Email     string              `json:"email" binding:"required,email" example:"johndoe@example.com"`
+	Authorize *OauthAuthorizeData `json:"authorize" binding:"omitempty"`
+}
+
+// Validator for redeem a magic link from the browser which asked for it
+type MagicLinkRedeemData struct {
+	Code    string `json:"code" binding:"required" example:"hG3k0-aPq9Lm2xZ7"`
+	Binding string `json:"binding" binding:"required" example:"Zp4sW9-qLx2Bn7Vt"`
+
+	// Code sent to the phone of the users who use it as second factor
+	PhoneCode string `json:"phone_code" binding:"omitempty,numeric,len=6" example:"123456"`
+
+	// Uuids of the legal documents the user accepts along with the login
+	AcceptedDocuments []string `json:"accepted_documents" binding:"omitempty,dive,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
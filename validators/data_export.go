@@ -0,0 +1,6 @@
+package validators
+
+// Validator for retrieve a data export of the user by its uuid
+type DataExportReadData struct {
+	UUID string `uri:"uuid" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
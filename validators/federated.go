@@ -0,0 +1,50 @@
+package validators
+
+// Validator for retrieve an identity provider by his name
+type IdentityProviderReadData struct {
+	Name string `uri:"name" binding:"required" example:"acme"`
+}
+
+// Validator for create an identity provider. The endpoints which are not
+// given are discovered from the issuer.
+type IdentityProviderCreateData struct {
+	Name             string   `json:"name" binding:"required,alphanum,lowercase,max=32" example:"acme"`
+	DisplayName      string   `json:"display_name" binding:"required" example:"Acme Corp"`
+	Issuer           string   `json:"issuer" binding:"required,url" example:"https://idp.acme.com"`
+	AuthorizationURL string   `json:"authorization_url" binding:"omitempty,url" example:"https://idp.acme.com/authorize"`
+	TokenURL         string   `json:"token_url" binding:"omitempty,url" example:"https://idp.acme.com/token"`
+	JWKSURL          string   `json:"jwks_url" binding:"omitempty,url" example:"https://idp.acme.com/jwks"`
+	ClientID         string   `json:"client_id" binding:"required" example:"gandalf"`
+	ClientSecret     string   `json:"client_secret" binding:"omitempty" example:"s3cr3t"`
+	Scopes           []string `json:"scopes" binding:"omitempty" example:"email,profile"`
+	Disabled         bool     `json:"disabled" example:"false"`
+}
+
+// Validator for update an identity provider
+type IdentityProviderUpdateData struct {
+	DisplayName      string   `json:"display_name" binding:"omitempty" example:"Acme Corp"`
+	AuthorizationURL string   `json:"authorization_url" binding:"omitempty,url" example:"https://idp.acme.com/authorize"`
+	TokenURL         string   `json:"token_url" binding:"omitempty,url" example:"https://idp.acme.com/token"`
+	JWKSURL          string   `json:"jwks_url" binding:"omitempty,url" example:"https://idp.acme.com/jwks"`
+	ClientID         string   `json:"client_id" binding:"omitempty" example:"gandalf"`
+	ClientSecret     string   `json:"client_secret" binding:"omitempty" example:"s3cr3t"`
+	Scopes           []string `json:"scopes" binding:"omitempty" example:"email,profile"`
+	Disabled         *bool    `json:"disabled" binding:"omitempty" example:"false"`
+}
+
+// Validator for start a login with an identity provider
+type FederatedLoginStartData struct {
+	RedirectURI string `json:"redirect_uri" binding:"required,url" example:"https://accounts.example.com/federated/callback"`
+}
+
+// Validator for finish a login with the answer of the identity provider
+type FederatedLoginCallbackData struct {
+	Code  string `json:"code" binding:"required" example:"SplxlOBeZQQYbYS6WxSbIA"`
+	State string `json:"state" binding:"required" example:"hG3k0-aPq9Lm2xZ7"`
+
+	// Code sent to the phone of the users who use it as second factor
+	PhoneCode string `json:"phone_code" binding:"omitempty,numeric,len=6" example:"123456"`
+
+	// Uuids of the legal documents the user accepts along with the login
+	AcceptedDocuments []string `json:"accepted_documents" binding:"omitempty,dive,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
@@ -0,0 +1,34 @@
+package validators
+
+// Validator for retrieve a legal document by its uuid
+type LegalDocumentReadData struct {
+	UUID string `uri:"uuid" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
+
+// Validator for retrieve a legal document of an app by its uuid
+type AppLegalDocumentReadData struct {
+	UUID     string `uri:"uuid" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Document string `uri:"document" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
+
+// Validator for list the current legal documents. The ones of the app with
+// the given client id are listed along with the deployment wide ones.
+type LegalDocumentQueryData struct {
+	ClientID string `form:"client_id" binding:"omitempty,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
+
+// Validator for create a legal document. The document is published apart,
+// so it can be reviewed before the users are asked to accept it.
+type LegalDocumentCreateData struct {
+	Kind      string `json:"kind" binding:"required,oneof=terms privacy" example:"terms"`
+	Version   string `json:"version" binding:"required,max=64" example:"2021-10"`
+	Title     string `json:"title" binding:"required,max=256" example:"Terms of service"`
+	URL       string `json:"url" binding:"required,url" example:"https://example.com/terms/2021-10"`
+	Summary   string `json:"summary" binding:"omitempty,max=1024" example:"We now keep the sessions for 90 days"`
+	Mandatory bool   `json:"mandatory" example:"true"`
+}
+
+// Validator for accept legal documents
+type LegalAcceptanceData struct {
+	Documents []string `json:"documents" binding:"required,min=1,dive,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
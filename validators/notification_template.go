@@ -0,0 +1,33 @@
+package validators
+
+// Validator for read the notification template of a kind and locale
+type NotificationTemplateReadData struct {
+	Kind   string `uri:"kind" binding:"required,oneof=user-verify-email user-change-password user-signup-attempt organization-invitation user-magic-link user-data-export guardian-consent alert-password-changed alert-app-authorized alert-new-device alert-impersonated alert-phone-changed" example:"user-verify-email"`
+	Locale string `uri:"locale" binding:"required,bcp47_language_tag" example:"es"`
+}
+
+// Validator for read the notification template of a kind and locale
+// overridden by an app
+type AppNotificationTemplateReadData struct {
+	NotificationTemplateReadData
+	UUID string `uri:"uuid" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
+
+// Validator for override a notification template. Templates are written
+// with the go template syntax.
+type NotificationTemplateData struct {
+	Subject string `json:"subject" binding:"required" example:"Welcome {{.Name}}"`
+	Text    string `json:"text" binding:"required" example:"Verify your email: {{.Link}}"`
+	HTML    string `json:"html" binding:"omitempty" example:"<a href=\"{{.Link}}\">Verify your email</a>"`
+}
+
+// Validator for preview a notification. The given subject, text and html
+// are previewed instead of the stored templates when present.
+type NotificationTemplatePreviewData struct {
+	Kind     string `json:"kind" binding:"required,oneof=user-verify-email user-change-password user-signup-attempt organization-invitation user-magic-link user-data-export guardian-consent alert-password-changed alert-app-authorized alert-new-device alert-impersonated alert-phone-changed" example:"user-verify-email"`
+	Locale   string `json:"locale" binding:"required,bcp47_language_tag" example:"es"`
+	ClientID string `json:"client_id" binding:"omitempty,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Subject  string `json:"subject" binding:"required_with=Text HTML" example:"Welcome {{.Name}}"`
+	Text     string `json:"text" binding:"required_with=Subject HTML" example:"Verify your email: {{.Link}}"`
+	HTML     string `json:"html" binding:"omitempty" example:"<a href=\"{{.Link}}\">Verify your email</a>"`
+}
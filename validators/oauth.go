@@ -8,6 +8,10 @@ type OauthAuthorizeData struct {
 	RedirectURI string           `json:"redirect_uri" binding:"required,url" example:"http://yourredirecturl.dev"`
 	Scopes      []bindings.Scope `json:"scopes" binding:"required" example:"user:read"`
 	State       string           `json:"state" binding:"omitempty" example:"iuywerghiuhg3487"`
+
+	// Uuids of the legal documents the user accepts along with the
+	// authorization, including the ones of the app
+	AcceptedDocuments []string `json:"accepted_documents" binding:"omitempty,dive,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
 }
 
 // Validator struct for oauth token exchange
@@ -18,3 +22,10 @@ type OauthExchangeToken struct {
 	AuthorizationCode string `json:"code" form:"code" binding:"required" example:"iwuqebgrfweiur4"`
 	RedirectUrl       string `json:"redirect_uri" form:"redirect_uri" binding:"required,url" example:"http://callback"`
 }
+
+// Validator struct for oauth end session
+type OauthEndSessionData struct {
+	IDTokenHint           string `json:"id_token_hint" form:"id_token_hint" binding:"required" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ8"`
+	PostLogoutRedirectURI string `json:"post_logout_redirect_uri" form:"post_logout_redirect_uri" binding:"omitempty,url" example:"http://yourlogouturl.dev"`
+	State                 string `json:"state" form:"state" binding:"omitempty" example:"iuywerghiuhg3487"`
+}
@@ -0,0 +1,38 @@
+package validators
+
+// Validator for organization creation
+type OrganizationCreateData struct {
+	Name string `json:"name" binding:"required,max=100" example:"Antartical"`
+}
+
+// Validator for organization update
+type OrganizationUpdateData struct {
+	Name string `json:"name" binding:"omitempty,max=100" example:"Antartical"`
+}
+
+// Validator for retrieve organization by his uuid
+type OrganizationReadData struct {
+	UUID string `uri:"uuid" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
+
+// Validator for retrieve an organization member
+type OrganizationMemberData struct {
+	UUID     string `uri:"uuid" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	UserUUID string `uri:"user" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
+
+// Validator for change the role of an organization member
+type OrganizationMemberRoleData struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member" example:"admin"`
+}
+
+// Validator for invite an email to join an organization
+type OrganizationInviteData struct {
+	Email string `json:"email" binding:"required,email" example:"johndoe@example.com"`
+	Role  string `json:"role" binding:"required,oneof=owner admin member" example:"member"`
+}
+
+// Validator for accept an organization invitation with the code mailed
+type OrganizationAcceptInvitationData struct {
+	Code string `json:"code" binding:"required" example:"hG3k0-aPq9Lm2xZ7"`
+}
@@ -0,0 +1,7 @@
+package validators
+
+// Validator for filter the outbox messages through the admin api
+type OutboxMessageListQuery struct {
+	PaginationQuery
+	Status string `form:"status" binding:"omitempty,oneof=pending delivered dead" example:"dead"`
+}
@@ -6,6 +6,11 @@ type PelipperUserVerifyEmail struct {
 	Name             string `binding:"required"`
 	Subject          string `binding:"required"`
 	VerificationLink string `binding:"required"`
+
+	// Rendered content, in the locale of the recipient
+	Locale string
+	Text   string
+	HTML   string
 }
 
 // Validator for send change password email with pelipper
@@ -14,4 +19,94 @@ type PelipperUserChangePassword struct {
 	Name               string `binding:"required"`
 	Subject            string `binding:"required"`
 	ChangePasswordLink string `binding:"required"`
+
+	// Rendered content, in the locale of the recipient
+	Locale string
+	Text   string
+	HTML   string
+}
+
+// Validator for send the sign up attempt notice with pelipper
+type PelipperUserSignupAttempt struct {
+	Email   string `binding:"required,email"`
+	Name    string `binding:"required"`
+	Subject string `binding:"required"`
+
+	// Rendered content, in the locale of the recipient
+	Locale string
+	Text   string
+	HTML   string
+}
+
+// Validator for send an organization invitation with pelipper
+type PelipperOrganizationInvitation struct {
+	Email            string `binding:"required,email"`
+	Subject          string `binding:"required"`
+	OrganizationName string `binding:"required"`
+	InvitedBy        string `binding:"required"`
+	InvitationLink   string `binding:"required"`
+
+	// Rendered content, in the locale of the recipient
+	Locale string
+	Text   string
+	HTML   string
+}
+
+// Validator for send a magic link with pelipper
+type PelipperUserMagicLink struct {
+	Email     string `binding:"required,email"`
+	Name      string `binding:"required"`
+	Subject   string `binding:"required"`
+	MagicLink string `binding:"required"`
+
+	// Rendered content, in the locale of the recipient
+	Locale string
+	Text   string
+	HTML   string
+}
+
+// Validator for send the link to download the data export of the user
+// with pelipper
+type PelipperUserDataExport struct {
+	Email      string `binding:"required,email"`
+	Name       string `binding:"required"`
+	Subject    string `binding:"required"`
+	ExportLink string `binding:"required"`
+
+	// Rendered content, in the locale of the recipient
+	Locale string
+	Text   string
+	HTML   string
+}
+
+// Validator for ask the guardian of a minor to consent to his sign-up with
+// pelipper. The email is the guardian one, and the name the minor one.
+type PelipperGuardianConsent struct {
+	Email       string `binding:"required,email"`
+	Name        string `binding:"required"`
+	Subject     string `binding:"required"`
+	ConsentLink string `binding:"required"`
+
+	// Rendered content, in the locale of the minor
+	Locale string
+	Text   string
+	HTML   string
+}
+
+// Validator for send a security alert with pelipper. Device, ip and app
+// name are only filled for the alerts they apply to.
+type PelipperSecurityAlert struct {
+	Email    string `binding:"required,email"`
+	Name     string `binding:"required"`
+	Subject  string `binding:"required"`
+	Alert    string `binding:"required"`
+	LockLink string `binding:"required"`
+	AppName  string
+	Device   string
+	IP       string
+
+	// Rendered content, in the locale of the recipient
+	Locale string
+	Text   string
+	HTML   string
 }
@@ -0,0 +1,33 @@
+package validators
+
+// Validator for a code sent by SMS to the phone of the user
+type PhoneCodeData struct {
+	Code string `json:"code" binding:"required,numeric,len=6" example:"123456"`
+}
+
+// Validator for the proof asked for before changing the phone or turning
+// it off as second factor: a code sent to the verified phone for
+// confirmation, or the current password
+type PhoneProofData struct {
+	PhoneCode       string `json:"phone_code" binding:"omitempty,numeric,len=6" example:"123456"`
+	CurrentPassword string `json:"current_password" example:"My@appPassw0rd"`
+}
+
+// Validator for turn on or off the phone as second factor
+type PhoneMFAData struct {
+	Enabled *bool `json:"enabled" binding:"required" example:"true"`
+	PhoneProofData
+}
+
+// Validator for ask for a recovery code sent to the phone of an user
+type PhoneRecoveryData struct {
+	Email string `json:"email" binding:"required,email" example:"johndoe@example.com"`
+}
+
+// Validator for reset the password of an user with the recovery code sent
+// to his phone
+type PhoneResetPasswordData struct {
+	Email    string `json:"email" binding:"required,email" example:"johndoe@example.com"`
+	Code     string `json:"code" binding:"required,numeric,len=6" example:"123456"`
+	Password string `json:"password" binding:"min=10,required" example:"My@appPassw0rd"`
+}
@@ -0,0 +1,14 @@
+package validators
+
+// Validator struct for the SAML authentication requests. The SSO endpoint
+// reads it from the query or the form, as the binding requires, and the
+// login page sends it back once the user has logged in. The relay state is
+// limited to 80 bytes by the SAML bindings.
+type SAMLRequestData struct {
+	SAMLRequest string `form:"SAMLRequest" json:"saml_request" binding:"required" example:"PHNhbWxwOkF1dGhuUmVxdWVzdCB4bWxuczpzYW1scD0idXJu"`
+	RelayState  string `form:"RelayState" json:"relay_state" binding:"omitempty,max=80" example:"/dashboard"`
+
+	// Uuids of the legal documents the user accepts when the login page
+	// sends the request back, including the ones of the app
+	AcceptedDocuments []string `form:"-" json:"accepted_documents" binding:"omitempty,dive,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
@@ -0,0 +1,79 @@
+package validators
+
+import "encoding/json"
+
+// Validator for the list queries of the SCIM resources. The start index is
+// 1-based as SCIM requires.
+type SCIMListQuery struct {
+	Filter     string `form:"filter" binding:"omitempty,max=512" example:"userName eq \"johndoe@example.com\""`
+	StartIndex int    `form:"startIndex" binding:"omitempty,min=1" example:"1"`
+	Count      int    `form:"count" binding:"omitempty,min=0" example:"100"`
+
+	// Attributes left out of the response, like the members of the groups
+	ExcludedAttributes string `form:"excludedAttributes" example:"members"`
+}
+
+// Validator for retrieve a SCIM resource by its id
+type SCIMResourceData struct {
+	ID string `uri:"id" binding:"required,uuid" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
+
+// Name of a SCIM user
+type SCIMName struct {
+	GivenName  string `json:"givenName" example:"John"`
+	FamilyName string `json:"familyName" example:"Doe"`
+	Formatted  string `json:"formatted" example:"John Doe"`
+}
+
+// Multi-valued attribute of a SCIM resource, like the emails of a user or
+// the members of a group
+type SCIMValue struct {
+	Value   string `json:"value" example:"johndoe@example.com"`
+	Type    string `json:"type" example:"work"`
+	Primary bool   `json:"primary" example:"true"`
+	Display string `json:"display" example:"John Doe"`
+}
+
+// Validator for the creation and replacement of SCIM users. The user name
+// must be the email of the user, which is his identifier in gandalf.
+type SCIMUserData struct {
+	Schemas    []string    `json:"schemas"`
+	UserName   string      `json:"userName" binding:"required,email" example:"johndoe@example.com"`
+	ExternalID string      `json:"externalId" binding:"max=255" example:"00u1a2b3c4"`
+	Name       SCIMName    `json:"name"`
+	Emails     []SCIMValue `json:"emails"`
+	Locale     string      `json:"locale" binding:"omitempty,bcp47_language_tag" example:"es-ES"`
+	Active     *bool       `json:"active" example:"true"`
+	Password   string      `json:"password" binding:"omitempty,min=10" example:"My@appPassw0rd"`
+}
+
+// Validator for the creation and replacement of SCIM groups
+type SCIMGroupData struct {
+	Schemas     []string    `json:"schemas"`
+	DisplayName string      `json:"displayName" binding:"required,max=100" example:"engineering"`
+	Members     []SCIMValue `json:"members"`
+}
+
+// A single operation of a SCIM PATCH request. The value is decoded by the
+// operation, since it may be a single value, a list or an object.
+type SCIMPatchOperation struct {
+	Op    string          `json:"op" binding:"required" example:"replace"`
+	Path  string          `json:"path" example:"active"`
+	Value json.RawMessage `json:"value" swaggertype:"object"`
+}
+
+// Validator for the SCIM PATCH requests
+type SCIMPatchData struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations" binding:"required,min=1,dive"`
+}
+
+// Validator for the creation of SCIM tokens
+type SCIMTokenCreateData struct {
+	Name string `json:"name" binding:"required,max=100" example:"Workday"`
+}
+
+// Validator for retrieve a SCIM token by its uuid
+type SCIMTokenReadData struct {
+	UUID string `uri:"uuid" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
@@ -0,0 +1,6 @@
+package validators
+
+// Validator for retrieve a session of the user by his uuid
+type SessionReadData struct {
+	UUID string `uri:"uuid" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
@@ -4,7 +4,8 @@ import "gandalf/bindings"
 
 // Validator for resend email notification to an user
 type UserResendEmail struct {
-	Email string `json:"email" binding:"required,email" example:"johndoe@example.com"`
+	Email    string `json:"email" binding:"required,email" example:"johndoe@example.com"`
+	ClientID string `json:"client_id" binding:"omitempty,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
 }
 
 // Validator for user creation
@@ -15,6 +16,16 @@ type UserCreateData struct {
 	Surname  string             `json:"surname" binding:"required" example:"Doe"`
 	Birthday bindings.BirthDate `json:"birthday" binding:"required" example:"1997-12-21"`
 	Phone    string             `json:"phone" binding:"omitempty,e164" example:"+34666123456"`
+	Locale   string             `json:"locale" binding:"omitempty,bcp47_language_tag" example:"es-ES"`
+	ClientID string             `json:"client_id" binding:"omitempty,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+
+	// Minors must give the email of a guardian, who consents to their
+	// sign-up
+	GuardianEmail string `json:"guardian_email" binding:"omitempty,email" example:"janedoe@example.com"`
+
+	// Users provisioned by a directory may come without birthday, so their
+	// unknown age does not hold their sign-up
+	Provisioned bool `json:"-"`
 }
 
 // Validator for retrieve user by his uuid
@@ -22,8 +33,14 @@ type UserReadData struct {
 	UUID string `uri:"uuid" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
 }
 
-// Validator for reset user password
+// Validator for verify an user with the code mailed to him
+type UserVerifyData struct {
+	Code string `json:"code" binding:"required" example:"hG3k0-aPq9Lm2xZ7"`
+}
+
+// Validator for reset user password with the code mailed to him
 type UserResetPasswordData struct {
+	Code     string `json:"code" binding:"required" example:"hG3k0-aPq9Lm2xZ7"`
 	Password string `json:"password" binding:"min=10,required" example:"My@appPassw0rd"`
 }
 
@@ -31,4 +48,23 @@ type UserResetPasswordData struct {
 type UserUpdateData struct {
 	Password string `json:"password" binding:"omitempty,min=10" example:"My@appPassw0rd"`
 	Phone    string `json:"phone" binding:"omitempty,e164" example:"+34666123456"`
+	Locale   string `json:"locale" binding:"omitempty,bcp47_language_tag" example:"es-ES"`
+
+	// Proof asked for when the phone is changed
+	PhoneProofData
+
+	// Security alerts preferences
+	AppAuthorizedAlerts *bool `json:"app_authorized_alerts" example:"true"`
+	NewDeviceAlerts     *bool `json:"new_device_alerts" example:"false"`
+}
+
+// Validator for consent to the sign-up of a minor with the code mailed to
+// his guardian
+type GuardianConsentData struct {
+	Code string `json:"code" binding:"required" example:"hG3k0-aPq9Lm2xZ7"`
+}
+
+// Validator for lock an user account with the code of a security alert
+type UserLockData struct {
+	Code string `json:"code" binding:"required" example:"hG3k0-aPq9Lm2xZ7"`
 }
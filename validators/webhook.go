@@ -0,0 +1,26 @@
+package validators
+
+// Validator struct for webhook creation
+type WebhookCreateData struct {
+	Url    string   `json:"url" binding:"required,url" example:"https://yourapp.dev/webhooks"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=user.created user.verified user.deleted user.purged app.connected app.disconnected" example:"user.deleted"`
+}
+
+// Validator struct for webhook update
+type WebhookUpdateData struct {
+	Url    string   `json:"url" binding:"omitempty,url" example:"https://yourapp.dev/webhooks"`
+	Events []string `json:"events" binding:"omitempty,min=1,dive,oneof=user.created user.verified user.deleted user.purged app.connected app.disconnected" example:"user.deleted"`
+	Active *bool    `json:"active" example:"true"`
+}
+
+// Validator for read a webhook of an app
+type WebhookReadData struct {
+	UUID    string `uri:"uuid" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+	Webhook string `uri:"webhook" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}
+
+// Validator for read a delivery of a webhook
+type WebhookDeliveryReadData struct {
+	WebhookReadData
+	Delivery string `uri:"delivery" binding:"required,uuid4" example:"4722679b-5a48-4e85-9084-605e8df610f4"`
+}